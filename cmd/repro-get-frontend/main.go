@@ -0,0 +1,17 @@
+// repro-get-frontend is the BuildKit gateway frontend for building images from the hash files.
+//
+// Usage: "# syntax=<FRONTEND IMAGE>" in the build definition file. See pkg/frontend.
+package main
+
+import (
+	"github.com/moby/buildkit/frontend/gateway/grpcclient"
+	"github.com/moby/buildkit/util/appcontext"
+	"github.com/reproducible-containers/repro-get/pkg/frontend"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	if err := grpcclient.RunFromEnvironment(appcontext.Context(), frontend.Build); err != nil {
+		logrus.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/attest"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/reproducible-containers/repro-get/pkg/version"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newAttestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attest [flags] SHA256SUMS...",
+		Short: "Generate the in-toto attestation (SLSA provenance) of the hash files",
+		Long: `Generate the in-toto attestation of the hash files, with the SLSA provenance predicate.
+The attestation describes the hash files (as the subjects), the repository indexes ("#repro-get:index=..."),
+the snapshots, the providers (--provider), and the version of repro-get.
+
+With --sign, the attestation is signed with the keyless flow of cosign, and the Sigstore bundle is written as "<OUTPUT>` + hashsig.SigstoreBundleSuffix + `".
+The bundle can be verified with 'cosign verify-blob --bundle <OUTPUT>` + hashsig.SigstoreBundleSuffix + ` <OUTPUT>'.
+
+The "startedOn" metadata is taken from $SOURCE_DATE_EPOCH, when it is set.`,
+		Example: "  repro-get attest --output=SHA256SUMS-" + archutil.OCIArchDashVariant() + ".intoto.json SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n\n" +
+			"  # Sigstore (e.g., on GitHub Actions with \"permissions: id-token: write\")\n" +
+			"  repro-get attest --sign --output=SHA256SUMS-" + archutil.OCIArchDashVariant() + ".intoto.json SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(1),
+		RunE: attestAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.StringP("output", "o", "", "Output file (default: stdout)")
+	flags.Bool("sign", false, "Sign the attestation with the keyless flow of Sigstore (needs cosign and --output)")
+	return cmd
+}
+
+func attestAction(cmd *cobra.Command, args []string) error {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	flags := cmd.Flags()
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+	sign, err := flags.GetBool("sign")
+	if err != nil {
+		return err
+	}
+	if sign && output == "" {
+		return errors.New("--sign needs --output")
+	}
+	opts := attest.Opts{
+		Distro:      d.Info().Name,
+		ToolVersion: version.GetVersion(),
+	}
+	if opts.Providers, err = getProviders(cmd); err != nil {
+		return err
+	}
+	if len(opts.Providers) == 0 {
+		opts.Providers = d.Info().DefaultProviders
+	}
+	if opts.Created, err = sourceDateEpoch(); err != nil {
+		return err
+	}
+	st, err := attest.New(args, opts)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "    ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if output == "" {
+		_, err = cmd.OutOrStdout().Write(b)
+		return err
+	}
+	if err = os.WriteFile(output, b, 0644); err != nil {
+		return err
+	}
+	logrus.Infof("Wrote the attestation to %q", output)
+	if sign {
+		if err = hashsig.SignFileWithSigstore(cmd.Context(), output); err != nil {
+			return err
+		}
+		logrus.Infof("Wrote the Sigstore bundle of %q to %q", output, output+hashsig.SigstoreBundleSuffix)
+	}
+	return nil
+}
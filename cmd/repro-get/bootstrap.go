@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/downloader"
+	"github.com/reproducible-containers/repro-get/pkg/envutil"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newBootstrapCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bootstrap [flags] SHA256SUMS...",
+		Short: "Create a root filesystem from the packages in the hash files",
+		Long: `Create a root filesystem from the packages in the hash files, without debootstrap.
+The packages are extracted into the root filesystem, and the package database is written.
+The hash files have to contain all the packages of the root filesystem, including the essential packages.
+
+The maintainer scripts are not executed by default (--scripts=skip).
+With --scripts=chroot, "dpkg --configure -a" is executed in the root filesystem with chroot(8) (Debian and Ubuntu only);
+this needs the root privilege, and the architecture of the packages has to be runnable on the host.
+The "preinst" scripts are not executed even with --scripts=chroot.
+
+The modification times of the files that are not extracted from the packages (e.g., the package database)
+are set to $SOURCE_DATE_EPOCH, or to the build time of the newest package (see 'repro-get hash source-date-epoch'),
+so that the root filesystem is reproducible.
+
+Debian, Ubuntu, and Alpine only.`,
+		Example: "  repro-get bootstrap --root=/mnt/rootfs SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get bootstrap --root=/mnt/rootfs --arch=arm64 SHA256SUMS",
+		Args: cobra.MinimumNArgs(1),
+		RunE: bootstrapAction,
+
+		ValidArgsFunction:     completeHashFiles,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("root", "", "Root filesystem to be created; must not exist, or must be empty (required)")
+	addBootstrapFlags(cmd)
+	return cmd
+}
+
+// addBootstrapFlags adds the flags for runBootstrap.
+func addBootstrapFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\", for the hash files with the architecture sections (\"#repro-get:arch=...\")")
+	flags.String("scripts", string(distro.BootstrapScriptsSkip), "How to handle the maintainer scripts: \"skip\" or \"chroot\"")
+	flags.Bool("merged-usr", true, "Create the symlinks such as \"/bin\" -> \"usr/bin\" (Debian and Ubuntu only)")
+	flags.String("source-date-epoch", envutil.String("SOURCE_DATE_EPOCH", ""), "Modification time of the files that are not extracted from the packages, in seconds since the epoch (default: the build time of the newest package) [$SOURCE_DATE_EPOCH]")
+}
+
+func bootstrapAction(cmd *cobra.Command, args []string) error {
+	root, err := cmd.Flags().GetString("root")
+	if err != nil {
+		return err
+	}
+	if root == "" {
+		return errors.New("--root needs to be specified")
+	}
+	_, err = runBootstrap(cmd, root, args)
+	return err
+}
+
+// runBootstrap creates the root filesystem from the hash files, with the flags added by addBootstrapFlags.
+// Returns the value of --source-date-epoch, or the build time of the newest package when the flag is not specified.
+func runBootstrap(cmd *cobra.Command, root string, hashFiles []string) (*time.Time, error) {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return nil, err
+	}
+	bootstrapper, ok := d.(distro.Bootstrapper)
+	if !ok {
+		return nil, fmt.Errorf("distro driver %q does not support bootstrapping", d.Info().Name)
+	}
+	flags := cmd.Flags()
+	opts := distro.BootstrapOpts{Root: root}
+	scripts, err := flags.GetString("scripts")
+	if err != nil {
+		return nil, err
+	}
+	opts.Scripts = distro.BootstrapScripts(scripts)
+	if opts.MergedUsr, err = flags.GetBool("merged-usr"); err != nil {
+		return nil, err
+	}
+	if opts.SourceDateEpoch, err = getSourceDateEpoch(cmd); err != nil {
+		return nil, err
+	}
+	arch, err := flags.GetString("arch")
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := newCache(cmd)
+	if err != nil {
+		return nil, err
+	}
+	fileSpecs, err := newVerifiedFileSpecs(cmd, hashFiles, arch)
+	if err != nil {
+		return nil, err
+	}
+	downloadRes, err := runDownloader(cmd, d, cache, fileSpecs, downloader.Opts{})
+	if err != nil {
+		return nil, err
+	}
+	if opts.SourceDateEpoch == nil {
+		if opts.SourceDateEpoch, err = newestPackageTime(d, cache, downloadRes.PackagesToBeInstalled); err != nil {
+			return nil, err
+		}
+		if opts.SourceDateEpoch != nil {
+			logrus.Infof("Using the build time of the newest package as SOURCE_DATE_EPOCH: %d", opts.SourceDateEpoch.Unix())
+		}
+	}
+	if _, err = verifyPackageSignatures(cmd, d, cache, downloadRes.PackagesToBeInstalled); err != nil {
+		return nil, err
+	}
+	if err = bootstrapper.Bootstrap(cmd.Context(), cache, downloadRes.PackagesToBeInstalled, opts); err != nil {
+		return nil, err
+	}
+	return opts.SourceDateEpoch, nil
+}
+
+// getSourceDateEpoch returns the value of --source-date-epoch, or nil if not specified.
+func getSourceDateEpoch(cmd *cobra.Command) (*time.Time, error) {
+	sourceDateEpoch, err := cmd.Flags().GetString("source-date-epoch")
+	if err != nil || sourceDateEpoch == "" {
+		return nil, err
+	}
+	sec, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --source-date-epoch %q: %w", sourceDateEpoch, err)
+	}
+	t := time.Unix(sec, 0)
+	return &t, nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/builder"
+	"github.com/reproducible-containers/repro-get/pkg/dockerfileutil"
+	"github.com/reproducible-containers/repro-get/pkg/envutil"
+	"github.com/reproducible-containers/repro-get/pkg/imageconfig"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newBuildCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build [flags] [DIR] [-- BUILDER_FLAGS...]",
+		Short: "Build a container image with docker buildx, nerdctl, podman, or buildah",
+		Long: `Build a container image from the Dockerfile in DIR (default: the current directory), with docker buildx, nerdctl, podman, or buildah.
+
+The following steps are executed:
+- Generate "Dockerfile" from the image config ("image.yaml") in DIR, if "Dockerfile" does not exist (see 'repro-get image dockerfile')
+- Copy the repro-get binaries into DIR as "repro-get.linux-<ARCH>", if they do not exist
+- Build "Dockerfile.generate-hash" into "SHA256SUMS-<ARCH>", if the hash files do not exist
+- Build "Dockerfile" for the platforms
+- Remove the copied repro-get binaries
+
+The platforms default to Archs of the image config, or to the host platform.
+The "Dockerfile" consumes the hash file of each platform ("SHA256SUMS-${TARGETARCH}"), so a single build yields
+the multi-platform image (manifest list) with the same packages as the single-platform builds.
+The repro-get binaries for the architectures other than the host architecture are copied from the directory of
+the repro-get binary (e.g., "/usr/local/bin/repro-get.linux-arm64"), or have to be placed in DIR in advance.
+
+The builder is detected from $PATH in the order of docker, nerdctl, podman, and buildah, unless --builder is specified.
+For podman and buildah, the multi-platform image is created with "--manifest=<TAG>".
+The flags after "--" are passed to the builder.`,
+		Example: "  repro-get build --tag=example.com/foo:latest .\n" +
+			"  repro-get build --builder=podman --build-arg=SOURCE_DATE_EPOCH=1639958400 --tag=foo .\n" +
+			"  repro-get build --platform=linux/amd64,linux/arm64 --output=type=image,name=example.com/foo,push=true . -- --no-cache",
+		Args: cobra.ArbitraryArgs,
+		RunE: buildAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("builder", envutil.String("REPRO_GET_BUILDER", string(builder.Auto)), "Builder: \"auto\", \"docker\" (docker buildx), \"nerdctl\", \"podman\", or \"buildah\" [$REPRO_GET_BUILDER]")
+	flags.String("file", "", "Dockerfile (default: \"DIR/Dockerfile\")")
+	flags.String("config", "", "Image config for generating the Dockerfile (default: \"DIR/"+imageconfig.DefaultFilename+"\", when the Dockerfile does not exist)")
+	flags.StringSliceP("tag", "t", nil, "Image name, such as \"example.com/foo:latest\"")
+	flags.StringSlice("platform", nil, "Target platforms, such as \"linux/amd64\" and \"linux/arm/v7\" (default: the host platform)")
+	flags.StringArray("build-arg", nil, "Build arg, such as \"SOURCE_DATE_EPOCH=1639958400\"")
+	flags.StringArray("secret", nil, "Build secret, such as \"id=netrc,src=$HOME/.netrc\"")
+	flags.String("output", "", "Output, such as \"type=local,dest=DIR\" and \"type=image,name=example.com/foo,push=true\" (default: the image store of the builder)")
+	addDockerfileGenerateFlags(cmd)
+	return cmd
+}
+
+func buildAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	dir := "."
+	var extraArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		args, extraArgs = args[:dash], args[dash:]
+	}
+	switch len(args) {
+	case 0:
+	case 1:
+		dir = args[0]
+	default:
+		return fmt.Errorf("expected at most 1 directory, got %v", args)
+	}
+
+	builderName, err := flags.GetString("builder")
+	if err != nil {
+		return err
+	}
+	b := builder.Builder(builderName)
+	if err = b.Validate(); err != nil {
+		return err
+	}
+	if b == builder.Auto {
+		if b, err = builder.Detect(); err != nil {
+			return err
+		}
+		logrus.Infof("Using builder %q", b)
+	}
+
+	opts := builder.Opts{Context: dir, ExtraArgs: extraArgs}
+	if opts.File, err = flags.GetString("file"); err != nil {
+		return err
+	}
+	if opts.File == "" {
+		opts.File = filepath.Join(dir, "Dockerfile")
+	}
+	if opts.Platforms, err = flags.GetStringSlice("platform"); err != nil {
+		return err
+	}
+	if opts.BuildArgs, err = flags.GetStringArray("build-arg"); err != nil {
+		return err
+	}
+	secrets, err := flags.GetStringArray("secret")
+	if err != nil {
+		return err
+	}
+	for _, f := range secrets {
+		secret, err := builder.ParseSecret(f)
+		if err != nil {
+			return err
+		}
+		opts.Secrets = append(opts.Secrets, *secret)
+	}
+
+	cfg, err := loadBuildImageConfig(cmd, dir, opts.File)
+	if err != nil {
+		return err
+	}
+	if cfg != nil {
+		if !flags.Changed("platform") {
+			opts.Platforms = nil
+			for _, arch := range cfg.Archs {
+				opts.Platforms = append(opts.Platforms, dockerfileutil.ArchDashVariantPlatform(arch))
+			}
+			if len(opts.Platforms) > 1 { // For generating the bake file too
+				if err = flags.Set("platform", strings.Join(opts.Platforms, ",")); err != nil {
+					return err
+				}
+			}
+		}
+		logrus.Infof("Generating %q from the image config", opts.File)
+		if _, err = generateImageDockerfile(cmd, cfg, dir); err != nil {
+			return err
+		}
+	}
+
+	archs, err := platformArchs(opts.Platforms)
+	if err != nil {
+		return err
+	}
+	for _, arch := range archs {
+		copied, err := ensureReproGetBinary(dir, arch)
+		if err != nil {
+			return err
+		}
+		if copied != "" {
+			defer os.Remove(copied)
+		}
+	}
+
+	ctx := cmd.Context()
+	for i, arch := range archs {
+		hashFile := filepath.Join(dir, imageconfig.HashFile(arch))
+		if _, err := os.Stat(hashFile); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		generateHashDockerfile := filepath.Join(dir, "Dockerfile.generate-hash")
+		if _, err := os.Stat(generateHashDockerfile); err != nil {
+			return fmt.Errorf("the hash file %q does not exist, and failed to find %q: %w", hashFile, generateHashDockerfile, err)
+		}
+		logrus.Infof("Generating %q", hashFile)
+		hashOpts := builder.Opts{
+			Context:   dir,
+			File:      generateHashDockerfile,
+			BuildArgs: opts.BuildArgs,
+			Secrets:   opts.Secrets,
+			Output:    "type=local,dest=" + dir,
+			ExtraArgs: opts.ExtraArgs,
+		}
+		if len(opts.Platforms) > 0 {
+			hashOpts.Platforms = []string{opts.Platforms[i]}
+		}
+		if err = b.Build(ctx, hashOpts); err != nil {
+			return err
+		}
+		if _, err := os.Stat(hashFile); err != nil {
+			return fmt.Errorf("failed to generate the hash file %q: %w", hashFile, err)
+		}
+	}
+
+	if opts.Tags, err = flags.GetStringSlice("tag"); err != nil {
+		return err
+	}
+	if opts.Output, err = flags.GetString("output"); err != nil {
+		return err
+	}
+	if len(opts.Platforms) > 1 && b == builder.Docker && opts.Output == "" {
+		logrus.Warn("The multi-platform image may fail to be loaded into the image store of Docker, " +
+			"consider specifying --output=type=image,name=<IMAGE>,push=true, or --output=type=oci,dest=<FILE>")
+	}
+	return b.Build(ctx, opts)
+}
+
+// loadBuildImageConfig loads the image config for generating the Dockerfile, if the Dockerfile does not exist.
+// Returns nil if the Dockerfile exists.
+func loadBuildImageConfig(cmd *cobra.Command, dir, dockerfile string) (*imageconfig.Config, error) {
+	if _, err := os.Stat(dockerfile); !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if dockerfile != filepath.Join(dir, "Dockerfile") {
+		return nil, fmt.Errorf("%q does not exist", dockerfile)
+	}
+	flags := cmd.Flags()
+	if !flags.Changed("config") {
+		if err := flags.Set("config", filepath.Join(dir, imageconfig.DefaultFilename)); err != nil {
+			return nil, err
+		}
+	}
+	cfgFile, err := flags.GetString("config")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(cfgFile); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("neither %q nor %q exists, run 'repro-get dockerfile generate' or create the image config", dockerfile, cfgFile)
+	}
+	cfg, _, err := loadImageConfig(cmd)
+	return cfg, err
+}
+
+// ensureReproGetBinary copies the repro-get binary into dir as "repro-get.linux-<ARCH>", if it does not exist.
+// The binaries for the architectures other than the host architecture are looked up in the directory of the repro-get binary.
+// Returns the path of the copied binary, or an empty string if the binary already exists.
+func ensureReproGetBinary(dir, arch string) (string, error) {
+	dst := filepath.Join(dir, "repro-get.linux-"+arch)
+	if _, err := os.Stat(dst); !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	src := self
+	if arch != archutil.OCIArchDashVariant() {
+		// e.g., "/usr/local/bin/repro-get.linux-arm64"
+		src = filepath.Join(filepath.Dir(self), "repro-get.linux-"+arch)
+		if _, err := os.Stat(src); err != nil {
+			return "", fmt.Errorf("neither %q nor %q exists (needed for building the image for %q): %w", dst, src, arch, err)
+		}
+	}
+	logrus.Debugf("Copying %q to %q", src, dst)
+	if err = copyFile(dst, src, 0755); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	return dst, nil
+}
+
+func copyFile(dst, src string, perm os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// platformArchs converts the platforms such as "linux/arm/v7" into the architectures such as "arm-v7".
+// Returns the host architecture when no platform is specified.
+func platformArchs(platforms []string) ([]string, error) {
+	if len(platforms) == 0 {
+		return []string{archutil.OCIArchDashVariant()}, nil
+	}
+	archs := make([]string, len(platforms))
+	for i, p := range platforms {
+		arch, err := dockerfileutil.PlatformArchDashVariant(p)
+		if err != nil {
+			return nil, err
+		}
+		archs[i] = arch
+	}
+	return archs, nil
+}
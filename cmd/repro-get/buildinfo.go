@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newBuildinfoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "buildinfo [flags] SHA256SUMS...",
+		Short: "Fetch and verify the build information files of the packages in the hash files",
+		Long: `Fetch the build information files (*.buildinfo) of the packages in the hash files, and verify that they record the hashes of the packages.
+The build information files are stored in the cache, and optionally in the output dir (--output-dir),
+for auditing the reproducibility of the packages by rebuilding them from the sources.
+
+The hashes of the build information files are printed in the format of SHA256SUMS, so that they can be pinned too.
+
+The signatures of the build information files are verified only when --keyring is specified.
+
+Debian only, by default. The build information files for Ubuntu need --buildinfo-provider.`,
+		Example: "  repro-get buildinfo --output-dir=buildinfo SHA256SUMS-" + archutil.OCIArchDashVariant() + " >SHA256SUMS-buildinfo",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    buildinfoAction,
+
+		ValidArgsFunction:     completeHashFiles,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\", for the hash files with the architecture sections (\"#repro-get:arch=...\")")
+	flags.StringSlice("buildinfo-provider", nil, "URL templates of the build information files, such as \"https://buildinfos.debian.net/buildinfo-pool/{{.Prefix}}/{{.Source}}/{{.Source}}_{{.Version}}_{{.Architecture}}.buildinfo\" (default: the default of the distro)")
+	flags.StringSlice("keyring", nil, "OpenPGP keyrings for verifying the signatures of the build information files (default: not verified)")
+	flags.String("output-dir", "", "Directory to store the build information files, in addition to the cache")
+	flags.Bool("json", false, "Enable JSON output")
+	return cmd
+}
+
+func buildinfoAction(cmd *cobra.Command, args []string) error {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	fetcher, ok := d.(distro.BuildinfoFetcher)
+	if !ok {
+		return fmt.Errorf("distro driver %q does not support fetching the build information files", d.Info().Name)
+	}
+	flags := cmd.Flags()
+	arch, err := flags.GetString("arch")
+	if err != nil {
+		return err
+	}
+	var opts distro.FetchBuildinfosOpts
+	if opts.Providers, err = flags.GetStringSlice("buildinfo-provider"); err != nil {
+		return err
+	}
+	if opts.Keyrings, err = flags.GetStringSlice("keyring"); err != nil {
+		return err
+	}
+	outputDir, err := flags.GetString("output-dir")
+	if err != nil {
+		return err
+	}
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, arch)
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	pkgs := make([]filespec.FileSpec, 0, len(fileSpecs))
+	for _, sp := range fileSpecs {
+		pkgs = append(pkgs, *sp)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	buildinfos, err := fetcher.FetchBuildinfos(cmd.Context(), c, pkgs, opts)
+	if err != nil {
+		return err
+	}
+
+	// Multiple packages share the same build information file
+	sums := make(map[string]string)
+	for _, bi := range buildinfos {
+		sums[bi.Buildinfo] = bi.SHA256
+	}
+	if outputDir != "" {
+		if err = os.MkdirAll(outputDir, 0755); err != nil {
+			return err
+		}
+		for name, sum := range sums {
+			blob, err := c.BlobAbsPath(sum)
+			if err != nil {
+				return err
+			}
+			b, err := os.ReadFile(blob)
+			if err != nil {
+				return err
+			}
+			if err = os.WriteFile(filepath.Join(outputDir, name), b, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	logrus.Infof("Verified %d build information files for %d packages", len(sums), len(buildinfos))
+
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		return writeJSON(w, buildinfos)
+	}
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hw := distro.NewHashWriter(w)
+	for _, name := range names {
+		if err = hw(sums[name], name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/remotecache"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -15,8 +23,164 @@ func newCacheCommand() *cobra.Command {
 	}
 	cmd.AddCommand(
 		newCacheImportCommand(),
+		newCacheImportSystemCommand(),
 		newCacheExportCommand(),
 		newCacheCleanCommand(),
+		newCacheGCCommand(),
+		newCachePruneCommand(),
+		newCacheChunkCommand(),
+		newCacheSyncCommand(),
+		newCacheVerifyCommand(),
+		newCacheInfoCommand(),
+		newCacheLinkAptCommand(),
+		newCacheInvalidateURLCommand(),
 	)
 	return cmd
 }
+
+// defaultCacheDir is the system-wide cache dir.
+const defaultCacheDir = "/var/cache/repro-get"
+
+// userCacheDir returns the per-user cache dir, such as "~/.cache/repro-get".
+func userCacheDir() (string, error) {
+	dir, err := os.UserCacheDir() // $XDG_CACHE_HOME or ~/.cache
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repro-get"), nil
+}
+
+// defaultCacheSpec returns defaultCacheDir when it is writable.
+// Otherwise (e.g., when running as a non-root user), returns the per-user cache dir,
+// with defaultCacheDir as a read-only dir if it exists.
+func defaultCacheSpec() *cacheSpec {
+	if isWritableDir(defaultCacheDir) {
+		return &cacheSpec{dir: defaultCacheDir}
+	}
+	dir, err := userCacheDir()
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to get the user cache dir, falling back to %q", defaultCacheDir)
+		return &cacheSpec{dir: defaultCacheDir}
+	}
+	logrus.Debugf("%q is not writable, using %q", defaultCacheDir, dir)
+	spec := &cacheSpec{dir: dir}
+	if st, err := os.Stat(defaultCacheDir); err == nil && st.IsDir() {
+		spec.readOnlyDirs = []string{defaultCacheDir}
+	}
+	return spec
+}
+
+// isWritableDir returns true if dir is writable, or can be created.
+func isWritableDir(dir string) bool {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			return isWritable(d)
+		}
+		if d == filepath.Dir(d) {
+			return false
+		}
+	}
+}
+
+// cacheSpec is the parsed value of the --cache flag.
+type cacheSpec struct {
+	dir          string   // The primary (writable) dir
+	readOnlyDirs []string // Read-only dirs
+	remote       string   // Remote cache URL such as "s3://BUCKET/PREFIX"
+}
+
+// parseCacheSpec parses the --cache flag value, such as "/var/cache/repro-get",
+// "primary:/var/cache/repro-get,ro:/mnt/shared-cache", and "s3://BUCKET/PREFIX".
+// When the primary dir is not specified, the primary dir and the read-only dirs of defaultCacheSpec are used.
+func parseCacheSpec(s string) (*cacheSpec, error) {
+	var spec cacheSpec
+	for _, f := range strings.Split(s, ",") {
+		switch {
+		case f == "":
+			continue
+		case remotecache.IsRemote(f):
+			if spec.remote != "" {
+				return nil, fmt.Errorf("multiple remote caches cannot be specified: %q", s)
+			}
+			spec.remote = f
+		case strings.HasPrefix(f, "ro:"):
+			spec.readOnlyDirs = append(spec.readOnlyDirs, strings.TrimPrefix(f, "ro:"))
+		default:
+			if spec.dir != "" {
+				return nil, fmt.Errorf("multiple primary caches cannot be specified: %q (Hint: prepend \"ro:\" to the read-only caches)", s)
+			}
+			spec.dir = strings.TrimPrefix(f, "primary:")
+		}
+	}
+	if spec.dir == "" {
+		sys := defaultCacheSpec()
+		spec.dir = sys.dir
+		spec.readOnlyDirs = append(sys.readOnlyDirs, spec.readOnlyDirs...)
+	}
+	return &spec, nil
+}
+
+// newCache instantiates the cache specified with the --cache flag.
+func newCache(cmd *cobra.Command) (*cache.Cache, error) {
+	flags := cmd.Flags()
+	cacheStr, err := flags.GetString("cache")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := parseCacheSpec(cacheStr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cache.New(spec.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range spec.readOnlyDirs {
+		if err = c.AddReadOnlyDir(dir); err != nil {
+			return nil, fmt.Errorf("failed to add the read-only cache %q: %w", dir, err)
+		}
+	}
+	if spec.remote != "" {
+		remote, err := remotecache.New(spec.remote)
+		if err != nil {
+			return nil, err
+		}
+		writeThrough, err := flags.GetBool("cache-write-through")
+		if err != nil {
+			return nil, err
+		}
+		c.SetRemote(remote, writeThrough)
+	}
+	urlTTLStr, err := flags.GetString("cache-url-ttl")
+	if err != nil {
+		return nil, err
+	}
+	if urlTTLStr != "" {
+		urlTTL, err := parseAge(urlTTLStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse --cache-url-ttl: %w", err)
+		}
+		c.SetURLTTL(urlTTL)
+	}
+	if err = configureURLOpener(cmd, c.URLOpener()); err != nil {
+		return nil, err
+	}
+	chunked, err := flags.GetBool("cache-chunked")
+	if err != nil {
+		return nil, err
+	}
+	c.SetChunked(chunked)
+	mismatchDir, err := flags.GetString("cache-keep-mismatch")
+	if err != nil {
+		return nil, err
+	}
+	c.SetMismatchDir(mismatchDir)
+	hook, err := flags.GetString("cache-hook")
+	if err != nil {
+		return nil, err
+	}
+	if hook != "" {
+		c.AddHook(execHook(hook))
+	}
+	return c, nil
+}
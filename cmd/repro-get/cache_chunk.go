@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheChunkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chunk",
+		Short: "Split the plain blobs in the cache into the content-defined chunks",
+		Long: `Split the plain blobs in the cache into the content-defined chunks, so that the successive versions of large files share most of the storage.
+Useful for migrating an existing cache into the chunked mode (--cache-chunked),
+and for removing the files reassembled from the chunks on installation.`,
+		Example: "  repro-get cache chunk",
+		Args:    cobra.NoArgs,
+		RunE:    cacheChunkAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	return cmd
+}
+
+func cacheChunkAction(cmd *cobra.Command, args []string) error {
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	chunked, err := c.ChunkBlobs()
+	w := cmd.OutOrStdout()
+	var chunkedSize int64
+	for _, b := range chunked {
+		fmt.Fprintln(w, b.SHA256)
+		chunkedSize += b.Size
+	}
+	logrus.Infof("Chunked %d blobs (%d bytes)", len(chunked), chunkedSize)
+	if err != nil {
+		return err
+	}
+	info, err := c.Info()
+	if err != nil {
+		return err
+	}
+	logrus.Infof("The chunks of the blobs occupy %d bytes", info.ChunksSize)
+	return nil
+}
@@ -26,6 +26,11 @@ func cacheCleanAction(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	logrus.Infof("Removing %q", cacheStr)
-	return os.RemoveAll(cacheStr)
+	spec, err := parseCacheSpec(cacheStr)
+	if err != nil {
+		return err
+	}
+	// The read-only caches and the remote cache are not cleaned
+	logrus.Infof("Removing %q", spec.dir)
+	return os.RemoveAll(spec.dir)
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheGCCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove the least recently used blobs from the cache",
+		Example: `  # Remove the blobs that have not been used for 90 days, and keep the cache smaller than 10 GiB
+  repro-get cache gc --max-size=10GiB --max-age=90d`,
+		Args: cobra.NoArgs,
+		RunE: cacheGCAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("max-size", "", "Maximum total size of the blobs, such as \"10GiB\" (default: unlimited)")
+	flags.String("max-age", "", "Maximum duration since the last use of the blobs, such as \"90d\" and \"12h\" (default: unlimited)")
+	flags.Bool("dry-run", false, "Print the blobs to be removed, without removing them")
+	return cmd
+}
+
+func cacheGCAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	var (
+		opts cache.GCOpts
+		err  error
+	)
+	if s, err := flags.GetString("max-size"); err != nil {
+		return err
+	} else if s != "" {
+		if opts.MaxSize, err = parseSize(s); err != nil {
+			return err
+		}
+	}
+	if s, err := flags.GetString("max-age"); err != nil {
+		return err
+	} else if s != "" {
+		if opts.MaxAge, err = parseAge(s); err != nil {
+			return err
+		}
+	}
+	if opts.DryRun, err = flags.GetBool("dry-run"); err != nil {
+		return err
+	}
+	if opts.MaxSize == 0 && opts.MaxAge == 0 {
+		return errors.New("either --max-size or --max-age has to be specified")
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	removed, err := c.GC(opts)
+	w := cmd.OutOrStdout()
+	var removedSize int64
+	for _, b := range removed {
+		fmt.Fprintln(w, b.SHA256)
+		removedSize += b.Size
+	}
+	verb := "Removed"
+	if opts.DryRun {
+		verb = "Would remove"
+	}
+	logrus.Infof("%s %d blobs (%d bytes)", verb, len(removed), removedSize)
+	return err
+}
+
+var sizeRegexp = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?)\s*([KMGT]i?B?|B)?$`)
+
+// parseSize parses a size string such as "10GiB" (10*1024^3 bytes) and "10GB" (10*1000^3 bytes).
+func parseSize(s string) (int64, error) {
+	m := sizeRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	f, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	unit := strings.TrimSuffix(m[3], "B")
+	base := 1000.0
+	if strings.HasSuffix(unit, "i") {
+		base = 1024.0
+		unit = strings.TrimSuffix(unit, "i")
+	}
+	mult := 1.0
+	switch unit {
+	case "K":
+		mult = base
+	case "M":
+		mult = base * base
+	case "G":
+		mult = base * base * base
+	case "T":
+		mult = base * base * base * base
+	}
+	return int64(f * mult), nil
+}
+
+// parseAge parses a duration string such as "90d", in addition to the format of time.ParseDuration such as "12h".
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	return d, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/sirupsen/logrus"
+)
+
+// execHook returns a hook that executes the program with the event in JSON on stdin.
+// The event type is also set to $REPRO_GET_CACHE_EVENT.
+func execHook(prog string) cache.Hook {
+	return func(ev cache.Event) error {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(prog)
+		cmd.Env = append(os.Environ(), "REPRO_GET_CACHE_EVENT="+string(ev.Type))
+		cmd.Stdin = bytes.NewReader(b)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		logrus.Debugf("Running %v for %s", cmd.Args, string(b))
+		if err = cmd.Run(); err != nil {
+			return fmt.Errorf("failed to run %v: %w", cmd.Args, err)
+		}
+		return nil
+	}
+}
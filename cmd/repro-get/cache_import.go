@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -23,12 +22,7 @@ func newCacheImportCommand() *cobra.Command {
 func cacheImportAction(cmd *cobra.Command, args []string) error {
 	w := cmd.OutOrStdout()
 	hw := distro.NewHashWriter(w)
-	flags := cmd.Flags()
-	cacheStr, err := flags.GetString("cache")
-	if err != nil {
-		return err
-	}
-	cache, err := cache.New(cacheStr)
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/distro/alpine"
+	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheImportSystemCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import-system [flags]",
+		Short: "Import the package files cached by the system package managers",
+		Long: `Import the package files cached by the system package managers:
+- apt: ` + debian.ArchivesDir + `
+- apk: ` + alpine.PackageCacheDir + `, ` + alpine.IndexCacheDir + `
+
+The origin URLs are recorded from the apt lists and the APKINDEX files, when available.`,
+		Example: "  repro-get cache import-system",
+		Args:    cobra.NoArgs,
+		RunE:    cacheImportSystemAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("root", "/", "Root filesystem to inspect")
+	return cmd
+}
+
+func cacheImportSystemAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	root, err := flags.GetString("root")
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	hw := distro.NewHashWriter(w)
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		fn   func(*cache.Cache, string) (map[string]string, error)
+	}{
+		{name: "apt", fn: debian.ImportArchives},
+		{name: "apk", fn: alpine.ImportPackageCache},
+	} {
+		imported, err := f.fn(c, root)
+		for basename, sha256sum := range imported {
+			if hwErr := hw(sha256sum, basename); hwErr != nil {
+				logrus.Warn(hwErr)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logrus.Debugf("No %s cache was found: %v", f.name, err)
+				continue
+			}
+			return err
+		}
+		logrus.Infof("Imported %d files from the %s cache", len(imported), f.name)
+	}
+	return nil
+}
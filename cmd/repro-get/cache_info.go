@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/spf13/cobra"
+)
+
+func newCacheInfoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "info [flags] [SHA256SUMS]...",
+		Short: "Show the cache statistics",
+		Long: `Show the cache statistics.
+When hash files are specified, the number and the size of the cached files are shown for each of the hash files too.
+
+When --blob is specified, the information of the blob is shown instead, including the provenance,
+i.e., the provider and the URL that actually served the blob, and when.`,
+		Example: "  repro-get cache info --json SHA256SUMS-" + archutil.OCIArchDashVariant() + `
+  repro-get cache info --blob 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc`,
+		RunE: cacheInfoAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "Enable JSON output")
+	flags.String("blob", "", "Show the information of the blob with the specified sha256sum")
+	return cmd
+}
+
+// CacheInfo is printed by `repro-get cache info`.
+type CacheInfo struct {
+	Dir          string   `json:"Dir"`                    // The primary dir
+	ReadOnlyDirs []string `json:"ReadOnlyDirs,omitempty"` // Looked up after Dir, in this order
+	Remote       string   `json:"Remote,omitempty"`       // Looked up after ReadOnlyDirs
+	cache.Info
+	HashFiles []HashFileCacheInfo `json:"HashFiles,omitempty"`
+}
+
+// HashFileCacheInfo is the cache statistics for a hash file.
+type HashFileCacheInfo struct {
+	File   string `json:"File"`
+	Files  int    `json:"Files"`  // The number of the files in the hash file
+	Cached int    `json:"Cached"` // The number of the cached files
+	Size   int64  `json:"Size"`   // The total size of the cached files
+}
+
+// BlobCacheInfo is printed by `repro-get cache info --blob`.
+type BlobCacheInfo struct {
+	SHA256     string            `json:"SHA256"`
+	Path       string            `json:"Path"`
+	Size       int64             `json:"Size"`
+	URL        string            `json:"URL,omitempty"` // The origin URL
+	Provenance *cache.Provenance `json:"Provenance,omitempty"`
+}
+
+func cacheInfoAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	blob, err := flags.GetString("blob")
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	if blob != "" {
+		if len(args) > 0 {
+			return errors.New("hash files cannot be specified with --blob")
+		}
+		return cacheInfoBlob(cmd, c, strings.TrimPrefix(blob, "sha256:"), jsonFlag)
+	}
+	info, err := c.Info()
+	if err != nil {
+		return err
+	}
+	x := CacheInfo{
+		Dir:          c.Dir(),
+		ReadOnlyDirs: c.ReadOnlyDirs(),
+		Info:         *info,
+	}
+	if remote := c.Remote(); remote != nil {
+		x.Remote = remote.String()
+	}
+	for _, hashFile := range args {
+		fileSpecs, err := filespec.NewFromSHA256SUMSFiles(hashFile)
+		if err != nil {
+			return err
+		}
+		hfi := HashFileCacheInfo{
+			File:  hashFile,
+			Files: len(fileSpecs),
+		}
+		for _, sp := range fileSpecs {
+			size, err := c.BlobSize(sp.Sum())
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return err
+			}
+			hfi.Cached++
+			hfi.Size += size
+		}
+		x.HashFiles = append(x.HashFiles, hfi)
+	}
+
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		b, err := json.MarshalIndent(x, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	fmt.Fprintln(w, "Dir: "+x.Dir)
+	for _, dir := range x.ReadOnlyDirs {
+		fmt.Fprintln(w, "Read-only dir: "+dir)
+	}
+	if x.Remote != "" {
+		fmt.Fprintln(w, "Remote: "+x.Remote)
+	}
+	fmt.Fprintf(w, "Blobs: %d\n", x.Blobs)
+	fmt.Fprintf(w, "Size: %d bytes\n", x.Size)
+	if x.Chunks > 0 {
+		fmt.Fprintf(w, "Chunks: %d (%d bytes)\n", x.Chunks, x.ChunksSize)
+	}
+	fmt.Fprintf(w, "Hits: %d\n", x.Hits)
+	fmt.Fprintf(w, "Misses: %d\n", x.Misses)
+	if x.Oldest != nil {
+		fmt.Fprintf(w, "Oldest: %s (last used at %s)\n", x.Oldest.SHA256, x.Oldest.LastUsed.Format(time.RFC3339))
+	}
+	if x.Newest != nil {
+		fmt.Fprintf(w, "Newest: %s (last used at %s)\n", x.Newest.SHA256, x.Newest.LastUsed.Format(time.RFC3339))
+	}
+	if len(x.HashFiles) > 0 {
+		fmt.Fprintln(w, "Hash files:")
+		for _, hfi := range x.HashFiles {
+			fmt.Fprintf(w, "- %s: %d/%d files cached (%d bytes)\n", hfi.File, hfi.Cached, hfi.Files, hfi.Size)
+		}
+	}
+	return nil
+}
+
+func cacheInfoBlob(cmd *cobra.Command, c *cache.Cache, sha256sum string, jsonFlag bool) error {
+	size, err := c.BlobSize(sha256sum)
+	if err != nil {
+		return fmt.Errorf("failed to get the blob %q: %w", sha256sum, err)
+	}
+	x := BlobCacheInfo{
+		SHA256: sha256sum,
+		Size:   size,
+	}
+	if x.Path, err = c.BlobAbsPath(sha256sum); err != nil {
+		return err
+	}
+	if u, err := c.OriginURLBySHA256(sha256sum); err == nil {
+		x.URL = u.Redacted()
+	}
+	if x.Provenance, err = c.Provenance(sha256sum); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		x.Provenance = nil
+	}
+
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		b, err := json.MarshalIndent(x, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	fmt.Fprintln(w, "SHA256: "+x.SHA256)
+	fmt.Fprintln(w, "Path: "+x.Path)
+	fmt.Fprintf(w, "Size: %d bytes\n", x.Size)
+	if x.URL != "" {
+		fmt.Fprintln(w, "URL: "+x.URL)
+	}
+	if p := x.Provenance; p != nil {
+		fmt.Fprintln(w, "Provenance:")
+		fmt.Fprintln(w, "- URL: "+p.URL)
+		if p.Provider != "" {
+			fmt.Fprintln(w, "- Provider: "+p.Provider)
+		}
+		if p.Remote != "" {
+			fmt.Fprintln(w, "- Remote: "+p.Remote)
+		}
+		fmt.Fprintln(w, "- Fetched at: "+p.FetchedAt.Format(time.RFC3339))
+	} else {
+		fmt.Fprintln(w, "Provenance: unknown")
+	}
+	return nil
+}
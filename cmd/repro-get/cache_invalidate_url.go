@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheInvalidateURLCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "invalidate-url URL...",
+		Short: "Forget the sha256sums of the URLs, so that the URLs are fetched again",
+		Long: `Forget the sha256sums of the URLs, so that the URLs are fetched again.
+The cached blobs are not removed.
+See also the --cache-url-ttl flag.`,
+		Example: "  repro-get cache invalidate-url https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/ca-certificates-bundle-20220614-r0.apk",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    cacheInvalidateURLAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	return cmd
+}
+
+func cacheInvalidateURLAction(cmd *cobra.Command, args []string) error {
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	for _, rawURL := range args {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse URL %q: %w", rawURL, err)
+		}
+		if err = c.InvalidateURL(u); err != nil {
+			return err
+		}
+		logrus.Infof("Invalidated %q", u.Redacted())
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheLinkAptCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "link-apt [flags] SHA256SUMS...",
+		Short: "Link the cached *.deb files into the apt archive directory",
+		Long: `Link the cached *.deb files into the apt archive directory, with the file names expected by apt.
+The files are hard-linked, or copied when hard links are not available (reflinked on supported filesystems).
+Then 'apt-get install' can install the packages without downloading them.`,
+		Example: `  repro-get download SHA256SUMS-` + archutil.OCIArchDashVariant() + `
+  repro-get cache link-apt SHA256SUMS-` + archutil.OCIArchDashVariant() + `
+  apt-get install --no-download hello`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: cacheLinkAptAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("dir", debian.ArchivesDir, "apt archive directory")
+	return cmd
+}
+
+func cacheLinkAptAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	dir, err := flags.GetString("dir")
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "partial"), 0755); err != nil {
+		return err
+	}
+	var linked, missing int
+	for _, sp := range fileSpecs {
+		if !strings.HasSuffix(sp.Basename, ".deb") {
+			logrus.Debugf("Skipping non-deb file %q", sp.Name)
+			continue
+		}
+		// The basename is like "foo_1%3a2.0-1_amd64.deb", with the URL-encoded epoch, as apt expects
+		dst := filepath.Join(dir, filepath.Base(sp.Basename))
+		if err = c.Link(sp.Sum(), dst); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logrus.Warnf("%q (%s) is not cached", sp.Name, sp.Sum())
+				missing++
+				continue
+			}
+			if errors.Is(err, os.ErrExist) {
+				logrus.WithError(err).Warn("Avoiding to overwrite an existing file")
+				continue
+			}
+			return err
+		}
+		logrus.Debugf("Linked %q (%s) to %q", sp.Name, sp.Sum(), dst)
+		linked++
+	}
+	logrus.Infof("Linked %d files into %q", linked, dir)
+	if missing > 0 {
+		return fmt.Errorf("%d files are not cached (Hint: run `repro-get download`)", missing)
+	}
+	return nil
+}
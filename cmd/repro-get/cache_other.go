@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// isWritable returns true if a file can be created in the existing dir.
+func isWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".repro-get-writable-")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	_ = os.Remove(f.Name())
+	return true
+}
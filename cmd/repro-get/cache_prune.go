@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCachePruneCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune SHA256SUMS...",
+		Short: "Remove the blobs that are not referenced by the hash files",
+		Example: `  # Keep only the blobs needed for SHA256SUMS-` + archutil.OCIArchDashVariant() + `
+  repro-get cache prune SHA256SUMS-` + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(1),
+		RunE: cachePruneAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("dry-run", false, "Print the blobs to be removed, without removing them")
+	return cmd
+}
+
+func cachePruneAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	dryRun, err := flags.GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]struct{}, len(fileSpecs))
+	for _, sp := range fileSpecs {
+		keep[sp.Sum()] = struct{}{}
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	removed, err := c.Prune(keep, dryRun)
+	w := cmd.OutOrStdout()
+	var removedSize int64
+	for _, b := range removed {
+		fmt.Fprintln(w, b.SHA256)
+		removedSize += b.Size
+	}
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	logrus.Infof("%s %d blobs (%d bytes)", verb, len(removed), removedSize)
+	return err
+}
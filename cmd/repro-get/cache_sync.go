@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/remotecache"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync [flags] DEST",
+		Short: "Copy the cached files that are missing in the destination",
+		Long: `Copy the cached files that are missing in the destination.
+
+DEST is one of:
+- a cache directory, such as "/mnt/shared-cache"
+- an rsync target, such as "user@host:/var/cache/repro-get" and "rsync://host/module"
+- a remote cache URL, such as "s3://BUCKET/PREFIX" (` + strings.Join(remotecache.Schemes, ", ") + `)
+
+Only the files in the primary cache directory are copied.`,
+		Example: `  repro-get cache sync /mnt/shared-cache
+  repro-get cache sync mirror.example.com:/var/cache/repro-get
+  repro-get cache sync s3://BUCKET/PREFIX`,
+		Args: cobra.ExactArgs(1),
+		RunE: cacheSyncAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("dry-run", false, "Print the blobs to be copied, without copying them")
+	return cmd
+}
+
+func cacheSyncAction(cmd *cobra.Command, args []string) error {
+	dest := args[0]
+	flags := cmd.Flags()
+	dryRun, err := flags.GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	if isRsyncTarget(dest) {
+		return rsyncCache(cmd, c, dest, dryRun)
+	}
+	opts := cache.SyncOpts{
+		DryRun: dryRun,
+	}
+	var synced []cache.Blob
+	if remotecache.IsRemote(dest) {
+		remote, err := remotecache.New(dest)
+		if err != nil {
+			return err
+		}
+		synced, err = c.Sync(cmd.Context(), remote, opts)
+	} else {
+		var dst *cache.Cache
+		dst, err = cache.New(dest)
+		if err != nil {
+			return err
+		}
+		synced, err = c.SyncDir(dst, opts)
+	}
+	w := cmd.OutOrStdout()
+	var syncedSize int64
+	for _, b := range synced {
+		fmt.Fprintln(w, b.SHA256)
+		syncedSize += b.Size
+	}
+	verb := "Copied"
+	if dryRun {
+		verb = "Would copy"
+	}
+	logrus.Infof("%s %d blobs (%d bytes) to %q", verb, len(synced), syncedSize, dest)
+	return err
+}
+
+// isRsyncTarget returns true for "rsync://HOST/MODULE", "HOST:PATH", and "USER@HOST:PATH".
+func isRsyncTarget(s string) bool {
+	if strings.HasPrefix(s, "rsync://") {
+		return true
+	}
+	if strings.Contains(s, "://") {
+		return false
+	}
+	colon := strings.Index(s, ":")
+	slash := strings.Index(s, "/")
+	return colon > 0 && (slash < 0 || colon < slash)
+}
+
+// rsyncCache copies the blobs and the URL files with rsync.
+// The index and the statistics are not copied, as they are specific to each cache dir.
+func rsyncCache(cmd *cobra.Command, c *cache.Cache, dest string, dryRun bool) error {
+	rsyncExe, err := exec.LookPath("rsync")
+	if err != nil {
+		return fmt.Errorf("rsync is needed for syncing to %q: %w", dest, err)
+	}
+	rsyncArgs := []string{"--archive", "--ignore-existing", "--itemize-changes", "--exclude=*.tmp"}
+	if dryRun {
+		rsyncArgs = append(rsyncArgs, "--dry-run")
+	}
+	// The blobs are copied before the URL files, so that the URL files and the provenance files do not point to missing blobs
+	// The parent dirs such as "blobs" contain "blobs/sha256" and "blobs/sha512"
+	for _, rel := range []string{path.Dir(cache.BlobsSHA256RelPath), path.Dir(cache.URLsSHA256RelPath), cache.ReverseURLRelPath, path.Dir(cache.ProvenanceSHA256RelPath)} {
+		rsync := exec.CommandContext(cmd.Context(), rsyncExe, append(rsyncArgs, "--relative",
+			strings.TrimSuffix(c.Dir(), "/")+"/./"+rel+"/", strings.TrimSuffix(dest, "/")+"/")...)
+		rsync.Stdout = cmd.OutOrStdout()
+		rsync.Stderr = os.Stderr
+		logrus.Debugf("Running %v", rsync.Args)
+		if err = rsync.Run(); err != nil {
+			return fmt.Errorf("failed to run %v: %w", rsync.Args, err)
+		}
+	}
+	return nil
+}
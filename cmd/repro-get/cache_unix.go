@@ -0,0 +1,10 @@
+//go:build unix
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// isWritable returns true if the existing file or dir is writable.
+func isWritable(p string) bool {
+	return unix.Access(p, unix.W_OK) == nil
+}
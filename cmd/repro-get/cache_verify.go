@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newCacheVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the cache",
+		Long: `Verify the cache.
+The blobs are re-hashed, and the URL files are checked to point to existing blobs.
+The read-only caches and the remote cache are not verified.`,
+		Example: `  # Report the corrupted blobs
+  repro-get cache verify
+
+  # Remove the corrupted blobs
+  repro-get cache verify --repair`,
+		Args: cobra.NoArgs,
+		RunE: cacheVerifyAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("repair", false, "Remove the bad entries")
+	return cmd
+}
+
+func cacheVerifyAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	var (
+		opts cache.VerifyOpts
+		err  error
+	)
+	if opts.Repair, err = flags.GetBool("repair"); err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	problems, err := c.Verify(opts)
+	w := cmd.OutOrStdout()
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(problems) == 0:
+		logrus.Info("No problem was found")
+	case opts.Repair:
+		logrus.Infof("Repaired %d problems", len(problems))
+	default:
+		return fmt.Errorf("found %d problems (Hint: try --repair)", len(problems))
+	}
+	return nil
+}
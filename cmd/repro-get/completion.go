@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/spf13/cobra"
+)
+
+// hashFilePatterns are the patterns of the hash files in the working directory, for the shell completion.
+var hashFilePatterns = []string{"SHA256SUMS*", "SHA512SUMS*"}
+
+// nonHashFileSuffixes are the suffixes of the files that match hashFilePatterns but are not hash files.
+var nonHashFileSuffixes = append([]string{".intoto.json", filespec.CIDsFileSuffix, ".torrent", ".old"}, hashsig.SignatureSuffixes...)
+
+// hashFilesInWorkDir returns the hash files in the working directory.
+func hashFilesInWorkDir() []string {
+	var res []string
+	for _, pattern := range hashFilePatterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			cobra.CompDebugln(fmt.Sprintf("failed to glob %q: %v", pattern, err), false)
+			continue
+		}
+	matchLoop:
+		for _, f := range matches {
+			for _, suffix := range nonHashFileSuffixes {
+				if strings.HasSuffix(f, suffix) {
+					continue matchLoop
+				}
+			}
+			res = append(res, f)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// completeHashFiles completes the hash files in the working directory.
+// The other files are completed too when no hash file matches.
+func completeHashFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	res := filterCompletions(hashFilesInWorkDir(), args, toComplete)
+	if len(res) == 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePackageNames completes the names of the packages in the hash files in the working directory,
+// and the names of the installed packages.
+func completePackageNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	d, err := getDistro(cmd)
+	if err != nil {
+		cobra.CompDebugln(err.Error(), false)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	seen := make(map[string]struct{})
+	if hashFiles := hashFilesInWorkDir(); len(hashFiles) > 0 {
+		arch, _ := cmd.Flags().GetString("arch")
+		fileSpecs, err := filespec.NewFromSHA256SUMSFilesForArch(arch, hashFiles...)
+		if err != nil {
+			cobra.CompDebugln(fmt.Sprintf("failed to read the hash files %v: %v", hashFiles, err), false)
+		}
+		for _, sp := range fileSpecs {
+			if pkgName, err := d.PackageName(*sp); err == nil && pkgName != "" {
+				seen[pkgName] = struct{}{}
+			}
+		}
+	}
+	if lister, ok := d.(distro.InstalledLister); ok {
+		root, _ := cmd.Flags().GetString("root")
+		installed, err := lister.InstalledPackages(cmd.Context(), root)
+		if err != nil {
+			cobra.CompDebugln(fmt.Sprintf("failed to list the installed packages: %v", err), false)
+		}
+		for _, pkg := range installed {
+			seen[pkg.Package] = struct{}{}
+		}
+	}
+	candidates := make([]string, 0, len(seen))
+	for pkgName := range seen {
+		candidates = append(candidates, pkgName)
+	}
+	sort.Strings(candidates)
+	return filterCompletions(candidates, args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// filterCompletions returns the candidates that have the prefix toComplete, excluding the ones in args.
+func filterCompletions(candidates, args []string, toComplete string) []string {
+	specified := make(map[string]struct{}, len(args))
+	for _, arg := range args {
+		specified[arg] = struct{}{}
+	}
+	var res []string
+	for _, s := range candidates {
+		if _, ok := specified[s]; ok {
+			continue
+		}
+		if strings.HasPrefix(s, toComplete) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
@@ -15,6 +15,8 @@ func newDockerfileCommand() *cobra.Command {
 	}
 	cmd.AddCommand(
 		newDockerfileGenerateCommand(),
+		newDockerfilePinCommand(),
+		newDockerfileUpdateCommand(),
 	)
 	return cmd
 }
@@ -4,13 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
-	"github.com/reproducible-containers/repro-get/pkg/ocidistutil"
+	"github.com/reproducible-containers/repro-get/pkg/dockerfileutil"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -57,9 +59,24 @@ func newDockerfileGenerateCommand() *cobra.Command {
 		Example: `  # Generate "Dockerfile.generate-hash" and "Dockerfile" in the current directory for gcc
   repro-get --distro=debian dockerfile generate . debian:bullseye-20211220 gcc build-essential
 
+  # Generate "Dockerfile.generate-hash" and "Dockerfile" for Ubuntu
+  repro-get --distro=ubuntu dockerfile generate . ubuntu:22.04 gcc
+
+  # Generate "Dockerfile.generate-hash" and "Dockerfile" for Fedora
+  repro-get --distro=fedora dockerfile generate . fedora:37 gcc
+
+  # Generate "Dockerfile.generate-hash" and "Dockerfile" for Alpine
+  repro-get --distro=alpine dockerfile generate . alpine:3.16 gcc
+
+  # Generate "Dockerfile" for building Go binaries offline with "SHA256SUMS-gomod"
+  repro-get --distro=gomod dockerfile generate . golang:1.19
+
   # Generate "Dockerfile" only, for consuming existing hash files
   repro-get --distro=debian dockerfile generate . debian:bullseye-20211220
 
+  # Generate "docker-bake.hcl" too, for building the multi-platform image with "docker buildx bake"
+  repro-get --distro=debian dockerfile generate --platform=linux/amd64,linux/arm64 . debian:bullseye-20211220 gcc
+
 To build "Dockerfile.generate-hash" and "Dockerfile":
 ` +
 			regexp.MustCompilePOSIX("^").ReplaceAllString(helpForBuildingDockerfiles(true), "  "),
@@ -68,20 +85,43 @@ To build "Dockerfile.generate-hash" and "Dockerfile":
 
 		DisableFlagsInUseLine: true,
 	}
+	addDockerfileGenerateFlags(cmd)
+	cmd.Flags().StringSlice("platform", nil, "Target platforms of the bake file (\""+dockerfileutil.BakeFilename+"\") for building the multi-platform image, such as \"linux/amd64,linux/arm64\" (default: no bake file)")
 	return cmd
 }
 
+// addDockerfileGenerateFlags adds the flags for generateDockerfile.
+func addDockerfileGenerateFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.String("source-date-epoch", "", "Default value of the SOURCE_DATE_EPOCH build arg, in seconds since the epoch (default: computed during the build from the hash file and the base image)")
+}
+
 func dockerfileGenerateAction(cmd *cobra.Command, args []string) error {
 	d, err := getDistro(cmd)
 	if err != nil {
 		return err
 	}
-	flags := cmd.Flags()
-	if !flags.Changed("distro") {
+	if !cmd.Flags().Changed("distro") {
 		logrus.Warnf("No image distro was explicitly specified (--distro=...), assuming the distro to be %q", d.Info().Name)
 	}
 
-	providers, err := flags.GetStringSlice("provider")
+	dir := args[0]
+	baseImageOrig := args[1]
+	pkgs := args[2:]
+	opts := distro.DockerfileOpts{
+		GenerateHash: len(pkgs) > 0,
+	}
+	if err = generateDockerfile(cmd, d, dir, baseImageOrig, pkgs, opts); err != nil {
+		return err
+	}
+	printNextStepsForDockerfiles(cmd, opts.GenerateHash)
+	return nil
+}
+
+// generateDockerfile generates the Dockerfiles in dir, with the base image pinned by the digest.
+// The bake file is generated too, when --platform is specified.
+func generateDockerfile(cmd *cobra.Command, d distro.Distro, dir, baseImageOrig string, pkgs []string, opts distro.DockerfileOpts) error {
+	providers, err := getProviders(cmd)
 	if err != nil {
 		return err
 	}
@@ -90,19 +130,27 @@ func dockerfileGenerateAction(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := cmd.Context()
-	dir := args[0]
-	baseImageOrig := args[1]
-	pkgs := args[2:]
-
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	resolvedWithDigest, err := ocidistutil.RefWithDigest(ctx, baseImageOrig)
+	lockFile := filepath.Join(dir, dockerfileutil.BaseImageLockFilename)
+	lock, err := dockerfileutil.LoadBaseImageLock(lockFile)
+	if err != nil {
+		return err
+	}
+	resolvedWithDigest, err := resolveBaseImage(ctx, lock, baseImageOrig, false)
 	if err != nil {
 		return err
 	}
+	if err = lock.Save(lockFile); err != nil {
+		return err
+	}
 
+	sourceDateEpoch, err := getSourceDateEpoch(cmd)
+	if err != nil {
+		return err
+	}
 	templateArgs := distro.DockerfileTemplateArgs{
 		BaseImage:          resolvedWithDigest,
 		BaseImageOrig:      baseImageOrig,
@@ -110,18 +158,35 @@ func dockerfileGenerateAction(cmd *cobra.Command, args []string) error {
 		OCIArchDashVariant: archutil.OCIArchDashVariant(),
 		Providers:          providers,
 	}
-	opts := distro.DockerfileOpts{
-		GenerateHash: len(pkgs) > 0,
+	if sourceDateEpoch != nil {
+		templateArgs.SourceDateEpoch = strconv.FormatInt(sourceDateEpoch.Unix(), 10)
 	}
 	if err = d.GenerateDockerfile(ctx, dir, templateArgs, opts); err != nil {
 		return err
 	}
+	platforms, err := cmd.Flags().GetStringSlice("platform")
+	if err != nil || len(platforms) == 0 {
+		return err
+	}
+	bakeOpts := dockerfileutil.BakeOpts{
+		Platforms:       platforms,
+		GenerateHash:    opts.GenerateHash,
+		SourceDateEpoch: templateArgs.SourceDateEpoch,
+	}
+	f := filepath.Join(dir, dockerfileutil.BakeFilename)
+	logrus.Infof("Generating %q", f)
+	var b bytes.Buffer
+	if err = dockerfileutil.WriteBakeFile(&b, bakeOpts); err != nil {
+		return fmt.Errorf("failed to generate %q: %w", f, err)
+	}
+	return os.WriteFile(f, b.Bytes(), 0644)
+}
 
+func printNextStepsForDockerfiles(cmd *cobra.Command, needsToGenerateHash bool) {
 	w := cmd.OutOrStdout()
 	logrus.Infof("Next steps:")
 	sep := strings.Repeat("-", 5)
 	fmt.Fprintln(w, sep)
-	fmt.Fprint(w, helpForBuildingDockerfiles(opts.GenerateHash))
+	fmt.Fprint(w, helpForBuildingDockerfiles(needsToGenerateHash))
 	fmt.Fprintln(w, sep)
-	return nil
 }
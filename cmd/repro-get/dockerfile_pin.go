@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/dockerfileutil"
+	"github.com/reproducible-containers/repro-get/pkg/ocidistutil"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newDockerfilePinCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pin [flags] [DIR]",
+		Short: "Pin the base images of the Dockerfiles by the digests (EXPERIMENTAL)",
+		Long: `Pin the base images of the Dockerfiles by the digests (EXPERIMENTAL)
+The base images ("ARG BASE_IMAGE=<REF>" and "FROM <REF>") of "Dockerfile", "Dockerfile.*", and "*.Dockerfile" in DIR
+are rewritten to "<REF>@<DIGEST>", and the digests are recorded in "` + dockerfileutil.BaseImageLockFilename + `" in DIR.
+
+The digests recorded in "` + dockerfileutil.BaseImageLockFilename + `" are used as they are, so the Dockerfiles
+can be regenerated with 'repro-get dockerfile generate' without changing the base images.
+Use 'repro-get dockerfile update' to refresh the digests.
+`,
+		Example: "  repro-get dockerfile pin .",
+		Args:    cobra.MaximumNArgs(1),
+		RunE:    dockerfilePinAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	return cmd
+}
+
+func dockerfilePinAction(cmd *cobra.Command, args []string) error {
+	return pinDockerfiles(cmd, args, false)
+}
+
+// pinDockerfiles pins the base images of the Dockerfiles in the directory args[0], or in the current directory.
+func pinDockerfiles(cmd *cobra.Command, args []string, update bool) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	files, err := dockerfilesInDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no Dockerfile was found in %q", dir)
+	}
+	lockFile := filepath.Join(dir, dockerfileutil.BaseImageLockFilename)
+	lock, err := dockerfileutil.LoadBaseImageLock(lockFile)
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]string) // key: the reference in the Dockerfiles
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		m := make(map[string]string)
+		for _, ref := range dockerfileutil.BaseImages(b) {
+			if _, ok := resolved[ref]; !ok {
+				if resolved[ref], err = resolveBaseImage(cmd.Context(), lock, ref, update); err != nil {
+					return err
+				}
+			}
+			m[ref] = resolved[ref]
+		}
+		newB := dockerfileutil.ReplaceBaseImages(b, m)
+		if bytes.Equal(b, newB) {
+			continue
+		}
+		if err = os.WriteFile(f, newB, 0644); err != nil {
+			return err
+		}
+		logrus.Infof("Updated %q", f)
+	}
+	return lock.Save(lockFile)
+}
+
+// dockerfilesInDir returns "Dockerfile", "Dockerfile.*", and "*.Dockerfile" in the directory.
+func dockerfilesInDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, ent := range entries {
+		name := ent.Name()
+		if ent.IsDir() || !(name == "Dockerfile" || strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile")) {
+			continue
+		}
+		res = append(res, filepath.Join(dir, name))
+	}
+	return res, nil
+}
+
+// resolveBaseImage returns the base image reference pinned by the digest, such as
+// "docker.io/library/debian:bullseye-20211220@sha256:<SHA256>", and records the digest in the lock.
+//
+// Unless update is true, the digest recorded in the lock, or the digest in ref, is used without resolving the reference.
+func resolveBaseImage(ctx context.Context, lock *dockerfileutil.BaseImageLock, ref string, update bool) (string, error) {
+	unpinned, dgst, err := dockerfileutil.UnpinnedBaseImage(ref)
+	if err != nil {
+		return "", err
+	}
+	old, locked := lock.BaseImages[unpinned]
+	switch {
+	case !update && locked:
+		dgst = old
+	case !update && dgst != "":
+	case dgst != "" && !hasTag(unpinned):
+		logrus.Warnf("Cannot update %q, as it has no tag", ref)
+	default:
+		resolvedWithDigest, err := ocidistutil.RefWithDigest(ctx, unpinned)
+		if err != nil {
+			return "", err
+		}
+		if _, dgst, err = dockerfileutil.UnpinnedBaseImage(resolvedWithDigest); err != nil {
+			return "", err
+		}
+		if locked && old != dgst {
+			logrus.Infof("Updated %q: %s -> %s", unpinned, old, dgst)
+		}
+	}
+	if !locked {
+		logrus.Infof("Pinned %q to %s", unpinned, dgst)
+	}
+	lock.BaseImages[unpinned] = dgst
+	return unpinned + "@" + dgst.String(), nil
+}
+
+// hasTag returns true if the reference has the tag, such as "docker.io/library/debian:bullseye".
+// The port number of the registry is not a tag.
+func hasTag(ref string) bool {
+	return strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/reproducible-containers/repro-get/pkg/dockerfileutil"
+	"github.com/spf13/cobra"
+)
+
+func newDockerfileUpdateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update [flags] [DIR]",
+		Short: "Update the digests of the base images of the Dockerfiles (EXPERIMENTAL)",
+		Long: `Update the digests of the base images of the Dockerfiles (EXPERIMENTAL)
+Similar to 'repro-get dockerfile pin', but the digests are resolved again from the tags,
+ignoring the digests recorded in "` + dockerfileutil.BaseImageLockFilename + `".
+`,
+		Example: "  repro-get dockerfile update .",
+		Args:    cobra.MaximumNArgs(1),
+		RunE:    dockerfileUpdateAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	return cmd
+}
+
+func dockerfileUpdateAction(cmd *cobra.Command, args []string) error {
+	return pinDockerfiles(cmd, args, true)
+}
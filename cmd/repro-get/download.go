@@ -1,10 +1,21 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/downloader"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/reproducible-containers/repro-get/pkg/keyring"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -13,14 +24,29 @@ func newDownloadCommand() *cobra.Command {
 		Use:   "download [SHA256SUMS]...",
 		Short: "Download packages into the cache",
 		Long: `Download packages into the cache.
-Use 'repro-get cache export' for exporting the cache.`,
-		Example: "  repro-get download SHA256SUMS-" + archutil.OCIArchDashVariant(),
-		Args:    cobra.MinimumNArgs(1),
-		RunE:    downloadAction,
+Use 'repro-get cache export' for exporting the cache.
+
+With --manifest-only, the URLs of the files that are not cached yet are printed without downloading them,
+so that the files can be fetched on another machine with the existing tools (aria2c, curl),
+and imported into the cache later with 'repro-get cache import'.`,
+		Example: "  repro-get download SHA256SUMS-" + archutil.OCIArchDashVariant() + `
 
+  # Air-gapped workflow
+  repro-get download --manifest-only --manifest-format=aria2c SHA256SUMS-` + archutil.OCIArchDashVariant() + ` >manifest.txt
+  aria2c --input-file=manifest.txt --dir=files  # On an internet-connected machine
+  repro-get cache import files`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: downloadAction,
+
+		ValidArgsFunction:     completeHashFiles,
 		DisableFlagsInUseLine: true,
 	}
 
+	flags := cmd.Flags()
+	flags.Bool("dry-run", false, "Print the files to be downloaded, without accessing the network")
+	flags.Bool("manifest-only", false, "Print the URLs of the files that are not cached yet, without downloading them")
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\", for the hash files with the architecture sections (\"#repro-get:arch=...\")")
+	flags.String("manifest-format", string(downloader.ManifestFormatText), "Format of --manifest-only: \"text\" (<SHA256>  <URL> lines), \"aria2c\" (aria2c input file), or \"curl\" (shell script)")
 	return cmd
 }
 
@@ -34,26 +60,409 @@ func downloadAction(cmd *cobra.Command, args []string) error {
 		SkipInstalled: false,
 	}
 
-	ctx := cmd.Context()
+	cache, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+
 	flags := cmd.Flags()
-	cacheStr, err := flags.GetString("cache")
+	arch, err := flags.GetString("arch")
 	if err != nil {
 		return err
 	}
-	cache, err := cache.New(cacheStr)
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, arch)
+	if err != nil {
+		return err
+	}
+
+	manifestOnly, err := flags.GetBool("manifest-only")
+	if err != nil {
+		return err
+	}
+	if manifestOnly {
+		manifestFormat, err := flags.GetString("manifest-format")
+		if err != nil {
+			return err
+		}
+		if opts.Providers, err = getProviders(cmd); err != nil {
+			return err
+		}
+		entries, err := downloader.Manifest(d, cache, fileSpecs, opts)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("%d files are not cached yet", len(entries))
+		return downloader.WriteManifest(cmd.OutOrStdout(), entries, downloader.ManifestFormat(manifestFormat))
+	}
+
+	dryRun, err := flags.GetBool("dry-run")
 	if err != nil {
 		return err
 	}
-	opts.Providers, err = flags.GetStringSlice("provider")
+	jsonFlag, err := jsonOutput(cmd)
 	if err != nil {
 		return err
 	}
+	w := cmd.OutOrStdout()
+	if dryRun {
+		entries, _, err := runPlanner(cmd, d, cache, fileSpecs, opts)
+		if err != nil {
+			return err
+		}
+		if jsonFlag {
+			return writeJSON(w, DownloadResult{Plan: entries})
+		}
+		return downloader.WritePlan(w, entries)
+	}
 
-	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	res, err := runDownloader(cmd, d, cache, fileSpecs, opts)
 	if err != nil {
 		return err
 	}
+	if jsonFlag {
+		x := DownloadResult{Files: []DownloadedFile{}}
+		for _, sp := range res.PackagesToBeInstalled {
+			f, err := newDownloadedFile(cache, sp)
+			if err != nil {
+				return err
+			}
+			x.Files = append(x.Files, *f)
+		}
+		return writeJSON(w, x)
+	}
+	return nil
+}
+
+// DownloadResult is printed by `repro-get download --format=json`.
+type DownloadResult struct {
+	Files []DownloadedFile       `json:"Files,omitempty"`
+	Plan  []downloader.PlanEntry `json:"Plan,omitempty"` // Only with --dry-run
+}
+
+// DownloadedFile is a file in the cache.
+type DownloadedFile struct {
+	Name string `json:"Name"` // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Sum  string `json:"Sum"`  // In the format of the hash file, e.g., "sha512:<SHA512>" for SHA512
+	Path string `json:"Path"` // The path in the cache
+	Size int64  `json:"Size"`
+}
+
+func newDownloadedFile(c *cache.Cache, sp filespec.FileSpec) (*DownloadedFile, error) {
+	f := &DownloadedFile{
+		Name: sp.Name,
+		Sum:  sp.Sum(),
+	}
+	var err error
+	if f.Path, err = c.LookupBlobAbsPath(f.Sum); err != nil {
+		return nil, err
+	}
+	if f.Size, err = c.BlobSize(f.Sum); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// newVerifiedFileSpecs returns a file spec map from the hash files, after verifying their signatures (see newHashsigVerifyOpts).
+// The snapshot in the TUF repository (--tuf-repository) is applied too.
+// Only the entries for arch are returned, when the hash files have the architecture sections.
+func newVerifiedFileSpecs(cmd *cobra.Command, hashFiles []string, arch string) (map[string]*filespec.FileSpec, error) {
+	opts, err := newHashsigVerifyOpts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if err = hashsig.VerifyFiles(cmd.Context(), hashFiles, opts); err != nil {
+		return nil, err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFilesForArch(arch, hashFiles...)
+	if err != nil {
+		return nil, err
+	}
+	tufCfg, err := getTUFDistroConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if tufCfg != nil && tufCfg.Snapshot != "" {
+		for _, sp := range fileSpecs {
+			if sp.Snapshot == "" {
+				sp.Snapshot = tufCfg.Snapshot
+			}
+		}
+	}
+	return fileSpecs, nil
+}
+
+// newHashsigVerifyOpts returns the options for verifying the signatures of the hash files,
+// with --signature-keyring, --signature-minisign-key, --keys-dir, --sigstore-identity, --sigstore-oidc-issuer, and --require-signature.
+// The keys in the TUF repository (--tuf-repository) are applied too.
+func newHashsigVerifyOpts(cmd *cobra.Command) (hashsig.VerifyOpts, error) {
+	var opts hashsig.VerifyOpts
+	flags := cmd.Flags()
+	keyrings, err := flags.GetStringSlice("signature-keyring")
+	if err != nil {
+		return opts, err
+	}
+	trustedKeyrings, err := trustedKeyFiles(cmd, keyring.OpenPGP)
+	if err != nil {
+		return opts, err
+	}
+	keyrings = append(keyrings, trustedKeyrings...)
+	minisignFlag, err := flags.GetStringSlice("signature-minisign-key")
+	if err != nil {
+		return opts, err
+	}
+	var minisignKeys []string // the inline keys
+	minisignKeyFiles, err := trustedKeyFiles(cmd, keyring.Minisign)
+	if err != nil {
+		return opts, err
+	}
+	for _, v := range minisignFlag {
+		if _, err := os.Stat(v); err == nil {
+			minisignKeyFiles = append(minisignKeyFiles, v)
+		} else {
+			minisignKeys = append(minisignKeys, v)
+		}
+	}
+	tufCfg, err := getTUFDistroConfig(cmd)
+	if err != nil {
+		return opts, err
+	}
+	if tufCfg != nil {
+		for _, f := range tufCfg.keyFiles {
+			switch {
+			case strings.HasSuffix(f, keyring.RSASuffix):
+			case strings.HasSuffix(f, keyring.MinisignSuffix):
+				minisignKeyFiles = append(minisignKeyFiles, f)
+			default:
+				keyrings = append(keyrings, f)
+			}
+		}
+	}
+	opts.Keyring, err = hashsig.ReadKeyring(keyrings...)
+	if err != nil {
+		return opts, err
+	}
+	opts.MinisignKeys, err = hashsig.ReadMinisignPublicKeys(minisignKeyFiles...)
+	if err != nil {
+		return opts, err
+	}
+	for _, v := range minisignKeys {
+		k, err := hashsig.ParseMinisignPublicKey(v)
+		if err != nil {
+			return opts, fmt.Errorf("failed to parse --signature-minisign-key %q as a file or a base64-encoded public key: %w", v, err)
+		}
+		opts.MinisignKeys = append(opts.MinisignKeys, *k)
+	}
+	var sigstoreOpts hashsig.SigstoreOpts
+	sigstoreOpts.Identity, err = flags.GetString("sigstore-identity")
+	if err != nil {
+		return opts, err
+	}
+	sigstoreOpts.OIDCIssuer, err = flags.GetString("sigstore-oidc-issuer")
+	if err != nil {
+		return opts, err
+	}
+	if sigstoreOpts.Identity != "" || sigstoreOpts.OIDCIssuer != "" {
+		if sigstoreOpts.Identity == "" || sigstoreOpts.OIDCIssuer == "" {
+			return opts, errors.New("--sigstore-identity and --sigstore-oidc-issuer have to be specified together")
+		}
+		opts.Sigstore = &sigstoreOpts
+	}
+	opts.Require, err = flags.GetBool("require-signature")
+	return opts, err
+}
+
+// verifyPackageSignatures verifies the signatures embedded in the cached packages, when --verify-package-signatures is specified.
+// Returns nil when the flag is not specified.
+func verifyPackageSignatures(cmd *cobra.Command, d distro.Distro, c *cache.Cache, pkgs []filespec.FileSpec) ([]distro.PackageSignature, error) {
+	flags := cmd.Flags()
+	verify, err := flags.GetBool("verify-package-signatures")
+	if err != nil || !verify {
+		return nil, err
+	}
+	verifier, ok := d.(distro.PackageVerifier)
+	if !ok {
+		return nil, fmt.Errorf("distro driver %q does not support --verify-package-signatures", d.Info().Name)
+	}
+	var opts distro.VerifyPackagesOpts
+	if opts.PoliciesDir, err = flags.GetString("debsig-policies-dir"); err != nil {
+		return nil, err
+	}
+	if opts.KeyringsDir, err = flags.GetString("debsig-keyrings-dir"); err != nil {
+		return nil, err
+	}
+	if opts.Keyrings, err = flags.GetStringSlice("package-keyring"); err != nil {
+		return nil, err
+	}
+	trustedKeyrings, err := trustedKeyFiles(cmd, keyring.OpenPGP)
+	if err != nil {
+		return nil, err
+	}
+	opts.Keyrings = append(opts.Keyrings, trustedKeyrings...)
+	tufCfg, err := getTUFDistroConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if tufCfg != nil {
+		opts.Keyrings = append(opts.Keyrings, tufCfg.keyFiles...)
+	}
+	sigs, err := verifier.VerifyPackages(cmd.Context(), c, pkgs, opts)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Verified the signatures of %d packages", len(sigs))
+	return sigs, nil
+}
 
-	_, err = downloader.Download(ctx, d, cache, fileSpecs, opts)
-	return err
+// runDownloader runs downloader.Download, with the options filled from the global flags.
+func runDownloader(cmd *cobra.Command, d distro.Distro, c *cache.Cache, fileSpecs map[string]*filespec.FileSpec, opts downloader.Opts) (*downloader.Result, error) {
+	flags := cmd.Flags()
+	var err error
+	opts.Providers, err = getProviders(cmd)
+	if err != nil {
+		return nil, err
+	}
+	deltaProviders, err := flags.GetStringSlice("delta-provider")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range deltaProviders {
+		dp, err := downloader.ParseDeltaProvider(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse --delta-provider: %w", err)
+		}
+		opts.DeltaProviders = append(opts.DeltaProviders, *dp)
+	}
+	opts.Retries, err = flags.GetInt("retries")
+	if err != nil {
+		return nil, err
+	}
+	opts.RetryBackoff, err = flags.GetDuration("retry-backoff")
+	if err != nil {
+		return nil, err
+	}
+	opts.Timeout, err = flags.GetDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+	opts.PerFileTimeout, err = flags.GetDuration("per-file-timeout")
+	if err != nil {
+		return nil, err
+	}
+	reorder, err := flags.GetBool("provider-reorder")
+	if err != nil {
+		return nil, err
+	}
+	persist, err := flags.GetBool("provider-health-persist")
+	if err != nil {
+		return nil, err
+	}
+	if reorder {
+		opts.ProviderHealth = downloader.NewProviderHealth()
+		if persist {
+			if h, err := downloader.LoadProviderHealth(c); err != nil {
+				logrus.WithError(err).Warn("Failed to load the provider health")
+			} else {
+				opts.ProviderHealth = h
+			}
+		}
+	}
+	var quietEvents bool
+	opts.EventHandler, quietEvents, err = newEventHandler(cmd)
+	if err != nil {
+		return nil, err
+	}
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return nil, err
+	}
+	// stdout is used for the events, the JSON output, or the output of the caller (when opts.Quiet is already set)
+	opts.Quiet = opts.Quiet || quietEvents || jsonFlag
+	res, err := downloader.Download(cmd.Context(), d, c, fileSpecs, opts)
+	if reorder && persist {
+		if saveErr := opts.ProviderHealth.Save(c); saveErr != nil {
+			logrus.WithError(saveErr).Warn("Failed to save the provider health")
+		}
+	}
+	return res, err
+}
+
+// runPlanner returns the plan of runDownloader, without accessing the network.
+// The returned result contains the packages that would be installed.
+func runPlanner(cmd *cobra.Command, d distro.Distro, c *cache.Cache, fileSpecs map[string]*filespec.FileSpec, opts downloader.Opts) ([]downloader.PlanEntry, *downloader.Result, error) {
+	flags := cmd.Flags()
+	var err error
+	opts.Providers, err = getProviders(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	reorder, err := flags.GetBool("provider-reorder")
+	if err != nil {
+		return nil, nil, err
+	}
+	persist, err := flags.GetBool("provider-health-persist")
+	if err != nil {
+		return nil, nil, err
+	}
+	if reorder && persist {
+		if opts.ProviderHealth, err = downloader.LoadProviderHealth(c); err != nil {
+			logrus.WithError(err).Warn("Failed to load the provider health")
+			opts.ProviderHealth = nil
+		}
+	}
+	entries, err := downloader.Plan(cmd.Context(), d, c, fileSpecs, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		res                downloader.Result
+		nDownload, nCached int
+	)
+	for _, ent := range entries {
+		switch ent.Action {
+		case downloader.PlanDownload:
+			nDownload++
+		case downloader.PlanCached:
+			nCached++
+		default:
+			continue
+		}
+		res.PackagesToBeInstalled = append(res.PackagesToBeInstalled, *fileSpecs[ent.Name])
+	}
+	logrus.Infof("Dry run: %d files would be downloaded, %d files are already cached", nDownload, nCached)
+	return entries, &res, nil
+}
+
+// newEventHandler returns the handler that writes the progress events as JSON lines, when --progress=json is specified.
+// quiet is true when the events are written to stdout.
+func newEventHandler(cmd *cobra.Command) (handler downloader.EventHandler, quiet bool, err error) {
+	flags := cmd.Flags()
+	progress, err := flags.GetString("progress")
+	if err != nil {
+		return nil, false, err
+	}
+	switch progress {
+	case "auto":
+		return nil, false, nil
+	case "json":
+	default:
+		return nil, false, fmt.Errorf("unknown --progress value %q, expected \"auto\" or \"json\"", progress)
+	}
+	fd, err := flags.GetInt("progress-fd")
+	if err != nil {
+		return nil, false, err
+	}
+	w := os.NewFile(uintptr(fd), "progress")
+	if w == nil {
+		return nil, false, fmt.Errorf("invalid --progress-fd %d", fd)
+	}
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	handler = func(ev downloader.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(ev); err != nil {
+			logrus.WithError(err).Warn("Failed to write the progress event")
+		}
+	}
+	return handler, fd == int(os.Stdout.Fd()), nil
 }
@@ -17,6 +17,13 @@ func newHashCommand() *cobra.Command {
 		newHashGenerateCommand(),
 		newHashUpdateCommand(),
 		newHashInspectCommand(),
+		newHashConvertCommand(),
+		newHashSignCommand(),
+		newHashDiffCommand(),
+		newHashMergeCommand(),
+		newHashLintCommand(),
+		newHashImportCommand(),
+		newHashSourceDateEpochCommand(),
 	)
 	return cmd
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+func newHashConvertCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "convert [flags] FILE",
+		Short: "Convert the hash file to a structured lockfile (JSON or YAML), and vice versa",
+		Long: `Convert the hash file to a structured lockfile (JSON or YAML), and vice versa.
+The format of the input file is detected automatically.
+The file is written to stdout.`,
+		Example: "  repro-get hash convert --format=json SHA256SUMS-" + archutil.OCIArchDashVariant() + " >repro-get.lock.json\n" +
+			"  repro-get hash convert --format=sha256sums repro-get.lock.json >SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.ExactArgs(1),
+		RunE: hashConvertAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("format", string(lockfile.FormatJSON), fmt.Sprintf("Output format (%v)", lockfile.Formats))
+	return cmd
+}
+
+func hashConvertAction(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	lf, err := readLockfile(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := lf.Marshal(lockfile.Format(format))
+	if err != nil {
+		return err
+	}
+	_, err = cmd.OutOrStdout().Write(b)
+	return err
+}
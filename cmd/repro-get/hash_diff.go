@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newHashDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [flags] OLD NEW",
+		Short: "Show the difference of the hash files",
+		Long: `Show the difference of the hash files (or the structured lockfiles).
+
+The output lines are prefixed with:
+  "+": added
+  "-": removed
+  "~": upgraded (or downgraded)
+  "!": suspicious; the digest was changed without changing the version
+
+The suspicious changes should not happen for the legitimate updates of the packages,
+and have to be reviewed carefully.`,
+		Example: "  repro-get hash diff SHA256SUMS-amd64.old SHA256SUMS-amd64",
+		Args:    cobra.ExactArgs(2),
+		RunE:    hashDiffAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "Enable JSON output")
+	return cmd
+}
+
+func readLockfile(fname string) (*lockfile.Lockfile, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lf, err := lockfile.Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", fname, err)
+	}
+	return lf, nil
+}
+
+func hashDiffAction(cmd *cobra.Command, args []string) error {
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	oldLF, err := readLockfile(args[0])
+	if err != nil {
+		return err
+	}
+	newLF, err := readLockfile(args[1])
+	if err != nil {
+		return err
+	}
+	diff := lockfile.Diff(oldLF, newLF)
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		if diff == nil {
+			diff = []lockfile.DiffEntry{}
+		}
+		b, err := json.MarshalIndent(diff, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	return printDiff(w, diff)
+}
+
+// printDiff prints the diff with the "+", "-", "~", and "!" prefixes, and logs the summary.
+func printDiff(w io.Writer, diff []lockfile.DiffEntry) error {
+	counts := make(map[lockfile.DiffType]int)
+	for _, e := range diff {
+		counts[e.Type]++
+		var line string
+		switch e.Type {
+		case lockfile.DiffAdded:
+			line = "+ " + e.New.Name
+		case lockfile.DiffRemoved:
+			line = "- " + e.Old.Name
+		case lockfile.DiffUpgraded:
+			line = fmt.Sprintf("~ %s (%s -> %s)", e.New.Name, e.Old.Version, e.New.Version)
+		case lockfile.DiffSuspicious:
+			line = fmt.Sprintf("! %s (%s -> %s)", e.New.Name, strings.Join(e.Old.Sums(), ","), strings.Join(e.New.Sums(), ","))
+		}
+		if archSection := e.ArchSection(); archSection != "" {
+			line += " [arch=" + archSection + "]"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("%d added, %d removed, %d upgraded, %d suspicious",
+		counts[lockfile.DiffAdded], counts[lockfile.DiffRemoved], counts[lockfile.DiffUpgraded], counts[lockfile.DiffSuspicious])
+	if n := counts[lockfile.DiffSuspicious]; n > 0 {
+		logrus.Warnf("%d packages have the digests changed without changing the versions", n)
+	}
+	return nil
+}
@@ -3,12 +3,22 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
-	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/distro/alpine"
+	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
+	"github.com/reproducible-containers/repro-get/pkg/dockerfileutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
 	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -18,14 +28,35 @@ func newHashGenerateCommand() *cobra.Command {
 		Short: "Generate the hash file",
 		Long: `Generate the hash file.
 The file is written to stdout.`,
-		Example: "  repro-get hash generate >SHA256SUMS-" + archutil.OCIArchDashVariant(),
-		Args:    cobra.ArbitraryArgs,
-		RunE:    hashGenerateAction,
+		Example: "  repro-get hash generate >SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n\n" +
+			"  # Generate the hash file for the Dockerfile, without building the image\n" +
+			"  repro-get --distro=debian hash generate --dockerfile=Dockerfile >SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.ArbitraryArgs,
+		RunE: hashGenerateAction,
 
+		ValidArgsFunction:     completePackageNames,
 		DisableFlagsInUseLine: true,
 	}
 	flags := cmd.Flags()
-	flags.String("dedupe", "", "Skip generating entries that are already presend in the specified file")
+	flags.StringSlice("dedupe", nil, "Skip generating entries that are already present in the specified files, such as \"SHA256SUMS-*\" (shell glob patterns are expanded)")
+	flags.String("lockfile", "", "Lockfile to read, for the drivers that read lockfiles (e.g., \"package-lock.json\" for npm)")
+	flags.String("root", "/", "Root filesystem to inspect for the installed packages (Debian, Ubuntu, and Alpine only)")
+	flags.StringSlice("keyring", nil, "OpenPGP keyrings (or RSA public keys \"*.rsa.pub\" for Alpine) for verifying the repository metadata (or the packages for Fedora), in addition to the keys in --keys-dir (default: the keyrings of the distro; none for Alpine and Fedora)")
+	flags.Bool("allow-unsigned", false, "Allow unsigned (or unverifiable) repository metadata")
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\" (Debian, Ubuntu, and Alpine only for foreign architectures)")
+	flags.String("snapshot", "", "Record the snapshot timestamp (e.g., \"20221101T000000Z\") in the hash file, for the providers with {{.Snapshot}}")
+	flags.Bool("resolve", false, "Resolve the dependencies of the specified packages from the repository metadata, without installing them (Debian, Ubuntu, and Alpine only)")
+	flags.String("dockerfile", "", "Generate the hashes of the packages installed by the RUN instructions of the Dockerfile (\"apt-get install\", \"apk add\"), with their dependencies (implies --resolve)")
+	flags.StringSlice("index", nil, "Repository index files or URLs to resolve the specified packages from, such as \"https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/APKINDEX.tar.gz\" (Alpine only)")
+	flags.Bool("record-index", false, "Record the SHA256 of the repository indexes (e.g., Packages and APKINDEX) in the hash file (Debian, Ubuntu, and Alpine only)")
+	flags.Bool("world", false, "Generate the hashes of the packages in the world file (/etc/apk/world) and their dependencies, with the installed versions (Alpine only)")
+	flags.Bool("source", false, "Generate the hashes of the source packages of the binary packages (Debian and Ubuntu only)")
+	flags.String("ppa", "", "Generate the hashes of the packages from the Launchpad PPA, such as \"deadsnakes/ppa\", and record the PPA in the hash file (Ubuntu only)")
+	flags.String("target-release", "", "Target release for the pinning, such as \"bullseye-backports\" (default: APT::Default-Release) (Debian and Ubuntu only)")
+	flags.StringSlice("preferences", nil, "Pinning preferences files (default: /etc/apt/preferences and /etc/apt/preferences.d/* in the root filesystem) (Debian and Ubuntu only)")
+	flags.Bool("sha512", false, "Generate SHA512SUMS instead of SHA256SUMS (Debian, Ubuntu, and Maven only)")
+	flags.Bool("with-urls", false, "Record the origin URL of each entry as a directive (\"#repro-get:url=<URL>  <FILENAME>\"), for the providers with {{.OriginURL}} and for auditing")
+	flags.String("format", string(lockfile.FormatSHA256SUMS), fmt.Sprintf("Output format (%v); \"json\" and \"yaml\" generate a structured lockfile", lockfile.Formats))
 	return cmd
 }
 
@@ -41,41 +72,323 @@ func hashGenerateAction(cmd *cobra.Command, args []string) error {
 	opts := distro.HashOpts{
 		FilterByName: args,
 	}
+	opts.Lockfile, err = flags.GetString("lockfile")
+	if err != nil {
+		return err
+	}
+	opts.Root, err = flags.GetString("root")
+	if err != nil {
+		return err
+	}
+	opts.Keyrings, err = flags.GetStringSlice("keyring")
+	if err != nil {
+		return err
+	}
+	opts.ExtraKeyrings, err = extraKeyrings(cmd)
+	if err != nil {
+		return err
+	}
+	opts.AllowUnsigned, err = flags.GetBool("allow-unsigned")
+	if err != nil {
+		return err
+	}
+	opts.Arch, err = flags.GetString("arch")
+	if err != nil {
+		return err
+	}
+	opts.Resolve, err = flags.GetBool("resolve")
+	if err != nil {
+		return err
+	}
+	dockerfile, err := flags.GetString("dockerfile")
+	if err != nil {
+		return err
+	}
+	if dockerfile != "" {
+		names, err := packagesFromDockerfile(dockerfile, d.Info().Name)
+		if err != nil {
+			return err
+		}
+		opts.FilterByName = append(opts.FilterByName, names...)
+		opts.Resolve = true
+	}
+	opts.Indexes, err = flags.GetStringSlice("index")
+	if err != nil {
+		return err
+	}
+	opts.World, err = flags.GetBool("world")
+	if err != nil {
+		return err
+	}
+	opts.Source, err = flags.GetBool("source")
+	if err != nil {
+		return err
+	}
+	opts.PPA, err = flags.GetString("ppa")
+	if err != nil {
+		return err
+	}
+	opts.TargetRelease, err = flags.GetString("target-release")
+	if err != nil {
+		return err
+	}
+	opts.Preferences, err = flags.GetStringSlice("preferences")
+	if err != nil {
+		return err
+	}
+	opts.SHA512, err = flags.GetBool("sha512")
+	if err != nil {
+		return err
+	}
 
 	if d.Info().CacheIsNeededForGeneratingHash {
-		cacheStr, err := flags.GetString("cache")
+		opts.Cache, err = newCache(cmd)
 		if err != nil {
 			return err
 		}
-		opts.Cache, err = cache.New(cacheStr)
+	}
+
+	snapshot, err := flags.GetString("snapshot")
+	if err != nil {
+		return err
+	}
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	var w io.Writer = cmd.OutOrStdout()
+	var buf bytes.Buffer
+	switch lockfile.Format(format) {
+	case lockfile.FormatSHA256SUMS:
+	case lockfile.FormatJSON, lockfile.FormatYAML:
+		// Converted to the lockfile after generating the hash file
+		w = &buf
+	default:
+		return fmt.Errorf("unknown format %q (expected one of %v)", format, lockfile.Formats)
+	}
+	if snapshot != "" {
+		if err = filespec.ValidateSnapshot(snapshot); err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintln(w, sha256sums.FormatDirective(filespec.DirectiveSnapshot, snapshot)); err != nil {
+			return err
+		}
+	}
+	if opts.PPA != "" {
+		ppa, err := filespec.ParsePPA(opts.PPA)
 		if err != nil {
 			return err
 		}
+		if _, err = fmt.Fprintln(w, sha256sums.FormatDirective(filespec.DirectivePPA, ppa.String())); err != nil {
+			return err
+		}
+	}
+	recordIndex, err := flags.GetBool("record-index")
+	if err != nil {
+		return err
+	}
+	if recordIndex {
+		opts.IndexWriter = newIndexWriter(w)
 	}
-
-	w := cmd.OutOrStdout()
 	hw := distro.NewHashWriter(w)
+	withURLs, err := flags.GetBool("with-urls")
+	if err != nil {
+		return err
+	}
+	if withURLs {
+		hw = newURLRecorder(w, hw, &opts, d.Info().DefaultProviders, snapshot)
+	}
+	hw = newSignerRecorder(w, hw, &opts)
 
-	dedupeFile, err := flags.GetString("dedupe")
+	dedupePatterns, err := flags.GetStringSlice("dedupe")
 	if err != nil {
 		return err
 	}
-	if dedupeFile != "" {
-		old, err := os.ReadFile(dedupeFile)
+	if len(dedupePatterns) > 0 {
+		oldSums, err := readDedupeFiles(dedupePatterns)
 		if err != nil {
-			return fmt.Errorf("failed to open %q: %w", dedupeFile, err)
-		}
-		oldSums, err := sha256sums.Parse(bytes.NewReader(old))
-		if err != nil {
-			return fmt.Errorf("failed to parse %q as SHA256SUMS: %w", dedupeFile, err)
+			return err
 		}
 		hw0 := hw
 		hw = func(sha256sum, filename string) error {
-			if oldSums[filename] == sha256sum {
+			if oldSums[filename+"  "+sha256sum] {
 				return nil
 			}
 			return hw0(sha256sum, filename)
 		}
 	}
-	return d.GenerateHash(ctx, hw, opts)
+	generatedAt := time.Now().UTC()
+	if err = d.GenerateHash(ctx, hw, opts); err != nil {
+		return err
+	}
+	if w != &buf {
+		return nil
+	}
+	lf, err := lockfile.FromSHA256SUMS(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	lf.GeneratedAt = &generatedAt
+	lf.Distro = d.Info().Name
+	b, err := lf.Marshal(lockfile.Format(format))
+	if err != nil {
+		return err
+	}
+	_, err = cmd.OutOrStdout().Write(b)
+	return err
+}
+
+// readDedupeFiles reads the hash files (or the lockfiles) for --dedupe, with the glob patterns expanded.
+// The signatures and the CIDs files alongside the hash files are skipped.
+// The keys of the returned map are "<FILENAME>  <SUM>".
+func readDedupeFiles(patterns []string) (map[string]bool, error) {
+	res := make(map[string]bool)
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(files) == 0 {
+			if _, err = os.Stat(pattern); err != nil {
+				return nil, fmt.Errorf("failed to open %q: %w", pattern, err)
+			}
+			files = []string{pattern}
+		}
+		for _, f := range files {
+			if hashsig.IsSignatureFile(f) || strings.HasSuffix(f, filespec.CIDsFileSuffix) {
+				logrus.Debugf("Skipping %q for --dedupe", f)
+				continue
+			}
+			lf, err := readLockfile(f)
+			if err != nil {
+				return nil, err
+			}
+			for _, pkg := range lf.Packages {
+				for _, sum := range pkg.Sums() {
+					res[pkg.Name+"  "+sum] = true
+				}
+			}
+			logrus.Debugf("Loaded %d entries from %q for --dedupe", len(lf.Packages), f)
+		}
+	}
+	return res, nil
+}
+
+// packagesFromDockerfile returns the names of the packages installed by the Dockerfile, with the package manager of the distro.
+func packagesFromDockerfile(dockerfile, distroName string) ([]string, error) {
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkgs, err := dockerfileutil.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", dockerfile, err)
+	}
+	var names []string
+	switch distroName {
+	case debian.NameDebian, debian.NameUbuntu:
+		names = pkgs.Apt
+	case alpine.Name:
+		names = pkgs.APK
+	default:
+		return nil, fmt.Errorf("--dockerfile is not supported for distro %q (Hint: specify --distro=debian, --distro=ubuntu, or --distro=alpine)", distroName)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no package for distro %q was found in %q (found %d apt packages and %d apk packages)", distroName, dockerfile, len(pkgs.Apt), len(pkgs.APK))
+	}
+	logrus.Infof("Found %d packages in %q: %v", len(names), dockerfile, names)
+	return names, nil
+}
+
+// newIndexWriter returns a HashWriter that writes the hashes of the repository indexes as directives,
+// such as "#repro-get:index=<SHA256>  deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages".
+func newIndexWriter(w io.Writer) distro.HashWriter {
+	return func(sha256sum, filename string) error {
+		_, err := fmt.Fprintln(w, sha256sums.FormatDirective(filespec.DirectiveIndex, sha256sum+"  "+filename))
+		return err
+	}
+}
+
+// newURLRecorder returns a HashWriter that writes each new entry with hw, followed by the url directive, such as
+// "#repro-get:url=http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb  pool/main/h/hello/hello_2.10-2_amd64.deb".
+//
+// opts.URLWriter is set for recording the origin URLs known to the distro driver.
+// Otherwise the URL is derived from the first HTTP(S) provider that is applicable to the entry.
+func newURLRecorder(w io.Writer, hw distro.HashWriter, opts *distro.HashOpts, providers []string, snapshot string) distro.HashWriter {
+	urls := make(map[string]string) // key: file name
+	opts.URLWriter = func(rawURL, filename string) error {
+		urls[filename] = rawURL
+		return nil
+	}
+	var fsOpts []filespec.Option
+	if snapshot != "" {
+		fsOpts = append(fsOpts, filespec.WithSnapshot(snapshot))
+	}
+	if ppa, err := filespec.ParsePPA(opts.PPA); err == nil {
+		fsOpts = append(fsOpts, filespec.WithPPA(ppa))
+	}
+	written := make(map[string]bool)
+	return func(sha256sum, filename string) error {
+		if err := hw(sha256sum, filename); err != nil {
+			return err
+		}
+		if written[filename] {
+			return nil
+		}
+		written[filename] = true
+		u, ok := urls[filename]
+		if !ok {
+			u = urlFromProviders(filename, sha256sum, providers, fsOpts...)
+		}
+		if u == "" {
+			logrus.Warnf("No origin URL is known for %q", filename)
+			return nil
+		}
+		_, err := fmt.Fprintln(w, sha256sums.FormatDirective(filespec.DirectiveURL, u+"  "+filename))
+		return err
+	}
+}
+
+// newSignerRecorder returns a HashWriter that writes each new entry with hw, followed by the signed-by directive, such as
+// "#repro-get:signed-by=115DF9AEF857853EE8445D0A0727707EA15B79CC  fedora-release/37/1/noarch/fedora-release-37-1.noarch.rpm",
+// when the distro driver verified the signature of the entry.
+// opts.SignerWriter is set for receiving the fingerprints from the distro driver.
+func newSignerRecorder(w io.Writer, hw distro.HashWriter, opts *distro.HashOpts) distro.HashWriter {
+	signers := make(map[string]string) // key: file name
+	opts.SignerWriter = func(fingerprint, filename string) error {
+		signers[filename] = fingerprint
+		return nil
+	}
+	return func(sha256sum, filename string) error {
+		if err := hw(sha256sum, filename); err != nil {
+			return err
+		}
+		fpr, ok := signers[filename]
+		if !ok {
+			return nil
+		}
+		delete(signers, filename)
+		_, err := fmt.Fprintln(w, sha256sums.FormatDirective(filespec.DirectiveSignedBy, fpr+"  "+filename))
+		return err
+	}
+}
+
+// urlFromProviders returns the URL of the file with the first HTTP(S) provider that is applicable, or an empty string.
+func urlFromProviders(filename, sum string, providers []string, fsOpts ...filespec.Option) string {
+	sp, err := filespec.New(filename, sum, fsOpts...)
+	if err != nil {
+		logrus.WithError(err).Debugf("Failed to parse %q", filename)
+		return ""
+	}
+	for _, provider := range providers {
+		u, err := sp.URL(provider)
+		if err != nil {
+			continue
+		}
+		if u.Scheme == "http" || u.Scheme == "https" {
+			return u.String()
+		}
+	}
+	return ""
 }
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReadDedupeFiles(t *testing.T) {
+	dir := t.TempDir()
+	const sum = "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
+	hashFile := filepath.Join(dir, "SHA256SUMS-amd64")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(sum+"  pool/main/h/hello/hello_2.10-2_amd64.deb\n"), 0644))
+	for _, suffix := range []string{".asc", ".minisig", ".sig", ".sigstore.json", ".ipfs"} {
+		assert.NilError(t, os.WriteFile(hashFile+suffix, []byte("not a hash file\n"), 0644))
+	}
+	res, err := readDedupeFiles([]string{filepath.Join(dir, "SHA256SUMS-*")})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]bool{"pool/main/h/hello/hello_2.10-2_amd64.deb  " + sum: true}, res)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
+	"github.com/spf13/cobra"
+)
+
+// hashImporters is the map of the formats of 'hash import --from'.
+var hashImporters = map[string]func(io.Reader, distro.HashWriter) error{
+	"apt-print-uris": debian.ImportPrintURIs,
+}
+
+func newHashImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import [flags] [FILE] >SHA256SUMS",
+		Short: "Import the hashes from the output of other tools",
+		Long: `Import the hashes from the output of other tools, such as 'apt-get install --print-uris -qq'.
+The output of the tool is read from FILE, or stdin when FILE is omitted or "-".
+The hash file is written to stdout.
+
+With --from=apt-print-uris, the packages are resolved by apt itself, so the hash file can be generated
+on the systems where the resolution modes of repro-get ('hash generate --resolve') are unavailable.`,
+		Example: "  apt-get install --print-uris -qq hello | repro-get hash import --from=apt-print-uris >SHA256SUMS",
+		Args:    cobra.MaximumNArgs(1),
+		RunE:    hashImportAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("from", "", "Format of the input: \"apt-print-uris\" (the output of 'apt-get install --print-uris -qq')")
+	_ = cmd.RegisterFlagCompletionFunc("from", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"apt-print-uris"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func hashImportAction(cmd *cobra.Command, args []string) error {
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+	importer, ok := hashImporters[from]
+	if !ok {
+		return fmt.Errorf("unknown format %q for --from (expected \"apt-print-uris\")", from)
+	}
+	var r io.Reader = cmd.InOrStdin()
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return importer(r, distro.NewHashWriter(cmd.OutOrStdout()))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/downloader"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashlint"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newHashLintCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [flags] SHA256SUMS...",
+		Short: "Check the hash files for the common mistakes",
+		Long: `Check the hash files for the common mistakes:
+- malformed lines, digests, and directives
+- duplicate or conflicting entries for the same file name
+- file names that cannot be parsed, such as "*.deb" files without the version
+- packages of multiple architectures in a single hash file
+
+With --check-reachability, the files are also checked to be retrievable from the providers, as in 'repro-get probe'.
+
+Fails when a problem is found. Useful as a pre-commit hook or a CI gate.`,
+		Example: "  repro-get hash lint SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get hash lint --check-reachability SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(1),
+		RunE: hashLintAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "Enable JSON output")
+	flags.Bool("check-reachability", false, "Check that the files are retrievable from the providers (needs the network)")
+	flags.Int("jobs", 8, "Number of the concurrent requests for --check-reachability")
+	return cmd
+}
+
+func hashLintAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	jsonFlag, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+	checkReachability, err := flags.GetBool("check-reachability")
+	if err != nil {
+		return err
+	}
+	problems := []hashlint.Problem{}
+	for _, f := range args {
+		p, err := lintFile(f)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, p...)
+	}
+	if checkReachability {
+		if len(problems) > 0 {
+			logrus.Warn("Skipping checking the reachability, as the hash files have problems")
+		} else {
+			p, err := lintReachability(cmd, args)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, p...)
+		}
+	}
+
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		b, err := json.MarshalIndent(problems, "", "    ")
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintln(w, string(b)); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			if _, err = fmt.Fprintln(w, p.String()); err != nil {
+				return err
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems", len(problems))
+	}
+	logrus.Info("No problem was found")
+	return nil
+}
+
+func lintFile(fname string) ([]hashlint.Problem, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return hashlint.Lint(fname, f)
+}
+
+// lintReachability returns the problems for the files that are not retrievable from any provider.
+func lintReachability(cmd *cobra.Command, hashFiles []string) ([]hashlint.Problem, error) {
+	flags := cmd.Flags()
+	var (
+		opts downloader.ProbeOpts
+		err  error
+	)
+	if opts.Jobs, err = flags.GetInt("jobs"); err != nil {
+		return nil, err
+	}
+	if opts.Providers, err = getProviders(cmd); err != nil {
+		return nil, err
+	}
+	d, err := getDistro(cmd)
+	if err != nil {
+		return nil, err
+	}
+	o := urlopener.New()
+	if err = configureURLOpener(cmd, o); err != nil {
+		return nil, err
+	}
+	var problems []hashlint.Problem
+	for _, hashFile := range hashFiles {
+		fileSpecs, err := filespec.NewFromSHA256SUMSFiles(hashFile)
+		if err != nil {
+			return nil, err
+		}
+		results, err := downloader.Probe(cmd.Context(), d, o, fileSpecs, opts)
+		if err != nil {
+			return nil, err
+		}
+		available := make(map[string]bool)
+		lastErrors := make(map[string]string)
+		var names []string
+		for _, r := range results {
+			if _, ok := available[r.Name]; !ok {
+				names = append(names, r.Name)
+			}
+			available[r.Name] = available[r.Name] || r.Available
+			if r.Error != "" {
+				lastErrors[r.Name] = r.Error
+			}
+		}
+		for _, name := range names {
+			if !available[name] {
+				problems = append(problems, hashlint.Problem{
+					File:    hashFile,
+					Name:    name,
+					Check:   hashlint.CheckReachability,
+					Message: fmt.Sprintf("%q is not retrievable from any provider (last error: %s) (Hint: add a snapshot provider with --provider, see 'repro-get probe')", name, lastErrors[name]),
+				})
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
+	"github.com/spf13/cobra"
+)
+
+func newHashMergeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge [flags] FILE... >SHA256SUMS",
+		Short: "Merge the hash files",
+		Long: `Merge the hash files (or the structured lockfiles), such as the hash files for the different architectures or stages.
+The file is written to stdout.
+
+The entries with the same file name are merged into one.
+Fails when the files have conflicting digests for the same file name, or conflicting directives such as "#repro-get:snapshot".
+
+With --arch-sections, the entries of each file are put into the architecture section ("#repro-get:arch=<ARCH>")
+named after the "-<ARCH>" suffix of the file name, so that the files for the different architectures
+can be maintained as a single file. The entries that are identical in all the architectures are put into the common section.`,
+		Example: "  repro-get hash merge SHA256SUMS-base SHA256SUMS-extra >SHA256SUMS\n" +
+			"  repro-get hash merge --arch-sections SHA256SUMS-amd64 SHA256SUMS-arm64 >SHA256SUMS",
+		Args: cobra.MinimumNArgs(1),
+		RunE: hashMergeAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("format", string(lockfile.FormatSHA256SUMS), fmt.Sprintf("Output format (%v)", lockfile.Formats))
+	flags.Bool("arch-sections", false, "Put the entries into the architecture sections, named after the \"-<ARCH>\" suffix of the file names, such as \"SHA256SUMS-amd64\"")
+	return cmd
+}
+
+func hashMergeAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	archSections, err := flags.GetBool("arch-sections")
+	if err != nil {
+		return err
+	}
+	merged := &lockfile.Lockfile{Version: lockfile.Version}
+	for _, f := range args {
+		lf, err := readLockfile(f)
+		if err != nil {
+			return err
+		}
+		if archSections {
+			arch, err := archFromHashFileName(f)
+			if err != nil {
+				return err
+			}
+			for i := range lf.Packages {
+				if lf.Packages[i].ArchSection == "" {
+					lf.Packages[i].ArchSection = arch
+				}
+			}
+		}
+		if err = merged.Merge(lf); err != nil {
+			return fmt.Errorf("failed to merge %q: %w", f, err)
+		}
+	}
+	if archSections {
+		merged.HoistCommonPackages()
+	}
+	b, err := merged.Marshal(lockfile.Format(format))
+	if err != nil {
+		return err
+	}
+	_, err = cmd.OutOrStdout().Write(b)
+	return err
+}
+
+// archFromHashFileName returns the architecture from the file name such as "SHA256SUMS-amd64" and "SHA256SUMS-arm-v7".
+func archFromHashFileName(fname string) (string, error) {
+	base := filepath.Base(fname)
+	for _, ext := range []string{".json", ".yaml", ".yml"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	_, arch, ok := strings.Cut(base, "-")
+	if !ok || filespec.ValidateArch(arch) != nil {
+		return "", fmt.Errorf("failed to determine the architecture from the file name %q (expected a name like \"SHA256SUMS-amd64\")", fname)
+	}
+	return arch, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newHashSignCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sign [flags] [SHA256SUMS]...",
+		Short: "Sign the hash files with an OpenPGP key, a minisign (or signify) key, or with Sigstore",
+		Long: `Sign the hash files with an OpenPGP key, a minisign (or signify) key, or with Sigstore.
+The ASCII-armored detached signature is written as "<FILE>` + hashsig.SignatureSuffix + `", alongside the hash file.
+The signature is verified on 'repro-get download' and 'repro-get install' with --signature-keyring.
+The passphrase of an encrypted private key is read from $REPRO_GET_SIGNING_KEY_PASSPHRASE.
+
+With --sigstore, the hash files are signed with the keyless flow of cosign, and the signatures are recorded in the Rekor transparency log.
+The Sigstore bundle is written as "<FILE>` + hashsig.SigstoreBundleSuffix + `", and verified with --sigstore-identity and --sigstore-oidc-issuer.
+
+With --minisign-key, the signature is written as "<FILE>` + hashsig.MinisignSignatureSuffix + `", in the format of minisign.
+With --signify-key, the signature is written as "<FILE>` + hashsig.SignifySignatureSuffix + `", in the format of signify.
+Both accept the secret keys of minisign and of signify (unencrypted), and are verified with --signature-minisign-key.
+The trusted comment of the minisign signature contains $SOURCE_DATE_EPOCH (or the current time) as the timestamp.`,
+		Example: "  gpg --export-secret-keys --armor KEYID >secring.asc\n" +
+			"  repro-get hash sign --key=secring.asc SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  gpg --export --armor KEYID >pubring.asc\n" +
+			"  repro-get --signature-keyring=pubring.asc --require-signature install SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n\n" +
+			"  # Sigstore (e.g., on GitHub Actions with \"permissions: id-token: write\")\n" +
+			"  repro-get hash sign --sigstore SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get --sigstore-identity='^https://github.com/USERNAME/REPO/' --sigstore-oidc-issuer='^https://token.actions.githubusercontent.com$' --require-signature install SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n\n" +
+			"  # minisign\n" +
+			"  minisign -G -p minisign.pub -s minisign.key\n" +
+			"  repro-get hash sign --minisign-key=minisign.key SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get --signature-minisign-key=minisign.pub --require-signature install SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(1),
+		RunE: hashSignAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("key", "", "OpenPGP private key file (binary or ASCII-armored) to sign with")
+	flags.Bool("sigstore", false, "Sign with the keyless flow of Sigstore (needs cosign), instead of an OpenPGP key")
+	flags.String("minisign-key", "", "minisign (or signify) secret key file to sign with, in the format of minisign (SHA256SUMS"+hashsig.MinisignSignatureSuffix+")")
+	flags.String("signify-key", "", "minisign (or signify) secret key file to sign with, in the format of signify (SHA256SUMS"+hashsig.SignifySignatureSuffix+")")
+	return cmd
+}
+
+func hashSignAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	key, err := flags.GetString("key")
+	if err != nil {
+		return err
+	}
+	sigstore, err := flags.GetBool("sigstore")
+	if err != nil {
+		return err
+	}
+	minisignKey, err := flags.GetString("minisign-key")
+	if err != nil {
+		return err
+	}
+	signifyKey, err := flags.GetString("signify-key")
+	if err != nil {
+		return err
+	}
+	var specified int
+	for _, v := range []bool{key != "", sigstore, minisignKey != "", signifyKey != ""} {
+		if v {
+			specified++
+		}
+	}
+	if specified > 1 {
+		return errors.New("--key, --sigstore, --minisign-key, and --signify-key are mutually exclusive")
+	}
+	if minisignKey != "" || signifyKey != "" {
+		return hashSignWithMinisign(args, minisignKey, signifyKey)
+	}
+	if sigstore {
+		for _, f := range args {
+			if err = hashsig.SignFileWithSigstore(cmd.Context(), f); err != nil {
+				return err
+			}
+			logrus.Infof("Wrote the Sigstore bundle of %q to %q", f, f+hashsig.SigstoreBundleSuffix)
+		}
+		return nil
+	}
+	if key == "" {
+		return errors.New("needs --key, --sigstore, --minisign-key, or --signify-key")
+	}
+	signer, err := hashsig.ReadSigner(key, []byte(os.Getenv("REPRO_GET_SIGNING_KEY_PASSPHRASE")))
+	if err != nil {
+		return err
+	}
+	for _, f := range args {
+		if err = hashsig.SignFile(f, signer); err != nil {
+			return err
+		}
+		logrus.Infof("Wrote the signature of %q to %q", f, f+hashsig.SignatureSuffix)
+	}
+	return nil
+}
+
+func hashSignWithMinisign(hashFiles []string, minisignKey, signifyKey string) error {
+	passphrase := []byte(os.Getenv("REPRO_GET_SIGNING_KEY_PASSPHRASE"))
+	if signifyKey != "" {
+		k, err := hashsig.ReadMinisignSecretKey(signifyKey, passphrase)
+		if err != nil {
+			return err
+		}
+		for _, f := range hashFiles {
+			if err = hashsig.SignFileWithSignify(f, k); err != nil {
+				return err
+			}
+			logrus.Infof("Wrote the signify signature of %q (signed by %s) to %q", f, k.Public().ID(), f+hashsig.SignifySignatureSuffix)
+		}
+		return nil
+	}
+	k, err := hashsig.ReadMinisignSecretKey(minisignKey, passphrase)
+	if err != nil {
+		return err
+	}
+	timestamp, err := sourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	for _, f := range hashFiles {
+		if err = hashsig.SignFileWithMinisign(f, k, timestamp); err != nil {
+			return err
+		}
+		logrus.Infof("Wrote the minisign signature of %q (signed by %s) to %q", f, k.Public().ID(), f+hashsig.MinisignSignatureSuffix)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/downloader"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newHashSourceDateEpochCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "source-date-epoch [flags] SHA256SUMS...",
+		Short: "Print the build time of the newest package in the hash files, for $SOURCE_DATE_EPOCH",
+		Long: `Print the build time of the newest package in the hash files, in seconds since the epoch.
+The value can be used as $SOURCE_DATE_EPOCH (https://reproducible-builds.org/docs/source-date-epoch/),
+so that the image built from the same hash files has the same timestamps.
+
+The packages are downloaded into the cache, as the build times are read from the package files.`,
+		Example: "  export SOURCE_DATE_EPOCH=$(repro-get hash source-date-epoch SHA256SUMS-" + archutil.OCIArchDashVariant() + ")",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    hashSourceDateEpochAction,
+
+		ValidArgsFunction:     completeHashFiles,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\", for the hash files with the architecture sections (\"#repro-get:arch=...\")")
+	return cmd
+}
+
+func hashSourceDateEpochAction(cmd *cobra.Command, args []string) error {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	arch, err := cmd.Flags().GetString("arch")
+	if err != nil {
+		return err
+	}
+	cache, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, arch)
+	if err != nil {
+		return err
+	}
+	// stdout is used for the result
+	res, err := runDownloader(cmd, d, cache, fileSpecs, downloader.Opts{Quiet: true})
+	if err != nil {
+		return err
+	}
+	t, err := newestPackageTime(d, cache, res.PackagesToBeInstalled)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return errors.New("no package was found in the hash files")
+	}
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), t.Unix())
+	return err
+}
+
+// newestPackageTime returns the build time of the newest package, or nil if no package is found.
+func newestPackageTime(d distro.Distro, c *cache.Cache, pkgs []filespec.FileSpec) (*time.Time, error) {
+	timer, ok := d.(distro.PackageTimer)
+	if !ok {
+		return nil, fmt.Errorf("distro driver %q does not support reading the build times of the packages", d.Info().Name)
+	}
+	var newest *filespec.FileSpec
+	var res time.Time
+	for i := range pkgs {
+		t, err := timer.PackageTime(c, pkgs[i])
+		if err != nil {
+			return nil, err
+		}
+		if t.After(res) {
+			res, newest = t, &pkgs[i]
+		}
+	}
+	if newest == nil {
+		return nil, nil
+	}
+	logrus.Debugf("The newest package is %q (%s)", newest.Basename, res.Format(time.RFC3339))
+	return &res, nil
+}
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
 	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -17,18 +20,53 @@ import (
 
 func newHashUpdateCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "update SHA256SUMS",
-		Short:   "Update the hash file",
-		Example: "  repro-get hash update SHA256SUMS-" + archutil.OCIArchDashVariant(),
-		Args:    cobra.ExactArgs(1),
-		RunE:    hashUpdateAction,
+		Use:   "update SHA256SUMS",
+		Short: "Update the hash file",
+		Long: `Update the hash file.
+The packages in the hash file are resolved again with the current repository metadata (e.g., the apt lists),
+and the hash file is rewritten with the new versions and digests.
+
+The changes are printed in the same format as 'repro-get hash diff'.
+
+The signatures alongside the hash file ("<SHA256SUMS>` + hashsig.SignatureSuffix + `", etc.) do not match the rewritten hash file.
+Specify --unsign to remove them, and sign the hash file again with 'repro-get hash sign'.`,
+		Example: "  repro-get hash update SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get hash update --dry-run SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get hash update --unsign SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.ExactArgs(1),
+		RunE: hashUpdateAction,
 
 		DisableFlagsInUseLine: true,
 	}
-
+	flags := cmd.Flags()
+	flags.String("root", "/", "Root filesystem to inspect")
+	flags.StringSlice("keyring", nil, "OpenPGP keyrings (or RSA public keys \"*.rsa.pub\" for Alpine) for verifying the repository metadata (or the packages for Fedora), in addition to the keys in --keys-dir (default: the keyrings of the distro; none for Alpine and Fedora)")
+	flags.Bool("allow-unsigned", false, "Allow unsigned (or unverifiable) repository metadata")
+	flags.String("target-release", "", "Target release for the pinning, such as \"bullseye-backports\" (default: APT::Default-Release) (Debian and Ubuntu only)")
+	flags.StringSlice("preferences", nil, "Pinning preferences files (default: /etc/apt/preferences and /etc/apt/preferences.d/* in the root filesystem) (Debian and Ubuntu only)")
+	flags.String("snapshot", "", "Record the snapshot timestamp (e.g., \"20221101T000000Z\") in the hash file, instead of the existing one")
+	flags.Bool("dry-run", false, "Print the changes without rewriting the hash file")
+	flags.Bool("unsign", false, "Remove the signatures alongside the hash file, as they do not match the rewritten hash file")
 	return cmd
 }
 
+// checkStaleSignatures refuses to rewrite the signed hash file, unless unsign is specified.
+func checkStaleSignatures(hashFile string, unsign bool) error {
+	if unsign {
+		return nil
+	}
+	sigs, err := hashsig.Signatures(hashFile)
+	if err != nil {
+		return err
+	}
+	if len(sigs) > 0 {
+		return fmt.Errorf("%q is signed (%s), and the signatures do not match the rewritten hash file "+
+			"(Hint: specify --unsign to remove the signatures, and sign the hash file again with 'repro-get hash sign')",
+			hashFile, strings.Join(sigs, ", "))
+	}
+	return nil
+}
+
 func hashUpdateAction(cmd *cobra.Command, args []string) error {
 	d, err := getDistro(cmd)
 	if err != nil {
@@ -37,21 +75,46 @@ func hashUpdateAction(cmd *cobra.Command, args []string) error {
 
 	ctx := cmd.Context()
 	hashFile := args[0]
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	unsign, err := cmd.Flags().GetBool("unsign")
+	if err != nil {
+		return err
+	}
+	if !dryRun {
+		if err = checkStaleSignatures(hashFile, unsign); err != nil {
+			return err
+		}
+	}
 	old, err := os.ReadFile(hashFile)
 	if err != nil {
 		return fmt.Errorf("failed to open %q: %w", hashFile, err)
 	}
-	sums, err := sha256sums.Parse(bytes.NewReader(old))
+	if sections, err := filespec.SplitArchSections(old); err != nil {
+		return fmt.Errorf("failed to parse the architecture sections of %q: %w", hashFile, err)
+	} else if len(sections) > 1 {
+		return errors.New("updating the hash file with the architecture sections is not supported (Hint: update the hash file for each architecture, and merge them with 'repro-get hash merge --arch-sections')")
+	}
+	sums, err := sha256sums.ParseAll(bytes.NewReader(old))
 	if err != nil {
 		return fmt.Errorf("failed to parse %q as SHA256SUMS: %w", hashFile, err)
 	}
-	fileSpecs, err := filespec.NewFromSHA256SUMS(sums)
+	fileSpecs, err := filespec.NewFromSums(sums)
 	if err != nil {
 		return err
 	}
 
-	var pkgs []string
+	var (
+		pkgs []string
+		// Keep generating SHA512 for the hash file that only has SHA512 (see `hash generate --sha512`)
+		sha512 = len(fileSpecs) > 0
+	)
 	for _, f := range fileSpecs {
+		if f.SHA256 != "" || f.SHA512 == "" {
+			sha512 = false
+		}
 		pkg, err := d.PackageName(*f)
 		if err != nil {
 			logrus.WithError(err).Warnf("Failed to resolve the package name of %q", f.Name)
@@ -62,13 +125,81 @@ func hashUpdateAction(cmd *cobra.Command, args []string) error {
 
 	opts := distro.HashOpts{
 		FilterByName: pkgs,
+		SHA512:       sha512,
+	}
+	opts.Root, err = cmd.Flags().GetString("root")
+	if err != nil {
+		return err
+	}
+	opts.Keyrings, err = cmd.Flags().GetStringSlice("keyring")
+	if err != nil {
+		return err
+	}
+	opts.ExtraKeyrings, err = extraKeyrings(cmd)
+	if err != nil {
+		return err
+	}
+	opts.AllowUnsigned, err = cmd.Flags().GetBool("allow-unsigned")
+	if err != nil {
+		return err
+	}
+	opts.TargetRelease, err = cmd.Flags().GetString("target-release")
+	if err != nil {
+		return err
+	}
+	opts.Preferences, err = cmd.Flags().GetStringSlice("preferences")
+	if err != nil {
+		return err
 	}
 	var b bytes.Buffer
-	hw := distro.NewHashWriter(&b)
+	// Preserve the directives such as "#repro-get:snapshot=..."
+	directives, err := sha256sums.ParseDirectives(bytes.NewReader(old))
+	if err != nil {
+		return err
+	}
+	snapshot, err := cmd.Flags().GetString("snapshot")
+	if err != nil {
+		return err
+	}
+	if snapshot != "" {
+		if err = filespec.ValidateSnapshot(snapshot); err != nil {
+			return err
+		}
+		directives[filespec.DirectiveSnapshot] = snapshot
+	}
+	opts.PPA = directives[filespec.DirectivePPA]
+	if _, ok := directives[filespec.DirectiveIndex]; ok {
+		// Record the hashes of the current repository indexes, instead of the old ones
+		delete(directives, filespec.DirectiveIndex)
+		opts.IndexWriter = newIndexWriter(&b)
+	}
+	// Keep recording the origin URLs (see `hash generate --with-urls`)
+	_, withURLs := directives[filespec.DirectiveURL]
+	delete(directives, filespec.DirectiveURL)
+	// The signers are recorded again when the signatures are verified
+	delete(directives, filespec.DirectiveSignedBy)
+	directiveKeys := make([]string, 0, len(directives))
+	for k := range directives {
+		directiveKeys = append(directiveKeys, k)
+	}
+	sort.Strings(directiveKeys)
+	for _, k := range directiveKeys {
+		fmt.Fprintln(&b, sha256sums.FormatDirective(k, directives[k]))
+	}
+	var generated int
+	hw0 := distro.NewHashWriter(&b)
+	if withURLs {
+		hw0 = newURLRecorder(&b, hw0, &opts, d.Info().DefaultProviders, directives[filespec.DirectiveSnapshot])
+	}
+	hw0 = newSignerRecorder(&b, hw0, &opts)
+	hw := func(sha256sum, filename string) error {
+		generated++
+		return hw0(sha256sum, filename)
+	}
 	if err := d.GenerateHash(ctx, hw, opts); err != nil {
 		return err
 	}
-	if b.Len() == 0 {
+	if generated == 0 {
 		return errors.New("no hash was generated")
 	}
 	neu := b.Bytes()
@@ -76,6 +207,31 @@ func hashUpdateAction(cmd *cobra.Command, args []string) error {
 		logrus.Info("No update")
 		return nil
 	}
-	fmt.Fprintln(cmd.OutOrStdout(), cmp.Diff(string(old), string(neu)))
-	return os.WriteFile(hashFile, neu, 0644)
+	oldLF, err := lockfile.FromSHA256SUMS(old)
+	if err != nil {
+		return err
+	}
+	newLF, err := lockfile.FromSHA256SUMS(neu)
+	if err != nil {
+		return err
+	}
+	if err = printDiff(cmd.OutOrStdout(), lockfile.Diff(oldLF, newLF)); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	if err = os.WriteFile(hashFile, neu, 0644); err != nil {
+		return err
+	}
+	if unsign {
+		removed, err := hashsig.RemoveSignatures(hashFile)
+		if err != nil {
+			return err
+		}
+		for _, f := range removed {
+			logrus.Warnf("Removed the stale signature %q (Hint: sign %q again with 'repro-get hash sign')", f, hashFile)
+		}
+	}
+	return nil
 }
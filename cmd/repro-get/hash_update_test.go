@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheckStaleSignatures(t *testing.T) {
+	hashFile := filepath.Join(t.TempDir(), "SHA256SUMS-amd64")
+	assert.NilError(t, os.WriteFile(hashFile, []byte("35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb\n"), 0644))
+	assert.NilError(t, checkStaleSignatures(hashFile, false))
+
+	assert.NilError(t, os.WriteFile(hashFile+hashsig.MinisignSignatureSuffix, []byte("dummy"), 0644))
+	assert.ErrorContains(t, checkStaleSignatures(hashFile, false), "--unsign")
+	assert.NilError(t, checkStaleSignatures(hashFile, true))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/reproducible-containers/repro-get/pkg/imageconfig"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newImageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "image",
+		Short:         "Manage OCI images (EXPERIMENTAL)",
+		Args:          cobra.NoArgs,
+		RunE:          needsSubcommand,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(
+		newImageLockCommand(),
+		newImageBuildCommand(),
+		newImageDockerfileCommand(),
+	)
+	return cmd
+}
+
+// loadImageConfig loads the image config specified with --config, and returns the directory of the image config too.
+// The distro of the image config is used unless --distro is explicitly specified.
+// Returns nil when --config is empty.
+func loadImageConfig(cmd *cobra.Command) (*imageconfig.Config, string, error) {
+	flags := cmd.Flags()
+	f, err := flags.GetString("config")
+	if err != nil || f == "" {
+		return nil, "", err
+	}
+	cfg, err := imageconfig.Load(f)
+	if err != nil {
+		return nil, "", err
+	}
+	if flags.Changed("distro") {
+		if distroName, _ := flags.GetString("distro"); distroName != cfg.Distro {
+			logrus.Warnf("Ignoring Distro %q of %q, as --distro=%s is specified", cfg.Distro, f, distroName)
+		}
+	} else if err = flags.Set("distro", cfg.Distro); err != nil {
+		return nil, "", err
+	}
+	return cfg, filepath.Dir(f), nil
+}
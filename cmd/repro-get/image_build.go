@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/imageconfig"
+	"github.com/reproducible-containers/repro-get/pkg/ociimage"
+	"github.com/reproducible-containers/repro-get/pkg/rootfs"
+	"github.com/spf13/cobra"
+)
+
+func newImageBuildCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "build [flags]",
+		Short: "Build an OCI image from the packages in the hash files, without container engines",
+		Long: `Build an OCI image from the packages in the hash files, without container engines.
+The root filesystem is created in the same way as 'repro-get bootstrap', and written as the single layer of the image.
+The image is written in the OCI image layout (https://github.com/opencontainers/image-spec/blob/main/image-layout.md).
+
+The files in the layer are sorted by the names, and the access times, the change times, and the user and group names are not recorded.
+The creation time of the image is set to $SOURCE_DATE_EPOCH, or to the build time of the newest package,
+and the modification times of the files newer than it are clamped to it, so that the image is reproducible.
+
+The owners of the files are retained only when executed as the root user; otherwise the files are owned by 0:0,
+except the home directories of the users of the image config.
+
+With --config, the image is built from the image config (image.yaml) and the hash files resolved with 'repro-get image lock'.
+
+Debian, Ubuntu, and Alpine only.`,
+		Example: "  repro-get image build --hash=SHA256SUMS-" + archutil.OCIArchDashVariant() + " --output=oci:out/\n" +
+			"  repro-get image build --hash=SHA256SUMS --arch=arm64 --tag=latest --cmd=/bin/bash --output=oci:out/\n" +
+			"  repro-get image build --config=image.yaml --output=oci:out/",
+		Args: cobra.NoArgs,
+		RunE: imageBuildAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.StringSlice("hash", nil, "Hash files (required, unless --config is specified)")
+	flags.String("config", "", "Image config, such as \"image.yaml\"; the hash files default to \"SHA256SUMS-<ARCH>\" in the directory of the image config")
+	flags.String("output", "", "Output, such as \"oci:DIR\"; the directory must not exist, or must be empty (required)")
+	flags.String("tag", "", "Tag of the image in the OCI image layout (the \"org.opencontainers.image.ref.name\" annotation)")
+	flags.StringSlice("cmd", nil, "Default command of the image, such as \"/bin/sh\"; overrides Cmd of the image config")
+	addBootstrapFlags(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("hash", completeHashFiles)
+	return cmd
+}
+
+func imageBuildAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	cfg, cfgDir, err := loadImageConfig(cmd)
+	if err != nil {
+		return err
+	}
+	arch, err := flags.GetString("arch")
+	if err != nil {
+		return err
+	}
+	hashFiles, err := flags.GetStringSlice("hash")
+	if err != nil {
+		return err
+	}
+	if len(hashFiles) == 0 {
+		if cfg == nil {
+			return errors.New("--hash needs to be specified")
+		}
+		hashFiles = []string{filepath.Join(cfgDir, imageconfig.HashFile(arch))}
+	}
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+	outputDir, err := parseImageOutput(output)
+	if err != nil {
+		return err
+	}
+	var opts ociimage.Opts
+	opts.KeepOwner = os.Geteuid() == 0
+	if opts.Platform, err = ociimage.ParsePlatform(arch); err != nil {
+		return err
+	}
+	if opts.RefName, err = flags.GetString("tag"); err != nil {
+		return err
+	}
+	if cfg != nil {
+		opts.Config = cfg.ImageConfig()
+		opts.Annotations = cfg.Annotations
+		opts.Owners = make(map[string]ociimage.Owner)
+		for name, u := range cfg.HomeDirs() {
+			opts.Owners[name] = ociimage.Owner{UID: u.UID, GID: u.GID}
+		}
+	}
+	if flags.Changed("cmd") {
+		if opts.Config.Cmd, err = flags.GetStringSlice("cmd"); err != nil {
+			return err
+		}
+	}
+	root, err := os.MkdirTemp("", "repro-get-image-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+	if opts.Created, err = runBootstrap(cmd, root, hashFiles); err != nil {
+		return err
+	}
+	opts.ClampTime = opts.Created
+	if cfg != nil && len(cfg.Users) > 0 {
+		since, err := rootfs.Now(root)
+		if err != nil {
+			return err
+		}
+		if err := cfg.CreateUsers(root); err != nil {
+			return err
+		}
+		if err := rootfs.Normalize(root, since, opts.Created); err != nil {
+			return err
+		}
+	}
+	desc, err := ociimage.Build(outputDir, root, opts)
+	if err != nil {
+		return err
+	}
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	if jsonFlag {
+		return writeJSON(cmd.OutOrStdout(), desc)
+	}
+	return nil
+}
+
+// parseImageOutput parses the --output value such as "oci:DIR", and returns the directory.
+func parseImageOutput(s string) (string, error) {
+	if s == "" {
+		return "", errors.New("--output needs to be specified")
+	}
+	typ, dir, ok := strings.Cut(s, ":")
+	if !ok || typ != "oci" || dir == "" {
+		return "", fmt.Errorf("invalid --output %q, expected \"oci:DIR\"", s)
+	}
+	return dir, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/dockerfileutil"
+	"github.com/reproducible-containers/repro-get/pkg/imageconfig"
+	"github.com/spf13/cobra"
+)
+
+func newImageDockerfileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dockerfile [flags] DIR",
+		Short: "Generate Dockerfiles from the image config",
+		Long: `Generate Dockerfiles from the image config (image.yaml), in the same way as 'repro-get dockerfile generate'.
+The users and the metadata of the image config are appended to "Dockerfile".
+The annotations are converted to the labels.
+
+"Dockerfile.generate-hash" is generated too, unless the hash files already exist in DIR.
+With multiple Archs, "docker-bake.hcl" is generated too, for building the multi-platform image with 'docker buildx bake'.
+The image config needs BaseImage.`,
+		Example: "  repro-get image dockerfile --config=image.yaml .",
+		Args:    cobra.ExactArgs(1),
+		RunE:    imageDockerfileAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("config", imageconfig.DefaultFilename, "Image config")
+	addDockerfileGenerateFlags(cmd)
+	flags.StringSlice("platform", nil, "Target platforms of the bake file (\""+dockerfileutil.BakeFilename+"\"), such as \"linux/amd64,linux/arm64\" (default: Archs of the image config, when multiple architectures are specified)")
+	return cmd
+}
+
+func imageDockerfileAction(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadImageConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return errors.New("--config needs to be specified")
+	}
+	generateHash, err := generateImageDockerfile(cmd, cfg, args[0])
+	if err != nil {
+		return err
+	}
+	printNextStepsForDockerfiles(cmd, generateHash)
+	return nil
+}
+
+// generateImageDockerfile generates the Dockerfiles in dir from the image config.
+// Returns true if "Dockerfile.generate-hash" was generated too.
+func generateImageDockerfile(cmd *cobra.Command, cfg *imageconfig.Config, dir string) (bool, error) {
+	if cfg.BaseImage == "" {
+		return false, errors.New("BaseImage needs to be specified in the image config")
+	}
+	d, err := getDistro(cmd)
+	if err != nil {
+		return false, err
+	}
+	instructions, err := cfg.DockerfileInstructions()
+	if err != nil {
+		return false, err
+	}
+	flags := cmd.Flags()
+	if !flags.Changed("platform") && len(cfg.Archs) > 1 {
+		var platforms []string
+		for _, arch := range cfg.Archs {
+			platforms = append(platforms, dockerfileutil.ArchDashVariantPlatform(arch))
+		}
+		if err = flags.Set("platform", strings.Join(platforms, ",")); err != nil {
+			return false, err
+		}
+	}
+	platforms, err := flags.GetStringSlice("platform")
+	if err != nil {
+		return false, err
+	}
+	archs, err := platformArchs(platforms)
+	if err != nil {
+		return false, err
+	}
+	var opts distro.DockerfileOpts
+	for _, arch := range archs {
+		if _, err := os.Stat(filepath.Join(dir, imageconfig.HashFile(arch))); errors.Is(err, os.ErrNotExist) {
+			opts.GenerateHash = true
+		}
+	}
+	if err = generateDockerfile(cmd, d, dir, cfg.BaseImage, cfg.Packages, opts); err != nil {
+		return false, err
+	}
+	if instructions != "" {
+		f, err := os.OpenFile(filepath.Join(dir, "Dockerfile"), os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return false, err
+		}
+		_, err = f.WriteString("\n# Generated from the image config\n" + instructions)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return opts.GenerateHash, nil
+}
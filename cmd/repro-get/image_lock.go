@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/imageconfig"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newImageLockCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock [flags]",
+		Short: "Resolve the packages of the image config into the hash files",
+		Long: `Resolve the packages of the image config (image.yaml) into the hash files.
+The hash files ("SHA256SUMS-<ARCH>") are written in the directory of the image config, for each of the architectures (Archs).
+
+The packages are resolved with their dependencies from the repository metadata, in the same way as 'repro-get hash generate --resolve'.
+Run 'apt-get update' beforehand on Debian and Ubuntu.
+
+Example image.yaml:
+  Distro: debian
+  BaseImage: debian:bullseye-20211220
+  Packages: [base-files, base-passwd, bash, coreutils, dpkg, hello, libc-bin]
+  Archs: [amd64, arm64]
+  Entrypoint: [/usr/bin/hello]
+  Users:
+    - Name: app
+      UID: 1000
+  User: app
+  Annotations:
+    org.opencontainers.image.source: https://github.com/USERNAME/REPO
+
+Debian, Ubuntu, and Alpine only.`,
+		Example: "  repro-get image lock --config=image.yaml",
+		Args:    cobra.NoArgs,
+		RunE:    imageLockAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("config", imageconfig.DefaultFilename, "Image config")
+	return cmd
+}
+
+func imageLockAction(cmd *cobra.Command, args []string) error {
+	cfg, dir, err := loadImageConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return errors.New("--config needs to be specified")
+	}
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	opts := distro.HashOpts{
+		FilterByName:  cfg.Packages,
+		Resolve:       true,
+		Indexes:       cfg.Indexes,
+		TargetRelease: cfg.TargetRelease,
+	}
+	if opts.ExtraKeyrings, err = extraKeyrings(cmd); err != nil {
+		return err
+	}
+	if d.Info().CacheIsNeededForGeneratingHash {
+		if opts.Cache, err = newCache(cmd); err != nil {
+			return err
+		}
+	}
+	if cfg.Snapshot != "" {
+		if err = filespec.ValidateSnapshot(cfg.Snapshot); err != nil {
+			return err
+		}
+	}
+	for _, arch := range cfg.Archs {
+		var b bytes.Buffer
+		if cfg.Snapshot != "" {
+			fmt.Fprintln(&b, sha256sums.FormatDirective(filespec.DirectiveSnapshot, cfg.Snapshot))
+		}
+		opts.Arch = arch
+		if err = d.GenerateHash(cmd.Context(), distro.NewHashWriter(&b), opts); err != nil {
+			return fmt.Errorf("failed to resolve the packages for %q: %w", arch, err)
+		}
+		f := filepath.Join(dir, imageconfig.HashFile(arch))
+		if err = os.WriteFile(f, b.Bytes(), 0644); err != nil {
+			return err
+		}
+		logrus.Infof("Wrote %q", f)
+	}
+	return nil
+}
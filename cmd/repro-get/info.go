@@ -72,6 +72,9 @@ func GetInfo(cmd *cobra.Command) (*Info, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cache == "" {
+		cache = defaultCacheSpec().dir
+	}
 	x := &Info{
 		Version: version.GetVersion(),
 		Cache:   cache,
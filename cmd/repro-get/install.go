@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
@@ -18,8 +22,12 @@ func newInstallCommand() *cobra.Command {
 		Args:    cobra.MinimumNArgs(1),
 		RunE:    installAction,
 
+		ValidArgsFunction:     completeHashFiles,
 		DisableFlagsInUseLine: true,
 	}
+	flags := cmd.Flags()
+	flags.String("root", "/", "Root filesystem to install the packages into (Alpine only)")
+	flags.Bool("dry-run", false, "Print the files to be downloaded and the command to be executed, without accessing the network and without installing the packages")
 	return cmd
 }
 
@@ -31,38 +39,133 @@ func installAction(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	flags := cmd.Flags()
 
+	root, err := flags.GetString("root")
+	if err != nil {
+		return err
+	}
 	downloadOpts := downloader.Opts{
-		SkipInstalled: true,
+		// The installed packages are detected only for the host root filesystem
+		SkipInstalled: root == "" || root == "/",
 	}
 
-	downloadOpts.Providers, err = flags.GetStringSlice("provider")
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
 
-	cacheStr, err := flags.GetString("cache")
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, archutil.OCIArchDashVariant())
 	if err != nil {
 		return err
 	}
-	cache, err := cache.New(cacheStr)
+
+	dryRun, err := flags.GetBool("dry-run")
 	if err != nil {
 		return err
 	}
-
-	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	jsonFlag, err := jsonOutput(cmd)
 	if err != nil {
 		return err
 	}
-
-	downloadRes, err := downloader.Download(ctx, d, cache, fileSpecs, downloadOpts)
+	w := cmd.OutOrStdout()
+	x := InstallResult{Installed: []string{}}
+	var downloadRes *downloader.Result
+	if dryRun {
+		x.Plan, downloadRes, err = runPlanner(cmd, d, cache, fileSpecs, downloadOpts)
+		if err == nil && !jsonFlag {
+			err = downloader.WritePlan(w, x.Plan)
+		}
+	} else {
+		downloadRes, err = runDownloader(cmd, d, cache, fileSpecs, downloadOpts)
+	}
 	if err != nil {
 		return err
 	}
+	for _, sp := range downloadRes.PackagesToBeInstalled {
+		x.Installed = append(x.Installed, sp.Name)
+	}
 	if len(downloadRes.PackagesToBeInstalled) == 0 {
 		logrus.Info("No package to install")
+		if jsonFlag {
+			return writeJSON(w, x)
+		}
 		return nil
 	}
 
-	var installOpts distro.InstallOpts
-	return d.InstallPackages(ctx, cache, downloadRes.PackagesToBeInstalled, installOpts)
+	installOpts := distro.InstallOpts{
+		Root: root,
+	}
+	if dryRun {
+		if x.Command, err = installCommand(d, cache, downloadRes.PackagesToBeInstalled, installOpts); err != nil {
+			return err
+		}
+		if jsonFlag {
+			return writeJSON(w, x)
+		}
+		return writeInstallCommand(w, x.Command)
+	}
+	if _, err = verifyPackageSignatures(cmd, d, cache, downloadRes.PackagesToBeInstalled); err != nil {
+		return err
+	}
+	if err = d.InstallPackages(ctx, cache, downloadRes.PackagesToBeInstalled, installOpts); err != nil {
+		return err
+	}
+	eventHandler, _, err := newEventHandler(cmd)
+	if err != nil {
+		return err
+	}
+	if eventHandler != nil {
+		for i := range downloadRes.PackagesToBeInstalled {
+			eventHandler(downloader.NewEvent(downloader.EventInstalled, &downloadRes.PackagesToBeInstalled[i]))
+		}
+	}
+	if jsonFlag {
+		return writeJSON(w, x)
+	}
+	return nil
+}
+
+// InstallResult is printed by `repro-get install --format=json`.
+type InstallResult struct {
+	Installed []string               `json:"Installed"`         // The file names of the installed packages, or the packages to be installed with --dry-run
+	Plan      []downloader.PlanEntry `json:"Plan,omitempty"`    // Only with --dry-run
+	Command   []string               `json:"Command,omitempty"` // Only with --dry-run, when known
+}
+
+// installCommand returns the command that d.InstallPackages would execute.
+// Returns nil when the command is unknown, or when no command would be executed.
+func installCommand(d distro.Distro, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) ([]string, error) {
+	commander, ok := d.(distro.InstallCommander)
+	if !ok {
+		logrus.Infof("Dry run: %d packages would be installed (distro driver %q does not support printing the command)", len(pkgs), d.Info().Name)
+		return nil, nil
+	}
+	args, err := commander.InstallCommand(c, pkgs, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		logrus.Infof("Dry run: %d packages would be installed without executing any command", len(pkgs))
+	}
+	return args, nil
+}
+
+// writeInstallCommand writes the command as a "run COMMAND..." line, unless args is empty.
+func writeInstallCommand(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(args))
+	for i, f := range args {
+		quoted[i] = shellQuote(f)
+	}
+	_, err := fmt.Fprintf(w, "run %s\n", strings.Join(quoted, " "))
+	return err
+}
+
+// shellQuote quotes s for the POSIX shell, only when s contains special characters.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./+=:%@,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
@@ -7,7 +7,6 @@ import (
 	"os/exec"
 	"strings"
 
-	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -20,12 +19,17 @@ func newIPFSPushCommand() *cobra.Command {
 		Long: `Push the files into IPFS, and append the CIDs to the hash file.
 Needs 'ipfs' command (https://github.com/ipfs/kubo) to be installed.
 
+With --mapping-file, the CIDs are appended to the mapping file "<SHA256SUMS>.ipfs"
+instead of the hash file, so that the hash file itself is kept intact.
+The mapping file is loaded automatically when the hash file is loaded.
+
 There is no 'repro-get ipfs pull' command.
-To pull the pushed packages, set the provider to a {{.CID}} template string
-with an IPFS gateway, such as:
+To pull the pushed packages, set the provider to "ipfs://" (with --ipfs-gateway),
+or to a {{.CID}} template string with an IPFS gateway, such as:
+$ repro-get --provider=ipfs:// install
 $ repro-get --provider=http://ipfs.io/ipfs/{{.CID}} install
 `,
-		Example: "  repro-get ipfs push SHA256SUMS",
+		Example: "  repro-get ipfs push SHA256SUMS\n  repro-get ipfs push --mapping-file SHA256SUMS",
 		Args:    cobra.ExactArgs(1),
 		RunE:    ipfsPushAction,
 
@@ -34,6 +38,7 @@ $ repro-get --provider=http://ipfs.io/ipfs/{{.CID}} install
 
 	flags := cmd.Flags()
 	flags.Bool("append", true, "Append the CIDs to the hash file")
+	flags.Bool("mapping-file", false, "Append the CIDs to the mapping file \"<SHA256SUMS>"+filespec.CIDsFileSuffix+"\" instead of the hash file")
 
 	return cmd
 }
@@ -45,11 +50,7 @@ func ipfsPushAction(cmd *cobra.Command, args []string) error {
 
 	flags := cmd.Flags()
 	hashFile := args[0]
-	cacheStr, err := flags.GetString("cache")
-	if err != nil {
-		return err
-	}
-	cache, err := cache.New(cacheStr)
+	cache, err := newCache(cmd)
 	if err != nil {
 		return err
 	}
@@ -67,11 +68,19 @@ func ipfsPushAction(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	mappingFileFlag, err := flags.GetBool("mapping-file")
+	if err != nil {
+		return err
+	}
 	var appender io.WriteCloser
 	if appendFlag {
-		appender, err = os.OpenFile(hashFile, os.O_WRONLY|os.O_APPEND, 0o644)
+		appendFile, appendFlags := hashFile, os.O_WRONLY|os.O_APPEND
+		if mappingFileFlag {
+			appendFile, appendFlags = hashFile+filespec.CIDsFileSuffix, appendFlags|os.O_CREATE
+		}
+		appender, err = os.OpenFile(appendFile, appendFlags, 0o644)
 		if err != nil {
-			return fmt.Errorf("failed to open %q with O_WRONLY|O_APPEND: %w", hashFile, err)
+			return fmt.Errorf("failed to open %q for appending: %w", appendFile, err)
 		}
 		defer appender.Close()
 	}
@@ -81,7 +90,7 @@ func ipfsPushAction(cmd *cobra.Command, args []string) error {
 			logrus.Infof("Skipping to push %q (Already has CID %q)", fname, fileSpec.CID)
 			continue
 		}
-		blobPath, err := cache.BlobAbsPath(fileSpec.SHA256)
+		blobPath, err := cache.BlobAbsPath(fileSpec.Sum())
 		if err != nil {
 			return err
 		}
@@ -96,7 +105,7 @@ func ipfsPushAction(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to execute %v: %w", ipfsCmd.Args, err)
 		}
 		cid := strings.TrimSpace(string(cidB))
-		newLine := fmt.Sprintf("%s  /ipfs/%s", fileSpec.SHA256, cid)
+		newLine := fmt.Sprintf("%s  /ipfs/%s", fileSpec.Sum(), cid)
 		if _, err = fmt.Fprintln(stdout, newLine); err != nil {
 			return err
 		}
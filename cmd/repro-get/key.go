@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/keyring"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newKeyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Manage the trusted keys",
+		Long: `Manage the trusted keys for verifying the repository metadata (InRelease, Release.gpg, APKINDEX.tar.gz),
+and the signatures of the hash files (SHA256SUMS.asc, SHA256SUMS.minisig, SHA256SUMS.sig).
+
+The keys are stored in the directory specified with --keys-dir, and are used by
+'repro-get hash generate', 'repro-get hash update', and 'repro-get image lock' for verifying the repository metadata,
+in addition to the keyrings of the distro,
+and by 'repro-get download', 'repro-get install', and so on for verifying the hash files, in addition to --signature-keyring and --signature-minisign-key.`,
+		Args:          cobra.NoArgs,
+		RunE:          needsSubcommand,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(
+		newKeyAddCommand(),
+		newKeyListCommand(),
+		newKeyRemoveCommand(),
+	)
+	return cmd
+}
+
+// defaultKeysDir is the system-wide keys dir.
+const defaultKeysDir = "/etc/repro-get/keys"
+
+// keysDir returns the keys dir specified with --keys-dir.
+// Defaults to defaultKeysDir when it exists, or can be created. Otherwise returns the per-user dir,
+// such as "~/.config/repro-get/keys".
+func keysDir(cmd *cobra.Command) (*keyring.Dir, error) {
+	dir, err := cmd.Flags().GetString("keys-dir")
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		return keyring.New(dir), nil
+	}
+	if st, err := os.Stat(defaultKeysDir); (err == nil && st.IsDir()) || isWritableDir(defaultKeysDir) {
+		return keyring.New(defaultKeysDir), nil
+	}
+	configDir, err := os.UserConfigDir() // $XDG_CONFIG_HOME or ~/.config
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to get the user config dir, falling back to %q", defaultKeysDir)
+		return keyring.New(defaultKeysDir), nil
+	}
+	dir = filepath.Join(configDir, "repro-get", "keys")
+	logrus.Debugf("%q does not exist and is not writable, using %q", defaultKeysDir, dir)
+	return keyring.New(dir), nil
+}
+
+// trustedKeyFiles returns the files of the keys of the type in the keys dir.
+func trustedKeyFiles(cmd *cobra.Command, t keyring.KeyType) ([]string, error) {
+	d, err := keysDir(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return d.Files(t)
+}
+
+// extraKeyrings returns the files of all the keys in the keys dir and in the TUF repository, for distro.HashOpts.ExtraKeyrings.
+func extraKeyrings(cmd *cobra.Command) ([]string, error) {
+	var res []string
+	for _, t := range []keyring.KeyType{keyring.OpenPGP, keyring.RSA} {
+		files, err := trustedKeyFiles(cmd, t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, files...)
+	}
+	tufCfg, err := getTUFDistroConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if tufCfg != nil {
+		for _, f := range tufCfg.keyFiles {
+			if !strings.HasSuffix(f, keyring.MinisignSuffix) {
+				res = append(res, f)
+			}
+		}
+	}
+	return res, nil
+}
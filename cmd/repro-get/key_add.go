@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/distro/alpine"
+	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newKeyAddCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add [flags] FILE...",
+		Short: "Add trusted keys",
+		Long: `Add trusted keys to the keys dir.
+
+The file is an OpenPGP keyring (binary or ASCII-armored), an RSA public key for Alpine ("*.rsa.pub"),
+a minisign (or signify) public key for the hash files, or a keyring package ("*.deb" or "*.apk"), such as "debian-archive-keyring", "ubuntu-keyring", and "alpine-keys".
+The keys in the keyring packages are imported from /usr/share/keyrings, /etc/apt/trusted.gpg.d, and /usr/share/apk/keys.`,
+		Example: `  repro-get key add /usr/share/keyrings/debian-archive-keyring.gpg
+  repro-get key add debian-archive-keyring_2023.3+deb12u1_all.deb
+  repro-get key add alpine-keys-2.4-r1.apk`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: keyAddAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	return cmd
+}
+
+// keyExtractors are the drivers for extracting the keys from the keyring packages, keyed by the file extensions.
+var keyExtractors = map[string]string{
+	".deb": debian.Name,
+	".apk": alpine.Name,
+}
+
+func keyAddAction(cmd *cobra.Command, args []string) error {
+	d, err := keysDir(cmd)
+	if err != nil {
+		return err
+	}
+	for _, f := range args {
+		files, err := readKeyFiles(cmd, f)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			keys, err := d.Add(name, files[name])
+			if err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if len(k.UserIDs) > 0 {
+					logrus.Infof("Added %s key %s (%s)", k.Type, k.ID, strings.Join(k.UserIDs, ", "))
+				} else {
+					logrus.Infof("Added %s key %s", k.Type, k.ID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readKeyFiles reads the key file, or the key files in the keyring package.
+func readKeyFiles(cmd *cobra.Command, f string) (map[string][]byte, error) {
+	distroName, ok := keyExtractors[filepath.Ext(f)]
+	if !ok {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{f: b}, nil
+	}
+	ke, ok := knownDistros[distroName].(distro.KeyExtractor)
+	if !ok {
+		return nil, fmt.Errorf("distro driver %q does not support extracting keys", distroName)
+	}
+	files, err := ke.ExtractKeys(cmd.Context(), f)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no key was found in " + f)
+	}
+	return files, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reproducible-containers/repro-get/pkg/keyring"
+	"github.com/spf13/cobra"
+)
+
+func newKeyListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [flags]",
+		Short: "List the trusted keys",
+		Args:  cobra.NoArgs,
+		RunE:  keyListAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "Enable JSON output")
+	return cmd
+}
+
+func keyListAction(cmd *cobra.Command, args []string) error {
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	d, err := keysDir(cmd)
+	if err != nil {
+		return err
+	}
+	keys, err := d.List()
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		if keys == nil {
+			keys = []keyring.Key{}
+		}
+		return writeJSON(w, keys)
+	}
+	tw := tabwriter.NewWriter(w, 4, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tID\tUSER IDS\tFILE")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k.Type, k.ID, strings.Join(k.UserIDs, ", "), k.File)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newKeyRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove [flags] ID...",
+		Short: "Remove trusted keys",
+		Long: `Remove trusted keys from the keys dir.
+The ID is the fingerprint, the key ID (the last 16 or 8 hex digits of the fingerprint), or the file name of the key, as shown in 'repro-get key list'.`,
+		Example: "  repro-get key remove 4CB50190207B4758A3F73A796ED0E7B82643E131",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    keyRemoveAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	return cmd
+}
+
+func keyRemoveAction(cmd *cobra.Command, args []string) error {
+	d, err := keysDir(cmd)
+	if err != nil {
+		return err
+	}
+	for _, id := range args {
+		k, err := d.Remove(id)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Removed %s key %s (%s)", k.Type, k.ID, k.File)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/pkglist"
+	"github.com/spf13/cobra"
+)
+
+func newListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [flags] SHA256SUMS...",
+		Short: "List the packages in the hash files",
+		Long: `List the packages in the hash files, with the names, the versions, the architectures, the sizes, and the digests.
+The sizes are shown only for the cached files, as the hash files do not contain the sizes.`,
+		Example: "  repro-get list SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get list --format=csv SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(1),
+		RunE: listAction,
+
+		ValidArgsFunction:     completeHashFiles,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\", for the hash files with the architecture sections (\"#repro-get:arch=...\")")
+	flags.String("format", string(pkglist.FormatTable), fmt.Sprintf("Output format %v", pkglist.Formats))
+	return cmd
+}
+
+func listAction(cmd *cobra.Command, args []string) error {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	flags := cmd.Flags()
+	arch, err := flags.GetString("arch")
+	if err != nil {
+		return err
+	}
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, arch)
+	if err != nil {
+		return err
+	}
+	entries, err := pkglist.New(d, c, fileSpecs)
+	if err != nil {
+		return err
+	}
+	return pkglist.Write(cmd.OutOrStdout(), entries, pkglist.Format(format))
+}
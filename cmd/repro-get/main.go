@@ -1,18 +1,27 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/distro/alpine"
+	"github.com/reproducible-containers/repro-get/pkg/distro/cargo"
 	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
 	"github.com/reproducible-containers/repro-get/pkg/distro/distroutil/detect"
 	"github.com/reproducible-containers/repro-get/pkg/distro/fedora"
+	"github.com/reproducible-containers/repro-get/pkg/distro/gomod"
+	"github.com/reproducible-containers/repro-get/pkg/distro/maven"
 	"github.com/reproducible-containers/repro-get/pkg/distro/none"
+	"github.com/reproducible-containers/repro-get/pkg/distro/npm"
 	"github.com/reproducible-containers/repro-get/pkg/distro/ubuntu"
 	"github.com/reproducible-containers/repro-get/pkg/envutil"
+	"github.com/reproducible-containers/repro-get/pkg/tuf"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
 	"github.com/reproducible-containers/repro-get/pkg/version"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -20,16 +29,39 @@ import (
 
 func main() {
 	if err := newRootCommand().Execute(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			logrus.Error(exitErr.err)
+			os.Exit(exitErr.code)
+		}
 		logrus.Fatal(err)
 	}
 }
 
+// exitError is an error with a non-default exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
 var knownDistros = map[string]distro.Distro{
 	none.Name:   none.New(),
 	debian.Name: debian.New(),
 	ubuntu.Name: ubuntu.New(),
 	fedora.Name: fedora.New(),
 	alpine.Name: alpine.New(),
+	npm.Name:    npm.New(),
+	gomod.Name:  gomod.New(),
+	cargo.Name:  cargo.New(),
+	maven.Name:  maven.New(),
 }
 
 func knownDistroNames() []string {
@@ -91,7 +123,13 @@ func newRootCommand() *cobra.Command {
 	}
 	flags := cmd.PersistentFlags()
 	flags.Bool("debug", envutil.Bool("DEBUG", false), "debug mode [$DEBUG]")
-	flags.String("cache", envutil.String("REPRO_GET_CACHE", "/var/cache/repro-get"), "Cache directory [$REPRO_GET_CACHE]")
+	flags.String("format", envutil.String("REPRO_GET_FORMAT", "text"), "Output format of download, install, verify, cache info, and hash diff: \"text\" or \"json\" (not applicable to the subcommands with their own --format flags) [$REPRO_GET_FORMAT]")
+	flags.String("cache", envutil.String("REPRO_GET_CACHE", ""), "Cache directory, optionally with read-only directories and a remote cache URL, such as \"primary:/var/cache/repro-get,ro:/mnt/shared-cache,s3://BUCKET/PREFIX\" (default: \""+defaultCacheDir+"\", or the user cache directory when \""+defaultCacheDir+"\" is not writable) [$REPRO_GET_CACHE]")
+	flags.Bool("cache-write-through", envutil.Bool("REPRO_GET_CACHE_WRITE_THROUGH", false), "Upload the downloaded files to the remote cache too, while downloading them [$REPRO_GET_CACHE_WRITE_THROUGH]")
+	flags.Bool("cache-chunked", envutil.Bool("REPRO_GET_CACHE_CHUNKED", false), "Store the cached files as content-defined chunks, so that the successive versions of large files share most of the storage [$REPRO_GET_CACHE_CHUNKED]")
+	flags.String("cache-hook", envutil.String("REPRO_GET_CACHE_HOOK", ""), "Program to execute on the cache events (import, evict, verify-failure), with the event in JSON on stdin [$REPRO_GET_CACHE_HOOK]")
+	flags.String("cache-url-ttl", envutil.String("REPRO_GET_CACHE_URL_TTL", ""), "Re-fetch the URLs that were cached earlier than the TTL, such as \"12h\" and \"7d\" (unlimited by default) [$REPRO_GET_CACHE_URL_TTL]")
+	flags.String("cache-keep-mismatch", envutil.String("REPRO_GET_CACHE_KEEP_MISMATCH", ""), "Directory to keep the downloaded files that do not match their hashes, and the diffoscope reports (removed by default) [$REPRO_GET_CACHE_KEEP_MISMATCH]")
 
 	defaultDistro, err := getDistroByName("")
 	if err != nil {
@@ -104,6 +142,36 @@ func newRootCommand() *cobra.Command {
 	})
 	// the actual default value is filled after resolving the distro
 	flags.StringSlice("provider", envutil.StringSlice("REPRO_GET_PROVIDER", nil), "File provider, run 'repro-get info' to show the default [$REPRO_GET_PROVIDER]")
+	flags.StringSlice("delta-provider", envutil.StringSlice("REPRO_GET_DELTA_PROVIDER", nil), "Delta provider for reconstructing the *.deb files from the older versions in the cache, such as \"debdelta=http://debdeltas.debian.net/debian-deltas/{{.DebdeltaName}}\" (needs debpatch) and \"zsync=http://mirror.example.com/debian/{{.Name}}.zsync\" (needs zsync) [$REPRO_GET_DELTA_PROVIDER]")
+	flags.StringSlice("proxy", envutil.StringSlice("REPRO_GET_PROXY", nil), "Proxy for downloading files, optionally with a host name pattern, such as \"socks5://proxy.example.com:1080\", \"*.example.com=http://proxy.example.com:3128\", and \"mirror.example.com=direct\" (default: $HTTP_PROXY, $HTTPS_PROXY, and $NO_PROXY) [$REPRO_GET_PROXY]")
+	flags.StringSlice("tls-ca-cert", envutil.StringSlice("REPRO_GET_TLS_CA_CERT", nil), "CA certificate file (PEM) for downloading files, optionally with a host name pattern, such as \"mirror.example.com=/etc/ssl/private-ca.pem\" [$REPRO_GET_TLS_CA_CERT]")
+	flags.StringSlice("tls-client-cert", envutil.StringSlice("REPRO_GET_TLS_CLIENT_CERT", nil), "Client certificate and key files (PEM) for downloading files, optionally with a host name pattern, such as \"mirror.example.com=/etc/ssl/client.pem:/etc/ssl/client-key.pem\" [$REPRO_GET_TLS_CLIENT_CERT]")
+	flags.StringSlice("tls-insecure-skip-verify", envutil.StringSlice("REPRO_GET_TLS_INSECURE_SKIP_VERIFY", nil), "INSECURE: Host name patterns to skip verifying the TLS certificates for, such as \"mirror.example.com\" and \"*\" [$REPRO_GET_TLS_INSECURE_SKIP_VERIFY]")
+	flags.StringSlice("auth", envutil.StringSlice("REPRO_GET_AUTH", nil), "Credential for downloading files from the host (HTTP, HTTPS), such as \"mirror.example.com=USERNAME:PASSWORD\" and \"mirror.example.com=bearer:TOKEN\" [$REPRO_GET_AUTH]")
+	flags.String("auth-helper", envutil.String("REPRO_GET_AUTH_HELPER", ""), "Credential helper program, executed as \"PROGRAM get\" with \"<SCHEME>://<HOST>\" on stdin, printing {\"Username\":\"...\",\"Secret\":\"...\"} on stdout [$REPRO_GET_AUTH_HELPER]")
+	flags.StringSlice("netrc", envutil.StringSlice("REPRO_GET_NETRC", nil), "netrc files for the credentials, such as \"/etc/apt/auth.conf.d/90ubuntu-advantage\" (default: $NETRC, or ~/.netrc if it exists) [$REPRO_GET_NETRC]")
+	flags.String("ipfs-gateway", envutil.String("REPRO_GET_IPFS_GATEWAY", urlopener.DefaultIPFSGateway), "IPFS gateway for the \"ipfs://\" providers [$REPRO_GET_IPFS_GATEWAY]")
+	flags.Bool("provider-reorder", envutil.Bool("REPRO_GET_PROVIDER_REORDER", true), "Try the fastest healthy provider first, by recording the success rate and the latency of the providers [$REPRO_GET_PROVIDER_REORDER]")
+	flags.Bool("provider-health-persist", envutil.Bool("REPRO_GET_PROVIDER_HEALTH_PERSIST", false), "Persist the success rate and the latency of the providers in the cache directory, for the next runs [$REPRO_GET_PROVIDER_HEALTH_PERSIST]")
+	flags.String("progress", envutil.String("REPRO_GET_PROGRESS", "auto"), "Progress output: \"auto\" (progress bars on terminals, plain lines otherwise) or \"json\" (JSON lines of the events) [$REPRO_GET_PROGRESS]")
+	flags.Int("progress-fd", envutil.Int("REPRO_GET_PROGRESS_FD", 1), "File descriptor for --progress=json (default: 1, stdout) [$REPRO_GET_PROGRESS_FD]")
+	flags.Int("retries", envutil.Int("REPRO_GET_RETRIES", 3), "Number of the retries per provider on transient errors (HTTP 5xx, connection errors), before trying the next provider [$REPRO_GET_RETRIES]")
+	flags.Duration("retry-backoff", envutil.Duration("REPRO_GET_RETRY_BACKOFF", time.Second), "Initial backoff between the retries, doubled on each retry [$REPRO_GET_RETRY_BACKOFF]")
+	flags.Duration("timeout", envutil.Duration("REPRO_GET_TIMEOUT", 0), "Timeout for downloading all the files (0 for unlimited) [$REPRO_GET_TIMEOUT]")
+	flags.Duration("per-file-timeout", envutil.Duration("REPRO_GET_PER_FILE_TIMEOUT", 0), "Timeout for downloading a file from a provider, including the retries; the next provider is tried on the timeout (0 for unlimited) [$REPRO_GET_PER_FILE_TIMEOUT]")
+	flags.String("keys-dir", envutil.String("REPRO_GET_KEYS_DIR", ""), "Directory of the trusted keys managed with 'repro-get key', for verifying the repository metadata and the hash files (default: \""+defaultKeysDir+"\", or the user config directory when \""+defaultKeysDir+"\" neither exists nor is writable) [$REPRO_GET_KEYS_DIR]")
+	flags.Bool("verify-package-signatures", envutil.Bool("REPRO_GET_VERIFY_PACKAGE_SIGNATURES", false), "Verify the signatures embedded in the packages before installing them, in addition to the hashes (Debian and Ubuntu: debsig, needs debsig-verify; Fedora: OpenPGP) [$REPRO_GET_VERIFY_PACKAGE_SIGNATURES]")
+	flags.String("debsig-policies-dir", envutil.String("REPRO_GET_DEBSIG_POLICIES_DIR", ""), "Directory of the debsig policies for --verify-package-signatures (default: \"/etc/debsig/policies\") [$REPRO_GET_DEBSIG_POLICIES_DIR]")
+	flags.String("debsig-keyrings-dir", envutil.String("REPRO_GET_DEBSIG_KEYRINGS_DIR", ""), "Directory of the debsig keyrings for --verify-package-signatures (default: \"/usr/share/debsig/keyrings\") [$REPRO_GET_DEBSIG_KEYRINGS_DIR]")
+	flags.StringSlice("package-keyring", envutil.StringSlice("REPRO_GET_PACKAGE_KEYRING", nil), "OpenPGP keyrings for --verify-package-signatures (Fedora only), in addition to the OpenPGP keys in --keys-dir (default: \"/etc/pki/rpm-gpg/RPM-GPG-KEY-*\" when no key is specified) [$REPRO_GET_PACKAGE_KEYRING]")
+	flags.String("tuf-repository", envutil.String("REPRO_GET_TUF_REPOSITORY", ""), "TUF repository URL (or directory) for distributing the providers, the keys, and the snapshot of the distro, protected against the rollback and the freeze attacks [$REPRO_GET_TUF_REPOSITORY]")
+	flags.String("tuf-root", envutil.String("REPRO_GET_TUF_ROOT", ""), "Initial trusted root metadata (root.json) of the TUF repository, needed on the first use [$REPRO_GET_TUF_ROOT]")
+	flags.String("tuf-target", envutil.String("REPRO_GET_TUF_TARGET", tuf.DefaultProviderConfigTarget), "Target name of the provider config in the TUF repository [$REPRO_GET_TUF_TARGET]")
+	flags.StringSlice("signature-keyring", envutil.StringSlice("REPRO_GET_SIGNATURE_KEYRING", nil), "OpenPGP keyrings for verifying the signatures of the hash files (SHA256SUMS.asc), on download and install, in addition to the OpenPGP keys in --keys-dir [$REPRO_GET_SIGNATURE_KEYRING]")
+	flags.StringSlice("signature-minisign-key", envutil.StringSlice("REPRO_GET_SIGNATURE_MINISIGN_KEY", nil), "minisign (or signify) public key files, or the base64-encoded public keys, for verifying the signatures of the hash files (SHA256SUMS.minisig, SHA256SUMS.sig), in addition to the minisign keys in --keys-dir [$REPRO_GET_SIGNATURE_MINISIGN_KEY]")
+	flags.String("sigstore-identity", envutil.String("REPRO_GET_SIGSTORE_IDENTITY", ""), "Regular expression of the certificate identity for verifying the Sigstore bundles of the hash files (SHA256SUMS.sigstore.json) with cosign, such as \"^https://github.com/USERNAME/REPO/\" [$REPRO_GET_SIGSTORE_IDENTITY]")
+	flags.String("sigstore-oidc-issuer", envutil.String("REPRO_GET_SIGSTORE_OIDC_ISSUER", ""), "Regular expression of the OIDC issuer for verifying the Sigstore bundles, such as \"^https://token.actions.githubusercontent.com$\" [$REPRO_GET_SIGSTORE_OIDC_ISSUER]")
+	flags.Bool("require-signature", envutil.Bool("REPRO_GET_REQUIRE_SIGNATURE", false), "Refuse the hash files without a valid signature (needs --signature-keyring, --signature-minisign-key, or --sigstore-identity and --sigstore-oidc-issuer) [$REPRO_GET_REQUIRE_SIGNATURE]")
 
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		if debug, _ := cmd.Flags().GetBool("debug"); debug {
@@ -115,11 +183,26 @@ func newRootCommand() *cobra.Command {
 	cmd.AddCommand(
 		newInfoCommand(),
 		newInstallCommand(),
+		newRemoveCommand(),
+		newRollbackCommand(),
+		newBootstrapCommand(),
 		newDownloadCommand(),
+		newListCommand(),
+		newProbeCommand(),
 		newHashCommand(),
 		newCacheCommand(),
+		newOCICommand(),
 		newIPFSCommand(),
+		newTorrentCommand(),
 		newDockerfileCommand(),
+		newImageCommand(),
+		newBuildCommand(),
+		newKeyCommand(),
+		newServeCommand(),
+		newSBOMCommand(),
+		newAttestCommand(),
+		newBuildinfoCommand(),
+		newVerifyCommand(),
 	)
 	return cmd
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newOCICommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "oci",
+		Short:         "Manage OCI artifacts on container registries",
+		Args:          cobra.NoArgs,
+		RunE:          needsSubcommand,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(
+		newOCIPushCommand(),
+		newOCIPullCommand(),
+		newOCIAttachCommand(),
+		newOCIReferrersCommand(),
+	)
+	return cmd
+}
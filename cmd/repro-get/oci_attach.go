@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/reproducible-containers/repro-get/pkg/ocidistutil"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newOCIAttachCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attach [flags] REF FILE...",
+		Short: "Attach the hash files, the SBOMs, and the provenance to an OCI artifact or an image, as OCI referrers",
+		Long: `Attach the hash files, the SBOMs, and the provenance to an OCI artifact or an image, as OCI referrers.
+REF is an artifact pushed with 'repro-get oci push', or an image built with 'repro-get image build' and pushed to the registry.
+
+Each of the files is pushed as a referrer artifact, along with its signatures alongside the file
+("<FILE>` + hashsig.SignatureSuffix + `", "<FILE>` + hashsig.MinisignSignatureSuffix + `", "<FILE>` + hashsig.SignifySignatureSuffix + `", and "<FILE>` + hashsig.SigstoreBundleSuffix + `").
+The artifact type is detected from the file name, unless --artifact-type is specified:
+- "*.spdx.json":                       ` + ocidistutil.SPDXArtifactType + `
+- "*.cdx.json", "*.cyclonedx.json":    ` + ocidistutil.CycloneDXArtifactType + `
+- "*.intoto.json", "*.intoto.jsonl":   ` + ocidistutil.InTotoArtifactType + `
+- Others (hash files):                 ` + ocidistutil.HashFileArtifactType + `
+
+The referrers are recorded in the "referrers tag schema" of the OCI distribution spec v1.1 ("sha256-<DIGEST>"),
+and can be listed with 'repro-get oci referrers'.
+The attached hash files are verified on 'repro-get oci pull'.`,
+		Example: "  repro-get oci attach ghcr.io/USERNAME/dpkgs:latest SHA256SUMS-" + archutil.OCIArchDashVariant() + " sbom.spdx.json SHA256SUMS-" + archutil.OCIArchDashVariant() + ".intoto.json",
+		Args:    cobra.MinimumNArgs(2),
+		RunE:    ociAttachAction,
+
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("plain-http", false, "Use plain HTTP instead of HTTPS")
+	flags.String("artifact-type", "", "Artifact type of the files (default: detected from the file names)")
+
+	return cmd
+}
+
+func ociAttachAction(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	plainHTTP, err := flags.GetBool("plain-http")
+	if err != nil {
+		return err
+	}
+	artifactType, err := flags.GetString("artifact-type")
+	if err != nil {
+		return err
+	}
+	artifact, err := ocidistutil.NewArtifact(ctx, args[0], plainHTTP)
+	if err != nil {
+		return err
+	}
+	subject, err := artifact.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return attachFiles(ctx, cmd.OutOrStdout(), artifact, subject, args[1:], artifactType)
+}
+
+// isReferrerSignatureOf returns true if name is the name of a signature of file.
+func isReferrerSignatureOf(name, file string) bool {
+	for _, suffix := range hashsig.SignatureSuffixes {
+		if name == file+suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// referrerArtifactType detects the artifact type of the file from the file name.
+func referrerArtifactType(file string) string {
+	switch base := filepath.Base(file); {
+	case strings.HasSuffix(base, ".spdx.json"):
+		return ocidistutil.SPDXArtifactType
+	case strings.HasSuffix(base, ".cdx.json"), strings.HasSuffix(base, ".cyclonedx.json"):
+		return ocidistutil.CycloneDXArtifactType
+	case strings.HasSuffix(base, ".intoto.json"), strings.HasSuffix(base, ".intoto.jsonl"):
+		return ocidistutil.InTotoArtifactType
+	default:
+		return ocidistutil.HashFileArtifactType
+	}
+}
+
+// referrerMediaType returns the media type of the file (or the signature) in the referrer.
+func referrerMediaType(file, artifactType string) string {
+	switch {
+	case strings.HasSuffix(file, hashsig.SignatureSuffix):
+		return "application/pgp-signature"
+	case strings.HasSuffix(file, hashsig.SigstoreBundleSuffix):
+		return "application/vnd.dev.sigstore.bundle+json"
+	case hashsig.IsSignatureFile(file):
+		return ocidistutil.ArtifactLayerMediaType
+	case artifactType == ocidistutil.HashFileArtifactType:
+		return "text/plain"
+	default:
+		return artifactType
+	}
+}
+
+// referrerBlob returns the blob of the file.
+func referrerBlob(file, artifactType string) (*ocidistutil.ArtifactBlob, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dgst, err := digest.SHA256.FromReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the digest of %q: %w", file, err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &ocidistutil.ArtifactBlob{
+		SHA256:    dgst.Encoded(),
+		Size:      st.Size(),
+		Title:     filepath.Base(file),
+		MediaType: referrerMediaType(file, artifactType),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(file)
+		},
+	}, nil
+}
+
+// attachFiles pushes each of the files as a referrer of the subject, along with the signatures alongside the file,
+// and prints the digests of the referrers to w.
+// The artifact type is detected from the file names, when artifactType is empty.
+func attachFiles(ctx context.Context, w io.Writer, artifact *ocidistutil.Artifact, subject ocispec.Descriptor, files []string, artifactType string) error {
+	for _, file := range files {
+		if hashsig.IsSignatureFile(file) {
+			return fmt.Errorf("%q is a signature (Hint: specify the signed file instead, and the signatures alongside the file are attached along with it)", file)
+		}
+		typ := artifactType
+		if typ == "" {
+			typ = referrerArtifactType(file)
+		}
+		b, err := referrerBlob(file, typ)
+		if err != nil {
+			return err
+		}
+		blobs := []ocidistutil.ArtifactBlob{*b}
+		for _, suffix := range hashsig.SignatureSuffixes {
+			sig, err := referrerBlob(file+suffix, typ)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return err
+			}
+			blobs = append(blobs, *sig)
+		}
+		annotations := map[string]string{
+			ocispec.AnnotationTitle: b.Title,
+		}
+		logrus.Infof("Attaching %q (%s, with %d signatures) to %q (%s)", file, typ, len(blobs)-1, artifact.Ref(), subject.Digest)
+		desc, err := artifact.PushReferrer(ctx, subject, typ, blobs, annotations)
+		if err != nil {
+			return fmt.Errorf("failed to attach %q: %w", file, err)
+		}
+		fmt.Fprintln(w, desc.Digest)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/reproducible-containers/repro-get/pkg/ocidistutil"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newOCIPullCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pull [flags] REF",
+		Short: "Pull the files of an OCI artifact from a container registry into the cache",
+		Long: `Pull the files of an OCI artifact from a container registry into the cache.
+The artifact is expected to be pushed with 'repro-get oci push'.
+Use 'repro-get install' for installing the pulled packages.
+
+The hash files attached to the artifact (see 'repro-get oci attach') are verified with --signature-keyring, --signature-minisign-key,
+--sigstore-identity, and --sigstore-oidc-issuer, and the pulled files have to be listed in them.
+The SBOMs and the provenance attached to the artifact are rejected when their signatures are bad.
+With --require-signature, the artifact has to have the signed hash files attached.
+`,
+		Example: "  repro-get oci pull ghcr.io/USERNAME/dpkgs:latest\n" +
+			"  repro-get --signature-keyring=pubring.asc --require-signature oci pull --referrers-dir=. ghcr.io/USERNAME/dpkgs:latest",
+		Args: cobra.ExactArgs(1),
+		RunE: ociPullAction,
+
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("plain-http", false, "Use plain HTTP instead of HTTPS")
+	flags.String("referrers-dir", "", "Directory to save the files attached to the artifact, such as the hash files (default: a temporary directory)")
+
+	return cmd
+}
+
+func ociPullAction(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	cache, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	plainHTTP, err := flags.GetBool("plain-http")
+	if err != nil {
+		return err
+	}
+
+	artifact, err := ocidistutil.NewArtifact(ctx, args[0], plainHTTP)
+	if err != nil {
+		return err
+	}
+	desc, manifest, err := artifact.Manifest(ctx)
+	if err != nil {
+		return err
+	}
+	verified, err := verifyOCIReferrers(cmd, artifact, desc)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Pulling %d files from %q (%s)", len(manifest.Layers), artifact.Ref(), desc.Digest)
+	for _, layer := range manifest.Layers {
+		if layer.Digest.Algorithm() != digest.SHA256 {
+			return fmt.Errorf("unsupported digest %q", layer.Digest)
+		}
+		sha256sum := layer.Digest.Encoded()
+		if verified != nil {
+			if _, ok := verified[sha256sum]; !ok {
+				return fmt.Errorf("%q (%s) is not listed in the hash files attached to %q", layer.Annotations[ocispec.AnnotationTitle], layer.Digest, artifact.Ref())
+			}
+		}
+		cached, err := cache.Cached(sha256sum)
+		if err != nil {
+			return err
+		}
+		if cached {
+			logrus.Debugf("Already cached: %s", layer.Digest)
+			continue
+		}
+		r, err := artifact.Fetch(ctx, layer)
+		if err != nil {
+			return err
+		}
+		got, err := cache.ImportWithReader(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if got != sha256sum {
+			return fmt.Errorf("expected sha256sum %q, got %q", sha256sum, got)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), sha256sum)
+	}
+	return nil
+}
+
+// verifyOCIReferrers saves the files attached to the subject (see attachFiles) into --referrers-dir, and verifies their signatures
+// (see newHashsigVerifyOpts).
+// Returns the SHA256 sums in the attached hash files, or nil when no hash file is attached.
+func verifyOCIReferrers(cmd *cobra.Command, artifact *ocidistutil.Artifact, subject ocispec.Descriptor) (map[string]struct{}, error) {
+	ctx := cmd.Context()
+	dir, err := cmd.Flags().GetString("referrers-dir")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		if dir, err = os.MkdirTemp("", "repro-get-referrers-"); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+	} else if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	opts, err := newHashsigVerifyOpts(cmd)
+	if err != nil {
+		return nil, err
+	}
+	referrers, err := artifact.Referrers(ctx, subject.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	var hashFiles []string
+	seen := make(map[string]struct{}, len(referrers))
+	for _, f := range referrers {
+		switch f.ArtifactType {
+		case ocidistutil.HashFileArtifactType, ocidistutil.SPDXArtifactType, ocidistutil.CycloneDXArtifactType, ocidistutil.InTotoArtifactType:
+		default:
+			logrus.Debugf("Ignoring the referrer %s of an unknown artifact type %q", f.Digest, f.ArtifactType)
+			continue
+		}
+		title := f.Annotations[ocispec.AnnotationTitle]
+		if _, ok := seen[title]; ok {
+			logrus.Debugf("Ignoring the older referrer %s of %q", f.Digest, title)
+			continue
+		}
+		seen[title] = struct{}{}
+		file, err := pullReferrer(ctx, artifact, f, dir)
+		if err != nil {
+			return nil, err
+		}
+		if f.ArtifactType == ocidistutil.HashFileArtifactType {
+			hashFiles = append(hashFiles, file)
+			continue
+		}
+		// The SBOMs and the provenance do not have to be signed, but a bad signature is rejected
+		optsNoRequire := opts
+		optsNoRequire.Require = false
+		if err = hashsig.VerifyFiles(ctx, []string{file}, optsNoRequire); err != nil {
+			return nil, err
+		}
+	}
+	if len(hashFiles) == 0 {
+		if opts.Require {
+			return nil, fmt.Errorf("%w: no hash file is attached to %q (Hint: attach the signed hash files with 'repro-get oci attach' or 'repro-get oci push --attach')",
+				hashsig.ErrUnsigned, artifact.Ref())
+		}
+		logrus.Debugf("No hash file is attached to %q", artifact.Ref())
+		return nil, nil
+	}
+	if err = hashsig.VerifyFiles(ctx, hashFiles, opts); err != nil {
+		return nil, err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(hashFiles...)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]struct{}, len(fileSpecs))
+	for _, sp := range fileSpecs {
+		res[sp.Sum()] = struct{}{}
+	}
+	return res, nil
+}
+
+// pullReferrer saves the file of the referrer and its signatures into dir, and returns the path of the file.
+// The stale signatures of the file in dir are removed.
+func pullReferrer(ctx context.Context, artifact *ocidistutil.Artifact, desc ocispec.Descriptor, dir string) (string, error) {
+	title := desc.Annotations[ocispec.AnnotationTitle]
+	if title == "" || filepath.Base(title) != title || title == "." || title == ".." {
+		return "", fmt.Errorf("invalid title %q of the referrer %s", title, desc.Digest)
+	}
+	manifest, err := artifact.FetchManifest(ctx, desc)
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, title)
+	if _, err = hashsig.RemoveSignatures(file); err != nil {
+		return "", err
+	}
+	var found bool
+	for _, layer := range manifest.Layers {
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		if name == title {
+			found = true
+		} else if !isReferrerSignatureOf(name, title) {
+			return "", fmt.Errorf("unexpected file %q in the referrer %s of %q", name, desc.Digest, title)
+		}
+		if err = pullReferrerBlob(ctx, artifact, layer, filepath.Join(dir, name)); err != nil {
+			return "", err
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("the referrer %s does not contain %q", desc.Digest, title)
+	}
+	logrus.Debugf("Saved the referrer %s as %q", desc.Digest, file)
+	return file, nil
+}
+
+// pullReferrerBlob saves the blob as file, after verifying the digest.
+func pullReferrerBlob(ctx context.Context, artifact *ocidistutil.Artifact, desc ocispec.Descriptor, file string) error {
+	r, err := artifact.Fetch(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	verifier := desc.Digest.Verifier()
+	b, err := io.ReadAll(io.TeeReader(io.LimitReader(r, desc.Size), verifier))
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) != desc.Size || !verifier.Verified() {
+		return fmt.Errorf("failed to verify %q: expected %s", filepath.Base(file), desc.Digest)
+	}
+	return os.WriteFile(file, b, 0644)
+}
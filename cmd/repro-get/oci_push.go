@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/ocidistutil"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newOCIPushCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "push [flags] REF SHA256SUMS...",
+		Short: "Push the cached files into a container registry as an OCI artifact",
+		Long: `Push the cached files into a container registry as an OCI artifact.
+The files are selected by the hash files, and have to be cached in advance with 'repro-get download'.
+
+The pushed files can be pulled with 'repro-get oci pull', or with the 'oci://' provider:
+$ repro-get --provider=oci://ghcr.io/USERNAME/dpkgs install SHA256SUMS
+
+With --attach, the hash files and their signatures are attached to the artifact as OCI referrers (see 'repro-get oci attach'),
+so that they can be verified on 'repro-get oci pull'.
+`,
+		Example: "  repro-get oci push ghcr.io/USERNAME/dpkgs:latest SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get oci push --attach ghcr.io/USERNAME/dpkgs:latest SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(2),
+		RunE: ociPushAction,
+
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("plain-http", false, "Use plain HTTP instead of HTTPS")
+	flags.Bool("attach", false, "Attach the hash files and their signatures to the artifact, as OCI referrers")
+
+	return cmd
+}
+
+func ociPushAction(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	cache, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	plainHTTP, err := flags.GetBool("plain-http")
+	if err != nil {
+		return err
+	}
+	attach, err := flags.GetBool("attach")
+	if err != nil {
+		return err
+	}
+
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args[1:]...)
+	if err != nil {
+		return err
+	}
+	fnames := make([]string, 0, len(fileSpecs))
+	for fname := range fileSpecs {
+		fnames = append(fnames, fname)
+	}
+	sort.Strings(fnames)
+
+	var blobs []ocidistutil.ArtifactBlob
+	seen := make(map[string]struct{}, len(fnames))
+	for _, fname := range fnames {
+		fileSpec := fileSpecs[fname]
+		sum := fileSpec.Sum()
+		if _, ok := seen[sum]; ok {
+			continue
+		}
+		seen[sum] = struct{}{}
+		blobPath, err := cache.BlobAbsPath(sum)
+		if err != nil {
+			return err
+		}
+		st, err := os.Stat(blobPath)
+		if err != nil {
+			return fmt.Errorf("uncached file? %q: %w (Hint: try 'repro-get download ...')", fname, err)
+		}
+		blobs = append(blobs, ocidistutil.ArtifactBlob{
+			SHA256: sum,
+			Size:   st.Size(),
+			Title:  fileSpec.Basename,
+			Open: func() (io.ReadCloser, error) {
+				return os.Open(blobPath)
+			},
+		})
+	}
+
+	artifact, err := ocidistutil.NewArtifact(ctx, args[0], plainHTTP)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Pushing %d files to %q", len(blobs), artifact.Ref())
+	desc, err := artifact.Push(ctx, blobs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), desc.Digest)
+	if attach {
+		return attachFiles(ctx, io.Discard, artifact, desc, args[1:], ocidistutil.HashFileArtifactType)
+	}
+	return nil
+}
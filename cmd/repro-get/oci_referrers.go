@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/reproducible-containers/repro-get/pkg/ocidistutil"
+	"github.com/spf13/cobra"
+)
+
+func newOCIReferrersCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "referrers [flags] REF",
+		Short: "List the referrers attached to an OCI artifact or an image",
+		Long: `List the referrers attached to an OCI artifact or an image, with 'repro-get oci attach' or 'repro-get oci push --attach'.
+The newest referrer comes first.`,
+		Example: "  repro-get oci referrers ghcr.io/USERNAME/dpkgs:latest",
+		Args:    cobra.ExactArgs(1),
+		RunE:    ociReferrersAction,
+
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("plain-http", false, "Use plain HTTP instead of HTTPS")
+	flags.String("artifact-type", "", "Show only the referrers of the artifact type")
+	flags.Bool("json", false, "Enable JSON output")
+
+	return cmd
+}
+
+func ociReferrersAction(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	plainHTTP, err := flags.GetBool("plain-http")
+	if err != nil {
+		return err
+	}
+	artifactType, err := flags.GetString("artifact-type")
+	if err != nil {
+		return err
+	}
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	artifact, err := ocidistutil.NewArtifact(ctx, args[0], plainHTTP)
+	if err != nil {
+		return err
+	}
+	subject, err := artifact.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	referrers, err := artifact.Referrers(ctx, subject.Digest, artifactType)
+	if err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		if referrers == nil {
+			referrers = []ocispec.Descriptor{}
+		}
+		return writeJSON(w, referrers)
+	}
+	tw := tabwriter.NewWriter(w, 4, 8, 4, ' ', 0)
+	fmt.Fprintln(tw, "DIGEST\tARTIFACT TYPE\tTITLE")
+	for _, f := range referrers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Digest, f.ArtifactType, f.Annotations[ocispec.AnnotationTitle])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// jsonOutput returns true when the JSON output is enabled with the global --format=json flag,
+// or with the --json flag of the command.
+//
+// The global --format flag is shadowed by the --format flags of the subcommands, such as 'hash generate'.
+func jsonOutput(cmd *cobra.Command) (bool, error) {
+	flags := cmd.Flags()
+	if flags.Lookup("json") != nil {
+		jsonFlag, err := flags.GetBool("json")
+		if err != nil {
+			return false, err
+		}
+		if jsonFlag {
+			return true, nil
+		}
+	}
+	format, err := cmd.Root().PersistentFlags().GetString("format")
+	if err != nil {
+		return false, err
+	}
+	switch format {
+	case "text":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown --format value %q, expected \"text\" or \"json\"", format)
+	}
+}
+
+// writeJSON writes v as an indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
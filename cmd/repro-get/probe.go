@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/downloader"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newProbeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "probe [flags] SHA256SUMS...",
+		Short: "Check which providers still serve the files, without downloading them",
+		Long: `Check which providers still serve the files, without downloading them.
+HEAD requests (or ranged GET requests for the first byte) are sent to every provider for every file.
+The cache is not used or modified.
+
+Fails when a file is not retrievable from any provider.
+Useful for knowing when the mirrors have dropped the pinned versions and a snapshot provider is needed.`,
+		Example: "  repro-get probe SHA256SUMS-" + archutil.OCIArchDashVariant() + `
+  repro-get --provider='http://deb.debian.org/debian/{{.Name}}' --provider='http://snapshot.debian.org/archive/debian/{{.Snapshot}}/{{.Name}}' probe SHA256SUMS-` + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(1),
+		RunE: probeAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("json", false, "Enable JSON output")
+	flags.Int("jobs", 8, "Number of the concurrent requests")
+	return cmd
+}
+
+func probeAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	jsonFlag, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+	var opts downloader.ProbeOpts
+	if opts.Jobs, err = flags.GetInt("jobs"); err != nil {
+		return err
+	}
+	if opts.Providers, err = getProviders(cmd); err != nil {
+		return err
+	}
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	if err != nil {
+		return err
+	}
+	// The cache is not needed, as nothing is downloaded
+	o := urlopener.New()
+	if err = configureURLOpener(cmd, o); err != nil {
+		return err
+	}
+	results, err := downloader.Probe(cmd.Context(), d, o, fileSpecs, opts)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		b, err := json.MarshalIndent(results, "", "    ")
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintln(w, string(b)); err != nil {
+			return err
+		}
+	}
+	var files, unavailable int
+	for i := 0; i < len(results); {
+		name := results[i].Name
+		files++
+		if !jsonFlag {
+			fmt.Fprintln(w, name+":")
+		}
+		available := false
+		for ; i < len(results) && results[i].Name == name; i++ {
+			r := results[i]
+			available = available || r.Available
+			if jsonFlag {
+				continue
+			}
+			switch {
+			case r.Available && r.Size > 0:
+				fmt.Fprintf(w, "- OK: %s (%d bytes)\n", r.URL, r.Size)
+			case r.Available:
+				fmt.Fprintf(w, "- OK: %s\n", r.URL)
+			case r.URL != "":
+				fmt.Fprintf(w, "- NG: %s: %s\n", r.URL, r.Error)
+			default:
+				fmt.Fprintf(w, "- N/A: %s: %s\n", r.Provider, r.Error)
+			}
+		}
+		if !available {
+			logrus.Warnf("%q is not retrievable from any provider", name)
+			unavailable++
+		}
+	}
+	if unavailable > 0 {
+		return fmt.Errorf("%d of %d files are not retrievable from any provider", unavailable, files)
+	}
+	logrus.Infof("All the %d files are retrievable", files)
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/drift"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove [flags] SHA256SUMS...",
+		Short: "Uninstall the packages installed with the hash files",
+		Long: `Uninstall the packages installed with the hash files.
+Only the packages that are installed with the versions pinned in the hash files are uninstalled.
+
+Debian, Ubuntu, Fedora, and Alpine only.`,
+		Example: "  repro-get remove SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    removeAction,
+
+		ValidArgsFunction:     completeHashFiles,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.Bool("dry-run", false, "Print the packages to be uninstalled, without uninstalling them")
+	return cmd
+}
+
+func removeAction(cmd *cobra.Command, args []string) error {
+	d, lister, uninstaller, err := getUninstaller(cmd)
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, archutil.OCIArchDashVariant())
+	if err != nil {
+		return err
+	}
+	installed, err := lister.InstalledPackages(cmd.Context(), "")
+	if err != nil {
+		return fmt.Errorf("failed to list the installed packages: %w", err)
+	}
+	pkgs := drift.Pinned(fileSpecs, installed)
+	if len(pkgs) == 0 {
+		logrus.Info("No package to remove")
+		return nil
+	}
+	if dryRun {
+		return writeRemovals(cmd.OutOrStdout(), pkgs)
+	}
+	logrus.Debugf("Removing %d packages with the distro driver %q", len(pkgs), d.Info().Name)
+	return uninstaller.UninstallPackages(cmd.Context(), pkgs, distro.InstallOpts{})
+}
+
+// getUninstaller returns the distro driver, with the interfaces for listing and uninstalling the packages.
+func getUninstaller(cmd *cobra.Command) (distro.Distro, distro.InstalledLister, distro.Uninstaller, error) {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	lister, ok := d.(distro.InstalledLister)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("distro driver %q does not support listing the installed packages", d.Info().Name)
+	}
+	uninstaller, ok := d.(distro.Uninstaller)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("distro driver %q does not support uninstalling the packages", d.Info().Name)
+	}
+	return d, lister, uninstaller, nil
+}
+
+// writeRemovals writes the packages to be uninstalled, as "remove PACKAGE[:ARCH] VERSION" lines.
+func writeRemovals(w io.Writer, pkgs []distro.InstalledPackage) error {
+	var b strings.Builder
+	for _, pkg := range pkgs {
+		s := pkg.Package
+		if pkg.Architecture != "" {
+			s += ":" + pkg.Architecture
+		}
+		fmt.Fprintf(&b, "remove %s %s\n", s, pkg.Version)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
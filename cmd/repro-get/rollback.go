@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/downloader"
+	"github.com/reproducible-containers/repro-get/pkg/drift"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newRollbackCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback [flags] OLD_SHA256SUMS...",
+		Short: "Revert the installed packages to the old hash files",
+		Long: `Revert the installed packages to the old hash files.
+The packages pinned in the old hash files are installed, unless they are already installed with the pinned versions.
+The installed packages with other versions are downgraded (or upgraded) to the pinned versions.
+
+When --current is specified, the packages that are pinned in the current hash files but not in the old hash files are uninstalled.
+The other packages are kept as they are.
+
+The packages are installed from the cache, and downloaded only when they are not cached.
+
+Debian, Ubuntu, Fedora, and Alpine only.`,
+		Example: "  repro-get rollback --current=SHA256SUMS-" + archutil.OCIArchDashVariant() + " SHA256SUMS-" + archutil.OCIArchDashVariant() + ".old",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    rollbackAction,
+
+		ValidArgsFunction:     completeHashFiles,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.StringSlice("current", nil, "The current hash files, for uninstalling the packages that are not pinned in the old hash files")
+	flags.Bool("dry-run", false, "Print the files to be downloaded and the packages to be installed and uninstalled, without accessing the network and without modifying the system")
+	return cmd
+}
+
+func rollbackAction(cmd *cobra.Command, args []string) error {
+	d, lister, uninstaller, err := getUninstaller(cmd)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	flags := cmd.Flags()
+	currentFiles, err := flags.GetStringSlice("current")
+	if err != nil {
+		return err
+	}
+	dryRun, err := flags.GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	arch := archutil.OCIArchDashVariant()
+	old, err := newVerifiedFileSpecs(cmd, args, arch)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]*filespec.FileSpec)
+	if len(currentFiles) > 0 {
+		if current, err = newVerifiedFileSpecs(cmd, currentFiles, arch); err != nil {
+			return err
+		}
+	}
+	installed, err := lister.InstalledPackages(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list the installed packages: %w", err)
+	}
+	plan := drift.Rollback(old, current, installed)
+	if len(plan.Install) == 0 && len(plan.Remove) == 0 {
+		logrus.Info("No package to roll back")
+		return nil
+	}
+
+	cache, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	installOpts := distro.InstallOpts{
+		Downgrade: true,
+	}
+	if len(plan.Install) > 0 {
+		fileSpecs := make(map[string]*filespec.FileSpec, len(plan.Install))
+		for i := range plan.Install {
+			fileSpecs[plan.Install[i].Name] = &plan.Install[i]
+		}
+		if dryRun {
+			entries, downloadRes, err := runPlanner(cmd, d, cache, fileSpecs, downloader.Opts{})
+			if err != nil {
+				return err
+			}
+			if err = downloader.WritePlan(cmd.OutOrStdout(), entries); err != nil {
+				return err
+			}
+			command, err := installCommand(d, cache, downloadRes.PackagesToBeInstalled, installOpts)
+			if err != nil {
+				return err
+			}
+			if err = writeInstallCommand(cmd.OutOrStdout(), command); err != nil {
+				return err
+			}
+		} else {
+			downloadRes, err := runDownloader(cmd, d, cache, fileSpecs, downloader.Opts{})
+			if err != nil {
+				return err
+			}
+			if _, err = verifyPackageSignatures(cmd, d, cache, downloadRes.PackagesToBeInstalled); err != nil {
+				return err
+			}
+			if err = d.InstallPackages(ctx, cache, downloadRes.PackagesToBeInstalled, installOpts); err != nil {
+				return err
+			}
+		}
+	}
+	// The packages are uninstalled after installing the old packages, so that the packages depending on them are replaced first
+	if dryRun {
+		return writeRemovals(cmd.OutOrStdout(), plan.Remove)
+	}
+	return uninstaller.UninstallPackages(ctx, plan.Remove, installOpts)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/sbom"
+	"github.com/reproducible-containers/repro-get/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+func newSBOMCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sbom [flags] SHA256SUMS...",
+		Short: "Generate the SBOM of the packages in the hash files",
+		Long: `Generate the software bill of materials (SBOM) of the packages in the hash files.
+The SBOM is generated from the hash files and the package metadata derived from the file names, without accessing the network.
+The download locations are resolved with the providers (--provider).
+The package URLs (purls) of the deb, rpm, and apk packages are namespaced with the distro (--distro).
+
+The creation time of the SBOM is taken from $SOURCE_DATE_EPOCH, when it is set.`,
+		Example: "  repro-get sbom --format=spdx-json SHA256SUMS-" + archutil.OCIArchDashVariant() + " >sbom.spdx.json\n" +
+			"  repro-get sbom --format=cyclonedx-json SHA256SUMS-" + archutil.OCIArchDashVariant() + " >sbom.cdx.json",
+		Args: cobra.MinimumNArgs(1),
+		RunE: sbomAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("format", string(sbom.FormatSPDXJSON), fmt.Sprintf("Output format %v", sbom.Formats))
+	flags.String("name", "", "Name of the SBOM document (default: the file name of the first hash file)")
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\", for the hash files with the architecture sections (\"#repro-get:arch=...\")")
+	return cmd
+}
+
+func sbomAction(cmd *cobra.Command, args []string) error {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	flags := cmd.Flags()
+	format, err := flags.GetString("format")
+	if err != nil {
+		return err
+	}
+	arch, err := flags.GetString("arch")
+	if err != nil {
+		return err
+	}
+	opts := sbom.Opts{
+		Distro:      d.Info().Name,
+		ToolVersion: version.GetVersion(),
+	}
+	if opts.Name, err = flags.GetString("name"); err != nil {
+		return err
+	}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(args[0])
+	}
+	if opts.Providers, err = getProviders(cmd); err != nil {
+		return err
+	}
+	if len(opts.Providers) == 0 {
+		opts.Providers = d.Info().DefaultProviders
+	}
+	if opts.Created, err = sourceDateEpoch(); err != nil {
+		return err
+	}
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, arch)
+	if err != nil {
+		return err
+	}
+	return sbom.Write(cmd.OutOrStdout(), fileSpecs, sbom.Format(format), opts)
+}
+
+// sourceDateEpoch returns the time of $SOURCE_DATE_EPOCH, or the zero time if it is not set.
+func sourceDateEpoch() (time.Time, error) {
+	v := os.Getenv("SOURCE_DATE_EPOCH")
+	if v == "" {
+		return time.Time{}, nil
+	}
+	epoch, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse $SOURCE_DATE_EPOCH %q: %w", v, err)
+	}
+	return time.Unix(epoch, 0), nil
+}
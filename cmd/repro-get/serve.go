@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro/alpine"
+	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/reposerver"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve [flags] SHA256SUMS...",
+		Short: "Serve the cached packages as an apt/apk repository over HTTP",
+		Long: `Serve the cached packages as an apt/apk repository over HTTP.
+The files are served under the names in the hash files (e.g., "/pool/main/h/hello/hello_2.10-2_amd64.deb"),
+and under the by-hash paths (e.g., "/pool/main/h/hello/by-hash/SHA256/<SHA256>").
+
+Unless --metadata=false is specified, the unsigned repository metadata files are generated:
+- "/Packages", "/Packages.gz", and "/Release", for the *.deb files (flat repository)
+- "/<DIR>/APKINDEX.tar.gz", for the *.apk files
+
+The packages have to be downloaded in advance with 'repro-get download'.`,
+		Example: `  repro-get download SHA256SUMS-` + archutil.OCIArchDashVariant() + `
+  repro-get serve --listen :8080 SHA256SUMS-` + archutil.OCIArchDashVariant() + `
+
+  # On the client (Debian)
+  echo "deb [trusted=yes] http://<HOST>:8080/ ./" >/etc/apt/sources.list.d/repro-get.list
+  apt-get update && apt-get install hello
+
+  # On the client (Alpine)
+  echo "http://<HOST>:8080/v3.16/main" >/etc/apk/repositories
+  apk add --allow-untrusted hello`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: serveAction,
+
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("listen", ":8080", "Listen address")
+	flags.Bool("metadata", true, "Generate the repository metadata (Packages, APKINDEX.tar.gz)")
+	return cmd
+}
+
+func serveAction(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	listen, err := flags.GetString("listen")
+	if err != nil {
+		return err
+	}
+	metadata, err := flags.GetBool("metadata")
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	if err != nil {
+		return err
+	}
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	var opts reposerver.Opts
+	if metadata {
+		opts.MetadataGenerators = []reposerver.MetadataGenerator{
+			debian.GenerateRepositoryMetadata,
+			alpine.GenerateRepositoryMetadata,
+		}
+	}
+	s, err := reposerver.New(c, fileSpecs, opts)
+	if err != nil {
+		return err
+	}
+	metadataPaths := s.MetadataPaths()
+	sort.Strings(metadataPaths)
+	for _, f := range metadataPaths {
+		logrus.Debugf("Generated %q", f)
+	}
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           s,
+		ReadHeaderTimeout: time.Minute,
+	}
+	logrus.Infof("Serving %d files on %q", len(fileSpecs), listen)
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newTorrentCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "torrent",
+		Short:         "Manage BitTorrent",
+		Args:          cobra.NoArgs,
+		RunE:          needsSubcommand,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	cmd.AddCommand(
+		newTorrentCreateCommand(),
+		newTorrentPullCommand(),
+	)
+	return cmd
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/torrentutil"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newTorrentCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create [flags] SHA256SUMS...",
+		Short: "Create a torrent file from the cached files",
+		Long: `Create a torrent file from the cached files, with the names in the hash files.
+
+The HTTP(S) providers (default: the providers of the distro) ending with "/{{.Name}}" are added as the web seeds (BEP 19),
+so that the files can be fetched from the HTTP(S) servers when no peer is available.
+Additional web seeds can be specified with --web-seed, e.g., the URL of 'repro-get serve'.
+
+The files have to be downloaded in advance with 'repro-get download'.
+Use 'repro-get torrent pull' to download the files with the torrent file.`,
+		Example: "  repro-get torrent create --output=packages.torrent SHA256SUMS",
+		Args:    cobra.MinimumNArgs(1),
+		RunE:    torrentCreateAction,
+
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.StringP("output", "o", "", "Output file (required)")
+	flags.String("name", "repro-get", "Name of the torrent")
+	flags.StringSlice("tracker", nil, "Tracker URLs (optional, as the peers can be found with DHT)")
+	flags.StringSlice("web-seed", nil, "Additional web seed URLs that serve the files under the names in the hash files")
+	flags.Int64("piece-length", 0, "Piece length in bytes (default: chosen by the total size)")
+
+	return cmd
+}
+
+func torrentCreateAction(cmd *cobra.Command, args []string) error {
+	stdout := cmd.OutOrStdout()
+	flags := cmd.Flags()
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		return fmt.Errorf("needs --output")
+	}
+	var opts torrentutil.Opts
+	if opts.Name, err = flags.GetString("name"); err != nil {
+		return err
+	}
+	if opts.Trackers, err = flags.GetStringSlice("tracker"); err != nil {
+		return err
+	}
+	if opts.WebSeeds, err = flags.GetStringSlice("web-seed"); err != nil {
+		return err
+	}
+	if opts.PieceLength, err = flags.GetInt64("piece-length"); err != nil {
+		return err
+	}
+	providers, err := getProviders(cmd)
+	if err != nil {
+		return err
+	}
+	if len(providers) == 0 {
+		d, err := getDistro(cmd)
+		if err != nil {
+			return err
+		}
+		providers = d.Info().DefaultProviders
+	}
+	for _, f := range providers {
+		if ws, ok := webSeedFromProvider(f); ok {
+			opts.WebSeeds = append(opts.WebSeeds, ws)
+		} else {
+			logrus.Debugf("Provider %q cannot be used as a web seed", f)
+		}
+	}
+
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(args...)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(fileSpecs))
+	for name := range fileSpecs {
+		if filespec.ParsePseudoFilename(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	files := make([]torrentutil.File, len(names))
+	for i, name := range names {
+		sp := fileSpecs[name]
+		blob, err := c.BlobAbsPath(sp.Sum())
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(blob); err != nil {
+			return fmt.Errorf("uncached file? %q: %w (Hint: try 'repro-get download ...')", name, err)
+		}
+		files[i] = torrentutil.File{Path: name, Local: blob}
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	infoHash, err := torrentutil.Create(f, files, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", output, err)
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	logrus.Infof("Created %q (%d files)", output, len(files))
+	_, err = fmt.Fprintln(stdout, torrentutil.MagnetURI(infoHash, opts.Name, opts.WebSeeds))
+	return err
+}
+
+// webSeedFromProvider converts "http://deb.debian.org/debian/{{.Name}}" into "http://deb.debian.org/debian".
+func webSeedFromProvider(provider string) (string, bool) {
+	if !strings.HasPrefix(provider, "http://") && !strings.HasPrefix(provider, "https://") {
+		return "", false
+	}
+	ws := strings.TrimSuffix(provider, "/{{.Name}}")
+	if ws == provider || strings.Contains(ws, "{{") {
+		return "", false
+	}
+	return ws, true
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newTorrentPullCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pull [flags] TORRENT SHA256SUMS...",
+		Short: "Pull the files with BitTorrent into the cache",
+		Long: `Pull the files with BitTorrent into the cache.
+Needs 'aria2c' command (https://aria2.github.io/) to be installed.
+
+TORRENT is a torrent file or a magnet URI, typically created with 'repro-get torrent create'.
+The files are imported into the cache only after verifying the SHA256 in the hash files.
+The pulled files can be installed with 'repro-get install' then.`,
+		Example: "  repro-get torrent pull packages.torrent SHA256SUMS\n  repro-get install SHA256SUMS",
+		Args:    cobra.MinimumNArgs(2),
+		RunE:    torrentPullAction,
+
+		DisableFlagsInUseLine: true,
+	}
+
+	flags := cmd.Flags()
+	flags.String("name", "repro-get", "Name of the torrent")
+	flags.String("dir", "", "Directory to save the downloaded files, kept after pulling (default: a temporary directory)")
+	flags.Duration("seed-time", 0, "Duration to keep seeding the files after pulling")
+
+	return cmd
+}
+
+func torrentPullAction(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	stderr := cmd.ErrOrStderr()
+	flags := cmd.Flags()
+	torrent, hashFiles := args[0], args[1:]
+	name, err := flags.GetString("name")
+	if err != nil {
+		return err
+	}
+	dir, err := flags.GetString("dir")
+	if err != nil {
+		return err
+	}
+	seedTime, err := flags.GetDuration("seed-time")
+	if err != nil {
+		return err
+	}
+
+	c, err := newCache(cmd)
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := filespec.NewFromSHA256SUMSFiles(hashFiles...)
+	if err != nil {
+		return err
+	}
+	aria2cExe, err := exec.LookPath("aria2c")
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		dir, err = os.MkdirTemp("", "repro-get-torrent-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	aria2cCmd := exec.CommandContext(ctx, aria2cExe,
+		"--dir="+dir,
+		"--seed-time="+fmt.Sprintf("%g", seedTime.Minutes()),
+		"--summary-interval=0",
+		"--file-allocation=none",
+		"--follow-torrent=mem",
+		torrent)
+	aria2cCmd.Stdout = stderr
+	aria2cCmd.Stderr = stderr
+	logrus.Debugf("Running %v", aria2cCmd.Args)
+	if err = aria2cCmd.Run(); err != nil {
+		return fmt.Errorf("failed to execute %v: %w", aria2cCmd.Args, err)
+	}
+
+	root, err := securejoin.SecureJoin(dir, name)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(fileSpecs))
+	for f := range fileSpecs {
+		if filespec.ParsePseudoFilename(f) == nil {
+			names = append(names, f)
+		}
+	}
+	sort.Strings(names)
+	var imported, missing int
+	for _, f := range names {
+		sp := fileSpecs[f]
+		if cached, err := c.Cached(sp.Sum()); err != nil {
+			return err
+		} else if cached {
+			logrus.Debugf("Skipping to import %q (Already cached)", f)
+			continue
+		}
+		local, err := securejoin.SecureJoin(root, f)
+		if err != nil {
+			return err
+		}
+		r, err := os.Open(local)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logrus.Warnf("%q was not found in the torrent", f)
+				missing++
+				continue
+			}
+			return err
+		}
+		err = c.ImportVerifiedWithReader(r, sp.Sum())
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("failed to import %q: %w", f, err)
+		}
+		imported++
+	}
+	logrus.Infof("Imported %d files (%d files were not found in the torrent)", imported, missing)
+	if missing > 0 {
+		return fmt.Errorf("%d files were not found in the torrent", missing)
+	}
+	return nil
+}
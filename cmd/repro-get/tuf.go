@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/tuf"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// tufDistroConfig is the provider config of the distro, fetched from the TUF repository.
+type tufDistroConfig struct {
+	tuf.DistroProviderConfig
+	keyFiles []string // The local files of DistroProviderConfig.Keys
+}
+
+// tufDistroConfigCache caches the result of getTUFDistroConfig, as it is called multiple times in a command.
+var tufDistroConfigCache = make(map[string]*tufDistroConfig)
+
+// getTUFDistroConfig fetches the provider config of the distro from the TUF repository specified with --tuf-repository.
+// Returns nil when --tuf-repository is not specified, or the config has no entry for the distro.
+func getTUFDistroConfig(cmd *cobra.Command) (*tufDistroConfig, error) {
+	flags := cmd.Flags()
+	repo, err := flags.GetString("tuf-repository")
+	if err != nil {
+		return nil, err
+	}
+	if repo == "" {
+		return nil, nil
+	}
+	distroName, err := flags.GetString("distro")
+	if err != nil {
+		return nil, err
+	}
+	d, err := getDistroByName(distroName)
+	if err != nil {
+		return nil, err
+	}
+	distroName = d.Info().Name
+	if cached, ok := tufDistroConfigCache[distroName]; ok {
+		return cached, nil
+	}
+	initialRootFile, err := flags.GetString("tuf-root")
+	if err != nil {
+		return nil, err
+	}
+	var initialRoot []byte
+	if initialRootFile != "" {
+		if initialRoot, err = os.ReadFile(initialRootFile); err != nil {
+			return nil, err
+		}
+	}
+	target, err := flags.GetString("tuf-target")
+	if err != nil {
+		return nil, err
+	}
+	cacheStr, err := flags.GetString("cache")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := parseCacheSpec(cacheStr)
+	if err != nil {
+		return nil, err
+	}
+	repoDigest := sha256.Sum256([]byte(repo))
+	dir := filepath.Join(spec.dir, "tuf", hex.EncodeToString(repoDigest[:])[:16])
+
+	o := urlopener.New()
+	if err = configureURLOpener(cmd, o); err != nil {
+		return nil, err
+	}
+	ctx := cmd.Context()
+	c, err := tuf.New(o, repo, dir, initialRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the TUF metadata of %q (Hint: specify the initial root metadata with --tuf-root): %w", repo, err)
+	}
+	if err = c.Update(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update the TUF metadata of %q: %w", repo, err)
+	}
+	b, err := c.Target(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := tuf.ParseProviderConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	dcfg, ok := cfg.Distros[distroName]
+	if !ok {
+		logrus.Debugf("The provider config %q of %q has no entry for distro %q", target, repo, distroName)
+		tufDistroConfigCache[distroName] = nil
+		return nil, nil
+	}
+	res := &tufDistroConfig{DistroProviderConfig: dcfg}
+	for _, k := range dcfg.Keys {
+		b, err := c.Target(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		f, err := securejoin.SecureJoin(filepath.Join(dir, "targets"), k)
+		if err != nil {
+			return nil, err
+		}
+		if err = os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(f, b, 0644); err != nil {
+			return nil, err
+		}
+		res.keyFiles = append(res.keyFiles, f)
+	}
+	logrus.Debugf("Using the provider config %q (root version %d) of %q for distro %q", target, c.Root().Version, repo, distroName)
+	tufDistroConfigCache[distroName] = res
+	return res, nil
+}
+
+// getProviders returns the --provider flag value, or the providers in the TUF repository.
+// Returns nil when neither is specified, i.e., the default providers of the distro are used.
+func getProviders(cmd *cobra.Command) ([]string, error) {
+	providers, err := cmd.Flags().GetStringSlice("provider")
+	if err != nil {
+		return nil, err
+	}
+	if len(providers) > 0 {
+		return providers, nil
+	}
+	cfg, err := getTUFDistroConfig(cmd)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.Providers, nil
+}
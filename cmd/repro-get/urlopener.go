@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/spf13/cobra"
+)
+
+// configureURLOpener applies the --proxy, --tls-*, --auth*, --netrc, and --ipfs-gateway flags.
+func configureURLOpener(cmd *cobra.Command, o *urlopener.URLOpener) error {
+	flags := cmd.Flags()
+	proxyStrs, err := flags.GetStringSlice("proxy")
+	if err != nil {
+		return err
+	}
+	proxies := make([]urlopener.Proxy, len(proxyStrs))
+	for i, f := range proxyStrs {
+		p, err := urlopener.ParseProxy(f)
+		if err != nil {
+			return fmt.Errorf("failed to parse --proxy: %w", err)
+		}
+		proxies[i] = *p
+	}
+	o.SetProxies(proxies)
+
+	// The flags for the same host are merged into a single rule
+	var tlsRules []*urlopener.TLS
+	tlsRule := func(host string) *urlopener.TLS {
+		host = strings.ToLower(host)
+		for _, r := range tlsRules {
+			if r.Host == host {
+				return r
+			}
+		}
+		r := &urlopener.TLS{Host: host}
+		tlsRules = append(tlsRules, r)
+		return r
+	}
+	// cutHost cuts "[HOST=]VALUE"
+	cutHost := func(s string) (string, string) {
+		if host, v, ok := strings.Cut(s, "="); ok {
+			return host, v
+		}
+		return "", s
+	}
+	caCerts, err := flags.GetStringSlice("tls-ca-cert")
+	if err != nil {
+		return err
+	}
+	for _, f := range caCerts {
+		host, file := cutHost(f)
+		tlsRule(host).CACert = file
+	}
+	clientCerts, err := flags.GetStringSlice("tls-client-cert")
+	if err != nil {
+		return err
+	}
+	for _, f := range clientCerts {
+		host, v := cutHost(f)
+		cert, key, ok := strings.Cut(v, ":")
+		if !ok {
+			return fmt.Errorf("failed to parse --tls-client-cert: expected [HOST=]CERT_FILE:KEY_FILE, got %q", f)
+		}
+		r := tlsRule(host)
+		r.ClientCert, r.ClientKey = cert, key
+	}
+	insecureHosts, err := flags.GetStringSlice("tls-insecure-skip-verify")
+	if err != nil {
+		return err
+	}
+	for _, host := range insecureHosts {
+		tlsRule(host).InsecureSkipVerify = true
+	}
+	rules := make([]urlopener.TLS, len(tlsRules))
+	for i, r := range tlsRules {
+		rules[i] = *r
+	}
+	if err = o.SetTLS(rules); err != nil {
+		return err
+	}
+
+	credSources, err := credentialSources(cmd)
+	if err != nil {
+		return err
+	}
+	o.SetCredentialSources(credSources...)
+
+	ipfsGateway, err := flags.GetString("ipfs-gateway")
+	if err != nil {
+		return err
+	}
+	return o.SetIPFSGateway(ipfsGateway)
+}
+
+// credentialSources returns the credential sources in the order of --auth, --auth-helper, and --netrc.
+func credentialSources(cmd *cobra.Command) ([]urlopener.CredentialSource, error) {
+	flags := cmd.Flags()
+	var res []urlopener.CredentialSource
+	authStrs, err := flags.GetStringSlice("auth")
+	if err != nil {
+		return nil, err
+	}
+	if len(authStrs) > 0 {
+		creds := make([]urlopener.HostCredential, len(authStrs))
+		for i, f := range authStrs {
+			hc, err := urlopener.ParseHostCredential(f)
+			if err != nil {
+				// f is not printed, as it contains a secret
+				return nil, fmt.Errorf("failed to parse --auth: %w", err)
+			}
+			creds[i] = *hc
+		}
+		res = append(res, urlopener.StaticCredentials(creds))
+	}
+	helper, err := flags.GetString("auth-helper")
+	if err != nil {
+		return nil, err
+	}
+	if helper != "" {
+		res = append(res, urlopener.HelperCredentials(helper))
+	}
+	netrcFiles, err := flags.GetStringSlice("netrc")
+	if err != nil {
+		return nil, err
+	}
+	if !flags.Changed("netrc") && len(netrcFiles) == 0 {
+		netrcFiles = defaultNetrcFiles()
+	}
+	if len(netrcFiles) > 0 {
+		src, err := urlopener.NetrcCredentials(netrcFiles...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --netrc: %w", err)
+		}
+		res = append(res, src)
+	}
+	return res, nil
+}
+
+// defaultNetrcFiles returns $NETRC, or ~/.netrc if it exists.
+func defaultNetrcFiles() []string {
+	if f := os.Getenv("NETRC"); f != "" {
+		return []string{f}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	f := filepath.Join(home, ".netrc")
+	if _, err := os.Stat(f); err != nil {
+		return nil
+	}
+	return []string{f}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/drift"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// exitCodeDrift is the exit code of 'repro-get verify' when a drift is found.
+const exitCodeDrift = 2
+
+func newVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify [flags] SHA256SUMS...",
+		Short: "Verify that the installed packages match the hash files",
+		Long: `Verify that the installed packages match the hash files.
+The following drifts are reported:
+- missing:  pinned in the hash files, but not installed
+- mismatch: installed with a version that differs from the hash files
+- extra:    installed, but not pinned in the hash files (unless --ignore-extra)
+
+Only the package names and the versions are compared; the installed files are not re-hashed.
+
+Exit codes:
+- 0: no drift was found
+- 1: an error occurred
+- 2: a drift was found
+
+Debian, Ubuntu, Fedora, and Alpine only.`,
+		Example: "  repro-get verify SHA256SUMS-" + archutil.OCIArchDashVariant() + "\n" +
+			"  repro-get --distro=alpine verify --root=/mnt/rootfs --json SHA256SUMS-" + archutil.OCIArchDashVariant(),
+		Args: cobra.MinimumNArgs(1),
+		RunE: verifyAction,
+
+		ValidArgsFunction:     completeHashFiles,
+		DisableFlagsInUseLine: true,
+	}
+	flags := cmd.Flags()
+	flags.String("root", "/", "Root filesystem to inspect (Debian, Ubuntu, and Alpine only)")
+	flags.String("arch", archutil.OCIArchDashVariant(), "Architecture, such as \"amd64\", \"arm64\", and \"arm-v7\", for the hash files with the architecture sections (\"#repro-get:arch=...\")")
+	flags.Bool("ignore-extra", false, "Do not report the packages that are not pinned in the hash files")
+	flags.Bool("json", false, "Enable JSON output")
+	return cmd
+}
+
+func verifyAction(cmd *cobra.Command, args []string) error {
+	d, err := getDistro(cmd)
+	if err != nil {
+		return err
+	}
+	lister, ok := d.(distro.InstalledLister)
+	if !ok {
+		return fmt.Errorf("distro driver %q does not support listing the installed packages", d.Info().Name)
+	}
+	flags := cmd.Flags()
+	root, err := flags.GetString("root")
+	if err != nil {
+		return err
+	}
+	arch, err := flags.GetString("arch")
+	if err != nil {
+		return err
+	}
+	ignoreExtra, err := flags.GetBool("ignore-extra")
+	if err != nil {
+		return err
+	}
+	jsonFlag, err := jsonOutput(cmd)
+	if err != nil {
+		return err
+	}
+	fileSpecs, err := newVerifiedFileSpecs(cmd, args, arch)
+	if err != nil {
+		return err
+	}
+	installed, err := lister.InstalledPackages(cmd.Context(), root)
+	if err != nil {
+		return err
+	}
+	report := drift.Check(fileSpecs, installed)
+	if ignoreExtra {
+		drifts := []drift.Entry{}
+		for _, e := range report.Drifts {
+			if e.Kind != drift.KindExtra {
+				drifts = append(drifts, e)
+			}
+		}
+		report.Drifts = drifts
+	}
+
+	w := cmd.OutOrStdout()
+	if jsonFlag {
+		b, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintln(w, string(b)); err != nil {
+			return err
+		}
+	} else {
+		for _, e := range report.Drifts {
+			if _, err = fmt.Fprintln(w, e.String()); err != nil {
+				return err
+			}
+		}
+	}
+	if len(report.Drifts) > 0 {
+		return &exitError{
+			code: exitCodeDrift,
+			err:  fmt.Errorf("found %d drifts (%d packages matched)", len(report.Drifts), report.Matched),
+		}
+	}
+	logrus.Infof("No drift was found (%d packages matched)", report.Matched)
+	return nil
+}
@@ -32,3 +32,23 @@ func Split(pkgDashVer string) (*APK, error) {
 	}
 	return nil, fmt.Errorf("failed to split %q into the package name and the version string", pkgDashVer)
 }
+
+// ArchFromOCI converts an OCI architecture string such as "arm64" to an apk architecture string such as "aarch64".
+func ArchFromOCI(ociArchDashVariant string) (string, error) {
+	switch ociArchDashVariant {
+	case "amd64":
+		return "x86_64", nil
+	case "arm64":
+		return "aarch64", nil
+	case "arm-v7":
+		return "armv7", nil
+	case "arm-v6":
+		return "armhf", nil
+	case "386":
+		return "x86", nil
+	case "ppc64le", "s390x", "riscv64":
+		return ociArchDashVariant, nil
+	default:
+		return "", fmt.Errorf("unsupported architecture %q", ociArchDashVariant)
+	}
+}
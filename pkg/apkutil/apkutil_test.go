@@ -15,3 +15,47 @@ func TestParseFilename(t *testing.T) {
 	}
 	assert.DeepEqual(t, expected, got)
 }
+
+func TestArchFromOCI(t *testing.T) {
+	for oci, expected := range map[string]string{
+		"amd64":  "x86_64",
+		"arm64":  "aarch64",
+		"arm-v7": "armv7",
+		"386":    "x86",
+	} {
+		got, err := ArchFromOCI(oci)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, got)
+	}
+	_, err := ArchFromOCI("wasm")
+	assert.ErrorContains(t, err, "unsupported architecture")
+}
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0-r0", "1.0-r1", -1},
+		{"1.0.1", "1.0", 1},
+		{"1.10", "1.9", 1},
+		{"1.1.1q-r0", "1.1.1p-r0", 1},
+		{"1.0_rc1", "1.0", -1},
+		{"1.0_p1", "1.0", 1},
+		{"1.0_alpha2", "1.0_beta1", -1},
+		{"20220614-r0", "20211220-r0", 1},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, CompareVersions(tc.a, tc.b), "%s vs %s", tc.a, tc.b)
+		assert.Equal(t, -tc.expected, CompareVersions(tc.b, tc.a), "%s vs %s", tc.b, tc.a)
+	}
+}
+
+func TestSatisfiesConstraint(t *testing.T) {
+	assert.Assert(t, SatisfiesConstraint("1.2.3-r0", ">=", "1.2"))
+	assert.Assert(t, !SatisfiesConstraint("1.2.3-r0", "<", "1.2"))
+	assert.Assert(t, SatisfiesConstraint("1.2.3-r0", "=", "1.2.3-r0"))
+	assert.Assert(t, SatisfiesConstraint("1.2.3-r0", "~", "1.2"))
+	assert.Assert(t, !SatisfiesConstraint("1.3.0-r0", "~", "1.2"))
+}
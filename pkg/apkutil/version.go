@@ -0,0 +1,129 @@
+package apkutil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// suffixRanks are the ranks of the version suffixes such as "_rc1".
+// The pre-release suffixes are ranked lower than no suffix (noSuffixRank).
+var suffixRanks = map[string]int{
+	"alpha": 0,
+	"beta":  1,
+	"pre":   2,
+	"rc":    3,
+	"cvs":   5,
+	"svn":   6,
+	"git":   7,
+	"hg":    8,
+	"p":     9,
+}
+
+const noSuffixRank = 4
+
+type version struct {
+	numbers  []int
+	letter   byte
+	suffixes [][2]int // rank, number
+	revision int
+}
+
+func parseVersion(s string) version {
+	var v version
+	s, rev, ok := strings.Cut(s, "-r")
+	if ok {
+		v.revision, _ = strconv.Atoi(rev)
+	}
+	s, suffixes, _ := strings.Cut(s, "_")
+	for _, f := range strings.Split(s, ".") {
+		digits := strings.TrimRightFunc(f, func(r rune) bool { return r < '0' || r > '9' })
+		n, _ := strconv.Atoi(digits)
+		v.numbers = append(v.numbers, n)
+		if len(f) > len(digits) {
+			v.letter = f[len(digits)]
+		}
+	}
+	if suffixes != "" {
+		for _, f := range strings.Split(suffixes, "_") {
+			name := strings.TrimRightFunc(f, func(r rune) bool { return '0' <= r && r <= '9' })
+			rank, ok := suffixRanks[name]
+			if !ok {
+				rank = noSuffixRank
+			}
+			n, _ := strconv.Atoi(f[len(name):])
+			v.suffixes = append(v.suffixes, [2]int{rank, n})
+		}
+	}
+	return v
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CompareVersions compares apk version strings such as "1.1.1q-r0".
+// Returns -1, 0, or 1.
+//
+// The comparison is a simplified version of `apk version -t`.
+func CompareVersions(a, b string) int {
+	va, vb := parseVersion(a), parseVersion(b)
+	for i := 0; i < len(va.numbers) && i < len(vb.numbers); i++ {
+		if c := compareInt(va.numbers[i], vb.numbers[i]); c != 0 {
+			return c
+		}
+	}
+	if c := compareInt(len(va.numbers), len(vb.numbers)); c != 0 {
+		return c
+	}
+	if c := compareInt(int(va.letter), int(vb.letter)); c != 0 {
+		return c
+	}
+	for i := 0; i < len(va.suffixes) || i < len(vb.suffixes); i++ {
+		sa, sb := [2]int{noSuffixRank, 0}, [2]int{noSuffixRank, 0}
+		if i < len(va.suffixes) {
+			sa = va.suffixes[i]
+		}
+		if i < len(vb.suffixes) {
+			sb = vb.suffixes[i]
+		}
+		if c := compareInt(sa[0], sb[0]); c != 0 {
+			return c
+		}
+		if c := compareInt(sa[1], sb[1]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(va.revision, vb.revision)
+}
+
+// SatisfiesConstraint returns true if the version satisfies the constraint operator (such as ">=") and the version.
+// The fuzzy operator "~" matches the version prefix.
+func SatisfiesConstraint(ver, op, constraintVer string) bool {
+	if strings.Contains(op, "~") {
+		return strings.HasPrefix(ver, constraintVer)
+	}
+	c := CompareVersions(ver, constraintVer)
+	switch op {
+	case "=":
+		return c == 0
+	case ">=", "=>":
+		return c >= 0
+	case "<=", "=<":
+		return c <= 0
+	case ">":
+		return c > 0
+	case "<":
+		return c < 0
+	case "><":
+		return c != 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,168 @@
+// Package attest generates the in-toto attestations of the hash files, with the SLSA provenance predicate.
+//
+// The attestation describes the hash files (as the subjects), the repository indexes that were used for generating them,
+// the providers, and the version of repro-get, so that the consumers can verify where the pinned package set came from.
+//
+// https://github.com/in-toto/attestation/blob/main/spec/v1/statement.md
+// https://slsa.dev/spec/v1.0/provenance
+package attest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/lockfile"
+)
+
+const (
+	// StatementType is the type of the in-toto statement.
+	StatementType = "https://in-toto.io/Statement/v1"
+	// PredicateTypeSLSAProvenance is the predicate type of the SLSA provenance.
+	PredicateTypeSLSAProvenance = "https://slsa.dev/provenance/v1"
+	// BuildType is the build type of the SLSA provenance generated by repro-get.
+	BuildType = "https://github.com/reproducible-containers/repro-get/attest/v1"
+	// BuilderID is the builder ID of the SLSA provenance generated by repro-get.
+	BuilderID = "https://github.com/reproducible-containers/repro-get"
+)
+
+// Statement is an in-toto statement.
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+// Subject is a subject of a Statement.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"` // key: "sha256"
+}
+
+// Provenance is the SLSA provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition is the inputs of the build.
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"` // The repository indexes
+}
+
+// ExternalParameters is the external parameters of the BuildType.
+type ExternalParameters struct {
+	Distro    string               `json:"distro,omitempty"` // "debian"
+	Providers []string             `json:"providers,omitempty"`
+	HashFiles []HashFileParameters `json:"hashFiles"`
+}
+
+// HashFileParameters is the directives of a hash file.
+type HashFileParameters struct {
+	Name     string `json:"name"`               // "SHA256SUMS-amd64"
+	Snapshot string `json:"snapshot,omitempty"` // "20221101T000000Z"
+	PPA      string `json:"ppa,omitempty"`      // "deadsnakes/ppa"
+	Packages int    `json:"packages"`           // The number of the packages
+}
+
+// ResourceDescriptor is an in-toto resource descriptor.
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// RunDetails is the details of the build.
+type RunDetails struct {
+	Builder  Builder        `json:"builder"`
+	Metadata *BuildMetadata `json:"metadata,omitempty"`
+}
+
+// Builder is the builder, i.e., repro-get.
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"` // key: "repro-get"
+}
+
+// BuildMetadata is the metadata of the build.
+type BuildMetadata struct {
+	StartedOn string `json:"startedOn,omitempty"`
+}
+
+// Opts is the options for New.
+type Opts struct {
+	Distro      string    // The distro name, such as "debian"
+	Providers   []string  // The providers, such as "http://deb.debian.org/debian/{{.Name}}"
+	Created     time.Time // Recorded as the "startedOn" metadata, unless zero
+	ToolVersion string    // The version of repro-get, such as "v0.3.0"
+}
+
+// New returns the statement of the hash files.
+// The hash files may be structured lockfiles too (see lockfile.Read).
+func New(hashFiles []string, opts Opts) (*Statement, error) {
+	st := &Statement{
+		Type:          StatementType,
+		PredicateType: PredicateTypeSLSAProvenance,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: ExternalParameters{
+					Distro:    opts.Distro,
+					Providers: opts.Providers,
+				},
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{
+					ID: BuilderID,
+				},
+			},
+		},
+	}
+	if opts.ToolVersion != "" {
+		st.Predicate.RunDetails.Builder.Version = map[string]string{"repro-get": opts.ToolVersion}
+	}
+	if !opts.Created.IsZero() {
+		st.Predicate.RunDetails.Metadata = &BuildMetadata{StartedOn: opts.Created.UTC().Format(time.RFC3339)}
+	}
+	seenIndexes := make(map[string]bool)
+	for _, f := range hashFiles {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		lf, err := lockfile.Read(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", f, err)
+		}
+		name := filepath.Base(f)
+		sum := sha256.Sum256(b)
+		st.Subject = append(st.Subject, Subject{
+			Name:   name,
+			Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		})
+		params := &st.Predicate.BuildDefinition.ExternalParameters
+		params.HashFiles = append(params.HashFiles, HashFileParameters{
+			Name:     name,
+			Snapshot: lf.Snapshot,
+			PPA:      lf.PPA,
+			Packages: len(lf.Packages),
+		})
+		for _, idx := range lf.Indexes {
+			if k := idx.SHA256 + "  " + idx.Name; !seenIndexes[k] {
+				seenIndexes[k] = true
+				st.Predicate.BuildDefinition.ResolvedDependencies = append(st.Predicate.BuildDefinition.ResolvedDependencies, ResourceDescriptor{
+					Name:   idx.Name,
+					Digest: map[string]string{"sha256": idx.SHA256},
+				})
+			}
+		}
+	}
+	return st, nil
+}
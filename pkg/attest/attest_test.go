@@ -0,0 +1,68 @@
+package attest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNew(t *testing.T) {
+	const content = `#repro-get:snapshot=20221101T000000Z
+#repro-get:index=1111111111111111111111111111111111111111111111111111111111111111  deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+`
+	dir := t.TempDir()
+	amd64 := filepath.Join(dir, "SHA256SUMS-amd64")
+	arm64 := filepath.Join(dir, "SHA256SUMS-arm64")
+	assert.NilError(t, os.WriteFile(amd64, []byte(content), 0644))
+	assert.NilError(t, os.WriteFile(arm64, []byte(content), 0644))
+
+	opts := Opts{
+		Distro:      "debian",
+		Providers:   []string{"http://deb.debian.org/debian/{{.Name}}"},
+		Created:     time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC),
+		ToolVersion: "v0.3.0",
+	}
+	st, err := New([]string{amd64, arm64}, opts)
+	assert.NilError(t, err)
+	sum := sha256.Sum256([]byte(content))
+	assert.DeepEqual(t, &Statement{
+		Type: StatementType,
+		Subject: []Subject{
+			{Name: "SHA256SUMS-amd64", Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])}},
+			{Name: "SHA256SUMS-arm64", Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])}},
+		},
+		PredicateType: PredicateTypeSLSAProvenance,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: ExternalParameters{
+					Distro:    "debian",
+					Providers: []string{"http://deb.debian.org/debian/{{.Name}}"},
+					HashFiles: []HashFileParameters{
+						{Name: "SHA256SUMS-amd64", Snapshot: "20221101T000000Z", Packages: 1},
+						{Name: "SHA256SUMS-arm64", Snapshot: "20221101T000000Z", Packages: 1},
+					},
+				},
+				ResolvedDependencies: []ResourceDescriptor{
+					{
+						Name:   "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages",
+						Digest: map[string]string{"sha256": "1111111111111111111111111111111111111111111111111111111111111111"},
+					},
+				},
+			},
+			RunDetails: RunDetails{
+				Builder:  Builder{ID: BuilderID, Version: map[string]string{"repro-get": "v0.3.0"}},
+				Metadata: &BuildMetadata{StartedOn: "2022-11-01T00:00:00Z"},
+			},
+		},
+	}, st)
+
+	_, err = New([]string{filepath.Join(dir, "nonexistent")}, opts)
+	assert.Assert(t, os.IsNotExist(err))
+}
@@ -0,0 +1,165 @@
+// Package builder invokes the container image builders (docker buildx, nerdctl, podman, and buildah).
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Builder is a container image builder.
+type Builder string
+
+const (
+	Auto    = Builder("auto") // Detected with Detect
+	Docker  = Builder("docker")
+	Nerdctl = Builder("nerdctl")
+	Podman  = Builder("podman")
+	Buildah = Builder("buildah")
+)
+
+// Builders is the list of the builders, in the order of the preference of Detect.
+var Builders = []Builder{Docker, Nerdctl, Podman, Buildah}
+
+// Validate returns an error if the builder is unknown.
+func (b Builder) Validate() error {
+	if b == Auto {
+		return nil
+	}
+	for _, f := range Builders {
+		if b == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown builder %q, expected one of %v", b, Builders)
+}
+
+// Detect returns the first builder found in $PATH.
+func Detect() (Builder, error) {
+	for _, b := range Builders {
+		if _, err := exec.LookPath(string(b)); err == nil {
+			return b, nil
+		}
+	}
+	return "", fmt.Errorf("no builder was found in $PATH, install one of %v", Builders)
+}
+
+// Secret is a build secret, such as "id=netrc,src=/home/user/.netrc".
+type Secret struct {
+	ID  string
+	Src string
+}
+
+// ParseSecret parses the secret in the form of "id=ID,src=FILE".
+func ParseSecret(s string) (*Secret, error) {
+	var secret Secret
+	for _, f := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid secret %q, expected \"id=ID,src=FILE\"", s)
+		}
+		switch k {
+		case "id":
+			secret.ID = v
+		case "src", "source":
+			secret.Src = v
+		default:
+			return nil, fmt.Errorf("invalid secret %q: unknown key %q", s, k)
+		}
+	}
+	if secret.ID == "" || secret.Src == "" {
+		return nil, fmt.Errorf("invalid secret %q, expected \"id=ID,src=FILE\"", s)
+	}
+	return &secret, nil
+}
+
+// Opts is the options for Command.
+type Opts struct {
+	Context   string   // Context directory
+	File      string   // Dockerfile, relative to the current directory (not to Context)
+	Platforms []string // Such as "linux/amd64" and "linux/arm/v7"
+	Tags      []string
+	BuildArgs []string // "KEY=VALUE"
+	Secrets   []Secret
+	Output    string   // Such as "type=local,dest=DIR", in the syntax of `docker buildx build --output`
+	ExtraArgs []string // Appended before the context directory
+}
+
+// Command returns the command line for building the image.
+// The builder must not be Auto.
+//
+// The cache mounts (`RUN --mount=type=cache`) and the secret mounts (`RUN --mount=type=secret`) of the Dockerfile
+// are supported by all the builders, as docker buildx and nerdctl use BuildKit, and podman and buildah implement them.
+func (b Builder) Command(opts Opts) ([]string, error) {
+	if opts.Context == "" {
+		return nil, errors.New("no context directory was specified")
+	}
+	var args []string
+	switch b {
+	case Docker:
+		args = []string{string(b), "buildx", "build"}
+	case Nerdctl, Podman, Buildah:
+		args = []string{string(b), "build"}
+	case Auto:
+		return nil, errors.New("the builder has to be detected")
+	default:
+		return nil, b.Validate()
+	}
+	if opts.File != "" {
+		args = append(args, "--file="+opts.File)
+	}
+	if len(opts.Platforms) > 0 {
+		args = append(args, "--platform="+strings.Join(opts.Platforms, ","))
+	}
+	if len(opts.Platforms) > 1 && (b == Podman || b == Buildah) && len(opts.Tags) > 0 {
+		// The multi-platform image is created as a manifest list, only with --manifest
+		if len(opts.Tags) > 1 {
+			return nil, fmt.Errorf("%s needs exactly one tag for building the multi-platform image, got %v", b, opts.Tags)
+		}
+		args = append(args, "--manifest="+opts.Tags[0])
+	} else {
+		for _, f := range opts.Tags {
+			args = append(args, "--tag="+f)
+		}
+	}
+	for _, f := range opts.BuildArgs {
+		args = append(args, "--build-arg="+f)
+	}
+	for _, f := range opts.Secrets {
+		args = append(args, "--secret=id="+f.ID+",src="+f.Src)
+	}
+	if opts.Output != "" {
+		args = append(args, "--output="+opts.Output)
+	}
+	args = append(args, opts.ExtraArgs...)
+	return append(args, opts.Context), nil
+}
+
+// Build builds the image.
+func (b Builder) Build(ctx context.Context, opts Opts) error {
+	args, err := b.Command(opts)
+	if err != nil {
+		return err
+	}
+	cmdName, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, cmdName, args[1:]...)
+	if b == Podman || b == Buildah {
+		// The SHELL instruction is ignored for the OCI image format
+		cmd.Env = append(os.Environ(), "BUILDAH_FORMAT=docker")
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %v: %w", cmd.Args, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package builder
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCommand(t *testing.T) {
+	opts := Opts{
+		Context:   ".",
+		File:      "Dockerfile",
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+		Tags:      []string{"example.com/foo:latest"},
+		BuildArgs: []string{"SOURCE_DATE_EPOCH=1639958400"},
+		Secrets:   []Secret{{ID: "netrc", Src: "/home/user/.netrc"}},
+		ExtraArgs: []string{"--no-cache"},
+	}
+	args, err := Docker.Command(opts)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"docker", "buildx", "build", "--file=Dockerfile", "--platform=linux/amd64,linux/arm64",
+		"--tag=example.com/foo:latest", "--build-arg=SOURCE_DATE_EPOCH=1639958400", "--secret=id=netrc,src=/home/user/.netrc",
+		"--no-cache", "."}, args)
+
+	args, err = Buildah.Command(Opts{Context: "ctx", Output: "type=local,dest=out"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"buildah", "build", "--output=type=local,dest=out", "ctx"}, args)
+
+	args, err = Podman.Command(opts)
+	assert.NilError(t, err)
+	assert.Equal(t, "--manifest=example.com/foo:latest", args[4])
+	opts.Tags = append(opts.Tags, "example.com/foo:v1")
+	_, err = Podman.Command(opts)
+	assert.ErrorContains(t, err, "exactly one tag")
+
+	_, err = Auto.Command(opts)
+	assert.ErrorContains(t, err, "detected")
+	_, err = Builder("kaniko").Command(opts)
+	assert.ErrorContains(t, err, "unknown builder")
+	_, err = Podman.Command(Opts{})
+	assert.ErrorContains(t, err, "context")
+}
+
+func TestParseSecret(t *testing.T) {
+	s, err := ParseSecret("id=netrc,src=/home/user/.netrc")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Secret{ID: "netrc", Src: "/home/user/.netrc"}, s)
+
+	_, err = ParseSecret("id=netrc")
+	assert.ErrorContains(t, err, "invalid secret")
+	_, err = ParseSecret("id=netrc,src=foo,type=env")
+	assert.ErrorContains(t, err, "unknown key")
+}
@@ -4,11 +4,24 @@
 //
 //   - blobs/sha256/*.tmp:    tmp files
 //
+//   - blobs/sha256/<SHA256>.partial.tmp: partially downloaded blobs, for resuming the downloads
+//
 //   - blobs/sha256/<SHA256>: verified blobs
 //
 //   - urls/sha256/<SHA256> : URL of the blob (optional)
 //
 //   - digests/by-url-sha256/<SHA256-OF-URL> : digest of the blob (optional)
+//
+//   - recipes/sha256/<SHA256>: list of the chunks of the blob, in the chunked mode (see SetChunked)
+//
+//   - chunks/sha256/<SHA256>: chunks, in the chunked mode
+//
+//   - provenance/sha256/<SHA256>: provider and URL that actually served the blob (optional)
+//
+// The blobs of the SHA512 sums are stored in "blobs/sha512/<SHA512>", with "urls/sha512/<SHA512>", and so on.
+// The sha256sum arguments of the methods may be SHA512 sums prefixed with "sha512:" (see sha256sums.ParseSum).
+//
+// The modification time of the blob (or the recipe) is used as the last use of the blob, for GC.
 package cache
 
 import (
@@ -21,11 +34,13 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/containerd/continuity/fs"
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/opencontainers/go-digest"
 	"github.com/reproducible-containers/repro-get/pkg/progressbar"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"github.com/reproducible-containers/repro-get/pkg/urlopener"
 	"github.com/sirupsen/logrus"
 )
@@ -43,7 +58,13 @@ func New(dir string) (*Cache, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
-	for _, f := range []string{BlobsSHA256RelPath, URLsSHA256RelPath, ReverseURLRelPath} {
+	subDirs := []string{ReverseURLRelPath, ChunksSHA256RelPath}
+	for _, alg := range sumAlgorithms {
+		for _, f := range []string{BlobsSHA256RelPath, URLsSHA256RelPath, RecipesSHA256RelPath, ProvenanceSHA256RelPath} {
+			subDirs = append(subDirs, algRelPath(f, alg))
+		}
+	}
+	for _, f := range subDirs {
 		subDir := filepath.Join(dir, f) // no need to use securejoin (const)
 		if err := os.MkdirAll(subDir, 0755); err != nil {
 			return nil, err
@@ -53,30 +74,164 @@ func New(dir string) (*Cache, error) {
 		dir:       dir,
 		urlOpener: urlopener.New(),
 	}
+	var err error
+	if c.index, err = openIndex(dir); err != nil {
+		// The cache still works without the index, e.g., when the dir is read-only
+		logrus.WithError(err).Warnf("Failed to open the index of the cache %q", dir)
+		c.index = nil
+	}
 	return c, nil
 }
 
 type Cache struct {
-	dir       string
-	urlOpener *urlopener.URLOpener
+	dir          string
+	readOnlyDirs []string
+	urlOpener    *urlopener.URLOpener
+	remote       Remote
+	writeThrough bool
+	index        *index // nil if unavailable
+	urlTTL       time.Duration
+	hooks        []Hook
+	chunked      bool
+	mismatchDir  string
+}
+
+// URLOpener returns the URL opener used for downloading the blobs.
+func (c *Cache) URLOpener() *urlopener.URLOpener {
+	return c.urlOpener
 }
 
+// Dir returns the primary (writable) cache dir.
 func (c *Cache) Dir() string {
 	return c.dir
 }
 
+// AddReadOnlyDir adds a read-only cache dir, such as a shared cache on NFS.
+// The blobs and the URL files missing in the primary dir are looked up in the read-only dirs,
+// in the order of addition.
+// The read-only dirs are never written, and are not subject to GC, Prune, and Export.
+func (c *Cache) AddReadOnlyDir(dir string) error {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("expected %q to be a directory", dir)
+	}
+	c.readOnlyDirs = append(c.readOnlyDirs, dir)
+	return nil
+}
+
+// ReadOnlyDirs returns the read-only cache dirs.
+func (c *Cache) ReadOnlyDirs() []string {
+	return c.readOnlyDirs
+}
+
+// lookupReadOnly looks up rel in the read-only dirs.
+func (c *Cache) lookupReadOnly(rel string) (string, bool) {
+	for _, dir := range c.readOnlyDirs {
+		abs := filepath.Join(dir, rel) // no need to use securejoin (rel is verified)
+		if _, err := os.Stat(abs); err == nil {
+			return abs, true
+		}
+	}
+	return "", false
+}
+
+// readFile reads rel in the primary dir, or in the read-only dirs.
+func (c *Cache) readFile(rel string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(c.dir, rel)) // no need to use securejoin (rel is verified)
+	if errors.Is(err, os.ErrNotExist) {
+		if abs, ok := c.lookupReadOnly(rel); ok {
+			return os.ReadFile(abs)
+		}
+	}
+	return b, err
+}
+
+// checkURLTTL returns an error that wraps os.ErrNotExist when fetchedAt is older than the URL TTL.
+func (c *Cache) checkURLTTL(u *url.URL, fetchedAt time.Time) error {
+	if c.urlTTL > 0 && time.Since(fetchedAt) > c.urlTTL {
+		return fmt.Errorf("%w: the mapping for %q has expired (fetched at %s, TTL %s)",
+			os.ErrNotExist, u.Redacted(), fetchedAt.Format(time.RFC3339), c.urlTTL)
+	}
+	return nil
+}
+
+// sumAlgorithms are the digest algorithms of the sums. See sha256sums.ParseSum.
+var sumAlgorithms = []digest.Algorithm{digest.SHA256, digest.SHA512, sha256sums.BLAKE3}
+
+// algRelPath returns the dir for the algorithm, such as "blobs/sha512" for "blobs/sha256".
+func algRelPath(sha256RelPath string, alg digest.Algorithm) string {
+	return path.Join(path.Dir(sha256RelPath), alg.String())
+}
+
+// sumRelPath returns a clean relative path like "blobs/sha256/<SHA256>" and "blobs/sha512/<SHA512>".
+func sumRelPath(sha256RelPath, sum string) (string, error) {
+	d, err := sha256sums.ParseSum(sum)
+	if err != nil {
+		return "", err
+	}
+	return securejoin.SecureJoin(algRelPath(sha256RelPath, d.Algorithm()), d.Encoded())
+}
+
+// digesterOf returns a digester of the algorithm of the sum.
+func digesterOf(sum string) (digest.Digester, error) {
+	d, err := sha256sums.ParseSum(sum)
+	if err != nil {
+		return nil, err
+	}
+	return sha256sums.NewDigester(d.Algorithm()), nil
+}
+
 // BlobRelPath returns a clean relative path like "blobs/sha256/<SHA256>".
 // The caller should append this path to c.Dir().
 // The returned path may not exist.
 // If it exists, its digest must have been already verified.
 func (c *Cache) BlobRelPath(sha256sum string) (string, error) {
-	if err := digest.SHA256.Validate(sha256sum); err != nil {
-		return "", err
-	}
-	return securejoin.SecureJoin(BlobsSHA256RelPath, sha256sum)
+	return sumRelPath(BlobsSHA256RelPath, sha256sum)
 }
 
+// BlobAbsPath returns the absolute path of the blob.
+// When the blob is missing in the primary dir but present in a read-only dir,
+// the path in the read-only dir is returned.
+// When the blob is chunked, the blob is reassembled into the primary dir.
+// Otherwise the path in the primary dir is returned, and it may not exist.
 func (c *Cache) BlobAbsPath(sha256sum string) (string, error) {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return blob, err
+	}
+	if _, err := os.Stat(blob); errors.Is(err, os.ErrNotExist) {
+		rel, _ := c.BlobRelPath(sha256sum) // already verified
+		if roBlob, ok := c.lookupReadOnly(rel); ok {
+			return roBlob, nil
+		}
+		if _, _, err := c.lookupRecipe(sha256sum); err == nil {
+			return c.materialize(sha256sum)
+		}
+	}
+	return blob, nil
+}
+
+// LookupBlobAbsPath is similar to BlobAbsPath, but does not reassemble the chunked blob.
+// The path in the primary dir is returned for the chunked blob, and for the missing blob.
+func (c *Cache) LookupBlobAbsPath(sha256sum string) (string, error) {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return blob, err
+	}
+	if _, err := os.Stat(blob); errors.Is(err, os.ErrNotExist) {
+		rel, _ := c.BlobRelPath(sha256sum) // already verified
+		if roBlob, ok := c.lookupReadOnly(rel); ok {
+			return roBlob, nil
+		}
+	}
+	return blob, nil
+}
+
+// primaryBlobAbsPath returns the absolute path of the blob in the primary dir.
+func (c *Cache) primaryBlobAbsPath(sha256sum string) (string, error) {
 	rel, err := c.BlobRelPath(sha256sum)
 	if err != nil {
 		return "", err
@@ -85,10 +240,7 @@ func (c *Cache) BlobAbsPath(sha256sum string) (string, error) {
 }
 
 func (c *Cache) URLFileRelPath(sha256sum string) (string, error) {
-	if err := digest.SHA256.Validate(sha256sum); err != nil {
-		return "", err
-	}
-	return securejoin.SecureJoin(URLsSHA256RelPath, sha256sum)
+	return sumRelPath(URLsSHA256RelPath, sha256sum)
 }
 
 func (c *Cache) URLFileAbsPath(sha256sum string) (string, error) {
@@ -114,31 +266,96 @@ func (c *Cache) ReverseURLFileAbsPath(u *url.URL) (string, error) {
 }
 
 func (c *Cache) Cached(sha256sum string) (bool, error) {
-	blob, err := c.BlobAbsPath(sha256sum)
-	if err != nil {
-		return false, err
+	if ok, err := c.hasPrimary(sha256sum); err != nil || ok {
+		return ok, err
 	}
-	if _, err := os.Stat(blob); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return false, nil
-		}
-		return false, err
+	rel, _ := c.BlobRelPath(sha256sum) // already verified
+	if _, ok := c.lookupReadOnly(rel); ok {
+		return true, nil
 	}
-	return true, nil
+	rel, _ = c.recipeRelPath(sha256sum) // already verified
+	_, ok := c.lookupReadOnly(rel)
+	return ok, nil
 }
 
 func (c *Cache) Ensure(ctx context.Context, u *url.URL, sha256sum string) error {
-	blob, err := c.BlobAbsPath(sha256sum) // also verifies sha256sum string representation
+	blob, err := c.primaryBlobAbsPath(sha256sum) // also verifies sha256sum string representation
 	if err != nil {
 		return err
 	}
-	if _, err := os.Stat(blob); err == nil {
+	if ok, err := c.hasPrimary(sha256sum); err != nil {
+		return err
+	} else if ok {
 		// sha256sum is verified on the initial caching
+		if err = c.Touch(sha256sum); err != nil {
+			logrus.WithError(err).Warnf("Failed to record the last use of %q", sha256sum)
+		}
+		return nil
+	}
+	if cached, err := c.Cached(sha256sum); err != nil {
+		return err
+	} else if cached {
+		logrus.Debugf("Using %q in the read-only cache", sha256sum)
+		c.countStats(true)
 		return nil
-	} else if !errors.Is(err, os.ErrNotExist) {
+	}
+	c.countStats(false)
+
+	if c.remote != nil {
+		if err = c.ensureFromRemote(ctx, blob, sha256sum); err == nil {
+			c.recordProvenance(ctx, sha256sum, u, c.remote.String())
+			return c.writeURLFiles(sha256sum, u)
+		} else if errors.Is(err, os.ErrNotExist) {
+			logrus.Debugf("Not found in the remote cache %q: %q", c.remote, sha256sum)
+		} else {
+			logrus.WithError(err).Warnf("Failed to fetch %q from the remote cache %q", sha256sum, c.remote)
+		}
+	}
+
+	tee, err := c.ensureFromURL(ctx, u, blob, sha256sum)
+	if err != nil {
+		return err
+	}
+	if err := c.writeURLFiles(sha256sum, u); err != nil {
+		if tee != nil {
+			tee.abort(err)
+		}
 		return err
 	}
+	c.recordProvenance(ctx, sha256sum, u, "")
+	if c.remote != nil && c.writeThrough {
+		if tee != nil {
+			err = tee.commit()
+		} else {
+			err = c.putToRemote(ctx, c.remote, sha256sum)
+		}
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to store %q into the remote cache %q", sha256sum, c.remote)
+		}
+	}
+	return nil
+}
+
+// blobAdded indexes the blob that has been added to the primary dir, and fires EventImport.
+// In the chunked mode, the blob is split into the chunks.
+func (c *Cache) blobAdded(sha256sum, blob string) {
+	st, err := os.Stat(blob)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to stat %q", blob)
+		return
+	}
+	c.indexBlob(sha256sum, st.Size())
+	c.fire(Event{Type: EventImport, SHA256: sha256sum, Size: st.Size()})
+	if c.chunked {
+		if _, err = c.chunkBlob(sha256sum); err != nil {
+			logrus.WithError(err).Warnf("Failed to chunk %q, keeping the plain file", sha256sum)
+		}
+	}
+}
 
+// store stores the content of r as the blob, after verifying sha256sum.
+// sz may be negative when the size is unknown.
+func (c *Cache) store(ctx context.Context, blob, sha256sum string, r io.Reader, sz int64) error {
 	tmpW, err := os.CreateTemp(filepath.Dir(blob), ".download-*.tmp")
 	if err != nil {
 		return err
@@ -148,30 +365,27 @@ func (c *Cache) Ensure(ctx context.Context, u *url.URL, sha256sum string) error
 		os.Remove(tmpW.Name())
 	}()
 
-	r, sz, err := c.urlOpener.Open(ctx, u, sha256sum)
+	bar, err := progressbar.Start(ctx, sz, 0)
 	if err != nil {
-		return fmt.Errorf("failed to open URL %q: %w", u.Redacted(), err)
+		return err
 	}
-	defer r.Close()
 
-	bar, err := progressbar.New(sz)
+	digester, err := digesterOf(sha256sum)
 	if err != nil {
 		return err
 	}
-
-	digester := digest.SHA256.Digester()
 	hasher := digester.Hash()
 	mw := io.MultiWriter(tmpW, hasher)
 
-	bar.Start()
-	if _, err = io.Copy(mw, bar.NewProxyReader(r)); err != nil {
+	_, err = io.Copy(mw, bar.NewProxyReader(r))
+	bar.Finish()
+	if err != nil {
 		return fmt.Errorf("failed to copy %d bytes: %w", sz, err)
 	}
-	bar.Finish()
 
-	actualSHA256SUM := digester.Digest().Encoded()
+	actualSHA256SUM := sha256sums.FormatSum(digester.Digest())
 	if actualSHA256SUM != sha256sum {
-		return fmt.Errorf("expected sha256sum %q, got %q", sha256sum, actualSHA256SUM)
+		return c.mismatch(tmpW.Name(), sha256sum, actualSHA256SUM)
 	}
 
 	if err = tmpW.Sync(); err != nil {
@@ -183,14 +397,12 @@ func (c *Cache) Ensure(ctx context.Context, u *url.URL, sha256sum string) error
 	if err = os.Rename(tmpW.Name(), blob); err != nil {
 		return err
 	}
-	if err := c.writeURLFiles(sha256sum, u); err != nil {
-		return err
-	}
+	c.blobAdded(sha256sum, blob)
 	return nil
 }
 
 func (c *Cache) Export(dir string) (map[string]string, error) {
-	blobs, err := os.ReadDir(filepath.Join(c.dir, BlobsSHA256RelPath)) // no need to use securejoin (const)
+	blobs, err := c.Blobs()
 	if err != nil {
 		return nil, err
 	}
@@ -198,19 +410,8 @@ func (c *Cache) Export(dir string) (map[string]string, error) {
 		return nil, err
 	}
 	exported := make(map[string]string)
-	for _, f := range blobs {
-		if f.IsDir() {
-			continue
-		}
-		sha256sum := f.Name()
-		if strings.HasPrefix(sha256sum, ".") || strings.HasSuffix(sha256sum, ".tmp") {
-			continue
-		}
-		if err = digest.SHA256.Validate(sha256sum); err != nil {
-			logrus.WithError(err).Errorf("Invalid sha256sum %q", sha256sum)
-			continue
-		}
-		cpSrc := filepath.Join(c.dir, BlobsSHA256RelPath, sha256sum) // no need to use securejoin (sha256sum is verified)
+	for _, b := range blobs {
+		sha256sum := b.SHA256
 		basename := "UNKNOWN-" + sha256sum
 		if u, err := c.OriginURLBySHA256(sha256sum); err == nil {
 			basename = path.Base(u.Path)
@@ -225,7 +426,7 @@ func (c *Cache) Export(dir string) (map[string]string, error) {
 			logrus.Errorf("Avoiding to overwrite existing file %q", cpDst)
 			continue
 		}
-		if err = fs.CopyFile(cpDst, cpSrc); err != nil {
+		if err = c.exportBlob(cpDst, sha256sum); err != nil {
 			return exported, err
 		}
 		exported[basename] = sha256sum
@@ -233,13 +434,31 @@ func (c *Cache) Export(dir string) (map[string]string, error) {
 	return exported, nil
 }
 
+// exportBlob copies the blob in the primary dir to dst.
+// The chunked blobs are reassembled.
+func (c *Cache) exportBlob(dst, sha256sum string) error {
+	cpSrc, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(cpSrc); err == nil {
+		return fs.CopyFile(dst, cpSrc)
+	}
+	r, _, err := c.openPrimaryBlob(sha256sum)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return writeVerified(dst, sha256sum, r)
+}
+
 // Import imports local directories or files, and returns map[basename]sha256sum .
 func (c *Cache) Import(dirOrFiles ...string) (map[string]string, error) {
 	m := make(map[string]string)
 	for _, f := range dirOrFiles {
 		xM, err := c.import1(f)
 		if err != nil {
-			return m, nil
+			return m, err
 		}
 		for k, v := range xM {
 			if conflict, ok := m[k]; ok {
@@ -302,6 +521,10 @@ func (c *Cache) importDir(dir string) (map[string]string, error) {
 }
 
 func (c *Cache) importFile(nameFull string) (sha256sum string, err error) {
+	nameFull, err = filepath.Abs(nameFull)
+	if err != nil {
+		return "", err
+	}
 	u, err := url.Parse("file://" + nameFull)
 	if err != nil {
 		return "", err
@@ -312,6 +535,23 @@ func (c *Cache) importFile(nameFull string) (sha256sum string, err error) {
 // ImportWithReader imports from the reader.
 // Does not create the URL file.
 func (c *Cache) ImportWithReader(r io.Reader) (sha256sum string, err error) {
+	return c.importWithReader(r, "")
+}
+
+// ImportVerifiedWithReader imports from the reader, after verifying sha256sum.
+// Does not create the URL file.
+func (c *Cache) ImportVerifiedWithReader(r io.Reader, sha256sum string) error {
+	if _, err := sha256sums.ParseSum(sha256sum); err != nil {
+		return err
+	}
+	_, err := c.importWithReader(r, sha256sum)
+	return err
+}
+
+// importWithReader imports from the reader.
+// The content is hashed with SHA256, unless expectedSHA256SUM is a sum of another algorithm.
+// When expectedSHA256SUM is non-empty, the content is not imported unless it matches expectedSHA256SUM.
+func (c *Cache) importWithReader(r io.Reader, expectedSHA256SUM string) (sha256sum string, err error) {
 	blobsSHA256Dir := filepath.Join(c.dir, BlobsSHA256RelPath) // no need to use securejoin (const)
 	tmpW, err := os.CreateTemp(blobsSHA256Dir, ".import-*.tmp")
 	if err != nil {
@@ -322,13 +562,23 @@ func (c *Cache) ImportWithReader(r io.Reader) (sha256sum string, err error) {
 		os.Remove(tmpW.Name())
 	}()
 	digester := digest.SHA256.Digester()
+	if expectedSHA256SUM != "" {
+		if digester, err = digesterOf(expectedSHA256SUM); err != nil {
+			return "", err
+		}
+	}
 	hasher := digester.Hash()
 	mw := io.MultiWriter(tmpW, hasher)
 	if _, err = io.Copy(mw, r); err != nil {
 		return "", err
 	}
-	sha256sum = digester.Digest().Encoded()
-	blob, err := c.BlobAbsPath(sha256sum)
+	sha256sum = sha256sums.FormatSum(digester.Digest())
+	if expectedSHA256SUM != "" && sha256sum != expectedSHA256SUM {
+		err = fmt.Errorf("expected sha256sum %q, got %q", expectedSHA256SUM, sha256sum)
+		c.fire(Event{Type: EventVerifyFailure, SHA256: expectedSHA256SUM, Reason: err.Error()})
+		return "", err
+	}
+	blob, err := c.primaryBlobAbsPath(sha256sum)
 	if err != nil {
 		return "", err
 	}
@@ -341,6 +591,7 @@ func (c *Cache) ImportWithReader(r io.Reader) (sha256sum string, err error) {
 	if err = os.Rename(tmpW.Name(), blob); err != nil {
 		return "", err
 	}
+	c.blobAdded(sha256sum, blob)
 	return sha256sum, nil
 }
 
@@ -358,6 +609,17 @@ func (c *Cache) ImportWithURL(u *url.URL) (sha256sum string, err error) {
 	return sha256sum, err
 }
 
+// ImportWithReaderAndURL imports from the reader, and records u as the origin URL.
+// The caller must have verified that the content of the reader is identical to the content of u.
+func (c *Cache) ImportWithReaderAndURL(r io.Reader, u *url.URL) (sha256sum string, err error) {
+	sha256sum, err = c.ImportWithReader(r)
+	if err != nil {
+		return "", err
+	}
+	err = c.writeURLFiles(sha256sum, u)
+	return sha256sum, err
+}
+
 // writeURLFiles writes URL files.
 // Existing files are overwritten.
 func (c *Cache) writeURLFiles(sha256sum string, u *url.URL) error {
@@ -372,20 +634,22 @@ func (c *Cache) writeURLFiles(sha256sum string, u *url.URL) error {
 	if err != nil {
 		return err
 	}
-	if err = os.WriteFile(revURLFileAbs, []byte("sha256:"+sha256sum), 0644); err != nil {
+	d, _ := sha256sums.ParseSum(sha256sum) // already verified
+	if err = os.WriteFile(revURLFileAbs, []byte(d.String()), 0644); err != nil {
 		return fmt.Errorf("failed to create %q: %w", revURLFileAbs, err)
 	}
+	c.indexURL(sha256sum, u)
 	return nil
 }
 
 // OriginURLBySHA256 returns the origin of the blob.
 // Not always available.
 func (c *Cache) OriginURLBySHA256(sha256sum string) (*url.URL, error) {
-	urlFileAbs, err := c.URLFileAbsPath(sha256sum)
+	urlFileRel, err := c.URLFileRelPath(sha256sum)
 	if err != nil {
 		return nil, err
 	}
-	b, err := os.ReadFile(urlFileAbs)
+	b, err := c.readFile(urlFileRel)
 	if err != nil {
 		return nil, err
 	}
@@ -395,22 +659,56 @@ func (c *Cache) OriginURLBySHA256(sha256sum string) (*url.URL, error) {
 
 // SHA256ByOriginURL returns the sha256sum by the origin URL.
 // Not always available.
+// Returns an error that wraps os.ErrNotExist when the mapping is older than the URL TTL.
 func (c *Cache) SHA256ByOriginURL(u *url.URL) (string, error) {
-	revUrlFileAbs, err := c.ReverseURLFileAbsPath(u)
+	if c.index != nil {
+		ent, err := c.index.lookupURL(u.Redacted())
+		if err == nil {
+			if err = c.checkURLTTL(u, ent.FetchedAt); err != nil {
+				return "", err
+			}
+			return ent.SHA256, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithError(err).Warnf("Failed to look up %q in the index", u.Redacted())
+		}
+		// Fall back to the reverse URL file, which may have been created by an older version of repro-get,
+		// or may exist only in a read-only dir.
+	}
+	revURLFileRel, err := c.ReverseURLFileRelPath(u)
 	if err != nil {
 		return "", err
 	}
-	b, err := os.ReadFile(revUrlFileAbs)
+	b, err := c.readFile(revURLFileRel)
 	if err != nil {
 		return "", err
 	}
-	s := strings.TrimSpace(string(b))
-	d, err := digest.Parse(s)
+	if c.urlTTL > 0 {
+		st, err := os.Stat(filepath.Join(c.dir, revURLFileRel)) // no need to use securejoin (revURLFileRel is verified)
+		if errors.Is(err, os.ErrNotExist) {
+			if abs, ok := c.lookupReadOnly(revURLFileRel); ok {
+				st, err = os.Stat(abs)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		if err = c.checkURLTTL(u, st.ModTime()); err != nil {
+			return "", err
+		}
+	}
+	d, err := parseReverseURLFile(b)
 	if err != nil {
 		return "", err
 	}
-	if d.Algorithm() != digest.SHA256 {
-		return "", fmt.Errorf("expected algorithm %q, got %q (%q)", digest.SHA256, d.Algorithm(), d)
+	return sha256sums.FormatSum(d), nil
+}
+
+// parseReverseURLFile parses the content of a reverse URL file, such as "sha256:<SHA256>".
+func parseReverseURLFile(b []byte) (digest.Digest, error) {
+	s := strings.TrimSpace(string(b))
+	if !strings.Contains(s, ":") {
+		return "", fmt.Errorf("expected a digest with the algorithm prefix, got %q", s)
 	}
-	return d.Encoded(), nil
+	return sha256sums.ParseSum(s)
 }
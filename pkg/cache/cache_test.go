@@ -16,6 +16,7 @@ import (
 	"testing"
 
 	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"gotest.tools/v3/assert"
 )
 
@@ -244,6 +245,19 @@ func TestCacheExportImport(t *testing.T) {
 		}
 	})
 
+	t.Run("ImportByRelativeDir", func(t *testing.T) {
+		cache2, err := New(t.TempDir())
+		assert.NilError(t, err)
+		wd, err := os.Getwd()
+		assert.NilError(t, err)
+		assert.NilError(t, os.Chdir(filepath.Dir(exportDir)))
+		defer os.Chdir(wd) //nolint:errcheck
+		imported, err := cache2.Import(filepath.Base(exportDir))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, mapByBasename, imported)
+		testCacheDir(t, cache2, blobsBySHA256)
+	})
+
 	t.Run("ImportByFile", func(t *testing.T) {
 		cache2Dir := t.TempDir()
 		cache2, err := New(cache2Dir)
@@ -258,3 +272,119 @@ func TestCacheExportImport(t *testing.T) {
 		}
 	})
 }
+
+func TestCacheImportVerifiedWithReader(t *testing.T) {
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	content := []byte("foo")
+	sha256sum := digest.SHA256.FromBytes(content).Encoded()
+
+	err = cache.ImportVerifiedWithReader(bytes.NewReader([]byte("bar")), sha256sum)
+	assert.ErrorContains(t, err, "expected sha256sum")
+	cached, err := cache.Cached(digest.SHA256.FromBytes([]byte("bar")).Encoded())
+	assert.NilError(t, err)
+	assert.Assert(t, !cached)
+
+	assert.NilError(t, cache.ImportVerifiedWithReader(bytes.NewReader(content), sha256sum))
+	cached, err = cache.Cached(sha256sum)
+	assert.NilError(t, err)
+	assert.Assert(t, cached)
+}
+
+func TestCacheReadOnlyDir(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := context.TODO()
+
+	shared, err := New(t.TempDir())
+	assert.NilError(t, err)
+	var foo, bar *testBlob
+	for _, blob := range blobsBySHA256 {
+		switch blob.basename {
+		case "foo":
+			foo = blob
+			assert.NilError(t, shared.Ensure(ctx, testServer.basenameURL(blob), blob.sha256))
+		case "bar":
+			bar = blob
+		}
+	}
+
+	primaryDir := t.TempDir()
+	cache, err := New(primaryDir)
+	assert.NilError(t, err)
+	assert.NilError(t, cache.AddReadOnlyDir(shared.Dir()))
+	assert.Check(t, cache.AddReadOnlyDir(filepath.Join(t.TempDir(), "non-existent")) != nil)
+
+	// foo is looked up in the read-only dir
+	unreachable, err := url.Parse("http://localhost:0/unreachable")
+	assert.NilError(t, err)
+	assert.NilError(t, cache.Ensure(ctx, unreachable, foo.sha256))
+	fooBlob, err := cache.BlobAbsPath(foo.sha256)
+	assert.NilError(t, err)
+	assert.Equal(t, filepath.Join(shared.Dir(), BlobsSHA256RelPath, foo.sha256), fooBlob)
+	u, err := cache.OriginURLBySHA256(foo.sha256)
+	assert.NilError(t, err)
+	assert.Equal(t, testServer.basenameURL(foo).String(), u.String())
+	sha256sum, err := cache.SHA256ByOriginURL(testServer.basenameURL(foo))
+	assert.NilError(t, err)
+	assert.Equal(t, foo.sha256, sha256sum)
+
+	// bar is written to the primary dir
+	assert.NilError(t, cache.Ensure(ctx, testServer.basenameURL(bar), bar.sha256))
+	barBlob, err := cache.BlobAbsPath(bar.sha256)
+	assert.NilError(t, err)
+	assert.Equal(t, filepath.Join(primaryDir, BlobsSHA256RelPath, bar.sha256), barBlob)
+	cached, err := shared.Cached(bar.sha256)
+	assert.NilError(t, err)
+	assert.Assert(t, !cached)
+
+	// Touching a blob in the read-only dir is a no-op
+	assert.NilError(t, cache.Touch(foo.sha256))
+
+	// GC does not touch the read-only dir
+	blobs, err := cache.Blobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(blobs))
+	assert.Equal(t, bar.sha256, blobs[0].SHA256)
+}
+
+func TestCacheEnsureSHA512(t *testing.T) {
+	testCacheEnsureAlgorithm(t, digest.SHA512)
+}
+
+func TestCacheEnsureBLAKE3(t *testing.T) {
+	testCacheEnsureAlgorithm(t, sha256sums.BLAKE3)
+}
+
+func testCacheEnsureAlgorithm(t *testing.T, alg digest.Algorithm) {
+	b := []byte("blob-" + alg.String())
+	d, err := sha256sums.FromReader(alg, bytes.NewReader(b))
+	assert.NilError(t, err)
+	sum := d.String() // "sha512:..." or "blake3:..."
+	f := filepath.Join(t.TempDir(), "blob")
+	assert.NilError(t, os.WriteFile(f, b, 0644))
+	u, err := url.Parse("file://" + f)
+	assert.NilError(t, err)
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	assert.NilError(t, cache.Ensure(context.TODO(), u, sum))
+	ok, err := cache.Cached(sum)
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	blob, err := cache.BlobAbsPath(sum)
+	assert.NilError(t, err)
+	assert.Equal(t, filepath.Join(cache.Dir(), "blobs", alg.String(), d.Encoded()), blob)
+	got, err := cache.SHA256ByOriginURL(u)
+	assert.NilError(t, err)
+	assert.Equal(t, sum, got)
+
+	problems, err := cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems))
+
+	wrong, err := sha256sums.FromReader(alg, strings.NewReader("wrong"))
+	assert.NilError(t, err)
+	assert.ErrorContains(t, cache.Ensure(context.TODO(), u, wrong.String()), "expected sha256sum")
+}
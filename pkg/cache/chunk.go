@@ -0,0 +1,458 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ChunksSHA256RelPath contains the chunks of the chunked blobs, shared across the blobs.
+	ChunksSHA256RelPath = "chunks/sha256"
+	// RecipesSHA256RelPath contains the recipes of the chunked blobs, i.e., the lists of the chunks.
+	// The modification time of the recipe is used as the last use of the blob.
+	RecipesSHA256RelPath = "recipes/sha256"
+)
+
+// The parameters of the content-defined chunking.
+// Changing them does not break the existing recipes, but the new chunks will not be deduplicated with the old ones.
+const (
+	chunkMinSize = 64 * 1024
+	chunkMaxSize = 1024 * 1024
+	chunkMask    = uint64(1<<18-1) << (64 - 18) // 256 KiB on average, after chunkMinSize
+)
+
+// gearTable is the random table of the Gear hash, generated with SplitMix64 for stability across the releases.
+var gearTable = func() (t [256]uint64) {
+	x := uint64(0x7265_7072_6f2d_6765) // "repro-ge"
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
+
+// chunkBoundary returns the length of the first chunk of b, using the Gear hash as in FastCDC.
+// b is expected to contain chunkMaxSize bytes unless it is the end of the blob.
+func chunkBoundary(b []byte) int {
+	n := len(b)
+	if n <= chunkMinSize {
+		return n
+	}
+	if n > chunkMaxSize {
+		n = chunkMaxSize
+	}
+	var h uint64
+	for i := chunkMinSize; i < n; i++ {
+		h = (h << 1) + gearTable[b[i]]
+		if h&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// splitChunks splits the content of r into the content-defined chunks.
+// The chunk passed to fn is only valid until fn returns.
+func splitChunks(r io.Reader, fn func(chunk []byte) error) error {
+	buf := make([]byte, 2*chunkMaxSize)
+	var n int
+	eof := false
+	for {
+		for !eof && n < chunkMaxSize {
+			m, err := r.Read(buf[n:])
+			n += m
+			if errors.Is(err, io.EOF) {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+		l := chunkBoundary(buf[:n])
+		if err := fn(buf[:l]); err != nil {
+			return err
+		}
+		n = copy(buf, buf[l:n])
+	}
+}
+
+// recipe is the list of the chunks of a chunked blob.
+type recipe struct {
+	Size   int64      `json:"Size"`
+	Chunks []chunkRef `json:"Chunks"`
+}
+
+type chunkRef struct {
+	SHA256 string `json:"SHA256"`
+	Size   int64  `json:"Size"`
+}
+
+// SetChunked enables the chunked storage mode.
+// In the chunked mode, the blobs added to the primary dir are split into the content-defined chunks,
+// so that the successive versions of large files share most of the storage.
+// The blobs are reassembled on demand by BlobAbsPath; use ChunkBlobs to remove the reassembled files.
+//
+// The blobs that are not larger than the minimum chunk size are stored as plain files.
+func (c *Cache) SetChunked(chunked bool) {
+	c.chunked = chunked
+}
+
+func (c *Cache) recipeRelPath(sha256sum string) (string, error) {
+	return sumRelPath(RecipesSHA256RelPath, sha256sum)
+}
+
+func chunkRelPath(sha256sum string) (string, error) {
+	if err := digest.SHA256.Validate(sha256sum); err != nil {
+		return "", err
+	}
+	return securejoin.SecureJoin(ChunksSHA256RelPath, sha256sum)
+}
+
+// lookupRecipe returns the recipe of the blob, and the dir that contains the recipe and its chunks.
+// The primary dir is looked up first, and then the read-only dirs.
+// Returns an error that wraps os.ErrNotExist when the blob is not chunked.
+func (c *Cache) lookupRecipe(sha256sum string) (*recipe, string, error) {
+	rel, err := c.recipeRelPath(sha256sum)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, dir := range append([]string{c.dir}, c.readOnlyDirs...) {
+		r, err := readRecipe(filepath.Join(dir, rel)) // no need to use securejoin (rel is verified)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return r, dir, err
+	}
+	return nil, "", fmt.Errorf("%w: no recipe for %q", os.ErrNotExist, sha256sum)
+}
+
+func readRecipe(file string) (*recipe, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var r recipe
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse the recipe %q: %w", file, err)
+	}
+	return &r, nil
+}
+
+// chunkReader reads the chunks of the recipe sequentially.
+type chunkReader struct {
+	dir    string
+	chunks []chunkRef
+	cur    *os.File
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			rel, err := chunkRelPath(r.chunks[0].SHA256)
+			if err != nil {
+				return 0, err
+			}
+			r.chunks = r.chunks[1:]
+			if r.cur, err = os.Open(filepath.Join(r.dir, rel)); err != nil { // no need to use securejoin (rel is verified)
+				return 0, err
+			}
+		}
+		n, err := r.cur.Read(p)
+		if errors.Is(err, io.EOF) {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.cur != nil {
+		return r.cur.Close()
+	}
+	return nil
+}
+
+// openPrimaryBlob opens the blob in the primary dir, as a plain file or as chunks.
+func (c *Cache) openPrimaryBlob(sha256sum string) (io.ReadCloser, int64, error) {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(blob)
+	if err == nil {
+		st, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, st.Size(), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, 0, err
+	}
+	rel, _ := c.recipeRelPath(sha256sum) // already verified
+	r, err := readRecipe(filepath.Join(c.dir, rel))
+	if err != nil {
+		return nil, 0, err
+	}
+	return &chunkReader{dir: c.dir, chunks: r.Chunks}, r.Size, nil
+}
+
+// hasPrimary returns true if the primary dir has the blob, as a plain file or as chunks.
+func (c *Cache) hasPrimary(sha256sum string) (bool, error) {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return false, err
+	}
+	rel, _ := c.recipeRelPath(sha256sum) // already verified
+	for _, f := range []string{blob, filepath.Join(c.dir, rel)} {
+		if _, err := os.Stat(f); err == nil {
+			return true, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// BlobSize returns the size of the cached blob, without reassembling the chunked blob.
+// Returns an error that wraps os.ErrNotExist when the blob is not cached.
+func (c *Cache) BlobSize(sha256sum string) (int64, error) {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return 0, err
+	}
+	st, err := os.Stat(blob)
+	if errors.Is(err, os.ErrNotExist) {
+		rel, _ := c.BlobRelPath(sha256sum) // already verified
+		if roBlob, ok := c.lookupReadOnly(rel); ok {
+			st, err = os.Stat(roBlob)
+		}
+	}
+	if err == nil {
+		return st.Size(), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	r, _, err := c.lookupRecipe(sha256sum)
+	if err != nil {
+		return 0, err
+	}
+	return r.Size, nil
+}
+
+// materialize reassembles the chunked blob into the primary blobs dir, and returns the path.
+func (c *Cache) materialize(sha256sum string) (string, error) {
+	r, dir, err := c.lookupRecipe(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	logrus.Debugf("Reassembling %q from %d chunks", sha256sum, len(r.Chunks))
+	cr := &chunkReader{dir: dir, chunks: r.Chunks}
+	defer cr.Close()
+	if err = writeVerified(blob, sha256sum, cr); err != nil {
+		return "", fmt.Errorf("failed to reassemble %q: %w", sha256sum, err)
+	}
+	return blob, nil
+}
+
+// writeVerified writes the content of rd to file via a temporary file, after verifying sha256sum.
+func writeVerified(file, sha256sum string, rd io.Reader) error {
+	tmpW, err := os.CreateTemp(filepath.Dir(file), ".chunk-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmpW.Close()
+		os.Remove(tmpW.Name())
+	}()
+	digester, err := digesterOf(sha256sum)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(io.MultiWriter(tmpW, digester.Hash()), rd); err != nil {
+		return err
+	}
+	if actual := sha256sums.FormatSum(digester.Digest()); actual != sha256sum {
+		return fmt.Errorf("expected sha256sum %q, got %q", sha256sum, actual)
+	}
+	if err = tmpW.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpW.Name(), file)
+}
+
+// writeFileAtomic writes b to file via a temporary file.
+func writeFileAtomic(file string, b []byte) error {
+	tmpW, err := os.CreateTemp(filepath.Dir(file), ".chunk-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmpW.Close()
+		os.Remove(tmpW.Name())
+	}()
+	if _, err = tmpW.Write(b); err != nil {
+		return err
+	}
+	if err = tmpW.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpW.Name(), file)
+}
+
+// chunkBlob splits the blob in the primary dir into the chunks, and removes the plain file.
+// Returns false if the blob is too small to be chunked.
+func (c *Cache) chunkBlob(sha256sum string) (bool, error) {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if st.Size() <= chunkMinSize {
+		return false, nil
+	}
+	r := recipe{Size: st.Size()}
+	err = splitChunks(f, func(chunk []byte) error {
+		d := digest.SHA256.FromBytes(chunk)
+		rel, err := chunkRelPath(d.Encoded())
+		if err != nil {
+			return err
+		}
+		r.Chunks = append(r.Chunks, chunkRef{SHA256: d.Encoded(), Size: int64(len(chunk))})
+		chunkFile := filepath.Join(c.dir, rel) // no need to use securejoin (rel is verified)
+		if _, err := os.Stat(chunkFile); err == nil {
+			return nil
+		}
+		return writeFileAtomic(chunkFile, chunk)
+	})
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return false, err
+	}
+	rel, _ := c.recipeRelPath(sha256sum) // already verified
+	recipeFile := filepath.Join(c.dir, rel)
+	if err = writeFileAtomic(recipeFile, b); err != nil {
+		return false, err
+	}
+	// Keep the last use of the blob
+	if err = os.Chtimes(recipeFile, st.ModTime(), st.ModTime()); err != nil {
+		return false, err
+	}
+	return true, os.Remove(blob)
+}
+
+// ChunkBlobs splits the plain blobs in the primary dir into the chunks, e.g., for migrating an existing cache
+// into the chunked mode, or for removing the files reassembled by BlobAbsPath.
+// Returns the blobs that were chunked.
+func (c *Cache) ChunkBlobs() ([]Blob, error) {
+	blobs, err := c.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	var res []Blob
+	for _, b := range blobs {
+		blob, err := c.primaryBlobAbsPath(b.SHA256)
+		if err != nil {
+			return res, err
+		}
+		if _, err = os.Stat(blob); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		chunked, err := c.chunkBlob(b.SHA256)
+		if err != nil {
+			return res, fmt.Errorf("failed to chunk %q: %w", b.SHA256, err)
+		}
+		if chunked {
+			b.Chunked = true
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+// removeUnreferencedChunks removes the chunks that are not referenced by any recipe in the primary dir.
+func (c *Cache) removeUnreferencedChunks() error {
+	referenced := make(map[string]struct{})
+	for _, alg := range sumAlgorithms {
+		recipesDir := filepath.Join(c.dir, algRelPath(RecipesSHA256RelPath, alg)) // no need to use securejoin (const)
+		ents, err := os.ReadDir(recipesDir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		for _, ent := range ents {
+			if ent.IsDir() || strings.HasSuffix(ent.Name(), ".tmp") {
+				continue
+			}
+			r, err := readRecipe(filepath.Join(recipesDir, ent.Name())) // no need to use securejoin (ent.Name() is a base name)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return err
+			}
+			for _, ch := range r.Chunks {
+				referenced[ch.SHA256] = struct{}{}
+			}
+		}
+	}
+	chunksDir := filepath.Join(c.dir, ChunksSHA256RelPath) // no need to use securejoin (const)
+	ents, err := os.ReadDir(chunksDir)
+	if err != nil {
+		return err
+	}
+	for _, ent := range ents {
+		name := ent.Name()
+		if ent.IsDir() || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		if _, ok := referenced[name]; ok {
+			continue
+		}
+		logrus.Debugf("Removing unreferenced chunk %q", name)
+		if err = os.Remove(filepath.Join(chunksDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) { // no need to use securejoin (name is a base name)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b) //nolint:gosec
+	return b
+}
+
+func splitChunksForTest(t testing.TB, b []byte) []string {
+	t.Helper()
+	var res []string
+	var joined []byte
+	err := splitChunks(bytes.NewReader(b), func(chunk []byte) error {
+		assert.Assert(t, len(chunk) <= chunkMaxSize)
+		res = append(res, digest.SHA256.FromBytes(chunk).Encoded())
+		joined = append(joined, chunk...)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.Equal(b, joined))
+	return res
+}
+
+func TestSplitChunks(t *testing.T) {
+	v1 := randomBytes(42, 8*1024*1024)
+	// Insert some bytes in the middle
+	v2 := append(append(append([]byte{}, v1[:4*1024*1024]...), []byte("inserted")...), v1[4*1024*1024:]...)
+	chunks1, chunks2 := splitChunksForTest(t, v1), splitChunksForTest(t, v2)
+	assert.Assert(t, len(chunks1) > 8, len(chunks1))
+	shared := make(map[string]struct{})
+	for _, ch := range chunks1 {
+		shared[ch] = struct{}{}
+	}
+	var notShared int
+	for _, ch := range chunks2 {
+		if _, ok := shared[ch]; !ok {
+			notShared++
+		}
+	}
+	assert.Assert(t, notShared <= 2, "%d/%d chunks are not shared", notShared, len(chunks2))
+	assert.Equal(t, 0, len(splitChunksForTest(t, nil)))
+}
+
+func TestCacheChunked(t *testing.T) {
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache.SetChunked(true)
+
+	v1 := randomBytes(42, 4*1024*1024)
+	v2 := append(append([]byte{}, v1...), []byte("appended")...)
+	small := []byte("small")
+	var shas []string
+	for _, b := range [][]byte{v1, v2, small} {
+		sha256sum, err := cache.ImportWithReader(bytes.NewReader(b))
+		assert.NilError(t, err)
+		shas = append(shas, sha256sum)
+	}
+	blobs, err := cache.Blobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(blobs))
+	for _, b := range blobs {
+		assert.Equal(t, b.SHA256 != shas[2], b.Chunked, b.SHA256)
+	}
+	info, err := cache.Info()
+	assert.NilError(t, err)
+	assert.Equal(t, int64(len(v1)+len(v2)+len(small)), info.Size)
+	assert.Assert(t, info.ChunksSize < int64(len(v1)+chunkMaxSize), info.ChunksSize)
+
+	problems, err := cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems))
+
+	// The plain file is reassembled on demand
+	for i, b := range [][]byte{v1, v2} {
+		cached, err := cache.Cached(shas[i])
+		assert.NilError(t, err)
+		assert.Assert(t, cached)
+		size, err := cache.BlobSize(shas[i])
+		assert.NilError(t, err)
+		assert.Equal(t, int64(len(b)), size)
+		blob, err := cache.BlobAbsPath(shas[i])
+		assert.NilError(t, err)
+		got, err := os.ReadFile(blob)
+		assert.NilError(t, err)
+		assert.Assert(t, bytes.Equal(b, got))
+	}
+	rechunked, err := cache.ChunkBlobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(rechunked))
+	exportDir := t.TempDir()
+	exported, err := cache.Export(exportDir)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(exported))
+	got, err := os.ReadFile(filepath.Join(exportDir, "UNKNOWN-"+shas[1]))
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.Equal(v2, got))
+
+	// The chunks shared with v2 are kept
+	_, err = cache.Prune(map[string]struct{}{shas[1]: {}}, false)
+	assert.NilError(t, err)
+	problems, err = cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems))
+	_, err = cache.Prune(nil, false)
+	assert.NilError(t, err)
+	info, err = cache.Info()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, info.Blobs)
+	assert.Equal(t, 0, info.Chunks)
+}
+
+func TestCacheVerifyChunked(t *testing.T) {
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache.SetChunked(true)
+	sha256sum, err := cache.ImportWithReader(bytes.NewReader(randomBytes(42, 2*1024*1024)))
+	assert.NilError(t, err)
+	r, _, err := cache.lookupRecipe(sha256sum)
+	assert.NilError(t, err)
+	chunkRel, err := chunkRelPath(r.Chunks[0].SHA256)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(cache.Dir(), chunkRel), []byte("corrupted"), 0644))
+
+	problems, err := cache.Verify(VerifyOpts{Repair: true})
+	assert.NilError(t, err)
+	paths := make(map[string]struct{})
+	for _, p := range problems {
+		paths[p.Path] = struct{}{}
+	}
+	assert.DeepEqual(t, map[string]struct{}{
+		filepath.Join(RecipesSHA256RelPath, sha256sum): {},
+		chunkRel: {},
+	}, paths)
+	cached, err := cache.Cached(sha256sum)
+	assert.NilError(t, err)
+	assert.Assert(t, !cached)
+}
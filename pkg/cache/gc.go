@@ -0,0 +1,296 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// Blob is a cached blob.
+type Blob struct {
+	SHA256   string    `json:"SHA256"`
+	Size     int64     `json:"Size"`
+	LastUsed time.Time `json:"LastUsed"`          // The modification time of the blob file (or the recipe), updated by Touch
+	Chunked  bool      `json:"Chunked,omitempty"` // Stored as the chunks, in the chunked mode
+}
+
+// Blobs returns the blobs in the primary dir, sorted by the last use (the least recently used first).
+func (c *Cache) Blobs() ([]Blob, error) {
+	return blobsInDir(c.dir)
+}
+
+func blobsInDir(dir string) ([]Blob, error) {
+	var res []Blob
+	seen := make(map[string]struct{})
+	type subDir struct {
+		rel string
+		alg digest.Algorithm
+	}
+	var subDirs []subDir
+	for _, alg := range sumAlgorithms {
+		subDirs = append(subDirs,
+			subDir{rel: algRelPath(BlobsSHA256RelPath, alg), alg: alg},
+			subDir{rel: algRelPath(RecipesSHA256RelPath, alg), alg: alg})
+	}
+	for _, sub := range subDirs {
+		rel := sub.rel
+		ents, err := os.ReadDir(filepath.Join(dir, rel)) // no need to use securejoin (const)
+		if err != nil {
+			if rel != BlobsSHA256RelPath && errors.Is(err, os.ErrNotExist) {
+				// Created by an older version of repro-get
+				continue
+			}
+			return nil, err
+		}
+		for _, ent := range ents {
+			if ent.IsDir() {
+				continue
+			}
+			name := ent.Name()
+			if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".tmp") {
+				continue
+			}
+			d, err := sha256sums.ParseSum(digest.NewDigestFromEncoded(sub.alg, name).String())
+			if err != nil {
+				logrus.WithError(err).Errorf("Invalid %s sum %q", sub.alg, name)
+				continue
+			}
+			sha256sum := sha256sums.FormatSum(d)
+			if _, ok := seen[sha256sum]; ok {
+				// Reassembled from the chunks
+				continue
+			}
+			info, err := ent.Info()
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			b := Blob{
+				SHA256:   sha256sum,
+				Size:     info.Size(),
+				LastUsed: info.ModTime(),
+			}
+			if path.Dir(rel) == path.Dir(RecipesSHA256RelPath) {
+				r, err := readRecipe(filepath.Join(dir, rel, name)) // no need to use securejoin (name is verified)
+				if err != nil {
+					if errors.Is(err, os.ErrNotExist) {
+						continue
+					}
+					return nil, err
+				}
+				b.Size = r.Size
+				b.Chunked = true
+			}
+			seen[sha256sum] = struct{}{}
+			res = append(res, b)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].LastUsed.Equal(res[j].LastUsed) {
+			return res[i].SHA256 < res[j].SHA256
+		}
+		return res[i].LastUsed.Before(res[j].LastUsed)
+	})
+	return res, nil
+}
+
+// Touch records the current time as the last use of the blob, for GC.
+// A cache hit is counted too, for Stats.
+// The blobs in the read-only dirs are not touched.
+func (c *Cache) Touch(sha256sum string) error {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	c.countStats(true)
+	now := time.Now()
+	rel, _ := c.recipeRelPath(sha256sum) // already verified
+	found := false
+	for _, f := range []string{blob, filepath.Join(c.dir, rel)} {
+		if err = os.Chtimes(f, now, now); err == nil {
+			found = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if found {
+		return nil
+	}
+	if cached, err := c.Cached(sha256sum); err != nil || cached {
+		// In the read-only dirs
+		return err
+	}
+	return fmt.Errorf("%w: %q is not cached", os.ErrNotExist, sha256sum)
+}
+
+// Remove removes the blob, with its URL file and its reverse URL file.
+// The chunks of a chunked blob are removed by GC and Prune, as they may be shared with other blobs.
+func (c *Cache) Remove(sha256sum string) error {
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	if u, err := c.OriginURLBySHA256(sha256sum); err == nil {
+		// The reverse URL file may point to another blob, when the content of the URL has changed
+		if revSHA256, err := c.SHA256ByOriginURL(u); err == nil && revSHA256 == sha256sum {
+			revURLFileAbs, err := c.ReverseURLFileAbsPath(u)
+			if err != nil {
+				return err
+			}
+			if err = os.Remove(revURLFileAbs); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	urlFileAbs, err := c.URLFileAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(urlFileAbs); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err = c.removeProvenance(sha256sum); err != nil {
+		return err
+	}
+	removed := false
+	rel, _ := c.recipeRelPath(sha256sum) // already verified
+	for _, f := range []string{blob, filepath.Join(c.dir, rel)} {
+		if err = os.Remove(f); err == nil {
+			removed = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if c.index != nil {
+		if err = c.index.removeBlob(sha256sum); err != nil {
+			return fmt.Errorf("failed to remove %q from the index: %w", sha256sum, err)
+		}
+	}
+	if removed {
+		c.fire(Event{Type: EventEvict, SHA256: sha256sum})
+	}
+	return nil
+}
+
+// removeDanglingReverseURLFiles removes the reverse URL files that point to missing blobs.
+func (c *Cache) removeDanglingReverseURLFiles() error {
+	dir := filepath.Join(c.dir, ReverseURLRelPath) // no need to use securejoin (const)
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, ent := range ents {
+		if ent.IsDir() {
+			continue
+		}
+		f := filepath.Join(dir, ent.Name()) // no need to use securejoin (ent.Name() is a base name)
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if d, err := parseReverseURLFile(b); err == nil {
+			if cached, err := c.Cached(sha256sums.FormatSum(d)); err != nil || cached {
+				continue
+			}
+		}
+		logrus.Debugf("Removing dangling reverse URL file %q", f)
+		if err = os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GCOpts is the options for GC.
+type GCOpts struct {
+	MaxSize int64         // Maximum total size of the blobs in bytes. Unlimited when zero.
+	MaxAge  time.Duration // Maximum duration since the last use of the blobs. Unlimited when zero.
+	DryRun  bool          // Do not remove anything
+}
+
+// GC removes the blobs that have not been used for opts.MaxAge,
+// and then removes the least recently used blobs until the total size fits opts.MaxSize.
+// The partially downloaded blobs that have not been written for opts.MaxAge are removed too.
+// Returns the removed blobs.
+func (c *Cache) GC(opts GCOpts) ([]Blob, error) {
+	blobs, err := c.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, b := range blobs {
+		total += b.Size
+	}
+	now := time.Now()
+	var removed []Blob
+	for _, b := range blobs {
+		expired := opts.MaxAge > 0 && now.Sub(b.LastUsed) > opts.MaxAge
+		exceeded := opts.MaxSize > 0 && total > opts.MaxSize
+		if !expired && !exceeded {
+			// The blobs are sorted by the last use, so the rest of the blobs are not expired either
+			break
+		}
+		if !opts.DryRun {
+			if err = c.Remove(b.SHA256); err != nil {
+				return removed, err
+			}
+		}
+		total -= b.Size
+		removed = append(removed, b)
+	}
+	if !opts.DryRun {
+		if err = c.removeDanglingReverseURLFiles(); err != nil {
+			return removed, err
+		}
+		if err = c.removeUnreferencedChunks(); err != nil {
+			return removed, err
+		}
+		if opts.MaxAge > 0 {
+			if err = c.removeStalePartials(opts.MaxAge); err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// Prune removes the blobs that are not contained in keep.
+// Returns the removed blobs.
+func (c *Cache) Prune(keep map[string]struct{}, dryRun bool) ([]Blob, error) {
+	blobs, err := c.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	var removed []Blob
+	for _, b := range blobs {
+		if _, ok := keep[b.SHA256]; ok {
+			continue
+		}
+		if !dryRun {
+			if err = c.Remove(b.SHA256); err != nil {
+				return removed, err
+			}
+		}
+		removed = append(removed, b)
+	}
+	if !dryRun {
+		if err = c.removeDanglingReverseURLFiles(); err != nil {
+			return removed, err
+		}
+		if err = c.removeUnreferencedChunks(); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheGC(t *testing.T) {
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+
+	now := time.Now()
+	blobs := make(map[string]*testBlob)
+	for i, basename := range []string{"old", "middle", "new"} {
+		blob := newTestBlob(basename)
+		u, err := url.Parse("https://example.com/" + basename)
+		assert.NilError(t, err)
+		sha256sum, err := cache.ImportWithReaderAndURL(bytes.NewReader(blob.b), u)
+		assert.NilError(t, err)
+		assert.Equal(t, blob.sha256, sha256sum)
+		blobAbs, err := cache.BlobAbsPath(sha256sum)
+		assert.NilError(t, err)
+		lastUsed := now.Add(time.Duration(i-2) * 10 * 24 * time.Hour)
+		assert.NilError(t, os.Chtimes(blobAbs, lastUsed, lastUsed))
+		blobs[basename] = blob
+	}
+
+	got, err := cache.Blobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(got))
+	assert.Equal(t, blobs["old"].sha256, got[0].SHA256)
+	assert.Equal(t, blobs["middle"].sha256, got[1].SHA256)
+	assert.Equal(t, blobs["new"].sha256, got[2].SHA256)
+	assert.Equal(t, int64(len(blobs["old"].b)), got[0].Size)
+
+	// Dry run
+	removed, err := cache.GC(GCOpts{MaxAge: 15 * 24 * time.Hour, DryRun: true})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(removed))
+	cached, err := cache.Cached(blobs["old"].sha256)
+	assert.NilError(t, err)
+	assert.Assert(t, cached)
+
+	// By age
+	removed, err = cache.GC(GCOpts{MaxAge: 15 * 24 * time.Hour})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(removed))
+	assert.Equal(t, blobs["old"].sha256, removed[0].SHA256)
+	cached, err = cache.Cached(blobs["old"].sha256)
+	assert.NilError(t, err)
+	assert.Assert(t, !cached)
+	_, err = cache.OriginURLBySHA256(blobs["old"].sha256)
+	assert.Assert(t, err != nil)
+	oldURL, err := url.Parse("https://example.com/old")
+	assert.NilError(t, err)
+	_, err = cache.SHA256ByOriginURL(oldURL)
+	assert.Assert(t, err != nil)
+
+	// Touching the middle blob makes the new blob the least recently used one
+	assert.NilError(t, cache.Touch(blobs["middle"].sha256))
+
+	// By size
+	removed, err = cache.GC(GCOpts{MaxSize: int64(len(blobs["middle"].b))})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(removed))
+	assert.Equal(t, blobs["new"].sha256, removed[0].SHA256)
+	got, err = cache.Blobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, blobs["middle"].sha256, got[0].SHA256)
+	middleURL, err := url.Parse("https://example.com/middle")
+	assert.NilError(t, err)
+	sha256sum, err := cache.SHA256ByOriginURL(middleURL)
+	assert.NilError(t, err)
+	assert.Equal(t, blobs["middle"].sha256, sha256sum)
+}
+
+func TestCachePrune(t *testing.T) {
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+
+	blobs := make(map[string]*testBlob)
+	for _, basename := range []string{"foo", "bar", "baz"} {
+		blob := newTestBlob(basename)
+		u, err := url.Parse("https://example.com/" + basename)
+		assert.NilError(t, err)
+		_, err = cache.ImportWithReaderAndURL(bytes.NewReader(blob.b), u)
+		assert.NilError(t, err)
+		blobs[basename] = blob
+	}
+	keep := map[string]struct{}{
+		blobs["foo"].sha256: {},
+		blobs["baz"].sha256: {},
+		"0000000000000000000000000000000000000000000000000000000000000000": {}, // not cached
+	}
+
+	removed, err := cache.Prune(keep, true)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(removed))
+	got, err := cache.Blobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(got))
+
+	removed, err = cache.Prune(keep, false)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(removed))
+	assert.Equal(t, blobs["bar"].sha256, removed[0].SHA256)
+	got, err = cache.Blobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(got))
+	barURL, err := url.Parse("https://example.com/bar")
+	assert.NilError(t, err)
+	_, err = cache.SHA256ByOriginURL(barURL)
+	assert.Assert(t, err != nil)
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// EventType is the type of Event.
+type EventType string
+
+const (
+	// EventImport is fired when a blob is added to the primary dir.
+	EventImport EventType = "import"
+	// EventEvict is fired when a blob is removed from the primary dir, e.g., by GC and Prune.
+	EventEvict EventType = "evict"
+	// EventVerifyFailure is fired when a blob does not match its sha256sum, or when Verify finds a problem.
+	EventVerifyFailure EventType = "verify-failure"
+)
+
+// Event is an event of the cache.
+type Event struct {
+	Type   EventType `json:"Type"`
+	Dir    string    `json:"Dir"`              // The primary dir
+	SHA256 string    `json:"SHA256,omitempty"` // Empty for the problems of the files that are not blobs. Prefixed with "sha512:" for SHA512.
+	Size   int64     `json:"Size,omitempty"`   // Set for EventImport
+	Path   string    `json:"Path,omitempty"`   // Set for EventVerifyFailure, relative to Dir
+	Reason string    `json:"Reason,omitempty"` // Set for EventVerifyFailure
+}
+
+// Hook is called on an event.
+// Hooks may be called from multiple goroutines, and must not call the methods of the cache.
+// The errors are logged, and do not fail the operations of the cache.
+type Hook func(Event) error
+
+// AddHook adds the hook.
+// Not safe to call concurrently with the other methods.
+func (c *Cache) AddHook(hook Hook) {
+	c.hooks = append(c.hooks, hook)
+}
+
+func (c *Cache) fire(ev Event) {
+	ev.Dir = c.dir
+	for _, hook := range c.hooks {
+		if err := hook(ev); err != nil {
+			logrus.WithError(err).Warnf("Failed to run the hook for the %q event of %q", ev.Type, ev.SHA256)
+		}
+	}
+}
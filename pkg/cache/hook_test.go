@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheHook(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := context.TODO()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	cache.AddHook(func(ev Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+		return nil
+	})
+	popEvents := func() []Event {
+		mu.Lock()
+		defer mu.Unlock()
+		res := events
+		events = nil
+		return res
+	}
+
+	var blobs []*testBlob
+	for _, blob := range blobsBySHA256 {
+		blobs = append(blobs, blob)
+	}
+	assert.NilError(t, cache.Ensure(ctx, testServer.digestURL(blobs[0]), blobs[0].sha256))
+	assert.DeepEqual(t, []Event{
+		{Type: EventImport, Dir: cache.Dir(), SHA256: blobs[0].sha256, Size: int64(len(blobs[0].b))},
+	}, popEvents())
+
+	// Cache hits do not fire events
+	assert.NilError(t, cache.Ensure(ctx, testServer.digestURL(blobs[0]), blobs[0].sha256))
+	assert.Equal(t, 0, len(popEvents()))
+
+	err = cache.Ensure(ctx, testServer.basenameURL(blobs[0]), blobs[1].sha256)
+	assert.ErrorContains(t, err, "expected sha256sum")
+	ev := popEvents()
+	assert.Equal(t, 1, len(ev))
+	assert.Equal(t, EventVerifyFailure, ev[0].Type)
+	assert.Equal(t, blobs[1].sha256, ev[0].SHA256)
+
+	blob, err := cache.BlobAbsPath(blobs[0].sha256)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(blob, []byte("corrupted"), 0644))
+	_, err = cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	ev = popEvents()
+	assert.Equal(t, 1, len(ev))
+	assert.Equal(t, EventVerifyFailure, ev[0].Type)
+	assert.Equal(t, blobs[0].sha256, ev[0].SHA256)
+	assert.Equal(t, filepath.Join(BlobsSHA256RelPath, blobs[0].sha256), ev[0].Path)
+
+	assert.NilError(t, cache.Remove(blobs[0].sha256))
+	assert.DeepEqual(t, []Event{
+		{Type: EventEvict, Dir: cache.Dir(), SHA256: blobs[0].sha256},
+	}, popEvents())
+}
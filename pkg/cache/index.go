@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// IndexRelPath is the SQLite database that indexes the metadata of the blobs and the URL files.
+// The index complements the flat files (URLsSHA256RelPath and ReverseURLRelPath), which remain the source of truth
+// for the older versions of repro-get.
+const IndexRelPath = "index.db"
+
+const indexSchema = `
+CREATE TABLE IF NOT EXISTS blobs (
+	sha256     TEXT PRIMARY KEY,
+	size       INTEGER NOT NULL,
+	fetched_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS urls (
+	url        TEXT PRIMARY KEY,
+	sha256     TEXT NOT NULL,
+	fetched_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS urls_sha256 ON urls (sha256);
+`
+
+// index is the SQLite index of the cache.
+type index struct {
+	db *sql.DB
+}
+
+// openIndex opens the index in the dir.
+// The index is populated from the flat files on creation.
+func openIndex(dir string) (*index, error) {
+	file := filepath.Join(dir, IndexRelPath) // no need to use securejoin (const)
+	_, statErr := os.Stat(file)
+	created := errors.Is(statErr, os.ErrNotExist)
+	db, err := sql.Open("sqlite", "file:"+file+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(indexSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize %q: %w", file, err)
+	}
+	idx := &index{db: db}
+	if created {
+		if err = idx.populate(dir); err != nil {
+			db.Close()
+			os.Remove(file)
+			return nil, fmt.Errorf("failed to populate %q: %w", file, err)
+		}
+	}
+	return idx, nil
+}
+
+// populate populates the index from the flat files.
+func (idx *index) populate(dir string) error {
+	blobs, err := blobsInDir(dir)
+	if err != nil {
+		return err
+	}
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	for _, blob := range blobs {
+		sha256sum := blob.SHA256
+		if _, err = tx.Exec("INSERT OR REPLACE INTO blobs (sha256, size, fetched_at) VALUES (?, ?, ?)",
+			sha256sum, blob.Size, blob.LastUsed.Unix()); err != nil {
+			return err
+		}
+		urlFileRel, err := sumRelPath(URLsSHA256RelPath, sha256sum)
+		if err != nil {
+			return err
+		}
+		urlFile := filepath.Join(dir, urlFileRel) // no need to use securejoin (urlFileRel is verified)
+		b, err := os.ReadFile(urlFile)
+		if err != nil {
+			continue
+		}
+		urlInfo, err := os.Stat(urlFile)
+		if err != nil {
+			continue
+		}
+		if _, err = tx.Exec("INSERT OR REPLACE INTO urls (url, sha256, fetched_at) VALUES (?, ?, ?)",
+			strings.TrimSpace(string(b)), sha256sum, urlInfo.ModTime().Unix()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// reindex rebuilds the index from the flat files.
+func (idx *index) reindex(dir string) error {
+	if _, err := idx.db.Exec("DELETE FROM blobs; DELETE FROM urls;"); err != nil {
+		return err
+	}
+	return idx.populate(dir)
+}
+
+func (idx *index) putBlob(sha256sum string, size int64) error {
+	_, err := idx.db.Exec("INSERT OR REPLACE INTO blobs (sha256, size, fetched_at) VALUES (?, ?, ?)",
+		sha256sum, size, time.Now().Unix())
+	return err
+}
+
+// putURL records the URL. u must be redacted.
+func (idx *index) putURL(u string, sha256sum string) error {
+	_, err := idx.db.Exec("INSERT OR REPLACE INTO urls (url, sha256, fetched_at) VALUES (?, ?, ?)",
+		u, sha256sum, time.Now().Unix())
+	return err
+}
+
+func (idx *index) removeBlob(sha256sum string) error {
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	if _, err = tx.Exec("DELETE FROM blobs WHERE sha256 = ?", sha256sum); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM urls WHERE sha256 = ?", sha256sum); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// removeURL removes the URL, and returns false if the URL was not indexed. u must be redacted.
+func (idx *index) removeURL(u string) (bool, error) {
+	res, err := idx.db.Exec("DELETE FROM urls WHERE url = ?", u)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// URLEntry is an entry of the URL index.
+type URLEntry struct {
+	URL       string    `json:"URL"`
+	SHA256    string    `json:"SHA256"`
+	Size      int64     `json:"Size"` // -1 if unknown
+	FetchedAt time.Time `json:"FetchedAt"`
+}
+
+// lookupURL returns the entry for the URL. u must be redacted.
+// Returns an error that wraps os.ErrNotExist when the URL is not indexed.
+func (idx *index) lookupURL(u string) (*URLEntry, error) {
+	row := idx.db.QueryRow(`SELECT urls.sha256, IFNULL(blobs.size, -1), urls.fetched_at
+FROM urls LEFT JOIN blobs ON urls.sha256 = blobs.sha256 WHERE urls.url = ?`, u)
+	ent := URLEntry{URL: u}
+	var fetchedAt int64
+	if err := row.Scan(&ent.SHA256, &ent.Size, &fetchedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %q is not indexed", os.ErrNotExist, u)
+		}
+		return nil, err
+	}
+	ent.FetchedAt = time.Unix(fetchedAt, 0)
+	return &ent, nil
+}
+
+// URLs returns the URL entries in the index, sorted by the URL.
+// Returns an error if the index is not available.
+func (c *Cache) URLs() ([]URLEntry, error) {
+	if c.index == nil {
+		return nil, errors.New("the index is not available")
+	}
+	rows, err := c.index.db.Query(`SELECT urls.url, urls.sha256, IFNULL(blobs.size, -1), urls.fetched_at
+FROM urls LEFT JOIN blobs ON urls.sha256 = blobs.sha256 ORDER BY urls.url`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []URLEntry
+	for rows.Next() {
+		var (
+			ent       URLEntry
+			fetchedAt int64
+		)
+		if err = rows.Scan(&ent.URL, &ent.SHA256, &ent.Size, &fetchedAt); err != nil {
+			return res, err
+		}
+		ent.FetchedAt = time.Unix(fetchedAt, 0)
+		res = append(res, ent)
+	}
+	return res, rows.Err()
+}
+
+// SetURLTTL sets the TTL of the URL mappings.
+// SHA256ByOriginURL ignores the mappings that were fetched earlier than the TTL, so that mutable URLs are re-fetched.
+// Unlimited when zero.
+func (c *Cache) SetURLTTL(ttl time.Duration) {
+	c.urlTTL = ttl
+}
+
+// InvalidateURL removes the mapping from the URL to the sha256sum in the primary dir, so that the URL is fetched again.
+// The blob is not removed.
+// Returns an error that wraps os.ErrNotExist when the mapping is not found.
+func (c *Cache) InvalidateURL(u *url.URL) error {
+	revURLFileAbs, err := c.ReverseURLFileAbsPath(u)
+	if err != nil {
+		return err
+	}
+	found := true
+	if err = os.Remove(revURLFileAbs); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		found = false
+	}
+	if c.index != nil {
+		indexed, err := c.index.removeURL(u.Redacted())
+		if err != nil {
+			return fmt.Errorf("failed to remove %q from the index: %w", u.Redacted(), err)
+		}
+		found = found || indexed
+	}
+	if !found {
+		return fmt.Errorf("%w: no mapping for %q", os.ErrNotExist, u.Redacted())
+	}
+	return nil
+}
+
+// indexURL records the URL in the index, if the index is available.
+func (c *Cache) indexURL(sha256sum string, u *url.URL) {
+	if c.index == nil {
+		return
+	}
+	if err := c.index.putURL(u.Redacted(), sha256sum); err != nil {
+		logrus.WithError(err).Warnf("Failed to index %q", u.Redacted())
+	}
+}
+
+// indexBlob records the blob in the index, if the index is available.
+func (c *Cache) indexBlob(sha256sum string, size int64) {
+	if c.index == nil {
+		return
+	}
+	if err := c.index.putBlob(sha256sum, size); err != nil {
+		logrus.WithError(err).Warnf("Failed to index %q", sha256sum)
+	}
+}
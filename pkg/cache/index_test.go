@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheIndex(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := context.TODO()
+
+	dir := t.TempDir()
+	cache, err := New(dir)
+	assert.NilError(t, err)
+	for _, blob := range blobsBySHA256 {
+		assert.NilError(t, cache.Ensure(ctx, testServer.basenameURL(blob), blob.sha256))
+	}
+	testURLs := func(cache *Cache) {
+		t.Helper()
+		ents, err := cache.URLs()
+		assert.NilError(t, err)
+		assert.Equal(t, len(blobsBySHA256), len(ents))
+		for _, ent := range ents {
+			blob, ok := blobsBySHA256[ent.SHA256]
+			assert.Assert(t, ok, ent.SHA256)
+			assert.Equal(t, testServer.basenameURL(blob).String(), ent.URL)
+			assert.Equal(t, int64(len(blob.b)), ent.Size)
+			assert.Assert(t, !ent.FetchedAt.IsZero())
+		}
+	}
+	testURLs(cache)
+
+	// The index is populated from the flat files on creation
+	for _, f := range []string{IndexRelPath, IndexRelPath + "-wal", IndexRelPath + "-shm"} {
+		assert.NilError(t, os.RemoveAll(filepath.Join(dir, f)))
+	}
+	cache, err = New(dir)
+	assert.NilError(t, err)
+	testURLs(cache)
+
+	for _, blob := range blobsBySHA256 {
+		assert.NilError(t, cache.Remove(blob.sha256))
+		break
+	}
+	ents, err := cache.URLs()
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256)-1, len(ents))
+}
+
+func TestCacheURLTTL(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache.SetURLTTL(time.Hour)
+	for _, blob := range blobsBySHA256 {
+		u := testServer.basenameURL(blob)
+		sha256sum, err := cache.ImportWithURL(u)
+		assert.NilError(t, err)
+		assert.Equal(t, blob.sha256, sha256sum)
+		sha256sum, err = cache.SHA256ByOriginURL(u)
+		assert.NilError(t, err)
+		assert.Equal(t, blob.sha256, sha256sum)
+
+		old := time.Now().Add(-2 * time.Hour)
+		_, err = cache.index.db.Exec("UPDATE urls SET fetched_at = ?", old.Unix())
+		assert.NilError(t, err)
+		_, err = cache.SHA256ByOriginURL(u)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist), err)
+
+		// Without the index, the modification time of the reverse URL file is used
+		revURLFileAbs, err := cache.ReverseURLFileAbsPath(u)
+		assert.NilError(t, err)
+		assert.NilError(t, os.Chtimes(revURLFileAbs, old, old))
+		cache.index = nil
+		_, err = cache.SHA256ByOriginURL(u)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist), err)
+
+		cache.SetURLTTL(0)
+		sha256sum, err = cache.SHA256ByOriginURL(u)
+		assert.NilError(t, err)
+		assert.Equal(t, blob.sha256, sha256sum)
+	}
+}
+
+func TestCacheInvalidateURL(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	for _, blob := range blobsBySHA256 {
+		u := testServer.basenameURL(blob)
+		err = cache.InvalidateURL(u)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist), err)
+
+		_, err = cache.ImportWithURL(u)
+		assert.NilError(t, err)
+		assert.NilError(t, cache.InvalidateURL(u))
+		_, err = cache.SHA256ByOriginURL(u)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist), err)
+		// The blob is kept
+		assert.NilError(t, cache.Ensure(context.TODO(), u, blob.sha256))
+		err = cache.InvalidateURL(u)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist), err)
+	}
+}
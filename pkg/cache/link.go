@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/continuity/fs"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// Link creates dst as a hard link to the blob.
+// When the hard link cannot be created (e.g., across filesystems), the blob is copied with copy_file_range(2),
+// which creates a reflink on filesystems such as Btrfs and XFS.
+//
+// An existing dst is kept when it already has the same content.
+// Returns an error that wraps os.ErrExist when dst exists with different content.
+func (c *Cache) Link(sha256sum, dst string) error {
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	blobSt, err := os.Stat(blob)
+	if err != nil {
+		return err
+	}
+	if dstSt, err := os.Stat(dst); err == nil {
+		if os.SameFile(blobSt, dstSt) {
+			return nil
+		}
+		f, err := os.Open(dst)
+		if err != nil {
+			return err
+		}
+		expected, _ := sha256sums.ParseSum(sha256sum) // already verified
+		d, err := sha256sums.FromReader(expected.Algorithm(), f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", dst, err)
+		}
+		if d == expected {
+			return nil
+		}
+		return fmt.Errorf("%w: %q has a different sha256sum %s (expected %s)", os.ErrExist, dst, sha256sums.FormatSum(d), sha256sum)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".repro-get.tmp")
+	_ = os.Remove(tmp)
+	if err = os.Link(blob, tmp); err != nil {
+		logrus.WithError(err).Debugf("Failed to create a hard link %q, falling back to copying", tmp)
+		if err = fs.CopyFile(tmp, blob); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
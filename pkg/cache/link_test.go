@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheLink(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := context.TODO()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	dir := t.TempDir()
+	for _, blob := range blobsBySHA256 {
+		dst := filepath.Join(dir, blob.basename)
+		err = cache.Link(blob.sha256, dst)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist), err)
+
+		assert.NilError(t, cache.Ensure(ctx, testServer.digestURL(blob), blob.sha256))
+		assert.NilError(t, cache.Link(blob.sha256, dst))
+		b, err := os.ReadFile(dst)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, blob.b, b)
+		// Idempotent
+		assert.NilError(t, cache.Link(blob.sha256, dst))
+
+		// Copied file with the same content is kept
+		assert.NilError(t, os.Remove(dst))
+		assert.NilError(t, os.WriteFile(dst, blob.b, 0644))
+		assert.NilError(t, cache.Link(blob.sha256, dst))
+
+		assert.NilError(t, os.Remove(dst))
+		assert.NilError(t, os.WriteFile(dst, bytes.ToUpper(blob.b), 0644))
+		err = cache.Link(blob.sha256, dst)
+		assert.Assert(t, errors.Is(err, os.ErrExist), err)
+	}
+	ents, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256), len(ents))
+}
@@ -0,0 +1,6 @@
+package cache
+
+import "errors"
+
+// errLocked is returned by tryLockFile when the file is locked by another process.
+var errLocked = errors.New("the file is locked by another process")
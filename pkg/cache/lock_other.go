@@ -0,0 +1,21 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+// lockFile is a no-op on non-Unix platforms.
+// The processes sharing the cache are not serialized.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// tryLockFile is a no-op on non-Unix platforms.
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on non-Unix platforms.
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MismatchError is returned when the downloaded content does not match the expected sum.
+type MismatchError struct {
+	Expected string // The expected sum
+	Actual   string // The actual sum
+	File     string // The mismatching file kept in the mismatch dir (see SetMismatchDir), or empty
+	Report   string // The report of diffoscope(1) against the verified file, set by the caller, or empty
+}
+
+func (e *MismatchError) Error() string {
+	s := fmt.Sprintf("expected sha256sum %q, got %q", e.Expected, e.Actual)
+	switch {
+	case e.Report != "":
+		s += fmt.Sprintf(" (the mismatching file was kept as %q, see the diffoscope report %q)", e.File, e.Report)
+	case e.File != "":
+		s += fmt.Sprintf(" (the mismatching file was kept as %q)", e.File)
+	}
+	return s
+}
+
+// SetMismatchDir enables keeping the downloaded files that do not match the expected sums in dir,
+// for inspecting them later. The files are named after their actual sums.
+// The mismatching files are removed when dir is empty (default).
+func (c *Cache) SetMismatchDir(dir string) {
+	c.mismatchDir = dir
+}
+
+// mismatch fires EventVerifyFailure, and moves the mismatching file into the mismatch dir, or removes it.
+func (c *Cache) mismatch(file, expected, actual string) error {
+	e := &MismatchError{Expected: expected, Actual: actual}
+	c.fire(Event{Type: EventVerifyFailure, SHA256: expected, Reason: e.Error()})
+	if c.mismatchDir != "" {
+		kept, err := keepFile(c.mismatchDir, file, strings.ReplaceAll(actual, ":", "-"))
+		if err == nil {
+			logrus.Debugf("Kept the mismatching file as %q", kept)
+			e.File = kept
+			return e
+		}
+		logrus.WithError(err).Warnf("Failed to keep the mismatching file %q in %q", file, c.mismatchDir)
+	}
+	if err := os.Remove(file); err != nil {
+		logrus.WithError(err).Warnf("Failed to remove %q", file)
+	}
+	return e
+}
+
+// keepFile moves the file into dir as name. The file is copied when it cannot be renamed, e.g., across filesystems.
+func keepFile(dir, file, name string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, name) // no need to use securejoin (name is a sum)
+	if err := os.Rename(file, dst); err == nil {
+		return dst, nil
+	}
+	r, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return dst, os.Remove(file)
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheMismatchDir(t *testing.T) {
+	blob := newTestBlob("foo")
+	f := filepath.Join(t.TempDir(), blob.basename)
+	assert.NilError(t, os.WriteFile(f, blob.b, 0644))
+	u, err := url.Parse("file://" + f)
+	assert.NilError(t, err)
+	wrong := newTestBlob("bar").sha256
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	err = cache.Ensure(context.TODO(), u, wrong)
+	var mm *MismatchError
+	assert.Assert(t, errors.As(err, &mm))
+	assert.Equal(t, wrong, mm.Expected)
+	assert.Equal(t, blob.sha256, mm.Actual)
+	assert.Equal(t, "", mm.File)
+
+	mismatchDir := filepath.Join(t.TempDir(), "mismatch")
+	cache.SetMismatchDir(mismatchDir)
+	err = cache.Ensure(context.TODO(), u, wrong)
+	assert.Assert(t, errors.As(err, &mm))
+	assert.Equal(t, filepath.Join(mismatchDir, blob.sha256), mm.File)
+	assert.ErrorContains(t, err, "the mismatching file was kept")
+	b, err := os.ReadFile(mm.File)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, blob.b, b)
+	cached, err := cache.Cached(wrong)
+	assert.NilError(t, err)
+	assert.Assert(t, !cached)
+}
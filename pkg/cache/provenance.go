@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProvenanceSHA256RelPath contains the provenance of the downloaded blobs.
+const ProvenanceSHA256RelPath = "provenance/sha256"
+
+// Provenance records where a blob was actually fetched from.
+type Provenance struct {
+	URL       string    `json:"URL"`                // Redacted
+	Provider  string    `json:"Provider,omitempty"` // The provider template, e.g., "http://deb.debian.org/debian/{{.Name}}"
+	Remote    string    `json:"Remote,omitempty"`   // Set when the blob was fetched from the remote cache instead of URL
+	FetchedAt time.Time `json:"FetchedAt"`
+}
+
+type providerKey struct{}
+
+// WithProvider returns a context that records provider in the provenance of the blobs fetched by Ensure.
+func WithProvider(ctx context.Context, provider string) context.Context {
+	return context.WithValue(ctx, providerKey{}, provider)
+}
+
+func providerFromContext(ctx context.Context) string {
+	provider, _ := ctx.Value(providerKey{}).(string)
+	return provider
+}
+
+func (c *Cache) provenanceRelPath(sha256sum string) (string, error) {
+	return sumRelPath(ProvenanceSHA256RelPath, sha256sum)
+}
+
+// Provenance returns the provenance of the blob.
+// Returns an error that wraps os.ErrNotExist when the provenance is not recorded.
+func (c *Cache) Provenance(sha256sum string) (*Provenance, error) {
+	rel, err := c.provenanceRelPath(sha256sum)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.readFile(rel)
+	if err != nil {
+		return nil, err
+	}
+	var p Provenance
+	if err = json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse the provenance of %q: %w", sha256sum, err)
+	}
+	return &p, nil
+}
+
+// WriteProvenance writes the provenance of the blob in the primary dir.
+// The existing provenance is overwritten.
+func (c *Cache) WriteProvenance(sha256sum string, p *Provenance) error {
+	rel, err := c.provenanceRelPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(c.dir, rel) // no need to use securejoin (rel is verified)
+	if err = writeFileAtomic(file, b); err != nil {
+		return fmt.Errorf("failed to create %q: %w", file, err)
+	}
+	return nil
+}
+
+// removeProvenance removes the provenance of the blob in the primary dir, if any.
+func (c *Cache) removeProvenance(sha256sum string) error {
+	rel, err := c.provenanceRelPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(filepath.Join(c.dir, rel)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// recordProvenance records the provenance of the blob that has just been fetched by Ensure.
+// The failure is just logged, as the provenance is optional.
+func (c *Cache) recordProvenance(ctx context.Context, sha256sum string, u *url.URL, remote string) {
+	p := &Provenance{
+		URL:       u.Redacted(),
+		Provider:  providerFromContext(ctx),
+		Remote:    remote,
+		FetchedAt: time.Now().UTC(),
+	}
+	if err := c.WriteProvenance(sha256sum, p); err != nil {
+		logrus.WithError(err).Warnf("Failed to record the provenance of %q", sha256sum)
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheProvenance(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := WithProvider(context.TODO(), testServer.URL+"/blobs/sha256/{{.SHA256}}")
+
+	remote := &testRemote{blobs: make(map[string][]byte)}
+	cache1, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache1.SetRemote(remote, true)
+	cache2, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache2.SetRemote(remote, false)
+	for _, blob := range blobsBySHA256 {
+		u := testServer.digestURL(blob)
+		_, err = cache1.Provenance(blob.sha256)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist))
+
+		assert.NilError(t, cache1.Ensure(ctx, u, blob.sha256))
+		p, err := cache1.Provenance(blob.sha256)
+		assert.NilError(t, err)
+		assert.Equal(t, u.String(), p.URL)
+		assert.Equal(t, testServer.URL+"/blobs/sha256/{{.SHA256}}", p.Provider)
+		assert.Equal(t, "", p.Remote)
+		assert.Assert(t, !p.FetchedAt.IsZero())
+
+		// Fetched from the remote cache
+		assert.NilError(t, cache2.Ensure(ctx, u, blob.sha256))
+		p, err = cache2.Provenance(blob.sha256)
+		assert.NilError(t, err)
+		assert.Equal(t, u.String(), p.URL)
+		assert.Equal(t, remote.String(), p.Remote)
+
+		problems, err := cache1.Verify(VerifyOpts{})
+		assert.NilError(t, err)
+		assert.Equal(t, 0, len(problems))
+
+		assert.NilError(t, cache1.Remove(blob.sha256))
+		_, err = cache1.Provenance(blob.sha256)
+		assert.Assert(t, errors.Is(err, os.ErrNotExist))
+	}
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Remote is a remote cache backend, such as an S3 bucket.
+// See pkg/remotecache for the implementations.
+type Remote interface {
+	// Get opens the blob.
+	// Returns an error that wraps os.ErrNotExist when the blob does not exist.
+	// The returned size may be negative when it is unknown.
+	Get(ctx context.Context, sha256sum string) (io.ReadCloser, int64, error)
+	// Put stores the blob.
+	Put(ctx context.Context, sha256sum string, r io.Reader, size int64) error
+	// String returns the URL of the remote cache, without credentials.
+	String() string
+}
+
+// RemoteStatter is implemented by the remote cache backends that can check the existence of a blob without fetching it.
+type RemoteStatter interface {
+	// Stat returns the size of the blob.
+	// Returns an error that wraps os.ErrNotExist when the blob does not exist.
+	Stat(ctx context.Context, sha256sum string) (int64, error)
+}
+
+// SetRemote sets the remote cache backend.
+// The blobs missing in the local cache are fetched from the remote cache, before falling back to the origin URLs.
+// When writeThrough is true, the blobs fetched from the origin URLs are stored into the remote cache too.
+func (c *Cache) SetRemote(remote Remote, writeThrough bool) {
+	c.remote = remote
+	c.writeThrough = writeThrough
+}
+
+// Remote returns the remote cache backend, or nil.
+func (c *Cache) Remote() Remote {
+	return c.remote
+}
+
+func (c *Cache) ensureFromRemote(ctx context.Context, blob, sha256sum string) error {
+	r, sz, err := c.remote.Get(ctx, sha256sum)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return c.store(ctx, blob, sha256sum, r, sz)
+}
+
+// putToRemote uploads the blob in the primary dir to the remote cache.
+func (c *Cache) putToRemote(ctx context.Context, remote Remote, sha256sum string) error {
+	r, size, err := c.openPrimaryBlob(sha256sum)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err = remote.Put(ctx, sha256sum, r, size); err != nil {
+		return fmt.Errorf("failed to put %q: %w", sha256sum, err)
+	}
+	return nil
+}
+
+// remoteTee uploads the blob to the remote cache while the blob is being downloaded.
+// The last chunk is held back until commit is called after verifying the digest,
+// so that the remote cache never completes the upload of a corrupted blob.
+type remoteTee struct {
+	pw      *io.PipeWriter
+	pending []byte
+	err     error // the first error of the upload; the later chunks are discarded
+	done    chan error
+}
+
+func newRemoteTee(ctx context.Context, remote Remote, sha256sum string, size int64) *remoteTee {
+	pr, pw := io.Pipe()
+	t := &remoteTee{
+		pw:   pw,
+		done: make(chan error, 1),
+	}
+	go func() {
+		err := remote.Put(ctx, sha256sum, pr, size)
+		pr.CloseWithError(err) // unblocks the writer
+		t.done <- err
+	}()
+	return t
+}
+
+// Write never fails, so that a failure of the upload does not fail the download.
+func (t *remoteTee) Write(p []byte) (int, error) {
+	t.flush()
+	t.pending = append(t.pending[:0], p...)
+	return len(p), nil
+}
+
+func (t *remoteTee) flush() {
+	if t.err == nil && len(t.pending) > 0 {
+		_, t.err = t.pw.Write(t.pending)
+	}
+	t.pending = t.pending[:0]
+}
+
+// commit completes the upload.
+func (t *remoteTee) commit() error {
+	t.flush()
+	t.pw.Close()
+	if err := <-t.done; err != nil {
+		return err
+	}
+	return t.err
+}
+
+// abort aborts the upload.
+func (t *remoteTee) abort(err error) {
+	t.pw.CloseWithError(err)
+	<-t.done
+}
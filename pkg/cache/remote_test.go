@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+type testRemote struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (r *testRemote) Get(ctx context.Context, sha256sum string) (io.ReadCloser, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.blobs[sha256sum]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %s", os.ErrNotExist, sha256sum)
+	}
+	return io.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+}
+
+func (r *testRemote) Put(ctx context.Context, sha256sum string, rd io.Reader, size int64) error {
+	b, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blobs[sha256sum] = b
+	return nil
+}
+
+func (r *testRemote) String() string {
+	return "test://"
+}
+
+func TestCacheRemote(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := context.TODO()
+
+	remote := &testRemote{blobs: make(map[string][]byte)}
+	cache1, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache1.SetRemote(remote, true)
+	for _, blob := range blobsBySHA256 {
+		assert.NilError(t, cache1.Ensure(ctx, testServer.digestURL(blob), blob.sha256))
+		// Written through
+		assert.DeepEqual(t, blob.b, remote.blobs[blob.sha256])
+	}
+
+	// Read through, from the remote cache, not from the unreachable URL
+	cache2, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache2.SetRemote(remote, false)
+	unreachable, err := url.Parse("http://localhost:0/unreachable")
+	assert.NilError(t, err)
+	for _, blob := range blobsBySHA256 {
+		assert.NilError(t, cache2.Ensure(ctx, unreachable, blob.sha256))
+		u, err := cache2.OriginURLBySHA256(blob.sha256)
+		assert.NilError(t, err)
+		assert.Equal(t, unreachable.String(), u.String())
+	}
+	testCacheDir(t, cache2, blobsBySHA256)
+
+	// Corrupted blobs in the remote cache are not used
+	for sha256sum := range remote.blobs {
+		remote.blobs[sha256sum] = []byte("corrupted")
+	}
+	cache3, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache3.SetRemote(remote, false)
+	for _, blob := range blobsBySHA256 {
+		assert.NilError(t, cache3.Ensure(ctx, testServer.digestURL(blob), blob.sha256))
+	}
+	testCacheDir(t, cache3, blobsBySHA256)
+
+	// Blobs that do not match the sha256sum are not written through
+	remote4 := &testRemote{blobs: make(map[string][]byte)}
+	cache4, err := New(t.TempDir())
+	assert.NilError(t, err)
+	cache4.SetRemote(remote4, true)
+	var blobs []*testBlob
+	for _, blob := range blobsBySHA256 {
+		blobs = append(blobs, blob)
+	}
+	err = cache4.Ensure(ctx, testServer.basenameURL(blobs[0]), blobs[1].sha256)
+	assert.ErrorContains(t, err, "expected sha256sum")
+	assert.Equal(t, 0, len(remote4.blobs))
+}
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/progressbar"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// partialSuffix is the suffix of the partially downloaded blobs, such as "blobs/sha256/<SHA256>.partial.tmp".
+// The size of the partial file is used as the offset for resuming the download.
+const partialSuffix = ".partial.tmp"
+
+// openPartial opens and locks the partial file of the blob.
+// Waits for the other process that is downloading the same blob.
+func openPartial(blob string) (*os.File, error) {
+	partial := blob + partialSuffix
+	for {
+		f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err = tryLockFile(f); errors.Is(err, errLocked) {
+			logrus.Infof("Waiting for another process that is downloading %q", partial)
+			err = lockFile(f)
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		// The other process may have renamed or removed the file while we were waiting for the lock
+		fSt, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if st, err := os.Stat(partial); err == nil && os.SameFile(fSt, st) {
+			return f, nil
+		}
+		f.Close()
+	}
+}
+
+// ensureFromURL downloads the blob from u into the primary dir, after verifying sha256sum.
+//
+// The partially downloaded content is kept on an error, so that the next call can resume the download
+// with an HTTP Range request, when the server supports it.
+// The returned remoteTee is non-nil when the blob is being uploaded to the remote cache (write-through).
+func (c *Cache) ensureFromURL(ctx context.Context, u *url.URL, blob, sha256sum string) (*remoteTee, error) {
+	f, err := openPartial(blob)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // unlocks the file
+	if _, err = os.Stat(blob); err == nil {
+		// Downloaded by another process
+		return nil, nil
+	}
+
+	digester, err := digesterOf(sha256sum)
+	if err != nil {
+		return nil, err
+	}
+	hasher := digester.Hash()
+	offset, err := io.Copy(hasher, f)
+	if err != nil {
+		return nil, err
+	}
+	r, start, sz, err := c.urlOpener.OpenFrom(ctx, u, sha256sum, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open URL %q: %w", u.Redacted(), err)
+	}
+	defer r.Close()
+	if start != offset {
+		// Not resumable
+		digester, _ = digesterOf(sha256sum) // already verified
+		hasher = digester.Hash()
+		if err = f.Truncate(0); err != nil {
+			return nil, err
+		}
+	} else if start > 0 {
+		logrus.Infof("Resuming the download of %q from %d bytes", u.Redacted(), start)
+	}
+	if _, err = f.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var tee *remoteTee
+	if c.remote != nil && c.writeThrough && sz >= 0 && start == 0 {
+		// The size has to be known for the object storage services
+		tee = newRemoteTee(ctx, c.remote, sha256sum, sz)
+		r = io.NopCloser(io.TeeReader(r, tee))
+	}
+	abort := func(err error) (*remoteTee, error) {
+		if tee != nil {
+			tee.abort(err)
+		}
+		return nil, err
+	}
+
+	bar, err := progressbar.Start(ctx, sz, start)
+	if err != nil {
+		return abort(err)
+	}
+	_, err = io.Copy(io.MultiWriter(f, hasher), bar.NewProxyReader(r))
+	bar.Finish()
+	if err != nil {
+		if syncErr := f.Sync(); syncErr != nil {
+			logrus.WithError(syncErr).Warnf("Failed to sync %q", f.Name())
+		}
+		return abort(fmt.Errorf("failed to download %q (will be resumed on the next attempt): %w", u.Redacted(), err))
+	}
+
+	actualSHA256SUM := sha256sums.FormatSum(digester.Digest())
+	if actualSHA256SUM != sha256sum {
+		return abort(c.mismatch(f.Name(), sha256sum, actualSHA256SUM))
+	}
+	if err = f.Sync(); err != nil {
+		return abort(err)
+	}
+	if err = os.Rename(f.Name(), blob); err != nil {
+		return abort(err)
+	}
+	c.blobAdded(sha256sum, blob)
+	return tee, nil
+}
+
+// removeStalePartials removes the partial files that have not been written for maxAge.
+// The files locked by other processes are kept.
+func (c *Cache) removeStalePartials(maxAge time.Duration) error {
+	for _, alg := range sumAlgorithms {
+		dir := filepath.Join(c.dir, algRelPath(BlobsSHA256RelPath, alg)) // no need to use securejoin (const)
+		if err := removeStalePartialsInDir(dir, maxAge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeStalePartialsInDir(dir string, maxAge time.Duration) error {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	now := time.Now()
+	for _, ent := range ents {
+		if ent.IsDir() || !strings.HasSuffix(ent.Name(), partialSuffix) {
+			continue
+		}
+		info, err := ent.Info()
+		if err != nil || now.Sub(info.ModTime()) <= maxAge {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, ent.Name())) // no need to use securejoin (ent.Name() is a base name)
+		if err != nil {
+			continue
+		}
+		if err = tryLockFile(f); err == nil {
+			logrus.Debugf("Removing stale partial file %q", f.Name())
+			if err = os.Remove(f.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+				f.Close()
+				return err
+			}
+		}
+		f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheEnsureResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	sha256sum := digest.SHA256.FromBytes(content).Encoded()
+
+	newServer := func(t testing.TB, rangeSupported bool, ranges *[]string) *url.URL {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*ranges = append(*ranges, r.Header.Get("Range"))
+			if !rangeSupported {
+				r.Header.Del("Range")
+			}
+			http.ServeContent(w, r, "blob", time.Time{}, bytes.NewReader(content))
+		}))
+		t.Cleanup(srv.Close)
+		u, err := url.Parse(srv.URL + "/blob")
+		assert.NilError(t, err)
+		return u
+	}
+
+	testCase := func(t *testing.T, rangeSupported bool, partial []byte, expectedRange string) {
+		ctx := context.TODO()
+		var ranges []string
+		u := newServer(t, rangeSupported, &ranges)
+		cache, err := New(t.TempDir())
+		assert.NilError(t, err)
+		blob, err := cache.BlobAbsPath(sha256sum)
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(blob+partialSuffix, partial, 0644))
+
+		assert.NilError(t, cache.Ensure(ctx, u, sha256sum))
+		assert.DeepEqual(t, []string{expectedRange}, ranges)
+		b, err := os.ReadFile(blob)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, content, b)
+		_, err = os.Stat(blob + partialSuffix)
+		assert.Assert(t, os.IsNotExist(err))
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		testCase(t, true, content[:1000], "bytes=1000-")
+	})
+	t.Run("range-unsupported", func(t *testing.T) {
+		testCase(t, false, content[:1000], "bytes=1000-")
+	})
+	t.Run("no-partial", func(t *testing.T) {
+		testCase(t, true, nil, "")
+	})
+	t.Run("corrupted-partial", func(t *testing.T) {
+		ctx := context.TODO()
+		var ranges []string
+		u := newServer(t, true, &ranges)
+		cache, err := New(t.TempDir())
+		assert.NilError(t, err)
+		blob, err := cache.BlobAbsPath(sha256sum)
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(blob+partialSuffix, []byte("corrupted"), 0644))
+
+		assert.ErrorContains(t, cache.Ensure(ctx, u, sha256sum), "expected sha256sum")
+		_, err = os.Stat(blob + partialSuffix)
+		assert.Assert(t, os.IsNotExist(err))
+
+		// The next attempt downloads the blob from the beginning
+		assert.NilError(t, cache.Ensure(ctx, u, sha256sum))
+		assert.DeepEqual(t, []string{"bytes=9-", ""}, ranges)
+		b, err := os.ReadFile(blob)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, content, b)
+	})
+}
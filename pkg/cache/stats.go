@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatsRelPath is the file for recording the hit/miss counters.
+const StatsRelPath = "stats.json"
+
+// Stats is the hit/miss counters of the primary dir.
+type Stats struct {
+	Hits   int64 `json:"Hits"`   // The number of the requests for the blobs that were cached
+	Misses int64 `json:"Misses"` // The number of the requests for the blobs that were not cached
+}
+
+// Stats returns the hit/miss counters.
+func (c *Cache) Stats() (*Stats, error) {
+	b, err := os.ReadFile(filepath.Join(c.dir, StatsRelPath)) // no need to use securejoin (const)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Stats{}, nil
+		}
+		return nil, err
+	}
+	var stats Stats
+	if err = json.Unmarshal(b, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// countStats increments the hit/miss counters.
+// Errors are ignored, as the primary dir may not be writable.
+func (c *Cache) countStats(hit bool) {
+	if err := c.updateStats(func(stats *Stats) {
+		if hit {
+			stats.Hits++
+		} else {
+			stats.Misses++
+		}
+	}); err != nil {
+		logrus.WithError(err).Debug("Failed to update the cache stats")
+	}
+}
+
+func (c *Cache) updateStats(fn func(*Stats)) error {
+	f, err := os.OpenFile(filepath.Join(c.dir, StatsRelPath), os.O_RDWR|os.O_CREATE, 0644) // no need to use securejoin (const)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Lock the file, as multiple processes may share the cache
+	if err = lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f) //nolint:errcheck
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	var stats Stats
+	if len(b) > 0 {
+		if err = json.Unmarshal(b, &stats); err != nil {
+			logrus.WithError(err).Warnf("Resetting the broken cache stats %q", f.Name())
+			stats = Stats{}
+		}
+	}
+	fn(&stats)
+	if b, err = json.Marshal(stats); err != nil {
+		return err
+	}
+	if err = f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(b, 0)
+	return err
+}
+
+// Info is the summary of the primary dir.
+type Info struct {
+	Blobs      int   `json:"Blobs"`
+	Size       int64 `json:"Size"`
+	Chunks     int   `json:"Chunks,omitempty"`     // The number of the chunks, in the chunked mode
+	ChunksSize int64 `json:"ChunksSize,omitempty"` // The total size of the chunks, shared across the chunked blobs
+	Oldest     *Blob `json:"Oldest,omitempty"`     // The least recently used blob
+	Newest     *Blob `json:"Newest,omitempty"`     // The most recently used blob
+	Stats
+}
+
+// Info returns the summary of the primary dir.
+func (c *Cache) Info() (*Info, error) {
+	blobs, err := c.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Blobs: len(blobs),
+		Stats: *stats,
+	}
+	for _, b := range blobs {
+		info.Size += b.Size
+	}
+	chunks, err := os.ReadDir(filepath.Join(c.dir, ChunksSHA256RelPath)) // no need to use securejoin (const)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, ent := range chunks {
+		if ent.IsDir() || strings.HasSuffix(ent.Name(), ".tmp") {
+			continue
+		}
+		if st, err := ent.Info(); err == nil {
+			info.Chunks++
+			info.ChunksSize += st.Size()
+		}
+	}
+	if len(blobs) > 0 {
+		info.Oldest = &blobs[0]
+		info.Newest = &blobs[len(blobs)-1]
+	}
+	return info, nil
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheInfo(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := context.TODO()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	info, err := cache.Info()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Info{}, info)
+
+	var size int64
+	for _, blob := range blobsBySHA256 {
+		for i := 0; i < 3; i++ {
+			assert.NilError(t, cache.Ensure(ctx, testServer.digestURL(blob), blob.sha256))
+		}
+		size += int64(len(blob.b))
+	}
+	info, err = cache.Info()
+	assert.NilError(t, err)
+	assert.Equal(t, 2, info.Blobs)
+	assert.Equal(t, size, info.Size)
+	assert.Equal(t, int64(4), info.Hits)
+	assert.Equal(t, int64(2), info.Misses)
+	assert.Assert(t, info.Oldest != nil && info.Newest != nil)
+	assert.Assert(t, !info.Newest.LastUsed.Before(info.Oldest.LastUsed))
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// SyncOpts is the options for Sync and SyncDir.
+type SyncOpts struct {
+	DryRun bool // Do not copy the blobs, just return them
+}
+
+// Sync copies the blobs in the primary dir to the remote cache, unless the remote cache already has them.
+// Returns the copied blobs.
+func (c *Cache) Sync(ctx context.Context, remote Remote, opts SyncOpts) ([]Blob, error) {
+	blobs, err := c.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	var res []Blob
+	for _, b := range blobs {
+		ok, err := remoteHas(ctx, remote, b.SHA256)
+		if err != nil {
+			return res, err
+		}
+		if ok {
+			continue
+		}
+		if !opts.DryRun {
+			if err = c.putToRemote(ctx, remote, b.SHA256); err != nil {
+				return res, err
+			}
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func remoteHas(ctx context.Context, remote Remote, sha256sum string) (bool, error) {
+	var err error
+	if statter, ok := remote.(RemoteStatter); ok {
+		_, err = statter.Stat(ctx, sha256sum)
+	} else {
+		var r io.ReadCloser
+		if r, _, err = remote.Get(ctx, sha256sum); err == nil {
+			r.Close()
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SyncDir copies the blobs and the URL files in the primary dir to the primary dir of dst,
+// unless dst already has the blobs.
+// Returns the copied blobs.
+func (c *Cache) SyncDir(dst *Cache, opts SyncOpts) ([]Blob, error) {
+	blobs, err := c.Blobs()
+	if err != nil {
+		return nil, err
+	}
+	var res []Blob
+	for _, b := range blobs {
+		dstBlob, err := dst.primaryBlobAbsPath(b.SHA256)
+		if err != nil {
+			return res, err
+		}
+		if ok, err := dst.hasPrimary(b.SHA256); err != nil {
+			return res, err
+		} else if ok {
+			continue
+		}
+		if !opts.DryRun {
+			if err = c.syncBlob(dst, dstBlob, b.SHA256); err != nil {
+				return res, err
+			}
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func (c *Cache) syncBlob(dst *Cache, dstBlob, sha256sum string) error {
+	r, size, err := c.openPrimaryBlob(sha256sum)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err = dst.store(context.TODO(), dstBlob, sha256sum, r, size); err != nil {
+		return err
+	}
+	if p, err := c.Provenance(sha256sum); err == nil {
+		if _, err = dst.Provenance(sha256sum); errors.Is(err, os.ErrNotExist) {
+			if err = dst.WriteProvenance(sha256sum, p); err != nil {
+				return err
+			}
+		}
+	}
+	u, err := c.OriginURLBySHA256(sha256sum)
+	if err != nil {
+		return nil
+	}
+	// Do not overwrite the mapping in dst, which may be newer
+	if _, err = dst.SHA256ByOriginURL(u); errors.Is(err, os.ErrNotExist) {
+		return dst.writeURLFiles(sha256sum, u)
+	}
+	return nil
+}
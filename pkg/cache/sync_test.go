@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheSync(t *testing.T) {
+	blobsBySHA256 := newTestBlobs("foo", "bar")
+	testServer := newTestHTTPServer(t, blobsBySHA256)
+	defer testServer.Close()
+	ctx := context.TODO()
+
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+	for _, blob := range blobsBySHA256 {
+		assert.NilError(t, cache.Ensure(ctx, testServer.basenameURL(blob), blob.sha256))
+	}
+
+	remote := &testRemote{blobs: make(map[string][]byte)}
+	synced, err := cache.Sync(ctx, remote, SyncOpts{DryRun: true})
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256), len(synced))
+	assert.Equal(t, 0, len(remote.blobs))
+	synced, err = cache.Sync(ctx, remote, SyncOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256), len(synced))
+	for _, blob := range blobsBySHA256 {
+		assert.DeepEqual(t, blob.b, remote.blobs[blob.sha256])
+	}
+	synced, err = cache.Sync(ctx, remote, SyncOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(synced))
+
+	dst, err := New(t.TempDir())
+	assert.NilError(t, err)
+	synced, err = cache.SyncDir(dst, SyncOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(blobsBySHA256), len(synced))
+	testCacheDir(t, dst, blobsBySHA256)
+	for _, blob := range blobsBySHA256 {
+		u := testServer.basenameURL(blob)
+		sha256sum, err := dst.SHA256ByOriginURL(u)
+		assert.NilError(t, err)
+		assert.Equal(t, blob.sha256, sha256sum)
+	}
+	synced, err = cache.SyncDir(dst, SyncOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(synced))
+}
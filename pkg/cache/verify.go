@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// Problem is a problem found by Verify.
+type Problem struct {
+	Path   string // Path relative to the cache dir, such as "blobs/sha256/<SHA256>"
+	Reason string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Reason
+}
+
+// VerifyOpts is the options for Verify.
+type VerifyOpts struct {
+	Repair bool // Remove the bad entries
+}
+
+// Verify verifies the blobs and the URL files in the primary dir.
+//
+// The following problems are reported:
+//   - blobs that do not match their sha256sums (or sha512sums), such as corrupted or truncated ones
+//   - recipes and chunks of the chunked blobs that do not match their sha256sums, or point to missing chunks
+//   - URL files and reverse URL files that are malformed, or point to missing blobs
+//
+// The temporary files (*.tmp) are ignored, as they may belong to other running processes.
+func (c *Cache) Verify(opts VerifyOpts) ([]Problem, error) {
+	var problems []Problem
+	report := func(rel, reason string) error {
+		p := Problem{Path: rel, Reason: reason}
+		problems = append(problems, p)
+		ev := Event{Type: EventVerifyFailure, Path: rel, Reason: reason}
+		if d := filepath.Dir(filepath.Dir(rel)); d == filepath.Dir(BlobsSHA256RelPath) || d == filepath.Dir(RecipesSHA256RelPath) {
+			alg := digest.Algorithm(filepath.Base(filepath.Dir(rel)))
+			ev.SHA256 = sha256sums.FormatSum(digest.NewDigestFromEncoded(alg, filepath.Base(rel)))
+		}
+		c.fire(ev)
+		if !opts.Repair {
+			return nil
+		}
+		logrus.Infof("Removing %q (%s)", rel, reason)
+		err := os.Remove(filepath.Join(c.dir, rel)) // no need to use securejoin (rel is a base name in a constant dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	type subDir struct {
+		rel    string
+		verify func(name string) (string, error)
+	}
+	// perAlg returns the sub dirs for the algorithms, such as "blobs/sha256" and "blobs/sha512" for "blobs/sha256".
+	perAlg := func(sha256RelPath string, verify func(sha256sum string) (string, error)) []subDir {
+		var res []subDir
+		for _, alg := range sumAlgorithms {
+			alg := alg
+			res = append(res, subDir{
+				rel: algRelPath(sha256RelPath, alg),
+				verify: func(name string) (string, error) {
+					return verify(sha256sums.FormatSum(digest.NewDigestFromEncoded(alg, name)))
+				},
+			})
+		}
+		return res
+	}
+	var subDirs []subDir
+	subDirs = append(subDirs, perAlg(BlobsSHA256RelPath, c.verifyBlob)...)
+	subDirs = append(subDirs, perAlg(RecipesSHA256RelPath, c.verifyRecipe)...)
+	subDirs = append(subDirs, subDir{rel: ChunksSHA256RelPath, verify: c.verifyChunk})
+	subDirs = append(subDirs, perAlg(URLsSHA256RelPath, c.verifyURLFile)...)
+	subDirs = append(subDirs, subDir{rel: ReverseURLRelPath, verify: c.verifyReverseURLFile})
+	subDirs = append(subDirs, perAlg(ProvenanceSHA256RelPath, c.verifyProvenanceFile)...)
+	for _, sub := range subDirs {
+		ents, err := os.ReadDir(filepath.Join(c.dir, sub.rel)) // no need to use securejoin (const)
+		if err != nil {
+			return problems, err
+		}
+		for _, ent := range ents {
+			name := ent.Name()
+			if strings.HasSuffix(name, ".tmp") {
+				continue
+			}
+			rel := filepath.Join(sub.rel, name) // no need to use securejoin (name is a base name)
+			if !ent.Type().IsRegular() {
+				if err = report(rel, "not a regular file"); err != nil {
+					return problems, err
+				}
+				continue
+			}
+			logrus.Debugf("Verifying %q", rel)
+			reason, err := sub.verify(name)
+			if err != nil {
+				return problems, err
+			}
+			if reason != "" {
+				if err = report(rel, reason); err != nil {
+					return problems, err
+				}
+			}
+		}
+	}
+	if opts.Repair && len(problems) > 0 && c.index != nil {
+		if err := c.index.reindex(c.dir); err != nil {
+			return problems, fmt.Errorf("failed to rebuild the index: %w", err)
+		}
+	}
+	return problems, nil
+}
+
+// verifyBlob returns a non-empty reason if the blob is bad.
+func (c *Cache) verifyBlob(sha256sum string) (string, error) {
+	if _, err := sha256sums.ParseSum(sha256sum); err != nil {
+		return fmt.Sprintf("invalid sha256sum: %v", err), nil
+	}
+	blob, err := c.primaryBlobAbsPath(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	return verifyFile(blob, sha256sum)
+}
+
+// verifyChunk returns a non-empty reason if the chunk is bad.
+func (c *Cache) verifyChunk(sha256sum string) (string, error) {
+	rel, err := chunkRelPath(sha256sum)
+	if err != nil {
+		return fmt.Sprintf("invalid sha256sum: %v", err), nil
+	}
+	return verifyFile(filepath.Join(c.dir, rel), sha256sum) // no need to use securejoin (rel is verified)
+}
+
+func verifyFile(file, sha256sum string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	expected, err := sha256sums.ParseSum(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	actual, err := sha256sums.FromReader(expected.Algorithm(), f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q: %w", file, err)
+	}
+	if actual != expected {
+		return fmt.Sprintf("corrupted or truncated (actual sha256sum %q)", sha256sums.FormatSum(actual)), nil
+	}
+	return "", nil
+}
+
+// verifyRecipe returns a non-empty reason if the recipe is bad, or its chunks do not reassemble the blob.
+func (c *Cache) verifyRecipe(sha256sum string) (string, error) {
+	rel, err := c.recipeRelPath(sha256sum)
+	if err != nil {
+		return fmt.Sprintf("invalid sha256sum: %v", err), nil
+	}
+	r, err := readRecipe(filepath.Join(c.dir, rel)) // no need to use securejoin (rel is verified)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		return err.Error(), nil
+	}
+	for _, ch := range r.Chunks {
+		chunkRel, err := chunkRelPath(ch.SHA256)
+		if err != nil {
+			return fmt.Sprintf("invalid chunk sha256sum: %v", err), nil
+		}
+		if _, err = os.Stat(filepath.Join(c.dir, chunkRel)); errors.Is(err, os.ErrNotExist) { // no need to use securejoin (chunkRel is verified)
+			return fmt.Sprintf("points to missing chunk %q", ch.SHA256), nil
+		}
+	}
+	cr := &chunkReader{dir: c.dir, chunks: r.Chunks}
+	defer cr.Close()
+	expected, _ := sha256sums.ParseSum(sha256sum) // already verified
+	actual, err := sha256sums.FromReader(expected.Algorithm(), cr)
+	if err != nil {
+		return "", fmt.Errorf("failed to reassemble %q: %w", sha256sum, err)
+	}
+	if actual != expected {
+		return fmt.Sprintf("chunks are corrupted (actual sha256sum %q)", sha256sums.FormatSum(actual)), nil
+	}
+	return "", nil
+}
+
+// verifyURLFile returns a non-empty reason if the URL file is bad.
+func (c *Cache) verifyURLFile(sha256sum string) (string, error) {
+	if _, err := sha256sums.ParseSum(sha256sum); err != nil {
+		return fmt.Sprintf("invalid sha256sum: %v", err), nil
+	}
+	urlFileAbs, err := c.URLFileAbsPath(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(urlFileAbs)
+	if err != nil {
+		return "", err
+	}
+	if _, err = url.Parse(strings.TrimSpace(string(b))); err != nil {
+		return fmt.Sprintf("invalid URL: %v", err), nil
+	}
+	return c.verifyBlobExistence(sha256sum)
+}
+
+// verifyProvenanceFile returns a non-empty reason if the provenance file is bad.
+func (c *Cache) verifyProvenanceFile(sha256sum string) (string, error) {
+	rel, err := c.provenanceRelPath(sha256sum)
+	if err != nil {
+		return fmt.Sprintf("invalid sha256sum: %v", err), nil
+	}
+	b, err := os.ReadFile(filepath.Join(c.dir, rel)) // no need to use securejoin (rel is verified)
+	if err != nil {
+		return "", err
+	}
+	var p Provenance
+	if err = json.Unmarshal(b, &p); err != nil {
+		return fmt.Sprintf("invalid provenance: %v", err), nil
+	}
+	return c.verifyBlobExistence(sha256sum)
+}
+
+// verifyReverseURLFile returns a non-empty reason if the reverse URL file is bad.
+func (c *Cache) verifyReverseURLFile(name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(c.dir, ReverseURLRelPath, name)) // no need to use securejoin (name is a base name)
+	if err != nil {
+		return "", err
+	}
+	d, err := parseReverseURLFile(b)
+	if err != nil {
+		return fmt.Sprintf("invalid digest: %v", err), nil
+	}
+	return c.verifyBlobExistence(sha256sums.FormatSum(d))
+}
+
+func (c *Cache) verifyBlobExistence(sha256sum string) (string, error) {
+	cached, err := c.Cached(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	if !cached {
+		return fmt.Sprintf("points to missing blob %q", sha256sum), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCacheVerify(t *testing.T) {
+	cache, err := New(t.TempDir())
+	assert.NilError(t, err)
+
+	blobs := make(map[string]*testBlob)
+	for _, basename := range []string{"good", "corrupted", "truncated"} {
+		blob := newTestBlob(basename)
+		u, err := url.Parse("https://example.com/" + basename)
+		assert.NilError(t, err)
+		_, err = cache.ImportWithReaderAndURL(bytes.NewReader(blob.b), u)
+		assert.NilError(t, err)
+		blobs[basename] = blob
+	}
+	problems, err := cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems))
+
+	corrupted, err := cache.BlobAbsPath(blobs["corrupted"].sha256)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(corrupted, []byte("blob-corruptex"), 0644))
+	truncated, err := cache.BlobAbsPath(blobs["truncated"].sha256)
+	assert.NilError(t, err)
+	assert.NilError(t, os.Truncate(truncated, 3))
+	dangling, err := url.Parse("https://example.com/dangling")
+	assert.NilError(t, err)
+	assert.NilError(t, cache.writeURLFiles("0000000000000000000000000000000000000000000000000000000000000000", dangling))
+	tmp := filepath.Join(cache.Dir(), BlobsSHA256RelPath, ".download-foo.tmp")
+	assert.NilError(t, os.WriteFile(tmp, []byte("in progress"), 0644))
+
+	problems, err = cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	paths := make(map[string]struct{})
+	for _, p := range problems {
+		paths[p.Path] = struct{}{}
+	}
+	danglingRevRel, err := cache.ReverseURLFileRelPath(dangling)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]struct{}{
+		filepath.Join(BlobsSHA256RelPath, blobs["corrupted"].sha256):                                         {},
+		filepath.Join(BlobsSHA256RelPath, blobs["truncated"].sha256):                                         {},
+		filepath.Join(URLsSHA256RelPath, "0000000000000000000000000000000000000000000000000000000000000000"): {},
+		danglingRevRel: {},
+	}, paths)
+
+	problems, err = cache.Verify(VerifyOpts{Repair: true})
+	assert.NilError(t, err)
+	// The URL files of the removed blobs are removed too
+	assert.Equal(t, 8, len(problems))
+	problems, err = cache.Verify(VerifyOpts{})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems))
+
+	got, err := cache.Blobs()
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, blobs["good"].sha256, got[0].SHA256)
+	_, err = os.Stat(tmp)
+	assert.NilError(t, err)
+}
@@ -0,0 +1,34 @@
+package cargoutil
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type Crate struct {
+	Package string `json:"Package"` // "serde"
+	Version string `json:"Version"` // "1.0.147"
+}
+
+// ParseFilename parses a file name like "serde/serde-1.0.147.crate".
+func ParseFilename(filename string) (*Crate, error) {
+	if !strings.HasSuffix(filename, ".crate") {
+		return nil, fmt.Errorf("expected *.crate, got %q", filename)
+	}
+	dir, base := path.Split(filename)
+	pkg := path.Base(dir)
+	prefix := pkg + "-"
+	if dir == "" || !strings.HasPrefix(base, prefix) {
+		return nil, fmt.Errorf("expected <PACKAGE>/<PACKAGE>-<VERSION>.crate, got %q", filename)
+	}
+	return &Crate{
+		Package: pkg,
+		Version: strings.TrimSuffix(strings.TrimPrefix(base, prefix), ".crate"),
+	}, nil
+}
+
+// Filename returns a file name like "serde/serde-1.0.147.crate".
+func Filename(pkg, version string) string {
+	return pkg + "/" + pkg + "-" + version + ".crate"
+}
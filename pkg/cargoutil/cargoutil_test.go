@@ -0,0 +1,21 @@
+package cargoutil
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseFilename(t *testing.T) {
+	got, err := ParseFilename("serde_json/serde_json-1.0.87.crate")
+	assert.NilError(t, err)
+	expected := &Crate{
+		Package: "serde_json",
+		Version: "1.0.87",
+	}
+	assert.DeepEqual(t, expected, got)
+	assert.Equal(t, "serde_json/serde_json-1.0.87.crate", Filename(expected.Package, expected.Version))
+
+	_, err = ParseFilename("serde-1.0.147.crate")
+	assert.ErrorContains(t, err, "expected <PACKAGE>/<PACKAGE>-<VERSION>.crate")
+}
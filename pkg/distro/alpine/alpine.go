@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	_ "embed"
 	"errors"
 	"fmt"
 	"io"
@@ -11,14 +12,17 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/reproducible-containers/repro-get/pkg/apkutil"
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/rootfs"
 	"github.com/reproducible-containers/repro-get/pkg/urlopener"
 	"github.com/sirupsen/logrus"
 )
@@ -32,7 +36,7 @@ func New() distro.Distro {
 		info: distro.Info{
 			Name: Name,
 			DefaultProviders: []string{
-				"https://dl-cdn.alpinelinux.org/alpine/{{.Name}}",
+				DefaultMirror + "/{{.Name}}",
 			},
 			Experimental:                   true,
 			CacheIsNeededForGeneratingHash: true,
@@ -51,12 +55,42 @@ func (d *alpine) Info() distro.Info {
 }
 
 func (d *alpine) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
+	if opts.Source {
+		return fmt.Errorf("%w: source packages", ErrNotImplemented)
+	}
+	if opts.TargetRelease != "" || len(opts.Preferences) > 0 {
+		return fmt.Errorf("%w: pinning", ErrNotImplemented)
+	}
+	if opts.PPA != "" {
+		return fmt.Errorf("%w: PPA", ErrNotImplemented)
+	}
 	if opts.Cache == nil {
 		return errors.New("cache is required")
 	}
+	if opts.World && len(opts.FilterByName) > 0 {
+		return errors.New("the world file cannot be combined with the package names")
+	}
+	// The packages are resolved purely from the specified indexes, regardless of the installed packages
+	resolve := opts.Resolve || len(opts.Indexes) > 0
+	if resolve && !opts.World && len(opts.FilterByName) == 0 {
+		return errors.New("resolving dependencies needs the package names to be specified")
+	}
+	customRoot := opts.Root != "" && opts.Root != "/"
+	var apks map[string]apkutil.APK
 	names := opts.FilterByName
-	if len(names) == 0 {
-		apks, err := Installed()
+	if opts.World {
+		var err error
+		names, err = readWorld(opts.Root)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return errors.New("no package is in the world file?")
+		}
+	}
+	if len(names) == 0 && !customRoot {
+		var err error
+		apks, err = Installed()
 		if err != nil {
 			return err
 		}
@@ -68,23 +102,170 @@ func (d *alpine) GenerateHash(ctx context.Context, hw distro.HashWriter, opts di
 		}
 	}
 	sort.Strings(names)
+	foreign := opts.Arch != "" && opts.Arch != archutil.OCIArchDashVariant()
+	urlOpener := opts.Cache.URLOpener()
+	if resolve || opts.World || customRoot {
+		// Look up the packages in the local APKINDEX files of the root filesystem, without running `apk fetch`
+		iv, err := newIndexVerifier(opts)
+		if err != nil {
+			return err
+		}
+		var entries []indexEntry
+		if len(opts.Indexes) > 0 {
+			for _, idx := range opts.Indexes {
+				idxEntries, sha256sum, err := readIndexLocation(ctx, urlOpener, idx, iv)
+				if err != nil {
+					return err
+				}
+				if opts.IndexWriter != nil {
+					if err = opts.IndexWriter(sha256sum, idx); err != nil {
+						return err
+					}
+				}
+				entries = append(entries, idxEntries...)
+			}
+		} else {
+			entries, err = readIndexes(opts.Root, opts.IndexWriter, iv)
+			if err != nil {
+				return fmt.Errorf("failed to read the APKINDEX files (Hint: try 'apk update'): %w", err)
+			}
+		}
+		arch := opts.Arch
+		if arch == "" {
+			arch = archutil.OCIArchDashVariant()
+		}
+		apkArch, err := apkutil.ArchFromOCI(arch)
+		if err != nil {
+			return err
+		}
+		var resolved []*indexEntry
+		if resolve {
+			resolved, err = newResolver(entries, apkArch).resolve(names)
+			if err != nil {
+				return err
+			}
+		} else {
+			// Use the installed database of the root filesystem instead of `apk info -v`, so as to pin the installed versions
+			installedEntries, err := readInstalledDB(opts.Root)
+			if err != nil {
+				return fmt.Errorf("failed to read the installed database (Hint: try '--resolve' for the latest versions): %w", err)
+			}
+			var pinned []*indexEntry
+			if opts.World {
+				pinned, err = newResolver(installedEntries, "").resolve(names)
+			} else {
+				pinned, err = filterInstalled(installedEntries, names)
+			}
+			if err != nil {
+				return err
+			}
+			resolved, err = pinToIndexes(pinned, entries)
+			if err != nil {
+				return err
+			}
+		}
+		for _, e := range resolved {
+			u, err := url.Parse(e.URL())
+			if err != nil {
+				return err
+			}
+			if err = d.generateHashWithURL(ctx, hw, opts.Cache, urlOpener, u, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !foreign {
+		// Look up the installed packages in the local APKINDEX files, so as to avoid running `apk fetch`,
+		// and to avoid downloading the packages that are present in the local apk cache.
+		var entries []*indexEntry
+		entries, names = lookupIndexes(names, apks, opts.IndexWriter)
+		for _, e := range entries {
+			u, err := url.Parse(e.URL())
+			if err != nil {
+				return err
+			}
+			if err = d.generateHashWithURL(ctx, hw, opts.Cache, urlOpener, u, e); err != nil {
+				return err
+			}
+		}
+		if len(names) == 0 {
+			return nil
+		}
+	}
 	dummyDir, err := os.MkdirTemp("", "")
 	if err != nil {
 		return err
 	}
 	defer os.RemoveAll(dummyDir)
-	urlsCmd := exec.CommandContext(ctx, "apk", append([]string{"fetch", "--simulate", "--output=" + dummyDir, "--url"}, names...)...)
+	var apkArgs []string
+	if foreign {
+		apkArch, err := apkutil.ArchFromOCI(opts.Arch)
+		if err != nil {
+			return err
+		}
+		// The index for the architecture has to be fetched with `apk --arch=ARCH update`
+		apkArgs = append(apkArgs, "--arch="+apkArch)
+	}
+	apkArgs = append(apkArgs, "fetch", "--simulate", "--output="+dummyDir, "--url")
+	urlsCmd := exec.CommandContext(ctx, "apk", append(apkArgs, names...)...)
 	urlsCmd.Stderr = os.Stderr
 	urls, err := urlsCmd.Output()
 	if err != nil {
 		return fmt.Errorf("failed to execute %v: %w", urlsCmd.Args, err)
 	}
-	return d.generateHashWithURLReader(ctx, hw, opts.Cache, bytes.NewReader(urls))
+	return d.generateHashWithURLReader(ctx, hw, opts.Cache, urlOpener, bytes.NewReader(urls))
+}
+
+// lookupIndexes looks up the installed versions of the packages in the local APKINDEX files.
+// apks is the installed packages, and is detected when nil.
+// The SHA256 of the APKINDEX files are recorded to iw, unless iw is nil.
+// Returns the found entries, and the names of the packages that were not found.
+func lookupIndexes(names []string, apks map[string]apkutil.APK, iw distro.HashWriter) ([]*indexEntry, []string) {
+	if apks == nil {
+		var err error
+		apks, err = Installed()
+		if err != nil {
+			logrus.WithError(err).Debug("Failed to detect the installed packages")
+			return nil, names
+		}
+	}
+	entries, err := readIndexes("/", iw, nil)
+	if err != nil {
+		logrus.WithError(err).Debug("Failed to read the local APKINDEX files")
+		return nil, names
+	}
+	byPkgVer := make(map[string]*indexEntry, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		k := e.Package + "-" + e.Version
+		if _, ok := byPkgVer[k]; !ok {
+			byPkgVer[k] = e
+		}
+	}
+	var (
+		found    []*indexEntry
+		notFound []string
+	)
+	for _, name := range names {
+		inst, ok := apks[name]
+		if !ok {
+			notFound = append(notFound, name)
+			continue
+		}
+		e, ok := byPkgVer[inst.Package+"-"+inst.Version]
+		if !ok {
+			logrus.Debugf("Package %s-%s was not found in the local APKINDEX files", inst.Package, inst.Version)
+			notFound = append(notFound, name)
+			continue
+		}
+		found = append(found, e)
+	}
+	return found, notFound
 }
 
-func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWriter, c *cache.Cache, r io.Reader) error {
+func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWriter, c *cache.Cache, urlOpener *urlopener.URLOpener, r io.Reader) error {
 	sc := bufio.NewScanner(r)
-	urlOpener := urlopener.New()
 	for sc.Scan() {
 		line := sc.Text()
 		trimmed := strings.TrimSpace(line)
@@ -92,7 +273,7 @@ func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWr
 		if err != nil {
 			return err
 		}
-		if err := d.generateHashWithURL(ctx, hw, c, urlOpener, u); err != nil {
+		if err := d.generateHashWithURL(ctx, hw, c, urlOpener, u, nil); err != nil {
 			return err
 		}
 	}
@@ -102,7 +283,10 @@ func (d *alpine) generateHashWithURLReader(ctx context.Context, hw distro.HashWr
 	return nil
 }
 
-func (d *alpine) generateHashWithURL(ctx context.Context, hw distro.HashWriter, c *cache.Cache, urlOpener *urlopener.URLOpener, u *url.URL) error {
+// generateHashWithURL generates the hash for the URL.
+// e is the APKINDEX entry for the URL, and may be nil.
+// When e is non-nil, the package file in the local apk cache is used instead of downloading it, if the checksum matches.
+func (d *alpine) generateHashWithURL(ctx context.Context, hw distro.HashWriter, c *cache.Cache, urlOpener *urlopener.URLOpener, u *url.URL, e *indexEntry) error {
 	logrus.Debugf("Generating the hash for %q", u.Redacted())
 	if u.Scheme != "https" {
 		return fmt.Errorf("expected an https url, got %q", u.Redacted())
@@ -118,14 +302,63 @@ func (d *alpine) generateHashWithURL(ctx context.Context, hw distro.HashWriter,
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
 	}
+	if e != nil {
+		sha256sum, err := importFromPackageCache(c, u, e)
+		if err == nil {
+			logrus.Debugf("%q: imported from the local apk cache", basename)
+			return hw(sha256sum, fname)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.WithError(err).Warnf("%q: failed to import from the local apk cache", basename)
+		}
+	}
 	logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
 	sha256sum, err := c.ImportWithURL(u)
 	if err != nil {
 		return err
 	}
+	if e != nil {
+		if err = verifyBlob(c, sha256sum, e.Checksum); err != nil {
+			return fmt.Errorf("failed to verify %q with the APKINDEX checksum: %w", u.Redacted(), err)
+		}
+	}
 	return hw(sha256sum, fname)
 }
 
+// verifyBlob verifies the cached blob with the checksum in APKINDEX.
+func verifyBlob(c *cache.Cache, sha256sum, checksum string) error {
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return verifyQ1(f, checksum)
+}
+
+// importFromPackageCache imports the package file from PackageCacheDir, after verifying the checksum in APKINDEX.
+func importFromPackageCache(c *cache.Cache, u *url.URL, e *indexEntry) (string, error) {
+	base, err := cachedPackageFile(e)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Join(PackageCacheDir, base)) // no need to use securejoin (cachedPackageFile returns a clean base name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err = verifyQ1(f, e.Checksum); err != nil {
+		return "", fmt.Errorf("failed to verify %q: %w", f.Name(), err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return c.ImportWithReaderAndURL(f, u)
+}
+
 // urlToFilenameWithoutProvider converts
 // "https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/ca-certificates-bundle-20220614-r0.apk"
 // to
@@ -165,6 +398,19 @@ func (d *alpine) IsPackageVersionInstalled(ctx context.Context, sp filespec.File
 	return inst.Version == sp.APK.Version, nil
 }
 
+// InstalledPackages reads the installed database of the root filesystem, so that the apk binary is not needed.
+func (d *alpine) InstalledPackages(ctx context.Context, root string) ([]distro.InstalledPackage, error) {
+	entries, err := readInstalledDB(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the installed database: %w", err)
+	}
+	res := make([]distro.InstalledPackage, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, distro.InstalledPackage{Package: e.Package, Version: e.Version, Architecture: e.Architecture})
+	}
+	return res, nil
+}
+
 // Installed returns the package map.
 // The map key is the package name.
 func Installed() (map[string]apkutil.APK, error) {
@@ -197,10 +443,34 @@ func installed(r io.Reader) (map[string]apkutil.APK, error) {
 	return pkgs, sc.Err()
 }
 
+// InstallCommand returns the "apk add" command line.
+// The package files are represented as the symlinks in a temporary directory like "/tmp/repro-get-apk-*.tmp",
+// as InstallPackages creates them for apk, which needs the "*.apk" file names.
+// Returns nil for a custom root, as the packages are extracted without the apk binary.
+func (d *alpine) InstallCommand(c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) ([]string, error) {
+	if len(pkgs) == 0 || (opts.Root != "" && opts.Root != "/") {
+		return nil, nil
+	}
+	tmpDir := filepath.Join(os.TempDir(), "repro-get-apk-*.tmp")
+	args := []string{"apk", "add", "--no-network"}
+	for _, pkg := range pkgs {
+		ln, err := securejoin.SecureJoin(tmpDir, pkg.Basename)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, ln)
+	}
+	return args, nil
+}
+
 func (d *alpine) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) error {
 	if len(pkgs) == 0 {
 		return nil
 	}
+	if opts.Root != "" && opts.Root != "/" {
+		// Extract the packages without the apk binary, so that the root filesystem can be built on non-Alpine hosts
+		return installPackagesToRoot(c, pkgs, opts.Root)
+	}
 	cmdName, err := exec.LookPath("apk")
 	if err != nil {
 		return err
@@ -213,7 +483,7 @@ func (d *alpine) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []fil
 	args := []string{"add", "--no-network"}
 	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(pkgs))
 	for _, pkg := range pkgs {
-		blob, err := c.BlobAbsPath(pkg.SHA256)
+		blob, err := c.BlobAbsPath(pkg.Sum())
 		if err != nil {
 			return err
 		}
@@ -237,6 +507,74 @@ func (d *alpine) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []fil
 	return nil
 }
 
+func (d *alpine) UninstallPackages(ctx context.Context, pkgs []distro.InstalledPackage, opts distro.InstallOpts) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("uninstalling packages from a custom root %q is not supported for Alpine", opts.Root)
+	}
+	cmdName, err := exec.LookPath("apk")
+	if err != nil {
+		return err
+	}
+	args := []string{"del", "--no-network"}
+	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(pkgs))
+	for _, pkg := range pkgs {
+		args = append(args, pkg.Package)
+	}
+	cmd := exec.CommandContext(ctx, cmdName, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	return cmd.Run()
+}
+
+// Bootstrap extracts the packages into the new root filesystem, with installPackagesToRoot.
+// The scripts such as ".post-install" cannot be executed.
+func (d *alpine) Bootstrap(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.BootstrapOpts) error {
+	if opts.Root == "" || opts.Root == "/" {
+		return errors.New("root filesystem needs to be specified")
+	}
+	switch opts.Scripts {
+	case "", distro.BootstrapScriptsSkip:
+	case distro.BootstrapScriptsChroot:
+		return errors.New("executing the scripts is not supported for Alpine")
+	default:
+		return fmt.Errorf("unknown scripts mode %q", opts.Scripts)
+	}
+	if err := rootfs.EnsureEmpty(opts.Root); err != nil {
+		return err
+	}
+	since, err := rootfs.Now(opts.Root)
+	if err != nil {
+		return err
+	}
+	if err := installPackagesToRoot(c, pkgs, opts.Root); err != nil {
+		return err
+	}
+	return rootfs.Normalize(opts.Root, since, opts.SourceDateEpoch)
+}
+
+var (
+	//go:embed Dockerfile.generate-hash.tmpl
+	dockerfileGenerateHashTmpl string
+
+	//go:embed Dockerfile.tmpl
+	dockerfileTmpl string
+)
+
 func (d *alpine) GenerateDockerfile(ctx context.Context, dir string, args distro.DockerfileTemplateArgs, opts distro.DockerfileOpts) error {
-	return ErrNotImplemented
+	if opts.GenerateHash {
+		f := filepath.Join(dir, "Dockerfile.generate-hash") // no need to use securejoin (const)
+		if err := args.WriteToFile(f, dockerfileGenerateHashTmpl); err != nil {
+			return fmt.Errorf("failed to generate %q: %w", f, err)
+		}
+	}
+	f := filepath.Join(dir, "Dockerfile") // no need to use securejoin (const)
+	if err := args.WriteToFile(f, dockerfileTmpl); err != nil {
+		return fmt.Errorf("failed to generate %q: %w", f, err)
+	}
+	return nil
 }
@@ -0,0 +1,357 @@
+package alpine
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMirror is the default mirror of the apk repositories.
+	DefaultMirror = "https://dl-cdn.alpinelinux.org/alpine"
+	// RepositoriesFile is the list of the apk repositories.
+	RepositoriesFile = "/etc/apk/repositories"
+	// IndexCacheDir is the directory where `apk update` stores the APKINDEX files.
+	IndexCacheDir = "/var/cache/apk"
+	// PackageCacheDir is the directory where apk stores the package files, when the cache is enabled with `setup-apkcache`.
+	PackageCacheDir = "/etc/apk/cache"
+)
+
+// indexEntry is an entry of APKINDEX.
+type indexEntry struct {
+	Package      string   // "P:" field, e.g., "ca-certificates-bundle"
+	Version      string   // "V:" field, e.g., "20220614-r0"
+	Architecture string   // "A:" field, e.g., "x86_64"
+	Checksum     string   // "C:" field, e.g., "Q1..." (SHA1 of the control segment, not the SHA256 of the file)
+	Description  string   // "T:" field, e.g., "Pre generated bundle of Mozilla certificates"
+	Depends      []string // "D:" field, e.g., "so:libc.musl-x86_64.so.1"
+	Provides     []string // "p:" field, e.g., "so:libcrypto.so.1.1=1.1"
+	Repository   string   // e.g., "https://dl-cdn.alpinelinux.org/alpine/v3.16/main"
+}
+
+// URL returns the URL of the package file, such as
+// "https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/ca-certificates-bundle-20220614-r0.apk".
+func (e *indexEntry) URL() string {
+	return e.Repository + "/" + e.Architecture + "/" + e.Package + "-" + e.Version + ".apk"
+}
+
+// readRepositories reads the repository URLs from the repositories file.
+// The tags such as "@testing" are removed.
+func readRepositories(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				logrus.Warnf("Ignoring unparsable repository %q", line)
+				continue
+			}
+			line = fields[1]
+		}
+		res = append(res, strings.TrimSuffix(line, "/"))
+	}
+	return res, sc.Err()
+}
+
+// indexCacheFile returns the name of the cached APKINDEX file for the repository,
+// such as "APKINDEX.<first 4 bytes of SHA1(repo) in hex>.tar.gz".
+func indexCacheFile(repo string) string {
+	sum := sha1.Sum([]byte(repo))
+	return "APKINDEX." + hex.EncodeToString(sum[:4]) + ".tar.gz"
+}
+
+// readIndexes reads the cached APKINDEX files of the repositories.
+//
+// root is the root filesystem to inspect. An empty string is treated as "/".
+// The SHA256 of the APKINDEX files are recorded with the repository URLs to iw, unless iw is nil.
+// The signatures of the APKINDEX files are verified with iv, unless iv is nil.
+func readIndexes(root string, iw distro.HashWriter, iv *indexVerifier) ([]indexEntry, error) {
+	if root == "" {
+		root = "/"
+	}
+	repositoriesFile, err := securejoin.SecureJoin(root, RepositoriesFile)
+	if err != nil {
+		return nil, err
+	}
+	indexCacheDir, err := securejoin.SecureJoin(root, IndexCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := readRepositories(repositoriesFile)
+	if err != nil {
+		return nil, err
+	}
+	var res []indexEntry
+	for _, repo := range repos {
+		f := filepath.Join(indexCacheDir, indexCacheFile(repo)) // no need to use securejoin (indexCacheFile returns a clean base name)
+		entries, sha256sum, err := readIndexFile(f, repo, iv)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logrus.Warnf("No index was found for repository %q (Hint: try 'apk update')", repo)
+				continue
+			}
+			return nil, err
+		}
+		if iw != nil {
+			if err = iw(sha256sum, repo); err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, entries...)
+	}
+	return res, nil
+}
+
+// readIndexFile reads APKINDEX.tar.gz, and returns the entries with the SHA256 of the file.
+func readIndexFile(file, repo string, iv *indexVerifier) ([]indexEntry, string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = iv.verify(b, file); err != nil {
+		return nil, "", err
+	}
+	logrus.Debugf("Reading %q (%q)", file, repo)
+	entries, err := parseIndex(bytes.NewReader(b), repo)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %q: %w", file, err)
+	}
+	return entries, digest.SHA256.FromBytes(b).Encoded(), nil
+}
+
+// repositoryOfIndex returns the repository URL for the location of APKINDEX.tar.gz.
+//
+// The location is a URL such as "https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/APKINDEX.tar.gz",
+// or a local path that is laid out in the same way as the repository, such as "/mnt/mirror/v3.16/main/x86_64/APKINDEX.tar.gz".
+// DefaultMirror is used as the repository URL for local paths.
+func repositoryOfIndex(location string) (string, error) {
+	const suffix = "/APKINDEX.tar.gz"
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if !strings.HasSuffix(u.Path, suffix) {
+			return "", fmt.Errorf("expected the URL %q to end with %q", u.Redacted(), suffix)
+		}
+		u.Path = path.Dir(path.Dir(u.Path)) // strip "/<ARCH>/APKINDEX.tar.gz"
+		return u.String(), nil
+	}
+	p := filepath.ToSlash(strings.TrimPrefix(location, "file://"))
+	sp := strings.Split(path.Dir(path.Dir(p)), "/")
+	for i := len(sp) - 1; i >= 0; i-- {
+		if len(sp[i]) >= 2 && sp[i][0] == 'v' && '1' <= sp[i][1] && sp[i][1] <= '9' {
+			return DefaultMirror + "/" + strings.Join(sp[i:], "/"), nil
+		}
+	}
+	return "", fmt.Errorf("failed to detect the repository of %q (Hint: place the file as \"v<VERSION>/<REPO>/<ARCH>/APKINDEX.tar.gz\", or specify the URL)", location)
+}
+
+// openIndex opens APKINDEX.tar.gz at the location, which is a URL or a local path.
+func openIndex(ctx context.Context, urlOpener *urlopener.URLOpener, location string) (io.ReadCloser, error) {
+	if u, err := url.Parse(location); err == nil && u.Scheme != "" {
+		r, _, err := urlOpener.Open(ctx, u, "")
+		return r, err
+	}
+	return os.Open(location)
+}
+
+// readIndexLocation reads APKINDEX.tar.gz at the location, which is a URL or a local path,
+// and returns the entries with the SHA256 of the file.
+func readIndexLocation(ctx context.Context, urlOpener *urlopener.URLOpener, location string, iv *indexVerifier) ([]indexEntry, string, error) {
+	repo, err := repositoryOfIndex(location)
+	if err != nil {
+		return nil, "", err
+	}
+	r, err := openIndex(ctx, urlOpener, location)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %q: %w", location, err)
+	}
+	if err = iv.verify(b, location); err != nil {
+		return nil, "", err
+	}
+	logrus.Debugf("Reading %q (%q)", location, repo)
+	entries, err := parseIndex(bytes.NewReader(b), repo)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %q: %w", location, err)
+	}
+	return entries, digest.SHA256.FromBytes(b).Encoded(), nil
+}
+
+// parseIndex parses APKINDEX.tar.gz.
+func parseIndex(r io.Reader, repo string) ([]indexEntry, error) {
+	// APKINDEX.tar.gz is a concatenation of the gzip streams of the signature and the index
+	gzR, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzR.Close()
+	tr := tar.NewReader(gzR)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no APKINDEX entry was found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == "APKINDEX" {
+			return parseIndexText(tr, repo)
+		}
+	}
+}
+
+// parseIndexText parses the "APKINDEX" text in APKINDEX.tar.gz.
+func parseIndexText(r io.Reader, repo string) ([]indexEntry, error) {
+	var (
+		res []indexEntry
+		cur indexEntry
+	)
+	flush := func() {
+		if cur.Package != "" {
+			cur.Repository = repo
+			res = append(res, cur)
+		}
+		cur = indexEntry{}
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		switch k {
+		case "P":
+			cur.Package = v
+		case "V":
+			cur.Version = v
+		case "A":
+			cur.Architecture = v
+		case "C":
+			cur.Checksum = v
+		case "T":
+			cur.Description = v
+		case "D":
+			cur.Depends = strings.Fields(v)
+		case "p":
+			cur.Provides = strings.Fields(v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return res, nil
+}
+
+// cachedPackageFile returns the name of the package file in PackageCacheDir,
+// such as "ca-certificates-bundle-20220614-r0.<first 4 bytes of the checksum in hex>.apk".
+func cachedPackageFile(e *indexEntry) (string, error) {
+	sum, err := decodeQ1(e.Checksum)
+	if err != nil {
+		return "", err
+	}
+	return e.Package + "-" + e.Version + "." + hex.EncodeToString(sum[:4]) + ".apk", nil
+}
+
+// decodeQ1 decodes a checksum string such as "Q1..." into SHA1 bytes.
+func decodeQ1(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "Q1") {
+		return nil, fmt.Errorf("unsupported checksum %q", s)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "Q1"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode checksum %q: %w", s, err)
+	}
+	if len(b) != sha1.Size {
+		return nil, fmt.Errorf("unexpected checksum length %d", len(b))
+	}
+	return b, nil
+}
+
+// verifyQ1 verifies the package file with the checksum in APKINDEX.
+// The checksum is the SHA1 of the gzip stream of the control segment, which follows the optional signature segment.
+func verifyQ1(r io.Reader, checksum string) error {
+	expected, err := decodeQ1(checksum)
+	if err != nil {
+		return err
+	}
+	// gzip.Reader reads the exact bytes of each stream from an io.ByteReader
+	hr := &hashingByteReader{r: bufio.NewReader(r)}
+	var gzR *gzip.Reader
+	for i := 0; i < 2; i++ { // signature (optional), control
+		hr.h = sha1.New()
+		if gzR == nil {
+			gzR, err = gzip.NewReader(hr)
+		} else {
+			err = gzR.Reset(hr)
+		}
+		if err != nil {
+			return err
+		}
+		gzR.Multistream(false)
+		if _, err = io.Copy(io.Discard, gzR); err != nil {
+			return err
+		}
+		if string(hr.h.Sum(nil)) == string(expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("checksum mismatch: expected %q", checksum)
+}
+
+type hashingByteReader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (r *hashingByteReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	return n, err
+}
+
+func (r *hashingByteReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.h.Write([]byte{b})
+	}
+	return b, err
+}
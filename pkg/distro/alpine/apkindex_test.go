@@ -0,0 +1,145 @@
+package alpine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"gotest.tools/v3/assert"
+)
+
+// gzipTar returns a gzip stream of a tar segment.
+// The end-of-archive marker is omitted unless eof is true, as in the apk files.
+func gzipTar(t testing.TB, files map[string]string, eof bool) []byte {
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for name, content := range files {
+		assert.NilError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tw.Flush())
+	b := tarBuf.Bytes()
+	if eof {
+		assert.NilError(t, tw.Close())
+		b = tarBuf.Bytes()
+	}
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	_, err := gw.Write(b)
+	assert.NilError(t, err)
+	assert.NilError(t, gw.Close())
+	return gzBuf.Bytes()
+}
+
+func q1(b []byte) string {
+	sum := sha1.Sum(b)
+	return "Q1" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestParseIndex(t *testing.T) {
+	const index = `C:Q1Ai5bvcvJKAYpFe7y5JjjHspLWyI=
+P:ca-certificates-bundle
+V:20220614-r0
+A:x86_64
+S:125036
+
+C:Q1phVVvSP6knx3BJb+mPwZbuziu8c=
+P:libcrypto1.1
+V:1.1.1q-r0
+A:x86_64
+D:so:libc.musl-x86_64.so.1
+p:so:libcrypto.so.1.1=1.1
+`
+	var b bytes.Buffer
+	b.Write(gzipTar(t, map[string]string{".SIGN.RSA.alpine-devel@lists.alpinelinux.org-6165ee59.rsa.pub": "dummy"}, false))
+	b.Write(gzipTar(t, map[string]string{"DESCRIPTION": "v3.16.2", "APKINDEX": index}, true))
+
+	const repo = "https://dl-cdn.alpinelinux.org/alpine/v3.16/main"
+	entries, err := parseIndex(&b, repo)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []indexEntry{
+		{
+			Package:      "ca-certificates-bundle",
+			Version:      "20220614-r0",
+			Architecture: "x86_64",
+			Checksum:     "Q1Ai5bvcvJKAYpFe7y5JjjHspLWyI=",
+			Repository:   repo,
+		},
+		{
+			Package:      "libcrypto1.1",
+			Version:      "1.1.1q-r0",
+			Architecture: "x86_64",
+			Checksum:     "Q1phVVvSP6knx3BJb+mPwZbuziu8c=",
+			Depends:      []string{"so:libc.musl-x86_64.so.1"},
+			Provides:     []string{"so:libcrypto.so.1.1=1.1"},
+			Repository:   repo,
+		},
+	}, entries)
+	assert.Equal(t, "https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/ca-certificates-bundle-20220614-r0.apk", entries[0].URL())
+	assert.Assert(t, strings.HasPrefix(indexCacheFile(repo), "APKINDEX."))
+	assert.Equal(t, len("APKINDEX.01234567.tar.gz"), len(indexCacheFile(repo)))
+}
+
+func TestVerifyQ1(t *testing.T) {
+	sig := gzipTar(t, map[string]string{".SIGN.RSA.dummy.rsa.pub": "dummy"}, false)
+	control := gzipTar(t, map[string]string{".PKGINFO": "pkgname = hello\n"}, false)
+	data := gzipTar(t, map[string]string{"usr/bin/hello": "hello"}, true)
+	signed := bytes.Join([][]byte{sig, control, data}, nil)
+	unsigned := bytes.Join([][]byte{control, data}, nil)
+
+	checksum := q1(control)
+	assert.NilError(t, verifyQ1(bytes.NewReader(signed), checksum))
+	assert.NilError(t, verifyQ1(bytes.NewReader(unsigned), checksum))
+	assert.ErrorContains(t, verifyQ1(bytes.NewReader(signed), q1(data)), "checksum mismatch")
+
+	e := &indexEntry{Package: "hello", Version: "2.12-r0", Checksum: checksum}
+	base, err := cachedPackageFile(e)
+	assert.NilError(t, err)
+	sum := sha1.Sum(control)
+	assert.Equal(t, "hello-2.12-r0."+hex.EncodeToString(sum[:4])+".apk", base)
+}
+
+func TestRepositoryOfIndex(t *testing.T) {
+	for location, expected := range map[string]string{
+		"https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/APKINDEX.tar.gz":  "https://dl-cdn.alpinelinux.org/alpine/v3.16/main",
+		"http://mirror.example.com/alpine/v3.16/community/aarch64/APKINDEX.tar.gz": "http://mirror.example.com/alpine/v3.16/community",
+		"/mnt/mirror/v3.16/main/x86_64/APKINDEX.tar.gz":                            DefaultMirror + "/v3.16/main",
+		"file:///mnt/mirror/v3.16/main/x86_64/APKINDEX.tar.gz":                     DefaultMirror + "/v3.16/main",
+	} {
+		got, err := repositoryOfIndex(location)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, got)
+	}
+	_, err := repositoryOfIndex("https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/APKINDEX.8a3f.tar.gz")
+	assert.ErrorContains(t, err, "expected the URL")
+	_, err = repositoryOfIndex("/var/cache/apk/APKINDEX.tar.gz")
+	assert.ErrorContains(t, err, "failed to detect the repository")
+}
+
+func TestReadIndexLocation(t *testing.T) {
+	const index = `C:Q1Ai5bvcvJKAYpFe7y5JjjHspLWyI=
+P:ca-certificates-bundle
+V:20220614-r0
+A:x86_64
+`
+	file := filepath.Join(t.TempDir(), "v3.16", "main", "x86_64", "APKINDEX.tar.gz")
+	assert.NilError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	b := gzipTar(t, map[string]string{"APKINDEX": index}, true)
+	assert.NilError(t, os.WriteFile(file, b, 0644))
+	entries, sha256sum, err := readIndexLocation(context.TODO(), urlopener.New(), file, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, digest.SHA256.FromBytes(b).Encoded(), sha256sum)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, DefaultMirror+"/v3.16/main/x86_64/ca-certificates-bundle-20220614-r0.apk", entries[0].URL())
+}
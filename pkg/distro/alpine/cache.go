@@ -0,0 +1,104 @@
+package alpine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/sirupsen/logrus"
+)
+
+// ImportPackageCache imports the package files in PackageCacheDir and IndexCacheDir of the root filesystem into the cache,
+// and returns map[basename]sha256sum .
+//
+// The origin URLs are recorded from the cached APKINDEX files, after verifying the checksums of the package files.
+// Otherwise the "file://" URLs are recorded, as in cache.Import.
+func ImportPackageCache(c *cache.Cache, root string) (map[string]string, error) {
+	if root == "" {
+		root = "/"
+	}
+	var (
+		files []string
+		seen  = make(map[string]struct{})
+	)
+	for _, d := range []string{PackageCacheDir, IndexCacheDir} {
+		dir, err := securejoin.SecureJoin(root, d)
+		if err != nil {
+			return nil, err
+		}
+		ents, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, ent := range ents {
+			name := ent.Name()
+			if !ent.Type().IsRegular() || !strings.HasSuffix(name, ".apk") {
+				continue
+			}
+			// PackageCacheDir is often a symlink to IndexCacheDir
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			files = append(files, filepath.Join(dir, name)) // no need to use securejoin (name is a base name)
+		}
+	}
+	m := make(map[string]string)
+	if len(files) == 0 {
+		return m, nil
+	}
+	entries := make(map[string]*indexEntry)
+	indexes, err := readIndexes(root, nil, nil)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to read the indexes, not recording the origin URLs")
+	}
+	for i := range indexes {
+		e := &indexes[i]
+		base, err := cachedPackageFile(e)
+		if err != nil {
+			continue
+		}
+		entries[base] = e
+	}
+	for _, file := range files {
+		sha256sum, err := importCachedPackage(c, file, entries[filepath.Base(file)])
+		if err != nil {
+			return m, fmt.Errorf("failed to import %q: %w", file, err)
+		}
+		m[filepath.Base(file)] = sha256sum
+	}
+	return m, nil
+}
+
+func importCachedPackage(c *cache.Cache, file string, e *indexEntry) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	u, err := url.Parse("file://" + file)
+	if err != nil {
+		return "", err
+	}
+	if e != nil {
+		if err = verifyQ1(f, e.Checksum); err != nil {
+			logrus.WithError(err).Warnf("Failed to verify %q, not recording the origin URL", file)
+		} else if u, err = url.Parse(e.URL()); err != nil {
+			return "", err
+		}
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+	logrus.Debugf("Importing %q (origin %q)", file, u.Redacted())
+	return c.ImportWithReaderAndURL(f, u)
+}
@@ -0,0 +1,52 @@
+package alpine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"gotest.tools/v3/assert"
+)
+
+func TestImportPackageCache(t *testing.T) {
+	root := t.TempDir()
+	const repo = "https://dl-cdn.alpinelinux.org/alpine/v3.16/main"
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(RepositoriesFile)), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, RepositoriesFile), []byte(repo+"\n"), 0644))
+	indexCacheDir := filepath.Join(root, IndexCacheDir)
+	assert.NilError(t, os.MkdirAll(indexCacheDir, 0755))
+	assert.NilError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, PackageCacheDir)), 0755))
+	assert.NilError(t, os.Symlink(indexCacheDir, filepath.Join(root, PackageCacheDir)))
+
+	control := gzipTar(t, map[string]string{".PKGINFO": "pkgname = hello\n"}, false)
+	data := gzipTar(t, map[string]string{"usr/bin/hello": "hello"}, true)
+	apk := bytes.Join([][]byte{control, data}, nil)
+	e := &indexEntry{Package: "hello", Version: "2.12-r0", Architecture: "x86_64", Checksum: q1(control), Repository: repo}
+	index := "C:" + e.Checksum + "\nP:" + e.Package + "\nV:" + e.Version + "\nA:" + e.Architecture + "\n"
+	assert.NilError(t, os.WriteFile(filepath.Join(indexCacheDir, indexCacheFile(repo)),
+		gzipTar(t, map[string]string{"APKINDEX": index}, true), 0644))
+	base, err := cachedPackageFile(e)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(indexCacheDir, base), apk, 0644))
+	unknown := []byte("unknown")
+	assert.NilError(t, os.WriteFile(filepath.Join(indexCacheDir, "unknown-1.0-r0.01234567.apk"), unknown, 0644))
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	imported, err := ImportPackageCache(c, root)
+	assert.NilError(t, err)
+	sha256sum := digest.SHA256.FromBytes(apk).Encoded()
+	assert.DeepEqual(t, map[string]string{
+		base:                          sha256sum,
+		"unknown-1.0-r0.01234567.apk": digest.SHA256.FromBytes(unknown).Encoded(),
+	}, imported)
+	u, err := c.OriginURLBySHA256(sha256sum)
+	assert.NilError(t, err)
+	assert.Equal(t, e.URL(), u.String())
+	u, err = c.OriginURLBySHA256(digest.SHA256.FromBytes(unknown).Encoded())
+	assert.NilError(t, err)
+	assert.Equal(t, "file", u.Scheme)
+}
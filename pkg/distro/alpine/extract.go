@@ -0,0 +1,398 @@
+package alpine
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/rootfs"
+	"github.com/sirupsen/logrus"
+)
+
+// paxChecksum is the PAX record that contains the SHA1 checksum of the file in the data segment.
+const paxChecksum = "APK-TOOLS.checksum.SHA1"
+
+// pkgInfoFields maps the keys of .PKGINFO to the fields of the installed database.
+var pkgInfoFields = []struct {
+	key   string
+	field string
+}{
+	{"pkgname", "P"},
+	{"pkgver", "V"},
+	{"arch", "A"},
+	{"size", "I"},
+	{"pkgdesc", "T"},
+	{"url", "U"},
+	{"license", "L"},
+	{"origin", "o"},
+	{"maintainer", "m"},
+	{"builddate", "t"},
+	{"commit", "c"},
+	{"provider_priority", "k"},
+	{"depend", "D"},
+	{"provides", "p"},
+	{"replaces", "r"},
+	{"install_if", "i"},
+}
+
+// installedFile is a file recorded in the installed database.
+type installedFile struct {
+	name     string // base name
+	checksum string // "Q1..." or empty
+}
+
+// extractedPackage is the result of extracting a package.
+type extractedPackage struct {
+	pkgInfo  map[string][]string // .PKGINFO
+	checksum string              // "Q1..." checksum of the control segment
+	size     int64               // size of the package file
+	dirs     []string            // directories, in the order of appearance
+	files    map[string][]installedFile
+	scripts  []string // such as ".post-install"
+}
+
+// dbEntry returns the entry for the installed database.
+func (p *extractedPackage) dbEntry() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "C:%s\n", p.checksum)
+	for _, f := range pkgInfoFields {
+		v, ok := p.pkgInfo[f.key]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%s\n", f.field, strings.Join(v, " "))
+		if f.key == "arch" {
+			fmt.Fprintf(&b, "S:%d\n", p.size)
+		}
+	}
+	for _, dir := range p.dirs {
+		fmt.Fprintf(&b, "F:%s\n", dir)
+		for _, f := range p.files[dir] {
+			fmt.Fprintf(&b, "R:%s\n", f.name)
+			if f.checksum != "" {
+				fmt.Fprintf(&b, "Z:%s\n", f.checksum)
+			}
+		}
+	}
+	return b.String()
+}
+
+// installPackagesToRoot installs the packages into the root filesystem without using the apk binary,
+// by extracting the package files and updating the installed database and the world file.
+// The maintainer scripts such as ".post-install" are not executed.
+func installPackagesToRoot(c *cache.Cache, pkgs []filespec.FileSpec, root string) error {
+	// The downloader cannot detect the packages installed in the root filesystem, so they are skipped here
+	installed := make(map[string]string)
+	installedEntries, err := readInstalledDB(root)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, e := range installedEntries {
+		installed[e.Package] = e.Version
+	}
+	logrus.Infof("Extracting %d packages into %q", len(pkgs), root)
+	var extracted []*extractedPackage
+	for _, pkg := range pkgs {
+		if pkg.APK != nil && installed[pkg.APK.Package] == pkg.APK.Version {
+			logrus.Infof("%q: already installed", pkg.Basename)
+			continue
+		}
+		blob, err := c.BlobAbsPath(pkg.Sum())
+		if err != nil {
+			return err
+		}
+		p, err := extractPackageFile(blob, root)
+		if err != nil {
+			return fmt.Errorf("failed to extract %q: %w", pkg.Basename, err)
+		}
+		if len(p.scripts) > 0 {
+			logrus.Warnf("%q: not executing the scripts %v", pkg.Basename, p.scripts)
+		}
+		extracted = append(extracted, p)
+	}
+	if len(extracted) == 0 {
+		return nil
+	}
+	if err := updateInstalledDB(root, extracted); err != nil {
+		return fmt.Errorf("failed to update the installed database: %w", err)
+	}
+	if err := updateWorld(root, extracted); err != nil {
+		return fmt.Errorf("failed to update the world file: %w", err)
+	}
+	return nil
+}
+
+func extractPackageFile(file, root string) (*extractedPackage, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := controlChecksum(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	p, err := extractPackage(f, root)
+	if err != nil {
+		return nil, err
+	}
+	p.checksum = checksum
+	p.size = st.Size()
+	return p, nil
+}
+
+// controlChecksum returns the "Q1..." checksum of the control segment, i.e., the gzip stream that contains ".PKGINFO".
+func controlChecksum(r io.Reader) (string, error) {
+	hr := &hashingByteReader{r: bufio.NewReader(r)}
+	var gzR *gzip.Reader
+	for i := 0; i < 2; i++ { // signature (optional), control
+		hr.h = sha1.New()
+		var err error
+		if gzR == nil {
+			gzR, err = gzip.NewReader(hr)
+		} else {
+			err = gzR.Reset(hr)
+		}
+		if err != nil {
+			return "", err
+		}
+		gzR.Multistream(false)
+		var hasPkgInfo bool
+		tr := tar.NewReader(gzR)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			if hdr.Name == ".PKGINFO" {
+				hasPkgInfo = true
+			}
+		}
+		// Consume the rest of the stream
+		if _, err = io.Copy(io.Discard, gzR); err != nil {
+			return "", err
+		}
+		if hasPkgInfo {
+			return "Q1" + base64.StdEncoding.EncodeToString(hr.h.Sum(nil)), nil
+		}
+	}
+	return "", errors.New("no .PKGINFO was found")
+}
+
+// isControlFile returns true for the files in the signature segment and the control segment,
+// such as ".SIGN.RSA.*", ".PKGINFO", and ".post-install".
+func isControlFile(name string) bool {
+	return strings.HasPrefix(name, ".") && !strings.Contains(name, "/")
+}
+
+// extractPackage extracts the data segment of the package into the root filesystem.
+//
+// The segments of the package are concatenated gzip streams, and the tar archives
+// of the signature segment and the control segment lack the end-of-archive marker,
+// so the whole package can be read as a single tar archive.
+func extractPackage(r io.Reader, root string) (*extractedPackage, error) {
+	gzR, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzR.Close()
+	p := &extractedPackage{
+		files: make(map[string][]installedFile),
+	}
+	seenDirs := make(map[string]bool)
+	addDir := func(dir string) {
+		if !seenDirs[dir] {
+			seenDirs[dir] = true
+			p.dirs = append(p.dirs, dir)
+		}
+	}
+	inData := false
+	tr := tar.NewReader(gzR)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if !inData && isControlFile(name) {
+			switch {
+			case name == ".PKGINFO":
+				b, err := io.ReadAll(tr)
+				if err != nil {
+					return nil, err
+				}
+				p.pkgInfo = parsePkgInfo(b)
+			case strings.HasPrefix(name, ".SIGN."):
+			default:
+				p.scripts = append(p.scripts, name)
+			}
+			continue
+		}
+		inData = true
+		if p.pkgInfo == nil {
+			return nil, errors.New("no .PKGINFO was found")
+		}
+		if name == "" || name == "." {
+			continue
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			addDir(name)
+		} else {
+			addDir(path.Dir(name))
+		}
+		checksum, err := extractEntry(tr, hdr, root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %q: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if pax, ok := hdr.PAXRecords[paxChecksum]; ok {
+				if b, err := hex.DecodeString(pax); err == nil {
+					checksum = "Q1" + base64.StdEncoding.EncodeToString(b)
+				}
+			}
+			dir := path.Dir(name)
+			p.files[dir] = append(p.files[dir], installedFile{name: path.Base(name), checksum: checksum})
+		}
+	}
+	if p.pkgInfo == nil {
+		return nil, errors.New("no .PKGINFO was found")
+	}
+	return p, nil
+}
+
+// extractEntry extracts the tar entry into the root filesystem.
+// Returns the "Q1..." checksum for regular files.
+func extractEntry(tr *tar.Reader, hdr *tar.Header, root string) (string, error) {
+	h := sha1.New()
+	if err := rootfs.ExtractEntry(tr, hdr, root, h); err != nil {
+		return "", err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return "", nil
+	}
+	return "Q1" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// parsePkgInfo parses .PKGINFO, which consists of "key = value" lines.
+func parsePkgInfo(b []byte) map[string][]string {
+	res := make(map[string][]string)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		res[k] = append(res[k], v)
+	}
+	return res
+}
+
+// updateInstalledDB appends the entries of the packages to the installed database,
+// replacing the existing entries of the same package names.
+func updateInstalledDB(root string, pkgs []*extractedPackage) error {
+	file, err := securejoin.SecureJoin(root, InstalledDBFile)
+	if err != nil {
+		return err
+	}
+	old, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	replaced := make(map[string]bool, len(pkgs))
+	for _, p := range pkgs {
+		for _, name := range p.pkgInfo["pkgname"] {
+			replaced[name] = true
+		}
+	}
+	var b bytes.Buffer
+	for _, entry := range strings.Split(string(old), "\n\n") {
+		entry = strings.Trim(entry, "\n")
+		if entry == "" {
+			continue
+		}
+		var name string
+		for _, line := range strings.Split(entry, "\n") {
+			if strings.HasPrefix(line, "P:") {
+				name = strings.TrimPrefix(line, "P:")
+				break
+			}
+		}
+		if replaced[name] {
+			logrus.Debugf("Replacing the installed database entry of %q", name)
+			continue
+		}
+		b.WriteString(entry + "\n\n")
+	}
+	for _, p := range pkgs {
+		b.WriteString(p.dbEntry() + "\n")
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, b.Bytes(), 0644)
+}
+
+// updateWorld adds the package names to the world file.
+func updateWorld(root string, pkgs []*extractedPackage) error {
+	file, err := securejoin.SecureJoin(root, WorldFile)
+	if err != nil {
+		return err
+	}
+	old, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	seen := make(map[string]bool)
+	var deps []string
+	for _, dep := range strings.Fields(string(old)) {
+		name, _, _ := splitDependency(dep)
+		seen[name] = true
+		deps = append(deps, dep)
+	}
+	for _, p := range pkgs {
+		for _, name := range p.pkgInfo["pkgname"] {
+			if !seen[name] {
+				seen[name] = true
+				deps = append(deps, name)
+			}
+		}
+	}
+	sort.Strings(deps)
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(strings.Join(deps, "\n")+"\n"), 0644)
+}
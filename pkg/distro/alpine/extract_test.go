@@ -0,0 +1,87 @@
+package alpine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExtractPackage(t *testing.T) {
+	const pkgInfo = `# Generated by abuild
+pkgname = hello
+pkgver = 2.12-r0
+pkgdesc = Hello world
+arch = x86_64
+size = 12345
+depend = so:libc.musl-x86_64.so.1
+depend = busybox
+`
+	sig := gzipTar(t, map[string]string{".SIGN.RSA.dummy.rsa.pub": "dummy"}, false)
+	control := gzipTar(t, map[string]string{".PKGINFO": pkgInfo, ".post-install": "#!/bin/sh\n"}, false)
+
+	var dataTar bytes.Buffer
+	tw := tar.NewWriter(&dataTar)
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "usr/", Mode: 0755}))
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "usr/bin/", Mode: 0755}))
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "usr/bin/hello", Mode: 0755, Size: 5}))
+	_, err := tw.Write([]byte("hello"))
+	assert.NilError(t, err)
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "usr/bin/hi", Linkname: "hello", Mode: 0777}))
+	assert.NilError(t, tw.Close())
+	var data bytes.Buffer
+	gw := gzip.NewWriter(&data)
+	_, err = gw.Write(dataTar.Bytes())
+	assert.NilError(t, err)
+	assert.NilError(t, gw.Close())
+
+	apk := bytes.Join([][]byte{sig, control, data.Bytes()}, nil)
+	checksum, err := controlChecksum(bytes.NewReader(apk))
+	assert.NilError(t, err)
+	assert.Equal(t, q1(control), checksum)
+
+	root := t.TempDir()
+	p, err := extractPackage(bytes.NewReader(apk), root)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{".post-install"}, p.scripts)
+	b, err := os.ReadFile(filepath.Join(root, "usr/bin/hello"))
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", string(b))
+	link, err := os.Readlink(filepath.Join(root, "usr/bin/hi"))
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", link)
+
+	p.checksum = checksum
+	p.size = int64(len(apk))
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(InstalledDBFile)), 0755))
+	const otherEntries = "C:Q1dummy=\nP:hello\nV:2.11-r0\n\nC:Q1dummy=\nP:musl\nV:1.2.3-r0\n\n"
+	assert.NilError(t, os.WriteFile(filepath.Join(root, InstalledDBFile), []byte(otherEntries), 0644))
+	assert.NilError(t, updateInstalledDB(root, []*extractedPackage{p}))
+	assert.NilError(t, updateWorld(root, []*extractedPackage{p}))
+
+	db, err := os.ReadFile(filepath.Join(root, InstalledDBFile))
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(string(db), "V:2.11-r0"))
+	entries, err := parseIndexText(bytes.NewReader(db), "")
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "musl", entries[0].Package)
+	assert.DeepEqual(t, indexEntry{
+		Package:      "hello",
+		Version:      "2.12-r0",
+		Architecture: "x86_64",
+		Checksum:     checksum,
+		Description:  "Hello world",
+		Depends:      []string{"so:libc.musl-x86_64.so.1", "busybox"},
+	}, entries[1])
+	assert.Assert(t, strings.Contains(string(db), "F:usr/bin\nR:hello\nZ:"+q1([]byte("hello"))+"\nR:hi\n"))
+
+	world, err := readWorld(root)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"hello"}, world)
+}
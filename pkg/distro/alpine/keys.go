@@ -0,0 +1,163 @@
+package alpine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/keyring"
+	"github.com/sirupsen/logrus"
+)
+
+// ExtractKeys extracts the RSA public keys ("*.rsa.pub") from the *.apk file of a keyring package, such as "alpine-keys".
+func (d *alpine) ExtractKeys(ctx context.Context, file string) (map[string][]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res, err := extractKeys(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the keys from %q: %w", file, err)
+	}
+	return res, nil
+}
+
+func extractKeys(r io.Reader) (map[string][]byte, error) {
+	gzR, err := gzip.NewReader(r) // Reads all the segments (signature, control, and data)
+	if err != nil {
+		return nil, err
+	}
+	defer gzR.Close()
+	res := make(map[string][]byte)
+	tr := tar.NewReader(gzR)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if hdr.Typeflag != tar.TypeReg || isControlFile(name) || !strings.HasSuffix(name, keyring.RSASuffix) {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		res[path.Base(name)] = b
+	}
+}
+
+// indexVerifier verifies the signatures of APKINDEX.tar.gz with the trusted RSA keys.
+type indexVerifier struct {
+	keys          map[string]*rsa.PublicKey // keyed by the file names, such as "alpine-devel@lists.alpinelinux.org-6165ee59.rsa.pub"
+	allowUnsigned bool
+}
+
+// newIndexVerifier returns the verifier for the RSA keys in opts.Keyrings and opts.ExtraKeyrings.
+// Returns nil when no RSA key is specified, i.e., APKINDEX.tar.gz is not verified, as in the previous versions.
+func newIndexVerifier(opts distro.HashOpts) (*indexVerifier, error) {
+	var files []string
+	for _, pattern := range opts.Keyrings {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	files = append(files, opts.ExtraKeyrings...)
+	keys, err := keyring.ReadRSAKeys(files...)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		logrus.Debug("Not verifying the signatures of APKINDEX.tar.gz, as no RSA key was specified")
+		return nil, nil
+	}
+	return &indexVerifier{keys: keys, allowUnsigned: opts.AllowUnsigned}, nil
+}
+
+// verify verifies APKINDEX.tar.gz. Nil iv skips the verification.
+func (iv *indexVerifier) verify(b []byte, location string) error {
+	if iv == nil {
+		return nil
+	}
+	keyName, err := verifyIndexSignature(b, iv.keys)
+	if err != nil {
+		if iv.allowUnsigned {
+			logrus.WithError(err).Warnf("Failed to verify %q", location)
+			return nil
+		}
+		return fmt.Errorf("failed to verify %q: %w (Hint: add the key with 'repro-get key add', or specify --allow-unsigned)", location, err)
+	}
+	logrus.Debugf("Verified %q with %q", location, keyName)
+	return nil
+}
+
+// verifyIndexSignature verifies the signature segment (".SIGN.RSA.<KEY>" or ".SIGN.RSA256.<KEY>") of APKINDEX.tar.gz,
+// which signs the rest of the file, i.e., the gzip stream of the index, with PKCS #1 v1.5.
+// Returns the name of the key.
+func verifyIndexSignature(b []byte, keys map[string]*rsa.PublicKey) (string, error) {
+	br := bytes.NewReader(b) // io.ByteReader, so that gzip does not read ahead beyond the first stream
+	gzR, err := gzip.NewReader(br)
+	if err != nil {
+		return "", err
+	}
+	defer gzR.Close()
+	gzR.Multistream(false)
+	tr := tar.NewReader(gzR)
+	hdr, err := tr.Next()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the signature: %w", err)
+	}
+	var (
+		h       crypto.Hash
+		hasher  hash.Hash
+		keyName string
+	)
+	switch {
+	case strings.HasPrefix(hdr.Name, ".SIGN.RSA256."):
+		h, hasher, keyName = crypto.SHA256, sha256.New(), strings.TrimPrefix(hdr.Name, ".SIGN.RSA256.")
+	case strings.HasPrefix(hdr.Name, ".SIGN.RSA."):
+		h, hasher, keyName = crypto.SHA1, sha1.New(), strings.TrimPrefix(hdr.Name, ".SIGN.RSA.")
+	default:
+		return "", errors.New("not signed")
+	}
+	if hdr.Size <= 0 || hdr.Size > 64*1024 {
+		return "", fmt.Errorf("unexpected size of the signature: %d", hdr.Size)
+	}
+	sig := make([]byte, hdr.Size)
+	if _, err = io.ReadFull(tr, sig); err != nil {
+		return "", fmt.Errorf("failed to read the signature: %w", err)
+	}
+	// Consume the rest of the stream
+	if _, err = io.Copy(io.Discard, gzR); err != nil {
+		return "", err
+	}
+	pub, ok := keys[keyName]
+	if !ok {
+		return "", fmt.Errorf("signed with an untrusted key %q", keyName)
+	}
+	hasher.Write(b[len(b)-br.Len():])
+	if err = rsa.VerifyPKCS1v15(pub, h, hasher.Sum(nil), sig); err != nil {
+		return "", fmt.Errorf("invalid signature of key %q: %w", keyName, err)
+	}
+	return keyName, nil
+}
@@ -0,0 +1,85 @@
+package alpine
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"gotest.tools/v3/assert"
+)
+
+func TestExtractKeys(t *testing.T) {
+	var apk []byte
+	apk = append(apk, gzipTar(t, map[string]string{".PKGINFO": "pkgname = alpine-keys\n"}, false)...)
+	apk = append(apk, gzipTar(t, map[string]string{
+		"usr/share/apk/keys/alpine-devel@lists.alpinelinux.org-6165ee59.rsa.pub": "key",
+		"usr/share/doc/alpine-keys/README":                                       "doc",
+	}, true)...)
+	file := filepath.Join(t.TempDir(), "alpine-keys-2.4-r1.apk")
+	assert.NilError(t, os.WriteFile(file, apk, 0644))
+
+	keys, err := New().(distro.KeyExtractor).ExtractKeys(context.TODO(), file)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string][]byte{"alpine-devel@lists.alpinelinux.org-6165ee59.rsa.pub": []byte("key")}, keys)
+}
+
+func TestVerifyIndexSignature(t *testing.T) {
+	const keyName = "test-61234567.rsa.pub"
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NilError(t, err)
+	stranger, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NilError(t, err)
+	index := gzipTar(t, map[string]string{"APKINDEX": "P:foo\nV:1.0-r0\n"}, true)
+	sum := sha1.Sum(index)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA1, sum[:])
+	assert.NilError(t, err)
+	signed := append(gzipTar(t, map[string]string{".SIGN.RSA." + keyName: string(sig)}, false), index...)
+
+	got, err := verifyIndexSignature(signed, map[string]*rsa.PublicKey{keyName: &priv.PublicKey})
+	assert.NilError(t, err)
+	assert.Equal(t, keyName, got)
+	_, err = verifyIndexSignature(signed, map[string]*rsa.PublicKey{keyName: &stranger.PublicKey})
+	assert.ErrorContains(t, err, "invalid signature")
+	_, err = verifyIndexSignature(signed, map[string]*rsa.PublicKey{"other.rsa.pub": &priv.PublicKey})
+	assert.ErrorContains(t, err, "untrusted key")
+	_, err = verifyIndexSignature(index, map[string]*rsa.PublicKey{keyName: &priv.PublicKey})
+	assert.ErrorContains(t, err, "not signed")
+	tampered := append(gzipTar(t, map[string]string{".SIGN.RSA." + keyName: string(sig)}, false),
+		gzipTar(t, map[string]string{"APKINDEX": "P:foo\nV:1.1-r0\n"}, true)...)
+	_, err = verifyIndexSignature(tampered, map[string]*rsa.PublicKey{keyName: &priv.PublicKey})
+	assert.ErrorContains(t, err, "invalid signature")
+
+	// Verify via readIndexLocation, with the key file
+	dir := t.TempDir()
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	assert.NilError(t, err)
+	keyFile := filepath.Join(dir, keyName)
+	assert.NilError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	iv, err := newIndexVerifier(distro.HashOpts{ExtraKeyrings: []string{keyFile, filepath.Join(dir, "ignored.asc")}})
+	assert.NilError(t, err)
+	file := filepath.Join(dir, "v3.16", "main", "x86_64", "APKINDEX.tar.gz")
+	assert.NilError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	assert.NilError(t, os.WriteFile(file, signed, 0644))
+	entries, _, err := readIndexLocation(context.TODO(), urlopener.New(), file, iv)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.NilError(t, os.WriteFile(file, tampered, 0644))
+	_, _, err = readIndexLocation(context.TODO(), urlopener.New(), file, iv)
+	assert.ErrorContains(t, err, "failed to verify")
+	iv.allowUnsigned = true
+	_, _, err = readIndexLocation(context.TODO(), urlopener.New(), file, iv)
+	assert.NilError(t, err)
+
+	iv, err = newIndexVerifier(distro.HashOpts{})
+	assert.NilError(t, err)
+	assert.Assert(t, iv == nil)
+}
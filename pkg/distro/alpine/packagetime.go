@@ -0,0 +1,73 @@
+package alpine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// PackageTime returns the "builddate" of .PKGINFO, which is set to $SOURCE_DATE_EPOCH by abuild.
+func (d *alpine) PackageTime(c *cache.Cache, pkg filespec.FileSpec) (time.Time, error) {
+	if pkg.APK == nil {
+		return time.Time{}, nil
+	}
+	blob, err := c.BlobAbsPath(pkg.Sum())
+	if err != nil {
+		return time.Time{}, err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	t, err := buildDate(f)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the build date of %q: %w", pkg.Basename, err)
+	}
+	return t, nil
+}
+
+// buildDate returns the "builddate" of .PKGINFO in the package.
+// See extractPackage for the format of the package.
+func buildDate(r io.Reader) (time.Time, error) {
+	gzR, err := gzip.NewReader(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer gzR.Close()
+	tr := tar.NewReader(gzR)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if hdr.Name != ".PKGINFO" {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return time.Time{}, err
+		}
+		v := parsePkgInfo(b)["builddate"]
+		if len(v) == 0 {
+			return time.Time{}, errors.New("no builddate was found in .PKGINFO")
+		}
+		sec, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid builddate %q: %w", v[0], err)
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Time{}, errors.New("no .PKGINFO was found")
+}
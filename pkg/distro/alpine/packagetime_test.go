@@ -0,0 +1,22 @@
+package alpine
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBuildDate(t *testing.T) {
+	sig := gzipTar(t, map[string]string{".SIGN.RSA.dummy.rsa.pub": "dummy"}, false)
+	control := gzipTar(t, map[string]string{".PKGINFO": "pkgname = hello\nbuilddate = 1667260800\n"}, false)
+	data := gzipTar(t, map[string]string{"usr/bin/hello": "hello"}, true)
+	got, err := buildDate(bytes.NewReader(bytes.Join([][]byte{sig, control, data}, nil)))
+	assert.NilError(t, err)
+	assert.Equal(t, time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC), got)
+
+	control = gzipTar(t, map[string]string{".PKGINFO": "pkgname = hello\n"}, false)
+	_, err = buildDate(bytes.NewReader(bytes.Join([][]byte{sig, control, data}, nil)))
+	assert.ErrorContains(t, err, "no builddate")
+}
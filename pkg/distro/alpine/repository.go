@@ -0,0 +1,139 @@
+package alpine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// GenerateRepositoryMetadata generates the unsigned APKINDEX.tar.gz files for the cached *.apk files,
+// and returns map[path]content, e.g., "v3.16/main/x86_64/APKINDEX.tar.gz".
+//
+// The repositories have to be used with `apk --allow-untrusted`.
+func GenerateRepositoryMetadata(c *cache.Cache, fileSpecs map[string]*filespec.FileSpec) (map[string][]byte, error) {
+	byDir := make(map[string][]string)
+	for _, sp := range fileSpecs {
+		if !strings.HasSuffix(sp.Name, ".apk") {
+			continue
+		}
+		dir := path.Dir(sp.Name)
+		byDir[dir] = append(byDir[dir], sp.Name)
+	}
+	res := make(map[string][]byte, len(byDir))
+	for dir, names := range byDir {
+		sort.Strings(names)
+		var b strings.Builder
+		for _, name := range names {
+			sp := fileSpecs[name]
+			blob, err := c.BlobAbsPath(sp.Sum())
+			if err != nil {
+				return nil, err
+			}
+			p, err := readPackageFile(blob)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					logrus.Warnf("%q (%s) is not cached", sp.Name, sp.Sum())
+					continue
+				}
+				return nil, fmt.Errorf("failed to read %q: %w", sp.Name, err)
+			}
+			b.WriteString(p.dbEntry())
+			b.WriteString("\n")
+		}
+		index, err := indexArchive(b.String())
+		if err != nil {
+			return nil, err
+		}
+		res[path.Join(dir, "APKINDEX.tar.gz")] = index
+	}
+	return res, nil
+}
+
+// readPackageFile reads .PKGINFO and the checksum of the control segment of the package file, without extracting the files.
+func readPackageFile(file string) (*extractedPackage, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := controlChecksum(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	gzR, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gzR.Close()
+	tr := tar.NewReader(gzR)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("no .PKGINFO was found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if !isControlFile(name) {
+			return nil, errors.New("no .PKGINFO was found")
+		}
+		if name == ".PKGINFO" {
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			return &extractedPackage{
+				pkgInfo:  parsePkgInfo(b),
+				checksum: checksum,
+				size:     st.Size(),
+			}, nil
+		}
+	}
+}
+
+// indexArchive returns APKINDEX.tar.gz that contains the "APKINDEX" text.
+// The timestamps are fixed for reproducibility.
+func indexArchive(text string) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	hdr := &tar.Header{
+		Name:    "APKINDEX",
+		Mode:    0644,
+		Size:    int64(len(text)),
+		ModTime: time.Unix(0, 0),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(tw, text); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
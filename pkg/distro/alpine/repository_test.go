@@ -0,0 +1,52 @@
+package alpine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestGenerateRepositoryMetadata(t *testing.T) {
+	const pkgInfo = `pkgname = hello
+pkgver = 2.12-r0
+pkgdesc = Hello world
+arch = x86_64
+depend = so:libc.musl-x86_64.so.1
+`
+	control := gzipTar(t, map[string]string{".PKGINFO": pkgInfo}, false)
+	data := gzipTar(t, map[string]string{"usr/bin/hello": "hello"}, true)
+	apk := bytes.Join([][]byte{control, data}, nil)
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	sha256sum, err := c.ImportWithReader(bytes.NewReader(apk))
+	assert.NilError(t, err)
+	sp, err := filespec.New("v3.16/main/x86_64/hello-2.12-r0.apk", sha256sum)
+	assert.NilError(t, err)
+	missing, err := filespec.New("v3.16/main/x86_64/missing-1.0-r0.apk", "0000000000000000000000000000000000000000000000000000000000000000")
+	assert.NilError(t, err)
+	fileSpecs := map[string]*filespec.FileSpec{sp.Name: sp, missing.Name: missing}
+
+	m, err := GenerateRepositoryMetadata(c, fileSpecs)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(m))
+	index, ok := m["v3.16/main/x86_64/APKINDEX.tar.gz"]
+	assert.Assert(t, ok)
+	entries, err := parseIndex(bytes.NewReader(index), "http://localhost/v3.16/main")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []indexEntry{
+		{
+			Package:      "hello",
+			Version:      "2.12-r0",
+			Architecture: "x86_64",
+			Checksum:     q1(control),
+			Description:  "Hello world",
+			Depends:      []string{"so:libc.musl-x86_64.so.1"},
+			Repository:   "http://localhost/v3.16/main",
+		},
+	}, entries)
+	assert.Equal(t, "http://localhost/v3.16/main/x86_64/hello-2.12-r0.apk", entries[0].URL())
+}
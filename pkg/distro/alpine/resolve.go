@@ -0,0 +1,135 @@
+package alpine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/apkutil"
+)
+
+// resolver resolves the dependency closure of packages from the APKINDEX entries, without running apk.
+type resolver struct {
+	byName     map[string]*indexEntry
+	byProvides map[string][]*indexEntry // key: provided name such as "so:libc.musl-x86_64.so.1" and "cmd:sh"
+	selected   map[string]*indexEntry
+}
+
+// newResolver creates a resolver. The newest entry is used for each package name.
+// apkArch is used for ignoring the entries of other architectures, unless empty.
+func newResolver(entries []indexEntry, apkArch string) *resolver {
+	r := &resolver{
+		byName:     make(map[string]*indexEntry),
+		byProvides: make(map[string][]*indexEntry),
+		selected:   make(map[string]*indexEntry),
+	}
+	for i := range entries {
+		e := &entries[i]
+		if apkArch != "" && e.Architecture != apkArch && e.Architecture != "noarch" {
+			continue
+		}
+		if old, ok := r.byName[e.Package]; ok && apkutil.CompareVersions(old.Version, e.Version) >= 0 {
+			continue
+		}
+		r.byName[e.Package] = e
+	}
+	for _, e := range r.byName {
+		for _, p := range e.Provides {
+			name, _, _ := splitDependency(p)
+			r.byProvides[name] = append(r.byProvides[name], e)
+		}
+	}
+	for _, v := range r.byProvides {
+		sort.Slice(v, func(i, j int) bool { return v[i].Package < v[j].Package })
+	}
+	return r
+}
+
+// splitDependency splits a dependency string such as "musl>=1.2.3" into "musl", ">=", and "1.2.3".
+func splitDependency(s string) (name, op, ver string) {
+	i := strings.IndexAny(s, "<>=~")
+	if i < 0 {
+		return s, "", ""
+	}
+	j := i
+	for j < len(s) && strings.ContainsRune("<>=~", rune(s[j])) {
+		j++
+	}
+	return s[:i], s[i:j], s[j:]
+}
+
+// resolve returns the dependency closure of the packages, sorted by the package name.
+func (r *resolver) resolve(names []string) ([]*indexEntry, error) {
+	var queue []*indexEntry
+	for _, name := range names {
+		e, err := r.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, e)
+	}
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		if _, ok := r.selected[e.Package]; ok {
+			continue
+		}
+		r.selected[e.Package] = e
+		for _, dep := range e.Depends {
+			if strings.HasPrefix(dep, "!") {
+				// Conflicts
+				continue
+			}
+			found, err := r.lookup(dep)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve the dependency of %q: %w", e.Package, err)
+			}
+			queue = append(queue, found)
+		}
+	}
+	selectedNames := make([]string, 0, len(r.selected))
+	for name := range r.selected {
+		selectedNames = append(selectedNames, name)
+	}
+	sort.Strings(selectedNames)
+	res := make([]*indexEntry, len(selectedNames))
+	for i, name := range selectedNames {
+		res[i] = r.selected[name]
+	}
+	return res, nil
+}
+
+// lookup returns the entry that satisfies the dependency string such as "musl>=1.2.3", "so:libc.musl-x86_64.so.1", and "/bin/sh".
+// A provider that is already selected is preferred.
+func (r *resolver) lookup(dep string) (*indexEntry, error) {
+	name, op, ver := splitDependency(dep)
+	if e, ok := r.byName[name]; ok {
+		if op == "" || apkutil.SatisfiesConstraint(e.Version, op, ver) {
+			return e, nil
+		}
+		return nil, fmt.Errorf("unsatisfiable dependency %q (found version %q)", dep, e.Version)
+	}
+	providers := r.byProvides[name]
+	if op != "" {
+		var filtered []*indexEntry
+		for _, p := range providers {
+			for _, provided := range p.Provides {
+				pName, _, pVer := splitDependency(provided)
+				if pName == name && pVer != "" && apkutil.SatisfiesConstraint(pVer, op, ver) {
+					filtered = append(filtered, p)
+					break
+				}
+			}
+		}
+		providers = filtered
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("unsatisfiable dependency %q (Hint: try 'apk update')", dep)
+	}
+	for _, p := range providers {
+		if _, ok := r.selected[p.Package]; ok {
+			return p, nil
+		}
+	}
+	return providers[0], nil
+}
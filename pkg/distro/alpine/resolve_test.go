@@ -0,0 +1,93 @@
+package alpine
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestResolver(t *testing.T) {
+	const index = `P:curl
+V:7.83.1-r3
+A:x86_64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+D:ca-certificates so:libc.musl-x86_64.so.1 so:libcurl.so.4
+
+P:curl
+V:7.83.1-r2
+A:x86_64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+D:ca-certificates so:libc.musl-x86_64.so.1 so:libcurl.so.4
+
+P:libcurl
+V:7.83.1-r3
+A:x86_64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+D:so:libc.musl-x86_64.so.1 !curl-dev-legacy
+p:so:libcurl.so.4=4.8.0
+
+P:ca-certificates
+V:20220614-r0
+A:x86_64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+D:/bin/sh so:libc.musl-x86_64.so.1
+
+P:musl
+V:1.2.3-r0
+A:x86_64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+p:so:libc.musl-x86_64.so.1=1
+
+P:busybox-binsh
+V:1.35.0-r17
+A:x86_64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+D:busybox=1.35.0-r17
+p:/bin/sh cmd:sh=1.35.0-r17
+
+P:busybox
+V:1.35.0-r17
+A:x86_64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+D:so:libc.musl-x86_64.so.1
+
+P:busybox
+V:1.35.0-r17
+A:aarch64
+C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+D:so:libc.musl-aarch64.so.1
+`
+	entries, err := parseIndexText(strings.NewReader(index), "https://dl-cdn.alpinelinux.org/alpine/v3.16/main")
+	assert.NilError(t, err)
+	resolved, err := newResolver(entries, "x86_64").resolve([]string{"curl"})
+	assert.NilError(t, err)
+	var got []string
+	for _, e := range resolved {
+		got = append(got, e.Package+"-"+e.Version+"."+e.Architecture)
+	}
+	assert.DeepEqual(t, []string{
+		"busybox-1.35.0-r17.x86_64",
+		"busybox-binsh-1.35.0-r17.x86_64",
+		"ca-certificates-20220614-r0.x86_64",
+		"curl-7.83.1-r3.x86_64",
+		"libcurl-7.83.1-r3.x86_64",
+		"musl-1.2.3-r0.x86_64",
+	}, got)
+
+	_, err = newResolver(entries, "x86_64").resolve([]string{"wget"})
+	assert.ErrorContains(t, err, "unsatisfiable dependency")
+}
+
+func TestSplitDependency(t *testing.T) {
+	for s, expected := range map[string][3]string{
+		"musl":                     {"musl", "", ""},
+		"musl>=1.2.3":              {"musl", ">=", "1.2.3"},
+		"busybox=1.35.0-r17":       {"busybox", "=", "1.35.0-r17"},
+		"so:libc.musl-x86_64.so.1": {"so:libc.musl-x86_64.so.1", "", ""},
+		"python3~3.10":             {"python3", "~", "3.10"},
+	} {
+		name, op, ver := splitDependency(s)
+		assert.DeepEqual(t, expected, [3]string{name, op, ver})
+	}
+}
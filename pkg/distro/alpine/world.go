@@ -0,0 +1,162 @@
+package alpine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// WorldFile is the list of the packages that were explicitly installed (with `apk add`).
+	WorldFile = "/etc/apk/world"
+	// InstalledDBFile is the database of the installed packages, in the same format as APKINDEX.
+	InstalledDBFile = "/lib/apk/db/installed"
+)
+
+// readWorld reads the dependency strings in the world file.
+//
+// root is the root filesystem to inspect. An empty string is treated as "/".
+func readWorld(root string) ([]string, error) {
+	if root == "" {
+		root = "/"
+	}
+	file, err := securejoin.SecureJoin(root, WorldFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	deps, err := parseWorld(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", file, err)
+	}
+	return deps, nil
+}
+
+// parseWorld parses the world file.
+//
+// The repository tags such as "@testing" are removed from the dependency strings, e.g., "foo@testing>=1.0" becomes "foo>=1.0".
+// The checksum constraints such as "foo><Q1..." (for the packages installed from local files) are removed too.
+// The conflicts such as "!foo" are skipped.
+func parseWorld(r io.Reader) ([]string, error) {
+	var res []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		for _, dep := range strings.Fields(sc.Text()) {
+			if strings.HasPrefix(dep, "!") {
+				continue
+			}
+			name, op, ver := splitDependency(dep)
+			if i := strings.Index(name, "@"); i >= 0 {
+				name = name[:i]
+			}
+			if name == "" {
+				return nil, fmt.Errorf("unexpected dependency %q", dep)
+			}
+			if op == "" || strings.HasPrefix(ver, "Q1") {
+				res = append(res, name)
+				continue
+			}
+			res = append(res, name+op+ver)
+		}
+	}
+	return res, sc.Err()
+}
+
+// readInstalledDB reads the installed database.
+//
+// root is the root filesystem to inspect. An empty string is treated as "/".
+func readInstalledDB(root string) ([]indexEntry, error) {
+	if root == "" {
+		root = "/"
+	}
+	file, err := securejoin.SecureJoin(root, InstalledDBFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := parseIndexText(f, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", file, err)
+	}
+	return entries, nil
+}
+
+// filterInstalled returns the installed entries of the packages, or all the installed entries when names is empty.
+func filterInstalled(installed []indexEntry, names []string) ([]*indexEntry, error) {
+	byName := make(map[string]*indexEntry, len(installed))
+	res := make([]*indexEntry, 0, len(installed))
+	for i := range installed {
+		e := &installed[i]
+		byName[e.Package] = e
+		if len(names) == 0 {
+			res = append(res, e)
+		}
+	}
+	if len(names) == 0 {
+		if len(res) == 0 {
+			return nil, errors.New("no package is installed?")
+		}
+		return res, nil
+	}
+	for _, name := range names {
+		e, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("package %q is not installed", name)
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// virtualDescription is the description of the virtual packages created with `apk add --virtual`.
+const virtualDescription = "virtual meta package"
+
+// pinToIndexes returns the APKINDEX entries that correspond to the installed entries.
+// The entries are matched by the checksum, or by the name, the version, and the architecture when the checksum is missing.
+// The virtual packages are skipped, as they do not exist in the repositories.
+func pinToIndexes(installed []*indexEntry, indexes []indexEntry) ([]*indexEntry, error) {
+	byChecksum := make(map[string]*indexEntry, len(indexes))
+	byPkgVerArch := make(map[string]*indexEntry, len(indexes))
+	for i := range indexes {
+		e := &indexes[i]
+		if _, ok := byChecksum[e.Checksum]; !ok && e.Checksum != "" {
+			byChecksum[e.Checksum] = e
+		}
+		k := e.Package + "-" + e.Version + "." + e.Architecture
+		if _, ok := byPkgVerArch[k]; !ok {
+			byPkgVerArch[k] = e
+		}
+	}
+	var res []*indexEntry
+	for _, inst := range installed {
+		if e, ok := byChecksum[inst.Checksum]; ok && inst.Checksum != "" {
+			res = append(res, e)
+			continue
+		}
+		if e, ok := byPkgVerArch[inst.Package+"-"+inst.Version+"."+inst.Architecture]; ok {
+			res = append(res, e)
+			continue
+		}
+		if inst.Description == virtualDescription {
+			logrus.Debugf("Skipping virtual package %q", inst.Package)
+			continue
+		}
+		return nil, fmt.Errorf("installed package %s-%s was not found in the APKINDEX files (Hint: try 'apk update', or try '--resolve' for the latest version)",
+			inst.Package, inst.Version)
+	}
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package alpine
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseWorld(t *testing.T) {
+	const world = `alpine-base
+curl@edge>=7.83
+python3~3.10
+hello><Q1Ai5bvcvJKAYpFe7y5JjjHspLWyI=
+!wget
+.build-deps
+`
+	got, err := parseWorld(strings.NewReader(world))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"alpine-base", "curl>=7.83", "python3~3.10", "hello", ".build-deps"}, got)
+}
+
+func TestPinToIndexes(t *testing.T) {
+	const installedDB = `C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+P:curl
+V:7.83.1-r2
+A:x86_64
+D:so:libcurl.so.4
+
+C:Q1BBBBBBBBBBBBBBBBBBBBBBBBBBBBB=
+P:libcurl
+V:7.83.1-r2
+A:x86_64
+p:so:libcurl.so.4=4.8.0
+
+C:Q1CCCCCCCCCCCCCCCCCCCCCCCCCCCCC=
+P:.build-deps
+V:20221014.000000
+A:noarch
+T:virtual meta package
+D:curl
+`
+	const index = `C:Q1AAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+P:curl
+V:7.83.1-r2
+A:x86_64
+
+C:Q1DDDDDDDDDDDDDDDDDDDDDDDDDDDDD=
+P:curl
+V:7.83.1-r3
+A:x86_64
+
+P:libcurl
+V:7.83.1-r2
+A:x86_64
+`
+	installedEntries, err := parseIndexText(strings.NewReader(installedDB), "")
+	assert.NilError(t, err)
+	indexEntries, err := parseIndexText(strings.NewReader(index), "https://dl-cdn.alpinelinux.org/alpine/v3.16/main")
+	assert.NilError(t, err)
+
+	pinned, err := newResolver(installedEntries, "").resolve([]string{".build-deps"})
+	assert.NilError(t, err)
+	resolved, err := pinToIndexes(pinned, indexEntries)
+	assert.NilError(t, err)
+	var got []string
+	for _, e := range resolved {
+		got = append(got, e.URL())
+	}
+	assert.DeepEqual(t, []string{
+		"https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/curl-7.83.1-r2.apk",
+		"https://dl-cdn.alpinelinux.org/alpine/v3.16/main/x86_64/libcurl-7.83.1-r2.apk",
+	}, got)
+
+	_, err = pinToIndexes(pinned, indexEntries[:1])
+	assert.ErrorContains(t, err, "libcurl-7.83.1-r2 was not found")
+}
+
+func TestFilterInstalled(t *testing.T) {
+	installed := []indexEntry{
+		{Package: "busybox", Version: "1.35.0-r17"},
+		{Package: "musl", Version: "1.2.3-r0"},
+	}
+	all, err := filterInstalled(installed, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(all))
+	filtered, err := filterInstalled(installed, []string{"musl"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []*indexEntry{&installed[1]}, filtered)
+	_, err = filterInstalled(installed, []string{"curl"})
+	assert.ErrorContains(t, err, "not installed")
+}
@@ -0,0 +1,313 @@
+// Package cargo provides the distro driver for Rust crates.
+//
+// The "distro" here is a set of crates recorded in Cargo.lock.
+package cargo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/opencontainers/go-digest"
+	"github.com/pelletier/go-toml"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/cargoutil"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Name            = "cargo"
+	DefaultLockfile = "Cargo.lock"
+	// DefaultVendorDir is the directory where the crates are vendored, relative to the current directory.
+	DefaultVendorDir = "vendor"
+)
+
+var ErrNotImplemented = fmt.Errorf("distro driver %q does not implement the requested feature", Name)
+
+func New() distro.Distro {
+	d := &cargo{
+		info: distro.Info{
+			Name: Name,
+			DefaultProviders: []string{
+				"https://static.crates.io/crates/{{.Name}}",
+			},
+			Experimental: true,
+		},
+	}
+	return d
+}
+
+type cargo struct {
+	info distro.Info
+}
+
+func (d *cargo) Info() distro.Info {
+	return d.info
+}
+
+// Entry is a crates.io package entry of Cargo.lock.
+type Entry struct {
+	Package  string // "serde"
+	Version  string // "1.0.147"
+	Checksum string // sha256 of the crate
+}
+
+// Filename returns a file name like "serde/serde-1.0.147.crate".
+func (e *Entry) Filename() string {
+	return cargoutil.Filename(e.Package, e.Version)
+}
+
+type lockfile struct {
+	Package  []lockfilePkg     `toml:"package"`
+	Metadata map[string]string `toml:"metadata"` // Cargo.lock v1
+}
+
+type lockfilePkg struct {
+	Name     string `toml:"name"`
+	Version  string `toml:"version"`
+	Source   string `toml:"source"`
+	Checksum string `toml:"checksum"`
+}
+
+func isCratesIO(source string) bool {
+	switch source {
+	case "registry+https://github.com/rust-lang/crates.io-index", "sparse+https://index.crates.io/":
+		return true
+	}
+	return false
+}
+
+// ParseLockfile parses Cargo.lock.
+// The result is sorted by the file name.
+// Path dependencies, git dependencies, and third-party registries are skipped.
+func ParseLockfile(r io.Reader) ([]Entry, error) {
+	var lf lockfile
+	if err := toml.NewDecoder(r).Decode(&lf); err != nil {
+		return nil, err
+	}
+	m := make(map[string]Entry)
+	for _, f := range lf.Package {
+		if f.Source == "" {
+			// path dependency
+			continue
+		}
+		if !isCratesIO(f.Source) {
+			logrus.Warnf("Skipping %s %s (unsupported source %q)", f.Name, f.Version, f.Source)
+			continue
+		}
+		checksum := f.Checksum
+		if checksum == "" {
+			// Cargo.lock v1: `"checksum serde 1.0.147 (registry+https://...)" = "<SHA256>"`
+			checksum = lf.Metadata[fmt.Sprintf("checksum %s %s (%s)", f.Name, f.Version, f.Source)]
+		}
+		if err := digest.SHA256.Validate(checksum); err != nil {
+			return nil, fmt.Errorf("invalid checksum for %s %s: %w", f.Name, f.Version, err)
+		}
+		e := Entry{
+			Package:  f.Name,
+			Version:  f.Version,
+			Checksum: checksum,
+		}
+		m[e.Filename()] = e
+	}
+	var fnames []string
+	for f := range m {
+		fnames = append(fnames, f)
+	}
+	sort.Strings(fnames)
+	res := make([]Entry, len(fnames))
+	for i, f := range fnames {
+		res[i] = m[f]
+	}
+	return res, nil
+}
+
+func (d *cargo) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
+	lockfile := opts.Lockfile
+	if lockfile == "" {
+		lockfile = DefaultLockfile
+	}
+	f, err := os.Open(lockfile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := ParseLockfile(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse %q: %w", lockfile, err)
+	}
+	filter := make(map[string]struct{}, len(opts.FilterByName))
+	for _, f := range opts.FilterByName {
+		filter[f] = struct{}{}
+	}
+	for _, e := range entries {
+		if len(filter) > 0 {
+			if _, ok := filter[e.Package]; !ok {
+				continue
+			}
+		}
+		if err := hw(e.Checksum, e.Filename()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *cargo) PackageName(sp filespec.FileSpec) (string, error) {
+	if sp.Crate == nil {
+		return "", fmt.Errorf("crate information not available for %q", sp.Name)
+	}
+	return sp.Crate.Package, nil
+}
+
+// cargoChecksum is the ".cargo-checksum.json" file in a vendored crate directory.
+type cargoChecksum struct {
+	Files   map[string]string `json:"files"`
+	Package string            `json:"package"`
+}
+
+func vendoredDir(sp filespec.FileSpec) (string, error) {
+	if sp.Crate == nil {
+		return "", fmt.Errorf("crate information not available for %q", sp.Name)
+	}
+	return securejoin.SecureJoin(DefaultVendorDir, sp.Crate.Package+"-"+sp.Crate.Version)
+}
+
+func (d *cargo) IsPackageVersionInstalled(ctx context.Context, sp filespec.FileSpec) (bool, error) {
+	dir, err := vendoredDir(sp)
+	if err != nil {
+		return false, err
+	}
+	b, err := os.ReadFile(filepath.Join(dir, ".cargo-checksum.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	var cc cargoChecksum
+	if err = json.Unmarshal(b, &cc); err != nil {
+		return false, err
+	}
+	return cc.Package == sp.SHA256, nil
+}
+
+// InstallPackages vendors the crates into DefaultVendorDir, in the same layout as `cargo vendor --versioned-dirs`.
+func (d *cargo) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
+	logrus.Infof("Vendoring %d crates into %q", len(pkgs), DefaultVendorDir)
+	for _, pkg := range pkgs {
+		dir, err := vendoredDir(pkg)
+		if err != nil {
+			return err
+		}
+		blob, err := c.BlobAbsPath(pkg.Sum())
+		if err != nil {
+			return err
+		}
+		if err = os.RemoveAll(dir); err != nil {
+			return err
+		}
+		files, err := extractCrate(dir, blob, pkg.Crate.Package+"-"+pkg.Crate.Version)
+		if err != nil {
+			return fmt.Errorf("failed to extract %q: %w", pkg.Name, err)
+		}
+		b, err := json.Marshal(cargoChecksum{Files: files, Package: pkg.SHA256})
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(dir, ".cargo-checksum.json"), b, 0644); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("Add the following lines to .cargo/config.toml:\n" +
+		"[source.crates-io]\nreplace-with = \"vendored-sources\"\n\n" +
+		"[source.vendored-sources]\ndirectory = \"" + DefaultVendorDir + "\"")
+	return nil
+}
+
+// extractCrate extracts the crate into dir, and returns the sha256sum map of the extracted files.
+// The top-level directory (topDir) of the archive is stripped.
+func extractCrate(dir, crate, topDir string) (map[string]string, error) {
+	f, err := os.Open(crate)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gzR, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gzR.Close()
+	files := make(map[string]string)
+	tr := tar.NewReader(gzR)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return files, err
+		}
+		rel := strings.TrimPrefix(path.Clean(hdr.Name), topDir+"/")
+		if rel == topDir {
+			continue
+		}
+		dst, err := securejoin.SecureJoin(dir, rel)
+		if err != nil {
+			return files, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(dst, 0755); err != nil {
+				return files, err
+			}
+		case tar.TypeReg:
+			sha256sum, err := writeFile(dst, tr, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return files, err
+			}
+			files[rel] = sha256sum
+		default:
+			logrus.Warnf("Ignoring %q (unsupported type %q)", hdr.Name, hdr.Typeflag)
+		}
+	}
+	return files, nil
+}
+
+func writeFile(dst string, r io.Reader, perm os.FileMode) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return "", err
+	}
+	defer w.Close()
+	digester := digest.SHA256.Digester()
+	if _, err = io.Copy(io.MultiWriter(w, digester.Hash()), r); err != nil {
+		return "", err
+	}
+	return digester.Digest().Encoded(), w.Close()
+}
+
+func (d *cargo) GenerateDockerfile(ctx context.Context, dir string, args distro.DockerfileTemplateArgs, opts distro.DockerfileOpts) error {
+	return ErrNotImplemented
+}
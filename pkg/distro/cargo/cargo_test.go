@@ -0,0 +1,73 @@
+package cargo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseLockfile(t *testing.T) {
+	const s = `# This file is automatically @generated by Cargo.
+# It is not intended for manual editing.
+version = 3
+
+[[package]]
+name = "foo"
+version = "0.1.0"
+dependencies = [
+ "itoa",
+]
+
+[[package]]
+name = "itoa"
+version = "1.0.4"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "4217ad341ebadf8d8e724e264f13e593e0648f5b3e94b3896a5df283be015ecc"
+
+[[package]]
+name = "bar"
+version = "0.2.0"
+source = "git+https://example.com/bar.git#0123456789abcdef"
+`
+	got, err := ParseLockfile(strings.NewReader(s))
+	assert.NilError(t, err)
+	expected := []Entry{
+		{
+			Package:  "itoa",
+			Version:  "1.0.4",
+			Checksum: "4217ad341ebadf8d8e724e264f13e593e0648f5b3e94b3896a5df283be015ecc",
+		},
+	}
+	assert.DeepEqual(t, expected, got)
+	assert.Equal(t, "itoa/itoa-1.0.4.crate", got[0].Filename())
+}
+
+func TestExtractCrate(t *testing.T) {
+	tmp := t.TempDir()
+	crate := filepath.Join(tmp, "foo-0.1.0.crate")
+	f, err := os.Create(crate)
+	assert.NilError(t, err)
+	gzW := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzW)
+	const content = "fn main() {}\n"
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Name: "foo-0.1.0/src/main.rs", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+	_, err = tw.Write([]byte(content))
+	assert.NilError(t, err)
+	assert.NilError(t, tw.Close())
+	assert.NilError(t, gzW.Close())
+	assert.NilError(t, f.Close())
+
+	dir := filepath.Join(tmp, "vendor", "foo-0.1.0")
+	files, err := extractCrate(dir, crate, "foo-0.1.0")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"src/main.rs": digest.SHA256.FromString(content).Encoded()}, files)
+	b, err := os.ReadFile(filepath.Join(dir, "src", "main.rs"))
+	assert.NilError(t, err)
+	assert.Equal(t, content, string(b))
+}
@@ -0,0 +1,148 @@
+package debian
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/sirupsen/logrus"
+)
+
+// ArchivesDir is the directory where apt stores the downloaded package files.
+const ArchivesDir = "/var/cache/apt/archives"
+
+// ImportArchives imports the package files in ArchivesDir of the root filesystem into the cache,
+// and returns map[basename]sha256sum .
+//
+// The origin URLs are recorded from the Packages lists, when the SHA256 of the file is found in the lists,
+// e.g., "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb".
+// Otherwise the "file://" URLs are recorded, as in cache.Import.
+func ImportArchives(c *cache.Cache, root string) (map[string]string, error) {
+	if root == "" {
+		root = "/"
+	}
+	dir, err := securejoin.SecureJoin(root, ArchivesDir)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	filter := make(map[string]struct{})
+	var files []string
+	for _, ent := range ents {
+		name := ent.Name()
+		if !ent.Type().IsRegular() || !strings.HasSuffix(name, ".deb") {
+			continue
+		}
+		dpkg, err := dpkgutil.ParseFilename(name)
+		if err != nil {
+			logrus.WithError(err).Warnf("Ignoring %q", name)
+			continue
+		}
+		filter[dpkg.Package] = struct{}{}
+		files = append(files, filepath.Join(dir, name)) // no need to use securejoin (name is a base name)
+	}
+	m := make(map[string]string)
+	if len(files) == 0 {
+		return m, nil
+	}
+	var poolURLs map[string]*url.URL
+	if lists, err := listsDir(root); err == nil {
+		poolURLs, err = poolURLsBySHA256(lists, filter)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to read the Packages lists, not recording the pool paths")
+		}
+	}
+	for _, file := range files {
+		sha256sum, err := importArchive(c, file, poolURLs)
+		if err != nil {
+			return m, fmt.Errorf("failed to import %q: %w", file, err)
+		}
+		m[filepath.Base(file)] = sha256sum
+	}
+	return m, nil
+}
+
+func importArchive(c *cache.Cache, file string, poolURLs map[string]*url.URL) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	d, err := digest.SHA256.FromReader(f)
+	if err != nil {
+		return "", err
+	}
+	u, ok := poolURLs[d.Encoded()]
+	if !ok {
+		if u, err = url.Parse("file://" + file); err != nil {
+			return "", err
+		}
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	logrus.Debugf("Importing %q (origin %q)", file, u.Redacted())
+	sha256sum, err := c.ImportWithReaderAndURL(f, u)
+	if err != nil {
+		return "", err
+	}
+	if sha256sum != d.Encoded() {
+		return "", fmt.Errorf("%q was modified during the import", file)
+	}
+	return sha256sum, nil
+}
+
+// poolURLsBySHA256 returns the URLs of the package files in the Packages lists, keyed by the SHA256.
+func poolURLsBySHA256(dir string, filter map[string]struct{}) (map[string]*url.URL, error) {
+	paragraphs, err := readPackagesLists(dir, filter, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*url.URL, len(paragraphs))
+	for _, p := range paragraphs {
+		sha256sum, filename := p.Values["SHA256"], p.Values["Filename"]
+		if sha256sum == "" || filename == "" {
+			continue
+		}
+		base, err := repositoryURLOfLists(p.Values[listsFileField])
+		if err != nil {
+			logrus.WithError(err).Debug("Failed to parse the name of the lists file")
+			continue
+		}
+		u := *base
+		u.Path = path.Join(u.Path, filename)
+		res[sha256sum] = &u
+	}
+	return res, nil
+}
+
+// repositoryURLOfLists returns the repository URL from the name of the lists file, e.g.,
+// "http://deb.debian.org/debian" for "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages".
+//
+// The scheme is always "http", as the lists file name does not contain the scheme.
+func repositoryURLOfLists(base string) (*url.URL, error) {
+	sp := strings.Split(base, "_")
+	for i := 1; i < len(sp); i++ {
+		if sp[i] != "dists" {
+			continue
+		}
+		// apt escapes '_' in the URL as "%5f"
+		p, err := url.PathUnescape("/" + strings.Join(sp[1:i], "/"))
+		if err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "http", Host: sp[0], Path: p}, nil
+	}
+	return nil, fmt.Errorf("expected <HOST>_<PATH>_dists_..., got %q", base)
+}
@@ -0,0 +1,68 @@
+package debian
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"gotest.tools/v3/assert"
+)
+
+func TestRepositoryURLOfLists(t *testing.T) {
+	testCases := map[string]string{
+		"deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages":                       "http://deb.debian.org/debian",
+		"ppa.launchpadcontent.net_deadsnakes_ppa_ubuntu_dists_jammy_main_binary-amd64_Packages": "http://ppa.launchpadcontent.net/deadsnakes/ppa/ubuntu",
+		"mirror.example.com_foo%5fbar_dists_stable_main_binary-amd64_Packages":                  "http://mirror.example.com/foo_bar",
+	}
+	for base, expected := range testCases {
+		u, err := repositoryURLOfLists(base)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, u.String())
+	}
+	_, err := repositoryURLOfLists("example.com_Packages")
+	assert.ErrorContains(t, err, "expected")
+}
+
+func TestImportArchives(t *testing.T) {
+	root := t.TempDir()
+	archivesDir := filepath.Join(root, ArchivesDir)
+	assert.NilError(t, os.MkdirAll(filepath.Join(archivesDir, "partial"), 0755))
+	hello, unknown := []byte("hello"), []byte("unknown")
+	helloSHA256, unknownSHA256 := digest.SHA256.FromBytes(hello).Encoded(), digest.SHA256.FromBytes(unknown).Encoded()
+	assert.NilError(t, os.WriteFile(filepath.Join(archivesDir, "hello_2.10-2_amd64.deb"), hello, 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(archivesDir, "unknown_1.0_amd64.deb"), unknown, 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(archivesDir, "lock"), nil, 0640))
+
+	listsDir := filepath.Join(root, ListsDir)
+	assert.NilError(t, os.MkdirAll(listsDir, 0755))
+	packages := `Package: hello
+Version: 2.10-2
+Architecture: amd64
+Filename: pool/main/h/hello/hello_2.10-2_amd64.deb
+SHA256: ` + helloSHA256 + `
+
+Package: unknown
+Version: 1.0
+Architecture: amd64
+Filename: pool/main/u/unknown/unknown_1.0_amd64.deb
+SHA256: 0000000000000000000000000000000000000000000000000000000000000000
+`
+	assert.NilError(t, os.WriteFile(filepath.Join(listsDir, "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages"), []byte(packages), 0644))
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	imported, err := ImportArchives(c, root)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{
+		"hello_2.10-2_amd64.deb": helloSHA256,
+		"unknown_1.0_amd64.deb":  unknownSHA256,
+	}, imported)
+	u, err := c.OriginURLBySHA256(helloSHA256)
+	assert.NilError(t, err)
+	assert.Equal(t, "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb", u.String())
+	u, err = c.OriginURLBySHA256(unknownSHA256)
+	assert.NilError(t, err)
+	assert.Equal(t, "file://"+filepath.Join(archivesDir, "unknown_1.0_amd64.deb"), u.String())
+}
@@ -0,0 +1,460 @@
+package debian
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/rootfs"
+	"github.com/sirupsen/logrus"
+)
+
+// InfoDir is the directory of the dpkg database that contains the file lists and the maintainer scripts.
+const InfoDir = "/var/lib/dpkg/info"
+
+// mergedUsrDirs is the list of the directories to be symlinked into /usr, for the architectures.
+// Derived from debootstrap.
+var mergedUsrDirs = map[string][]string{
+	"amd64":    {"lib32", "lib64", "libx32"},
+	"i386":     {"lib64", "libx32"},
+	"mips64el": {"lib32", "lib64", "libo32"},
+	"ppc64el":  {"lib64"},
+	"s390x":    {"lib32"},
+}
+
+// extractedDeb is the result of extracting a *.deb file.
+type extractedDeb struct {
+	control   []byte            // The "control" file
+	pkg       string            // "hello"
+	arch      string            // "amd64"
+	multiArch string            // "same", etc.
+	info      map[string][]byte // The other files in control.tar, such as "md5sums" and "postinst"
+	infoModes map[string]os.FileMode
+	files     []string // "/.", "/usr", "/usr/bin", "/usr/bin/hello", ...
+}
+
+// infoName returns the name of the package in the info dir, such as "hello" and "libc6:amd64".
+func (p *extractedDeb) infoName() string {
+	if p.multiArch == "same" {
+		return p.pkg + ":" + p.arch
+	}
+	return p.pkg
+}
+
+func (p *extractedDeb) hasScripts() bool {
+	for _, f := range []string{"preinst", "postinst"} {
+		if _, ok := p.info[f]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *debian) Bootstrap(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.BootstrapOpts) error {
+	root := opts.Root
+	if root == "" || root == "/" {
+		return errors.New("root filesystem needs to be specified")
+	}
+	switch opts.Scripts {
+	case "", distro.BootstrapScriptsSkip, distro.BootstrapScriptsChroot:
+	default:
+		return fmt.Errorf("unknown scripts mode %q", opts.Scripts)
+	}
+	if err := rootfs.EnsureEmpty(root); err != nil {
+		return err
+	}
+	since, err := rootfs.Now(root)
+	if err != nil {
+		return err
+	}
+	debs := binaryPackages(pkgs)
+	sort.Slice(debs, func(i, j int) bool { return debs[i].Name < debs[j].Name })
+	if opts.MergedUsr {
+		if err := createMergedUsr(root, debs); err != nil {
+			return fmt.Errorf("failed to create the merged /usr: %w", err)
+		}
+	}
+	logrus.Infof("Extracting %d packages into %q", len(debs), root)
+	extracted := make([]*extractedDeb, 0, len(debs))
+	var withScripts []string
+	for _, pkg := range debs {
+		blob, err := c.BlobAbsPath(pkg.Sum())
+		if err != nil {
+			return err
+		}
+		p, err := extractDebFile(ctx, blob, root)
+		if err != nil {
+			return fmt.Errorf("failed to extract %q: %w", pkg.Basename, err)
+		}
+		if p.hasScripts() {
+			withScripts = append(withScripts, p.pkg)
+		}
+		extracted = append(extracted, p)
+	}
+	status := "install ok installed"
+	if opts.Scripts == distro.BootstrapScriptsChroot {
+		// Configured by "dpkg --configure -a"
+		status = "install ok unpacked"
+	}
+	if err := writeDpkgDB(root, extracted, status); err != nil {
+		return fmt.Errorf("failed to write the dpkg database: %w", err)
+	}
+	switch opts.Scripts {
+	case distro.BootstrapScriptsChroot:
+		if err := configureInChroot(ctx, root); err != nil {
+			return err
+		}
+	default:
+		if len(withScripts) > 0 {
+			logrus.Warnf("Not executing the maintainer scripts of %d packages: %v", len(withScripts), withScripts)
+		}
+	}
+	return rootfs.Normalize(root, since, opts.SourceDateEpoch)
+}
+
+// createMergedUsr creates the symlinks such as "/bin" -> "usr/bin", like debootstrap.
+func createMergedUsr(root string, debs []filespec.FileSpec) error {
+	dirs := []string{"bin", "sbin", "lib"}
+	for _, pkg := range debs {
+		if arch := pkg.Dpkg.Architecture; arch != "all" {
+			dirs = append(dirs, mergedUsrDirs[arch]...)
+			break
+		}
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, "usr", dir), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(path.Join("usr", dir), filepath.Join(root, dir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractDebFile(ctx context.Context, file, root string) (*extractedDeb, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return extractDeb(ctx, f, root)
+}
+
+// extractDeb extracts the data.tar of the *.deb into the root filesystem, and reads the control.tar.
+func extractDeb(ctx context.Context, r io.Reader, root string) (*extractedDeb, error) {
+	p := &extractedDeb{
+		info:      make(map[string][]byte),
+		infoModes: make(map[string]os.FileMode),
+	}
+	err := readAr(r, func(name string, r io.Reader) error {
+		switch {
+		case name == "debian-binary":
+			return nil
+		case strings.HasPrefix(name, "control.tar"):
+			return withDecompressedTar(ctx, name, r, p.readControlTar)
+		case strings.HasPrefix(name, "data.tar"):
+			if p.control == nil {
+				return errors.New("control.tar has to precede data.tar")
+			}
+			return withDecompressedTar(ctx, name, r, func(tr *tar.Reader) error {
+				return p.extractDataTar(tr, root)
+			})
+		default:
+			logrus.Debugf("Ignoring the unknown member %q", name)
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.control == nil {
+		return nil, errors.New("no control file was found")
+	}
+	return p, nil
+}
+
+func (p *extractedDeb) readControlTar(tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if hdr.Typeflag != tar.TypeReg || strings.Contains(name, "/") {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if name == "control" {
+			p.control = b
+			continue
+		}
+		p.info[name] = b
+		p.infoModes[name] = hdr.FileInfo().Mode().Perm()
+	}
+	if p.control == nil {
+		return errors.New("no control file was found in control.tar")
+	}
+	paragraphs, err := parsePackages(bytes.NewReader(p.control), nil)
+	if err != nil {
+		return err
+	}
+	if len(paragraphs) != 1 {
+		return fmt.Errorf("expected 1 paragraph in the control file, got %d", len(paragraphs))
+	}
+	p.pkg = paragraphs[0].Values["Package"]
+	p.arch = paragraphs[0].Values["Architecture"]
+	p.multiArch = paragraphs[0].Values["Multi-Arch"]
+	if p.pkg == "" {
+		return errors.New("no Package field was found in the control file")
+	}
+	return nil
+}
+
+func (p *extractedDeb) extractDataTar(tr *tar.Reader, root string) error {
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// "./usr/bin/hello" -> "/usr/bin/hello", "./" -> "/."
+		name := path.Clean("/" + strings.TrimPrefix(hdr.Name, "./"))
+		if name == "/" {
+			name = "/."
+		}
+		if err := rootfs.ExtractEntry(tr, hdr, root, nil); err != nil {
+			return fmt.Errorf("failed to extract %q: %w", hdr.Name, err)
+		}
+		p.files = append(p.files, name)
+	}
+	return nil
+}
+
+// writeDpkgDB writes the status file and the info dir.
+func writeDpkgDB(root string, pkgs []*extractedDeb, status string) error {
+	infoDir, err := securejoin.SecureJoin(root, InfoDir)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(infoDir, 0755); err != nil {
+		return err
+	}
+	dbDir := filepath.Dir(infoDir)
+	for _, dir := range []string{"updates", "triggers"} {
+		if err = os.MkdirAll(filepath.Join(dbDir, dir), 0755); err != nil {
+			return err
+		}
+	}
+	if err = os.WriteFile(filepath.Join(dbDir, "available"), nil, 0644); err != nil {
+		return err
+	}
+	sorted := make([]*extractedDeb, len(pkgs))
+	copy(sorted, pkgs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].infoName() < sorted[j].infoName() })
+	var b bytes.Buffer
+	for _, p := range sorted {
+		name := p.infoName()
+		list := strings.Join(p.files, "\n") + "\n"
+		if err = os.WriteFile(filepath.Join(infoDir, name+".list"), []byte(list), 0644); err != nil {
+			return err
+		}
+		for f, content := range p.info {
+			if err = os.WriteFile(filepath.Join(infoDir, name+"."+f), content, p.infoModes[f]); err != nil {
+				return err
+			}
+		}
+		entry, err := statusEntry(root, p, status)
+		if err != nil {
+			return err
+		}
+		b.WriteString(entry + "\n")
+	}
+	return os.WriteFile(filepath.Join(dbDir, "status"), b.Bytes(), 0644)
+}
+
+// statusEntry returns the entry of the status file, i.e., the control file with the Status and Conffiles fields.
+func statusEntry(root string, p *extractedDeb, status string) (string, error) {
+	var b strings.Builder
+	sc := bufio.NewScanner(bytes.NewReader(p.control))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		b.WriteString(line + "\n")
+		if strings.HasPrefix(line, "Package:") {
+			b.WriteString("Status: " + status + "\n")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	if conffiles := strings.Fields(string(p.info["conffiles"])); len(conffiles) > 0 {
+		b.WriteString("Conffiles:\n")
+		for _, f := range conffiles {
+			if !strings.HasPrefix(f, "/") {
+				continue // flags such as "remove-on-upgrade"
+			}
+			sum, err := md5File(root, f)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&b, " %s %s\n", f, sum)
+		}
+	}
+	return b.String(), nil
+}
+
+func md5File(root, name string) (string, error) {
+	p, err := securejoin.SecureJoin(root, name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// configureInChroot executes "dpkg --configure -a" in the root filesystem.
+func configureInChroot(ctx context.Context, root string) error {
+	cmdName, err := exec.LookPath("chroot")
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, cmdName, root, "dpkg", "--configure", "-a")
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive", "DEBCONF_NONINTERACTIVE_SEEN=true", "LC_ALL=C")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %v: %w", cmd.Args, err)
+	}
+	return nil
+}
+
+// readAr calls fn for each member of the ar archive.
+func readAr(r io.Reader, fn func(name string, r io.Reader) error) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return err
+	}
+	if string(magic) != "!<arch>\n" {
+		return errors.New("not an ar archive")
+	}
+	hdr := make([]byte, 60)
+	for {
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if string(hdr[58:60]) != "`\n" {
+			return errors.New("invalid ar header")
+		}
+		name := strings.TrimSuffix(strings.TrimSpace(string(hdr[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid size of the ar member %q: %w", name, err)
+		}
+		lr := &io.LimitedReader{R: br, N: size}
+		if err = fn(name, lr); err != nil {
+			return err
+		}
+		// Consume the rest of the member and the padding
+		if _, err = io.Copy(io.Discard, lr); err != nil {
+			return err
+		}
+		if size%2 == 1 {
+			if _, err = br.Discard(1); err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+		}
+	}
+}
+
+// withDecompressedTar calls fn with the tar reader of the ar member.
+// The xz-compressed members are decompressed with the xz command.
+func withDecompressedTar(ctx context.Context, name string, r io.Reader, fn func(*tar.Reader) error) error {
+	switch path.Ext(name) {
+	case ".tar":
+		return fn(tar.NewReader(r))
+	case ".gz":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return fn(tar.NewReader(gr))
+	case ".zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return fn(tar.NewReader(zr))
+	case ".xz":
+		cmdName, err := exec.LookPath("xz")
+		if err != nil {
+			return fmt.Errorf("xz is needed for decompressing %q: %w", name, err)
+		}
+		cmd := exec.CommandContext(ctx, cmdName, "-dc")
+		cmd.Stdin = r
+		cmd.Stderr = os.Stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		logrus.Debugf("Running %v", cmd.Args)
+		if err = cmd.Start(); err != nil {
+			return err
+		}
+		err = fn(tar.NewReader(stdout))
+		// Consume the rest of the stream, so that xz does not fail with SIGPIPE
+		if _, copyErr := io.Copy(io.Discard, stdout); err == nil {
+			err = copyErr
+		}
+		if waitErr := cmd.Wait(); err == nil && waitErr != nil {
+			err = fmt.Errorf("failed to run %v: %w", cmd.Args, waitErr)
+		}
+		return err
+	default:
+		return fmt.Errorf("unsupported compression of %q", name)
+	}
+}
@@ -0,0 +1,130 @@
+package debian
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+type tarEntry struct {
+	hdr     tar.Header
+	content string
+}
+
+func gzipTar(t testing.TB, entries []tarEntry) []byte {
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.content))
+		assert.NilError(t, tw.WriteHeader(&hdr))
+		_, err := tw.Write([]byte(e.content))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tw.Close())
+	assert.NilError(t, gw.Close())
+	return b.Bytes()
+}
+
+func ar(members map[string][]byte, order ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("!<arch>\n")
+	for _, name := range order {
+		content := members[name]
+		fmt.Fprintf(&b, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, 0, 0, 0, "100644", len(content))
+		b.Write(content)
+		if len(content)%2 == 1 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes()
+}
+
+func TestBootstrap(t *testing.T) {
+	mt := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	const control = `Package: hello
+Version: 2.10-2
+Architecture: amd64
+Multi-Arch: same
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+`
+	deb := ar(map[string][]byte{
+		"debian-binary": []byte("2.0\n"),
+		"control.tar.gz": gzipTar(t, []tarEntry{
+			{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./control", Mode: 0644, ModTime: mt}, content: control},
+			{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./conffiles", Mode: 0644, ModTime: mt}, content: "/etc/hello.conf\n"},
+			{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./postinst", Mode: 0755, ModTime: mt}, content: "#!/bin/sh\n"},
+		}),
+		"data.tar.gz": gzipTar(t, []tarEntry{
+			{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0755, ModTime: mt}},
+			{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./bin/", Mode: 0755, ModTime: mt}},
+			{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./bin/hello", Mode: 0755, ModTime: mt}, content: "hello"},
+			{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "./etc/", Mode: 0755, ModTime: mt}},
+			{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "./etc/hello.conf", Mode: 0644, ModTime: mt}, content: "greeting=hello\n"},
+		}),
+	}, "debian-binary", "control.tar.gz", "data.tar.gz")
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	sum, err := c.ImportWithReader(bytes.NewReader(deb))
+	assert.NilError(t, err)
+	sp, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", sum)
+	assert.NilError(t, err)
+
+	root := filepath.Join(t.TempDir(), "rootfs")
+	d := New().(distro.Bootstrapper)
+	assert.NilError(t, d.Bootstrap(context.TODO(), c, []filespec.FileSpec{*sp}, distro.BootstrapOpts{Root: root, MergedUsr: true}))
+
+	b, err := os.ReadFile(filepath.Join(root, "usr/bin/hello"))
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", string(b))
+	link, err := os.Readlink(filepath.Join(root, "bin"))
+	assert.NilError(t, err)
+	assert.Equal(t, "usr/bin", link)
+
+	b, err = os.ReadFile(filepath.Join(root, "var/lib/dpkg/status"))
+	assert.NilError(t, err)
+	assert.Equal(t, `Package: hello
+Status: install ok installed
+Version: 2.10-2
+Architecture: amd64
+Multi-Arch: same
+Description: example package based on GNU hello
+ The GNU hello program produces a familiar, friendly greeting.
+Conffiles:
+ /etc/hello.conf 801ef2bfa1ce9046be4eb650dabcc017
+
+`, string(b))
+	b, err = os.ReadFile(filepath.Join(root, "var/lib/dpkg/info/hello:amd64.list"))
+	assert.NilError(t, err)
+	assert.Equal(t, "/.\n/bin\n/bin/hello\n/etc\n/etc/hello.conf\n", string(b))
+	st, err := os.Stat(filepath.Join(root, "var/lib/dpkg/info/hello:amd64.postinst"))
+	assert.NilError(t, err)
+	assert.Equal(t, os.FileMode(0755), st.Mode().Perm())
+
+	// The files that are not extracted from the package have the modification time of the package files
+	for _, f := range []string{"usr", "var/lib/dpkg/status", "bin", "."} {
+		st, err := os.Lstat(filepath.Join(root, f))
+		assert.NilError(t, err)
+		assert.Assert(t, st.ModTime().Equal(mt), "%s: %v", f, st.ModTime())
+	}
+
+	installed, err := Installed(root)
+	assert.NilError(t, err)
+	assert.Equal(t, "2.10-2", installed["hello:amd64"].Version)
+
+	assert.ErrorContains(t, d.Bootstrap(context.TODO(), c, []filespec.FileSpec{*sp}, distro.BootstrapOpts{Root: root}), "not empty")
+}
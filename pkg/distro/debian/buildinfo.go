@@ -0,0 +1,208 @@
+package debian
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"pault.ag/go/debian/control"
+)
+
+// DefaultBuildinfoProviders are the default of distro.FetchBuildinfosOpts.Providers for Debian.
+// Ubuntu has no equivalent of buildinfos.debian.net.
+var DefaultBuildinfoProviders = []string{
+	"https://buildinfos.debian.net/buildinfo-pool/{{.Prefix}}/{{.Source}}/{{.Source}}_{{.Version}}_{{.Architecture}}.buildinfo",
+}
+
+// BuildinfoTemplateArgs is the argument of the provider templates of the *.buildinfo files.
+type BuildinfoTemplateArgs struct {
+	Prefix       string // "h", "libh", taken from the pool directory of the package
+	Source       string // "hello", taken from the pool directory of the package
+	Version      string // "2.10-2", or "2.10-2+b1" for binNMUs
+	Architecture string // "amd64", "all"
+}
+
+// buildinfoTemplateArgs returns the candidates of the template arguments for the *.deb file.
+// The arch-independent packages may be recorded in the *.buildinfo file of "amd64",
+// when they were uploaded along with the arch-dependent packages.
+func buildinfoTemplateArgs(sp filespec.FileSpec) ([]BuildinfoTemplateArgs, error) {
+	// "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	elems := strings.Split(sp.Name, "/")
+	if len(elems) < 5 || elems[0] != "pool" || sp.Dpkg == nil {
+		return nil, fmt.Errorf("expected \"pool/<COMPONENT>/<PREFIX>/<SOURCE>/<FILE>.deb\", got %q", sp.Name)
+	}
+	args := BuildinfoTemplateArgs{
+		Prefix:       elems[len(elems)-3],
+		Source:       elems[len(elems)-2],
+		Version:      sp.Dpkg.Version,
+		Architecture: sp.Dpkg.Architecture,
+	}
+	res := []BuildinfoTemplateArgs{args}
+	if args.Architecture == "all" {
+		args.Architecture = "amd64"
+		res = append(res, args)
+	}
+	return res, nil
+}
+
+func buildinfoURL(provider string, args BuildinfoTemplateArgs) (*url.URL, error) {
+	tmpl, err := template.New("").Parse(provider)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err = tmpl.Execute(&b, args); err != nil {
+		return nil, err
+	}
+	return url.Parse(b.String())
+}
+
+// errNotRecorded is returned by verifyBuildinfo when the *.buildinfo file does not record the package.
+var errNotRecorded = errors.New("not recorded")
+
+// FetchBuildinfos fetches the *.buildinfo files of the *.deb files, and verifies that they record the SHA256 of the *.deb files.
+func (d *debian) FetchBuildinfos(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.FetchBuildinfosOpts) ([]distro.Buildinfo, error) {
+	providers := opts.Providers
+	if len(providers) == 0 && d.info.Name == NameDebian {
+		providers = DefaultBuildinfoProviders
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no buildinfo provider is known for %q (Hint: specify --buildinfo-provider)", d.info.Name)
+	}
+	var keyring openpgp.EntityList
+	if len(opts.Keyrings) > 0 {
+		var err error
+		keyring, err = ReadKeyrings("/", opts.Keyrings)
+		if err != nil {
+			return nil, err
+		}
+		if len(keyring) == 0 {
+			return nil, fmt.Errorf("no OpenPGP key was found in %v", opts.Keyrings)
+		}
+	}
+	var res []distro.Buildinfo
+	for _, pkg := range binaryPackages(pkgs) {
+		bi, err := fetchBuildinfo(ctx, c, pkg, providers, keyring)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the buildinfo of %q: %w", pkg.Name, err)
+		}
+		res = append(res, *bi)
+	}
+	return res, nil
+}
+
+// fetchBuildinfo tries the providers, and returns the first *.buildinfo file that records the package.
+func fetchBuildinfo(ctx context.Context, c *cache.Cache, pkg filespec.FileSpec, providers []string, keyring openpgp.EntityList) (*distro.Buildinfo, error) {
+	if pkg.SHA256 == "" {
+		return nil, fmt.Errorf("no SHA256 is known (only %s), while the buildinfo files only record SHA256", pkg.Sum())
+	}
+	candidates, err := buildinfoTemplateArgs(pkg)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, args := range candidates {
+		for _, provider := range providers {
+			u, err := buildinfoURL(provider, args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to determine the URL with the provider %q: %w", provider, err)
+			}
+			sha256sum, err := importBuildinfo(ctx, c, u)
+			if err != nil {
+				logrus.WithError(err).Debugf("Failed to fetch %q", u.Redacted())
+				lastErr = err
+				continue
+			}
+			signer, err := verifyBuildinfo(c, sha256sum, pkg, keyring)
+			if errors.Is(err, errNotRecorded) {
+				logrus.Debugf("%q does not record %q", u.Redacted(), pkg.Basename)
+				lastErr = fmt.Errorf("%q does not record %q", u.Redacted(), pkg.Basename)
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to verify %q: %w", u.Redacted(), err)
+			}
+			bi := &distro.Buildinfo{
+				Name:      pkg.Name,
+				Buildinfo: path.Base(u.Path),
+				SHA256:    sha256sum,
+				URL:       u.Redacted(),
+			}
+			if signer != nil {
+				bi.Signer = strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint[:]))
+			}
+			logrus.Debugf("Verified %q for %q", bi.URL, pkg.Name)
+			return bi, nil
+		}
+	}
+	return nil, lastErr
+}
+
+func importBuildinfo(ctx context.Context, c *cache.Cache, u *url.URL) (string, error) {
+	sha256sum, err := c.SHA256ByOriginURL(u)
+	if err == nil {
+		logrus.Debugf("Found cached sha256sum %s for %q", sha256sum, u.Redacted())
+		return sha256sum, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+	}
+	r, _, err := c.URLOpener().Open(ctx, u, "")
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return c.ImportWithReaderAndURL(r, u)
+}
+
+// verifyBuildinfo verifies that the cached *.buildinfo file records the SHA256 of the package.
+// The signature is verified when the keyring is non-empty, and the signer is returned.
+// Returns errNotRecorded when the *.buildinfo file does not record the package.
+func verifyBuildinfo(c *cache.Cache, sha256sum string, pkg filespec.FileSpec, keyring openpgp.EntityList) (*openpgp.Entity, error) {
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(blob)
+	if err != nil {
+		return nil, err
+	}
+	var kr *openpgp.EntityList
+	if len(keyring) > 0 {
+		kr = &keyring
+	}
+	pr, err := control.NewParagraphReader(bytes.NewReader(b), kr)
+	if err != nil {
+		return nil, err
+	}
+	if kr != nil && pr.Signer() == nil {
+		return nil, errors.New("not signed")
+	}
+	paragraph, err := pr.Next()
+	if err != nil {
+		return nil, err
+	}
+	// Each line is like "<SHA256> <SIZE> <FILE>"
+	for _, line := range strings.Split(strings.TrimSpace(paragraph.Values["Checksums-Sha256"]), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[2] != pkg.Basename {
+			continue
+		}
+		if !strings.EqualFold(fields[0], pkg.SHA256) {
+			return nil, fmt.Errorf("expected SHA256 %s for %q, got %s (Hint: the package may have been rebuilt, or tampered)", pkg.SHA256, pkg.Basename, fields[0])
+		}
+		return pr.Signer(), nil
+	}
+	return nil, errNotRecorded
+}
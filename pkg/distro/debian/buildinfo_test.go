@@ -0,0 +1,95 @@
+package debian
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"gotest.tools/v3/assert"
+)
+
+const (
+	testHelloSHA256    = "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
+	testHelloDocSHA256 = "0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+func TestFetchBuildinfos(t *testing.T) {
+	signer, err := openpgp.NewEntity("buildd", "", "buildd@example.com", nil)
+	assert.NilError(t, err)
+	stranger, err := openpgp.NewEntity("stranger", "", "stranger@example.com", nil)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(dir, "h", "hello"), 0755))
+	writeInRelease(t, filepath.Join(dir, "h", "hello", "hello_2.10-2_amd64.buildinfo"), signer, `Format: 1.0
+Source: hello
+Binary: hello hello-doc
+Architecture: amd64 all
+Version: 2.10-2
+Checksums-Sha256:
+ `+testHelloSHA256+` 56132 hello_2.10-2_amd64.deb
+ `+testHelloDocSHA256+` 1234 hello-doc_2.10-2_all.deb
+Build-Origin: Debian
+`)
+	keyringFile := filepath.Join(dir, "buildd.gpg")
+	var kb bytes.Buffer
+	assert.NilError(t, signer.Serialize(&kb))
+	assert.NilError(t, os.WriteFile(keyringFile, kb.Bytes(), 0644))
+	strangerFile := filepath.Join(dir, "stranger.gpg")
+	kb.Reset()
+	assert.NilError(t, stranger.Serialize(&kb))
+	assert.NilError(t, os.WriteFile(strangerFile, kb.Bytes(), 0644))
+
+	hello, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", testHelloSHA256)
+	assert.NilError(t, err)
+	helloDoc, err := filespec.New("pool/main/h/hello/hello-doc_2.10-2_all.deb", testHelloDocSHA256)
+	assert.NilError(t, err)
+	src, err := filespec.New("pool/main/h/hello/hello_2.10-2.dsc", testHelloSHA256)
+	assert.NilError(t, err)
+	tampered, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", strings.Repeat("f", 64))
+	assert.NilError(t, err)
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	ctx := context.Background()
+	d := New().(distro.BuildinfoFetcher)
+	opts := distro.FetchBuildinfosOpts{
+		Providers: []string{"file://" + dir + "/{{.Prefix}}/{{.Source}}/{{.Source}}_{{.Version}}_{{.Architecture}}.buildinfo"},
+		Keyrings:  []string{keyringFile},
+	}
+	res, err := d.FetchBuildinfos(ctx, c, []filespec.FileSpec{*hello, *helloDoc, *src}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(res))
+	fpr := strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint[:]))
+	for i, sp := range []*filespec.FileSpec{hello, helloDoc} {
+		assert.Equal(t, sp.Name, res[i].Name)
+		assert.Equal(t, "hello_2.10-2_amd64.buildinfo", res[i].Buildinfo)
+		assert.Equal(t, fpr, res[i].Signer)
+		cached, err := c.Cached(res[i].SHA256)
+		assert.NilError(t, err)
+		assert.Assert(t, cached)
+	}
+
+	_, err = d.FetchBuildinfos(ctx, c, []filespec.FileSpec{*tampered}, opts)
+	assert.ErrorContains(t, err, "expected SHA256")
+
+	opts.Keyrings = []string{strangerFile}
+	_, err = d.FetchBuildinfos(ctx, c, []filespec.FileSpec{*hello}, opts)
+	assert.ErrorContains(t, err, "failed to verify")
+
+	opts.Keyrings = nil
+	res, err = d.FetchBuildinfos(ctx, c, []filespec.FileSpec{*hello}, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, "", res[0].Signer)
+
+	_, err = NewUbuntu().(distro.BuildinfoFetcher).FetchBuildinfos(ctx, c, []filespec.FileSpec{*hello}, distro.FetchBuildinfosOpts{})
+	assert.ErrorContains(t, err, "no buildinfo provider")
+}
@@ -1,25 +1,25 @@
 package debian
 
 import (
-	"bufio"
 	"context"
 	_ "embed"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
 
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"github.com/sirupsen/logrus"
 	"pault.ag/go/debian/control"
-	"pault.ag/go/debian/version"
 )
 
 const (
@@ -34,9 +34,11 @@ func New() distro.Distro {
 			Name: NameDebian,
 			DefaultProviders: []string{
 				// HTTPS is not used by default in the apt-get ecosystem. See also README.md.
-				"http://deb.debian.org/debian/{{.Name}}",                      // fast, multi-arch, ephemeral
-				"http://deb.debian.org/debian-security/{{.Name}}",             // fast, multi-arch, ephemeral
-				"http://debian.notset.fr/snapshot/by-hash/SHA256/{{.SHA256}}", // slow, amd64 only, persistent
+				"http://deb.debian.org/debian/{{.Name}}",                                     // fast, multi-arch, ephemeral
+				"http://deb.debian.org/debian-security/{{.Name}}",                            // fast, multi-arch, ephemeral
+				"http://snapshot.debian.org/archive/debian/{{.Snapshot}}/{{.Name}}",          // slow, multi-arch, persistent, needs the snapshot directive
+				"http://snapshot.debian.org/archive/debian-security/{{.Snapshot}}/{{.Name}}", // slow, multi-arch, persistent, needs the snapshot directive
+				"http://debian.notset.fr/snapshot/by-hash/SHA256/{{.SHA256}}",                // slow, amd64 only, persistent
 			},
 		},
 	}
@@ -49,8 +51,11 @@ func NewUbuntu() distro.Distro {
 			Name: NameUbuntu,
 			DefaultProviders: []string{
 				// HTTPS is not used by default in the apt-get ecosystem. See also README.md.
-				"http://ports.ubuntu.com/{{.Name}}",          // multi-arch, ephemeral
-				"http://archive.ubuntu.com/ubuntu/{{.Name}}", // amd64 only, ephemeral
+				"http://ppa.launchpadcontent.net/{{.PPA.Owner}}/{{.PPA.Name}}/ubuntu/{{.Name}}",            // multi-arch, ephemeral, needs the PPA directive
+				"https://launchpad.net/~{{.PPA.Owner}}/+archive/ubuntu/{{.PPA.Name}}/+files/{{.Basename}}", // multi-arch, persistent, HTTPS only, needs the PPA directive
+				"http://ports.ubuntu.com/{{.Name}}",                                                        // multi-arch, ephemeral
+				"http://archive.ubuntu.com/ubuntu/{{.Name}}",                                               // amd64 only, ephemeral
+				"http://security.ubuntu.com/ubuntu/{{.Name}}",                                              // amd64 only, ephemeral, for the security updates that are not synced to archive.ubuntu.com yet
 				// Ubuntu has no equivalent of debian.notset.fr
 			},
 		},
@@ -68,9 +73,28 @@ func (d *debian) Info() distro.Info {
 }
 
 func (d *debian) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
+	if opts.Resolve && len(opts.FilterByName) == 0 {
+		return errors.New("resolving dependencies needs the package names to be specified")
+	}
+	if opts.World {
+		return errors.New("the world file is not supported for Debian and Ubuntu")
+	}
+	if len(opts.Indexes) > 0 {
+		return errors.New("repository indexes are not supported for Debian and Ubuntu (Hint: use the apt lists)")
+	}
+	var ppa *filespec.PPA
+	if opts.PPA != "" {
+		if opts.Source {
+			return errors.New("source packages of PPAs are not supported yet")
+		}
+		var err error
+		if ppa, err = filespec.ParsePPA(opts.PPA); err != nil {
+			return err
+		}
+	}
 	names := opts.FilterByName
 	if len(names) == 0 {
-		dpkgs, err := Installed()
+		dpkgs, err := Installed(opts.Root)
 		if err != nil {
 			return err
 		}
@@ -81,66 +105,211 @@ func (d *debian) GenerateHash(ctx context.Context, hw distro.HashWriter, opts di
 			names = append(names, name)
 		}
 	}
-	sort.Strings(names)
+	hostArch := archutil.OCIArchDashVariant()
+	foreign := opts.Arch != "" && opts.Arch != hostArch
+	arch := hostArch
+	if foreign {
+		arch = opts.Arch
+	}
+	dpkgArch, err := dpkgutil.ArchFromOCI(arch)
+	if err != nil {
+		if foreign {
+			return err
+		}
+		logrus.WithError(err).Debug("Failed to determine the dpkg architecture of the host, not filtering packages by architecture")
+	}
+	filter := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if foreign {
+			// Drop the architecture qualifier of the host, such as ":amd64"
+			name, _, _ = strings.Cut(name, ":")
+		}
+		filter[name] = struct{}{}
+	}
 
 	// /var/lib/dpkg/available is only updated by dselect,
-	// so we have to shell out `apt-cache show PKGS...`
-	aptCacheArgs := append([]string{"show"}, names...)
-	aptCacheCmd := exec.Command("apt-cache", aptCacheArgs...)
-	aptCacheCmd.Stderr = os.Stderr
-	aptCacheR, err := aptCacheCmd.StdoutPipe()
+	// so we have to read the apt lists
+	listsDir, err := listsDir(opts.Root)
 	if err != nil {
 		return err
 	}
-	defer aptCacheR.Close()
-	// logrus.Debugf("Running %v", aptCacheCmd.Args)
-	if err := aptCacheCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start %v: %w", aptCacheCmd.Args, err)
+	keyringRoot, keyrings := opts.Root, DefaultKeyrings
+	if len(opts.Keyrings) > 0 {
+		keyringRoot, keyrings = "/", opts.Keyrings
 	}
-	if err = generateHash(hw, aptCacheR); err != nil {
-		return fmt.Errorf("failed to parse the output of %v: %w", aptCacheCmd.Args, err)
+	keyring, err := ReadKeyrings(keyringRoot, keyrings)
+	if err != nil {
+		return err
 	}
-	return nil
+	var extraKeyrings []string
+	for _, f := range opts.ExtraKeyrings {
+		if !strings.HasSuffix(f, ".rsa.pub") { // RSA keys for Alpine
+			extraKeyrings = append(extraKeyrings, f)
+		}
+	}
+	extraKeyring, err := ReadKeyrings("/", extraKeyrings)
+	if err != nil {
+		return err
+	}
+	keyring = append(keyring, extraKeyring...)
+	rv := newReleaseVerifier(listsDir, keyring, opts.AllowUnsigned)
+	listsFilter := filter
+	if opts.Resolve {
+		// The dependencies are not known yet
+		listsFilter = nil
+	}
+	pp, err := newPinPolicy(opts.Root, opts.Preferences, opts.TargetRelease)
+	if err != nil {
+		return err
+	}
+	paragraphs, err := readPackagesLists(listsDir, listsFilter, rv, pp)
+	if err != nil {
+		return err
+	}
+	if opts.IndexWriter != nil {
+		if err = writeListsHashes(opts.IndexWriter, listsDir, "_Packages"); err != nil {
+			return err
+		}
+	}
+	if dpkgArch != "" {
+		paragraphs = filterParagraphsByArch(paragraphs, dpkgArch, filter)
+		if foreign && len(paragraphs) == 0 {
+			return fmt.Errorf("no package was found for architecture %q (Hint: try 'dpkg --add-architecture %s && apt-get update')", dpkgArch, dpkgArch)
+		}
+	}
+	if opts.Resolve {
+		paragraphs, err = newResolver(newestParagraphs(paragraphs), dpkgArch).resolve(names)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.Source {
+		srcs := sourcesOf(paragraphs)
+		srcFilter := make(map[string]struct{}, len(srcs))
+		for _, src := range srcs {
+			srcFilter[src.Package] = struct{}{}
+		}
+		srcParagraphs, err := readSourcesLists(listsDir, srcFilter, rv)
+		if err != nil {
+			return err
+		}
+		if opts.IndexWriter != nil {
+			if err = writeListsHashes(opts.IndexWriter, listsDir, "_Sources"); err != nil {
+				return err
+			}
+		}
+		if opts.URLWriter != nil {
+			hw = withURLWriter(hw, opts.URLWriter, sourceURLs(srcParagraphs))
+		}
+		return generateSourceHash(hw, srcs, srcParagraphs)
+	}
+	if opts.URLWriter != nil {
+		hw = withURLWriter(hw, opts.URLWriter, binaryURLs(paragraphs))
+	}
+	return generateHash(hw, paragraphs, ppa, opts.SHA512)
 }
 
-func generateHash(hw distro.HashWriter, r io.Reader) error {
-	bufR := bufio.NewReader(r)
+// filterParagraphsByArch returns the paragraphs for the specified dpkg architecture, including "all".
+// The paragraphs for other architectures are returned too, only when the filter explicitly
+// contains the names with the architecture qualifier, such as "libc6:i386".
+func filterParagraphsByArch(paragraphs []control.Paragraph, dpkgArch string, filter map[string]struct{}) []control.Paragraph {
+	var res []control.Paragraph
+	for _, f := range paragraphs {
+		switch pkgArch := f.Values["Architecture"]; pkgArch {
+		case dpkgArch, "all":
+			res = append(res, f)
+		default:
+			if _, ok := filter[f.Values["Package"]+":"+pkgArch]; ok {
+				res = append(res, f)
+			}
+		}
+	}
+	return res
+}
 
-	var paragraphs []control.BinaryParagraph
-	if err := control.Unmarshal(&paragraphs, bufR); err != nil {
-		return err
+// listsDir returns the lists dir in the root filesystem.
+// Falls back to the lists dir of the host when the root filesystem lacks it (e.g., distroless).
+func listsDir(root string) (string, error) {
+	if root == "" || root == "/" {
+		return ListsDir, nil
 	}
-	// logrus.Debugf("Scanning %d entries", len(paragraphs))
+	dir, err := securejoin.SecureJoin(root, ListsDir)
+	if err != nil {
+		return "", err
+	}
+	if files, _ := listsFiles(dir, "_Packages"); len(files) > 0 {
+		return dir, nil
+	}
+	logrus.Warnf("No Packages file was found in %q, falling back to %q", dir, ListsDir)
+	return ListsDir, nil
+}
 
-	seen := make(map[string]string)
+// newestParagraphs returns the paragraphs of the newest versions, sorted by Package + ":" + Architecture.
+// The paragraphs with higher pin priorities are preferred over the newer versions, as in apt.
+func newestParagraphs(paragraphs []control.Paragraph) []control.Paragraph {
+	// logrus.Debugf("Scanning %d entries", len(paragraphs))
+	seen := make(map[string]control.Paragraph)
 	for _, f := range paragraphs {
-		ver := f.Paragraph.Values["Version"]
-		seenK := f.Package + ":" + f.Paragraph.Values["Architecture"]
+		seenK := f.Values["Package"] + ":" + f.Values["Architecture"]
 		if seenV, ok := seen[seenK]; ok {
-			seenVParsed, err := version.Parse(seenV)
+			prefer, err := preferParagraph(f, seenV)
 			if err != nil {
-				logrus.WithError(err).Warnf("Failed to parse version %q", seenV)
+				logrus.WithError(err).Warnf("Failed to compare the versions of %q", seenK)
 				continue
 			}
-			verParsed, err := version.Parse(ver)
-			if err != nil {
-				logrus.WithError(err).Warnf("Failed to parse version %q", ver)
+			if !prefer {
 				continue
 			}
-			if version.Compare(seenVParsed, verParsed) > 0 {
-				continue
+		}
+		seen[seenK] = f
+	}
+	seenKeys := make([]string, 0, len(seen))
+	for k := range seen {
+		seenKeys = append(seenKeys, k)
+	}
+	sort.Strings(seenKeys)
+	res := make([]control.Paragraph, len(seenKeys))
+	for i, k := range seenKeys {
+		res[i] = seen[k]
+	}
+	return res
+}
+
+// generateHash generates the hashes of the newest paragraphs.
+// Only the paragraphs from ppa are used when ppa is non-nil, otherwise the paragraphs from PPAs are skipped,
+// as a hash file cannot mix the packages of different PPAs.
+// The SHA512 fields are used instead of the SHA256 fields when sha512 is true.
+func generateHash(hw distro.HashWriter, paragraphs []control.Paragraph, ppa *filespec.PPA, sha512 bool) error {
+	for _, f := range newestParagraphs(paragraphs) {
+		pkgName := f.Values["Package"]
+		if fPPA := ppaOf(f); !samePPA(fPPA, ppa) {
+			if fPPA != nil && ppa == nil {
+				logrus.Warnf("Skipping package %q from PPA %q (Hint: generate a separate hash file with --ppa=%s)", pkgName, fPPA, fPPA)
+			} else {
+				logrus.Debugf("Skipping package %q, as it is not from PPA %q", pkgName, ppa)
 			}
+			continue
 		}
-		seen[seenK] = ver
-		dpkgFilename := f.Paragraph.Values["Filename"]
+		dpkgFilename := f.Values["Filename"]
 		if dpkgFilename == "" {
-			logrus.Warnf("No Filename found for package %q (Hint: try 'apt-get update')", f.Package)
+			logrus.Warnf("No Filename found for package %q (Hint: try 'apt-get update')", pkgName)
 			continue
 		}
 
-		sha256Digest := f.Paragraph.Values["SHA256"]
+		if sha512 {
+			sha512Digest := f.Values["SHA512"]
+			if sha512Digest == "" {
+				logrus.Warnf("No SHA512 found for package %q (Hint: try 'apt-get update')", pkgName)
+				continue
+			}
+			if err := hw(sha256sums.SHA512Prefix+sha512Digest, dpkgFilename); err != nil {
+				return err
+			}
+			continue
+		}
+		sha256Digest := f.Values["SHA256"]
 		if sha256Digest == "" {
-			logrus.Warnf("No SHA256 found for package %q (Hint: try 'apt-get update')", f.Package)
+			logrus.Warnf("No SHA256 found for package %q (Hint: try 'apt-get update')", pkgName)
 			continue
 		}
 		if err := hw(sha256Digest, dpkgFilename); err != nil {
@@ -159,11 +328,12 @@ func (d *debian) PackageName(sp filespec.FileSpec) (string, error) {
 
 func (d *debian) IsPackageVersionInstalled(ctx context.Context, sp filespec.FileSpec) (bool, error) {
 	if sp.Dpkg == nil {
-		return false, fmt.Errorf("dpkg information not available for %q", sp.Name)
+		// Source packages are never installed
+		return false, nil
 	}
 	if d.installed == nil {
 		var err error
-		d.installed, err = Installed()
+		d.installed, err = Installed("")
 		if err != nil {
 			return false, fmt.Errorf("failed to detect installed dpkgs: %w", err)
 		}
@@ -179,60 +349,37 @@ func (d *debian) IsPackageVersionInstalled(ctx context.Context, sp filespec.File
 	return inst.Version == sp.Dpkg.Version, nil
 }
 
-// Installed returns the package map.
-// The map key is Package + ":" + Architecture (if Architecture != "").
-func Installed() (map[string]dpkgutil.Dpkg, error) {
-	cmd := exec.Command("dpkg-query", "-f", "${Package},${Version},${Architecture}\n", "-W")
-	cmd.Stderr = os.Stderr
-	r, err := cmd.StdoutPipe()
+func (d *debian) InstalledPackages(ctx context.Context, root string) ([]distro.InstalledPackage, error) {
+	dpkgs, err := Installed(root)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to detect installed dpkgs: %w", err)
 	}
-	defer r.Close()
-	// logrus.Debugf("Running %v", cmd.Args)
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start %v: %w", cmd.Args, err)
+	res := make([]distro.InstalledPackage, 0, len(dpkgs))
+	for _, dpkg := range dpkgs {
+		res = append(res, distro.InstalledPackage{Package: dpkg.Package, Version: dpkg.Version, Architecture: dpkg.Architecture})
 	}
-	return installed(r)
-}
-
-func installed(r io.Reader) (map[string]dpkgutil.Dpkg, error) {
-	const expectedFields = 3
-	pkgs := make(map[string]dpkgutil.Dpkg)
-	sc := bufio.NewScanner(r)
-	for sc.Scan() {
-		line := sc.Text()
-		trimmed := strings.TrimSpace(line)
-		fields := strings.SplitN(trimmed, ",", expectedFields)
-		if len(fields) != expectedFields {
-			return pkgs, fmt.Errorf("unexpected line %q: expected %d fields, got %d", line, expectedFields, len(fields))
-		}
-		pkg := dpkgutil.Dpkg{
-			Package:      fields[0],
-			Version:      fields[1],
-			Architecture: fields[2],
-		}
-		k := pkg.Package
-		if pkg.Architecture != "" {
-			k += ":" + pkg.Architecture
-		}
-		pkgs[k] = pkg
-	}
-	return pkgs, sc.Err()
+	return res, nil
 }
 
 func (d *debian) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) error {
 	if len(pkgs) == 0 {
 		return nil
 	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("installing packages into a custom root %q is not supported for Debian and Ubuntu", opts.Root)
+	}
 	cmdName, err := exec.LookPath("dpkg")
 	if err != nil {
 		return err
 	}
+	debs := binaryPackages(pkgs)
+	if len(debs) == 0 {
+		return nil
+	}
 	args := []string{"-i"}
-	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(pkgs))
-	for _, pkg := range pkgs {
-		blob, err := c.BlobAbsPath(pkg.SHA256)
+	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(debs))
+	for _, pkg := range debs {
+		blob, err := c.BlobAbsPath(pkg.Sum())
 		if err != nil {
 			return err
 		}
@@ -253,17 +400,87 @@ var (
 	//go:embed Dockerfile.generate-hash.tmpl
 	dockerfileGenerateHashTmpl string
 
+	//go:embed Dockerfile.generate-hash.ubuntu.tmpl
+	dockerfileGenerateHashUbuntuTmpl string
+
+	// Dockerfile.tmpl is used for Ubuntu too
 	//go:embed Dockerfile.tmpl
 	dockerfileTmpl string
 )
 
+// binaryPackages returns the binary packages.
+// The source packages (*.dsc, *.orig.tar.*, ...) are just downloaded, not installed.
+func binaryPackages(pkgs []filespec.FileSpec) []filespec.FileSpec {
+	var debs []filespec.FileSpec
+	for _, pkg := range pkgs {
+		if pkg.Dpkg == nil {
+			logrus.Debugf("Skipping installing %q (not a binary package)", pkg.Name)
+			continue
+		}
+		debs = append(debs, pkg)
+	}
+	return debs
+}
+
+func (d *debian) InstallCommand(c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) ([]string, error) {
+	if opts.Root != "" && opts.Root != "/" {
+		return nil, fmt.Errorf("installing packages into a custom root %q is not supported for Debian and Ubuntu", opts.Root)
+	}
+	debs := binaryPackages(pkgs)
+	if len(debs) == 0 {
+		return nil, nil
+	}
+	args := []string{"dpkg", "-i"}
+	for _, pkg := range debs {
+		blob, err := c.LookupBlobAbsPath(pkg.Sum())
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, blob)
+	}
+	return args, nil
+}
+
+func (d *debian) UninstallPackages(ctx context.Context, pkgs []distro.InstalledPackage, opts distro.InstallOpts) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("uninstalling packages from a custom root %q is not supported for Debian and Ubuntu", opts.Root)
+	}
+	cmdName, err := exec.LookPath("dpkg")
+	if err != nil {
+		return err
+	}
+	args := []string{"-r"}
+	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(pkgs))
+	for _, pkg := range pkgs {
+		s := pkg.Package
+		if pkg.Architecture != "" && pkg.Architecture != "all" {
+			s += ":" + pkg.Architecture
+		}
+		args = append(args, s)
+	}
+	cmd := exec.CommandContext(ctx, cmdName, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	return cmd.Run()
+}
+
 func (d *debian) GenerateDockerfile(ctx context.Context, dir string, args distro.DockerfileTemplateArgs, opts distro.DockerfileOpts) error {
-	if d.info.Name != NameDebian {
-		return fmt.Errorf("generating dockerfiles needs the distro driver to be set to %q, not %q", NameDebian, d.info.Name)
+	generateHashTmpl := dockerfileGenerateHashTmpl
+	switch d.info.Name {
+	case NameDebian:
+	case NameUbuntu:
+		generateHashTmpl = dockerfileGenerateHashUbuntuTmpl
+	default:
+		return fmt.Errorf("generating dockerfiles needs the distro driver to be set to %q or %q, not %q", NameDebian, NameUbuntu, d.info.Name)
 	}
 	if opts.GenerateHash {
 		f := filepath.Join(dir, "Dockerfile.generate-hash") // no need to use securejoin (const)
-		if err := args.WriteToFile(f, dockerfileGenerateHashTmpl); err != nil {
+		if err := args.WriteToFile(f, generateHashTmpl); err != nil {
 			return fmt.Errorf("failed to generate %q: %w", f, err)
 		}
 	}
@@ -2,16 +2,22 @@ package debian
 
 import (
 	"bytes"
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
 	"gotest.tools/v3/assert"
+	"pault.ag/go/debian/control"
 )
 
 func TestGenerateHash(t *testing.T) {
-	// s is from `apt-cache show bash hello` on Debian 11
+	// s is from /var/lib/apt/lists/deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages on Debian 11 (excerpt)
 	const s = `Package: bash
 Version: 5.1-2+deb11u1
 Essential: yes
@@ -61,10 +67,26 @@ Size: 56132
 MD5sum: 52b0cad2e741dd722c3e2e16a0aae57e
 SHA256: 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc
 
+Package: hello
+Version: 2.10-1
+Architecture: amd64
+Filename: pool/main/h/hello/hello_2.10-1_amd64.deb
+SHA256: 0000000000000000000000000000000000000000000000000000000000000000
+
+Package: dash
+Version: 0.5.11+git20200708+dd9ef66-5
+Architecture: amd64
+Filename: pool/main/d/dash/dash_0.5.11+git20200708+dd9ef66-5_amd64.deb
+SHA256: 0000000000000000000000000000000000000000000000000000000000000000
+
 `
+	paragraphs, err := parsePackages(strings.NewReader(s), map[string]struct{}{"bash:amd64": {}, "hello": {}})
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(paragraphs))
+
 	var b bytes.Buffer
 	hw := distro.NewHashWriter(&b)
-	assert.NilError(t, generateHash(hw, strings.NewReader(s)))
+	assert.NilError(t, generateHash(hw, paragraphs, nil, false))
 
 	const expected = `f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
@@ -72,13 +94,66 @@ SHA256: 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc
 	assert.Equal(t, expected, b.String())
 }
 
+func TestGenerateHashPPA(t *testing.T) {
+	const ppaLists = "ppa.launchpadcontent.net_deadsnakes_ppa_ubuntu_dists_jammy_main_binary-amd64_Packages"
+	paragraphs := []control.Paragraph{
+		{Values: map[string]string{"Package": "hello", "Version": "2.10-2", "Architecture": "amd64",
+			"Filename": "pool/main/h/hello/hello_2.10-2_amd64.deb", "SHA256": "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+			listsFileField: "archive.ubuntu.com_ubuntu_dists_jammy_main_binary-amd64_Packages"}},
+		{Values: map[string]string{"Package": "python3.12", "Version": "3.12.0-1+jammy1", "Architecture": "amd64",
+			"Filename": "pool/main/p/python3.12/python3.12_3.12.0-1+jammy1_amd64.deb", "SHA256": "2d1e6e1a4b2a3d1e7d64f7cb2c19aa0b5d22b8ad0a6d4c31e5fbd0e1b4ee3c36",
+			listsFileField: ppaLists}},
+	}
+	assert.DeepEqual(t, &filespec.PPA{Owner: "deadsnakes", Name: "ppa"}, ppaOf(paragraphs[1]))
+
+	var b bytes.Buffer
+	assert.NilError(t, generateHash(distro.NewHashWriter(&b), paragraphs, nil, false))
+	assert.Equal(t, "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb\n", b.String())
+
+	b.Reset()
+	assert.NilError(t, generateHash(distro.NewHashWriter(&b), paragraphs, &filespec.PPA{Owner: "deadsnakes", Name: "ppa"}, false))
+	assert.Equal(t, "2d1e6e1a4b2a3d1e7d64f7cb2c19aa0b5d22b8ad0a6d4c31e5fbd0e1b4ee3c36  pool/main/p/python3.12/python3.12_3.12.0-1+jammy1_amd64.deb\n", b.String())
+}
+
 func TestInstalled(t *testing.T) {
-	// s is from `dpkg-query -f '${Package},${Version},${Architecture}\n' -W bash hello` on Debian 11
-	const s = `bash,5.1-2+deb11u1,amd64
-hello,2.10-2,amd64
+	// s is from /var/lib/dpkg/status on Debian 11 (excerpt)
+	const s = `Package: bash
+Essential: yes
+Status: install ok installed
+Priority: required
+Section: shells
+Installed-Size: 6469
+Maintainer: Matthias Klose <doko@debian.org>
+Architecture: amd64
+Multi-Arch: foreign
+Version: 5.1-2+deb11u1
+Description: GNU Bourne Again SHell
+
+Package: hello
+Status: install ok installed
+Priority: optional
+Section: devel
+Installed-Size: 280
+Maintainer: Santiago Vila <sanvila@debian.org>
+Architecture: amd64
+Version: 2.10-2
+Description: example package based on GNU hello
+
+Package: nano
+Status: deinstall ok config-files
+Priority: important
+Section: editors
+Installed-Size: 2699
+Maintainer: Jordi Mallach <jordi@debian.org>
+Architecture: amd64
+Version: 5.4-2+deb11u2
+Conffiles:
+ /etc/nanorc 3ab1e2e5e4f9e5cb5b8e3d4d8f4c3e4d
+Description: small, friendly text editor inspired by Pico
+
 `
-	got, err := installed(strings.NewReader(s))
-	assert.NilError(t, err)
+	got := make(map[string]dpkgutil.Dpkg)
+	assert.NilError(t, installed(got, strings.NewReader(s)))
 	expected := map[string]dpkgutil.Dpkg{
 		"bash:amd64": {
 			Package:      "bash",
@@ -93,3 +168,108 @@ hello,2.10-2,amd64
 	}
 	assert.DeepEqual(t, expected, got)
 }
+
+func TestInstalledRoot(t *testing.T) {
+	root := t.TempDir()
+	statusDir := filepath.Join(root, StatusDir)
+	assert.NilError(t, os.MkdirAll(statusDir, 0755))
+	// Distroless images have status.d/<PACKAGE> without the "Status" field
+	const s = `Package: base-files
+Version: 11.1+deb11u5
+Architecture: amd64
+Maintainer: Santiago Vila <sanvila@debian.org>
+Installed-Size: 340
+Priority: required
+Section: admin
+Description: Debian base system miscellaneous files
+`
+	assert.NilError(t, os.WriteFile(filepath.Join(statusDir, "base-files"), []byte(s), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(statusDir, "base-files.md5sums"), []byte("d41d8cd98f00b204e9800998ecf8427e  etc/debian_version\n"), 0644))
+
+	got, err := Installed(root)
+	assert.NilError(t, err)
+	expected := map[string]dpkgutil.Dpkg{
+		"base-files:amd64": {
+			Package:      "base-files",
+			Version:      "11.1+deb11u5",
+			Architecture: "amd64",
+		},
+	}
+	assert.DeepEqual(t, expected, got)
+}
+
+func TestFilterParagraphsByArch(t *testing.T) {
+	const s = `Package: hello
+Version: 2.10-2
+Architecture: amd64
+
+Package: hello
+Version: 2.10-2
+Architecture: arm64
+
+Package: ca-certificates
+Version: 20210119
+Architecture: all
+`
+	paragraphs, err := parsePackages(strings.NewReader(s), nil)
+	assert.NilError(t, err)
+	got := filterParagraphsByArch(paragraphs, "arm64", nil)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, "arm64", got[0].Values["Architecture"])
+	assert.Equal(t, "all", got[1].Values["Architecture"])
+
+	got = filterParagraphsByArch(paragraphs, "arm64", map[string]struct{}{"hello:amd64": {}})
+	assert.Equal(t, 3, len(got))
+}
+
+func TestInstallCommand(t *testing.T) {
+	cacheDir := t.TempDir()
+	c, err := cache.New(cacheDir)
+	assert.NilError(t, err)
+	fileSpecs, err := filespec.NewFromSHA256SUMS(map[string]string{
+		"pool/main/h/hello/hello_2.10-2_amd64.deb": "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		"pool/main/h/hello/hello_2.10-2.dsc":       "f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377",
+	})
+	assert.NilError(t, err)
+	var pkgs []filespec.FileSpec
+	for _, sp := range fileSpecs {
+		pkgs = append(pkgs, *sp)
+	}
+	d := New().(distro.InstallCommander)
+	args, err := d.InstallCommand(c, pkgs, distro.InstallOpts{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"dpkg", "-i",
+		filepath.Join(cacheDir, "blobs/sha256/35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc")}, args)
+
+	_, err = d.InstallCommand(c, pkgs, distro.InstallOpts{Root: "/mnt"})
+	assert.ErrorContains(t, err, "custom root")
+}
+
+func TestGenerateDockerfile(t *testing.T) {
+	args := distro.DockerfileTemplateArgs{
+		BaseImage:          "ubuntu:22.04@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		BaseImageOrig:      "ubuntu:22.04",
+		Packages:           []string{"gcc", "libc6-dev"},
+		OCIArchDashVariant: "amd64",
+		Providers:          NewUbuntu().Info().DefaultProviders,
+	}
+	dir := t.TempDir()
+	assert.NilError(t, NewUbuntu().GenerateDockerfile(context.TODO(), dir, args, distro.DockerfileOpts{GenerateHash: true}))
+	b, err := os.ReadFile(filepath.Join(dir, "Dockerfile.generate-hash"))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(b), "ARG BASE_IMAGE="+args.BaseImage+" # ubuntu:22.04\n"))
+	assert.Assert(t, strings.Contains(string(b), `ARG PACKAGES="gcc libc6-dev"`))
+	assert.Assert(t, !strings.Contains(string(b), "snapshot.debian.org"))
+	b, err = os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(b), "http://archive.ubuntu.com/ubuntu/{{.Name}},http://security.ubuntu.com/ubuntu/{{.Name}}"))
+	assert.Assert(t, strings.Contains(string(b), "ARG SOURCE_DATE_EPOCH=\n"))
+
+	args.SourceDateEpoch = "1639958400"
+	assert.NilError(t, NewUbuntu().GenerateDockerfile(context.TODO(), dir, args, distro.DockerfileOpts{}))
+	b, err = os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(b), "ARG SOURCE_DATE_EPOCH=1639958400\n"))
+
+	assert.ErrorContains(t, (&debian{info: distro.Info{Name: "foo"}}).GenerateDockerfile(context.TODO(), dir, args, distro.DockerfileOpts{}), "not \"foo\"")
+}
@@ -0,0 +1,110 @@
+package debian
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp/armor"  //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"golang.org/x/crypto/openpgp/packet" //nolint:staticcheck // Ditto
+)
+
+// debsigOrigin is the ar member of the debsig signature of the "origin" role.
+const debsigOrigin = "_gpgorigin"
+
+// VerifyPackages verifies the debsig signatures of the *.deb files with debsig-verify(1),
+// following the policies in opts.PoliciesDir.
+func (d *debian) VerifyPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.VerifyPackagesOpts) ([]distro.PackageSignature, error) {
+	debs := binaryPackages(pkgs)
+	if len(debs) == 0 {
+		return nil, nil
+	}
+	cmdName, err := exec.LookPath("debsig-verify")
+	if err != nil {
+		return nil, fmt.Errorf("%w (Hint: install the debsig-verify package)", err)
+	}
+	var args []string
+	if opts.PoliciesDir != "" {
+		args = append(args, "--policies-dir", opts.PoliciesDir)
+	}
+	if opts.KeyringsDir != "" {
+		args = append(args, "--keyrings-dir", opts.KeyringsDir)
+	}
+	res := make([]distro.PackageSignature, 0, len(debs))
+	for _, pkg := range debs {
+		blob, err := c.BlobAbsPath(pkg.Sum())
+		if err != nil {
+			return nil, err
+		}
+		cmd := exec.CommandContext(ctx, cmdName, append(args, blob)...)
+		logrus.Debugf("Running %v", cmd.Args)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("failed to verify the debsig signature of %q: %w: %s", pkg.Name, err, strings.TrimSpace(string(out)))
+		}
+		keyID, err := debsigKeyIDFromFile(blob)
+		if err != nil {
+			// Not critical, as the signature was already verified by debsig-verify
+			logrus.WithError(err).Warnf("Failed to read the key ID of the debsig signature of %q", pkg.Name)
+		}
+		logrus.Debugf("Verified the debsig signature of %q (key %q)", pkg.Name, keyID)
+		res = append(res, distro.PackageSignature{Name: pkg.Name, KeyID: keyID})
+	}
+	return res, nil
+}
+
+func debsigKeyIDFromFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return debsigKeyID(f)
+}
+
+// debsigKeyID returns the issuer key ID of the debsig origin signature, without verifying the signature.
+func debsigKeyID(r io.Reader) (string, error) {
+	var sig []byte
+	err := readAr(r, func(name string, r io.Reader) error {
+		if name != debsigOrigin {
+			return nil
+		}
+		var err error
+		sig, err = io.ReadAll(io.LimitReader(r, 1024*1024))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if sig == nil {
+		return "", errors.New("not signed")
+	}
+	// The signature may be armored
+	var pr io.Reader = bytes.NewReader(sig)
+	if blk, err := armor.Decode(bytes.NewReader(sig)); err == nil {
+		pr = blk.Body
+	}
+	p, err := packet.Read(pr)
+	if err != nil {
+		return "", err
+	}
+	switch p := p.(type) {
+	case *packet.Signature:
+		if p.IssuerKeyId != nil {
+			return fmt.Sprintf("%016X", *p.IssuerKeyId), nil
+		}
+		return "", errors.New("the signature has no issuer key ID")
+	case *packet.SignatureV3:
+		return fmt.Sprintf("%016X", p.IssuerKeyId), nil
+	default:
+		return "", fmt.Errorf("expected a signature packet, got %T", p)
+	}
+}
@@ -0,0 +1,79 @@
+package debian
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"gotest.tools/v3/assert"
+)
+
+// fakeDebsigVerify installs a fake debsig-verify that accepts the packages with the origin signature,
+// when the policies dir is "/policies".
+func fakeDebsigVerify(t testing.TB) {
+	binDir := t.TempDir()
+	const script = `#!/bin/sh
+set -eu
+[ "$1" = "--policies-dir" ] && [ "$2" = "/policies" ]
+if grep -q _gpgorigin "$3"; then
+	echo "debsig: Verified package from 'Example' (example)"
+else
+	echo "debsig: Origin Signature check failed. This deb might not be signed."
+	exit 13
+fi
+`
+	assert.NilError(t, os.WriteFile(filepath.Join(binDir, "debsig-verify"), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestVerifyPackages(t *testing.T) {
+	fakeDebsigVerify(t)
+	e, err := openpgp.NewEntity("example", "", "example@example.com", nil)
+	assert.NilError(t, err)
+	members := map[string][]byte{
+		"debian-binary":  []byte("2.0\n"),
+		"control.tar.gz": gzipTar(t, nil),
+		"data.tar.gz":    gzipTar(t, nil),
+	}
+	var sig bytes.Buffer
+	assert.NilError(t, openpgp.ArmoredDetachSign(&sig, e,
+		bytes.NewReader(bytes.Join([][]byte{members["debian-binary"], members["control.tar.gz"], members["data.tar.gz"]}, nil)), nil))
+	members[debsigOrigin] = sig.Bytes()
+	signed := ar(members, "debian-binary", "control.tar.gz", "data.tar.gz", debsigOrigin)
+	unsigned := ar(members, "debian-binary", "control.tar.gz", "data.tar.gz")
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	signedSum, err := c.ImportWithReader(bytes.NewReader(signed))
+	assert.NilError(t, err)
+	unsignedSum, err := c.ImportWithReader(bytes.NewReader(unsigned))
+	assert.NilError(t, err)
+	signedSp, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", signedSum)
+	assert.NilError(t, err)
+	unsignedSp, err := filespec.New("pool/main/h/hello/hello_2.10-3_amd64.deb", unsignedSum)
+	assert.NilError(t, err)
+	src, err := filespec.New("pool/main/h/hello/hello_2.10-2.dsc", unsignedSum)
+	assert.NilError(t, err)
+
+	ctx := context.Background()
+	d := New().(distro.PackageVerifier)
+	opts := distro.VerifyPackagesOpts{PoliciesDir: "/policies"}
+	sigs, err := d.VerifyPackages(ctx, c, []filespec.FileSpec{*signedSp, *src}, opts)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []distro.PackageSignature{
+		{Name: signedSp.Name, KeyID: fmt.Sprintf("%016X", e.PrimaryKey.KeyId)},
+	}, sigs)
+
+	_, err = d.VerifyPackages(ctx, c, []filespec.FileSpec{*signedSp, *unsignedSp}, opts)
+	assert.ErrorContains(t, err, "Origin Signature check failed")
+
+	_, err = debsigKeyID(bytes.NewReader(unsigned))
+	assert.ErrorContains(t, err, "not signed")
+}
@@ -0,0 +1,80 @@
+package debian
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// keyDirs are the directories of the keyrings in the keyring packages, such as "debian-archive-keyring".
+var keyDirs = []string{
+	"usr/share/keyrings/",
+	"etc/apt/trusted.gpg.d/",
+}
+
+// ExtractKeys extracts the keyrings ("*.gpg" and "*.asc") from the *.deb file of a keyring package,
+// such as "debian-archive-keyring" and "ubuntu-keyring".
+// The keyrings of the removed keys, such as "debian-archive-removed-keys.gpg", are ignored.
+func (d *debian) ExtractKeys(ctx context.Context, file string) (map[string][]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res, err := extractKeys(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the keys from %q: %w", file, err)
+	}
+	return res, nil
+}
+
+func extractKeys(ctx context.Context, r io.Reader) (map[string][]byte, error) {
+	res := make(map[string][]byte)
+	err := readAr(r, func(name string, r io.Reader) error {
+		if !strings.HasPrefix(name, "data.tar") {
+			return nil
+		}
+		return withDecompressedTar(ctx, name, r, func(tr *tar.Reader) error {
+			for {
+				hdr, err := tr.Next()
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if hdr.Typeflag != tar.TypeReg || !isKeyFile(hdr.Name) {
+					continue
+				}
+				b, err := io.ReadAll(tr)
+				if err != nil {
+					return err
+				}
+				res[path.Base(hdr.Name)] = b
+			}
+		})
+	})
+	return res, err
+}
+
+func isKeyFile(name string) bool {
+	name = strings.TrimPrefix(name, "./")
+	base := path.Base(name)
+	if strings.Contains(base, "removed") {
+		return false
+	}
+	if ext := path.Ext(base); ext != ".gpg" && ext != ".asc" {
+		return false
+	}
+	for _, dir := range keyDirs {
+		if path.Dir(name)+"/" == dir {
+			return true
+		}
+	}
+	return false
+}
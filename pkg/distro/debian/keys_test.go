@@ -0,0 +1,36 @@
+package debian
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"gotest.tools/v3/assert"
+)
+
+func TestExtractKeys(t *testing.T) {
+	data := gzipTar(t, []tarEntry{
+		{hdr: tar.Header{Name: "./usr/share/keyrings/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{hdr: tar.Header{Name: "./usr/share/keyrings/debian-archive-keyring.gpg", Typeflag: tar.TypeReg, Mode: 0644}, content: "keyring"},
+		{hdr: tar.Header{Name: "./usr/share/keyrings/debian-archive-removed-keys.gpg", Typeflag: tar.TypeReg, Mode: 0644}, content: "removed"},
+		{hdr: tar.Header{Name: "./etc/apt/trusted.gpg.d/debian-archive-bookworm-automatic.asc", Typeflag: tar.TypeReg, Mode: 0644}, content: "trusted"},
+		{hdr: tar.Header{Name: "./usr/share/doc/debian-archive-keyring/README.gpg", Typeflag: tar.TypeReg, Mode: 0644}, content: "doc"},
+	})
+	deb := ar(map[string][]byte{
+		"debian-binary":  []byte("2.0\n"),
+		"control.tar.gz": gzipTar(t, nil),
+		"data.tar.gz":    data,
+	}, "debian-binary", "control.tar.gz", "data.tar.gz")
+	file := filepath.Join(t.TempDir(), "debian-archive-keyring_2023.3_all.deb")
+	assert.NilError(t, os.WriteFile(file, deb, 0644))
+
+	keys, err := New().(distro.KeyExtractor).ExtractKeys(context.TODO(), file)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string][]byte{
+		"debian-archive-keyring.gpg":            []byte("keyring"),
+		"debian-archive-bookworm-automatic.asc": []byte("trusted"),
+	}, keys)
+}
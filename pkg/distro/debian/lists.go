@@ -0,0 +1,202 @@
+package debian
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/sirupsen/logrus"
+	"pault.ag/go/debian/control"
+)
+
+// ListsDir is the directory where apt stores the index files.
+const ListsDir = "/var/lib/apt/lists"
+
+// listsFiles returns the sorted list of the index files with the specified suffix, such as "_Packages".
+// The compressed files (*.gz) are included too.
+func listsFiles(dir, suffix string) ([]string, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, ent := range ents {
+		if ent.IsDir() {
+			continue
+		}
+		name := ent.Name()
+		switch {
+		case strings.HasSuffix(name, suffix), strings.HasSuffix(name, suffix+".gz"):
+			res = append(res, filepath.Join(dir, name))
+		case strings.Contains(name, suffix+"."):
+			logrus.Warnf("Ignoring %q (unsupported compression)", name)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// writeListsHashes records the SHA256 of the index files with the specified suffix, as they are stored in the lists dir.
+// The file names are recorded without the dir, such as "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages".
+func writeListsHashes(iw distro.HashWriter, dir, suffix string) error {
+	files, err := listsFiles(dir, suffix)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		dgst, err := digest.SHA256.FromReader(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if err = iw(dgst.Encoded(), filepath.Base(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openListsFile opens an index file, with decompression.
+func openListsFile(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(file, ".gz") {
+		return f, nil
+	}
+	gzR, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to decompress %q: %w", file, err)
+	}
+	return &gzReadCloser{Reader: gzR, f: f}, nil
+}
+
+type gzReadCloser struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (r *gzReadCloser) Close() error {
+	gzErr := r.Reader.Close()
+	fErr := r.f.Close()
+	if gzErr != nil {
+		return gzErr
+	}
+	return fErr
+}
+
+// parsePackages parses a "Packages" index.
+// When filter is non-nil, only the paragraphs with the package names in the filter are returned.
+// The filter may contain the names with the architecture qualifier, such as "bash:amd64".
+func parsePackages(r io.Reader, filter map[string]struct{}) ([]control.Paragraph, error) {
+	pr, err := control.NewParagraphReader(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res []control.Paragraph
+	for {
+		p, err := pr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		if filter != nil {
+			pkg := p.Values["Package"]
+			_, ok := filter[pkg]
+			if !ok {
+				_, ok = filter[pkg+":"+p.Values["Architecture"]]
+			}
+			if !ok {
+				continue
+			}
+		}
+		res = append(res, *p)
+	}
+	return res, nil
+}
+
+// readPackagesLists reads "*_Packages" files in the lists dir.
+// The files are verified with rv, unless rv is nil.
+// The paragraphs are annotated with the pin priorities computed by pp, unless pp is nil.
+// The paragraphs with negative pin priorities are omitted.
+func readPackagesLists(dir string, filter map[string]struct{}, rv *releaseVerifier, pp *pinPolicy) ([]control.Paragraph, error) {
+	res, err := readLists(dir, "_Packages", filter, rv, pp)
+	if errors.Is(err, errNoListsFile) {
+		return nil, fmt.Errorf("no Packages file was found in %q (Hint: try 'apt-get update')", dir)
+	}
+	return res, err
+}
+
+var errNoListsFile = errors.New("no lists file was found")
+
+// listsFileField is the synthetic field for annotating the paragraphs with the base name of the lists file.
+const listsFileField = "X-Repro-Get-Lists-File"
+
+func readLists(dir, suffix string, filter map[string]struct{}, rv *releaseVerifier, pp *pinPolicy) ([]control.Paragraph, error) {
+	files, err := listsFiles(dir, suffix)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errNoListsFile
+	}
+	var res []control.Paragraph
+	for _, f := range files {
+		var (
+			rel     *release
+			relPath string
+		)
+		if rv != nil {
+			logrus.Debugf("Verifying %q", f)
+			if rel, relPath, err = rv.verify(f); err != nil {
+				return nil, err
+			}
+		}
+		logrus.Debugf("Reading %q", f)
+		r, err := openListsFile(f)
+		if err != nil {
+			return nil, err
+		}
+		paragraphs, err := parsePackages(r, filter)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", f, err)
+		}
+		// The file name is like "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages"
+		base := filepath.Base(f)
+		for _, p := range paragraphs {
+			p.Values[listsFileField] = base
+		}
+		if pp == nil {
+			res = append(res, paragraphs...)
+			continue
+		}
+		host, _, _ := strings.Cut(base, "_")
+		for _, p := range paragraphs {
+			priority := pp.priority(p, rel, relPath, host)
+			if priority < 0 {
+				logrus.Debugf("Ignoring %s %s in %q (pin priority %d)", p.Values["Package"], p.Values["Version"], f, priority)
+				continue
+			}
+			p.Values[pinPriorityField] = strconv.Itoa(priority)
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,37 @@
+package debian
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteListsHashes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages":    "Package: hello\n",
+		"deb.debian.org_debian_dists_bullseye_contrib_binary-amd64_Packages": "Package: foo\n",
+		"deb.debian.org_debian_dists_bullseye_main_source_Sources":           "Package: hello\n",
+		"deb.debian.org_debian_dists_bullseye_InRelease":                     "dummy",
+	}
+	for name, content := range files {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	got := make(map[string]string)
+	iw := func(sha256sum, filename string) error {
+		got[filename] = sha256sum
+		return nil
+	}
+	assert.NilError(t, writeListsHashes(iw, dir, "_Packages"))
+	expected := make(map[string]string)
+	for _, name := range []string{
+		"deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages",
+		"deb.debian.org_debian_dists_bullseye_contrib_binary-amd64_Packages",
+	} {
+		expected[name] = digest.SHA256.FromString(files[name]).Encoded()
+	}
+	assert.DeepEqual(t, expected, got)
+}
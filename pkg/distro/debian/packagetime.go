@@ -0,0 +1,54 @@
+package debian
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// PackageTime returns the modification time of the first member ("debian-binary") of the *.deb archive,
+// which is set to the build time ($SOURCE_DATE_EPOCH) by dpkg-deb.
+func (d *debian) PackageTime(c *cache.Cache, pkg filespec.FileSpec) (time.Time, error) {
+	if pkg.Dpkg == nil {
+		return time.Time{}, nil
+	}
+	blob, err := c.BlobAbsPath(pkg.Sum())
+	if err != nil {
+		return time.Time{}, err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	t, err := debTime(f)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the build time of %q: %w", pkg.Basename, err)
+	}
+	return t, nil
+}
+
+// debTime returns the modification time of the first member of the ar archive.
+func debTime(r io.Reader) (time.Time, error) {
+	br := bufio.NewReader(r)
+	b := make([]byte, 8+60) // The magic and the first header
+	if _, err := io.ReadFull(br, b); err != nil {
+		return time.Time{}, err
+	}
+	if string(b[:8]) != "!<arch>\n" {
+		return time.Time{}, errors.New("not an ar archive")
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(b[8+16:8+28])), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid modification time of the ar member: %w", err)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
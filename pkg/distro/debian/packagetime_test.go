@@ -0,0 +1,42 @@
+package debian
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestPackageTime(t *testing.T) {
+	mt := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	deb := ar(map[string][]byte{
+		"debian-binary": []byte("2.0\n"),
+	}, "debian-binary")
+	copy(deb[8+16:8+28], fmt.Sprintf("%-12d", mt.Unix()))
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	sum, err := c.ImportWithReader(bytes.NewReader(deb))
+	assert.NilError(t, err)
+	sp, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", sum)
+	assert.NilError(t, err)
+
+	d := New().(distro.PackageTimer)
+	got, err := d.PackageTime(c, *sp)
+	assert.NilError(t, err)
+	assert.Equal(t, mt, got)
+
+	src, err := filespec.New("pool/main/h/hello/hello_2.10-2.dsc", sum)
+	assert.NilError(t, err)
+	got, err = d.PackageTime(c, *src)
+	assert.NilError(t, err)
+	assert.Assert(t, got.IsZero())
+
+	_, err = debTime(bytes.NewReader([]byte("not an ar archive, but long enough for the header of the first member")))
+	assert.ErrorContains(t, err, "not an ar archive")
+}
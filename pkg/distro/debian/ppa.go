@@ -0,0 +1,35 @@
+package debian
+
+import (
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"pault.ag/go/debian/control"
+)
+
+// ppaHosts are the host names of the Launchpad PPAs.
+var ppaHosts = []string{"ppa.launchpadcontent.net", "ppa.launchpad.net"}
+
+// ppaOf returns the PPA of the paragraph, from the name of the lists file such as
+// "ppa.launchpadcontent.net_deadsnakes_ppa_ubuntu_dists_jammy_main_binary-amd64_Packages".
+// Returns nil for the paragraphs that are not from PPAs.
+func ppaOf(f control.Paragraph) *filespec.PPA {
+	sp := strings.Split(f.Values[listsFileField], "_")
+	if len(sp) < 4 {
+		return nil
+	}
+	for _, h := range ppaHosts {
+		if sp[0] == h {
+			return &filespec.PPA{Owner: sp[1], Name: sp[2]}
+		}
+	}
+	return nil
+}
+
+// samePPA returns true if a and b are the same PPA, or both nil.
+func samePPA(a, b *filespec.PPA) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
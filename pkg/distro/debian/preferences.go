@@ -0,0 +1,317 @@
+package debian
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/sirupsen/logrus"
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/version"
+)
+
+const (
+	// PreferencesFile is the apt preferences file for pinning.
+	PreferencesFile = "/etc/apt/preferences"
+	// PreferencesDir contains the apt preferences files, with no extension or with ".pref".
+	PreferencesDir = "/etc/apt/preferences.d"
+	// AptConfFile is the apt configuration file, for reading "APT::Default-Release".
+	AptConfFile = "/etc/apt/apt.conf"
+	// AptConfDir contains the apt configuration files.
+	AptConfDir = "/etc/apt/apt.conf.d"
+)
+
+// Default pin priorities. See apt_preferences(5).
+const (
+	priorityTargetRelease       = 990
+	priorityDefault             = 500
+	priorityButAutomaticUpgrade = 100
+	priorityNotAutomatic        = 1
+)
+
+// pinPriorityField is the synthetic field for annotating the paragraphs with the pin priority.
+const pinPriorityField = "X-Repro-Get-Pin-Priority"
+
+// pin is an entry of the apt preferences.
+type pin struct {
+	packages []string // globs; "*" for the generic pins
+	kind     string   // "release", "version", or "origin"
+	value    string   // e.g., "a=bullseye-backports", "1.2*", "deb.debian.org"
+	priority int
+}
+
+func (p *pin) generic() bool {
+	return len(p.packages) == 1 && p.packages[0] == "*"
+}
+
+func (p *pin) matchesPackage(name string) bool {
+	for _, pattern := range p.packages {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pinPolicy computes the pin priorities of the paragraphs, as in apt-cache policy.
+type pinPolicy struct {
+	pins          []pin
+	targetRelease string
+}
+
+// newPinPolicy creates pinPolicy from the preferences files and the configuration in the root filesystem.
+//
+// preferences is the list of the preferences files; the files in the root filesystem are used when empty.
+// targetRelease overrides "APT::Default-Release" in the root filesystem, when non-empty.
+func newPinPolicy(root string, preferences []string, targetRelease string) (*pinPolicy, error) {
+	if root == "" {
+		root = "/"
+	}
+	pp := &pinPolicy{
+		targetRelease: targetRelease,
+	}
+	if len(preferences) == 0 {
+		var err error
+		preferences, err = confFiles(root, PreferencesFile, PreferencesDir, ".pref")
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range preferences {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		pins, err := parsePreferences(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", f, err)
+		}
+		pp.pins = append(pp.pins, pins...)
+	}
+	if pp.targetRelease == "" {
+		aptConfs, err := confFiles(root, AptConfFile, AptConfDir, ".conf")
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range aptConfs {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if v := parseDefaultRelease(b); v != "" {
+				pp.targetRelease = v
+			}
+		}
+	}
+	if pp.targetRelease != "" {
+		logrus.Debugf("Target release: %q", pp.targetRelease)
+	}
+	return pp, nil
+}
+
+// confFiles returns the file and the files in the dir, in the root filesystem.
+// The files in the dir must have no extension or the ext.
+func confFiles(root, file, dir, ext string) ([]string, error) {
+	var res []string
+	file, err := securejoin.SecureJoin(root, file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(file); err == nil {
+		res = append(res, file)
+	}
+	dir, err = securejoin.SecureJoin(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, ent := range ents {
+		if ent.IsDir() {
+			continue
+		}
+		if e := filepath.Ext(ent.Name()); e != "" && e != ext {
+			continue
+		}
+		res = append(res, filepath.Join(dir, ent.Name()))
+	}
+	return res, nil
+}
+
+// parsePreferences parses an apt preferences file.
+func parsePreferences(b []byte) ([]pin, error) {
+	var buf bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		buf.WriteString(line + "\n")
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	paragraphs, err := parsePackages(&buf, nil)
+	if err != nil {
+		return nil, err
+	}
+	var res []pin
+	for _, f := range paragraphs {
+		pkgs := strings.Fields(f.Values["Package"])
+		if len(pkgs) == 0 {
+			// "Explanation:"-only paragraphs
+			continue
+		}
+		kind, value, _ := strings.Cut(strings.TrimSpace(f.Values["Pin"]), " ")
+		switch kind {
+		case "release", "version", "origin":
+		default:
+			return nil, fmt.Errorf("unsupported pin %q for %v", f.Values["Pin"], pkgs)
+		}
+		priority, err := strconv.Atoi(strings.TrimSpace(f.Values["Pin-Priority"]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Pin-Priority for %v: %w", pkgs, err)
+		}
+		for _, pkg := range pkgs {
+			if strings.HasPrefix(pkg, "/") || strings.HasPrefix(pkg, "src:") {
+				logrus.Warnf("Ignoring unsupported package pattern %q in the preferences", pkg)
+			}
+		}
+		res = append(res, pin{
+			packages: pkgs,
+			kind:     kind,
+			value:    strings.TrimSpace(value),
+			priority: priority,
+		})
+	}
+	return res, nil
+}
+
+var defaultReleaseRegexp = regexp.MustCompile(`(?m)^\s*APT::Default-Release\s+"([^"]*)"\s*;`)
+
+// parseDefaultRelease returns the value of "APT::Default-Release" in an apt configuration file.
+// The nested syntax such as `APT { Default-Release "stable"; };` is not supported.
+func parseDefaultRelease(b []byte) string {
+	var res string
+	for _, m := range defaultReleaseRegexp.FindAllSubmatch(b, -1) {
+		res = string(m[1])
+	}
+	return res
+}
+
+// priority returns the pin priority of the paragraph.
+// rel is the Release file of the index file (nil if unknown),
+// relPath is the relative path of the index file such as "main/binary-amd64/Packages",
+// and host is the host name of the repository.
+func (pp *pinPolicy) priority(f control.Paragraph, rel *release, relPath, host string) int {
+	var component string
+	if relPath != "" {
+		component, _, _ = strings.Cut(relPath, "/")
+	}
+	pkg := f.Values["Package"]
+	// Pins for the specific packages take precedence over the generic pins
+	for _, generic := range []bool{false, true} {
+		for _, p := range pp.pins {
+			if p.generic() != generic || !p.matchesPackage(pkg) {
+				continue
+			}
+			if p.matches(f, rel, component, host) {
+				return p.priority
+			}
+		}
+	}
+	if rel == nil {
+		return priorityDefault
+	}
+	if pp.targetRelease != "" && (pp.targetRelease == rel.Values["Suite"] || pp.targetRelease == rel.Values["Codename"]) {
+		return priorityTargetRelease
+	}
+	if rel.Values["NotAutomatic"] == "yes" {
+		if rel.Values["ButAutomaticUpgrades"] == "yes" {
+			return priorityButAutomaticUpgrade
+		}
+		return priorityNotAutomatic
+	}
+	return priorityDefault
+}
+
+func (p *pin) matches(f control.Paragraph, rel *release, component, host string) bool {
+	switch p.kind {
+	case "version":
+		ok, _ := path.Match(p.value, f.Values["Version"])
+		return ok
+	case "origin":
+		return strings.Trim(p.value, `"`) == host
+	case "release":
+		if rel == nil {
+			return false
+		}
+		fields := map[string]string{
+			"a": rel.Values["Suite"],
+			"n": rel.Values["Codename"],
+			"v": rel.Values["Version"],
+			"o": rel.Values["Origin"],
+			"l": rel.Values["Label"],
+			"c": component,
+			"b": f.Values["Architecture"],
+		}
+		for _, cond := range strings.Split(p.value, ",") {
+			cond = strings.TrimSpace(cond)
+			k, v, ok := strings.Cut(cond, "=")
+			if !ok {
+				// "Pin: release bullseye-backports" is equivalent to "Pin: release a=bullseye-backports"
+				k, v = "a", cond
+			}
+			actual, known := fields[k]
+			if !known {
+				logrus.Warnf("Ignoring unsupported pin condition %q", cond)
+				continue
+			}
+			if ok, _ := path.Match(v, actual); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// pinPriority returns the pin priority annotated to the paragraph by readPackagesLists.
+func pinPriority(f control.Paragraph) int {
+	v, ok := f.Values[pinPriorityField]
+	if !ok {
+		return priorityDefault
+	}
+	priority, err := strconv.Atoi(v)
+	if err != nil {
+		return priorityDefault
+	}
+	return priority
+}
+
+// preferParagraph returns true if f should be preferred over seen, by comparing the pin priorities and then the versions.
+func preferParagraph(f, seen control.Paragraph) (bool, error) {
+	if pf, ps := pinPriority(f), pinPriority(seen); pf != ps {
+		return pf > ps, nil
+	}
+	seenVer, err := version.Parse(seen.Values["Version"])
+	if err != nil {
+		return false, fmt.Errorf("failed to parse version %q: %w", seen.Values["Version"], err)
+	}
+	ver, err := version.Parse(f.Values["Version"])
+	if err != nil {
+		return false, fmt.Errorf("failed to parse version %q: %w", f.Values["Version"], err)
+	}
+	return version.Compare(seenVer, ver) < 0, nil
+}
@@ -0,0 +1,108 @@
+package debian
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+	"pault.ag/go/debian/control"
+)
+
+func TestParsePreferences(t *testing.T) {
+	const s = `# comment
+Package: *
+Pin: release a=bullseye-backports
+Pin-Priority: 600
+
+Explanation: pin hello
+Package: hello hello-*
+Pin: version 2.10*
+Pin-Priority: 1001
+
+Package: foo
+Pin: origin "deb.example.com"
+Pin-Priority: -1
+`
+	pins, err := parsePreferences([]byte(s))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []pin{
+		{packages: []string{"*"}, kind: "release", value: "a=bullseye-backports", priority: 600},
+		{packages: []string{"hello", "hello-*"}, kind: "version", value: "2.10*", priority: 1001},
+		{packages: []string{"foo"}, kind: "origin", value: `"deb.example.com"`, priority: -1},
+	}, pins, cmp.AllowUnexported(pin{}))
+
+	_, err = parsePreferences([]byte("Package: *\nPin: foo\nPin-Priority: 1\n"))
+	assert.ErrorContains(t, err, "unsupported pin")
+}
+
+func TestParseDefaultRelease(t *testing.T) {
+	assert.Equal(t, "bullseye", parseDefaultRelease([]byte(`APT::Install-Recommends "false";
+APT::Default-Release "bullseye";
+`)))
+	assert.Equal(t, "", parseDefaultRelease([]byte(`APT::Install-Recommends "false";`)))
+}
+
+func TestPinPolicy(t *testing.T) {
+	stable := &release{Paragraph: control.Paragraph{Values: map[string]string{
+		"Origin": "Debian", "Suite": "stable", "Codename": "bullseye",
+	}}}
+	backports := &release{Paragraph: control.Paragraph{Values: map[string]string{
+		"Origin": "Debian Backports", "Suite": "stable-backports", "Codename": "bullseye-backports",
+		"NotAutomatic": "yes", "ButAutomaticUpgrades": "yes",
+	}}}
+	hello := control.Paragraph{Values: map[string]string{"Package": "hello", "Version": "2.10-2", "Architecture": "amd64"}}
+	helloBpo := control.Paragraph{Values: map[string]string{"Package": "hello", "Version": "2.10-3~bpo11+1", "Architecture": "amd64"}}
+
+	pp := &pinPolicy{}
+	assert.Equal(t, priorityDefault, pp.priority(hello, stable, "main/binary-amd64/Packages", "deb.debian.org"))
+	assert.Equal(t, priorityButAutomaticUpgrade, pp.priority(helloBpo, backports, "main/binary-amd64/Packages", "deb.debian.org"))
+	assert.Equal(t, priorityDefault, pp.priority(helloBpo, nil, "", "deb.debian.org"))
+
+	pp = &pinPolicy{targetRelease: "bullseye-backports"}
+	assert.Equal(t, priorityTargetRelease, pp.priority(helloBpo, backports, "main/binary-amd64/Packages", "deb.debian.org"))
+
+	pp = &pinPolicy{pins: []pin{
+		{packages: []string{"*"}, kind: "release", value: "o=Debian,c=main", priority: 900},
+		{packages: []string{"hello"}, kind: "release", value: "n=bullseye-backports", priority: 700},
+	}}
+	assert.Equal(t, 900, pp.priority(hello, stable, "main/binary-amd64/Packages", "deb.debian.org"))
+	assert.Equal(t, priorityDefault, pp.priority(hello, stable, "contrib/binary-amd64/Packages", "deb.debian.org"))
+	// The specific pin takes precedence over the generic one
+	assert.Equal(t, 700, pp.priority(helloBpo, backports, "main/binary-amd64/Packages", "deb.debian.org"))
+}
+
+func TestNewestParagraphsWithPinPriority(t *testing.T) {
+	hello := control.Paragraph{Values: map[string]string{"Package": "hello", "Version": "2.10-2", "Architecture": "amd64", pinPriorityField: "500"}}
+	helloBpo := control.Paragraph{Values: map[string]string{"Package": "hello", "Version": "2.10-3~bpo11+1", "Architecture": "amd64", pinPriorityField: "100"}}
+	res := newestParagraphs([]control.Paragraph{helloBpo, hello})
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, "2.10-2", res[0].Values["Version"])
+
+	helloBpo.Values[pinPriorityField] = "500"
+	res = newestParagraphs([]control.Paragraph{hello, helloBpo})
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, "2.10-3~bpo11+1", res[0].Values["Version"])
+}
+
+func TestNewPinPolicy(t *testing.T) {
+	root := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, PreferencesDir), 0755))
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, AptConfDir), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, PreferencesDir, "backports.pref"),
+		[]byte("Package: *\nPin: release n=bullseye-backports\nPin-Priority: 600\n"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, PreferencesDir, "ignored.dpkg-old"),
+		[]byte("invalid"), 0644))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, AptConfDir, "99default-release"),
+		[]byte(`APT::Default-Release "bullseye";`+"\n"), 0644))
+
+	pp, err := newPinPolicy(root, nil, "")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(pp.pins))
+	assert.Equal(t, "bullseye", pp.targetRelease)
+
+	pp, err = newPinPolicy(root, nil, "bullseye-backports")
+	assert.NilError(t, err)
+	assert.Equal(t, "bullseye-backports", pp.targetRelease)
+}
@@ -0,0 +1,67 @@
+package debian
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// ImportPrintURIs parses the output of "apt-get install --print-uris -qq PACKAGES...", and writes the hashes to hw.
+//
+// Each line of the output consists of the quoted URL, the file name, the size, and the checksum, such as:
+//
+//	'http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb' hello_2.10-2_amd64.deb 56132 SHA256:35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc
+//
+// The file names in the hash file are taken from the URLs, starting with "pool/".
+// The other lines (e.g., "Reading package lists...") are ignored.
+func ImportPrintURIs(r io.Reader, hw distro.HashWriter) error {
+	sc := bufio.NewScanner(r)
+	for i := 1; sc.Scan(); i++ {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "'") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return fmt.Errorf("line %d: expected \"'URL' FILENAME SIZE CHECKSUM\", got %q", i, line)
+		}
+		rawURL := strings.Trim(fields[0], "'")
+		if _, err := url.Parse(rawURL); err != nil {
+			return fmt.Errorf("line %d: failed to parse the URL %q: %w", i, rawURL, err)
+		}
+		poolIdx := strings.Index(rawURL, "/pool/")
+		if poolIdx < 0 {
+			return fmt.Errorf("line %d: the URL %q is not in the pool of the repository", i, rawURL)
+		}
+		name := rawURL[poolIdx+1:]
+		if err := filespec.ValidateName(name); err != nil {
+			return fmt.Errorf("line %d: %w", i, err)
+		}
+		alg, sum, ok := strings.Cut(fields[3], ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected \"<ALGORITHM>:<CHECKSUM>\", got %q", i, fields[3])
+		}
+		switch alg {
+		case "SHA256":
+		case "SHA512":
+			sum = sha256sums.SHA512Prefix + sum
+		default:
+			return fmt.Errorf("line %d: unsupported checksum algorithm %q for %q (Hint: run 'apt-get update' to fetch the repository metadata with SHA256)", i, alg, name)
+		}
+		if _, err := sha256sums.ParseSum(sum); err != nil {
+			return fmt.Errorf("line %d: %w", i, err)
+		}
+		logrus.Debugf("Importing %q (%s bytes) from %q", name, fields[2], rawURL)
+		if err := hw(sum, name); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,35 @@
+package debian
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"gotest.tools/v3/assert"
+)
+
+const testSHA512 = "2e7a3d7c2f5a7e6c4b6d8ae4f8ee4dbb27c2fd9b01f3b13ee0ea1f8af1bd5b9e3f62ba1e4a7b8d86e4b9d2ed5b3d0c7a7d6c0cb3f8b0c4fd10f3ad4c0f98e2c1"
+
+func TestImportPrintURIs(t *testing.T) {
+	const printURIs = `Reading package lists...
+'http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb' hello_2.10-2_amd64.deb 56132 SHA256:35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc
+'http://deb.debian.org/debian-security/pool/updates/main/f/foo/foo_1%3a2.0-1_amd64.deb' foo_1%3a2.0-1_amd64.deb 1234 SHA512:` + testSHA512 + `
+`
+	var b bytes.Buffer
+	assert.NilError(t, ImportPrintURIs(strings.NewReader(printURIs), distro.NewHashWriter(&b)))
+	assert.Equal(t, `35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+`+testSHA512+`  pool/updates/main/f/foo/foo_1%3a2.0-1_amd64.deb
+`, b.String())
+
+	testCases := map[string]string{
+		"'http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb' hello_2.10-2_amd64.deb 56132 MD5Sum:0123456789abcdef0123456789abcdef":     "unsupported checksum algorithm",
+		"'http://example.com/hello_2.10-2_amd64.deb' hello_2.10-2_amd64.deb 56132 SHA256:35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc": "not in the pool",
+		"'http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb' hello_2.10-2_amd64.deb 56132":                                             "expected",
+		"'http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb' hello_2.10-2_amd64.deb 56132 SHA256:35b1":                                 "line 1",
+	}
+	for line, expected := range testCases {
+		err := ImportPrintURIs(strings.NewReader(line+"\n"), distro.NewHashWriter(&b))
+		assert.ErrorContains(t, err, expected, line)
+	}
+}
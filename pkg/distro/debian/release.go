@@ -0,0 +1,248 @@
+package debian
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"pault.ag/go/debian/control"
+)
+
+// DefaultKeyrings are the OpenPGP keyrings for verifying the InRelease files.
+// Nonexistent files are ignored.
+var DefaultKeyrings = []string{
+	"/etc/apt/trusted.gpg",
+	"/etc/apt/trusted.gpg.d/*.gpg",
+	"/etc/apt/trusted.gpg.d/*.asc",
+	"/usr/share/keyrings/debian-archive-keyring.gpg",
+	"/usr/share/keyrings/ubuntu-archive-keyring.gpg",
+}
+
+// ReadKeyrings reads the OpenPGP keyrings.
+// The file names may contain globs.
+// Both binary and ASCII-armored keyrings are supported.
+func ReadKeyrings(root string, patterns []string) (openpgp.EntityList, error) {
+	if root == "" {
+		root = "/"
+	}
+	var res openpgp.EntityList
+	for _, pattern := range patterns {
+		pattern, err := securejoin.SecureJoin(root, pattern)
+		if err != nil {
+			return nil, err
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			el, err := readKeyring(f)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read keyring %q: %w", f, err)
+			}
+			res = append(res, el...)
+		}
+	}
+	return res, nil
+}
+
+func readKeyring(file string) (openpgp.EntityList, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
+
+// releaseVerifier verifies the index files in the lists dir, using the InRelease (or Release and Release.gpg) files.
+type releaseVerifier struct {
+	dir           string
+	keyring       openpgp.EntityList
+	allowUnsigned bool
+	releases      map[string]*release // key: release file prefix
+}
+
+// release is a parsed Release file.
+type release struct {
+	control.Paragraph                   // "Origin", "Suite", "Codename", ...
+	hashes            map[string]string // key: relative path such as "main/binary-amd64/Packages", value: sha256
+}
+
+func newReleaseVerifier(dir string, keyring openpgp.EntityList, allowUnsigned bool) *releaseVerifier {
+	return &releaseVerifier{
+		dir:           dir,
+		keyring:       keyring,
+		allowUnsigned: allowUnsigned,
+		releases:      make(map[string]*release),
+	}
+}
+
+// releasePrefix returns the prefix of the Release file for the index file, such as
+// "deb.debian.org_debian_dists_bullseye" for "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages".
+func (rv *releaseVerifier) releasePrefix(base string) (string, error) {
+	ents, err := os.ReadDir(rv.dir)
+	if err != nil {
+		return "", err
+	}
+	var res string
+	for _, ent := range ents {
+		name := ent.Name()
+		prefix := strings.TrimSuffix(strings.TrimSuffix(name, "_InRelease"), "_Release")
+		if prefix == name {
+			continue
+		}
+		if strings.HasPrefix(base, prefix+"_") && len(prefix) > len(res) {
+			res = prefix
+		}
+	}
+	if res == "" {
+		return "", fmt.Errorf("no InRelease file was found for %q", base)
+	}
+	return res, nil
+}
+
+// loadRelease loads the Release file with the prefix.
+func (rv *releaseVerifier) loadRelease(prefix string) (*release, error) {
+	if r, ok := rv.releases[prefix]; ok {
+		return r, nil
+	}
+	var paragraph *control.Paragraph
+	inRelease := filepath.Join(rv.dir, prefix+"_InRelease")
+	b, err := os.ReadFile(inRelease)
+	switch {
+	case err == nil:
+		paragraph, err = readReleaseParagraph(bytes.NewReader(b), &rv.keyring)
+		if err != nil {
+			if !rv.allowUnsigned {
+				return nil, fmt.Errorf("failed to verify %q: %w (Hint: specify --keyring, or --allow-unsigned)", inRelease, err)
+			}
+			logrus.WithError(err).Warnf("Failed to verify %q", inRelease)
+			paragraph, err = readReleaseParagraph(bytes.NewReader(b), nil)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		paragraph, err = rv.loadDetachedRelease(prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string)
+	// Each line is like "<SHA256> <SIZE> <PATH>"
+	for _, line := range strings.Split(strings.TrimSpace(paragraph.Values["SHA256"]), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		m[fields[2]] = fields[0]
+	}
+	r := &release{
+		Paragraph: *paragraph,
+		hashes:    m,
+	}
+	rv.releases[prefix] = r
+	return r, nil
+}
+
+// loadDetachedRelease loads the Release file with the detached signature (Release.gpg).
+func (rv *releaseVerifier) loadDetachedRelease(prefix string) (*control.Paragraph, error) {
+	release := filepath.Join(rv.dir, prefix+"_Release")
+	b, err := os.ReadFile(release)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := os.Open(release + ".gpg")
+	if err == nil {
+		defer sig.Close()
+		_, err = openpgp.CheckArmoredDetachedSignature(rv.keyring, bytes.NewReader(b), sig)
+	}
+	if err != nil {
+		if !rv.allowUnsigned {
+			return nil, fmt.Errorf("failed to verify %q: %w (Hint: specify --keyring, or --allow-unsigned)", release, err)
+		}
+		logrus.WithError(err).Warnf("Failed to verify %q", release)
+	}
+	return readReleaseParagraph(bytes.NewReader(b), nil)
+}
+
+func readReleaseParagraph(r io.Reader, keyring *openpgp.EntityList) (*control.Paragraph, error) {
+	pr, err := control.NewParagraphReader(r, keyring)
+	if err != nil {
+		return nil, err
+	}
+	if keyring != nil && pr.Signer() == nil {
+		return nil, errors.New("not signed")
+	}
+	return pr.Next()
+}
+
+// verify verifies the index file in the lists dir, such as "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages".
+// Returns the Release file and the relative path such as "main/binary-amd64/Packages".
+// The returned Release file is nil when the Release file is missing and rv.allowUnsigned is true.
+func (rv *releaseVerifier) verify(file string) (*release, string, error) {
+	base := filepath.Base(file)
+	prefix, err := rv.releasePrefix(base)
+	if err != nil {
+		if rv.allowUnsigned {
+			logrus.WithError(err).Warnf("Failed to verify %q", file)
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("%w (Hint: specify --allow-unsigned)", err)
+	}
+	r, err := rv.loadRelease(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	// apt escapes "_" in the path components as "%5f"
+	rel, err := url.PathUnescape(strings.ReplaceAll(strings.TrimPrefix(base, prefix+"_"), "_", "/"))
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	dgst, err := digest.SHA256.FromReader(f)
+	if err != nil {
+		return nil, "", err
+	}
+	if expected, ok := r.hashes[rel]; ok && expected == dgst.Encoded() {
+		return r, rel, nil
+	}
+	// The file might have been recompressed by apt, so check the hash of the decompressed content too
+	if strings.HasSuffix(rel, ".gz") {
+		rel = strings.TrimSuffix(rel, ".gz")
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return nil, "", err
+		}
+		gzR, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, "", err
+		}
+		defer gzR.Close()
+		dgst, err = digest.SHA256.FromReader(gzR)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	expected, ok := r.hashes[rel]
+	if !ok {
+		return nil, "", fmt.Errorf("%q is not listed in the Release file with prefix %q", rel, prefix)
+	}
+	if expected != dgst.Encoded() {
+		return nil, "", fmt.Errorf("sha256 mismatch for %q: expected %q (from the Release file with prefix %q), got %q", file, expected, prefix, dgst.Encoded())
+	}
+	return r, rel, nil
+}
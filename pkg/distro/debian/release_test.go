@@ -0,0 +1,73 @@
+package debian
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/crypto/openpgp"           //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"golang.org/x/crypto/openpgp/clearsign" //nolint:staticcheck // Ditto
+	"gotest.tools/v3/assert"
+)
+
+func writeInRelease(t testing.TB, file string, signer *openpgp.Entity, release string) {
+	var b bytes.Buffer
+	w, err := clearsign.Encode(&b, signer.PrivateKey, nil)
+	assert.NilError(t, err)
+	_, err = w.Write([]byte(release))
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	assert.NilError(t, os.WriteFile(file, b.Bytes(), 0644))
+}
+
+func TestReleaseVerifier(t *testing.T) {
+	signer, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	assert.NilError(t, err)
+	stranger, err := openpgp.NewEntity("stranger", "", "stranger@example.com", nil)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	const packages = `Package: hello
+Version: 2.10-2
+Architecture: amd64
+Filename: pool/main/h/hello/hello_2.10-2_amd64.deb
+SHA256: 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc
+`
+	packagesFile := filepath.Join(dir, "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages")
+	assert.NilError(t, os.WriteFile(packagesFile, []byte(packages), 0644))
+	release := fmt.Sprintf(`Origin: Debian
+Suite: stable
+Codename: bullseye
+SHA256:
+ %s %d main/binary-amd64/Packages
+`, digest.SHA256.FromString(packages).Encoded(), len(packages))
+	inReleaseFile := filepath.Join(dir, "deb.debian.org_debian_dists_bullseye_InRelease")
+	writeInRelease(t, inReleaseFile, signer, release)
+
+	rv := newReleaseVerifier(dir, openpgp.EntityList{signer}, false)
+	r, rel, err := rv.verify(packagesFile)
+	assert.NilError(t, err)
+	assert.Equal(t, "main/binary-amd64/Packages", rel)
+	assert.Equal(t, "bullseye", r.Values["Codename"])
+
+	rv = newReleaseVerifier(dir, openpgp.EntityList{stranger}, false)
+	_, _, err = rv.verify(packagesFile)
+	assert.ErrorContains(t, err, "failed to verify")
+
+	rv = newReleaseVerifier(dir, openpgp.EntityList{stranger}, true)
+	_, _, err = rv.verify(packagesFile)
+	assert.NilError(t, err)
+
+	assert.NilError(t, os.WriteFile(packagesFile, []byte(packages+"\nPackage: evil\n"), 0644))
+	rv = newReleaseVerifier(dir, openpgp.EntityList{signer}, false)
+	_, _, err = rv.verify(packagesFile)
+	assert.ErrorContains(t, err, "sha256 mismatch")
+
+	unsignedFile := filepath.Join(dir, "example.com_dists_foo_main_binary-amd64_Packages")
+	assert.NilError(t, os.WriteFile(unsignedFile, []byte(packages), 0644))
+	_, _, err = rv.verify(unsignedFile)
+	assert.ErrorContains(t, err, "no InRelease file was found")
+}
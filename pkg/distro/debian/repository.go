@@ -0,0 +1,207 @@
+package debian
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// GenerateRepositoryMetadata generates the unsigned "Packages", "Packages.gz", and "Release" files
+// of a flat repository for the cached *.deb files, and returns map[path]content.
+//
+// The repository has to be used with `deb [trusted=yes] http://<HOST>/ ./`.
+// The "Filename" fields are the names in the hash file, e.g., "pool/main/h/hello/hello_2.10-2_amd64.deb".
+func GenerateRepositoryMetadata(c *cache.Cache, fileSpecs map[string]*filespec.FileSpec) (map[string][]byte, error) {
+	var names []string
+	for name := range fileSpecs {
+		if strings.HasSuffix(name, ".deb") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+	var packages bytes.Buffer
+	for _, name := range names {
+		sp := fileSpecs[name]
+		if err := writePackagesParagraph(&packages, c, sp); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logrus.Warnf("%q (%s) is not cached", sp.Name, sp.Sum())
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %q: %w", sp.Name, err)
+		}
+	}
+	var packagesGz bytes.Buffer
+	gw := gzip.NewWriter(&packagesGz)
+	if _, err := gw.Write(packages.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	res := map[string][]byte{
+		"Packages":    packages.Bytes(),
+		"Packages.gz": packagesGz.Bytes(),
+	}
+	var release strings.Builder
+	fmt.Fprintf(&release, "Date: %s\n", time.Now().UTC().Format(time.RFC1123))
+	fmt.Fprintln(&release, "SHA256:")
+	for _, f := range []string{"Packages", "Packages.gz"} {
+		fmt.Fprintf(&release, " %s %d %s\n", digest.SHA256.FromBytes(res[f]).Encoded(), len(res[f]), f)
+	}
+	res["Release"] = []byte(release.String())
+	return res, nil
+}
+
+func writePackagesParagraph(w io.Writer, c *cache.Cache, sp *filespec.FileSpec) error {
+	blob, err := c.BlobAbsPath(sp.Sum())
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	ctrl, err := readControl(f)
+	if err != nil {
+		if sp.Dpkg == nil {
+			return err
+		}
+		// The dependencies are lost, but the package can be still installed by the name
+		logrus.WithError(err).Warnf("Failed to read the control file of %q, generating the minimal fields from the file name", sp.Name)
+		ver, err := url.PathUnescape(sp.Dpkg.Version) // "1%3a2.0-1" -> "1:2.0-1"
+		if err != nil {
+			return err
+		}
+		ctrl = fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\n", sp.Dpkg.Package, ver, sp.Dpkg.Architecture)
+	}
+	hashField := "SHA256: " + sp.SHA256
+	switch {
+	case sp.SHA256 != "":
+	case sp.SHA512 != "":
+		hashField = "SHA512: " + sp.SHA512
+	default:
+		// apt does not support BLAKE3
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		dgst, err := digest.SHA256.FromReader(f)
+		if err != nil {
+			return err
+		}
+		hashField = "SHA256: " + dgst.Encoded()
+	}
+	_, err = fmt.Fprintf(w, "%s\nFilename: %s\nSize: %d\n%s\n\n",
+		strings.TrimRight(ctrl, "\n"), sp.Name, st.Size(), hashField)
+	return err
+}
+
+// readControl reads the "control" file in the control.tar{,.gz,.xz,.zst} member of the *.deb ar archive.
+//
+// control.tar.xz is decompressed with the xz command.
+func readControl(r io.Reader) (string, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return "", err
+	}
+	if string(magic) != "!<arch>\n" {
+		return "", errors.New("not an ar archive")
+	}
+	hdr := make([]byte, 60)
+	for {
+		if _, err := io.ReadFull(br, hdr); err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", errors.New("no control.tar member was found")
+			}
+			return "", err
+		}
+		name := strings.TrimSuffix(strings.TrimSpace(string(hdr[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid ar header %q: %w", hdr, err)
+		}
+		member := io.LimitReader(br, size)
+		if strings.HasPrefix(name, "control.tar") {
+			return readControlTar(member, strings.TrimPrefix(name, "control.tar"))
+		}
+		// The members are aligned to 2 bytes
+		if _, err = io.CopyN(io.Discard, br, size+size%2); err != nil {
+			return "", err
+		}
+	}
+}
+
+func readControlTar(r io.Reader, ext string) (string, error) {
+	switch ext {
+	case "":
+	case ".gz":
+		gzR, err := gzip.NewReader(r)
+		if err != nil {
+			return "", err
+		}
+		defer gzR.Close()
+		r = gzR
+	case ".zst":
+		zR, err := zstd.NewReader(r)
+		if err != nil {
+			return "", err
+		}
+		defer zR.Close()
+		r = zR
+	case ".xz":
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		cmd := exec.Command("xz", "-dc")
+		cmd.Stdin = bytes.NewReader(b)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run %v (stderr=%q): %w", cmd.Args, stderr.String(), err)
+		}
+		r = bytes.NewReader(out)
+	default:
+		return "", fmt.Errorf("unsupported compression %q", ext)
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no control file was found")
+		}
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimPrefix(hdr.Name, "./") == "control" {
+			b, err := io.ReadAll(tr)
+			return string(b), err
+		}
+	}
+}
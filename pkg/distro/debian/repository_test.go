@@ -0,0 +1,88 @@
+package debian
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+// testDeb returns a *.deb ar archive with the control file.
+func testDeb(t testing.TB, ctrl string) []byte {
+	var tarBuf bytes.Buffer
+	gw := gzip.NewWriter(&tarBuf)
+	tw := tar.NewWriter(gw)
+	assert.NilError(t, tw.WriteHeader(&tar.Header{Name: "./control", Mode: 0644, Size: int64(len(ctrl))}))
+	_, err := tw.Write([]byte(ctrl))
+	assert.NilError(t, err)
+	assert.NilError(t, tw.Close())
+	assert.NilError(t, gw.Close())
+
+	var b bytes.Buffer
+	b.WriteString("!<arch>\n")
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", tarBuf.Bytes()},
+		{"data.tar.gz", []byte("dummy")},
+	} {
+		fmt.Fprintf(&b, "%-16s%-12s%-6s%-6s%-8s%-10d`\n", m.name, "0", "0", "0", "100644", len(m.data))
+		b.Write(m.data)
+		if len(m.data)%2 != 0 {
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func TestGenerateRepositoryMetadata(t *testing.T) {
+	const ctrl = `Package: hello
+Version: 2.10-2
+Architecture: amd64
+Depends: libc6 (>= 2.14)
+Description: example package based on GNU hello
+`
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	deb := testDeb(t, ctrl)
+	helloSHA256, err := c.ImportWithReader(bytes.NewReader(deb))
+	assert.NilError(t, err)
+	hello, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", helloSHA256)
+	assert.NilError(t, err)
+	// Not a valid deb, so the fields are generated from the file name
+	brokenSHA256, err := c.ImportWithReader(strings.NewReader("broken"))
+	assert.NilError(t, err)
+	broken, err := filespec.New("pool/main/b/broken/broken_1%3a1.0-1_all.deb", brokenSHA256)
+	assert.NilError(t, err)
+	fileSpecs := map[string]*filespec.FileSpec{hello.Name: hello, broken.Name: broken}
+
+	m, err := GenerateRepositoryMetadata(c, fileSpecs)
+	assert.NilError(t, err)
+	paragraphs, err := parsePackages(bytes.NewReader(m["Packages"]), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(paragraphs))
+	p := paragraphs[0]
+	assert.Equal(t, "broken", p.Values["Package"])
+	assert.Equal(t, "1:1.0-1", p.Values["Version"])
+	assert.Equal(t, "all", p.Values["Architecture"])
+	assert.Equal(t, broken.Name, p.Values["Filename"])
+	p = paragraphs[1]
+	assert.Equal(t, "hello", p.Values["Package"])
+	assert.Equal(t, "libc6 (>= 2.14)", p.Values["Depends"])
+	assert.Equal(t, hello.Name, p.Values["Filename"])
+	assert.Equal(t, fmt.Sprint(len(deb)), p.Values["Size"])
+	assert.Equal(t, helloSHA256, p.Values["SHA256"])
+
+	release, err := parsePackages(bytes.NewReader(m["Release"]), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(release))
+	assert.Assert(t, strings.Contains(release[0].Values["SHA256"], " Packages.gz"))
+}
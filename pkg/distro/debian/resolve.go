@@ -0,0 +1,171 @@
+package debian
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"pault.ag/go/debian/control"
+	"pault.ag/go/debian/dependency"
+	"pault.ag/go/debian/version"
+)
+
+// resolver resolves the dependency closure of packages from the candidate paragraphs.
+// Only "Pre-Depends" and "Depends" are followed, as in `apt-get install --no-install-recommends`.
+type resolver struct {
+	arch       *dependency.Arch // nil for ignoring architecture restrictions such as "[amd64]"
+	byName     map[string]control.Paragraph
+	byProvides map[string][]string // key: virtual package name, value: sorted real package names
+	selected   map[string]control.Paragraph
+}
+
+// newResolver creates a resolver. candidates should have at most one paragraph for each package name.
+// dpkgArch is used for evaluating architecture restrictions such as "[amd64]".
+func newResolver(candidates []control.Paragraph, dpkgArch string) *resolver {
+	r := &resolver{
+		byName:     make(map[string]control.Paragraph, len(candidates)),
+		byProvides: make(map[string][]string),
+		selected:   make(map[string]control.Paragraph),
+	}
+	for _, f := range candidates {
+		name := f.Values["Package"]
+		if _, ok := r.byName[name]; ok {
+			continue
+		}
+		r.byName[name] = f
+		if provides := f.Values["Provides"]; provides != "" {
+			dep, err := dependency.Parse(provides)
+			if err != nil {
+				logrus.WithError(err).Warnf("Failed to parse the Provides field of %q", name)
+				continue
+			}
+			for _, poss := range dep.GetAllPossibilities() {
+				r.byProvides[poss.Name] = append(r.byProvides[poss.Name], name)
+			}
+		}
+	}
+	for _, v := range r.byProvides {
+		sort.Strings(v)
+	}
+	if dpkgArch != "" {
+		arch, err := dependency.ParseArch(dpkgArch)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to parse architecture %q", dpkgArch)
+		}
+		r.arch = arch
+	}
+	return r
+}
+
+// resolve returns the dependency closure of the packages, sorted by the package name.
+func (r *resolver) resolve(names []string) ([]control.Paragraph, error) {
+	queue := make([]string, 0, len(names))
+	for _, name := range names {
+		name, _, _ = strings.Cut(name, ":")
+		found, ok := r.lookup(dependency.Possibility{Name: name})
+		if !ok {
+			return nil, fmt.Errorf("package %q was not found (Hint: try 'apt-get update')", name)
+		}
+		queue = append(queue, found)
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, ok := r.selected[name]; ok {
+			continue
+		}
+		f := r.byName[name]
+		r.selected[name] = f
+		for _, field := range []string{"Pre-Depends", "Depends"} {
+			v := f.Values[field]
+			if v == "" {
+				continue
+			}
+			dep, err := dependency.Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the %s field of %q: %w", field, name, err)
+			}
+			for _, rel := range dep.Relations {
+				found, err := r.resolveRelation(rel)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve the dependency of %q: %w", name, err)
+				}
+				if found != "" {
+					queue = append(queue, found)
+				}
+			}
+		}
+	}
+	selectedNames := make([]string, 0, len(r.selected))
+	for name := range r.selected {
+		selectedNames = append(selectedNames, name)
+	}
+	sort.Strings(selectedNames)
+	res := make([]control.Paragraph, len(selectedNames))
+	for i, name := range selectedNames {
+		res[i] = r.selected[name]
+	}
+	return res, nil
+}
+
+// resolveRelation returns the real package name that satisfies the relation such as "default-mta | mail-transport-agent".
+// A possibility that is already selected is preferred.
+// An empty string is returned when the relation is not applicable to the architecture.
+func (r *resolver) resolveRelation(rel dependency.Relation) (string, error) {
+	var (
+		applicable bool
+		candidates []string
+	)
+	for _, poss := range rel.Possibilities {
+		if r.arch != nil && poss.Architectures != nil && !poss.Architectures.Matches(r.arch) {
+			continue
+		}
+		applicable = true
+		if found, ok := r.lookup(poss); ok {
+			if _, selected := r.selected[found]; selected {
+				return found, nil
+			}
+			candidates = append(candidates, found)
+		}
+	}
+	if !applicable {
+		return "", nil
+	}
+	if len(candidates) == 0 {
+		var ss []string
+		for _, poss := range rel.Possibilities {
+			ss = append(ss, poss.String())
+		}
+		return "", fmt.Errorf("unsatisfiable dependency %q", strings.Join(ss, " | "))
+	}
+	return candidates[0], nil
+}
+
+// lookup returns the real package name that satisfies the possibility.
+func (r *resolver) lookup(poss dependency.Possibility) (string, bool) {
+	if f, ok := r.byName[poss.Name]; ok {
+		if poss.Version == nil {
+			return poss.Name, true
+		}
+		ver, err := version.Parse(f.Values["Version"])
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to parse version %q", f.Values["Version"])
+		} else if poss.Version.SatisfiedBy(ver) {
+			return poss.Name, true
+		}
+	}
+	if poss.Version != nil {
+		// Versioned Provides are not supported
+		return "", false
+	}
+	for _, found := range r.byProvides[poss.Name] {
+		if _, selected := r.selected[found]; selected {
+			return found, true
+		}
+	}
+	if founds := r.byProvides[poss.Name]; len(founds) > 0 {
+		return founds[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,72 @@
+package debian
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestResolver(t *testing.T) {
+	const s = `Package: hello
+Version: 2.10-2
+Architecture: amd64
+Depends: libc6 (>= 2.14)
+
+Package: libc6
+Version: 2.31-13+deb11u5
+Architecture: amd64
+Depends: libgcc-s1, libcrypt1 (>= 1:4.4.10-10~)
+
+Package: libgcc-s1
+Version: 10.2.1-6
+Architecture: amd64
+Depends: gcc-10-base (= 10.2.1-6), libc6 (>= 2.14)
+
+Package: gcc-10-base
+Version: 10.2.1-6
+Architecture: amd64
+
+Package: libcrypt1
+Version: 1:4.4.18-4
+Architecture: amd64
+Depends: libc6 (>= 2.25)
+
+Package: postfix
+Version: 3.5.17-0+deb11u1
+Architecture: amd64
+Provides: mail-transport-agent
+Depends: libc6, hurd-only [hurd-i386]
+
+Package: bsd-mailx
+Version: 8.1.2-0.20180807cvs-2
+Architecture: amd64
+Depends: default-mta | mail-transport-agent
+`
+	paragraphs, err := parsePackages(strings.NewReader(s), nil)
+	assert.NilError(t, err)
+
+	resolved, err := newResolver(paragraphs, "amd64").resolve([]string{"hello"})
+	assert.NilError(t, err)
+	var got []string
+	for _, f := range resolved {
+		got = append(got, f.Values["Package"])
+	}
+	assert.DeepEqual(t, []string{"gcc-10-base", "hello", "libc6", "libcrypt1", "libgcc-s1"}, got)
+
+	resolved, err = newResolver(paragraphs, "amd64").resolve([]string{"bsd-mailx:amd64"})
+	assert.NilError(t, err)
+	got = nil
+	for _, f := range resolved {
+		got = append(got, f.Values["Package"])
+	}
+	assert.DeepEqual(t, []string{"bsd-mailx", "gcc-10-base", "libc6", "libcrypt1", "libgcc-s1", "postfix"}, got)
+
+	_, err = newResolver(paragraphs, "amd64").resolve([]string{"nonexistent"})
+	assert.ErrorContains(t, err, "not found")
+
+	unsatisfiable, err := parsePackages(strings.NewReader("Package: foo\nVersion: 1\nArchitecture: amd64\nDepends: libc6 (>= 99)\n\n"+s), nil)
+	assert.NilError(t, err)
+	_, err = newResolver(unsatisfiable, "amd64").resolve([]string{"foo"})
+	assert.ErrorContains(t, err, "unsatisfiable dependency")
+}
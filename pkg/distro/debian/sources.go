@@ -0,0 +1,106 @@
+package debian
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/sirupsen/logrus"
+	"pault.ag/go/debian/control"
+)
+
+// SourcePackage is a source package name with the version.
+type SourcePackage struct {
+	Package string // "glibc"
+	Version string // "2.31-13+deb11u5"
+}
+
+// sourcesOf returns the sorted list of the source packages of the newest binary paragraphs.
+// The "Source" field is like "glibc", or "glibc (2.31-13+deb11u5)" when the version differs from the binary.
+func sourcesOf(binaries []control.Paragraph) []SourcePackage {
+	m := make(map[SourcePackage]struct{})
+	for _, f := range newestParagraphs(binaries) {
+		src := SourcePackage{
+			Package: f.Values["Package"],
+			Version: f.Values["Version"],
+		}
+		if v := strings.TrimSpace(f.Values["Source"]); v != "" {
+			fields := strings.Fields(v)
+			src.Package = fields[0]
+			if len(fields) > 1 {
+				src.Version = strings.Trim(fields[1], "()")
+			}
+		}
+		m[src] = struct{}{}
+	}
+	res := make([]SourcePackage, 0, len(m))
+	for src := range m {
+		res = append(res, src)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Package != res[j].Package {
+			return res[i].Package < res[j].Package
+		}
+		return res[i].Version < res[j].Version
+	})
+	return res
+}
+
+// readSourcesLists reads "*_Sources" files in the lists dir.
+// The files are verified with rv, unless rv is nil.
+func readSourcesLists(dir string, filter map[string]struct{}, rv *releaseVerifier) ([]control.Paragraph, error) {
+	res, err := readLists(dir, "_Sources", filter, rv, nil)
+	if errors.Is(err, errNoListsFile) {
+		return nil, fmt.Errorf("no Sources file was found in %q (Hint: enable deb-src and try 'apt-get update')", dir)
+	}
+	return res, err
+}
+
+// generateSourceHash writes the hashes of the files (*.dsc, *.orig.tar.*, *.debian.tar.*, ...) of the source packages.
+func generateSourceHash(hw distro.HashWriter, srcs []SourcePackage, paragraphs []control.Paragraph) error {
+	byPkgVer := make(map[SourcePackage]control.Paragraph, len(paragraphs))
+	for _, f := range paragraphs {
+		k := SourcePackage{
+			Package: f.Values["Package"],
+			Version: f.Values["Version"],
+		}
+		byPkgVer[k] = f
+	}
+	written := make(map[string]struct{})
+	for _, src := range srcs {
+		f, ok := byPkgVer[src]
+		if !ok {
+			logrus.Warnf("No source package found for %s %s (Hint: enable deb-src and try 'apt-get update')", src.Package, src.Version)
+			continue
+		}
+		dir := f.Values["Directory"]
+		if dir == "" {
+			logrus.Warnf("No Directory found for source package %q", src.Package)
+			continue
+		}
+		checksums := strings.TrimSpace(f.Values["Checksums-Sha256"])
+		if checksums == "" {
+			logrus.Warnf("No Checksums-Sha256 found for source package %q", src.Package)
+			continue
+		}
+		// Each line is like "<SHA256> <SIZE> <BASENAME>"
+		for _, line := range strings.Split(checksums, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				return fmt.Errorf("unexpected Checksums-Sha256 line %q for source package %q", line, src.Package)
+			}
+			filename := path.Join(dir, fields[2])
+			if _, ok := written[filename]; ok {
+				continue
+			}
+			written[filename] = struct{}{}
+			if err := hw(fields[0], filename); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
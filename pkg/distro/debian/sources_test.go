@@ -0,0 +1,81 @@
+package debian
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"gotest.tools/v3/assert"
+)
+
+func TestGenerateSourceHash(t *testing.T) {
+	const binaries = `Package: hello
+Version: 2.10-2
+Architecture: amd64
+Filename: pool/main/h/hello/hello_2.10-2_amd64.deb
+SHA256: 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc
+
+Package: libc6
+Source: glibc (2.31-13+deb11u5)
+Version: 2.31-13+deb11u5
+Architecture: amd64
+Filename: pool/main/g/glibc/libc6_2.31-13+deb11u5_amd64.deb
+SHA256: 0000000000000000000000000000000000000000000000000000000000000000
+
+Package: libc-bin
+Source: glibc
+Version: 2.31-13+deb11u5
+Architecture: amd64
+Filename: pool/main/g/glibc/libc-bin_2.31-13+deb11u5_amd64.deb
+SHA256: 0000000000000000000000000000000000000000000000000000000000000000
+`
+	// sources is from /var/lib/apt/lists/deb.debian.org_debian_dists_bullseye_main_source_Sources on Debian 11 (excerpt)
+	const sources = `Package: hello
+Binary: hello
+Version: 2.10-2
+Maintainer: Santiago Vila <sanvila@debian.org>
+Architecture: any
+Format: 3.0 (quilt)
+Files:
+ e2d8d9ba5ec7a2d0b42df1e9e9dc2a87 1300 hello_2.10-2.dsc
+ 6cd0ffea3884a4e79330338dcc2987d6 725946 hello_2.10.orig.tar.gz
+ b0e2b1f1e048320f9e661dd0b2e0ba16 6132 hello_2.10-2.debian.tar.xz
+Checksums-Sha256:
+ a3938d9b8a09c6ffcd251019ec570a0931a352303e1cce0ba7e3955d358d51c9 1300 hello_2.10-2.dsc
+ 31e066137a962676e89f69d1b65382de95a7ef7d914b8cb956f41ea72e0f516b 725946 hello_2.10.orig.tar.gz
+ 3d2e0a2d0c1fabbbf5b7b6d1427a2f0e791b1a2e4ae2e5b2ea1d4d2847e8bb16 6132 hello_2.10-2.debian.tar.xz
+Directory: pool/main/h/hello
+
+Package: glibc
+Binary: libc-bin, libc6
+Version: 2.31-13+deb11u5
+Format: 3.0 (quilt)
+Checksums-Sha256:
+ 1111111111111111111111111111111111111111111111111111111111111111 7100 glibc_2.31-13+deb11u5.dsc
+ 2222222222222222222222222222222222222222222222222222222222222222 17254692 glibc_2.31.orig.tar.xz
+Directory: pool/main/g/glibc
+`
+	binParagraphs, err := parsePackages(strings.NewReader(binaries), nil)
+	assert.NilError(t, err)
+	srcs := sourcesOf(binParagraphs)
+	assert.DeepEqual(t, []SourcePackage{
+		{Package: "glibc", Version: "2.31-13+deb11u5"},
+		{Package: "hello", Version: "2.10-2"},
+	}, srcs)
+
+	srcParagraphs, err := parsePackages(strings.NewReader(sources), nil)
+	assert.NilError(t, err)
+
+	var b bytes.Buffer
+	hw := distro.NewHashWriter(&b)
+	assert.NilError(t, generateSourceHash(hw, srcs, srcParagraphs))
+
+	const expected = `1111111111111111111111111111111111111111111111111111111111111111  pool/main/g/glibc/glibc_2.31-13+deb11u5.dsc
+2222222222222222222222222222222222222222222222222222222222222222  pool/main/g/glibc/glibc_2.31.orig.tar.xz
+a3938d9b8a09c6ffcd251019ec570a0931a352303e1cce0ba7e3955d358d51c9  pool/main/h/hello/hello_2.10-2.dsc
+31e066137a962676e89f69d1b65382de95a7ef7d914b8cb956f41ea72e0f516b  pool/main/h/hello/hello_2.10.orig.tar.gz
+3d2e0a2d0c1fabbbf5b7b6d1427a2f0e791b1a2e4ae2e5b2ea1d4d2847e8bb16  pool/main/h/hello/hello_2.10-2.debian.tar.xz
+`
+	assert.Equal(t, expected, b.String())
+}
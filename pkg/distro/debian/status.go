@@ -0,0 +1,103 @@
+package debian
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// StatusFile is the dpkg database of the installed packages.
+	StatusFile = "/var/lib/dpkg/status"
+	// StatusDir is used instead of StatusFile in distroless images.
+	StatusDir = "/var/lib/dpkg/status.d"
+)
+
+// Installed returns the package map.
+// The map key is Package + ":" + Architecture (if Architecture != "").
+//
+// root is the root filesystem to inspect. An empty string is treated as "/".
+func Installed(root string) (map[string]dpkgutil.Dpkg, error) {
+	if root == "" {
+		root = "/"
+	}
+	pkgs := make(map[string]dpkgutil.Dpkg)
+	statusFile, err := securejoin.SecureJoin(root, StatusFile)
+	if err != nil {
+		return nil, err
+	}
+	if err = installedFromFile(pkgs, statusFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return pkgs, err
+	}
+	statusDir, err := securejoin.SecureJoin(root, StatusDir)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := os.ReadDir(statusDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return pkgs, err
+	}
+	for _, ent := range ents {
+		if ent.IsDir() || strings.HasSuffix(ent.Name(), ".md5sums") {
+			continue
+		}
+		if err = installedFromFile(pkgs, filepath.Join(statusDir, ent.Name())); err != nil {
+			return pkgs, err
+		}
+	}
+	return pkgs, nil
+}
+
+func installedFromFile(pkgs map[string]dpkgutil.Dpkg, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	logrus.Debugf("Reading %q", file)
+	if err = installed(pkgs, f); err != nil {
+		return fmt.Errorf("failed to parse %q: %w", file, err)
+	}
+	return nil
+}
+
+// installed parses the dpkg status file and adds the installed packages to pkgs.
+func installed(pkgs map[string]dpkgutil.Dpkg, r io.Reader) error {
+	paragraphs, err := parsePackages(r, nil)
+	if err != nil {
+		return err
+	}
+	for _, p := range paragraphs {
+		// The status files in distroless images lack the "Status" field
+		if status := p.Values["Status"]; status != "" && !isInstalledStatus(status) {
+			continue
+		}
+		pkg := dpkgutil.Dpkg{
+			Package:      p.Values["Package"],
+			Version:      p.Values["Version"],
+			Architecture: p.Values["Architecture"],
+		}
+		if pkg.Package == "" {
+			continue
+		}
+		k := pkg.Package
+		if pkg.Architecture != "" {
+			k += ":" + pkg.Architecture
+		}
+		pkgs[k] = pkg
+	}
+	return nil
+}
+
+// isInstalledStatus returns true for the status like "install ok installed".
+func isInstalledStatus(status string) bool {
+	fields := strings.Fields(status)
+	return len(fields) == 3 && fields[2] == "installed"
+}
@@ -0,0 +1,73 @@
+package debian
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"pault.ag/go/debian/control"
+)
+
+// originURL returns the URL of the file in the repository of the lists file, such as
+// "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb" for
+// "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages" and "pool/main/h/hello/hello_2.10-2_amd64.deb".
+//
+// The scheme is assumed to be "http", as it is not recorded in the name of the lists file.
+// Returns false for the flat repositories, which do not have the "dists" directory.
+func originURL(listsFile, filename string) (string, bool) {
+	repo, _, ok := strings.Cut(listsFile, "_dists_")
+	if !ok || repo == "" || filename == "" {
+		return "", false
+	}
+	// apt escapes "_" in the URL as "%5f"
+	repo, err := url.PathUnescape(strings.ReplaceAll(repo, "_", "/"))
+	if err != nil {
+		return "", false
+	}
+	return "http://" + repo + "/" + filename, true
+}
+
+// binaryURLs returns the map of the file names to the origin URLs of the binary packages.
+func binaryURLs(paragraphs []control.Paragraph) map[string]string {
+	res := make(map[string]string, len(paragraphs))
+	for _, f := range paragraphs {
+		filename := f.Values["Filename"]
+		if u, ok := originURL(f.Values[listsFileField], filename); ok {
+			res[filename] = u
+		}
+	}
+	return res
+}
+
+// sourceURLs returns the map of the file names to the origin URLs of the files of the source packages.
+func sourceURLs(paragraphs []control.Paragraph) map[string]string {
+	res := make(map[string]string)
+	for _, f := range paragraphs {
+		dir := f.Values["Directory"]
+		for _, line := range strings.Split(strings.TrimSpace(f.Values["Checksums-Sha256"]), "\n") {
+			fields := strings.Fields(line)
+			if dir == "" || len(fields) != 3 {
+				continue
+			}
+			filename := path.Join(dir, fields[2])
+			if u, ok := originURL(f.Values[listsFileField], filename); ok {
+				res[filename] = u
+			}
+		}
+	}
+	return res
+}
+
+// withURLWriter returns a HashWriter that writes the origin URL of the file with uw, before writing the hash with hw.
+// The files that are missing in urls are written without the origin URLs.
+func withURLWriter(hw distro.HashWriter, uw distro.URLWriter, urls map[string]string) distro.HashWriter {
+	return func(sha256sum, filename string) error {
+		if u, ok := urls[filename]; ok {
+			if err := uw(u, filename); err != nil {
+				return err
+			}
+		}
+		return hw(sha256sum, filename)
+	}
+}
@@ -0,0 +1,68 @@
+package debian
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"gotest.tools/v3/assert"
+	"pault.ag/go/debian/control"
+)
+
+func TestOriginURL(t *testing.T) {
+	testCases := []struct {
+		listsFile string
+		filename  string
+		expected  string
+	}{
+		{
+			listsFile: "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages",
+			filename:  "pool/main/h/hello/hello_2.10-2_amd64.deb",
+			expected:  "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb",
+		},
+		{
+			listsFile: "ppa.launchpadcontent.net_deadsnakes_ppa_ubuntu_dists_jammy_main_binary-amd64_Packages",
+			filename:  "pool/main/p/python3.12/python3.12_3.12.0-1+jammy1_amd64.deb",
+			expected:  "http://ppa.launchpadcontent.net/deadsnakes/ppa/ubuntu/pool/main/p/python3.12/python3.12_3.12.0-1+jammy1_amd64.deb",
+		},
+		{
+			listsFile: "example.com_foo%5fbar_dists_stable_main_source_Sources",
+			filename:  "pool/main/h/hello/hello_2.10-2.dsc",
+			expected:  "http://example.com/foo_bar/pool/main/h/hello/hello_2.10-2.dsc",
+		},
+		{
+			// flat repository
+			listsFile: "example.com_flat_Packages",
+			filename:  "hello_2.10-2_amd64.deb",
+		},
+	}
+	for _, tc := range testCases {
+		u, ok := originURL(tc.listsFile, tc.filename)
+		assert.Equal(t, tc.expected != "", ok, tc.listsFile)
+		assert.Equal(t, tc.expected, u)
+	}
+}
+
+func TestWithURLWriter(t *testing.T) {
+	paragraphs := []control.Paragraph{
+		{Values: map[string]string{"Package": "hello", "Filename": "pool/main/h/hello/hello_2.10-2_amd64.deb",
+			listsFileField: "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages"}},
+		{Values: map[string]string{"Package": "foo", "Filename": "foo_1.0_amd64.deb",
+			listsFileField: "example.com_flat_Packages"}},
+	}
+	var b bytes.Buffer
+	uw := func(rawURL, filename string) error {
+		_, err := fmt.Fprintf(&b, "url %s %s\n", rawURL, filename)
+		return err
+	}
+	hw := withURLWriter(distro.NewHashWriter(&b), uw, binaryURLs(paragraphs))
+	assert.NilError(t, hw("35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", "pool/main/h/hello/hello_2.10-2_amd64.deb"))
+	assert.NilError(t, hw("0000000000000000000000000000000000000000000000000000000000000000", "foo_1.0_amd64.deb"))
+
+	const expected = `url http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb pool/main/h/hello/hello_2.10-2_amd64.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+0000000000000000000000000000000000000000000000000000000000000000  foo_1.0_amd64.deb
+`
+	assert.Equal(t, expected, b.String())
+}
@@ -9,9 +9,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"github.com/sirupsen/logrus"
 )
 
@@ -36,6 +38,116 @@ type Distro interface {
 	GenerateDockerfile(ctx context.Context, dir string, args DockerfileTemplateArgs, opts DockerfileOpts) error
 }
 
+// InstalledLister is implemented by the distro drivers that can list the installed packages.
+type InstalledLister interface {
+	// InstalledPackages returns the installed packages in the root filesystem. An empty root is treated as "/".
+	InstalledPackages(ctx context.Context, root string) ([]InstalledPackage, error)
+}
+
+// InstallCommander is implemented by the distro drivers that install the packages with an external command, such as dpkg and apk.
+type InstallCommander interface {
+	// InstallCommand returns the command line that InstallPackages would execute, without executing it.
+	// The packages do not need to be cached.
+	// Returns nil when InstallPackages would not execute any command for the packages and the options.
+	InstallCommand(c *cache.Cache, pkgs []filespec.FileSpec, opts InstallOpts) ([]string, error)
+}
+
+// Uninstaller is implemented by the distro drivers that can uninstall the packages.
+type Uninstaller interface {
+	// UninstallPackages uninstalls the packages. The versions of the packages are ignored.
+	UninstallPackages(ctx context.Context, pkgs []InstalledPackage, opts InstallOpts) error
+}
+
+// Bootstrapper is implemented by the distro drivers that can create a root filesystem from the packages,
+// without using the package manager of the host.
+type Bootstrapper interface {
+	// Bootstrap extracts the packages into the new root filesystem opts.Root.
+	Bootstrap(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts BootstrapOpts) error
+}
+
+// PackageTimer is implemented by the distro drivers that can read the build times of the packages.
+type PackageTimer interface {
+	// PackageTime returns the build time of the cached package.
+	// Returns the zero time for the files that are not packages, such as the source packages.
+	PackageTime(c *cache.Cache, pkg filespec.FileSpec) (time.Time, error)
+}
+
+// KeyExtractor is implemented by the distro drivers that can extract the trusted keys from the keyring packages,
+// such as "debian-archive-keyring" and "alpine-keys".
+type KeyExtractor interface {
+	// ExtractKeys returns the contents of the key files in the package file, keyed by the base names.
+	ExtractKeys(ctx context.Context, file string) (map[string][]byte, error)
+}
+
+// PackageVerifier is implemented by the distro drivers that can verify the signatures embedded in the packages,
+// such as the debsig signatures of the *.deb files.
+type PackageVerifier interface {
+	// VerifyPackages verifies the signatures of the cached packages, in addition to the hashes.
+	// The files that are not packages, such as the source packages, are skipped.
+	VerifyPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts VerifyPackagesOpts) ([]PackageSignature, error)
+}
+
+type VerifyPackagesOpts struct {
+	PoliciesDir string   // Directory of the debsig policies (Debian and Ubuntu only). Defaults to the default of debsig-verify(1), "/etc/debsig/policies".
+	KeyringsDir string   // Directory of the debsig keyrings (Debian and Ubuntu only). Defaults to the default of debsig-verify(1), "/usr/share/debsig/keyrings".
+	Keyrings    []string // OpenPGP keyrings (Fedora only). Defaults to "/etc/pki/rpm-gpg/RPM-GPG-KEY-*".
+}
+
+// PackageSignature is the verified signature of a package.
+type PackageSignature struct {
+	Name        string `json:"Name"`                  // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	KeyID       string `json:"KeyID,omitempty"`       // The ID of the signing key, such as "6ED0E7B82643E131", when known
+	Fingerprint string `json:"Fingerprint,omitempty"` // The fingerprint of the signing key, when known
+}
+
+// BuildinfoFetcher is implemented by the distro drivers that can fetch the build information of the packages,
+// such as the *.buildinfo files of the *.deb files on buildinfos.debian.net.
+type BuildinfoFetcher interface {
+	// FetchBuildinfos fetches the build information files of the packages into the cache,
+	// and verifies that they record the hashes of the packages.
+	// The packages do not need to be cached. The files that are not binary packages are skipped.
+	FetchBuildinfos(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts FetchBuildinfosOpts) ([]Buildinfo, error)
+}
+
+type FetchBuildinfosOpts struct {
+	Providers []string // URL templates of the build information files. Defaults to the default of the distro.
+	Keyrings  []string // OpenPGP keyrings for verifying the signatures of the build information files. The signatures are not verified when empty.
+}
+
+// Buildinfo is the verified build information file of a package.
+type Buildinfo struct {
+	Name      string `json:"Name"`             // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Buildinfo string `json:"Buildinfo"`        // "hello_2.10-2_amd64.buildinfo"
+	SHA256    string `json:"SHA256"`           // The SHA256 of the build information file, for the cache
+	URL       string `json:"URL"`              // "https://buildinfos.debian.net/buildinfo-pool/h/hello/hello_2.10-2_amd64.buildinfo"
+	Signer    string `json:"Signer,omitempty"` // The fingerprint of the signing key, when verified
+}
+
+// BootstrapScripts specifies how the maintainer scripts are handled on bootstrapping.
+type BootstrapScripts string
+
+const (
+	BootstrapScriptsSkip   BootstrapScripts = "skip"   // Do not execute the maintainer scripts
+	BootstrapScriptsChroot BootstrapScripts = "chroot" // Execute the maintainer scripts in the root filesystem with chroot(8)
+)
+
+type BootstrapOpts struct {
+	Root    string           // Root filesystem to be created. Must not exist, or must be empty.
+	Scripts BootstrapScripts // Defaults to BootstrapScriptsSkip
+	// MergedUsr creates the symlinks such as "/bin" -> "usr/bin" before extracting the packages (Debian and Ubuntu only).
+	MergedUsr bool
+	// SourceDateEpoch is the modification time of the files that are not extracted from the packages, such as the package database.
+	// Defaults to the latest modification time of the files extracted from the packages.
+	SourceDateEpoch *time.Time
+}
+
+// InstalledPackage is an installed package.
+type InstalledPackage struct {
+	Package      string `json:"Package"`                // "hello"
+	Version      string `json:"Version"`                // "2.10-2"
+	Architecture string `json:"Architecture,omitempty"` // "amd64"
+}
+
 type Info struct {
 	Name                           string   `json:"Name"` // "debian", "ubuntu", ...
 	DefaultProviders               []string `json:"DefaultProviders"`
@@ -44,15 +156,42 @@ type Info struct {
 }
 
 type HashOpts struct {
-	FilterByName []string     // No filter when empty
-	Cache        *cache.Cache // Used only if Info.CacheIsNeededForGeneratingHash is true
+	FilterByName  []string     // No filter when empty
+	Cache         *cache.Cache // Used only if Info.CacheIsNeededForGeneratingHash is true
+	Lockfile      string       // Used only by the drivers that read lockfiles, such as "package-lock.json"
+	Root          string       // Root filesystem to inspect, used only by the drivers that read the package database. Defaults to "/".
+	Source        bool         // Generate the hashes of the source packages, used only by the drivers that support source packages
+	Arch          string       // OCI architecture with variant, such as "arm64" and "arm-v7". Defaults to the host architecture.
+	Keyrings      []string     // OpenPGP keyrings for verifying the repository metadata (or the packages for Fedora), or the RSA public keys ("*.rsa.pub") for Alpine. Defaults to the keyrings of the distro (none for Alpine and Fedora).
+	ExtraKeyrings []string     // Additional keys on the host, appended to Keyrings, such as the keys managed by `repro-get key`. The files that are not applicable to the distro are ignored.
+	AllowUnsigned bool         // Allow unsigned (or unverifiable) repository metadata
+	Resolve       bool         // Resolve the dependencies of FilterByName from the repository metadata, regardless of the installed packages
+	TargetRelease string       // Target release for the pinning, such as "bullseye-backports". Defaults to the configuration of the distro.
+	Preferences   []string     // Pinning preferences files. Defaults to the preferences files of the distro.
+	PPA           string       // Launchpad PPA such as "deadsnakes/ppa". Only the packages from the PPA are used when specified.
+	World         bool         // Generate the hashes of the packages in the world file (such as /etc/apk/world) and their dependencies, instead of the installed packages
+	Indexes       []string     // Repository index files or URLs (such as APKINDEX.tar.gz) to resolve FilterByName from, instead of the local repository metadata
+	IndexWriter   HashWriter   // Records the hashes of the repository indexes (such as Packages and APKINDEX) that were used, unless nil
+	SHA512        bool         // Generate SHA512 instead of SHA256, from the repository metadata that contains SHA512 (Debian, Ubuntu, and Maven only)
+	URLWriter     URLWriter    // Records the origin URLs of the files, before writing their hashes, unless nil. Not all the drivers support this.
+	SignerWriter  SignerWriter // Records the fingerprints of the keys that signed the files, before writing their hashes, unless nil. Fedora only.
 }
 
+// URLWriter writes the origin URL of a file, such as "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb".
+type URLWriter func(rawURL, filename string) error
+
+// SignerWriter writes the fingerprint of the OpenPGP key that signed a file, such as "115DF9AEF857853EE8445D0A0727707EA15B79CC".
+type SignerWriter func(fingerprint, filename string) error
+
+// HashWriter writes a hash.
+// sha256sum may be a SHA512 sum prefixed with "sha512:" (see sha256sums.ParseSum).
 type HashWriter func(sha256sum, filename string) error
 
+// NewHashWriter returns a HashWriter that writes the "<SUM>  <FILENAME>" lines.
+// The SHA512 sums are written without the "sha512:" prefix, as in the SHA512SUMS files.
 func NewHashWriter(w io.Writer) HashWriter {
 	return func(sha256sum, filename string) error {
-		_, err := fmt.Fprintln(w, sha256sum+"  "+filename)
+		_, err := fmt.Fprintln(w, strings.TrimPrefix(sha256sum, sha256sums.SHA512Prefix)+"  "+filename)
 		return err
 	}
 }
@@ -63,6 +202,7 @@ type DockerfileTemplateArgs struct {
 	Packages           []string
 	OCIArchDashVariant string
 	Providers          []string
+	SourceDateEpoch    string // Seconds since the epoch, or empty for computing it during the build
 }
 
 var DockerfileTemplateFuncMap = template.FuncMap{
@@ -87,5 +227,6 @@ type DockerfileOpts struct {
 }
 
 type InstallOpts struct {
-	// Reserved
+	Root      string // Root filesystem to install the packages into, used only by the drivers that support it. Defaults to "/".
+	Downgrade bool   // Allow replacing the installed packages with older versions, for the drivers that refuse it by default
 }
@@ -3,6 +3,7 @@ package fedora
 import (
 	"bufio"
 	"context"
+	_ "embed"
 	"errors"
 	"fmt"
 	"io"
@@ -10,9 +11,11 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
@@ -52,9 +55,37 @@ func (d *fedora) Info() distro.Info {
 }
 
 func (d *fedora) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
+	if opts.Source {
+		return fmt.Errorf("%w: source packages", ErrNotImplemented)
+	}
+	if opts.Arch != "" && opts.Arch != archutil.OCIArchDashVariant() {
+		return fmt.Errorf("%w: foreign architecture %q", ErrNotImplemented, opts.Arch)
+	}
+	if opts.Resolve {
+		return fmt.Errorf("%w: resolving dependencies", ErrNotImplemented)
+	}
+	if opts.TargetRelease != "" || len(opts.Preferences) > 0 {
+		return fmt.Errorf("%w: pinning", ErrNotImplemented)
+	}
+	if opts.PPA != "" {
+		return fmt.Errorf("%w: PPA", ErrNotImplemented)
+	}
+	if opts.World {
+		return fmt.Errorf("%w: world file", ErrNotImplemented)
+	}
+	if len(opts.Indexes) > 0 || opts.IndexWriter != nil {
+		return fmt.Errorf("%w: repository indexes", ErrNotImplemented)
+	}
 	if opts.Cache == nil {
 		return errors.New("cache is required")
 	}
+	rv, err := newRPMVerifier(opts)
+	if err != nil {
+		return err
+	}
 	names := opts.FilterByName
 	if len(names) == 0 {
 		rpms, err := Installed()
@@ -80,13 +111,13 @@ func (d *fedora) GenerateHash(ctx context.Context, hw distro.HashWriter, opts di
 	if err = cmd.Start(); err != nil {
 		return fmt.Errorf("failed to execute %v: %w", cmd.Args, err)
 	}
-	return d.generateHash(ctx, hw, opts.Cache, r)
+	return d.generateHash(ctx, hw, opts.SignerWriter, opts.Cache, rv, r)
 }
 
-func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, c *cache.Cache, r io.Reader) error {
+func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, sw distro.SignerWriter, c *cache.Cache, rv *rpmVerifier, r io.Reader) error {
 	const expectedFields = 2
 	sc := bufio.NewScanner(r)
-	urlOpener := urlopener.New()
+	urlOpener := c.URLOpener()
 	for sc.Scan() {
 		line := sc.Text()
 		trimmed := strings.TrimSpace(line)
@@ -112,7 +143,7 @@ func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, c *cach
 			continue
 		}
 		fname := fmt.Sprintf("%s/%s/%s/%s/%s", srpm.Package, srpm.Version, srpm.Release, rpm.Architecture, rpmName)
-		if err := d.generateHash1(ctx, hw, c, urlOpener, fname); err != nil {
+		if err := d.generateHash1(ctx, hw, sw, c, rv, urlOpener, fname); err != nil {
 			return err
 		}
 	}
@@ -122,7 +153,7 @@ func (d *fedora) generateHash(ctx context.Context, hw distro.HashWriter, c *cach
 	return nil
 }
 
-func (d *fedora) generateHash1(ctx context.Context, hw distro.HashWriter, c *cache.Cache, urlOpener *urlopener.URLOpener, fname string) error {
+func (d *fedora) generateHash1(ctx context.Context, hw distro.HashWriter, sw distro.SignerWriter, c *cache.Cache, rv *rpmVerifier, urlOpener *urlopener.URLOpener, fname string) error {
 	rawURL := kojiPackages + fname
 	u, err := url.Parse(rawURL)
 	if err != nil {
@@ -130,17 +161,26 @@ func (d *fedora) generateHash1(ctx context.Context, hw distro.HashWriter, c *cac
 	}
 	logrus.Debugf("Generating the hash for %q", u.Redacted())
 	basename := path.Base(fname)
-	if sha256sum, err := c.SHA256ByOriginURL(u); err == nil {
+	sha256sum, err := c.SHA256ByOriginURL(u)
+	if err == nil {
 		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, u.Redacted())
-		return hw(sha256sum, fname)
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+	} else {
+		logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
+		if sha256sum, err = c.ImportWithURL(u); err != nil {
+			return err
+		}
 	}
-	logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
-	sha256sum, err := c.ImportWithURL(u)
+	fpr, err := rv.verify(c, sha256sum, fname)
 	if err != nil {
 		return err
 	}
+	if fpr != "" && sw != nil {
+		if err = sw(fpr, fname); err != nil {
+			return err
+		}
+	}
 	return hw(sha256sum, fname)
 }
 
@@ -173,6 +213,21 @@ func (d *fedora) IsPackageVersionInstalled(ctx context.Context, sp filespec.File
 	return inst.Version+"."+inst.Release == sp.RPM.Version+"."+sp.RPM.Release, nil
 }
 
+func (d *fedora) InstalledPackages(ctx context.Context, root string) ([]distro.InstalledPackage, error) {
+	if root != "" && root != "/" {
+		return nil, fmt.Errorf("inspecting a custom root %q is not supported for Fedora", root)
+	}
+	rpms, err := Installed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect installed rpms: %w", err)
+	}
+	res := make([]distro.InstalledPackage, 0, len(rpms))
+	for _, rpm := range rpms {
+		res = append(res, distro.InstalledPackage{Package: rpm.Package, Version: rpm.Version + "-" + rpm.Release, Architecture: rpm.Architecture})
+	}
+	return res, nil
+}
+
 // Installed returns the package map.
 // The map key is Package + ":" + Architecture (if Architecture != "").
 func Installed() (map[string]rpmutil.RPM, error) {
@@ -209,18 +264,45 @@ func installed(r io.Reader) (map[string]rpmutil.RPM, error) {
 	return pkgs, sc.Err()
 }
 
+func (d *fedora) InstallCommand(c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) ([]string, error) {
+	if opts.Root != "" && opts.Root != "/" {
+		return nil, fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+	args := []string{"rpm", "-Uvh"}
+	if opts.Downgrade {
+		args = append(args, "--oldpackage")
+	}
+	for _, pkg := range pkgs {
+		blob, err := c.LookupBlobAbsPath(pkg.Sum())
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, blob)
+	}
+	return args, nil
+}
+
 func (d *fedora) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) error {
 	if len(pkgs) == 0 {
 		return nil
 	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
 	cmdName, err := exec.LookPath("rpm")
 	if err != nil {
 		return err
 	}
 	args := []string{"-Uvh"}
+	if opts.Downgrade {
+		args = append(args, "--oldpackage")
+	}
 	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(pkgs))
 	for _, pkg := range pkgs {
-		blob, err := c.BlobAbsPath(pkg.SHA256)
+		blob, err := c.BlobAbsPath(pkg.Sum())
 		if err != nil {
 			return err
 		}
@@ -237,6 +319,52 @@ func (d *fedora) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []fil
 	return nil
 }
 
+func (d *fedora) UninstallPackages(ctx context.Context, pkgs []distro.InstalledPackage, opts distro.InstallOpts) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
+	cmdName, err := exec.LookPath("rpm")
+	if err != nil {
+		return err
+	}
+	args := []string{"-e"}
+	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(pkgs))
+	for _, pkg := range pkgs {
+		s := pkg.Package
+		if pkg.Architecture != "" {
+			s += "." + pkg.Architecture
+		}
+		args = append(args, s)
+	}
+	cmd := exec.CommandContext(ctx, cmdName, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	return cmd.Run()
+}
+
+var (
+	//go:embed Dockerfile.generate-hash.tmpl
+	dockerfileGenerateHashTmpl string
+
+	//go:embed Dockerfile.tmpl
+	dockerfileTmpl string
+)
+
 func (d *fedora) GenerateDockerfile(ctx context.Context, dir string, args distro.DockerfileTemplateArgs, opts distro.DockerfileOpts) error {
-	return ErrNotImplemented
+	if opts.GenerateHash {
+		f := filepath.Join(dir, "Dockerfile.generate-hash") // no need to use securejoin (const)
+		if err := args.WriteToFile(f, dockerfileGenerateHashTmpl); err != nil {
+			return fmt.Errorf("failed to generate %q: %w", f, err)
+		}
+	}
+	f := filepath.Join(dir, "Dockerfile") // no need to use securejoin (const)
+	if err := args.WriteToFile(f, dockerfileTmpl); err != nil {
+		return fmt.Errorf("failed to generate %q: %w", f, err)
+	}
+	return nil
 }
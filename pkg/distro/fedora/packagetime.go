@@ -0,0 +1,32 @@
+package fedora
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/rpmutil"
+)
+
+// PackageTime returns the BUILDTIME of the package.
+func (d *fedora) PackageTime(c *cache.Cache, pkg filespec.FileSpec) (time.Time, error) {
+	if pkg.RPM == nil {
+		return time.Time{}, nil
+	}
+	blob, err := c.BlobAbsPath(pkg.Sum())
+	if err != nil {
+		return time.Time{}, err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	t, err := rpmutil.BuildTime(f)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the build time of %q: %w", pkg.Basename, err)
+	}
+	return t, nil
+}
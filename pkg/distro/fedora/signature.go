@@ -0,0 +1,127 @@
+package fedora
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"github.com/reproducible-containers/repro-get/pkg/keyring"
+	"github.com/reproducible-containers/repro-get/pkg/rpmutil"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+)
+
+// DefaultKeyrings is the default of distro.VerifyPackagesOpts.Keyrings.
+var DefaultKeyrings = []string{"/etc/pki/rpm-gpg/RPM-GPG-KEY-*"}
+
+// rpmVerifier verifies the signatures of the *.rpm files.
+type rpmVerifier struct {
+	keyring       openpgp.EntityList
+	allowUnsigned bool
+}
+
+// readKeyrings reads the OpenPGP keyrings. The patterns are expanded with filepath.Glob.
+// The RSA public keys for Alpine ("*.rsa.pub") are ignored.
+func readKeyrings(patterns ...string) (openpgp.EntityList, error) {
+	var files []string
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, keyring.RSASuffix) {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return hashsig.ReadKeyring(files...)
+}
+
+// newRPMVerifier returns the verifier for the OpenPGP keys in opts.Keyrings and opts.ExtraKeyrings.
+// Returns nil when no key is specified, i.e., the signatures are not verified, as in the previous versions.
+// Unlike VerifyPackages, DefaultKeyrings are not used when no key is specified.
+func newRPMVerifier(opts distro.HashOpts) (*rpmVerifier, error) {
+	patterns := append(append([]string{}, opts.Keyrings...), opts.ExtraKeyrings...)
+	kr, err := readKeyrings(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if len(kr) == 0 {
+		logrus.Debug("Not verifying the signatures of the packages, as no OpenPGP key was specified")
+		return nil, nil
+	}
+	return &rpmVerifier{keyring: kr, allowUnsigned: opts.AllowUnsigned}, nil
+}
+
+// verify verifies the cached *.rpm file, and returns the fingerprint of the signing key.
+// Nil rv skips the verification.
+func (rv *rpmVerifier) verify(c *cache.Cache, sha256sum, name string) (string, error) {
+	if rv == nil {
+		return "", nil
+	}
+	signer, err := verifyBlob(c, sha256sum, rv.keyring)
+	if err != nil {
+		if rv.allowUnsigned {
+			logrus.WithError(err).Warnf("Failed to verify %q", name)
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to verify %q: %w (Hint: add the key with 'repro-get key add', or specify --allow-unsigned)", name, err)
+	}
+	fpr := fingerprint(signer)
+	logrus.Debugf("Verified %q with %s", name, fpr)
+	return fpr, nil
+}
+
+func verifyBlob(c *cache.Cache, sha256sum string, kr openpgp.KeyRing) (*openpgp.Entity, error) {
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return rpmutil.VerifySignature(f, kr)
+}
+
+func fingerprint(e *openpgp.Entity) string {
+	return strings.ToUpper(hex.EncodeToString(e.PrimaryKey.Fingerprint[:]))
+}
+
+// VerifyPackages verifies the OpenPGP signatures of the *.rpm files with opts.Keyrings.
+func (d *fedora) VerifyPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.VerifyPackagesOpts) ([]distro.PackageSignature, error) {
+	patterns := opts.Keyrings
+	if len(patterns) == 0 {
+		patterns = DefaultKeyrings
+	}
+	kr, err := readKeyrings(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if len(kr) == 0 {
+		return nil, fmt.Errorf("no OpenPGP key was found in %v", patterns)
+	}
+	var res []distro.PackageSignature
+	for _, pkg := range pkgs {
+		if pkg.RPM == nil {
+			logrus.Debugf("Skipping verifying %q (not a binary package)", pkg.Name)
+			continue
+		}
+		signer, err := verifyBlob(c, pkg.Sum(), kr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify %q: %w", pkg.Name, err)
+		}
+		fpr := fingerprint(signer)
+		logrus.Debugf("Verified %q with %s", pkg.Name, fpr)
+		res = append(res, distro.PackageSignature{Name: pkg.Name, KeyID: fpr[len(fpr)-16:], Fingerprint: fpr})
+	}
+	return res, nil
+}
@@ -0,0 +1,340 @@
+// Package gomod provides the distro driver for Go modules.
+//
+// The "distro" here is a set of module zips and go.mod files recorded in go.sum.
+package gomod
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containerd/continuity/fs"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/gomodutil"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+const (
+	Name            = "gomod"
+	DefaultLockfile = "go.sum"
+	goProxy         = "https://proxy.golang.org/"
+)
+
+var ErrNotImplemented = fmt.Errorf("distro driver %q does not implement the requested feature", Name)
+
+func New() distro.Distro {
+	d := &gomod{
+		info: distro.Info{
+			Name: Name,
+			DefaultProviders: []string{
+				goProxy + "{{.Name}}",
+			},
+			Experimental:                   true,
+			CacheIsNeededForGeneratingHash: true,
+		},
+	}
+	return d
+}
+
+type gomod struct {
+	info       distro.Info
+	goModCache string
+}
+
+func (d *gomod) Info() distro.Info {
+	return d.info
+}
+
+// Entry is an entry of go.sum.
+type Entry struct {
+	Module  string // "github.com/BurntSushi/toml"
+	Version string // "v1.2.0"
+	Ext     string // ".zip" or ".mod"
+	Hash    string // "h1:..."
+}
+
+// Filename returns a file name like "github.com/!burnt!sushi/toml/@v/v1.2.0.zip".
+func (e *Entry) Filename() (string, error) {
+	return gomodutil.Filename(e.Module, e.Version, e.Ext)
+}
+
+// ParseGoSum parses go.sum.
+// The result is sorted by the file name.
+func ParseGoSum(r io.Reader) ([]Entry, error) {
+	m := make(map[string]Entry)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected line %q: expected 3 fields, got %d", line, len(fields))
+		}
+		e := Entry{
+			Module:  fields[0],
+			Version: fields[1],
+			Ext:     ".zip",
+			Hash:    fields[2],
+		}
+		if strings.HasSuffix(e.Version, "/go.mod") {
+			e.Version = strings.TrimSuffix(e.Version, "/go.mod")
+			e.Ext = ".mod"
+		}
+		fname, err := e.Filename()
+		if err != nil {
+			return nil, fmt.Errorf("unexpected line %q: %w", line, err)
+		}
+		m[fname] = e
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	var fnames []string
+	for f := range m {
+		fnames = append(fnames, f)
+	}
+	sort.Strings(fnames)
+	res := make([]Entry, len(fnames))
+	for i, f := range fnames {
+		res[i] = m[f]
+	}
+	return res, nil
+}
+
+func (d *gomod) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
+	if opts.Cache == nil {
+		return errors.New("cache is required")
+	}
+	lockfile := opts.Lockfile
+	if lockfile == "" {
+		lockfile = DefaultLockfile
+	}
+	f, err := os.Open(lockfile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := ParseGoSum(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse %q: %w", lockfile, err)
+	}
+	filter := make(map[string]struct{}, len(opts.FilterByName))
+	for _, f := range opts.FilterByName {
+		filter[f] = struct{}{}
+	}
+	for _, e := range entries {
+		e := e
+		if len(filter) > 0 {
+			if _, ok := filter[e.Module]; !ok {
+				continue
+			}
+		}
+		if err := d.generateHash1(ctx, hw, opts.Cache, &e); err != nil {
+			return fmt.Errorf("failed to generate the hash for %s@%s (%s): %w", e.Module, e.Version, e.Ext, err)
+		}
+	}
+	return nil
+}
+
+func (d *gomod) generateHash1(ctx context.Context, hw distro.HashWriter, c *cache.Cache, e *Entry) error {
+	fname, err := e.Filename()
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(goProxy + fname)
+	if err != nil {
+		return err
+	}
+	basename := path.Base(fname)
+	sha256sum, err := c.SHA256ByOriginURL(u)
+	if err == nil {
+		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, u.Redacted())
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+	} else {
+		logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
+		sha256sum, err = c.ImportWithURL(u)
+		if err != nil {
+			return err
+		}
+	}
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	h1, err := hashFile(blob, e.Ext)
+	if err != nil {
+		return err
+	}
+	if h1 != e.Hash {
+		return fmt.Errorf("go.sum mismatch: expected %q, got %q", e.Hash, h1)
+	}
+	return hw(sha256sum, fname)
+}
+
+// hashFile computes the go.sum hash of the zip or the go.mod file.
+func hashFile(file, ext string) (string, error) {
+	switch ext {
+	case ".zip":
+		return dirhash.HashZip(file, dirhash.Hash1)
+	case ".mod":
+		return dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+			return os.Open(file)
+		})
+	default:
+		return "", fmt.Errorf("unexpected file extension %q", ext)
+	}
+}
+
+func (d *gomod) PackageName(sp filespec.FileSpec) (string, error) {
+	if sp.GoMod == nil {
+		return "", fmt.Errorf("go module information not available for %q", sp.Name)
+	}
+	return sp.GoMod.Module, nil
+}
+
+// GoModCache returns the GOMODCACHE directory.
+func GoModCache(ctx context.Context) (string, error) {
+	if v := os.Getenv("GOMODCACHE"); v != "" {
+		return v, nil
+	}
+	if goExe, err := exec.LookPath("go"); err == nil {
+		cmd := exec.CommandContext(ctx, goExe, "env", "GOMODCACHE")
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to execute %v: %w", cmd.Args, err)
+		}
+		if s := strings.TrimSpace(string(out)); s != "" {
+			return s, nil
+		}
+	}
+	if v := os.Getenv("GOPATH"); v != "" {
+		return filepath.Join(filepath.SplitList(v)[0], "pkg", "mod"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "go", "pkg", "mod"), nil
+}
+
+// downloadDir returns "$GOMODCACHE/cache/download".
+// The directory can be also used as GOPROXY=file://$GOMODCACHE/cache/download .
+func (d *gomod) downloadDir(ctx context.Context) (string, error) {
+	if d.goModCache == "" {
+		var err error
+		d.goModCache, err = GoModCache(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(d.goModCache, "cache", "download"), nil
+}
+
+func (d *gomod) IsPackageVersionInstalled(ctx context.Context, sp filespec.FileSpec) (bool, error) {
+	if sp.GoMod == nil {
+		return false, fmt.Errorf("go module information not available for %q", sp.Name)
+	}
+	dir, err := d.downloadDir(ctx)
+	if err != nil {
+		return false, err
+	}
+	f, err := securejoin.SecureJoin(dir, sp.Name)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(f); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// InstallPackages populates $GOMODCACHE/cache/download, so that the modules can be used
+// with GOPROXY=off, or with GOPROXY=file://$GOMODCACHE/cache/download .
+func (d *gomod) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
+	dir, err := d.downloadDir(ctx)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Populating %q with %d files", dir, len(pkgs))
+	for _, pkg := range pkgs {
+		if pkg.GoMod == nil {
+			return fmt.Errorf("go module information not available for %q", pkg.Name)
+		}
+		blob, err := c.BlobAbsPath(pkg.Sum())
+		if err != nil {
+			return err
+		}
+		dst, err := securejoin.SecureJoin(dir, pkg.Name)
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		ext := path.Ext(pkg.Name)
+		logrus.Debugf("Copying %q to %q", blob, dst)
+		if err = fs.CopyFile(dst, blob); err != nil {
+			return err
+		}
+		if ext != ".zip" {
+			continue
+		}
+		// The go command needs the *.ziphash and *.info files too
+		h1, err := hashFile(blob, ext)
+		if err != nil {
+			return err
+		}
+		dstBase := strings.TrimSuffix(dst, ext)
+		if err = os.WriteFile(dstBase+".ziphash", []byte(h1), 0644); err != nil {
+			return err
+		}
+		info := fmt.Sprintf("{\"Version\":%q}\n", pkg.GoMod.Version)
+		if err = os.WriteFile(dstBase+".info", []byte(info), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//go:embed Dockerfile.tmpl
+var dockerfileTmpl string
+
+// GenerateDockerfile generates the Dockerfile for building the Go binaries offline, using the hash file "SHA256SUMS-gomod".
+// Dockerfile.generate-hash is not implemented, as the hash file is generated from go.sum without containers.
+func (d *gomod) GenerateDockerfile(ctx context.Context, dir string, args distro.DockerfileTemplateArgs, opts distro.DockerfileOpts) error {
+	if opts.GenerateHash {
+		return fmt.Errorf("%w: generating the hash file with a Dockerfile (Hint: run 'repro-get --distro=gomod hash generate >SHA256SUMS-gomod', and omit the packages)", ErrNotImplemented)
+	}
+	f := filepath.Join(dir, "Dockerfile") // no need to use securejoin (const)
+	if err := args.WriteToFile(f, dockerfileTmpl); err != nil {
+		return fmt.Errorf("failed to generate %q: %w", f, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package gomod
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseGoSum(t *testing.T) {
+	const s = `github.com/google/go-cmp v0.5.9 h1:O2Tfq5qg4qc4AmwVlvv0oLiVAGB7enBSJ2x2DqQFi38=
+github.com/google/go-cmp v0.5.9/go.mod h1:17dUlkBOakJ0+DkrSSNjCkIjxS6bF9zb3elmeNGIjoY=
+github.com/BurntSushi/toml v0.3.1/go.mod h1:xHWCNGjB5oqiDr8zfno3MHue2Ht5sIBksp03qcyfWMU=
+`
+	got, err := ParseGoSum(strings.NewReader(s))
+	assert.NilError(t, err)
+	expected := []Entry{
+		{
+			Module:  "github.com/BurntSushi/toml",
+			Version: "v0.3.1",
+			Ext:     ".mod",
+			Hash:    "h1:xHWCNGjB5oqiDr8zfno3MHue2Ht5sIBksp03qcyfWMU=",
+		},
+		{
+			Module:  "github.com/google/go-cmp",
+			Version: "v0.5.9",
+			Ext:     ".mod",
+			Hash:    "h1:17dUlkBOakJ0+DkrSSNjCkIjxS6bF9zb3elmeNGIjoY=",
+		},
+		{
+			Module:  "github.com/google/go-cmp",
+			Version: "v0.5.9",
+			Ext:     ".zip",
+			Hash:    "h1:O2Tfq5qg4qc4AmwVlvv0oLiVAGB7enBSJ2x2DqQFi38=",
+		},
+	}
+	assert.DeepEqual(t, expected, got)
+	fname, err := got[0].Filename()
+	assert.NilError(t, err)
+	assert.Equal(t, "github.com/!burnt!sushi/toml/@v/v0.3.1.mod", fname)
+}
+
+func TestHashFile(t *testing.T) {
+	// The content of github.com/google/go-cmp@v0.5.9/go.mod
+	const goMod = "module github.com/google/go-cmp\n\ngo 1.13\n"
+	f := filepath.Join(t.TempDir(), "v0.5.9.mod")
+	assert.NilError(t, os.WriteFile(f, []byte(goMod), 0644))
+	got, err := hashFile(f, ".mod")
+	assert.NilError(t, err)
+	assert.Equal(t, "h1:17dUlkBOakJ0+DkrSSNjCkIjxS6bF9zb3elmeNGIjoY=", got)
+}
+
+func TestGenerateDockerfile(t *testing.T) {
+	args := distro.DockerfileTemplateArgs{
+		BaseImage:          "golang:1.19@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		BaseImageOrig:      "golang:1.19",
+		OCIArchDashVariant: "amd64",
+		Providers:          New().Info().DefaultProviders,
+		SourceDateEpoch:    "1639958400",
+	}
+	dir := t.TempDir()
+	assert.NilError(t, New().GenerateDockerfile(context.TODO(), dir, args, distro.DockerfileOpts{}))
+	b, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(b), "ARG BASE_IMAGE="+args.BaseImage+" # golang:1.19\n"))
+	assert.Assert(t, strings.Contains(string(b), "ARG REPRO_GET_PROVIDER=https://proxy.golang.org/{{.Name}}\n"))
+	assert.Assert(t, strings.Contains(string(b), "ARG SOURCE_DATE_EPOCH=1639958400\n"))
+	assert.Assert(t, strings.Contains(string(b), `GOPROXY="file://${GOMODCACHE}/cache/download"`))
+
+	err = New().GenerateDockerfile(context.TODO(), dir, args, distro.DockerfileOpts{GenerateHash: true})
+	assert.Assert(t, errors.Is(err, ErrNotImplemented), err)
+}
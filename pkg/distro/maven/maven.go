@@ -0,0 +1,374 @@
+// Package maven provides the distro driver for Maven artifacts.
+//
+// The "distro" here is a set of artifacts recorded in Gradle's verification-metadata.xml,
+// or in the output of `mvn dependency:list`.
+package maven
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containerd/continuity/fs"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/mavenutil"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Name            = "maven"
+	DefaultLockfile = "gradle/verification-metadata.xml"
+	mavenCentral    = "https://repo1.maven.org/maven2/"
+)
+
+var ErrNotImplemented = fmt.Errorf("distro driver %q does not implement the requested feature", Name)
+
+func New() distro.Distro {
+	d := &maven{
+		info: distro.Info{
+			Name: Name,
+			DefaultProviders: []string{
+				mavenCentral + "{{.Name}}",
+			},
+			Experimental:                   true,
+			CacheIsNeededForGeneratingHash: true,
+		},
+	}
+	return d
+}
+
+type maven struct {
+	info distro.Info
+}
+
+func (d *maven) Info() distro.Info {
+	return d.info
+}
+
+// Entry is an artifact file entry.
+type Entry struct {
+	mavenutil.Maven
+	Basename string // "commons-lang3-3.12.0.jar"
+	SHA256   string // Optional
+	SHA512   string // Optional
+}
+
+// Filename returns a file name like "org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.jar".
+func (e *Entry) Filename() string {
+	return e.Maven.Filename(e.Basename)
+}
+
+type verificationMetadata struct {
+	Components []struct {
+		Group     string `xml:"group,attr"`
+		Name      string `xml:"name,attr"`
+		Version   string `xml:"version,attr"`
+		Artifacts []struct {
+			Name   string `xml:"name,attr"`
+			SHA256 []struct {
+				Value string `xml:"value,attr"`
+			} `xml:"sha256"`
+			SHA512 []struct {
+				Value string `xml:"value,attr"`
+			} `xml:"sha512"`
+		} `xml:"artifact"`
+	} `xml:"components>component"`
+}
+
+// ParseVerificationMetadata parses Gradle's verification-metadata.xml.
+// https://docs.gradle.org/current/userguide/dependency_verification.html
+func ParseVerificationMetadata(r io.Reader) ([]Entry, error) {
+	var vm verificationMetadata
+	if err := xml.NewDecoder(r).Decode(&vm); err != nil {
+		return nil, err
+	}
+	var res []Entry
+	for _, c := range vm.Components {
+		for _, a := range c.Artifacts {
+			e := Entry{
+				Maven: mavenutil.Maven{
+					Group:    c.Group,
+					Artifact: c.Name,
+					Version:  c.Version,
+				},
+				Basename: a.Name,
+			}
+			if len(a.SHA256) > 0 {
+				e.SHA256 = a.SHA256[0].Value
+			}
+			if len(a.SHA512) > 0 {
+				e.SHA512 = a.SHA512[0].Value
+			}
+			res = append(res, e)
+		}
+	}
+	return sortEntries(res), nil
+}
+
+// ParseDependencyList parses the output of `mvn dependency:list`.
+// Each line is expected to be like "[INFO]    org.apache.commons:commons-lang3:jar:3.12.0:compile".
+// Both the artifact (typically *.jar) and *.pom are emitted for each line.
+func ParseDependencyList(r io.Reader) ([]Entry, error) {
+	var res []Entry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "[INFO]"))
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		coords := strings.Split(fields[0], ":")
+		var (
+			m          mavenutil.Maven
+			typ        = "jar"
+			classifier string
+		)
+		switch len(coords) {
+		case 3: // group:artifact:version
+			m = mavenutil.Maven{Group: coords[0], Artifact: coords[1], Version: coords[2]}
+		case 4: // group:artifact:type:version
+			m = mavenutil.Maven{Group: coords[0], Artifact: coords[1], Version: coords[3]}
+			typ = coords[2]
+		case 5: // group:artifact:type:version:scope
+			m = mavenutil.Maven{Group: coords[0], Artifact: coords[1], Version: coords[3]}
+			typ = coords[2]
+		case 6: // group:artifact:type:classifier:version:scope
+			m = mavenutil.Maven{Group: coords[0], Artifact: coords[1], Version: coords[4]}
+			typ, classifier = coords[2], coords[3]
+		default:
+			// Not a dependency line, e.g., "The following files have been resolved:"
+			continue
+		}
+		if strings.Contains(m.Group, "/") || strings.Contains(m.Version, "/") {
+			continue
+		}
+		base := m.Artifact + "-" + m.Version
+		artifact := base
+		if classifier != "" {
+			artifact += "-" + classifier
+		}
+		if typ == "bundle" || typ == "test-jar" || typ == "maven-plugin" {
+			typ = "jar"
+		}
+		res = append(res,
+			Entry{Maven: m, Basename: artifact + "." + typ},
+			Entry{Maven: m, Basename: base + ".pom"},
+		)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return sortEntries(res), nil
+}
+
+// sortEntries sorts the entries by the file name, and removes duplicates.
+func sortEntries(entries []Entry) []Entry {
+	m := make(map[string]Entry, len(entries))
+	for _, e := range entries {
+		m[e.Filename()] = e
+	}
+	var fnames []string
+	for f := range m {
+		fnames = append(fnames, f)
+	}
+	sort.Strings(fnames)
+	res := make([]Entry, len(fnames))
+	for i, f := range fnames {
+		res[i] = m[f]
+	}
+	return res
+}
+
+func (d *maven) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
+	if opts.Cache == nil {
+		return errors.New("cache is required")
+	}
+	lockfile := opts.Lockfile
+	if lockfile == "" {
+		lockfile = DefaultLockfile
+	}
+	f, err := os.Open(lockfile)
+	if err != nil {
+		return fmt.Errorf("%w (Hint: specify the output of `mvn dependency:list` with --lockfile)", err)
+	}
+	defer f.Close()
+	parse := ParseDependencyList
+	if strings.HasSuffix(lockfile, ".xml") {
+		parse = ParseVerificationMetadata
+	}
+	entries, err := parse(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse %q: %w", lockfile, err)
+	}
+	filter := make(map[string]struct{}, len(opts.FilterByName))
+	for _, f := range opts.FilterByName {
+		filter[f] = struct{}{}
+	}
+	for _, e := range entries {
+		e := e
+		if len(filter) > 0 {
+			if _, ok := filter[packageName(&e.Maven)]; !ok {
+				continue
+			}
+		}
+		if err := d.generateHash1(ctx, hw, opts.Cache, &e, opts.SHA512); err != nil {
+			return fmt.Errorf("failed to generate the hash for %q: %w", e.Filename(), err)
+		}
+	}
+	return nil
+}
+
+func (d *maven) generateHash1(ctx context.Context, hw distro.HashWriter, c *cache.Cache, e *Entry, sha512 bool) error {
+	fname := e.Filename()
+	if sha512 && e.SHA512 != "" {
+		// No need to download
+		return hw(sha256sums.SHA512Prefix+e.SHA512, fname)
+	}
+	if e.SHA256 != "" {
+		// No need to download
+		return hw(e.SHA256, fname)
+	}
+	u, err := url.Parse(mavenCentral + fname)
+	if err != nil {
+		return err
+	}
+	basename := path.Base(fname)
+	sha256sum, err := c.SHA256ByOriginURL(u)
+	if err == nil {
+		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, u.Redacted())
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+	} else {
+		logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
+		sha256sum, err = c.ImportWithURL(u)
+		if err != nil {
+			return err
+		}
+	}
+	if e.SHA512 != "" {
+		blob, err := c.BlobAbsPath(sha256sum)
+		if err != nil {
+			return err
+		}
+		actual, err := sha512File(blob)
+		if err != nil {
+			return err
+		}
+		if actual != e.SHA512 {
+			return fmt.Errorf("sha512 mismatch: expected %q, got %q", e.SHA512, actual)
+		}
+	}
+	return hw(sha256sum, fname)
+}
+
+func sha512File(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha512.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// packageName returns a string like "org.apache.commons:commons-lang3".
+func packageName(m *mavenutil.Maven) string {
+	return m.Group + ":" + m.Artifact
+}
+
+func (d *maven) PackageName(sp filespec.FileSpec) (string, error) {
+	if sp.Maven == nil {
+		return "", fmt.Errorf("maven information not available for %q", sp.Name)
+	}
+	return packageName(sp.Maven), nil
+}
+
+// LocalRepository returns the local repository directory, typically "~/.m2/repository".
+func LocalRepository() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".m2", "repository"), nil
+}
+
+func (d *maven) IsPackageVersionInstalled(ctx context.Context, sp filespec.FileSpec) (bool, error) {
+	repo, err := LocalRepository()
+	if err != nil {
+		return false, err
+	}
+	f, err := securejoin.SecureJoin(repo, sp.Name)
+	if err != nil {
+		return false, err
+	}
+	r, err := os.Open(f)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer r.Close()
+	dgst, err := digest.SHA256.FromReader(r)
+	if err != nil {
+		return false, err
+	}
+	return dgst.Encoded() == sp.SHA256, nil
+}
+
+// InstallPackages copies the artifacts into the local repository, so that they can be used with `mvn --offline`,
+// or with `gradle --offline` with `mavenLocal()`.
+func (d *maven) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
+	repo, err := LocalRepository()
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Populating %q with %d files", repo, len(pkgs))
+	for _, pkg := range pkgs {
+		blob, err := c.BlobAbsPath(pkg.Sum())
+		if err != nil {
+			return err
+		}
+		dst, err := securejoin.SecureJoin(repo, pkg.Name)
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		logrus.Debugf("Copying %q to %q", blob, dst)
+		if err = fs.CopyFile(dst, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *maven) GenerateDockerfile(ctx context.Context, dir string, args distro.DockerfileTemplateArgs, opts distro.DockerfileOpts) error {
+	return ErrNotImplemented
+}
@@ -0,0 +1,63 @@
+package maven
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/mavenutil"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseVerificationMetadata(t *testing.T) {
+	const s = `<?xml version="1.0" encoding="UTF-8"?>
+<verification-metadata xmlns="https://schema.gradle.org/dependency-verification" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="https://schema.gradle.org/dependency-verification https://schema.gradle.org/dependency-verification/dependency-verification-1.1.xsd">
+   <configuration>
+      <verify-metadata>true</verify-metadata>
+      <verify-signatures>false</verify-signatures>
+   </configuration>
+   <components>
+      <component group="org.apache.commons" name="commons-lang3" version="3.12.0">
+         <artifact name="commons-lang3-3.12.0.jar">
+            <sha256 value="d919d904486c037f8d193412da0c92e22a9fa24230b9d67a57855c5c31c7e94e" origin="Generated by Gradle"/>
+         </artifact>
+      </component>
+   </components>
+</verification-metadata>
+`
+	got, err := ParseVerificationMetadata(strings.NewReader(s))
+	assert.NilError(t, err)
+	expected := []Entry{
+		{
+			Maven: mavenutil.Maven{
+				Group:    "org.apache.commons",
+				Artifact: "commons-lang3",
+				Version:  "3.12.0",
+			},
+			Basename: "commons-lang3-3.12.0.jar",
+			SHA256:   "d919d904486c037f8d193412da0c92e22a9fa24230b9d67a57855c5c31c7e94e",
+		},
+	}
+	assert.DeepEqual(t, expected, got)
+	assert.Equal(t, "org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.jar", got[0].Filename())
+}
+
+func TestParseDependencyList(t *testing.T) {
+	const s = `
+The following files have been resolved:
+   org.apache.commons:commons-lang3:jar:3.12.0:compile -- module org.apache.commons.lang3
+   junit:junit:jar:4.13.2:test
+`
+	got, err := ParseDependencyList(strings.NewReader(s))
+	assert.NilError(t, err)
+	var fnames []string
+	for _, e := range got {
+		fnames = append(fnames, e.Filename())
+	}
+	expected := []string{
+		"junit/junit/4.13.2/junit-4.13.2.jar",
+		"junit/junit/4.13.2/junit-4.13.2.pom",
+		"org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.jar",
+		"org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.pom",
+	}
+	assert.DeepEqual(t, expected, fnames)
+}
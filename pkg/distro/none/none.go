@@ -16,8 +16,9 @@ var ErrNotImplemented = fmt.Errorf("distro driver %q does not implement the requ
 func New() distro.Distro {
 	d := &none{
 		info: distro.Info{
-			Name:             Name,
-			DefaultProviders: nil,
+			Name: Name,
+			// The origin URLs are recorded in the hash files with `hash generate --with-urls`, or manually
+			DefaultProviders: []string{"{{.OriginURL}}"},
 		},
 	}
 	return d
@@ -0,0 +1,303 @@
+// Package npm provides the distro driver for npm packages.
+//
+// The "distro" here is a set of tarballs recorded in package-lock.json.
+package npm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/npmutil"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Name            = "npm"
+	DefaultLockfile = "package-lock.json"
+)
+
+var ErrNotImplemented = fmt.Errorf("distro driver %q does not implement the requested feature", Name)
+
+func New() distro.Distro {
+	d := &npm{
+		info: distro.Info{
+			Name: Name,
+			DefaultProviders: []string{
+				"https://registry.npmjs.org/{{.Name}}",
+			},
+			Experimental:                   true,
+			CacheIsNeededForGeneratingHash: true,
+		},
+	}
+	return d
+}
+
+type npm struct {
+	info distro.Info
+}
+
+func (d *npm) Info() distro.Info {
+	return d.info
+}
+
+// Entry is a tarball entry of package-lock.json.
+type Entry struct {
+	Package   string // "@babel/core"
+	Version   string // "7.19.3"
+	Resolved  string // "https://registry.npmjs.org/@babel/core/-/core-7.19.3.tgz"
+	Integrity string // "sha512-..."
+}
+
+// Filename returns a file name like "@babel/core/-/core-7.19.3.tgz".
+func (e *Entry) Filename() string {
+	return npmutil.Filename(e.Package, e.Version)
+}
+
+type lockfile struct {
+	LockfileVersion int                      `json:"lockfileVersion"`
+	Packages        map[string]lockfilePkg   `json:"packages"`     // lockfileVersion >= 2
+	Dependencies    map[string]lockfileDepV1 `json:"dependencies"` // lockfileVersion 1
+}
+
+type lockfilePkg struct {
+	Name      string `json:"name"` // only set for aliased packages
+	Version   string `json:"version"`
+	Resolved  string `json:"resolved"`
+	Integrity string `json:"integrity"`
+	Link      bool   `json:"link"`
+}
+
+type lockfileDepV1 struct {
+	Version      string                   `json:"version"`
+	Resolved     string                   `json:"resolved"`
+	Integrity    string                   `json:"integrity"`
+	Bundled      bool                     `json:"bundled"`
+	Dependencies map[string]lockfileDepV1 `json:"dependencies"`
+}
+
+// ParseLockfile parses package-lock.json.
+// The result is sorted by the file name, and does not contain duplicates.
+// Linked, bundled, and non-registry (e.g., git) dependencies are skipped.
+func ParseLockfile(r io.Reader) ([]Entry, error) {
+	var lf lockfile
+	if err := json.NewDecoder(r).Decode(&lf); err != nil {
+		return nil, err
+	}
+	m := make(map[string]Entry)
+	add := func(e Entry) {
+		if e.Resolved == "" || e.Integrity == "" {
+			logrus.Debugf("Skipping %s@%s (no resolved URL or integrity)", e.Package, e.Version)
+			return
+		}
+		if !strings.HasSuffix(e.Resolved, ".tgz") {
+			logrus.Warnf("Skipping %s@%s (unsupported resolved URL %q)", e.Package, e.Version, e.Resolved)
+			return
+		}
+		m[e.Filename()] = e
+	}
+	if len(lf.Packages) > 0 {
+		for k, v := range lf.Packages {
+			if k == "" || v.Link {
+				continue
+			}
+			const nodeModules = "node_modules/"
+			i := strings.LastIndex(k, nodeModules)
+			if i < 0 {
+				// workspace
+				continue
+			}
+			pkg := k[i+len(nodeModules):]
+			if v.Name != "" {
+				pkg = v.Name
+			}
+			add(Entry{Package: pkg, Version: v.Version, Resolved: v.Resolved, Integrity: v.Integrity})
+		}
+	} else {
+		var walk func(map[string]lockfileDepV1)
+		walk = func(deps map[string]lockfileDepV1) {
+			for k, v := range deps {
+				if !v.Bundled {
+					add(Entry{Package: k, Version: v.Version, Resolved: v.Resolved, Integrity: v.Integrity})
+				}
+				walk(v.Dependencies)
+			}
+		}
+		walk(lf.Dependencies)
+	}
+	var fnames []string
+	for f := range m {
+		fnames = append(fnames, f)
+	}
+	sort.Strings(fnames)
+	res := make([]Entry, len(fnames))
+	for i, f := range fnames {
+		res[i] = m[f]
+	}
+	return res, nil
+}
+
+func (d *npm) GenerateHash(ctx context.Context, hw distro.HashWriter, opts distro.HashOpts) error {
+	if opts.Cache == nil {
+		return errors.New("cache is required")
+	}
+	lockfile := opts.Lockfile
+	if lockfile == "" {
+		lockfile = DefaultLockfile
+	}
+	b, err := os.ReadFile(lockfile)
+	if err != nil {
+		return err
+	}
+	entries, err := ParseLockfile(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to parse %q: %w", lockfile, err)
+	}
+	filter := make(map[string]struct{}, len(opts.FilterByName))
+	for _, f := range opts.FilterByName {
+		filter[f] = struct{}{}
+	}
+	for _, e := range entries {
+		e := e
+		if len(filter) > 0 {
+			if _, ok := filter[e.Package]; !ok {
+				continue
+			}
+		}
+		if err := d.generateHash1(ctx, hw, opts.Cache, &e); err != nil {
+			return fmt.Errorf("failed to generate the hash for %s@%s: %w", e.Package, e.Version, err)
+		}
+	}
+	return nil
+}
+
+func (d *npm) generateHash1(ctx context.Context, hw distro.HashWriter, c *cache.Cache, e *Entry) error {
+	fname := e.Filename()
+	ii, err := npmutil.ParseIntegrity(e.Integrity)
+	if err != nil {
+		return err
+	}
+	if sha256sum := npmutil.SHA256(ii); sha256sum != "" {
+		// No need to download
+		return hw(sha256sum, fname)
+	}
+	u, err := url.Parse(e.Resolved)
+	if err != nil {
+		return err
+	}
+	basename := path.Base(fname)
+	sha256sum, err := c.SHA256ByOriginURL(u)
+	if err == nil {
+		logrus.Debugf("%q: found cached sha256sum %s for %q", basename, sha256sum, u.Redacted())
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check the cached sha256 by URL %q: %w", u.Redacted(), err)
+	} else {
+		logrus.Debugf("%q: downloading from %q", basename, u.Redacted())
+		sha256sum, err = c.ImportWithURL(u)
+		if err != nil {
+			return err
+		}
+	}
+	if err = verifyIntegrity(c, sha256sum, ii); err != nil {
+		return err
+	}
+	return hw(sha256sum, fname)
+}
+
+func verifyIntegrity(c *cache.Cache, sha256sum string, ii []npmutil.Integrity) error {
+	strongest := npmutil.Strongest(ii)
+	if strongest == nil {
+		return errors.New("no supported integrity")
+	}
+	blob, err := c.BlobAbsPath(sha256sum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := npmutil.NewHash(strongest.Algorithm)
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := h.Sum(nil); !bytes.Equal(actual, strongest.Digest) {
+		return fmt.Errorf("integrity mismatch (%s): expected %x, got %x", strongest.Algorithm, strongest.Digest, actual)
+	}
+	return nil
+}
+
+func (d *npm) PackageName(sp filespec.FileSpec) (string, error) {
+	if sp.NPM == nil {
+		return "", fmt.Errorf("npm information not available for %q", sp.Name)
+	}
+	return sp.NPM.Package, nil
+}
+
+func (d *npm) IsPackageVersionInstalled(ctx context.Context, sp filespec.FileSpec) (bool, error) {
+	// Adding the package to the npm cache is idempotent
+	return false, nil
+}
+
+// InstallPackages adds the packages to the npm cache, so that they can be installed with `npm ci --offline`.
+func (d *npm) InstallPackages(ctx context.Context, c *cache.Cache, pkgs []filespec.FileSpec, opts distro.InstallOpts) error {
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if opts.Root != "" && opts.Root != "/" {
+		return fmt.Errorf("%w: custom root %q", ErrNotImplemented, opts.Root)
+	}
+	cmdName, err := exec.LookPath("npm")
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "repro-get-npm-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	args := []string{"cache", "add"}
+	logrus.Infof("Running '%s %s ...' with %d packages", cmdName, strings.Join(args, " "), len(pkgs))
+	for _, pkg := range pkgs {
+		blob, err := c.BlobAbsPath(pkg.Sum())
+		if err != nil {
+			return err
+		}
+		// The basename is not unique for scoped packages
+		ln, err := securejoin.SecureJoin(tmpDir, pkg.Sum()+"-"+pkg.Basename)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(blob, ln); err != nil {
+			return err
+		}
+		args = append(args, ln)
+	}
+	cmd := exec.CommandContext(ctx, cmdName, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *npm) GenerateDockerfile(ctx context.Context, dir string, args distro.DockerfileTemplateArgs, opts distro.DockerfileOpts) error {
+	return ErrNotImplemented
+}
@@ -0,0 +1,58 @@
+package npm
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseLockfile(t *testing.T) {
+	// s is a trimmed package-lock.json generated by `npm install lodash @babel/parser`
+	const s = `{
+  "name": "foo",
+  "lockfileVersion": 2,
+  "requires": true,
+  "packages": {
+    "": {
+      "dependencies": {
+        "@babel/parser": "^7.19.4",
+        "lodash": "^4.17.21"
+      }
+    },
+    "node_modules/@babel/parser": {
+      "version": "7.19.4",
+      "resolved": "https://registry.npmjs.org/@babel/parser/-/parser-7.19.4.tgz",
+      "integrity": "sha512-qpVT7gtuOLjWeDTKLkJ6sryqLliBaFpAtGeqw5cs5giLldvh+Ch0plqnUMKoVAUS6ZEueQQiZV+p5pxtPitEsA=="
+    },
+    "node_modules/lodash": {
+      "version": "4.17.21",
+      "resolved": "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz",
+      "integrity": "sha512-v2kDEe57lecTulaDIuNTPy3Ry4gLGJ6Z1O3vE1krgXZNrsQ+LFTGHVxVjcXPs17LhbZVGedAJv8XZ1tvj5FvSg=="
+    },
+    "node_modules/local": {
+      "resolved": "../local",
+      "link": true
+    }
+  }
+}
+`
+	got, err := ParseLockfile(strings.NewReader(s))
+	assert.NilError(t, err)
+	expected := []Entry{
+		{
+			Package:   "@babel/parser",
+			Version:   "7.19.4",
+			Resolved:  "https://registry.npmjs.org/@babel/parser/-/parser-7.19.4.tgz",
+			Integrity: "sha512-qpVT7gtuOLjWeDTKLkJ6sryqLliBaFpAtGeqw5cs5giLldvh+Ch0plqnUMKoVAUS6ZEueQQiZV+p5pxtPitEsA==",
+		},
+		{
+			Package:   "lodash",
+			Version:   "4.17.21",
+			Resolved:  "https://registry.npmjs.org/lodash/-/lodash-4.17.21.tgz",
+			Integrity: "sha512-v2kDEe57lecTulaDIuNTPy3Ry4gLGJ6Z1O3vE1krgXZNrsQ+LFTGHVxVjcXPs17LhbZVGedAJv8XZ1tvj5FvSg==",
+		},
+	}
+	assert.DeepEqual(t, expected, got)
+	assert.Equal(t, "@babel/parser/-/parser-7.19.4.tgz", got[0].Filename())
+}
@@ -0,0 +1,113 @@
+package dockerfileutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// BakeFilename is the file name of the bake file written by WriteBakeFile.
+const BakeFilename = "docker-bake.hcl"
+
+// BakeOpts is the options for WriteBakeFile.
+type BakeOpts struct {
+	Platforms       []string // Such as "linux/amd64" and "linux/arm64"
+	GenerateHash    bool     // Add the "generate-hash" target for "Dockerfile.generate-hash"
+	SourceDateEpoch string   // Default value of the SOURCE_DATE_EPOCH variable, or empty
+}
+
+const bakeTmpl = `# Generated by repro-get.
+
+# Bake file (https://docs.docker.com/build/bake/) for building the multi-platform image using the hash files.
+
+# Usage:
+# Make sure that the hash files {{.HashFiles}} are present in the current directory{{if .GenerateHash}},
+# or generate them with the "generate-hash" target{{end}}.
+# ----------------------------------------------------------
+# Copy the repro-get binaries {{.Binaries}} into the current directory
+{{- if .GenerateHash}}
+# docker buildx bake generate-hash{{end}}
+# docker buildx bake --set image.tags=example.com/foo:latest --push
+# ----------------------------------------------------------
+
+variable "SOURCE_DATE_EPOCH" {
+  default = {{quote .SourceDateEpoch}}
+}
+
+group "default" {
+  targets = ["image"]
+}
+{{if .GenerateHash}}
+target "generate-hash" {
+  dockerfile = "Dockerfile.generate-hash"
+  platforms  = {{list .Platforms}}
+  # Write "SHA256SUMS-<ARCH>" files into the current directory, without the subdirectories for the platforms
+  output = ["type=local,dest=.,platform-split=false"]
+}
+{{end}}
+target "image" {
+  dockerfile = "Dockerfile"
+  platforms  = {{list .Platforms}}
+  args = {
+    SOURCE_DATE_EPOCH = SOURCE_DATE_EPOCH
+  }
+}
+`
+
+// WriteBakeFile writes the bake file for building "Dockerfile" (and "Dockerfile.generate-hash") for the platforms,
+// so that a single `docker buildx bake` invocation produces the multi-platform image from the hash files
+// ("SHA256SUMS-<ARCH>") selected by $TARGETARCH.
+func WriteBakeFile(w io.Writer, opts BakeOpts) error {
+	if len(opts.Platforms) == 0 {
+		return errors.New("no platform was specified")
+	}
+	var hashFiles, binaries []string
+	for _, p := range opts.Platforms {
+		arch, err := PlatformArchDashVariant(p)
+		if err != nil {
+			return err
+		}
+		hashFiles = append(hashFiles, strconv.Quote("SHA256SUMS-"+arch))
+		binaries = append(binaries, strconv.Quote("repro-get.linux-"+arch))
+	}
+	funcs := template.FuncMap{
+		"quote": strconv.Quote,
+		"list": func(ss []string) string {
+			quoted := make([]string, len(ss))
+			for i, s := range ss {
+				quoted[i] = strconv.Quote(s)
+			}
+			return "[" + strings.Join(quoted, ", ") + "]"
+		},
+	}
+	parsed, err := template.New(BakeFilename).Funcs(funcs).Parse(bakeTmpl)
+	if err != nil {
+		return err
+	}
+	args := map[string]interface{}{
+		"Platforms":       opts.Platforms,
+		"GenerateHash":    opts.GenerateHash,
+		"SourceDateEpoch": opts.SourceDateEpoch,
+		"HashFiles":       strings.Join(hashFiles, ", "),
+		"Binaries":        strings.Join(binaries, ", "),
+	}
+	return parsed.Execute(w, args)
+}
+
+// PlatformArchDashVariant converts the platform such as "linux/arm/v7" into "arm-v7",
+// for the file names such as "SHA256SUMS-arm-v7".
+func PlatformArchDashVariant(p string) (string, error) {
+	osName, arch, ok := strings.Cut(p, "/")
+	if !ok || osName != "linux" || arch == "" || strings.Count(arch, "/") > 1 {
+		return "", fmt.Errorf("invalid platform %q, expected \"linux/<ARCH>[/<VARIANT>]\"", p)
+	}
+	return strings.ReplaceAll(arch, "/", "-"), nil
+}
+
+// ArchDashVariantPlatform converts the architecture such as "arm-v7" into "linux/arm/v7".
+func ArchDashVariantPlatform(arch string) string {
+	return "linux/" + strings.ReplaceAll(arch, "-", "/")
+}
@@ -0,0 +1,40 @@
+package dockerfileutil
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteBakeFile(t *testing.T) {
+	var b strings.Builder
+	assert.NilError(t, WriteBakeFile(&b, BakeOpts{Platforms: []string{"linux/amd64", "linux/arm/v7"}, GenerateHash: true}))
+	s := b.String()
+	assert.Assert(t, strings.Contains(s, `# Copy the repro-get binaries "repro-get.linux-amd64", "repro-get.linux-arm-v7" into the current directory
+# docker buildx bake generate-hash
+`), s)
+	assert.Assert(t, strings.Contains(s, `target "generate-hash" {`))
+	assert.Assert(t, strings.Contains(s, `  platforms  = ["linux/amd64", "linux/arm/v7"]
+`))
+	assert.Assert(t, strings.Contains(s, "  default = \"\"\n"))
+
+	b.Reset()
+	assert.NilError(t, WriteBakeFile(&b, BakeOpts{Platforms: []string{"linux/arm64"}, SourceDateEpoch: "1639958400"}))
+	s = b.String()
+	assert.Assert(t, !strings.Contains(s, "generate-hash"), s)
+	assert.Assert(t, strings.Contains(s, "  default = \"1639958400\"\n"))
+
+	assert.ErrorContains(t, WriteBakeFile(&b, BakeOpts{Platforms: []string{"windows/amd64"}}), "invalid platform")
+	assert.ErrorContains(t, WriteBakeFile(&b, BakeOpts{}), "no platform")
+}
+
+func TestPlatformArchDashVariant(t *testing.T) {
+	arch, err := PlatformArchDashVariant("linux/arm/v7")
+	assert.NilError(t, err)
+	assert.Equal(t, "arm-v7", arch)
+	assert.Equal(t, "linux/arm/v7", ArchDashVariantPlatform(arch))
+	assert.Equal(t, "linux/amd64", ArchDashVariantPlatform("amd64"))
+	_, err = PlatformArchDashVariant("amd64")
+	assert.ErrorContains(t, err, "invalid platform")
+}
@@ -0,0 +1,179 @@
+package dockerfileutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containerd/containerd/reference/docker"
+	"github.com/opencontainers/go-digest"
+)
+
+// BaseImageArg is the name of the ARG instruction for the base image in the Dockerfiles generated by repro-get.
+const BaseImageArg = "BASE_IMAGE"
+
+// BaseImageLockFilename is the file name of BaseImageLock.
+const BaseImageLockFilename = "base-images.lock"
+
+// BaseImages returns the base image references in the Dockerfile, in the order of appearance, without duplicates.
+// The references are taken from `ARG BASE_IMAGE=<REF>` and `FROM <REF>`.
+// The references with variables, the stage names, and "scratch" are ignored.
+func BaseImages(b []byte) []string {
+	var (
+		res  []string
+		seen = make(map[string]bool)
+	)
+	forEachBaseImage(b, func(ref string) string {
+		if !seen[ref] {
+			seen[ref] = true
+			res = append(res, ref)
+		}
+		return ref
+	})
+	return res
+}
+
+// ReplaceBaseImages replaces the base image references in the Dockerfile, with the mapping from the references
+// returned by BaseImages. The other parts of the Dockerfile, including the comments, are retained as they are.
+func ReplaceBaseImages(b []byte, m map[string]string) []byte {
+	return forEachBaseImage(b, func(ref string) string {
+		if s, ok := m[ref]; ok {
+			return s
+		}
+		return ref
+	})
+}
+
+// forEachBaseImage calls fn for each base image reference, and returns the Dockerfile with the references replaced with
+// the results of fn. The line continuations are not supported.
+func forEachBaseImage(b []byte, fn func(ref string) string) []byte {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	stages := make(map[string]bool)
+	for i, line := range lines {
+		fields := strings.Fields(string(line))
+		if len(fields) < 2 {
+			continue
+		}
+		var (
+			refIdx int    // The index of the field that contains the reference
+			prefix string // The prefix of the field, such as "BASE_IMAGE="
+		)
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			if !strings.HasPrefix(fields[1], BaseImageArg+"=") {
+				continue
+			}
+			refIdx, prefix = 1, BaseImageArg+"="
+		case "FROM":
+			refIdx = 1
+			for refIdx < len(fields) && strings.HasPrefix(fields[refIdx], "--") { // FROM --platform=...
+				refIdx++
+			}
+			if refIdx >= len(fields) {
+				continue
+			}
+			isStage := stages[strings.ToLower(fields[refIdx])]
+			if refIdx+2 < len(fields) && strings.EqualFold(fields[refIdx+1], "AS") {
+				stages[strings.ToLower(fields[refIdx+2])] = true
+			}
+			if isStage {
+				continue
+			}
+		default:
+			continue
+		}
+		field := fields[refIdx]
+		ref := strings.TrimPrefix(field, prefix)
+		if ref == "" || ref == "scratch" || strings.Contains(ref, "$") {
+			continue
+		}
+		newRef := fn(ref)
+		if newRef == ref {
+			continue
+		}
+		s := string(line)
+		idx := fieldIndex(s, refIdx)
+		lines[i] = []byte(s[:idx] + prefix + newRef + s[idx+len(field):])
+	}
+	return bytes.Join(lines, nil)
+}
+
+// fieldIndex returns the byte index of the n-th field (0-based) of s, as split by strings.Fields.
+func fieldIndex(s string, n int) int {
+	inField := false
+	for i, r := range s {
+		isSpace := r == ' ' || r == '\t' || r == '\r' || r == '\n'
+		if !isSpace && !inField {
+			if n == 0 {
+				return i
+			}
+			n--
+		}
+		inField = !isSpace
+	}
+	return len(s)
+}
+
+// UnpinnedBaseImage returns the normalized reference without the digest, such as "docker.io/library/debian:bullseye-20211220",
+// and the digest, if present.
+func UnpinnedBaseImage(ref string) (string, digest.Digest, error) {
+	// Not ParseDockerRef, as it drops the tag when the digest is present
+	named, err := docker.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse %q: %w", ref, err)
+	}
+	named = docker.TagNameOnly(named)
+	var dgst digest.Digest
+	if digested, ok := named.(docker.Digested); ok {
+		dgst = digested.Digest()
+	}
+	return docker.TrimNamed(named).String() + tagSuffix(named), dgst, nil
+}
+
+func tagSuffix(named docker.Named) string {
+	if tagged, ok := named.(docker.Tagged); ok {
+		return ":" + tagged.Tag()
+	}
+	return ""
+}
+
+// BaseImageLock records the digests of the base images, keyed by the references returned by UnpinnedBaseImage.
+type BaseImageLock struct {
+	BaseImages map[string]digest.Digest `json:"BaseImages"`
+}
+
+// LoadBaseImageLock loads the lock file. An empty lock is returned when the file does not exist.
+func LoadBaseImageLock(f string) (*BaseImageLock, error) {
+	lock := &BaseImageLock{BaseImages: make(map[string]digest.Digest)}
+	b, err := os.ReadFile(f)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return lock, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", f, err)
+	}
+	if lock.BaseImages == nil {
+		lock.BaseImages = make(map[string]digest.Digest)
+	}
+	for k, v := range lock.BaseImages {
+		if err = v.Validate(); err != nil {
+			return nil, fmt.Errorf("failed to parse %q: invalid digest for %q: %w", f, k, err)
+		}
+	}
+	return lock, nil
+}
+
+// Save saves the lock file. The keys are sorted by encoding/json.
+func (lock *BaseImageLock) Save(f string) error {
+	b, err := json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f, append(b, '\n'), 0644)
+}
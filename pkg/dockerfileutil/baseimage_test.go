@@ -0,0 +1,80 @@
+package dockerfileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+)
+
+func TestBaseImages(t *testing.T) {
+	const dgst = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	const dockerfile = `ARG BASE_IMAGE=docker.io/library/debian:bullseye-20211220@` + dgst + ` # debian:bullseye-20211220
+ARG PACKAGES="gcc"
+
+FROM scratch AS repro-get
+FROM --platform=${TARGETPLATFORM} ${BASE_IMAGE} AS build
+FROM  golang:1.19  AS golang
+FROM golang
+FROM build
+FROM alpine:3.16
+FROM golang:1.19
+`
+	refs := BaseImages([]byte(dockerfile))
+	assert.DeepEqual(t, []string{"docker.io/library/debian:bullseye-20211220@" + dgst, "golang:1.19", "alpine:3.16"}, refs)
+
+	replaced := ReplaceBaseImages([]byte(dockerfile), map[string]string{
+		"docker.io/library/debian:bullseye-20211220@" + dgst: "docker.io/library/debian:bullseye-20211220@sha256:1111",
+		"golang:1.19": "docker.io/library/golang:1.19@sha256:2222",
+	})
+	assert.Equal(t, `ARG BASE_IMAGE=docker.io/library/debian:bullseye-20211220@sha256:1111 # debian:bullseye-20211220
+ARG PACKAGES="gcc"
+
+FROM scratch AS repro-get
+FROM --platform=${TARGETPLATFORM} ${BASE_IMAGE} AS build
+FROM  docker.io/library/golang:1.19@sha256:2222  AS golang
+FROM golang
+FROM build
+FROM alpine:3.16
+FROM docker.io/library/golang:1.19@sha256:2222
+`, string(replaced))
+}
+
+func TestUnpinnedBaseImage(t *testing.T) {
+	const dgst = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	for _, tc := range []struct {
+		ref      string
+		unpinned string
+		dgst     digest.Digest
+	}{
+		{"debian", "docker.io/library/debian:latest", ""},
+		{"debian:bullseye@" + dgst, "docker.io/library/debian:bullseye", dgst},
+		{"debian@" + dgst, "docker.io/library/debian", dgst},
+		{"localhost:5000/foo:1.0", "localhost:5000/foo:1.0", ""},
+	} {
+		unpinned, d, err := UnpinnedBaseImage(tc.ref)
+		assert.NilError(t, err)
+		assert.Equal(t, tc.unpinned, unpinned, tc.ref)
+		assert.Equal(t, tc.dgst, d, tc.ref)
+	}
+	_, _, err := UnpinnedBaseImage("Invalid")
+	assert.ErrorContains(t, err, "failed to parse")
+}
+
+func TestBaseImageLock(t *testing.T) {
+	f := filepath.Join(t.TempDir(), BaseImageLockFilename)
+	lock, err := LoadBaseImageLock(f)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(lock.BaseImages))
+	lock.BaseImages["docker.io/library/debian:bullseye"] = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	assert.NilError(t, lock.Save(f))
+	lock2, err := LoadBaseImageLock(f)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, lock, lock2)
+
+	assert.NilError(t, os.WriteFile(f, []byte(`{"BaseImages":{"debian":"sha256:foo"}}`), 0644))
+	_, err = LoadBaseImageLock(f)
+	assert.ErrorContains(t, err, "invalid digest")
+}
@@ -0,0 +1,239 @@
+// Package dockerfileutil extracts the package names from the RUN instructions of Dockerfiles,
+// for generating the hash files without building the images.
+// The base images of Dockerfiles can be pinned by the digests too, and the bake files can be generated for multi-platform builds.
+package dockerfileutil
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Packages is the packages installed by the RUN instructions of a Dockerfile.
+type Packages struct {
+	Apt []string `json:"Apt,omitempty"` // "apt-get install" and "apt install"
+	APK []string `json:"APK,omitempty"` // "apk add"
+}
+
+// Parse parses the Dockerfile and returns the packages installed by the RUN instructions.
+// The version constraints such as "hello=2.10-2" are stripped, and the arguments with variables (e.g., "$PKGS") are skipped.
+// The heredocs and the exec form of RUN (RUN ["executable", ...]) are not supported.
+func Parse(r io.Reader) (*Packages, error) {
+	var (
+		res  Packages
+		seen = make(map[string]bool)
+	)
+	add := func(list *[]string, kind, name string) {
+		if k := kind + "\x00" + name; !seen[k] {
+			seen[k] = true
+			*list = append(*list, name)
+		}
+	}
+	instructions, err := instructions(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range instructions {
+		keyword, args, _ := strings.Cut(inst, " ")
+		if !strings.EqualFold(keyword, "RUN") {
+			continue
+		}
+		args = strings.TrimSpace(args)
+		for strings.HasPrefix(args, "--") { // RUN --mount=... --network=...
+			_, args, _ = strings.Cut(args, " ")
+			args = strings.TrimSpace(args)
+		}
+		if strings.HasPrefix(args, "[") {
+			logrus.Warnf("Skipping the exec form of RUN instruction %q", inst)
+			continue
+		}
+		if strings.HasPrefix(args, "<<") {
+			logrus.Warnf("Skipping the heredoc of RUN instruction %q", inst)
+			continue
+		}
+		for _, words := range splitCommands(args) {
+			apt, names := packageNames(words)
+			for _, name := range names {
+				if apt {
+					add(&res.Apt, "apt", name)
+				} else {
+					add(&res.APK, "apk", name)
+				}
+			}
+		}
+	}
+	return &res, nil
+}
+
+// instructions returns the instructions of the Dockerfile, with the line continuations joined.
+// The comments and the empty lines are removed.
+func instructions(r io.Reader) ([]string, error) {
+	var (
+		res []string
+		cur strings.Builder
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			// Also allowed inside the line continuations
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			cur.WriteString(strings.TrimSuffix(line, "\\") + " ")
+			continue
+		}
+		cur.WriteString(line)
+		res = append(res, cur.String())
+		cur.Reset()
+	}
+	if cur.Len() > 0 {
+		res = append(res, cur.String())
+	}
+	return res, sc.Err()
+}
+
+// splitCommands splits the shell script into the simple commands, separated by "&&", "||", ";", "|", and "&".
+// The quotes are removed from the words. The words containing variables or command substitutions are kept as they are,
+// so that they can be skipped by the caller.
+func splitCommands(script string) [][]string {
+	var (
+		res    [][]string
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+	)
+	flushWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	flushCommand := func() {
+		flushWord()
+		if len(words) > 0 {
+			res = append(res, words)
+			words = nil
+		}
+	}
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			switch {
+			case c == quote:
+				quote = 0
+			case quote == '"' && c == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]):
+				i++
+				word.WriteRune(runes[i])
+			default:
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case c == ' ' || c == '\t':
+			flushWord()
+		case c == '&' || c == '|' || c == ';' || c == '(' || c == ')':
+			flushCommand()
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	flushCommand()
+	return res
+}
+
+// aptOptsWithValue is the set of the apt-get options that take a value as the next argument.
+var aptOptsWithValue = map[string]bool{
+	"-o": true, "--option": true,
+	"-c": true, "--config-file": true,
+	"-t": true, "--target-release": true, "--default-release": true,
+	"-a": true, "--host-architecture": true,
+}
+
+// apkOptsWithValue is the set of the apk options that take a value as the next argument.
+// The name of a virtual package ("--virtual NAME") is not a package to be installed.
+var apkOptsWithValue = map[string]bool{
+	"-X": true, "--repository": true,
+	"-p": true, "--root": true,
+	"-t": true, "--virtual": true,
+	"--arch":              true,
+	"--keys-dir":          true,
+	"--cache-dir":         true,
+	"--repositories-file": true,
+}
+
+// packageNames returns the package names of an "apt-get install", "apt install", or "apk add" command.
+// apt is true for apt-get and apt. Returns nil names for the other commands.
+func packageNames(words []string) (apt bool, names []string) {
+	// Skip the environment variables and the wrappers, as in "DEBIAN_FRONTEND=noninteractive apt-get install" and "sudo apk add"
+	for len(words) > 0 && (strings.Contains(words[0], "=") || words[0] == "sudo" || words[0] == "env") {
+		words = words[1:]
+	}
+	if len(words) < 2 {
+		return false, nil
+	}
+	var optsWithValue map[string]bool
+	switch words[0] {
+	case "apt-get", "apt":
+		apt, optsWithValue = true, aptOptsWithValue
+	case "apk":
+		optsWithValue = apkOptsWithValue
+	default:
+		return false, nil
+	}
+	var subcommand string
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case optsWithValue[w]:
+			i++
+		case isRedirection(w):
+			if strings.TrimLeft(w, "0123456789<>&") == "" {
+				i++ // "> /dev/null"
+			}
+		case strings.HasPrefix(w, "-"):
+		case subcommand == "":
+			subcommand = w
+			if (apt && subcommand != "install") || (!apt && subcommand != "add") {
+				return false, nil
+			}
+		case strings.ContainsAny(w, "$`"):
+			logrus.Warnf("Skipping %q, as it contains a variable or a command substitution", w)
+		default:
+			name, constraint := splitConstraint(w)
+			if constraint != "" {
+				logrus.Warnf("Ignoring the version constraint %q of package %q", constraint, name)
+			}
+			names = append(names, name)
+		}
+	}
+	return apt, names
+}
+
+// isRedirection returns true for the redirections such as "2>&1", ">/dev/null", and "> FILE".
+func isRedirection(s string) bool {
+	s = strings.TrimLeft(s, "0123456789")
+	return strings.HasPrefix(s, ">") || strings.HasPrefix(s, "<")
+}
+
+// splitConstraint splits "hello=2.10-2" into "hello" and "=2.10-2".
+// The apk constraints such as "hello>2.10" and "hello~2.10" are split too.
+// The apt release suffix such as "hello/bullseye-backports" is stripped as a constraint.
+func splitConstraint(s string) (name, constraint string) {
+	if i := strings.IndexAny(s, "=<>~/"); i > 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
@@ -0,0 +1,42 @@
+package dockerfileutil
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParse(t *testing.T) {
+	const dockerfile = `# syntax=docker/dockerfile:1
+FROM debian:bullseye-20211220
+ARG EXTRA
+RUN apt-get update && \
+  DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends \
+    -o Dpkg::Options::=--force-confold \
+    # comment inside the continuation
+    gcc "build-essential" hello=2.10-2 $EXTRA >/dev/null && \
+  rm -rf /var/lib/apt/lists/*
+run --mount=type=cache,target=/var/cache/apt apt install -t bullseye-backports curl; apt-get remove -y vim
+RUN apt-get install -y gcc 2> /dev/null
+RUN ["apt-get", "install", "-y", "jq"]
+
+FROM alpine:3.16
+RUN apk add --no-cache --virtual .build-deps musl-dev 'python3>3.9' && apk del .build-deps
+RUN sudo apk add -X https://dl-cdn.alpinelinux.org/alpine/edge/testing hello
+`
+	pkgs, err := Parse(strings.NewReader(dockerfile))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Packages{
+		Apt: []string{"gcc", "build-essential", "hello", "curl"},
+		APK: []string{"musl-dev", "python3", "hello"},
+	}, pkgs)
+}
+
+func TestSplitCommands(t *testing.T) {
+	assert.DeepEqual(t, [][]string{
+		{"echo", "a b", `c"d`},
+		{"apt-get", "install", "foo"},
+		{"true"},
+	}, splitCommands(`echo 'a b' "c\"d" && apt-get install foo || true`))
+}
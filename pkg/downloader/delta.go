@@ -0,0 +1,250 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+	"pault.ag/go/debian/version"
+)
+
+// DeltaType is the type of DeltaProvider.
+type DeltaType string
+
+const (
+	// DeltaDebdelta fetches a debdelta file and applies it with the debpatch command.
+	DeltaDebdelta DeltaType = "debdelta"
+	// DeltaZsync fetches a zsync control file and the missing blocks with the zsync command.
+	DeltaZsync DeltaType = "zsync"
+)
+
+// DeltaProvider provides the deltas for reconstructing a *.deb file from an older version in the cache.
+type DeltaProvider struct {
+	Type DeltaType
+	// Template is a template string of the URL, executed with DeltaTemplateArgs.
+	// e.g., "http://debdeltas.debian.net/debian-deltas/{{.DebdeltaName}}" for DeltaDebdelta,
+	// "http://mirror.example.com/debian/{{.Name}}.zsync" for DeltaZsync.
+	Template string
+}
+
+// ParseDeltaProvider parses "TYPE=TEMPLATE".
+func ParseDeltaProvider(s string) (*DeltaProvider, error) {
+	typ, tmpl, ok := strings.Cut(s, "=")
+	if !ok || tmpl == "" {
+		return nil, fmt.Errorf("expected TYPE=TEMPLATE, got %q", s)
+	}
+	dp := &DeltaProvider{Type: DeltaType(typ), Template: tmpl}
+	switch dp.Type {
+	case DeltaDebdelta, DeltaZsync:
+	default:
+		return nil, fmt.Errorf("unknown delta type %q (expected %q or %q)", typ, DeltaDebdelta, DeltaZsync)
+	}
+	return dp, nil
+}
+
+// DeltaTemplateArgs is the argument for the template of DeltaProvider.
+type DeltaTemplateArgs struct {
+	filespec.FileSpec                   // The new file
+	Old               filespec.FileSpec // The older version of the file in the cache
+	// DebdeltaName is the name of the debdelta file, e.g., "pool/main/h/hello/hello_2.10-1_2.10-2_amd64.debdelta".
+	DebdeltaName string
+}
+
+// URL returns the URL of the delta.
+func (dp *DeltaProvider) URL(sp, old *filespec.FileSpec) (*url.URL, error) {
+	if sp.Dpkg == nil || old.Dpkg == nil {
+		return nil, fmt.Errorf("expected *.deb files, got %q and %q", sp.Name, old.Name)
+	}
+	tmpl, err := template.New("").Parse(dp.Template)
+	if err != nil {
+		return nil, err
+	}
+	args := DeltaTemplateArgs{
+		FileSpec: *sp,
+		Old:      *old,
+		DebdeltaName: path.Join(path.Dir(sp.Name),
+			fmt.Sprintf("%s_%s_%s_%s.debdelta", sp.Dpkg.Package, old.Dpkg.Version, sp.Dpkg.Version, sp.Dpkg.Architecture)),
+	}
+	var b bytes.Buffer
+	if err = tmpl.Execute(&b, args); err != nil {
+		return nil, err
+	}
+	s := b.String()
+	if s == dp.Template {
+		return nil, fmt.Errorf("invalid delta provider %q", dp.Template)
+	}
+	return url.Parse(s)
+}
+
+// findOldVersion finds the newest older version of the *.deb file in the cache, by the origin URLs.
+// Returns nil when not found.
+func findOldVersion(c *cache.Cache, sp *filespec.FileSpec) (*filespec.FileSpec, error) {
+	if sp.Dpkg == nil {
+		return nil, nil
+	}
+	newVer, err := parseDpkgVersion(sp.Dpkg.Version)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := c.URLs()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		res    *filespec.FileSpec
+		resVer version.Version
+	)
+	for _, ent := range ents {
+		u, err := url.Parse(ent.URL)
+		if err != nil {
+			continue
+		}
+		dpkg, err := dpkgutil.ParseFilename(path.Base(u.Path))
+		if err != nil || dpkg.Package != sp.Dpkg.Package || dpkg.Architecture != sp.Dpkg.Architecture {
+			continue
+		}
+		ver, err := parseDpkgVersion(dpkg.Version)
+		if err != nil || version.Compare(ver, newVer) >= 0 || (res != nil && version.Compare(ver, resVer) <= 0) {
+			continue
+		}
+		if cached, err := c.Cached(ent.SHA256); err != nil || !cached {
+			continue
+		}
+		// Encode the epoch as in the hash file, e.g., "1:2.0-1" -> "1%3a2.0-1"
+		oldName := strings.ReplaceAll(path.Base(u.Path), ":", "%3a")
+		old, err := filespec.New(oldName, ent.SHA256)
+		if err != nil {
+			continue
+		}
+		res, resVer = old, ver
+	}
+	return res, nil
+}
+
+// parseDpkgVersion parses the version in a *.deb file name, such as "1%3a2.0-1".
+func parseDpkgVersion(s string) (version.Version, error) {
+	unescaped, err := url.PathUnescape(s)
+	if err != nil {
+		return version.Version{}, err
+	}
+	return version.Parse(unescaped)
+}
+
+// ensureDelta reconstructs the file from the older version in the cache with the delta,
+// and imports the file into the cache after verifying the SHA256.
+func ensureDelta(ctx context.Context, c *cache.Cache, dp *DeltaProvider, u *url.URL, sp, old *filespec.FileSpec) error {
+	oldBlob, err := c.BlobAbsPath(old.Sum())
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp("", "repro-get-delta-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	newFile := filepath.Join(tmpDir, "new.deb")
+	var cmd *exec.Cmd
+	switch dp.Type {
+	case DeltaDebdelta:
+		deltaFile := filepath.Join(tmpDir, "delta.debdelta")
+		if err = fetchToFile(ctx, c, u, deltaFile); err != nil {
+			return err
+		}
+		// The signature of the delta does not need to be verified, as the SHA256 of the result is verified
+		cmd = exec.CommandContext(ctx, "debpatch", "--accept-unsigned", deltaFile, oldBlob, newFile)
+	case DeltaZsync:
+		// zsync fetches the control file and the missing blocks by itself
+		cmd = exec.CommandContext(ctx, "zsync", "-q", "-i", oldBlob, "-o", newFile, u.String())
+		cmd.Dir = tmpDir
+	default:
+		return fmt.Errorf("unknown delta type %q", dp.Type)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %v (stderr=%q): %w", cmd.Args, stderr.String(), err)
+	}
+	f, err := os.Open(newFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = c.ImportVerifiedWithReader(f, sp.Sum()); err != nil {
+		return err
+	}
+	p := &cache.Provenance{
+		URL:       u.Redacted(),
+		Provider:  string(dp.Type) + "=" + dp.Template,
+		FetchedAt: time.Now().UTC(),
+	}
+	if err = c.WriteProvenance(sp.Sum(), p); err != nil {
+		logrus.WithError(err).Warnf("Failed to record the provenance of %q", sp.Sum())
+	}
+	return nil
+}
+
+func fetchToFile(ctx context.Context, c *cache.Cache, u *url.URL, file string) error {
+	r, _, err := c.URLOpener().Open(ctx, u, "")
+	if err != nil {
+		return fmt.Errorf("failed to open URL %q: %w", u.Redacted(), err)
+	}
+	defer r.Close()
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to download %q: %w", u.Redacted(), err)
+	}
+	return f.Close()
+}
+
+// downloadDelta tries the delta providers, and returns the redacted URL of the applied delta.
+// ok is false when no delta was applied; then the file has to be downloaded from the regular providers.
+func downloadDelta(ctx context.Context, c *cache.Cache, sp *filespec.FileSpec, opts Opts, printPackageStatus func(string, ...interface{}), emit func(Event)) (deltaURL string, ok bool) {
+	old, err := findOldVersion(c, sp)
+	if err != nil {
+		logrus.WithError(err).Debugf("Failed to find the older version of %s", sp.Basename)
+		return "", false
+	}
+	if old == nil {
+		return "", false
+	}
+	for _, dp := range opts.DeltaProviders {
+		dp := dp
+		u, err := dp.URL(sp, old)
+		if err != nil {
+			logrus.WithError(err).Debugf("Skipping the delta provider %q for %s", dp.Template, sp.Basename)
+			continue
+		}
+		printPackageStatus("Reconstructing from %s with the delta %s", old.Basename, u.Redacted())
+		provider := string(dp.Type) + "=" + dp.Template
+		ev := NewEvent(EventDownloadStart, sp)
+		ev.URL, ev.Provider = u.Redacted(), provider
+		emit(ev)
+		if err = ensureDelta(ctx, c, &dp, u, sp, old); err != nil {
+			ev = NewEvent(EventError, sp)
+			ev.URL, ev.Provider, ev.Error = u.Redacted(), provider, err.Error()
+			emit(ev)
+			logrus.WithError(err).Warnf("Failed to reconstruct %s with the delta %s, falling back to the providers", sp.Basename, u.Redacted())
+			continue
+		}
+		return u.Redacted(), true
+	}
+	return "", false
+}
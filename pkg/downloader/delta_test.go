@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro/none"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseDeltaProvider(t *testing.T) {
+	dp, err := ParseDeltaProvider("debdelta=http://debdeltas.debian.net/debian-deltas/{{.DebdeltaName}}")
+	assert.NilError(t, err)
+	assert.Equal(t, DeltaDebdelta, dp.Type)
+	assert.Equal(t, "http://debdeltas.debian.net/debian-deltas/{{.DebdeltaName}}", dp.Template)
+
+	_, err = ParseDeltaProvider("http://debdeltas.debian.net/debian-deltas/{{.DebdeltaName}}")
+	assert.ErrorContains(t, err, "expected TYPE=TEMPLATE")
+	_, err = ParseDeltaProvider("xdelta=http://example.com/{{.Name}}")
+	assert.ErrorContains(t, err, "unknown delta type")
+}
+
+func TestDeltaProviderURL(t *testing.T) {
+	sp, err := filespec.New("pool/main/f/foo/foo_1%3a2.0-2_amd64.deb", digest.SHA256.FromString("new").Encoded())
+	assert.NilError(t, err)
+	old, err := filespec.New("foo_1%3a2.0-1_amd64.deb", digest.SHA256.FromString("old").Encoded())
+	assert.NilError(t, err)
+	for tmpl, expected := range map[string]string{
+		"debdelta=http://debdeltas.debian.net/debian-deltas/{{.DebdeltaName}}": "http://debdeltas.debian.net/debian-deltas/pool/main/f/foo/foo_1%3a2.0-1_1%3a2.0-2_amd64.debdelta",
+		"zsync=http://mirror.example.com/debian/{{.Name}}.zsync":               "http://mirror.example.com/debian/pool/main/f/foo/foo_1%3a2.0-2_amd64.deb.zsync",
+	} {
+		dp, err := ParseDeltaProvider(tmpl)
+		assert.NilError(t, err)
+		u, err := dp.URL(sp, old)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, u.String())
+	}
+}
+
+func TestDownloadDelta(t *testing.T) {
+	// The fake debpatch command reconstructs the new file by appending the delta to the old file
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+set -eu
+[ "$1" = "--accept-unsigned" ]
+cat "$3" "$2" >"$4"
+`
+	assert.NilError(t, os.WriteFile(filepath.Join(binDir, "debpatch"), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	files := map[string]string{
+		"/pool/main/h/hello/hello_2.9-1_amd64.deb":              "hello-2.9",
+		"/pool/main/h/hello/hello_2.10-1_amd64.deb":             "hello-2.10",
+		"/pool/main/h/hello/hello_2.11-1_amd64.deb":             "hello-2.11",
+		"/pool/main/h/hello/hello_2.10-1_2.10-2_amd64.debdelta": "-delta",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(s))
+	}))
+	defer srv.Close()
+	ctx := context.TODO()
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	for _, f := range []string{"/pool/main/h/hello/hello_2.9-1_amd64.deb", "/pool/main/h/hello/hello_2.10-1_amd64.deb", "/pool/main/h/hello/hello_2.11-1_amd64.deb"} {
+		u, err := url.Parse(srv.URL + f)
+		assert.NilError(t, err)
+		assert.NilError(t, c.Ensure(ctx, u, digest.SHA256.FromString(files[f]).Encoded()))
+	}
+
+	sp, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", digest.SHA256.FromString("hello-2.10-delta").Encoded())
+	assert.NilError(t, err)
+	var events []Event
+	opts := Opts{
+		Providers: []string{srv.URL + "/nonexistent/{{.Name}}"},
+		DeltaProviders: []DeltaProvider{
+			{Type: DeltaDebdelta, Template: srv.URL + "/nonexistent/{{.DebdeltaName}}"},
+			{Type: DeltaDebdelta, Template: srv.URL + "/{{.DebdeltaName}}"},
+		},
+		EventHandler: func(ev Event) {
+			events = append(events, ev)
+		},
+		Quiet: true,
+	}
+	_, err = Download(ctx, none.New(), c, map[string]*filespec.FileSpec{sp.Name: sp}, opts)
+	assert.NilError(t, err)
+	var types []EventType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	assert.DeepEqual(t, []EventType{EventDownloadStart, EventError, EventDownloadStart, EventDownloadComplete}, types)
+	assert.Equal(t, srv.URL+"/pool/main/h/hello/hello_2.10-1_2.10-2_amd64.debdelta", events[3].URL)
+	cached, err := c.Cached(sp.SHA256)
+	assert.NilError(t, err)
+	assert.Assert(t, cached)
+
+	// Falls back to the regular providers when the reconstructed file does not match the SHA256
+	sp, err = filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", digest.SHA256.FromString("corrupted").Encoded())
+	assert.NilError(t, err)
+	_, err = Download(ctx, none.New(), c, map[string]*filespec.FileSpec{sp.Name: sp}, opts)
+	assert.ErrorContains(t, err, "/nonexistent/pool/main/h/hello/hello_2.10-2_amd64.deb")
+}
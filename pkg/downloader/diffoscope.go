@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// mismatchList is the list of the mismatching files of a file, kept in the cache (see cache.SetMismatchDir).
+type mismatchList []*cache.MismatchError
+
+// add adds the MismatchError in err, when the mismatching file was kept.
+func (l *mismatchList) add(err error) {
+	var mm *cache.MismatchError
+	if errors.As(err, &mm) && mm.File != "" {
+		*l = append(*l, mm)
+	}
+}
+
+// report compares the mismatching files with the verified file in the cache, using diffoscope(1).
+// The reports are attached to the errors, and logged.
+func (l mismatchList) report(ctx context.Context, c *cache.Cache, sp *filespec.FileSpec) {
+	if len(l) == 0 {
+		return
+	}
+	verified, err := c.BlobAbsPath(sp.Sum())
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to get the verified file of %s", sp.Basename)
+		return
+	}
+	for _, mm := range l {
+		report, err := diffoscope(ctx, verified, mm.File)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to compare the mismatching file %q with %s", mm.File, sp.Basename)
+			continue
+		}
+		mm.Report = report
+		logrus.Warnf("Found a mismatching file of %s: %v", sp.Basename, mm)
+	}
+}
+
+// diffoscope writes the report of diffoscope(1) next to the mismatching file, and returns the path of the report.
+func diffoscope(ctx context.Context, verified, mismatching string) (string, error) {
+	cmdName, err := exec.LookPath("diffoscope")
+	if err != nil {
+		return "", fmt.Errorf("%w (Hint: install diffoscope)", err)
+	}
+	report := mismatching + ".diffoscope.txt"
+	cmd := exec.CommandContext(ctx, cmdName, "--text", report, verified, mismatching)
+	logrus.Debugf("Running %v", cmd.Args)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		// diffoscope exits with 1 when the files differ
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return "", fmt.Errorf("failed to run %v: %w: %s", cmd.Args, err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,82 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro/none"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+// fakeDiffoscope installs a fake diffoscope that writes "<VERIFIED> <MISMATCHING>" as the report, and exits with 1.
+func fakeDiffoscope(t testing.TB) {
+	binDir := t.TempDir()
+	const script = `#!/bin/sh
+set -eu
+[ "$1" = "--text" ]
+echo "$3 $4" >"$2"
+exit 1
+`
+	assert.NilError(t, os.WriteFile(filepath.Join(binDir, "diffoscope"), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestDownloadMismatchDiffoscope(t *testing.T) {
+	fakeDiffoscope(t)
+	content := []byte("foo")
+	tampered := []byte("tampered")
+	sp := &filespec.FileSpec{
+		Name:     "foo",
+		Basename: "foo",
+		SHA256:   digest.SHA256.FromBytes(content).Encoded(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/good/foo":
+			_, _ = w.Write(content)
+		case strings.HasPrefix(r.URL.Path, "/bad/"):
+			_, _ = w.Write(tampered)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	mismatchDir := t.TempDir()
+	c.SetMismatchDir(mismatchDir)
+
+	opts := Opts{
+		Providers: []string{srv.URL + "/bad/{{.Name}}", srv.URL + "/good/{{.Name}}"},
+		Quiet:     true,
+	}
+	fileSpecs := map[string]*filespec.FileSpec{sp.Name: sp}
+	_, err = Download(context.TODO(), none.New(), c, fileSpecs, opts)
+	assert.NilError(t, err)
+
+	kept := filepath.Join(mismatchDir, digest.SHA256.FromBytes(tampered).Encoded())
+	b, err := os.ReadFile(kept)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tampered, b)
+	verified, err := c.BlobAbsPath(sp.SHA256)
+	assert.NilError(t, err)
+	report, err := os.ReadFile(kept + ".diffoscope.txt")
+	assert.NilError(t, err)
+	assert.Equal(t, verified+" "+kept+"\n", string(report))
+
+	// No verified file is available
+	opts.Providers = []string{srv.URL + "/bad/{{.Name}}"}
+	sp2 := *sp
+	sp2.Name, sp2.Basename = "bar", "bar"
+	sp2.SHA256 = digest.SHA256.FromBytes([]byte("bar")).Encoded()
+	_, err = Download(context.TODO(), none.New(), c, map[string]*filespec.FileSpec{sp2.Name: &sp2}, opts)
+	assert.ErrorContains(t, err, "the mismatching file was kept")
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"sort"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/reproducible-containers/repro-get/pkg/cache"
 	"github.com/reproducible-containers/repro-get/pkg/distro"
 	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/progressbar"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,6 +24,22 @@ type Result struct {
 type Opts struct {
 	Providers     []string
 	SkipInstalled bool
+	Retries       int           // Number of the retries per provider on transient errors
+	RetryBackoff  time.Duration // Initial backoff between the retries, doubled on each retry
+	// ProviderHealth is used for trying the fastest healthy provider first, when non-nil.
+	// The results of the downloads are recorded into ProviderHealth.
+	ProviderHealth *ProviderHealth
+	// EventHandler is called on the progress events, when non-nil.
+	EventHandler EventHandler
+	// Quiet disables printing the status lines and the progress bars, e.g., when stdout is used for the events.
+	Quiet bool
+	// DeltaProviders are tried before Providers, when an older version of the *.deb file is cached.
+	DeltaProviders []DeltaProvider
+	// Timeout is the timeout for downloading all the files. Unlimited when zero.
+	Timeout time.Duration
+	// PerFileTimeout is the timeout for downloading a file from a provider, including the retries.
+	// The next provider is tried on the timeout. Unlimited when zero.
+	PerFileTimeout time.Duration
 }
 
 func Download(ctx context.Context, d distro.Distro, cache *cache.Cache, fileSpecs map[string]*filespec.FileSpec, opts Opts) (*Result, error) {
@@ -45,11 +65,26 @@ func Download(ctx context.Context, d distro.Distro, cache *cache.Cache, fileSpec
 	sort.Strings(fnames)
 	l := len(fnames)
 
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
 	markUpProgressCounter := color.New(color.Bold).SprintFunc()
 	markUpPackage := color.New(color.FgCyan).SprintFunc()
 	markUpComment := color.New(color.FgHiBlack).SprintFunc()
+	tracker := progressbar.NewTracker(l, opts.Quiet)
+	defer tracker.Finish()
+	ctx = progressbar.WithTracker(ctx, tracker)
+	if !opts.Quiet && progressbar.IsTerminal() {
+		// Print the logs above the progress bars
+		origLogOut := logrus.StandardLogger().Out
+		logrus.SetOutput(tracker)
+		defer logrus.SetOutput(origLogOut)
+	}
 	printPackageStatusBase := func(i int, pkg, s string, ff ...interface{}) {
-		fmt.Println(markUpProgressCounter(fmt.Sprintf("(%03d/%03d)", i+1, l)) + " " + markUpPackage(pkg) + " " + markUpComment(fmt.Sprintf(s, ff...)))
+		tracker.Println(markUpProgressCounter(fmt.Sprintf("(%03d/%03d)", i+1, l)) + " " + markUpPackage(pkg) + " " + markUpComment(fmt.Sprintf(s, ff...)))
 	}
 
 	var res Result
@@ -58,6 +93,12 @@ func Download(ctx context.Context, d distro.Distro, cache *cache.Cache, fileSpec
 		printPackageStatus := func(s string, ff ...interface{}) {
 			printPackageStatusBase(i, sp.Basename, s, ff...)
 		}
+		emit := func(ev Event) {
+			if opts.EventHandler != nil {
+				ev.Index, ev.Total = i+1, l
+				opts.EventHandler(ev)
+			}
+		}
 		if opts.SkipInstalled {
 			packageVersionInstalled, err := d.IsPackageVersionInstalled(ctx, *sp)
 			if err != nil {
@@ -66,36 +107,184 @@ func Download(ctx context.Context, d distro.Distro, cache *cache.Cache, fileSpec
 			}
 			if packageVersionInstalled {
 				printPackageStatus("Already installed")
+				emit(NewEvent(EventInstalled, sp))
+				tracker.Increment()
 				continue
 			}
 		}
-		cached, err := cache.Cached(sp.SHA256)
+		cached, err := cache.Cached(sp.Sum())
 		if err != nil {
-			logrus.WithError(err).Warnf("Failed to check whether %q (%q) is cached", sp.SHA256, sp.Basename)
+			logrus.WithError(err).Warnf("Failed to check whether %q (%q) is cached", sp.Sum(), sp.Basename)
 			cached = false
 		}
 		if cached {
+			if err = cache.Touch(sp.Sum()); err != nil {
+				logrus.WithError(err).Warnf("Failed to record the last use of %q (%q)", sp.Sum(), sp.Basename)
+			}
 			printPackageStatus("Cached")
+			emit(NewEvent(EventCached, sp))
 			res.PackagesToBeInstalled = append(res.PackagesToBeInstalled, *sp)
+			tracker.Increment()
 			continue
 		}
+		if len(opts.DeltaProviders) > 0 {
+			begin := time.Now()
+			deltaCtx, cancel := withTimeout(ctx, opts.PerFileTimeout)
+			deltaURL, ok := downloadDelta(deltaCtx, cache, sp, opts, printPackageStatus, emit)
+			cancel()
+			if ok {
+				if err = verifySums(cache, sp); err != nil {
+					logrus.WithError(err).Warnf("Failed to verify the delta download of %s, falling back to the full download", sp.Basename)
+					ok = false
+				}
+			}
+			if ok {
+				ev := NewEvent(EventDownloadComplete, sp)
+				ev.URL, ev.Duration = deltaURL, time.Since(begin).Seconds()
+				setProvenance(&ev, cache, sp.Sum())
+				if ev.Size, err = cache.BlobSize(sp.Sum()); err != nil {
+					logrus.WithError(err).Debugf("Failed to get the size of %q (%q)", sp.Sum(), sp.Basename)
+				}
+				emit(ev)
+				res.PackagesToBeInstalled = append(res.PackagesToBeInstalled, *sp)
+				tracker.Increment()
+				continue
+			}
+		}
+		providers := providers
+		if opts.ProviderHealth != nil {
+			providers = opts.ProviderHealth.Sort(providers)
+		}
+		var (
+			mismatches  mismatchList
+			downloadErr error // The error of the last provider that was tried
+			urlErr      error // The error of the first provider that could not determine the URL
+			downloaded  bool
+		)
 		for j, provider := range providers {
 			u, err := sp.URL(provider)
 			if err != nil {
-				return nil, fmt.Errorf("failed to determine the URL of %v with the provider %q", sp, provider)
+				// e.g., the provider needs {{.CID}} but the hash file lacks CIDs
+				logrus.WithError(err).Debugf("Skipping the provider %q for %s", provider, sp.Basename)
+				if urlErr == nil {
+					urlErr = fmt.Errorf("failed to determine the URL of %v with the provider %q: %w", sp, provider, err)
+				}
+				continue
 			}
 			printPackageStatus("Downloading from %s", u.Redacted())
-			if err = cache.Ensure(ctx, u, sp.SHA256); err != nil {
+			ev := NewEvent(EventDownloadStart, sp)
+			ev.URL, ev.Provider = u.Redacted(), provider
+			emit(ev)
+			begin := time.Now()
+			fileCtx, cancel := withTimeout(ctx, opts.PerFileTimeout)
+			err = ensure(fileCtx, cache, u, sp.Sum(), provider, opts)
+			if err == nil {
+				err = verifySums(cache, sp)
+			}
+			cancel()
+			elapsed := time.Since(begin)
+			canceled := ctx.Err() != nil // Canceled, or the global timeout
+			if err != nil {
+				switch {
+				case canceled && errors.Is(ctx.Err(), context.DeadlineExceeded):
+					err = fmt.Errorf("timed out after %v: %w", opts.Timeout, err)
+				case !canceled && errors.Is(fileCtx.Err(), context.DeadlineExceeded):
+					err = fmt.Errorf("timed out after %v for the file: %w", opts.PerFileTimeout, err)
+				}
+			}
+			if opts.ProviderHealth != nil && !canceled {
+				opts.ProviderHealth.Record(provider, elapsed, err)
+			}
+			if err != nil {
+				ev = NewEvent(EventError, sp)
+				ev.URL, ev.Provider, ev.Error = u.Redacted(), provider, err.Error()
+				emit(ev)
+				mismatches.add(err)
+				downloadErr = fmt.Errorf("failed to download %s (%s): %w", sp.Basename, u.Redacted(), err)
+				if canceled {
+					return nil, downloadErr
+				}
 				if j != len(providers)-1 {
 					logrus.WithError(err).Warnf("Failed to download %s (%s), trying the next provider", sp.Basename, u.Redacted())
-				} else {
-					return nil, fmt.Errorf("failed to download %s (%s): %w", sp.Basename, u.Redacted(), err)
 				}
 			} else {
+				ev = NewEvent(EventDownloadComplete, sp)
+				ev.URL, ev.Provider, ev.Duration = u.Redacted(), provider, elapsed.Seconds()
+				setProvenance(&ev, cache, sp.Sum())
+				if ev.Size, err = cache.BlobSize(sp.Sum()); err != nil {
+					logrus.WithError(err).Debugf("Failed to get the size of %q (%q)", sp.Sum(), sp.Basename)
+				}
+				emit(ev)
+				mismatches.report(ctx, cache, sp)
+				downloaded = true
 				break
 			}
 		}
+		if !downloaded {
+			if downloadErr != nil {
+				return nil, downloadErr
+			}
+			return nil, fmt.Errorf("no provider can determine the URL of %s: %w", sp.Basename, urlErr)
+		}
 		res.PackagesToBeInstalled = append(res.PackagesToBeInstalled, *sp)
+		tracker.Increment()
 	}
 	return &res, nil
 }
+
+// withTimeout returns a child context with the timeout, or a cancelable child context when the timeout is zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// verifySums verifies the sums of the downloaded blob other than the cache key,
+// for the hash files that contain multiple sums for the same file.
+// The blob is removed from the cache on a mismatch.
+func verifySums(c *cache.Cache, sp *filespec.FileSpec) error {
+	sums := sp.Sums()
+	if len(sums) < 2 {
+		return nil
+	}
+	blob, err := c.BlobAbsPath(sums[0])
+	if err != nil {
+		return err
+	}
+	for _, expected := range sums[1:] {
+		expectedDigest, err := sha256sums.ParseSum(expected)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(blob)
+		if err != nil {
+			return err
+		}
+		actual, err := sha256sums.FromReader(expectedDigest.Algorithm(), f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if actual != expectedDigest {
+			if rmErr := c.Remove(sums[0]); rmErr != nil {
+				logrus.WithError(rmErr).Warnf("Failed to remove %q", sums[0])
+			}
+			return fmt.Errorf("expected %s, got %s (the blob matched %s)", expectedDigest, actual, sums[0])
+		}
+	}
+	return nil
+}
+
+// setProvenance sets the provider and the remote cache of the EventDownloadComplete event, from the provenance recorded in the cache.
+func setProvenance(ev *Event, c *cache.Cache, sha256sum string) {
+	p, err := c.Provenance(sha256sum)
+	if err != nil {
+		logrus.WithError(err).Debugf("Failed to get the provenance of %q", sha256sum)
+		return
+	}
+	if p.Provider != "" {
+		ev.Provider = p.Provider
+	}
+	ev.Remote = p.Remote
+}
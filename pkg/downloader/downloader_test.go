@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro/none"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestDownloadEvents(t *testing.T) {
+	content := []byte("foo")
+	sp := &filespec.FileSpec{
+		Name:     "foo",
+		Basename: "foo",
+		SHA256:   digest.SHA256.FromBytes(content).Encoded(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/good/foo" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+
+	var events []Event
+	opts := Opts{
+		Providers: []string{srv.URL + "/bad/{{.Name}}", srv.URL + "/good/{{.Name}}"},
+		EventHandler: func(ev Event) {
+			events = append(events, ev)
+		},
+		Quiet: true,
+	}
+	fileSpecs := map[string]*filespec.FileSpec{sp.Name: sp}
+	_, err = Download(context.TODO(), none.New(), c, fileSpecs, opts)
+	assert.NilError(t, err)
+	var types []EventType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+		assert.Equal(t, sp.SHA256, ev.SHA256)
+		assert.Equal(t, 1, ev.Index)
+		assert.Equal(t, 1, ev.Total)
+	}
+	assert.DeepEqual(t, []EventType{EventDownloadStart, EventError, EventDownloadStart, EventDownloadComplete}, types)
+	assert.Equal(t, srv.URL+"/good/foo", events[3].URL)
+	assert.Equal(t, int64(len(content)), events[3].Size)
+	assert.Equal(t, srv.URL+"/good/{{.Name}}", events[3].Provider)
+	p, err := c.Provenance(sp.SHA256)
+	assert.NilError(t, err)
+	assert.Equal(t, srv.URL+"/good/foo", p.URL)
+	assert.Equal(t, srv.URL+"/good/{{.Name}}", p.Provider)
+
+	events = nil
+	_, err = Download(context.TODO(), none.New(), c, fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, EventCached, events[0].Type)
+}
+
+func TestDownloadLastProviderWithoutURL(t *testing.T) {
+	sp := &filespec.FileSpec{
+		Name:     "foo",
+		Basename: "foo",
+		SHA256:   digest.SHA256.FromBytes([]byte("foo")).Encoded(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tampered"))
+	}))
+	defer srv.Close()
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	fileSpecs := map[string]*filespec.FileSpec{sp.Name: sp}
+
+	// The last provider needs {{.CID}}, but the file spec lacks the CID
+	opts := Opts{
+		Providers: []string{srv.URL + "/{{.Name}}", "https://ipfs.example.com/ipfs/{{.CID}}"},
+		Quiet:     true,
+	}
+	_, err = Download(context.TODO(), none.New(), c, fileSpecs, opts)
+	var mm *cache.MismatchError
+	assert.Assert(t, errors.As(err, &mm), "%v", err)
+	assert.ErrorContains(t, err, "failed to download foo ("+srv.URL+"/foo)")
+
+	opts.Providers = opts.Providers[1:]
+	_, err = Download(context.TODO(), none.New(), c, fileSpecs, opts)
+	assert.ErrorContains(t, err, "no provider can determine the URL")
+}
+
+func TestDownloadLocalDirectory(t *testing.T) {
+	content := []byte("foo")
+	sp, err := filespec.New("pool/main/f/foo/foo_1%3a2.0-1_amd64.deb", digest.SHA256.FromBytes(content).Encoded())
+	assert.NilError(t, err)
+	emptyDir, mirrorDir := t.TempDir(), t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(mirrorDir, "pool/main/f/foo"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(mirrorDir, sp.Name), content, 0644))
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+
+	var events []Event
+	opts := Opts{
+		Providers: []string{emptyDir + "/{{.Name}}", "file://" + mirrorDir + "/{{.Name}}"},
+		EventHandler: func(ev Event) {
+			events = append(events, ev)
+		},
+		Quiet: true,
+	}
+	_, err = Download(context.TODO(), none.New(), c, map[string]*filespec.FileSpec{sp.Name: sp}, opts)
+	assert.NilError(t, err)
+	last := events[len(events)-1]
+	assert.Equal(t, EventDownloadComplete, last.Type)
+	u, err := url.Parse(last.URL)
+	assert.NilError(t, err)
+	assert.Equal(t, filepath.Join(mirrorDir, sp.Name), u.Path)
+	cached, err := c.Cached(sp.SHA256)
+	assert.NilError(t, err)
+	assert.Assert(t, cached)
+}
+
+func TestDownloadTimeout(t *testing.T) {
+	content := []byte("foo")
+	sp := &filespec.FileSpec{
+		Name:     "foo",
+		Basename: "foo",
+		SHA256:   digest.SHA256.FromBytes(content).Encoded(),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hung/foo" {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+	fileSpecs := map[string]*filespec.FileSpec{sp.Name: sp}
+
+	t.Run("per-file", func(t *testing.T) {
+		c, err := cache.New(t.TempDir())
+		assert.NilError(t, err)
+		var events []Event
+		opts := Opts{
+			Providers:      []string{srv.URL + "/hung/{{.Name}}", srv.URL + "/good/{{.Name}}"},
+			PerFileTimeout: 100 * time.Millisecond,
+			EventHandler: func(ev Event) {
+				events = append(events, ev)
+			},
+			Quiet: true,
+		}
+		_, err = Download(context.TODO(), none.New(), c, fileSpecs, opts)
+		assert.NilError(t, err)
+		assert.Equal(t, 4, len(events))
+		assert.Equal(t, EventError, events[1].Type)
+		assert.ErrorContains(t, errors.New(events[1].Error), "timed out after 100ms for the file")
+		assert.Equal(t, EventDownloadComplete, events[3].Type)
+	})
+
+	t.Run("global", func(t *testing.T) {
+		c, err := cache.New(t.TempDir())
+		assert.NilError(t, err)
+		opts := Opts{
+			Providers: []string{srv.URL + "/hung/{{.Name}}", srv.URL + "/good/{{.Name}}"},
+			Timeout:   100 * time.Millisecond,
+			Quiet:     true,
+		}
+		_, err = Download(context.TODO(), none.New(), c, fileSpecs, opts)
+		assert.ErrorContains(t, err, "timed out after 100ms")
+		cached, err := c.Cached(sp.SHA256)
+		assert.NilError(t, err)
+		assert.Assert(t, !cached)
+	})
+}
+
+func TestDownloadMultipleSums(t *testing.T) {
+	content := []byte("foo")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	opts := Opts{
+		Providers: []string{srv.URL + "/{{.Name}}"},
+		Quiet:     true,
+	}
+	sha512sum := digest.SHA512.FromBytes(content).String()
+
+	// SHA512 matches, but SHA256 does not
+	sp, err := filespec.New("foo", sha512sum)
+	assert.NilError(t, err)
+	assert.NilError(t, sp.AddSum(digest.SHA256.FromString("bar").Encoded()))
+	assert.Equal(t, sha512sum, sp.Sum())
+	_, err = Download(context.TODO(), none.New(), c, map[string]*filespec.FileSpec{sp.Name: sp}, opts)
+	assert.ErrorContains(t, err, "expected sha256:")
+	cached, err := c.Cached(sha512sum)
+	assert.NilError(t, err)
+	assert.Assert(t, !cached)
+
+	assert.NilError(t, sp.AddSum(digest.SHA256.FromBytes(content).Encoded()))
+	_, err = Download(context.TODO(), none.New(), c, map[string]*filespec.FileSpec{sp.Name: sp}, opts)
+	assert.NilError(t, err)
+	cached, err = c.Cached(sha512sum)
+	assert.NilError(t, err)
+	assert.Assert(t, cached)
+}
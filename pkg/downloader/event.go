@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// EventType is the type of Event.
+type EventType string
+
+const (
+	// EventDownloadStart is emitted when a file starts to be downloaded from a provider.
+	EventDownloadStart EventType = "download-start"
+	// EventDownloadComplete is emitted when a file has been downloaded and verified.
+	EventDownloadComplete EventType = "download-complete"
+	// EventCached is emitted when a file is already cached.
+	EventCached EventType = "cached"
+	// EventInstalled is emitted when a package is already installed, or has been installed.
+	EventInstalled EventType = "installed"
+	// EventError is emitted when a file failed to be downloaded from a provider.
+	EventError EventType = "error"
+)
+
+// Event is a progress event.
+type Event struct {
+	Time     time.Time `json:"Time"`
+	Type     EventType `json:"Type"`
+	Name     string    `json:"Name"`               // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	SHA256   string    `json:"SHA256"`             // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", empty when only SHA512 or BLAKE3 is known
+	SHA512   string    `json:"SHA512,omitempty"`   // Set instead of SHA256, for the SHA512 entries of the hash file
+	BLAKE3   string    `json:"BLAKE3,omitempty"`   // Set instead of SHA256, for the BLAKE3 entries of the hash file
+	Index    int       `json:"Index,omitempty"`    // 1-based index of the file, not set for EventInstalled emitted after the installation
+	Total    int       `json:"Total,omitempty"`    // The number of the files
+	URL      string    `json:"URL,omitempty"`      // Redacted
+	Provider string    `json:"Provider,omitempty"` // The provider template, not set for EventCached and EventInstalled
+	Remote   string    `json:"Remote,omitempty"`   // Set for EventDownloadComplete, when the file was fetched from the remote cache instead of URL
+	Size     int64     `json:"Size,omitempty"`     // Set for EventDownloadComplete
+	Duration float64   `json:"Duration,omitempty"` // In seconds, set for EventDownloadComplete
+	Error    string    `json:"Error,omitempty"`    // Set for EventError
+}
+
+// EventHandler handles the progress events.
+type EventHandler func(Event)
+
+// NewEvent returns the event for the file.
+func NewEvent(typ EventType, sp *filespec.FileSpec) Event {
+	return Event{
+		Time:   time.Now(),
+		Type:   typ,
+		Name:   sp.Name,
+		SHA256: sp.SHA256,
+		SHA512: sp.SHA512,
+		BLAKE3: sp.BLAKE3,
+	}
+}
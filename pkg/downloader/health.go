@@ -0,0 +1,143 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+)
+
+// ProviderHealthRelPath is the file in the primary cache dir for persisting the provider health.
+const ProviderHealthRelPath = "providers.json"
+
+// healthAlpha is the weight of the latest observation in the exponentially weighted moving averages.
+const healthAlpha = 0.2
+
+// ProviderHealth records the success rate and the latency of the providers.
+type ProviderHealth struct {
+	mu        sync.Mutex
+	Providers map[string]*ProviderStats `json:"Providers"` // key: provider string
+}
+
+// ProviderStats is the health of a provider.
+type ProviderStats struct {
+	Successes   int64         `json:"Successes"`
+	Failures    int64         `json:"Failures"`
+	FailureRate float64       `json:"FailureRate"` // Exponentially weighted moving average
+	Latency     time.Duration `json:"Latency"`     // Exponentially weighted moving average of the successful downloads
+}
+
+func NewProviderHealth() *ProviderHealth {
+	return &ProviderHealth{
+		Providers: make(map[string]*ProviderStats),
+	}
+}
+
+// LoadProviderHealth loads the provider health from the primary cache dir.
+// Returns an empty ProviderHealth when the file does not exist.
+func LoadProviderHealth(c *cache.Cache) (*ProviderHealth, error) {
+	h := NewProviderHealth()
+	b, err := os.ReadFile(filepath.Join(c.Dir(), ProviderHealthRelPath)) // no need to use securejoin (const)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return h, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, h); err != nil {
+		return nil, err
+	}
+	if h.Providers == nil {
+		h.Providers = make(map[string]*ProviderStats)
+	}
+	return h, nil
+}
+
+// Save saves the provider health into the primary cache dir.
+func (h *ProviderHealth) Save(c *cache.Cache) error {
+	h.mu.Lock()
+	b, err := json.Marshal(h)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	f := filepath.Join(c.Dir(), ProviderHealthRelPath) // no need to use securejoin (const)
+	tmp := f + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f)
+}
+
+// Record records the result of a download.
+func (h *ProviderHealth) Record(provider string, d time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.Providers[provider]
+	if !ok {
+		st = &ProviderStats{}
+		h.Providers[provider] = st
+	}
+	failure := 0.0
+	if err != nil {
+		st.Failures++
+		failure = 1.0
+	} else {
+		if st.Successes == 0 {
+			st.Latency = d
+		} else {
+			st.Latency = time.Duration(healthAlpha*float64(d) + (1-healthAlpha)*float64(st.Latency))
+		}
+		st.Successes++
+	}
+	if st.Successes+st.Failures == 1 {
+		st.FailureRate = failure
+	} else {
+		st.FailureRate = healthAlpha*failure + (1-healthAlpha)*st.FailureRate
+	}
+}
+
+// Sort returns the providers sorted by the health:
+// the healthy providers in the ascending order of the latency,
+// the providers that have not been used yet, and then the unhealthy providers.
+// The original order is kept for the providers with the same health.
+func (h *ProviderHealth) Sort(providers []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	const (
+		rankHealthy = iota
+		rankUnknown
+		rankUnhealthy
+	)
+	rank := func(p string) int {
+		st, ok := h.Providers[p]
+		switch {
+		case !ok:
+			return rankUnknown
+		case st.FailureRate >= 0.5:
+			return rankUnhealthy
+		case st.Successes == 0:
+			return rankUnknown
+		default:
+			return rankHealthy
+		}
+	}
+	res := make([]string, len(providers))
+	copy(res, providers)
+	sort.SliceStable(res, func(i, j int) bool {
+		ri, rj := rank(res[i]), rank(res[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if ri == rankHealthy {
+			return h.Providers[res[i]].Latency < h.Providers[res[j]].Latency
+		}
+		return false
+	})
+	return res
+}
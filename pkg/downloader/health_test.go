@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"gotest.tools/v3/assert"
+)
+
+func TestProviderHealth(t *testing.T) {
+	h := NewProviderHealth()
+	providers := []string{"dead", "slow", "unknown", "fast"}
+	assert.DeepEqual(t, providers, h.Sort(providers))
+
+	errDead := errors.New("dead")
+	for i := 0; i < 3; i++ {
+		h.Record("dead", time.Millisecond, errDead)
+		h.Record("slow", 3*time.Second, nil)
+		h.Record("fast", 100*time.Millisecond, nil)
+	}
+	assert.DeepEqual(t, []string{"fast", "slow", "unknown", "dead"}, h.Sort(providers))
+
+	// The dead provider recovers
+	for i := 0; i < 5; i++ {
+		h.Record("dead", 10*time.Millisecond, nil)
+	}
+	assert.DeepEqual(t, []string{"dead", "fast", "slow", "unknown"}, h.Sort(providers))
+	assert.Equal(t, int64(3), h.Providers["dead"].Failures)
+	assert.Equal(t, int64(5), h.Providers["dead"].Successes)
+
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	assert.NilError(t, h.Save(c))
+	loaded, err := LoadProviderHealth(c)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, h.Sort(providers), loaded.Sort(providers))
+	assert.DeepEqual(t, *h.Providers["slow"], *loaded.Providers["slow"])
+}
@@ -0,0 +1,176 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// ManifestEntry is an entry of the download manifest.
+type ManifestEntry struct {
+	Name   string   `json:"Name"`             // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	SHA256 string   `json:"SHA256"`           // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", empty when only SHA512 or BLAKE3 is known
+	SHA512 string   `json:"SHA512,omitempty"` // Set instead of SHA256, for the SHA512 entries of the hash file
+	BLAKE3 string   `json:"BLAKE3,omitempty"` // Set instead of SHA256, for the BLAKE3 entries of the hash file
+	URLs   []string `json:"URLs"`             // Redacted, in the order of the providers
+}
+
+// checksum returns the checksum of the entry, as SHA256, SHA512, or BLAKE3.
+func (ent ManifestEntry) checksum() (alg, encoded string) {
+	switch {
+	case ent.SHA256 != "":
+	case ent.SHA512 != "":
+		return "sha512", ent.SHA512
+	case ent.BLAKE3 != "":
+		return "blake3", ent.BLAKE3
+	}
+	return "sha256", ent.SHA256
+}
+
+// ManifestFormat is the format of the download manifest.
+type ManifestFormat string
+
+const (
+	// ManifestFormatText is the "<SHA256>  <URL>" lines (or "<SHA512>  <URL>", "blake3:<BLAKE3>  <URL>").
+	ManifestFormatText ManifestFormat = "text"
+	// ManifestFormatAria2c is the input file for "aria2c --input-file".
+	ManifestFormatAria2c ManifestFormat = "aria2c"
+	// ManifestFormatCurl is a shell script that runs curl and sha256sum (or sha512sum, b3sum).
+	ManifestFormatCurl ManifestFormat = "curl"
+)
+
+// ManifestFormats is the list of the supported manifest formats.
+var ManifestFormats = []ManifestFormat{ManifestFormatText, ManifestFormatAria2c, ManifestFormatCurl}
+
+// Manifest resolves the URLs of the files that are not cached yet, without downloading them.
+// Only the HTTP(S) and FTP URLs are resolved, as the manifest is meant to be consumed by external fetchers.
+func Manifest(d distro.Distro, c *cache.Cache, fileSpecs map[string]*filespec.FileSpec, opts Opts) ([]ManifestEntry, error) {
+	if d == nil {
+		return nil, errors.New("distro driver needs to be specified")
+	}
+	if c == nil {
+		return nil, errors.New("cache needs to be specified")
+	}
+	providers := opts.Providers
+	if len(providers) == 0 {
+		providers = d.Info().DefaultProviders
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("provider needs to be specified")
+	}
+
+	var fnames []string
+	for f := range fileSpecs {
+		if filespec.ParsePseudoFilename(f) == nil {
+			fnames = append(fnames, f)
+		}
+	}
+	sort.Strings(fnames)
+	var res []ManifestEntry
+	for _, fname := range fnames {
+		sp := fileSpecs[fname]
+		cached, err := c.Cached(sp.Sum())
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to check whether %q (%q) is cached", sp.Sum(), sp.Basename)
+		} else if cached {
+			logrus.Debugf("Skipping %q (Already cached)", sp.Name)
+			continue
+		}
+		ent := ManifestEntry{Name: sp.Name, SHA256: sp.SHA256, SHA512: sp.SHA512, BLAKE3: sp.BLAKE3}
+		for _, provider := range providers {
+			u, err := sp.URL(provider)
+			if err != nil {
+				logrus.WithError(err).Debugf("Skipping the provider %q for %s", provider, sp.Basename)
+				continue
+			}
+			switch u.Scheme {
+			case "http", "https", "ftp":
+				ent.URLs = append(ent.URLs, u.Redacted())
+			default:
+				logrus.Debugf("Skipping the provider %q for %s (unsupported by the external fetchers)", provider, sp.Basename)
+			}
+		}
+		if len(ent.URLs) == 0 {
+			return res, fmt.Errorf("no HTTP(S) provider is available for %q", sp.Name)
+		}
+		res = append(res, ent)
+	}
+	return res, nil
+}
+
+// WriteManifest writes the manifest in the format.
+func WriteManifest(w io.Writer, entries []ManifestEntry, format ManifestFormat) error {
+	var b strings.Builder
+	switch format {
+	case ManifestFormatText:
+		for _, ent := range entries {
+			alg, sum := ent.checksum()
+			if alg == "blake3" {
+				// Not distinguishable from SHA256 without the prefix
+				sum = sha256sums.BLAKE3Prefix + sum
+			}
+			for _, u := range ent.URLs {
+				fmt.Fprintf(&b, "%s  %s\n", sum, u)
+			}
+		}
+	case ManifestFormatAria2c:
+		// https://aria2.github.io/manual/en/html/aria2c.html#input-file
+		for _, ent := range entries {
+			alg, sum := ent.checksum()
+			if alg == "blake3" {
+				// aria2c does not support BLAKE3, so the checksum has to be verified separately (e.g., with b3sum)
+				fmt.Fprintf(&b, "%s\n  out=%s\n", strings.Join(ent.URLs, "\t"), ent.Name)
+				continue
+			}
+			fmt.Fprintf(&b, "%s\n  out=%s\n  checksum=%s=%s\n", strings.Join(ent.URLs, "\t"), ent.Name, strings.Replace(alg, "sha", "sha-", 1), sum)
+		}
+	case ManifestFormatCurl:
+		b.WriteString(`#!/bin/sh
+# Fetches the files into the current directory.
+# The files can be imported into the cache with 'repro-get cache import DIR'.
+set -eu
+fetch() {
+	alg="$1"
+	sum="$2"
+	out="$3"
+	shift 3
+	check="${alg}sum -c --status"
+	if [ "$alg" = blake3 ]; then
+		check="b3sum -c --quiet"
+	fi
+	for u in "$@"; do
+		if curl -fsSL --create-dirs -o "$out" "$u" && echo "$sum  $out" | $check; then
+			return 0
+		fi
+		echo "Failed to fetch $out from $u" >&2
+	done
+	rm -f "$out"
+	return 1
+}
+`)
+		for _, ent := range entries {
+			alg, sum := ent.checksum()
+			fmt.Fprintf(&b, "fetch %s %s %s", alg, shellQuote(sum), shellQuote(ent.Name))
+			for _, u := range ent.URLs {
+				b.WriteString(" " + shellQuote(u))
+			}
+			b.WriteString("\n")
+		}
+	default:
+		return fmt.Errorf("unknown manifest format %q (expected one of %v)", format, ManifestFormats)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
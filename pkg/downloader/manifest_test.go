@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro/none"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestManifest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("foo"))
+	}))
+	defer srv.Close()
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	foo, err := filespec.New("dir/foo", digest.SHA256.FromString("foo").Encoded())
+	assert.NilError(t, err)
+	bar, err := filespec.New("dir/bar's", digest.SHA256.FromString("bar").Encoded())
+	assert.NilError(t, err)
+	u, err := url.Parse(srv.URL + "/foo")
+	assert.NilError(t, err)
+	assert.NilError(t, c.Ensure(context.TODO(), u, foo.SHA256))
+
+	opts := Opts{
+		Providers: []string{"oci://ghcr.io/example/repo", "https://mirror1.example.com/{{.Name}}", "http://mirror2.example.com/{{.Basename}}"},
+	}
+	fileSpecs := map[string]*filespec.FileSpec{foo.Name: foo, bar.Name: bar}
+	entries, err := Manifest(none.New(), c, fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []ManifestEntry{
+		{
+			Name:   "dir/bar's",
+			SHA256: bar.SHA256,
+			URLs:   []string{"https://mirror1.example.com/dir/bar's", "http://mirror2.example.com/bar's"},
+		},
+	}, entries)
+
+	var b bytes.Buffer
+	assert.NilError(t, WriteManifest(&b, entries, ManifestFormatText))
+	assert.Equal(t, bar.SHA256+"  https://mirror1.example.com/dir/bar's\n"+
+		bar.SHA256+"  http://mirror2.example.com/bar's\n", b.String())
+
+	b.Reset()
+	assert.NilError(t, WriteManifest(&b, entries, ManifestFormatAria2c))
+	assert.Equal(t, "https://mirror1.example.com/dir/bar's\thttp://mirror2.example.com/bar's\n"+
+		"  out=dir/bar's\n  checksum=sha-256="+bar.SHA256+"\n", b.String())
+
+	b.Reset()
+	assert.NilError(t, WriteManifest(&b, entries, ManifestFormatCurl))
+	assert.Assert(t, bytes.HasSuffix(b.Bytes(), []byte("\nfetch sha256 '"+bar.SHA256+`' 'dir/bar'\''s' 'https://mirror1.example.com/dir/bar'\''s' 'http://mirror2.example.com/bar'\''s'`+"\n")), b.String())
+
+	assert.ErrorContains(t, WriteManifest(&b, entries, "wget"), "unknown manifest format")
+
+	_, err = Manifest(none.New(), c, fileSpecs, Opts{Providers: []string{"oci://ghcr.io/example/repo"}})
+	assert.ErrorContains(t, err, "no HTTP(S) provider")
+}
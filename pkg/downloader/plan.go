@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// PlanAction is the action of a PlanEntry.
+type PlanAction string
+
+const (
+	PlanInstalled PlanAction = "installed" // Already installed, only with Opts.SkipInstalled
+	PlanCached    PlanAction = "cached"    // Already cached
+	PlanDownload  PlanAction = "download"  // To be downloaded
+)
+
+// PlanEntry is an entry of the download plan.
+type PlanEntry struct {
+	Name   string     `json:"Name"` // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Sum    string     `json:"Sum"`  // In the format of the hash file, e.g., "sha512:<SHA512>" for SHA512
+	Action PlanAction `json:"Action"`
+	Size   int64      `json:"Size,omitempty"` // Known only for PlanCached, as the hash file does not contain the sizes
+	URLs   []PlanURL  `json:"URLs,omitempty"` // For PlanDownload, in the order to be tried
+}
+
+// PlanURL is a URL to be tried for downloading a file.
+type PlanURL struct {
+	Provider string `json:"Provider"` // Redacted
+	URL      string `json:"URL"`      // Redacted
+}
+
+// Plan returns what Download would do, without accessing the network and without modifying the cache.
+// The providers are resolved in the same order as Download, but Opts.DeltaProviders are not tried.
+func Plan(ctx context.Context, d distro.Distro, c *cache.Cache, fileSpecs map[string]*filespec.FileSpec, opts Opts) ([]PlanEntry, error) {
+	if d == nil {
+		return nil, errors.New("distro driver needs to be specified")
+	}
+	if c == nil {
+		return nil, errors.New("cache needs to be specified")
+	}
+	providers := opts.Providers
+	if len(providers) == 0 {
+		providers = d.Info().DefaultProviders
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("provider needs to be specified")
+	}
+	if opts.ProviderHealth != nil {
+		providers = opts.ProviderHealth.Sort(providers)
+	}
+
+	var fnames []string
+	for f := range fileSpecs {
+		fnames = append(fnames, f)
+	}
+	sort.Strings(fnames)
+	res := make([]PlanEntry, 0, len(fnames))
+	for _, fname := range fnames {
+		sp := fileSpecs[fname]
+		ent := PlanEntry{Name: sp.Name, Sum: sp.Sum()}
+		if opts.SkipInstalled {
+			installed, err := d.IsPackageVersionInstalled(ctx, *sp)
+			if err != nil {
+				logrus.WithError(err).Warnf("Failed to check whether installed: %q", sp.Basename)
+			} else if installed {
+				ent.Action = PlanInstalled
+				res = append(res, ent)
+				continue
+			}
+		}
+		cached, err := c.Cached(sp.Sum())
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to check whether %q (%q) is cached", sp.Sum(), sp.Basename)
+		} else if cached {
+			ent.Action = PlanCached
+			if ent.Size, err = c.BlobSize(sp.Sum()); err != nil {
+				logrus.WithError(err).Debugf("Failed to get the size of %q (%q)", sp.Sum(), sp.Basename)
+			}
+			res = append(res, ent)
+			continue
+		}
+		ent.Action = PlanDownload
+		var lastErr error
+		for _, provider := range providers {
+			u, err := sp.URL(provider)
+			if err != nil {
+				logrus.WithError(err).Debugf("Skipping the provider %q for %s", provider, sp.Basename)
+				lastErr = err
+				continue
+			}
+			ent.URLs = append(ent.URLs, PlanURL{Provider: redactProvider(provider), URL: u.Redacted()})
+		}
+		if len(ent.URLs) == 0 {
+			return res, fmt.Errorf("failed to determine the URL of %q: %w", sp.Name, lastErr)
+		}
+		res = append(res, ent)
+	}
+	return res, nil
+}
+
+// redactProvider redacts the password in the provider template, like url.URL.Redacted.
+// url.URL.Redacted is not used, as it escapes the template actions in the path.
+func redactProvider(provider string) string {
+	scheme, rest, ok := strings.Cut(provider, "://")
+	if !ok {
+		return provider
+	}
+	authority, path := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+	i := strings.LastIndex(authority, "@")
+	if i < 0 {
+		return provider
+	}
+	user, _, ok := strings.Cut(authority[:i], ":")
+	if !ok {
+		return provider
+	}
+	return scheme + "://" + user + ":xxxxx" + authority[i:] + path
+}
+
+// WritePlan writes the plan in the human-readable format.
+func WritePlan(w io.Writer, entries []PlanEntry) error {
+	var b strings.Builder
+	for _, ent := range entries {
+		switch ent.Action {
+		case PlanDownload:
+			fmt.Fprintf(&b, "%s %s\n", ent.Action, ent.Name)
+			for _, u := range ent.URLs {
+				fmt.Fprintf(&b, "  from %s (provider %q)\n", u.URL, u.Provider)
+			}
+		case PlanCached:
+			fmt.Fprintf(&b, "%s %s (%d bytes)\n", ent.Action, ent.Name, ent.Size)
+		default:
+			fmt.Fprintf(&b, "%s %s\n", ent.Action, ent.Name)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+)
+
+// ProbeResult is the result of probing a file on a provider.
+type ProbeResult struct {
+	Name      string `json:"Name"`             // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	SHA256    string `json:"SHA256"`           // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", empty when only SHA512 or BLAKE3 is known
+	SHA512    string `json:"SHA512,omitempty"` // Set instead of SHA256, for the SHA512 entries of the hash file
+	BLAKE3    string `json:"BLAKE3,omitempty"` // Set instead of SHA256, for the BLAKE3 entries of the hash file
+	Provider  string `json:"Provider"`
+	URL       string `json:"URL,omitempty"` // Redacted, empty when the provider is not applicable
+	Available bool   `json:"Available"`
+	Size      int64  `json:"Size,omitempty"` // Set when available and known
+	Error     string `json:"Error,omitempty"`
+}
+
+// ProbeOpts is the options for Probe.
+type ProbeOpts struct {
+	Providers []string
+	Jobs      int // Number of the concurrent requests, defaults to 1
+}
+
+// Probe checks whether the files are retrievable from each of the providers, without downloading them.
+// The results are sorted by the names, and then by the order of the providers.
+func Probe(ctx context.Context, d distro.Distro, o *urlopener.URLOpener, fileSpecs map[string]*filespec.FileSpec, opts ProbeOpts) ([]ProbeResult, error) {
+	if d == nil {
+		return nil, errors.New("distro driver needs to be specified")
+	}
+	if o == nil {
+		return nil, errors.New("URL opener needs to be specified")
+	}
+	providers := opts.Providers
+	if len(providers) == 0 {
+		providers = d.Info().DefaultProviders
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("provider needs to be specified")
+	}
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = 1
+	}
+
+	var fnames []string
+	for f := range fileSpecs {
+		if filespec.ParsePseudoFilename(f) == nil {
+			fnames = append(fnames, f)
+		}
+	}
+	sort.Strings(fnames)
+	res := make([]ProbeResult, len(fnames)*len(providers))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, fname := range fnames {
+		sp := fileSpecs[fname]
+		for j, provider := range providers {
+			r := &res[i*len(providers)+j]
+			*r = ProbeResult{Name: sp.Name, SHA256: sp.SHA256, SHA512: sp.SHA512, BLAKE3: sp.BLAKE3, Provider: provider}
+			u, err := sp.URL(provider)
+			if err != nil {
+				r.Error = err.Error()
+				continue
+			}
+			r.URL = u.Redacted()
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				sz, err := o.Stat(ctx, u, sp.Sum())
+				if err != nil {
+					r.Error = err.Error()
+					return
+				}
+				r.Available = true
+				if sz > 0 {
+					r.Size = sz
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	return res, ctx.Err()
+}
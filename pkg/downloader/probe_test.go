@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/distro/none"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"gotest.tools/v3/assert"
+)
+
+func TestProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror2/foo" && r.URL.Path != "/mirror2/bar" && r.URL.Path != "/mirror1/foo" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+	fileSpecs := make(map[string]*filespec.FileSpec)
+	for _, f := range []string{"foo", "bar", "baz"} {
+		sp, err := filespec.New(f, digest.SHA256.FromString(f).Encoded())
+		assert.NilError(t, err)
+		fileSpecs[f] = sp
+	}
+	opts := ProbeOpts{
+		Providers: []string{srv.URL + "/mirror1/{{.Name}}", srv.URL + "/mirror2/{{.Name}}", srv.URL + "/snapshot/{{.Snapshot}}/{{.Name}}"},
+		Jobs:      4,
+	}
+	res, err := Probe(context.TODO(), none.New(), urlopener.New(), fileSpecs, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, 9, len(res))
+	type summary struct {
+		Name, Provider string
+		Available      bool
+	}
+	var got []summary
+	for _, r := range res {
+		got = append(got, summary{r.Name, r.Provider, r.Available})
+		if r.Available {
+			assert.Equal(t, int64(len("content")), r.Size)
+		} else {
+			assert.Assert(t, r.Error != "")
+		}
+	}
+	assert.DeepEqual(t, []summary{
+		{"bar", opts.Providers[0], false},
+		{"bar", opts.Providers[1], true},
+		{"bar", opts.Providers[2], false},
+		{"baz", opts.Providers[0], false},
+		{"baz", opts.Providers[1], false},
+		{"baz", opts.Providers[2], false},
+		{"foo", opts.Providers[0], true},
+		{"foo", opts.Providers[1], true},
+		{"foo", opts.Providers[2], false},
+	}, got)
+	assert.Assert(t, strings.Contains(res[2].Error, "no snapshot is known"), res[2].Error)
+	assert.Equal(t, "", res[2].URL)
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRetryBackoff caps the exponential backoff.
+const maxRetryBackoff = time.Minute
+
+// isTransient returns true for the errors that may succeed on a retry,
+// such as HTTP 5xx, HTTP 429, and connection errors.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *urlopener.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// ensure calls c.Ensure, retrying up to opts.Retries times on transient errors.
+// The backoff starts with opts.RetryBackoff, and doubles on each retry.
+// provider is recorded in the provenance of the blob.
+func ensure(ctx context.Context, c *cache.Cache, u *url.URL, sha256sum, provider string, opts Opts) error {
+	ctx = cache.WithProvider(ctx, provider)
+	backoff := opts.RetryBackoff
+	for i := 0; ; i++ {
+		err := c.Ensure(ctx, u, sha256sum)
+		if err == nil || i >= opts.Retries || !isTransient(err) {
+			return err
+		}
+		logrus.WithError(err).Warnf("Failed to download %s, retrying in %v (%d/%d)", u.Redacted(), backoff, i+1, opts.Retries)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"gotest.tools/v3/assert"
+)
+
+func TestEnsureRetry(t *testing.T) {
+	content := []byte("foo")
+	sha256sum := digest.SHA256.FromBytes(content).Encoded()
+
+	testCase := func(t *testing.T, failures, status, retries int, expectedRequests int, expectedOK bool) {
+		var requests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests <= failures {
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			_, _ = w.Write(content)
+		}))
+		defer srv.Close()
+		u, err := url.Parse(srv.URL + "/foo")
+		assert.NilError(t, err)
+		c, err := cache.New(t.TempDir())
+		assert.NilError(t, err)
+		err = ensure(context.TODO(), c, u, sha256sum, "", Opts{Retries: retries})
+		if expectedOK {
+			assert.NilError(t, err)
+		} else {
+			assert.Assert(t, err != nil)
+		}
+		assert.Equal(t, expectedRequests, requests)
+	}
+
+	t.Run("5xx", func(t *testing.T) {
+		testCase(t, 2, http.StatusServiceUnavailable, 3, 3, true)
+	})
+	t.Run("5xx-exhausted", func(t *testing.T) {
+		testCase(t, 5, http.StatusServiceUnavailable, 2, 3, false)
+	})
+	t.Run("4xx", func(t *testing.T) {
+		testCase(t, 1, http.StatusNotFound, 3, 1, false)
+	})
+	t.Run("no-retries", func(t *testing.T) {
+		testCase(t, 1, http.StatusInternalServerError, 0, 1, false)
+	})
+}
@@ -31,3 +31,23 @@ func Split(trimmed string) (*Dpkg, error) {
 		Architecture: sp[2],
 	}, nil
 }
+
+// ArchFromOCI converts an OCI architecture string such as "arm-v7" to a dpkg architecture string such as "armhf".
+func ArchFromOCI(ociArchDashVariant string) (string, error) {
+	switch ociArchDashVariant {
+	case "amd64", "arm64", "s390x", "riscv64":
+		return ociArchDashVariant, nil
+	case "arm-v7":
+		return "armhf", nil
+	case "arm-v5", "arm-v6":
+		return "armel", nil
+	case "386":
+		return "i386", nil
+	case "ppc64le":
+		return "ppc64el", nil
+	case "mips64le":
+		return "mips64el", nil
+	default:
+		return "", fmt.Errorf("unsupported architecture %q", ociArchDashVariant)
+	}
+}
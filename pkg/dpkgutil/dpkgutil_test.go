@@ -25,3 +25,18 @@ func TestParseFilename(t *testing.T) {
 	}
 	assert.DeepEqual(t, expected, got)
 }
+
+func TestArchFromOCI(t *testing.T) {
+	for oci, expected := range map[string]string{
+		"amd64":  "amd64",
+		"arm64":  "arm64",
+		"arm-v7": "armhf",
+		"386":    "i386",
+	} {
+		got, err := ArchFromOCI(oci)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, got)
+	}
+	_, err := ArchFromOCI("wasm")
+	assert.ErrorContains(t, err, "unsupported architecture")
+}
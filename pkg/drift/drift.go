@@ -0,0 +1,141 @@
+// Package drift compares the installed packages with the packages pinned in the hash files.
+package drift
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// Kind is the kind of an Entry.
+type Kind string
+
+const (
+	KindMissing  Kind = "missing"  // Pinned in the hash file, but not installed
+	KindMismatch Kind = "mismatch" // Installed with a version that differs from the hash file
+	KindExtra    Kind = "extra"    // Installed, but not pinned in the hash file
+)
+
+// Entry is a drift of a package.
+type Entry struct {
+	Kind         Kind   `json:"Kind"`
+	Package      string `json:"Package"`                // "hello"
+	Architecture string `json:"Architecture,omitempty"` // "amd64", when known
+	Expected     string `json:"Expected,omitempty"`     // The version in the hash file, empty for KindExtra
+	Installed    string `json:"Installed,omitempty"`    // The installed version, empty for KindMissing
+	Name         string `json:"Name,omitempty"`         // The file name in the hash file, such as "pool/main/h/hello/hello_2.10-2_amd64.deb"
+}
+
+func (e Entry) String() string {
+	s := e.Package
+	if e.Architecture != "" {
+		s += ":" + e.Architecture
+	}
+	switch e.Kind {
+	case KindMissing:
+		return string(e.Kind) + " " + s + " " + e.Expected
+	case KindMismatch:
+		return string(e.Kind) + " " + s + " " + e.Expected + " -> " + e.Installed
+	default:
+		return string(e.Kind) + " " + s + " " + e.Installed
+	}
+}
+
+// Report is the result of Check.
+type Report struct {
+	Matched int     `json:"Matched"` // The number of the packages installed with the pinned versions
+	Drifts  []Entry `json:"Drifts"`  // Sorted by the package name and the architecture
+}
+
+type pinned struct {
+	pkg, version, arch, name string
+}
+
+// pinnedOf returns the package of the file spec, or false for the files that are not installable packages, such as source packages.
+func pinnedOf(sp *filespec.FileSpec) (pinned, bool) {
+	switch {
+	case sp.Dpkg != nil:
+		version := sp.Dpkg.Version
+		if v, err := url.PathUnescape(version); err == nil {
+			version = v // "1%3a2.0-1" to "1:2.0-1"
+		}
+		return pinned{pkg: sp.Dpkg.Package, version: version, arch: sp.Dpkg.Architecture, name: sp.Name}, true
+	case sp.RPM != nil:
+		return pinned{pkg: sp.RPM.Package, version: sp.RPM.Version + "-" + sp.RPM.Release, arch: sp.RPM.Architecture, name: sp.Name}, true
+	case sp.APK != nil:
+		// The architecture is not a part of the file name
+		return pinned{pkg: sp.APK.Package, version: sp.APK.Version, name: sp.Name}, true
+	}
+	return pinned{}, false
+}
+
+// Check compares the installed packages with the packages in the file specs.
+//
+// The packages are compared by the names and the architectures.
+// The architectures are not compared when they are unknown from the file names (e.g., Alpine).
+// When a package is pinned with multiple versions, any of them is accepted.
+func Check(fileSpecs map[string]*filespec.FileSpec, installed []distro.InstalledPackage) *Report {
+	byName := make(map[string][]pinned)
+	for _, sp := range fileSpecs {
+		if p, ok := pinnedOf(sp); ok {
+			byName[p.pkg] = append(byName[p.pkg], p)
+		}
+	}
+	report := &Report{Drifts: []Entry{}}
+	seen := make(map[pinned]bool)
+	for _, inst := range installed {
+		var (
+			candidates []pinned
+			matched    bool
+		)
+		for _, p := range byName[inst.Package] {
+			if p.arch != "" && p.arch != inst.Architecture {
+				continue
+			}
+			candidates = append(candidates, p)
+			if p.version == inst.Version {
+				matched = true
+			}
+		}
+		for _, p := range candidates {
+			seen[p] = true
+		}
+		switch {
+		case matched:
+			report.Matched++
+		case len(candidates) == 0:
+			report.Drifts = append(report.Drifts, Entry{Kind: KindExtra, Package: inst.Package, Architecture: inst.Architecture, Installed: inst.Version})
+		default:
+			var expected, names []string
+			for _, p := range candidates {
+				expected = append(expected, p.version)
+				names = append(names, p.name)
+			}
+			sort.Strings(expected)
+			sort.Strings(names)
+			report.Drifts = append(report.Drifts, Entry{Kind: KindMismatch, Package: inst.Package, Architecture: inst.Architecture,
+				Expected: strings.Join(expected, ", "), Installed: inst.Version, Name: strings.Join(names, ", ")})
+		}
+	}
+	for _, pp := range byName {
+		for _, p := range pp {
+			if !seen[p] {
+				report.Drifts = append(report.Drifts, Entry{Kind: KindMissing, Package: p.pkg, Architecture: p.arch, Expected: p.version, Name: p.name})
+			}
+		}
+	}
+	sort.SliceStable(report.Drifts, func(i, j int) bool {
+		a, b := report.Drifts[i], report.Drifts[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Architecture != b.Architecture {
+			return a.Architecture < b.Architecture
+		}
+		return a.Kind < b.Kind
+	})
+	return report
+}
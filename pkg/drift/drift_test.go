@@ -0,0 +1,90 @@
+package drift
+
+import (
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheck(t *testing.T) {
+	fileSpecs, err := filespec.NewFromSHA256SUMS(map[string]string{
+		"pool/main/h/hello/hello_2.10-2_amd64.deb":                        "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		"pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb":                   "f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377",
+		"pool/main/b/base-files/base-files_11.1+deb11u5_all.deb":          "1111111111111111111111111111111111111111111111111111111111111111",
+		"pool/main/s/systemd/libsystemd0_247.3-7+deb11u1_amd64.deb":       "2222222222222222222222222222222222222222222222222222222222222222",
+		"pool/main/p/perl/perl-base_5.32.1-4+deb11u2_amd64.deb":           "3333333333333333333333333333333333333333333333333333333333333333",
+		"pool/main/g/glibc/glibc_2.31-13+deb11u5.dsc":                     "4444444444444444444444444444444444444444444444444444444444444444",
+		"pool/main/libs/libseccomp/libseccomp2_2.5.1-1+deb11u1_amd64.deb": "5555555555555555555555555555555555555555555555555555555555555555",
+		"pool/main/t/tzdata/tzdata_1%3a2021a-1_all.deb":                   "6666666666666666666666666666666666666666666666666666666666666666",
+	})
+	assert.NilError(t, err)
+	installed := []distro.InstalledPackage{
+		{Package: "hello", Version: "2.10-2", Architecture: "amd64"},
+		{Package: "bash", Version: "5.1-2+deb11u1", Architecture: "amd64"},
+		{Package: "base-files", Version: "11.1+deb11u5", Architecture: "all"},
+		{Package: "libsystemd0", Version: "247.3-7+deb11u2", Architecture: "amd64"},
+		{Package: "perl-base", Version: "5.32.1-4+deb11u2", Architecture: "i386"},
+		{Package: "tzdata", Version: "1:2021a-1", Architecture: "all"},
+		{Package: "curl", Version: "7.74.0-1.3+deb11u7", Architecture: "amd64"},
+	}
+	report := Check(fileSpecs, installed)
+	assert.Equal(t, 4, report.Matched)
+	var got []string
+	for _, e := range report.Drifts {
+		got = append(got, e.String())
+	}
+	assert.DeepEqual(t, []string{
+		"extra curl:amd64 7.74.0-1.3+deb11u7",
+		"missing libseccomp2:amd64 2.5.1-1+deb11u1",
+		"mismatch libsystemd0:amd64 247.3-7+deb11u1 -> 247.3-7+deb11u2",
+		"missing perl-base:amd64 5.32.1-4+deb11u2",
+		"extra perl-base:i386 5.32.1-4+deb11u2",
+	}, got)
+	assert.Equal(t, "pool/main/s/systemd/libsystemd0_247.3-7+deb11u1_amd64.deb", report.Drifts[2].Name)
+}
+
+func TestCheckAPK(t *testing.T) {
+	fileSpecs, err := filespec.NewFromSHA256SUMS(map[string]string{
+		"v3.16/main/x86_64/ca-certificates-bundle-20220614-r0.apk": "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		"v3.16/main/x86_64/busybox-1.35.0-r17.apk":                 "f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377",
+	})
+	assert.NilError(t, err)
+	installed := []distro.InstalledPackage{
+		{Package: "ca-certificates-bundle", Version: "20220614-r0", Architecture: "x86_64"},
+		{Package: "busybox", Version: "1.35.0-r18", Architecture: "x86_64"},
+	}
+	report := Check(fileSpecs, installed)
+	assert.Equal(t, 1, report.Matched)
+	assert.DeepEqual(t, []Entry{
+		{Kind: KindMismatch, Package: "busybox", Architecture: "x86_64", Expected: "1.35.0-r17", Installed: "1.35.0-r18", Name: "v3.16/main/x86_64/busybox-1.35.0-r17.apk"},
+	}, report.Drifts)
+}
+
+func TestRollback(t *testing.T) {
+	old, err := filespec.NewFromSHA256SUMS(map[string]string{
+		"pool/main/h/hello/hello_2.10-2_amd64.deb":                  "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		"pool/main/s/systemd/libsystemd0_247.3-7+deb11u1_amd64.deb": "2222222222222222222222222222222222222222222222222222222222222222",
+		"pool/main/g/glibc/glibc_2.31-13+deb11u5.dsc":               "4444444444444444444444444444444444444444444444444444444444444444",
+	})
+	assert.NilError(t, err)
+	current, err := filespec.NewFromSHA256SUMS(map[string]string{
+		"pool/main/h/hello/hello_2.10-2_amd64.deb":                  "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		"pool/main/s/systemd/libsystemd0_247.3-7+deb11u2_amd64.deb": "3333333333333333333333333333333333333333333333333333333333333333",
+		"pool/main/c/curl/curl_7.74.0-1.3+deb11u7_amd64.deb":        "5555555555555555555555555555555555555555555555555555555555555555",
+	})
+	assert.NilError(t, err)
+	installed := []distro.InstalledPackage{
+		{Package: "hello", Version: "2.10-2", Architecture: "amd64"},
+		{Package: "libsystemd0", Version: "247.3-7+deb11u2", Architecture: "amd64"},
+		{Package: "curl", Version: "7.74.0-1.3+deb11u7", Architecture: "amd64"},
+		{Package: "bash", Version: "5.1-2+deb11u1", Architecture: "amd64"},
+	}
+	plan := Rollback(old, current, installed)
+	assert.Equal(t, 1, len(plan.Install))
+	assert.Equal(t, "pool/main/s/systemd/libsystemd0_247.3-7+deb11u1_amd64.deb", plan.Install[0].Name)
+	assert.DeepEqual(t, []distro.InstalledPackage{installed[2]}, plan.Remove)
+
+	assert.DeepEqual(t, []distro.InstalledPackage{installed[0], installed[1], installed[2]}, Pinned(current, installed))
+}
@@ -0,0 +1,81 @@
+package drift
+
+import (
+	"sort"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// matches returns true when the installed package has the name and the architecture of the pinned package.
+// The architecture is not compared when it is unknown from the file name.
+func (p pinned) matches(inst distro.InstalledPackage) bool {
+	return p.pkg == inst.Package && (p.arch == "" || p.arch == inst.Architecture)
+}
+
+func pinnedList(fileSpecs map[string]*filespec.FileSpec) []pinned {
+	var res []pinned
+	for _, sp := range fileSpecs {
+		if p, ok := pinnedOf(sp); ok {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// Pinned returns the installed packages that are pinned in the file specs with the installed versions.
+func Pinned(fileSpecs map[string]*filespec.FileSpec, installed []distro.InstalledPackage) []distro.InstalledPackage {
+	pp := pinnedList(fileSpecs)
+	var res []distro.InstalledPackage
+	for _, inst := range installed {
+		for _, p := range pp {
+			if p.matches(inst) && p.version == inst.Version {
+				res = append(res, inst)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// RollbackPlan is the result of Rollback.
+type RollbackPlan struct {
+	Install []filespec.FileSpec       // The packages to be installed or downgraded, sorted by the file names
+	Remove  []distro.InstalledPackage // The packages to be removed
+}
+
+// Rollback returns the plan for reverting the installed packages to the old file specs.
+//
+// The packages pinned in the old file specs are installed, unless they are already installed with the pinned versions.
+// The installed packages that are pinned in the current file specs but not in the old file specs are removed.
+// The other installed packages are kept as they are.
+func Rollback(old, current map[string]*filespec.FileSpec, installed []distro.InstalledPackage) *RollbackPlan {
+	oldPinned, curPinned := pinnedList(old), pinnedList(current)
+	plan := &RollbackPlan{}
+	for _, p := range oldPinned {
+		var ok bool
+		for _, inst := range installed {
+			if p.matches(inst) && p.version == inst.Version {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			plan.Install = append(plan.Install, *old[p.name])
+		}
+	}
+	for _, inst := range installed {
+		var inOld, inCur bool
+		for _, p := range oldPinned {
+			inOld = inOld || p.matches(inst)
+		}
+		for _, p := range curPinned {
+			inCur = inCur || p.matches(inst)
+		}
+		if inCur && !inOld {
+			plan.Remove = append(plan.Remove, inst)
+		}
+	}
+	return plan
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -41,3 +42,29 @@ func Bool(envName string, defaultValue bool) bool {
 	}
 	return b
 }
+
+func Int(envName string, defaultValue int) int {
+	v, ok := os.LookupEnv(envName)
+	if !ok {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to parse %q ($%s) as an integer", v, envName)
+		return defaultValue
+	}
+	return i
+}
+
+func Duration(envName string, defaultValue time.Duration) time.Duration {
+	v, ok := os.LookupEnv(envName)
+	if !ok {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to parse %q ($%s) as a duration", v, envName)
+		return defaultValue
+	}
+	return d
+}
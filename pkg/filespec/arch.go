@@ -0,0 +1,73 @@
+package filespec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+)
+
+// DirectiveArch is the key of the hash file directive that begins the section for the architecture,
+// in a multi-architecture hash file, such as "#repro-get:arch=arm64".
+// The value is an OCI architecture with variant, such as "amd64", "arm64", and "arm-v7".
+//
+// The section continues until the next arch directive.
+// The entries before the first arch directive are common to all the architectures.
+const DirectiveArch = "arch"
+
+// ArchSection is a section of a multi-architecture hash file.
+type ArchSection struct {
+	Arch    string // Empty for the common section
+	Content []byte // The lines of the section, excluding the arch directive
+	Line    int    // The 1-based line number of the first line of Content
+}
+
+// SplitArchSections splits the content of a hash file into the sections.
+// The first section is always the common section, which may be empty.
+// An architecture may have multiple sections.
+func SplitArchSections(b []byte) ([]ArchSection, error) {
+	sections := []ArchSection{{Line: 1}}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for i := 1; sc.Scan(); i++ {
+		line := sc.Text()
+		if k, v, ok := sha256sums.ParseDirective(line); ok && k == DirectiveArch {
+			if err := ValidateArch(v); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i, err)
+			}
+			sections = append(sections, ArchSection{Arch: v, Line: i + 1})
+			continue
+		}
+		cur := &sections[len(sections)-1]
+		cur.Content = append(append(cur.Content, line...), '\n')
+	}
+	return sections, sc.Err()
+}
+
+// ValidateArch validates the architecture of an arch directive.
+func ValidateArch(arch string) error {
+	if arch == "" || strings.ContainsAny(arch, " \t/_:") || strings.ToLower(arch) != arch {
+		return fmt.Errorf("invalid architecture %q (expected a string like \"amd64\", \"arm64\", and \"arm-v7\")", arch)
+	}
+	return nil
+}
+
+// FilterByArch returns the content of the hash file, only with the common section and the sections for arch.
+// The content is returned as is when it has no arch directive.
+func FilterByArch(b []byte, arch string) ([]byte, error) {
+	sections, err := SplitArchSections(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) == 1 {
+		return b, nil
+	}
+	var res []byte
+	for _, s := range sections {
+		if s.Arch == "" || s.Arch == arch {
+			res = append(res, s.Content...)
+		}
+	}
+	return res, nil
+}
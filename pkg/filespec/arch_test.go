@@ -0,0 +1,51 @@
+package filespec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const testMultiArchSHA256SUMS = `#repro-get:snapshot=20221101T000000Z
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+#repro-get:arch=amd64
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+#repro-get:arch=arm64
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_arm64.deb
+`
+
+func TestSplitArchSections(t *testing.T) {
+	sections, err := SplitArchSections([]byte(testMultiArchSHA256SUMS))
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(sections))
+	assert.Equal(t, "", sections[0].Arch)
+	assert.Equal(t, 1, sections[0].Line)
+	assert.Equal(t, "amd64", sections[1].Arch)
+	assert.Equal(t, 4, sections[1].Line)
+	assert.Equal(t, "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb\n", string(sections[1].Content))
+	assert.Equal(t, "arm64", sections[2].Arch)
+
+	_, err = SplitArchSections([]byte("#repro-get:arch=\n"))
+	assert.ErrorContains(t, err, "line 1: invalid architecture")
+}
+
+func TestNewFromSHA256SUMSFilesForArch(t *testing.T) {
+	hashFile := filepath.Join(t.TempDir(), "SHA256SUMS")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(testMultiArchSHA256SUMS), 0644))
+
+	got, err := NewFromSHA256SUMSFilesForArch("arm64", hashFile)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, "20221101T000000Z", got["pool/main/b/base-files/base-files_11.1+deb11u5_all.deb"].Snapshot)
+	assert.Equal(t, "20221101T000000Z", got["pool/main/h/hello/hello_2.10-2_arm64.deb"].Snapshot)
+
+	got, err = NewFromSHA256SUMSFilesForArch("riscv64", hashFile)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(got))
+
+	got, err = NewFromSHA256SUMSFiles(hashFile)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(got))
+}
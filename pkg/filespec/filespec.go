@@ -1,19 +1,29 @@
 package filespec
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 	"github.com/reproducible-containers/repro-get/pkg/apkutil"
+	"github.com/reproducible-containers/repro-get/pkg/cargoutil"
 	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
-	"github.com/reproducible-containers/repro-get/pkg/ioutilx"
+	"github.com/reproducible-containers/repro-get/pkg/gomodutil"
+	"github.com/reproducible-containers/repro-get/pkg/mavenutil"
+	"github.com/reproducible-containers/repro-get/pkg/npmutil"
 	"github.com/reproducible-containers/repro-get/pkg/rpmutil"
 	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 	"github.com/sirupsen/logrus"
@@ -38,7 +48,9 @@ func ValidateName(name string) error {
 }
 
 type opts struct {
-	cid string
+	cid      string
+	snapshot string
+	ppa      *PPA
 }
 
 type Option func(o *opts)
@@ -49,7 +61,108 @@ func WithCID(cid string) Option {
 	}
 }
 
-func New(name, sha256 string, options ...Option) (*FileSpec, error) {
+// WithSnapshot specifies the snapshot timestamp such as "20221101T000000Z".
+func WithSnapshot(snapshot string) Option {
+	return func(o *opts) {
+		o.snapshot = snapshot
+	}
+}
+
+// WithPPA specifies the Launchpad PPA.
+func WithPPA(ppa *PPA) Option {
+	return func(o *opts) {
+		o.ppa = ppa
+	}
+}
+
+// SnapshotLayout is the time layout of the snapshot timestamp, used by snapshot.debian.org.
+const SnapshotLayout = "20060102T150405Z"
+
+// DirectiveSnapshot is the key of the hash file directive for the snapshot timestamp.
+const DirectiveSnapshot = "snapshot"
+
+// DirectiveIndex is the key of the hash file directive for the repository index that was used for generating the hash file,
+// such as "<SHA256>  deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages".
+// This directive may appear multiple times.
+const DirectiveIndex = "index"
+
+// DirectivePPA is the key of the hash file directive for the Launchpad PPA, such as "deadsnakes/ppa".
+const DirectivePPA = "ppa"
+
+// DirectiveURL is the key of the hash file directive for the origin URL of an entry,
+// such as "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb  pool/main/h/hello/hello_2.10-2_amd64.deb".
+// This directive may appear multiple times.
+const DirectiveURL = "url"
+
+// ParseURLDirective parses the value of a url directive into the origin URL and the file name.
+func ParseURLDirective(v string) (rawURL, filename string, err error) {
+	rawURL, filename, ok := strings.Cut(v, "  ")
+	if !ok {
+		return "", "", fmt.Errorf("invalid url directive %q (expected \"<URL>  <FILENAME>\")", v)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid url directive %q: %w", v, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid url directive %q: not an absolute URL", v)
+	}
+	if err = ValidateName(filename); err != nil {
+		return "", "", fmt.Errorf("invalid url directive %q: %w", v, err)
+	}
+	return rawURL, filename, nil
+}
+
+// DirectiveSignedBy is the key of the hash file directive for the fingerprint of the OpenPGP key that signed an entry,
+// such as "115DF9AEF857853EE8445D0A0727707EA15B79CC  fedora-release/37/1/noarch/fedora-release-37-1.noarch.rpm".
+// This directive may appear multiple times.
+const DirectiveSignedBy = "signed-by"
+
+// ParseSignedByDirective parses the value of a signed-by directive into the fingerprint and the file name.
+func ParseSignedByDirective(v string) (fingerprint, filename string, err error) {
+	fingerprint, filename, ok := strings.Cut(v, "  ")
+	if !ok {
+		return "", "", fmt.Errorf("invalid signed-by directive %q (expected \"<FINGERPRINT>  <FILENAME>\")", v)
+	}
+	if b, err := hex.DecodeString(fingerprint); err != nil || (len(b) != 20 && len(b) != 32) {
+		return "", "", fmt.Errorf("invalid signed-by directive %q: invalid fingerprint", v)
+	}
+	if err = ValidateName(filename); err != nil {
+		return "", "", fmt.Errorf("invalid signed-by directive %q: %w", v, err)
+	}
+	return strings.ToUpper(fingerprint), filename, nil
+}
+
+// PPA is a Launchpad Personal Package Archive.
+type PPA struct {
+	Owner string `json:"Owner"` // "deadsnakes"
+	Name  string `json:"Name"`  // "ppa"
+}
+
+func (ppa PPA) String() string {
+	return ppa.Owner + "/" + ppa.Name
+}
+
+// ParsePPA parses a PPA string such as "deadsnakes/ppa" and "ppa:deadsnakes/ppa".
+func ParsePPA(s string) (*PPA, error) {
+	owner, name, ok := strings.Cut(strings.TrimPrefix(s, "ppa:"), "/")
+	if !ok || owner == "" || name == "" || strings.ContainsAny(name, "/_") || strings.Contains(owner, "_") {
+		return nil, fmt.Errorf("invalid PPA %q (expected a string like \"deadsnakes/ppa\")", s)
+	}
+	return &PPA{Owner: owner, Name: name}, nil
+}
+
+// ValidateSnapshot validates the snapshot timestamp such as "20221101T000000Z".
+func ValidateSnapshot(snapshot string) error {
+	if _, err := time.Parse(SnapshotLayout, snapshot); err != nil {
+		return fmt.Errorf("invalid snapshot timestamp %q (expected a string like \"20221101T000000Z\"): %w", snapshot, err)
+	}
+	return nil
+}
+
+// New returns a file spec.
+// sum is a SHA256 sum, or a SHA512 or BLAKE3 sum prefixed with "sha512:" or "blake3:" (see sha256sums.ParseSum).
+func New(name, sum string, options ...Option) (*FileSpec, error) {
 	var opts opts
 	for _, o := range options {
 		o(&opts)
@@ -57,14 +170,20 @@ func New(name, sha256 string, options ...Option) (*FileSpec, error) {
 	if err := ValidateName(name); err != nil {
 		return nil, err
 	}
-	if err := digest.SHA256.Validate(sha256); err != nil {
-		return nil, err
+	if opts.snapshot != "" {
+		if err := ValidateSnapshot(opts.snapshot); err != nil {
+			return nil, err
+		}
 	}
 	sp := &FileSpec{
 		Name:     name,
 		Basename: filepath.Base(name),
-		SHA256:   sha256,
 		CID:      opts.cid,
+		Snapshot: opts.snapshot,
+		PPA:      opts.ppa,
+	}
+	if err := sp.AddSum(sum); err != nil {
+		return nil, err
 	}
 	switch {
 	case strings.HasSuffix(name, ".deb"):
@@ -85,28 +204,120 @@ func New(name, sha256 string, options ...Option) (*FileSpec, error) {
 			return sp, err
 		}
 		sp.APK = apk
+	case strings.HasSuffix(name, ".tgz") && strings.Contains(name, "/-/"):
+		npm, err := npmutil.ParseFilename(name)
+		if err != nil {
+			return sp, err
+		}
+		sp.NPM = npm
+	case (strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".mod")) && strings.Contains(name, "/@v/"):
+		goMod, err := gomodutil.ParseFilename(name)
+		if err != nil {
+			return sp, err
+		}
+		sp.GoMod = goMod
+	case strings.HasSuffix(name, ".crate"):
+		crate, err := cargoutil.ParseFilename(name)
+		if err != nil {
+			return sp, err
+		}
+		sp.Crate = crate
+	case mavenutil.HasExtension(name):
+		// Not an error, as *.jar files are not always in the Maven repository layout
+		if maven, err := mavenutil.ParseFilename(name); err == nil {
+			sp.Maven = maven
+		}
 	}
 	return sp, nil
 }
 
 type FileSpec struct {
-	Name     string         `json:"Name"`          // "pool/main/h/hello/hello_2.10-2_amd64.deb"
-	Basename string         `json:"Basename"`      // "hello_2.10-2_amd64.deb"
-	SHA256   string         `json:"SHA256"`        // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
-	CID      string         `json:"CID,omitempty"` // IPFS CID
-	Dpkg     *dpkgutil.Dpkg `json:"Dpkg,omitempty"`
-	RPM      *rpmutil.RPM   `json:"RPM,omitempty"`
-	APK      *apkutil.APK   `json:"APK,omitempty"`
+	Name       string           `json:"Name"`                // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Basename   string           `json:"Basename"`            // "hello_2.10-2_amd64.deb"
+	SHA256     string           `json:"SHA256"`              // "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", empty when only SHA512 or BLAKE3 is known
+	SHA256Path string           `json:"SHA256Path"`          // "pool/main/h/hello/by-hash/SHA256/<SHA256>", in the Acquire-By-Hash layout of apt
+	SHA512     string           `json:"SHA512,omitempty"`    // Set instead of SHA256, for the SHA512 entries of the hash file
+	BLAKE3     string           `json:"BLAKE3,omitempty"`    // Set instead of SHA256, for the BLAKE3 entries of the hash file
+	CID        string           `json:"CID,omitempty"`       // IPFS CID
+	Snapshot   string           `json:"Snapshot,omitempty"`  // "20221101T000000Z", for snapshot.debian.org
+	PPA        *PPA             `json:"PPA,omitempty"`       // Launchpad PPA
+	OriginURL  string           `json:"OriginURL,omitempty"` // "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb", when recorded with --with-urls
+	Dpkg       *dpkgutil.Dpkg   `json:"Dpkg,omitempty"`
+	RPM        *rpmutil.RPM     `json:"RPM,omitempty"`
+	APK        *apkutil.APK     `json:"APK,omitempty"`
+	NPM        *npmutil.NPM     `json:"NPM,omitempty"`
+	GoMod      *gomodutil.GoMod `json:"GoMod,omitempty"`
+	Crate      *cargoutil.Crate `json:"Crate,omitempty"`
+	Maven      *mavenutil.Maven `json:"Maven,omitempty"`
+}
+
+// Sum returns the sum of the file, i.e., SHA256, or SHA512 (BLAKE3) prefixed with "sha512:" ("blake3:").
+// The sum is used as the key of the cache.
+// When the file has multiple sums, the strongest one is returned (see sha256sums.Stronger).
+func (sp FileSpec) Sum() string {
+	if sums := sp.Sums(); len(sums) > 0 {
+		return sums[0]
+	}
+	return ""
+}
+
+// Sums returns all the sums of the file, from the strongest one.
+func (sp FileSpec) Sums() []string {
+	var sums []string
+	if sp.SHA256 != "" {
+		sums = sha256sums.AddSum(sums, sp.SHA256)
+	}
+	if sp.SHA512 != "" {
+		sums = sha256sums.AddSum(sums, sha256sums.SHA512Prefix+sp.SHA512)
+	}
+	if sp.BLAKE3 != "" {
+		sums = sha256sums.AddSum(sums, sha256sums.BLAKE3Prefix+sp.BLAKE3)
+	}
+	return sums
+}
+
+// AddSum adds a sum of the file, for the hash files that contain multiple sums for the same file.
+// The existing sum of the same algorithm is replaced.
+func (sp *FileSpec) AddSum(sum string) error {
+	d, err := sha256sums.ParseSum(sum)
+	if err != nil {
+		return err
+	}
+	switch d.Algorithm() {
+	case digest.SHA512:
+		sp.SHA512 = d.Encoded()
+	case sha256sums.BLAKE3:
+		sp.BLAKE3 = d.Encoded()
+	default:
+		sp.SHA256 = d.Encoded()
+		sp.SHA256Path = path.Join(path.Dir(sp.Name), "by-hash", "SHA256", sp.SHA256)
+	}
+	return nil
 }
 
 func (sp FileSpec) URL(provider string) (*url.URL, error) {
+	if provider == "ipfs://" {
+		provider = "ipfs://{{.CID}}"
+	}
 
 	// FIXME: find a more robust way to error out when a template property is empty
 	if strings.Contains(provider, ".CID") && sp.CID == "" {
-		return nil, fmt.Errorf("no CID is known for sha256 %q", sp.SHA256)
+		return nil, fmt.Errorf("no CID is known for %q", sp.Sum())
+	}
+	if strings.Contains(provider, ".SHA256") && sp.SHA256 == "" {
+		return nil, fmt.Errorf("no SHA256 is known for %q (only %s)", sp.Name, sp.Sum())
+	}
+	if strings.Contains(provider, ".Snapshot") && sp.Snapshot == "" {
+		return nil, fmt.Errorf("no snapshot is known for %q (Hint: generate the hash file with --snapshot)", sp.Name)
+	}
+	if strings.Contains(provider, ".PPA") && sp.PPA == nil {
+		return nil, fmt.Errorf("no PPA is known for %q (Hint: generate the hash file with --ppa)", sp.Name)
+	}
+	if strings.Contains(provider, ".OriginURL") && sp.OriginURL == "" {
+		return nil, fmt.Errorf("no origin URL is known for %q (Hint: generate the hash file with --with-urls)", sp.Name)
 	}
 
-	tmpl, err := template.New("").Parse(provider)
+	tmpl, err := template.New("").Funcs(templateFuncs).Parse(provider)
 	if err != nil {
 		return nil, err
 	}
@@ -116,8 +327,10 @@ func (sp FileSpec) URL(provider string) (*url.URL, error) {
 	}
 	s := b.String()
 
-	u, err := url.Parse(s)
-	if err != nil {
+	var u *url.URL
+	if p, ok := localPath(s); ok {
+		u = &url.URL{Scheme: "file", Path: p}
+	} else if u, err = url.Parse(s); err != nil {
 		return nil, fmt.Errorf("failed to parse %q as a URL: %w", s, err)
 	}
 
@@ -135,6 +348,56 @@ func (sp FileSpec) URL(provider string) (*url.URL, error) {
 	return u, nil
 }
 
+// localPath returns the local path of a "file://" URL string or an absolute path string.
+// The path is taken literally without percent-decoding, so that "{{.Name}}" is mapped to the same file name
+// on the filesystem, such as "foo_1%3a2.0-1_amd64.deb" in "/var/cache/apt/archives".
+func localPath(s string) (string, bool) {
+	if strings.HasPrefix(s, "file://") {
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "file://"), "localhost")
+	} else if !strings.HasPrefix(s, "/") {
+		return "", false
+	}
+	if !strings.HasPrefix(s, "/") || strings.ContainsAny(s, "?#") {
+		return "", false
+	}
+	return s, true
+}
+
+// templateFuncs is the set of the functions available in the provider strings.
+var templateFuncs = template.FuncMap{
+	"literalEpoch": literalEpoch,
+	"stripEpoch":   stripEpoch,
+}
+
+// epochRegexp matches the epoch in the version string in a Debian package file name, such as "_1%3a".
+var epochRegexp = regexp.MustCompile(`_([0-9]+)(%3[aA]|:)`)
+
+// replaceEpoch replaces the epoch in the base name of the file name.
+func replaceEpoch(name, repl string) string {
+	dir, base := path.Split(name)
+	if loc := epochRegexp.FindStringIndex(base); loc != nil {
+		base = base[:loc[0]] + epochRegexp.ReplaceAllString(base[loc[0]:loc[1]], repl) + base[loc[1]:]
+	}
+	return dir + base
+}
+
+// literalEpoch converts the URL-encoded epoch separator in a Debian package file name to the literal ":",
+// for the mirrors that store the file names with the literal ":".
+// e.g., "pool/main/f/foo/foo_1%3a2.0-1_amd64.deb" to "pool/main/f/foo/foo_1:2.0-1_amd64.deb".
+//
+// Usage: "http://mirror.example.com/debian/{{literalEpoch .Name}}"
+func literalEpoch(name string) string {
+	return replaceEpoch(name, "_${1}:")
+}
+
+// stripEpoch removes the epoch from a Debian package file name, for the mirrors that store the file names without epochs.
+// e.g., "pool/main/f/foo/foo_1%3a2.0-1_amd64.deb" to "pool/main/f/foo/foo_2.0-1_amd64.deb".
+//
+// Usage: "http://mirror.example.com/debian/{{stripEpoch .Name}}"
+func stripEpoch(name string) string {
+	return replaceEpoch(name, "_")
+}
+
 // PseudoFilename is prefixed with "/ipfs/".
 // e.g., "/ipfs/QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH".
 type PseudoFilename struct {
@@ -160,15 +423,25 @@ func ParsePseudoFilename(s string) *PseudoFilename {
 // The key of the returned map is a file name such as "pool/main/h/hello/hello_2.10-2_amd64.deb"".
 // The key does not contain "pseudo" file names prefixed with "/ipfs/".
 func NewFromSHA256SUMS(sha256sumsMapByFilename map[string]string) (map[string]*FileSpec, error) {
+	m := make(map[string][]string, len(sha256sumsMapByFilename))
+	for f, sum := range sha256sumsMapByFilename {
+		m[f] = []string{sum}
+	}
+	return NewFromSums(m)
+}
+
+// NewFromSums is similar to NewFromSHA256SUMS, but the map may contain multiple sums for each file (see sha256sums.ParseAll).
+func NewFromSums(sumsMapByFilename map[string][]string) (map[string]*FileSpec, error) {
 	var allFilenames []string // contains "pseudo" file names too
-	for f := range sha256sumsMapByFilename {
+	for f := range sumsMapByFilename {
 		allFilenames = append(allFilenames, f)
 	}
 	sort.Strings(allFilenames)
 	entries := make(map[string]*FileSpec)
 	cids := make(map[string]string) // key: sha256, value: cid
 	for _, filenameMaybePseudo := range allFilenames {
-		sum := sha256sumsMapByFilename[filenameMaybePseudo]
+		sums := sumsMapByFilename[filenameMaybePseudo]
+		sum := sums[0]
 		if pseudo := ParsePseudoFilename(filenameMaybePseudo); pseudo != nil {
 			if oldCID := cids[sum]; oldCID != "" {
 				logrus.Warnf("Multiple CIDs found for SHA256 %q, discarding CID %q, using %q", sum, oldCID, pseudo.CID)
@@ -177,27 +450,167 @@ func NewFromSHA256SUMS(sha256sumsMapByFilename map[string]string) (map[string]*F
 			continue
 		}
 		filename := filenameMaybePseudo
-		cid := cids[sum] // often empty
+		var cid string // often empty
+		for _, f := range sums {
+			if cid = cids[f]; cid != "" {
+				break
+			}
+		}
 		sp, err := New(filename, sum, WithCID(cid))
 		if err != nil {
 			return nil, err
 		}
+		for _, f := range sums[1:] {
+			if err = sp.AddSum(f); err != nil {
+				return nil, err
+			}
+		}
 		entries[filename] = sp
 	}
 	return entries, nil
 }
 
+// CIDsFileSuffix is the suffix of the file that maps the SHA256 to the IPFS CIDs, alongside the hash file.
+// e.g., "SHA256SUMS-amd64.ipfs" for "SHA256SUMS-amd64".
+// The file consists of the "<SHA256>  /ipfs/<CID>" lines.
+const CIDsFileSuffix = ".ipfs"
+
+// NewFromSHA256SUMSFiles returns a file spec map from the hash files.
+// The directives such as "#repro-get:snapshot=20221101T000000Z" are applied to the entries of the same file.
+// The CIDs are loaded from the CIDs files (see CIDsFileSuffix) too, when they exist.
+// A file may have multiple sums of different algorithms (e.g., in SHA256SUMS and SHA512SUMS).
+// The entries of all the architecture sections are returned (see DirectiveArch).
 func NewFromSHA256SUMSFiles(fnames ...string) (map[string]*FileSpec, error) {
-	r, err := ioutilx.CatReader(fnames...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open %v: %w", fnames, err)
+	return NewFromSHA256SUMSFilesForArch("", fnames...)
+}
+
+// NewFromSHA256SUMSFilesForArch is similar to NewFromSHA256SUMSFiles, but only returns the common entries and
+// the entries in the sections for arch, such as "amd64", when the hash files have the arch directives (see DirectiveArch).
+// No entry is filtered out when arch is empty.
+func NewFromSHA256SUMSFilesForArch(arch string, fnames ...string) (map[string]*FileSpec, error) {
+	var readers []io.Reader
+	for _, fname := range fnames {
+		b, err := os.ReadFile(fname)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %v: %w", fnames, err)
+		}
+		if arch != "" {
+			if b, err = FilterByArch(b, arch); err != nil {
+				return nil, fmt.Errorf("failed to parse the architecture sections of %q: %w", fname, err)
+			}
+		}
+		readers = append(readers, bytes.NewReader(b))
 	}
-	defer r.Close()
 
-	sums, err := sha256sums.Parse(r)
+	sums, err := sha256sums.ParseAll(io.MultiReader(readers...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse the hash files %v as SHA256SUMS: %w", fnames, err)
 	}
+	for _, fname := range fnames {
+		if err = loadCIDsFile(sums, fname+CIDsFileSuffix); err != nil {
+			return nil, err
+		}
+	}
 
-	return NewFromSHA256SUMS(sums)
+	entries, err := NewFromSums(sums)
+	if err != nil {
+		return nil, err
+	}
+	for _, fname := range fnames {
+		if err = applyDirectives(entries, fname); err != nil {
+			return nil, fmt.Errorf("failed to apply the directives of %q: %w", fname, err)
+		}
+	}
+	return entries, nil
+}
+
+// loadCIDsFile loads the "<SHA256>  /ipfs/<CID>" lines of the CIDs file into sums, if the file exists.
+func loadCIDsFile(sums map[string][]string, fname string) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	cids, err := sha256sums.Parse(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse the CIDs file %q: %w", fname, err)
+	}
+	for k, v := range cids {
+		if ParsePseudoFilename(k) == nil {
+			return fmt.Errorf("the CIDs file %q must not contain non-IPFS entry %q", fname, k)
+		}
+		sums[k] = []string{v}
+	}
+	return nil
+}
+
+func applyDirectives(entries map[string]*FileSpec, fname string) error {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	directives, err := sha256sums.ParseDirectives(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	snapshot := directives[DirectiveSnapshot]
+	if snapshot != "" {
+		if err = ValidateSnapshot(snapshot); err != nil {
+			return err
+		}
+	}
+	var ppa *PPA
+	if v := directives[DirectivePPA]; v != "" {
+		if ppa, err = ParsePPA(v); err != nil {
+			return err
+		}
+	}
+	urls, err := parseURLDirectives(b)
+	if err != nil {
+		return err
+	}
+	for filename, u := range urls {
+		if sp, ok := entries[filename]; ok {
+			sp.OriginURL = u
+		}
+	}
+	if snapshot == "" && ppa == nil {
+		return nil
+	}
+	sums, err := sha256sums.Parse(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for filename := range sums {
+		if sp, ok := entries[filename]; ok {
+			if snapshot != "" {
+				sp.Snapshot = snapshot
+			}
+			if ppa != nil {
+				sp.PPA = ppa
+			}
+		}
+	}
+	return nil
+}
+
+// parseURLDirectives returns the map of the file names to the origin URLs of the url directives.
+func parseURLDirectives(b []byte) (map[string]string, error) {
+	res := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		k, v, ok := sha256sums.ParseDirective(sc.Text())
+		if !ok || k != DirectiveURL {
+			continue
+		}
+		u, filename, err := ParseURLDirective(v)
+		if err != nil {
+			return nil, err
+		}
+		res[filename] = u
+	}
+	return res, sc.Err()
 }
@@ -1,6 +1,8 @@
 package filespec
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -22,9 +24,10 @@ func TestNewFromSHA256SUMS(t *testing.T) {
 `,
 			expected: map[string]*FileSpec{
 				"pool/main/h/hello/hello_2.10-2_amd64.deb": &FileSpec{
-					Name:     "pool/main/h/hello/hello_2.10-2_amd64.deb",
-					Basename: "hello_2.10-2_amd64.deb",
-					SHA256:   "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+					Name:       "pool/main/h/hello/hello_2.10-2_amd64.deb",
+					Basename:   "hello_2.10-2_amd64.deb",
+					SHA256:     "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+					SHA256Path: "pool/main/h/hello/by-hash/SHA256/35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
 					Dpkg: &dpkgutil.Dpkg{
 						Package:      "hello",
 						Version:      "2.10-2",
@@ -42,10 +45,11 @@ func TestNewFromSHA256SUMS(t *testing.T) {
 `,
 			expected: map[string]*FileSpec{
 				"pool/main/h/hello/hello_2.10-2_amd64.deb": &FileSpec{
-					Name:     "pool/main/h/hello/hello_2.10-2_amd64.deb",
-					Basename: "hello_2.10-2_amd64.deb",
-					SHA256:   "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
-					CID:      "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU",
+					Name:       "pool/main/h/hello/hello_2.10-2_amd64.deb",
+					Basename:   "hello_2.10-2_amd64.deb",
+					SHA256:     "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+					SHA256Path: "pool/main/h/hello/by-hash/SHA256/35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+					CID:        "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACU",
 					Dpkg: &dpkgutil.Dpkg{
 						Package:      "hello",
 						Version:      "2.10-2",
@@ -63,10 +67,11 @@ func TestNewFromSHA256SUMS(t *testing.T) {
 `,
 			expected: map[string]*FileSpec{
 				"pool/main/h/hello/hello_2.10-2_amd64.deb": &FileSpec{
-					Name:     "pool/main/h/hello/hello_2.10-2_amd64.deb",
-					Basename: "hello_2.10-2_amd64.deb",
-					SHA256:   "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
-					CID:      "QmTsD9EfB3Zu7DtLGWwDAkmnuhfjea5KyhXzNjd41LW35i",
+					Name:       "pool/main/h/hello/hello_2.10-2_amd64.deb",
+					Basename:   "hello_2.10-2_amd64.deb",
+					SHA256:     "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+					SHA256Path: "pool/main/h/hello/by-hash/SHA256/35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+					CID:        "QmTsD9EfB3Zu7DtLGWwDAkmnuhfjea5KyhXzNjd41LW35i",
 					Dpkg: &dpkgutil.Dpkg{
 						Package:      "hello",
 						Version:      "2.10-2",
@@ -85,3 +90,228 @@ func TestNewFromSHA256SUMS(t *testing.T) {
 		assert.DeepEqual(t, tc.expected, got)
 	}
 }
+
+func TestNewFromSHA256SUMSFilesWithSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	withSnapshot := filepath.Join(dir, "SHA256SUMS-amd64")
+	assert.NilError(t, os.WriteFile(withSnapshot, []byte(`#repro-get:snapshot=20221101T000000Z
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+`), 0644))
+	withoutSnapshot := filepath.Join(dir, "SHA256SUMS-extra")
+	assert.NilError(t, os.WriteFile(withoutSnapshot, []byte(`f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+`), 0644))
+
+	got, err := NewFromSHA256SUMSFiles(withSnapshot, withoutSnapshot)
+	assert.NilError(t, err)
+	hello := got["pool/main/h/hello/hello_2.10-2_amd64.deb"]
+	assert.Equal(t, "20221101T000000Z", hello.Snapshot)
+	bash := got["pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb"]
+	assert.Equal(t, "", bash.Snapshot)
+
+	const provider = "http://snapshot.debian.org/archive/debian/{{.Snapshot}}/{{.Name}}"
+	u, err := hello.URL(provider)
+	assert.NilError(t, err)
+	assert.Equal(t, "http://snapshot.debian.org/archive/debian/20221101T000000Z/pool/main/h/hello/hello_2.10-2_amd64.deb", u.String())
+	_, err = bash.URL(provider)
+	assert.ErrorContains(t, err, "no snapshot is known")
+
+	_, err = New("pool/main/h/hello/hello_2.10-2_amd64.deb", hello.SHA256, WithSnapshot("2022-11-01"))
+	assert.ErrorContains(t, err, "invalid snapshot timestamp")
+}
+
+func TestNewFromSHA256SUMSFilesWithPPA(t *testing.T) {
+	dir := t.TempDir()
+	withPPA := filepath.Join(dir, "SHA256SUMS-ppa")
+	assert.NilError(t, os.WriteFile(withPPA, []byte(`#repro-get:ppa=deadsnakes/ppa
+2d1e6e1a4b2a3d1e7d64f7cb2c19aa0b5d22b8ad0a6d4c31e5fbd0e1b4ee3c36  pool/main/p/python3.12/python3.12_3.12.0-1+jammy1_amd64.deb
+`), 0644))
+	withoutPPA := filepath.Join(dir, "SHA256SUMS-amd64")
+	assert.NilError(t, os.WriteFile(withoutPPA, []byte(`35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+`), 0644))
+
+	got, err := NewFromSHA256SUMSFiles(withPPA, withoutPPA)
+	assert.NilError(t, err)
+	python := got["pool/main/p/python3.12/python3.12_3.12.0-1+jammy1_amd64.deb"]
+	assert.DeepEqual(t, &PPA{Owner: "deadsnakes", Name: "ppa"}, python.PPA)
+	hello := got["pool/main/h/hello/hello_2.10-2_amd64.deb"]
+	assert.Assert(t, hello.PPA == nil)
+
+	const provider = "https://launchpad.net/~{{.PPA.Owner}}/+archive/ubuntu/{{.PPA.Name}}/+files/{{.Basename}}"
+	u, err := python.URL(provider)
+	assert.NilError(t, err)
+	assert.Equal(t, "https://launchpad.net/~deadsnakes/+archive/ubuntu/ppa/+files/python3.12_3.12.0-1+jammy1_amd64.deb", u.String())
+	_, err = hello.URL(provider)
+	assert.ErrorContains(t, err, "no PPA is known")
+}
+
+func TestNewFromSHA256SUMSFilesWithCIDsFile(t *testing.T) {
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "SHA256SUMS-amd64")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(`35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+`), 0644))
+	assert.NilError(t, os.WriteFile(hashFile+CIDsFileSuffix, []byte(`35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  /ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj
+`), 0644))
+
+	got, err := NewFromSHA256SUMSFiles(hashFile)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(got))
+	hello := got["pool/main/h/hello/hello_2.10-2_amd64.deb"]
+	assert.Equal(t, "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj", hello.CID)
+	bash := got["pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb"]
+	assert.Equal(t, "", bash.CID)
+
+	u, err := hello.URL("ipfs://")
+	assert.NilError(t, err)
+	assert.Equal(t, "ipfs://QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj", u.String())
+	_, err = bash.URL("ipfs://")
+	assert.ErrorContains(t, err, "CID")
+
+	assert.NilError(t, os.WriteFile(hashFile+CIDsFileSuffix, []byte(`35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+`), 0644))
+	_, err = NewFromSHA256SUMSFiles(hashFile)
+	assert.ErrorContains(t, err, "must not contain non-IPFS entry")
+}
+
+func TestNewFromSHA256SUMSFilesWithURLs(t *testing.T) {
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "SHA256SUMS-amd64")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(`35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+#repro-get:url=http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+`), 0644))
+
+	got, err := NewFromSHA256SUMSFiles(hashFile)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(got))
+	hello := got["pool/main/h/hello/hello_2.10-2_amd64.deb"]
+	assert.Equal(t, "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb", hello.OriginURL)
+	u, err := hello.URL("{{.OriginURL}}")
+	assert.NilError(t, err)
+	assert.Equal(t, hello.OriginURL, u.String())
+	bash := got["pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb"]
+	_, err = bash.URL("{{.OriginURL}}")
+	assert.ErrorContains(t, err, "no origin URL is known")
+}
+
+func TestParseURLDirective(t *testing.T) {
+	u, filename, err := ParseURLDirective("https://example.com/foo/bar.tar.gz  foo/bar.tar.gz")
+	assert.NilError(t, err)
+	assert.Equal(t, "https://example.com/foo/bar.tar.gz", u)
+	assert.Equal(t, "foo/bar.tar.gz", filename)
+	for _, s := range []string{"", "https://example.com/foo/bar.tar.gz", "/foo/bar.tar.gz  foo/bar.tar.gz", "https://example.com/foo/bar.tar.gz  /foo/bar.tar.gz"} {
+		_, _, err = ParseURLDirective(s)
+		assert.ErrorContains(t, err, "invalid url directive", s)
+	}
+}
+
+func TestParseSignedByDirective(t *testing.T) {
+	fpr, filename, err := ParseSignedByDirective("115df9aef857853ee8445d0a0727707ea15b79cc  foo/bar.rpm")
+	assert.NilError(t, err)
+	assert.Equal(t, "115DF9AEF857853EE8445D0A0727707EA15B79CC", fpr)
+	assert.Equal(t, "foo/bar.rpm", filename)
+	for _, s := range []string{"", "115DF9AEF857853EE8445D0A0727707EA15B79CC", "0727707EA15B79CC  foo/bar.rpm", "115DF9AEF857853EE8445D0A0727707EA15B79CC  /foo/bar.rpm"} {
+		_, _, err = ParseSignedByDirective(s)
+		assert.ErrorContains(t, err, "invalid signed-by directive", s)
+	}
+}
+
+func TestParsePPA(t *testing.T) {
+	ppa, err := ParsePPA("ppa:deadsnakes/ppa")
+	assert.NilError(t, err)
+	assert.Equal(t, "deadsnakes/ppa", ppa.String())
+	for _, s := range []string{"", "deadsnakes", "deadsnakes/", "/ppa", "a/b/c"} {
+		_, err = ParsePPA(s)
+		assert.ErrorContains(t, err, "invalid PPA", s)
+	}
+}
+
+func TestURLSHA256Path(t *testing.T) {
+	sp, err := New("dists/bullseye/main/binary-amd64/Packages.xz", "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc")
+	assert.NilError(t, err)
+	u, err := sp.URL("http://deb.debian.org/debian/{{.SHA256Path}}")
+	assert.NilError(t, err)
+	assert.Equal(t, "http://deb.debian.org/debian/dists/bullseye/main/binary-amd64/by-hash/SHA256/35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", u.String())
+}
+
+func TestURLEpoch(t *testing.T) {
+	sp, err := New("pool/main/f/foo/foo_1%3a2.0-1_amd64.deb", "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc")
+	assert.NilError(t, err)
+	for provider, expected := range map[string]string{
+		"http://deb.debian.org/debian/{{.Name}}":                  "http://deb.debian.org/debian/pool/main/f/foo/foo_1%3a2.0-1_amd64.deb",
+		"http://mirror.example.com/debian/{{literalEpoch .Name}}": "http://mirror.example.com/debian/pool/main/f/foo/foo_1:2.0-1_amd64.deb",
+		"http://mirror.example.com/debian/{{stripEpoch .Name}}":   "http://mirror.example.com/debian/pool/main/f/foo/foo_2.0-1_amd64.deb",
+		"file:///mnt/debs/{{stripEpoch .Basename}}":               "file:///mnt/debs/foo_2.0-1_amd64.deb",
+	} {
+		u, err := sp.URL(provider)
+		assert.NilError(t, err)
+		assert.Equal(t, expected, u.String())
+	}
+
+	assert.Equal(t, "pool/main/h/hello/hello_2.10-2_amd64.deb", stripEpoch("pool/main/h/hello/hello_2.10-2_amd64.deb"))
+	assert.Equal(t, "pool/main/f/foo/foo_2.0-1_amd64.deb", stripEpoch("pool/main/f/foo/foo_1:2.0-1_amd64.deb"))
+	assert.Equal(t, "pool/main/f/foo_1%3a/foo_1:2.0-1_amd64.deb", literalEpoch("pool/main/f/foo_1%3a/foo_1%3A2.0-1_amd64.deb"))
+}
+
+func TestURLLocal(t *testing.T) {
+	sp, err := New("pool/main/f/foo/foo_1%3a2.0-1_amd64.deb", "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc")
+	assert.NilError(t, err)
+	for provider, expected := range map[string]string{
+		"file:///mnt/mirror/debian/{{.Name}}":                   "/mnt/mirror/debian/pool/main/f/foo/foo_1%3a2.0-1_amd64.deb",
+		"file://localhost/var/cache/apt/archives/{{.Basename}}": "/var/cache/apt/archives/foo_1%3a2.0-1_amd64.deb",
+		"/media/usb/snapshot/{{stripEpoch .Basename}}":          "/media/usb/snapshot/foo_2.0-1_amd64.deb",
+		"/media/usb/blobs/{{.SHA256}}":                          "/media/usb/blobs/35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+	} {
+		u, err := sp.URL(provider)
+		assert.NilError(t, err)
+		assert.Equal(t, "file", u.Scheme)
+		assert.Equal(t, "", u.Host)
+		assert.Equal(t, expected, u.Path, provider)
+	}
+	_, err = sp.URL("/media/usb/foo.deb")
+	assert.ErrorContains(t, err, "invalid provider")
+}
+
+func TestNewSHA512(t *testing.T) {
+	const sha512sum = "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	sp, err := New("pool/main/h/hello/hello_2.10-2_amd64.deb", sha512sum)
+	assert.NilError(t, err)
+	assert.Equal(t, sha512sum[len("sha512:"):], sp.SHA512)
+	assert.Equal(t, "", sp.SHA256)
+	assert.Equal(t, sha512sum, sp.Sum())
+	u, err := sp.URL("http://deb.debian.org/debian/{{.Name}}")
+	assert.NilError(t, err)
+	assert.Equal(t, "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb", u.String())
+	_, err = sp.URL("http://example.com/blobs/{{.SHA256}}")
+	assert.ErrorContains(t, err, "SHA256")
+}
+
+func TestNewFromSHA256SUMSFilesWithMultipleSums(t *testing.T) {
+	dir := t.TempDir()
+	sha256File := filepath.Join(dir, "SHA256SUMS-amd64")
+	assert.NilError(t, os.WriteFile(sha256File, []byte(`35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+`), 0644))
+	sha512File := filepath.Join(dir, "SHA512SUMS-amd64")
+	const sha512sum = "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	assert.NilError(t, os.WriteFile(sha512File, []byte(sha512sum+"  pool/main/h/hello/hello_2.10-2_amd64.deb\n"), 0644))
+	assert.NilError(t, os.WriteFile(sha256File+CIDsFileSuffix, []byte(`35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  /ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj
+`), 0644))
+
+	got, err := NewFromSHA256SUMSFiles(sha256File, sha512File)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(got))
+	hello := got["pool/main/h/hello/hello_2.10-2_amd64.deb"]
+	assert.Equal(t, "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", hello.SHA256)
+	assert.Equal(t, sha512sum, hello.SHA512)
+	assert.Equal(t, "sha512:"+sha512sum, hello.Sum())
+	assert.DeepEqual(t, []string{"sha512:" + sha512sum, hello.SHA256}, hello.Sums())
+	assert.Equal(t, "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj", hello.CID)
+	u, err := hello.URL("http://deb.debian.org/debian/{{.SHA256Path}}")
+	assert.NilError(t, err)
+	assert.Equal(t, "http://deb.debian.org/debian/pool/main/h/hello/by-hash/SHA256/35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", u.String())
+
+	bash := got["pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb"]
+	assert.Equal(t, bash.SHA256, bash.Sum())
+	assert.DeepEqual(t, []string{bash.SHA256}, bash.Sums())
+}
@@ -0,0 +1,36 @@
+package frontend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the build definition, in YAML.
+// The first line has to be "# syntax=<FRONTEND IMAGE>", like Dockerfiles.
+type Config struct {
+	BaseImage string   `yaml:"BaseImage"`           // "debian:bullseye-20211220@sha256:<SHA256>"; the digest should be pinned
+	HashFiles []string `yaml:"HashFiles,omitempty"` // Defaults to "SHA256SUMS-<ARCH>" of the target platform, such as "SHA256SUMS-arm-v7"
+	Distro    string   `yaml:"Distro,omitempty"`    // Detected from the base image by default
+	Providers []string `yaml:"Providers,omitempty"` // The default providers of the distro driver by default
+}
+
+// ParseConfig parses the build definition.
+func ParseConfig(b []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty build definition")
+		}
+		return nil, fmt.Errorf("failed to parse the build definition: %w", err)
+	}
+	if cfg.BaseImage == "" {
+		return nil, errors.New("BaseImage needs to be specified")
+	}
+	return &cfg, nil
+}
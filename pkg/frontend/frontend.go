@@ -0,0 +1,310 @@
+// Package frontend implements the BuildKit gateway frontend that builds an image from the hash files,
+// without the Dockerfiles generated by `repro-get dockerfile generate`.
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/containerd/containerd/reference/docker"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// ReproGetPath is the path of the repro-get binary in the frontend image.
+	ReproGetPath = "/repro-get"
+
+	// DefaultFilename is the default file name of the build definition.
+	DefaultFilename = "Dockerfile"
+
+	keyFilename       = "filename"
+	keyTargetPlatform = "platform"
+	keySource         = "source" // The frontend image, set by the Dockerfile frontend for "# syntax=..."
+	buildArgPrefix    = "build-arg:"
+
+	localNameContext    = "context"
+	localNameDockerfile = "dockerfile"
+
+	// cacheDir is mounted under a directory inside tmpfs (/dev/*), so that the mount point directory does not remain in the image
+	cacheDir     = "/dev/.cache/repro-get"
+	hashFilesDir = "/mnt"
+)
+
+// script is executed in the base image, with the repro-get command line as the arguments.
+// Equivalent to the RUN instruction of the Dockerfiles generated by `repro-get dockerfile generate`.
+const script = `set -eu
+touch -d "@${SOURCE_DATE_EPOCH}" /dev/.source-date-epoch
+"$@"
+: Remove unneeded files for reproducibility
+rm -rf /var/cache/apk/* /var/cache/ldconfig/* /var/lib/rpm/rpmdb.sqlite-shm /var/lib/rpm/rpmdb.sqlite-wal
+for d in /var/log /run /tmp; do
+  if [ -d "$d" ]; then
+    find "$d" -newer /dev/.source-date-epoch -not -type d -xdev -print0 | xargs -0 rm -f
+  fi
+done
+: Reset the timestamps for reproducibility
+find $(ls / | grep -E -v "^(dev|mnt|proc|sys)$") -newer /dev/.source-date-epoch -xdev -print0 | xargs -0 -r touch -h -d "@${SOURCE_DATE_EPOCH}"
+`
+
+// StateOpts is the options for NewState.
+type StateOpts struct {
+	Source          string     // The frontend image that contains the repro-get binary at ReproGetPath
+	SessionID       string     // The session ID for the build context
+	SourceDateEpoch *time.Time // Defaults to the creation time of the base image
+	Providers       []string   // Overrides Config.Providers
+}
+
+// NewState returns the LLB state and the image config for the platform.
+// baseImage is expected to be pinned by the digest.
+func NewState(cfg *Config, baseImage string, baseConfig []byte, p ocispec.Platform, opts StateOpts) (llb.State, []byte, error) {
+	if opts.Source == "" {
+		return llb.State{}, nil, errors.New("the frontend image is unknown (the \"source\" option is not set)")
+	}
+	epoch := opts.SourceDateEpoch
+	if epoch == nil {
+		var img ocispec.Image
+		if err := json.Unmarshal(baseConfig, &img); err != nil {
+			return llb.State{}, nil, fmt.Errorf("failed to parse the config of %q: %w", baseImage, err)
+		}
+		if img.Created == nil {
+			return llb.State{}, nil, fmt.Errorf("the creation time of %q is unknown, SOURCE_DATE_EPOCH needs to be specified", baseImage)
+		}
+		epoch = img.Created
+	}
+	hashFiles := cfg.HashFiles
+	if len(hashFiles) == 0 {
+		hashFiles = []string{"SHA256SUMS-" + archDashVariant(p)}
+	}
+	args := []string{"/bin/sh", "-c", script, "sh", "/usr/local/bin/repro-get", "--cache=" + cacheDir}
+	if cfg.Distro != "" {
+		args = append(args, "--distro="+cfg.Distro)
+	}
+	providers := cfg.Providers
+	if len(opts.Providers) > 0 {
+		providers = opts.Providers
+	}
+	if len(providers) > 0 {
+		args = append(args, "--provider="+strings.Join(providers, ","))
+	}
+	args = append(args, "install")
+	for _, f := range hashFiles {
+		p := path.Join(hashFilesDir, f)
+		if !strings.HasPrefix(p, hashFilesDir+"/") {
+			return llb.State{}, nil, fmt.Errorf("invalid hash file %q", f)
+		}
+		args = append(args, p)
+	}
+
+	base, err := llb.Image(baseImage, llb.Platform(p)).WithImageConfig(baseConfig)
+	if err != nil {
+		return llb.State{}, nil, fmt.Errorf("failed to parse the config of %q: %w", baseImage, err)
+	}
+	hashFilesSrc := llb.Local(localNameContext,
+		llb.SessionID(opts.SessionID),
+		llb.SharedKeyHint(localNameContext),
+		llb.FollowPaths(hashFiles),
+		llb.WithCustomName("load the hash files"),
+	)
+	createdBy := "repro-get install " + strings.Join(hashFiles, " ")
+	run := base.Run(
+		llb.Args(args),
+		llb.Dir("/"),
+		llb.AddEnv("SOURCE_DATE_EPOCH", strconv.FormatInt(epoch.Unix(), 10)),
+		llb.WithCustomName(createdBy),
+	)
+	run.AddMount("/usr/local/bin/repro-get", llb.Image(opts.Source, llb.Platform(p)), llb.SourcePath(ReproGetPath), llb.Readonly)
+	run.AddMount(cacheDir, llb.Scratch(), llb.AsPersistentCacheDir("repro-get", llb.CacheMountShared))
+	run.AddMount(hashFilesDir, hashFilesSrc, llb.Readonly)
+
+	imgConfig, err := newImageConfig(baseConfig, *epoch, createdBy)
+	if err != nil {
+		return llb.State{}, nil, err
+	}
+	return run.Root(), imgConfig, nil
+}
+
+// newImageConfig returns the image config with the creation time and the history entry.
+// The other fields of the base image config are retained as they are.
+func newImageConfig(baseConfig []byte, epoch time.Time, createdBy string) ([]byte, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(baseConfig, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse the base image config: %w", err)
+	}
+	var history []ocispec.History
+	if b, ok := m["history"]; ok {
+		if err := json.Unmarshal(b, &history); err != nil {
+			return nil, fmt.Errorf("failed to parse the history of the base image: %w", err)
+		}
+	}
+	epoch = epoch.UTC()
+	history = append(history, ocispec.History{
+		Created:   &epoch,
+		CreatedBy: createdBy,
+		Comment:   "repro-get-frontend",
+	})
+	var err error
+	if m["created"], err = json.Marshal(epoch); err != nil {
+		return nil, err
+	}
+	if m["history"], err = json.Marshal(history); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// archDashVariant returns a string like "amd64", "arm64", "arm-v7".
+func archDashVariant(p ocispec.Platform) string {
+	s := p.Architecture
+	if p.Variant != "" {
+		s += "-" + p.Variant
+	}
+	return s
+}
+
+// Build builds the image. Build implements client.BuildFunc.
+func Build(ctx context.Context, c client.Client) (*client.Result, error) {
+	buildOpts := c.BuildOpts()
+	opts := buildOpts.Opts
+	filename := opts[keyFilename]
+	if filename == "" {
+		filename = DefaultFilename
+	}
+	cfg, err := readConfig(ctx, c, filename)
+	if err != nil {
+		return nil, err
+	}
+	stateOpts := StateOpts{
+		Source:    opts[keySource],
+		SessionID: buildOpts.SessionID,
+	}
+	if v := opts[buildArgPrefix+"SOURCE_DATE_EPOCH"]; v != "" {
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", v, err)
+		}
+		epoch := time.Unix(sec, 0).UTC()
+		stateOpts.SourceDateEpoch = &epoch
+	}
+	if v := opts[buildArgPrefix+"REPRO_GET_PROVIDER"]; v != "" {
+		stateOpts.Providers = strings.Split(v, ",")
+	}
+
+	targetPlatforms := []ocispec.Platform{platforms.DefaultSpec()}
+	if workers := buildOpts.Workers; len(workers) > 0 && len(workers[0].Platforms) > 0 {
+		targetPlatforms = []ocispec.Platform{workers[0].Platforms[0]}
+	}
+	if v := opts[keyTargetPlatform]; v != "" {
+		targetPlatforms = nil
+		for _, s := range strings.Split(v, ",") {
+			p, err := platforms.Parse(s)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the platform %q: %w", s, err)
+			}
+			targetPlatforms = append(targetPlatforms, platforms.Normalize(p))
+		}
+	}
+
+	exportMap := len(targetPlatforms) > 1
+	res := client.NewResult()
+	var expPlatforms exptypes.Platforms
+	for _, p := range targetPlatforms {
+		p := p
+		dgst, baseConfig, err := c.ResolveImageConfig(ctx, cfg.BaseImage, llb.ResolveImageConfigOpt{
+			Platform: &p,
+			LogName:  "resolve image config for " + cfg.BaseImage,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %q: %w", cfg.BaseImage, err)
+		}
+		baseImage, err := pinDigest(cfg.BaseImage, dgst)
+		if err != nil {
+			return nil, err
+		}
+		st, imgConfig, err := NewState(cfg, baseImage, baseConfig, p, stateOpts)
+		if err != nil {
+			return nil, err
+		}
+		def, err := st.Marshal(ctx, llb.Platform(p))
+		if err != nil {
+			return nil, err
+		}
+		r, err := c.Solve(ctx, client.SolveRequest{Definition: def.ToPB()})
+		if err != nil {
+			return nil, err
+		}
+		ref, err := r.SingleRef()
+		if err != nil {
+			return nil, err
+		}
+		if !exportMap {
+			res.AddMeta(exptypes.ExporterImageConfigKey, imgConfig)
+			res.SetRef(ref)
+			continue
+		}
+		id := platforms.Format(p)
+		res.AddMeta(exptypes.ExporterImageConfigKey+"/"+id, imgConfig)
+		res.AddRef(id, ref)
+		expPlatforms.Platforms = append(expPlatforms.Platforms, exptypes.Platform{ID: id, Platform: p})
+	}
+	if exportMap {
+		b, err := json.Marshal(expPlatforms)
+		if err != nil {
+			return nil, err
+		}
+		res.AddMeta(exptypes.ExporterPlatformsKey, b)
+	}
+	return res, nil
+}
+
+func readConfig(ctx context.Context, c client.Client, filename string) (*Config, error) {
+	src := llb.Local(localNameDockerfile,
+		llb.FollowPaths([]string{filename}),
+		llb.SessionID(c.BuildOpts().SessionID),
+		llb.SharedKeyHint(localNameDockerfile),
+		llb.WithCustomName("load build definition from "+filename),
+	)
+	def, err := src.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.Solve(ctx, client.SolveRequest{Definition: def.ToPB()})
+	if err != nil {
+		return nil, err
+	}
+	ref, err := r.SingleRef()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ref.ReadFile(ctx, client.ReadRequest{Filename: filename})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", filename, err)
+	}
+	return ParseConfig(b)
+}
+
+// pinDigest returns the reference pinned by the digest, such as "docker.io/library/debian:bullseye@sha256:<SHA256>".
+func pinDigest(ref string, dgst digest.Digest) (string, error) {
+	named, err := docker.ParseDockerRef(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q: %w", ref, err)
+	}
+	if _, ok := named.(docker.Digested); ok || dgst == "" {
+		return named.String(), nil
+	}
+	pinned, err := docker.WithDigest(named, dgst)
+	if err != nil {
+		return "", err
+	}
+	return pinned.String(), nil
+}
@@ -0,0 +1,101 @@
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`# syntax=ghcr.io/reproducible-containers/repro-get-frontend
+BaseImage: debian:bullseye-20211220
+Providers:
+  - http://deb.debian.org/debian/{{.Name}}
+`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Config{
+		BaseImage: "debian:bullseye-20211220",
+		Providers: []string{"http://deb.debian.org/debian/{{.Name}}"},
+	}, cfg)
+
+	_, err = ParseConfig([]byte("BaseImage: debian\nUnknown: foo\n"))
+	assert.ErrorContains(t, err, "not found")
+	_, err = ParseConfig([]byte("# syntax=foo\n"))
+	assert.ErrorContains(t, err, "empty")
+	_, err = ParseConfig([]byte("Distro: debian\n"))
+	assert.ErrorContains(t, err, "BaseImage")
+}
+
+func TestPinDigest(t *testing.T) {
+	const dgst = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	s, err := pinDigest("debian:bullseye-20211220", dgst)
+	assert.NilError(t, err)
+	assert.Equal(t, "docker.io/library/debian:bullseye-20211220@"+dgst, s)
+
+	const dgst2 = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	s, err = pinDigest("debian@"+dgst2, dgst)
+	assert.NilError(t, err)
+	assert.Equal(t, "docker.io/library/debian@"+dgst2, s)
+}
+
+func TestNewState(t *testing.T) {
+	cfg := &Config{BaseImage: "debian:bullseye-20211220", Distro: "debian"}
+	created := time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC)
+	baseConfig, err := json.Marshal(ocispec.Image{
+		Created:      &created,
+		Architecture: "arm",
+		OS:           "linux",
+		Variant:      "v7",
+		Config:       ocispec.ImageConfig{Env: []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}},
+		History:      []ocispec.History{{Created: &created, CreatedBy: "/bin/sh -c #(nop) ADD file:... in /"}},
+	})
+	assert.NilError(t, err)
+	p := ocispec.Platform{Architecture: "arm", OS: "linux", Variant: "v7"}
+	opts := StateOpts{Source: "ghcr.io/reproducible-containers/repro-get-frontend"}
+	st, imgConfig, err := NewState(cfg, "docker.io/library/debian:bullseye-20211220", baseConfig, p, opts)
+	assert.NilError(t, err)
+
+	def, err := st.Marshal(context.TODO(), llb.Platform(p))
+	assert.NilError(t, err)
+	var exec *pb.ExecOp
+	for _, dt := range def.Def {
+		var op pb.Op
+		assert.NilError(t, op.Unmarshal(dt))
+		if e := op.GetExec(); e != nil {
+			exec = e
+		}
+	}
+	assert.Assert(t, exec != nil)
+	assert.DeepEqual(t, []string{"/usr/local/bin/repro-get", "--cache=/dev/.cache/repro-get", "--distro=debian", "install", "/mnt/SHA256SUMS-arm-v7"}, exec.Meta.Args[4:])
+	assert.Assert(t, len(exec.Meta.Env) == 2)
+	assert.Equal(t, "SOURCE_DATE_EPOCH=1639958400", exec.Meta.Env[1])
+	var targets []string
+	for _, m := range exec.Mounts {
+		targets = append(targets, m.Dest)
+	}
+	assert.DeepEqual(t, []string{"/", "/dev/.cache/repro-get", "/mnt", "/usr/local/bin/repro-get"}, targets)
+
+	var img ocispec.Image
+	assert.NilError(t, json.Unmarshal(imgConfig, &img))
+	assert.Equal(t, created, *img.Created)
+	assert.Equal(t, 2, len(img.History))
+	assert.Equal(t, "repro-get install SHA256SUMS-arm-v7", img.History[1].CreatedBy)
+
+	epoch := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	opts.SourceDateEpoch = &epoch
+	opts.Providers = []string{"http://example.com/{{.Name}}"}
+	cfg.HashFiles = []string{"../SHA256SUMS"}
+	_, _, err = NewState(cfg, "docker.io/library/debian:bullseye-20211220", baseConfig, p, opts)
+	assert.ErrorContains(t, err, "invalid hash file")
+	cfg.HashFiles = []string{"SHA256SUMS"}
+	_, imgConfig, err = NewState(cfg, "docker.io/library/debian:bullseye-20211220", baseConfig, p, opts)
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(imgConfig, &img))
+	assert.Equal(t, epoch, *img.Created)
+}
@@ -0,0 +1,53 @@
+package gomodutil
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+type GoMod struct {
+	Module  string `json:"Module"`  // "github.com/BurntSushi/toml"
+	Version string `json:"Version"` // "v1.2.0"
+}
+
+// ParseFilename parses a file name like "github.com/!burnt!sushi/toml/@v/v1.2.0.zip".
+// The file name is escaped as in the GOPROXY protocol.
+func ParseFilename(filename string) (*GoMod, error) {
+	ext := path.Ext(filename)
+	if ext != ".zip" && ext != ".mod" {
+		return nil, fmt.Errorf("expected *.zip or *.mod, got %q", filename)
+	}
+	sp := strings.SplitN(strings.TrimSuffix(filename, ext), "/@v/", 2)
+	if len(sp) != 2 {
+		return nil, fmt.Errorf("expected <MODULE>/@v/<VERSION>%s, got %q", ext, filename)
+	}
+	mod, err := module.UnescapePath(sp[0])
+	if err != nil {
+		return nil, err
+	}
+	ver, err := module.UnescapeVersion(sp[1])
+	if err != nil {
+		return nil, err
+	}
+	return &GoMod{
+		Module:  mod,
+		Version: ver,
+	}, nil
+}
+
+// Filename returns a file name like "github.com/!burnt!sushi/toml/@v/v1.2.0.zip".
+// ext is ".zip" or ".mod".
+func Filename(mod, ver, ext string) (string, error) {
+	escMod, err := module.EscapePath(mod)
+	if err != nil {
+		return "", err
+	}
+	escVer, err := module.EscapeVersion(ver)
+	if err != nil {
+		return "", err
+	}
+	return escMod + "/@v/" + escVer + ext, nil
+}
@@ -0,0 +1,24 @@
+package gomodutil
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseFilename(t *testing.T) {
+	got, err := ParseFilename("github.com/!burnt!sushi/toml/@v/v1.2.0.zip")
+	assert.NilError(t, err)
+	expected := &GoMod{
+		Module:  "github.com/BurntSushi/toml",
+		Version: "v1.2.0",
+	}
+	assert.DeepEqual(t, expected, got)
+
+	fname, err := Filename(expected.Module, expected.Version, ".mod")
+	assert.NilError(t, err)
+	assert.Equal(t, "github.com/!burnt!sushi/toml/@v/v1.2.0.mod", fname)
+
+	_, err = ParseFilename("pool/main/h/hello/hello_2.10-2_amd64.deb")
+	assert.ErrorContains(t, err, "expected *.zip or *.mod")
+}
@@ -0,0 +1,187 @@
+// Package hashlint checks the hash files for the common mistakes, without accessing the network.
+package hashlint
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/dpkgutil"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+)
+
+// Check is the kind of a Problem.
+type Check string
+
+const (
+	CheckSyntax       Check = "syntax"       // Malformed lines and digests
+	CheckDirective    Check = "directive"    // Malformed directives such as "#repro-get:snapshot=..."
+	CheckDuplicate    Check = "duplicate"    // Duplicate or conflicting entries for the same file name
+	CheckFilename     Check = "filename"     // File names that cannot be parsed, e.g., "*.deb" files without the version
+	CheckArchitecture Check = "architecture" // Packages of multiple architectures in a single hash file, or in a wrong architecture section
+	CheckReachability Check = "reachability" // Files that are not retrievable from any provider (not checked by Lint)
+)
+
+// Problem is a problem found in a hash file.
+type Problem struct {
+	File    string `json:"File"`
+	Line    int    `json:"Line,omitempty"` // 1-based, zero when not applicable
+	Name    string `json:"Name,omitempty"` // File name of the entry, such as "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Check   Check  `json:"Check"`
+	Message string `json:"Message"`
+}
+
+func (p Problem) String() string {
+	loc := p.File
+	if p.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	return fmt.Sprintf("%s: [%s] %s", loc, p.Check, p.Message)
+}
+
+// archIndependent is the set of the architectures that can be mixed with any architecture.
+var archIndependent = map[string]bool{
+	"all":    true, // dpkg
+	"noarch": true, // rpm
+	"src":    true, // rpm
+	"source": true, // dpkg
+}
+
+type seenSum struct {
+	sum  string
+	line int
+}
+
+// Lint checks the hash file read from r. file is used only for the messages.
+// The returned problems are sorted by the line numbers.
+//
+// The entries in the architecture sections (see filespec.DirectiveArch) are checked against the architecture of the section,
+// and checked for the duplicates with the entries of the same section and the common section.
+func Lint(file string, r io.Reader) ([]Problem, error) {
+	var (
+		problems    []Problem
+		archSection string                                // empty for the common section
+		seen        = make(map[string]map[string]seenSum) // key: archSection and file name, algorithm
+		archs       = make(map[string][]string)           // key: architecture in the common section, value: file names
+	)
+	add := func(line int, name string, check Check, format string, a ...interface{}) {
+		problems = append(problems, Problem{File: file, Line: line, Name: name, Check: check, Message: fmt.Sprintf(format, a...)})
+	}
+	sc := bufio.NewScanner(r)
+	for i := 1; sc.Scan(); i++ {
+		line := sc.Text()
+		if k, v, ok := sha256sums.ParseDirective(line); ok {
+			if err := lintDirective(k, v); err != nil {
+				add(i, "", CheckDirective, "%v", err)
+			}
+			if k == filespec.DirectiveArch {
+				archSection = v
+			}
+			continue
+		}
+		sum, name, err := sha256sums.ParseLine(line)
+		if err != nil {
+			if errors.Is(err, sha256sums.ErrEmptyLine) || errors.Is(err, sha256sums.ErrCommentLine) {
+				continue
+			}
+			add(i, "", CheckSyntax, "%v (Hint: expected \"<SHA256>  <FILENAME>\", \"<SHA512>  <FILENAME>\", or \"blake3:<BLAKE3>  <FILENAME>\")", err)
+			continue
+		}
+		alg := "sha256"
+		if a, _, ok := strings.Cut(sum, ":"); ok {
+			alg = a
+		}
+		key := archSection + "\x00" + name
+		if seen[key] == nil {
+			seen[key] = make(map[string]seenSum)
+		}
+		prev, ok := seen[key][alg]
+		if !ok && archSection != "" {
+			prev, ok = seen["\x00"+name][alg]
+		}
+		if ok {
+			if prev.sum == sum {
+				add(i, name, CheckDuplicate, "duplicate entry for %q (also at line %d) (Hint: remove the line)", name, prev.line)
+			} else {
+				add(i, name, CheckDuplicate, "conflicting %s sums for %q (the other one is at line %d) (Hint: regenerate the hash file with 'repro-get hash generate')", alg, name, prev.line)
+			}
+			continue
+		}
+		seen[key][alg] = seenSum{sum: sum, line: i}
+		if len(seen[key]) > 1 || filespec.ParsePseudoFilename(name) != nil {
+			continue // already checked, or no need to check
+		}
+		sp, err := filespec.New(name, sum)
+		if err != nil {
+			add(i, name, CheckFilename, "%v", err)
+			continue
+		}
+		arch := architecture(sp)
+		if arch == "" || archIndependent[arch] {
+			continue
+		}
+		if archSection == "" {
+			archs[arch] = append(archs[arch], name)
+		} else if sp.Dpkg != nil {
+			if expected, err := dpkgutil.ArchFromOCI(archSection); err == nil && arch != expected {
+				add(i, name, CheckArchitecture, "%q is for %q, but is in the section for %q (Hint: move the line to the section \"%s\")",
+					name, arch, archSection, sha256sums.FormatDirective(filespec.DirectiveArch, "<ARCH>"))
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(archs) > 1 {
+		var ss []string
+		for arch, names := range archs {
+			ss = append(ss, fmt.Sprintf("%s (%d packages, e.g., %q)", arch, len(names), names[0]))
+		}
+		sort.Strings(ss)
+		add(0, "", CheckArchitecture, "mixed architectures: %s (Hint: split the hash file per architecture, such as \"SHA256SUMS-amd64\", or use the architecture sections \"%s\")",
+			strings.Join(ss, ", "), sha256sums.FormatDirective(filespec.DirectiveArch, "<ARCH>"))
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+func lintDirective(k, v string) error {
+	switch k {
+	case filespec.DirectiveSnapshot:
+		return filespec.ValidateSnapshot(v)
+	case filespec.DirectiveArch:
+		return filespec.ValidateArch(v)
+	case filespec.DirectivePPA:
+		_, err := filespec.ParsePPA(v)
+		return err
+	case filespec.DirectiveIndex:
+		if _, _, err := sha256sums.ParseLine(v); err != nil {
+			return fmt.Errorf("invalid index directive %q: %w", v, err)
+		}
+		return nil
+	case filespec.DirectiveURL:
+		_, _, err := filespec.ParseURLDirective(v)
+		return err
+	case filespec.DirectiveSignedBy:
+		_, _, err := filespec.ParseSignedByDirective(v)
+		return err
+	default:
+		return fmt.Errorf("unknown directive %q", k)
+	}
+}
+
+func architecture(sp *filespec.FileSpec) string {
+	switch {
+	case sp.Dpkg != nil:
+		return sp.Dpkg.Architecture
+	case sp.RPM != nil:
+		return sp.RPM.Architecture
+	}
+	return ""
+}
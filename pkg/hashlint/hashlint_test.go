@@ -0,0 +1,78 @@
+package hashlint
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLint(t *testing.T) {
+	const good = `#repro-get:snapshot=20221101T000000Z
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e  pool/main/h/hello/hello_2.10-2_amd64.deb
+#repro-get:url=http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  /ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj
+`
+	problems, err := Lint("SHA256SUMS", strings.NewReader(good))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems), "%v", problems)
+
+	const bad = `#repro-get:snapshot=2022-11-01
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cb  pool/main/h/hello/hello_2.10-2_amd64.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_amd64.deb
+2222222222222222222222222222222222222222222222222222222222222222  pool/main/h/hello/hello.deb
+3333333333333333333333333333333333333333333333333333333333333333  pool/main/h/hello/hello_2.10-2_arm64.deb
+#repro-get:url=pool/main/h/hello/hello_2.10-2_arm64.deb
+`
+	problems, err = Lint("SHA256SUMS", strings.NewReader(bad))
+	assert.NilError(t, err)
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.File+":"+string(p.Check)+":"+strconv.Itoa(p.Line))
+	}
+	assert.DeepEqual(t, []string{
+		"SHA256SUMS:architecture:0",
+		"SHA256SUMS:directive:1",
+		"SHA256SUMS:syntax:2",
+		"SHA256SUMS:duplicate:4",
+		"SHA256SUMS:duplicate:5",
+		"SHA256SUMS:filename:6",
+		"SHA256SUMS:directive:8",
+	}, got)
+	assert.Assert(t, strings.Contains(problems[0].Message, "amd64 (1 packages"), problems[0].Message)
+	assert.Assert(t, strings.Contains(problems[4].Message, "conflicting sha256 sums"), problems[4].Message)
+	assert.Equal(t, "SHA256SUMS:4: [duplicate] duplicate entry for \"pool/main/h/hello/hello_2.10-2_amd64.deb\" (also at line 3) (Hint: remove the line)", problems[3].String())
+}
+
+func TestLintArchSections(t *testing.T) {
+	const good = `f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+#repro-get:arch=amd64
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+2222222222222222222222222222222222222222222222222222222222222222  bin/tool
+#repro-get:arch=arm64
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_arm64.deb
+3333333333333333333333333333333333333333333333333333333333333333  bin/tool
+`
+	problems, err := Lint("SHA256SUMS", strings.NewReader(good))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems), "%v", problems)
+
+	const bad = `f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+#repro-get:arch=amd64
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_arm64.deb
+4444444444444444444444444444444444444444444444444444444444444444  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+#repro-get:arch=ARM64
+`
+	problems, err = Lint("SHA256SUMS", strings.NewReader(bad))
+	assert.NilError(t, err)
+	var got []string
+	for _, p := range problems {
+		got = append(got, string(p.Check)+":"+strconv.Itoa(p.Line))
+	}
+	assert.DeepEqual(t, []string{"architecture:3", "duplicate:4", "directive:5"}, got)
+}
@@ -0,0 +1,238 @@
+// Package hashsig signs and verifies the hash files with the detached OpenPGP signatures, with minisign (or signify) keys, or with Sigstore (cosign).
+//
+// The signature of "SHA256SUMS-amd64" is stored as "SHA256SUMS-amd64.asc" (ASCII-armored),
+// so that it can be also verified with "gpg --verify SHA256SUMS-amd64.asc SHA256SUMS-amd64".
+// The minisign signature is stored as "SHA256SUMS-amd64.minisig", and the signify signature is stored as "SHA256SUMS-amd64.sig".
+// The Sigstore bundle is stored as "SHA256SUMS-amd64.sigstore.json".
+package hashsig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+)
+
+// SignatureSuffix is the suffix of the detached signature, alongside the hash file.
+// e.g., "SHA256SUMS-amd64.asc" for "SHA256SUMS-amd64".
+const SignatureSuffix = ".asc"
+
+// SignatureSuffixes are the suffixes of all the kinds of the signatures alongside the hash file.
+var SignatureSuffixes = []string{SignatureSuffix, MinisignSignatureSuffix, SignifySignatureSuffix, SigstoreBundleSuffix}
+
+// IsSignatureFile returns true if the file name has one of SignatureSuffixes.
+func IsSignatureFile(name string) bool {
+	for _, suffix := range SignatureSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Signatures returns the signature files alongside the hash file.
+func Signatures(hashFile string) ([]string, error) {
+	var res []string
+	for _, suffix := range SignatureSuffixes {
+		if _, err := os.Stat(hashFile + suffix); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		res = append(res, hashFile+suffix)
+	}
+	return res, nil
+}
+
+// RemoveSignatures removes the signature files alongside the hash file, and returns the removed ones.
+func RemoveSignatures(hashFile string) ([]string, error) {
+	var res []string
+	for _, suffix := range SignatureSuffixes {
+		if err := os.Remove(hashFile + suffix); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return res, err
+		}
+		res = append(res, hashFile+suffix)
+	}
+	return res, nil
+}
+
+// ErrUnsigned is returned when the hash file has no signature.
+var ErrUnsigned = errors.New("the hash file is not signed")
+
+// ReadKeyring reads the OpenPGP keyring files.
+// Both binary and ASCII-armored keyrings are supported.
+func ReadKeyring(files ...string) (openpgp.EntityList, error) {
+	var res openpgp.EntityList
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var el openpgp.EntityList
+		if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN PGP")) {
+			el, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+		} else {
+			el, err = openpgp.ReadKeyRing(bytes.NewReader(b))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyring %q: %w", f, err)
+		}
+		res = append(res, el...)
+	}
+	return res, nil
+}
+
+// ReadSigner reads the first private key in the keyring file.
+// The passphrase is used for decrypting the private key, when it is encrypted.
+func ReadSigner(file string, passphrase []byte) (*openpgp.Entity, error) {
+	el, err := ReadKeyring(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range el {
+		if e.PrivateKey == nil {
+			continue
+		}
+		if e.PrivateKey.Encrypted {
+			if len(passphrase) == 0 {
+				return nil, fmt.Errorf("the private key in %q is encrypted, and no passphrase was specified", file)
+			}
+			if err = e.PrivateKey.Decrypt(passphrase); err != nil {
+				return nil, fmt.Errorf("failed to decrypt the private key in %q: %w", file, err)
+			}
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("no private key was found in %q", file)
+}
+
+// Sign writes the ASCII-armored detached signature of r to w.
+func Sign(w io.Writer, r io.Reader, signer *openpgp.Entity) error {
+	return openpgp.ArmoredDetachSign(w, signer, r, nil)
+}
+
+// SignFile writes the signature of the hash file as hashFile+SignatureSuffix.
+func SignFile(hashFile string, signer *openpgp.Entity) error {
+	f, err := os.Open(hashFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var b bytes.Buffer
+	if err = Sign(&b, f, signer); err != nil {
+		return fmt.Errorf("failed to sign %q: %w", hashFile, err)
+	}
+	sigFile := hashFile + SignatureSuffix
+	if err = os.WriteFile(sigFile, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to create %q: %w", sigFile, err)
+	}
+	return nil
+}
+
+// VerifyFile verifies the signature of the hash file, and returns the signer.
+// Returns an error that wraps ErrUnsigned when hashFile+SignatureSuffix does not exist.
+func VerifyFile(keyring openpgp.EntityList, hashFile string) (*openpgp.Entity, error) {
+	sigFile := hashFile + SignatureSuffix
+	sig, err := os.Open(sigFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %q does not exist", ErrUnsigned, sigFile)
+		}
+		return nil, err
+	}
+	defer sig.Close()
+	f, err := os.Open(hashFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, f, sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %q with %q: %w", hashFile, sigFile, err)
+	}
+	return signer, nil
+}
+
+// VerifyOpts is the options for VerifyFiles.
+type VerifyOpts struct {
+	Keyring      openpgp.EntityList  // For the OpenPGP signatures (SignatureSuffix)
+	MinisignKeys []MinisignPublicKey // For the minisign signatures (MinisignSignatureSuffix) and the signify signatures (SignifySignatureSuffix)
+	Sigstore     *SigstoreOpts       // For the Sigstore bundles (SigstoreBundleSuffix)
+	Require      bool                // Reject the hash files without a valid signature
+}
+
+// VerifyFiles verifies the signatures of the hash files, with the OpenPGP keyring, the minisign keys, and/or the Sigstore identity.
+// A bad signature is always rejected.
+// The unsigned hash files are rejected when opts.Require is true, otherwise they are just logged.
+func VerifyFiles(ctx context.Context, hashFiles []string, opts VerifyOpts) error {
+	if opts.Require && len(opts.Keyring) == 0 && len(opts.MinisignKeys) == 0 && opts.Sigstore == nil {
+		return errors.New("no keyring, minisign key, or Sigstore identity was specified for verifying the signatures of the hash files")
+	}
+	for _, f := range hashFiles {
+		var verified bool
+		if len(opts.Keyring) > 0 {
+			signer, err := VerifyFile(opts.Keyring, f)
+			switch {
+			case err == nil:
+				logrus.Infof("Verified the signature of %q (signed by %s)", f, identityName(signer))
+				verified = true
+			case !errors.Is(err, ErrUnsigned):
+				return err
+			}
+		} else if _, err := os.Stat(f + SignatureSuffix); err == nil {
+			logrus.Warnf("Not verifying %q, as no keyring was specified", f+SignatureSuffix)
+		}
+		if len(opts.MinisignKeys) > 0 {
+			signer, err := VerifyFileWithMinisign(opts.MinisignKeys, f)
+			switch {
+			case err == nil:
+				logrus.Infof("Verified the minisign (or signify) signature of %q (signed by %s)", f, signer.ID())
+				verified = true
+			case !errors.Is(err, ErrUnsigned):
+				return err
+			}
+		} else {
+			for _, suffix := range []string{MinisignSignatureSuffix, SignifySignatureSuffix} {
+				if _, err := os.Stat(f + suffix); err == nil {
+					logrus.Warnf("Not verifying %q, as no minisign key was specified", f+suffix)
+				}
+			}
+		}
+		if opts.Sigstore != nil {
+			err := VerifyFileWithSigstore(ctx, f, *opts.Sigstore)
+			switch {
+			case err == nil:
+				logrus.Infof("Verified the Sigstore bundle of %q", f)
+				verified = true
+			case !errors.Is(err, ErrUnsigned):
+				return err
+			}
+		} else if _, err := os.Stat(f + SigstoreBundleSuffix); err == nil {
+			logrus.Warnf("Not verifying %q, as no Sigstore identity was specified", f+SigstoreBundleSuffix)
+		}
+		if !verified {
+			if opts.Require {
+				return fmt.Errorf("%w: %q has no valid signature", ErrUnsigned, f)
+			}
+			logrus.Debugf("No signature of %q was verified", f)
+		}
+	}
+	return nil
+}
+
+func identityName(e *openpgp.Entity) string {
+	for name := range e.Identities {
+		return name
+	}
+	return e.PrimaryKey.KeyIdString()
+}
@@ -0,0 +1,113 @@
+package hashsig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"       //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"golang.org/x/crypto/openpgp/armor" //nolint:staticcheck // Ditto
+	"gotest.tools/v3/assert"
+)
+
+const testSHA256SUMS = "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb\n"
+
+func TestSignAndVerifyFile(t *testing.T) {
+	ctx := context.Background()
+	signer, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	assert.NilError(t, err)
+	stranger, err := openpgp.NewEntity("stranger", "", "stranger@example.com", nil)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "SHA256SUMS")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS), 0644))
+
+	_, err = VerifyFile(openpgp.EntityList{signer}, hashFile)
+	assert.Assert(t, errors.Is(err, ErrUnsigned))
+	assert.NilError(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Keyring: openpgp.EntityList{signer}}))
+	assert.ErrorContains(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Keyring: openpgp.EntityList{signer}, Require: true}), "not signed")
+	assert.ErrorContains(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Require: true}), "no keyring, minisign key, or Sigstore identity")
+
+	assert.NilError(t, SignFile(hashFile, signer))
+	got, err := VerifyFile(openpgp.EntityList{signer}, hashFile)
+	assert.NilError(t, err)
+	assert.Equal(t, signer.PrimaryKey.KeyId, got.PrimaryKey.KeyId)
+	assert.NilError(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Keyring: openpgp.EntityList{signer}, Require: true}))
+
+	_, err = VerifyFile(openpgp.EntityList{stranger}, hashFile)
+	assert.ErrorContains(t, err, "failed to verify")
+
+	assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS+testSHA256SUMS), 0644))
+	assert.ErrorContains(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Keyring: openpgp.EntityList{signer}}), "failed to verify")
+}
+
+func TestReadSigner(t *testing.T) {
+	signer, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	assert.NilError(t, err)
+	dir := t.TempDir()
+
+	var sec bytes.Buffer
+	w, err := armor.Encode(&sec, openpgp.PrivateKeyType, nil)
+	assert.NilError(t, err)
+	assert.NilError(t, signer.SerializePrivate(w, nil))
+	assert.NilError(t, w.Close())
+	secFile := filepath.Join(dir, "secring.asc")
+	assert.NilError(t, os.WriteFile(secFile, sec.Bytes(), 0600))
+
+	var pub bytes.Buffer
+	assert.NilError(t, signer.Serialize(&pub))
+	pubFile := filepath.Join(dir, "pubring.gpg")
+	assert.NilError(t, os.WriteFile(pubFile, pub.Bytes(), 0644))
+
+	got, err := ReadSigner(secFile, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, signer.PrimaryKey.KeyId, got.PrimaryKey.KeyId)
+
+	_, err = ReadSigner(pubFile, nil)
+	assert.ErrorContains(t, err, "no private key")
+
+	keyring, err := ReadKeyring(pubFile)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(keyring))
+
+	hashFile := filepath.Join(dir, "SHA256SUMS")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS), 0644))
+	assert.NilError(t, SignFile(hashFile, got))
+	_, err = VerifyFile(keyring, hashFile)
+	assert.NilError(t, err)
+}
+
+func TestIsSignatureFile(t *testing.T) {
+	for _, f := range []string{"SHA256SUMS.asc", "SHA256SUMS.minisig", "SHA256SUMS.sig", "SHA256SUMS.sigstore.json"} {
+		assert.Assert(t, IsSignatureFile(f), f)
+	}
+	for _, f := range []string{"SHA256SUMS", "SHA256SUMS.ipfs", "SHA256SUMS.intoto.json"} {
+		assert.Assert(t, !IsSignatureFile(f), f)
+	}
+}
+
+func TestRemoveSignatures(t *testing.T) {
+	hashFile := filepath.Join(t.TempDir(), "SHA256SUMS")
+	for _, f := range []string{hashFile, hashFile + SignatureSuffix, hashFile + SigstoreBundleSuffix, hashFile + ".ipfs"} {
+		assert.NilError(t, os.WriteFile(f, []byte(testSHA256SUMS), 0644))
+	}
+	expected := []string{hashFile + SignatureSuffix, hashFile + SigstoreBundleSuffix}
+	sigs, err := Signatures(hashFile)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expected, sigs)
+
+	removed, err := RemoveSignatures(hashFile)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expected, removed)
+	sigs, err = Signatures(hashFile)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(sigs))
+	for _, f := range []string{hashFile, hashFile + ".ipfs"} {
+		_, err = os.Stat(f)
+		assert.NilError(t, err)
+	}
+}
@@ -0,0 +1,372 @@
+package hashsig
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// MinisignSignatureSuffix is the suffix of the minisign signature, alongside the hash file.
+	// e.g., "SHA256SUMS-amd64.minisig" for "SHA256SUMS-amd64".
+	MinisignSignatureSuffix = ".minisig"
+	// SignifySignatureSuffix is the suffix of the signify signature, alongside the hash file.
+	// e.g., "SHA256SUMS-amd64.sig" for "SHA256SUMS-amd64".
+	SignifySignatureSuffix = ".sig"
+)
+
+const (
+	untrustedCommentPrefix = "untrusted comment: "
+	trustedCommentPrefix   = "trusted comment: "
+	minisignAlgEd25519     = "Ed" // The signature of the message itself. Also used by signify.
+	minisignAlgHashed      = "ED" // The signature of the BLAKE2b-512 hash of the message
+	signifyKDFAlg          = "BK" // bcrypt_pbkdf
+	minisignKDFAlgScrypt   = "Sc"
+	minisignChkAlgBLAKE2b  = "B2"
+	keyNumSize             = 8
+	publicKeyBlobSize      = 2 + keyNumSize + ed25519.PublicKeySize
+	signatureBlobSize      = 2 + keyNumSize + ed25519.SignatureSize
+	signifySecretKeySize   = 2 + 2 + 4 + 16 + 8 + keyNumSize + ed25519.PrivateKeySize
+	minisignSecretKeySize  = 2 + 2 + 2 + 32 + 8 + 8 + keyNumSize + ed25519.PrivateKeySize + blake2b.Size256
+)
+
+// MinisignPublicKey is an Ed25519 public key in the format of minisign, which is also the format of signify.
+type MinisignPublicKey struct {
+	KeyNum [keyNumSize]byte
+	Key    ed25519.PublicKey
+}
+
+// ID returns the key ID in the format of minisign, such as "E7620F1842B4E81F".
+func (k *MinisignPublicKey) ID() string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(k.KeyNum[:]))
+}
+
+// String returns the base64 representation of the key, such as "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3".
+func (k *MinisignPublicKey) String() string {
+	b := append(append([]byte(minisignAlgEd25519), k.KeyNum[:]...), k.Key...)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// MinisignSecretKey is an Ed25519 secret key of minisign or signify.
+type MinisignSecretKey struct {
+	KeyNum [keyNumSize]byte
+	Key    ed25519.PrivateKey
+}
+
+// Public returns the public key.
+func (k *MinisignSecretKey) Public() *MinisignPublicKey {
+	return &MinisignPublicKey{KeyNum: k.KeyNum, Key: k.Key.Public().(ed25519.PublicKey)}
+}
+
+// readMinisignLines returns the lines of the minisign (or signify) file, without the untrusted comments and the empty lines.
+func readMinisignLines(b []byte) []string {
+	var res []string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		// The trailing spaces are kept, as they are signed in the trusted comment
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, untrustedCommentPrefix) {
+			continue
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+// ParseMinisignPublicKey parses the minisign (or signify) public key.
+// s is the content of the public key file, or the base64 line that is usually passed to "minisign -P".
+func ParseMinisignPublicKey(s string) (*MinisignPublicKey, error) {
+	lines := readMinisignLines([]byte(s))
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("expected 1 line of the public key, got %d", len(lines))
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != publicKeyBlobSize || string(b[:2]) != minisignAlgEd25519 {
+		return nil, errors.New("not an Ed25519 public key of minisign or signify")
+	}
+	k := &MinisignPublicKey{Key: ed25519.PublicKey(b[2+keyNumSize:])}
+	copy(k.KeyNum[:], b[2:2+keyNumSize])
+	return k, nil
+}
+
+// ReadMinisignPublicKeys reads the minisign (or signify) public key files.
+func ReadMinisignPublicKeys(files ...string) ([]MinisignPublicKey, error) {
+	var res []MinisignPublicKey
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		k, err := ParseMinisignPublicKey(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the minisign public key %q: %w", f, err)
+		}
+		res = append(res, *k)
+	}
+	return res, nil
+}
+
+// ReadMinisignSecretKey reads the secret key file of minisign or signify.
+// The passphrase is used for decrypting the secret key of minisign, when it is encrypted.
+// The encrypted secret keys of signify are not supported.
+func ReadMinisignSecretKey(file string, passphrase []byte) (*MinisignSecretKey, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	lines := readMinisignLines(b)
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("expected 1 line of the secret key in %q, got %d", file, len(lines))
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the secret key %q: %w", file, err)
+	}
+	var k *MinisignSecretKey
+	switch {
+	case len(blob) == signifySecretKeySize && string(blob[:4]) == minisignAlgEd25519+signifyKDFAlg:
+		k, err = parseSignifySecretKey(blob)
+	case len(blob) == minisignSecretKeySize && string(blob[:2]) == minisignAlgEd25519:
+		k, err = parseMinisignSecretKey(blob, passphrase)
+	default:
+		err = errors.New("not an Ed25519 secret key of minisign or signify")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the secret key %q: %w", file, err)
+	}
+	return k, nil
+}
+
+// parseSignifySecretKey parses the secret key of signify:
+// pkalg[2] kdfalg[2] kdfrounds[4] salt[16] checksum[8] keynum[8] seckey[64].
+func parseSignifySecretKey(blob []byte) (*MinisignSecretKey, error) {
+	if rounds := binary.BigEndian.Uint32(blob[4:8]); rounds != 0 {
+		return nil, errors.New("encrypted secret keys of signify are not supported (Hint: generate the key with 'signify -G -n')")
+	}
+	checksum, rest := blob[24:32], blob[32:]
+	k := &MinisignSecretKey{Key: ed25519.PrivateKey(rest[keyNumSize:])}
+	copy(k.KeyNum[:], rest[:keyNumSize])
+	if h := sha512.Sum512(k.Key); !bytes.Equal(h[:len(checksum)], checksum) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return k, nil
+}
+
+// parseMinisignSecretKey parses the secret key of minisign:
+// sig_alg[2] kdf_alg[2] chk_alg[2] kdf_salt[32] kdf_opslimit[8] kdf_memlimit[8] keynum[8] sk[64] chk[32].
+// keynum, sk, and chk are encrypted with scrypt, when kdf_alg is "Sc".
+func parseMinisignSecretKey(blob []byte, passphrase []byte) (*MinisignSecretKey, error) {
+	if chkAlg := string(blob[4:6]); chkAlg != minisignChkAlgBLAKE2b {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", chkAlg)
+	}
+	salt := blob[6:38]
+	opsLimit, memLimit := binary.LittleEndian.Uint64(blob[38:46]), binary.LittleEndian.Uint64(blob[46:54])
+	enc := append([]byte{}, blob[54:]...)
+	switch kdfAlg := blob[2:4]; {
+	case bytes.Equal(kdfAlg, []byte{0, 0}):
+	case string(kdfAlg) == minisignKDFAlgScrypt:
+		if len(passphrase) == 0 {
+			return nil, errors.New("the secret key is encrypted, and no passphrase was specified")
+		}
+		n, r, p := scryptParams(opsLimit, memLimit)
+		stream, err := scrypt.Key(passphrase, salt, n, r, p, len(enc))
+		if err != nil {
+			return nil, err
+		}
+		for i := range enc {
+			enc[i] ^= stream[i]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported KDF algorithm %q", kdfAlg)
+	}
+	k := &MinisignSecretKey{Key: ed25519.PrivateKey(enc[keyNumSize : keyNumSize+ed25519.PrivateKeySize])}
+	copy(k.KeyNum[:], enc[:keyNumSize])
+	if chk := minisignSecretKeyChecksum(k); !bytes.Equal(chk, enc[keyNumSize+ed25519.PrivateKeySize:]) {
+		return nil, errors.New("checksum mismatch (Hint: check the passphrase)")
+	}
+	return k, nil
+}
+
+func minisignSecretKeyChecksum(k *MinisignSecretKey) []byte {
+	h, _ := blake2b.New256(nil) // never fails without a key
+	h.Write([]byte(minisignAlgEd25519))
+	h.Write(k.KeyNum[:])
+	h.Write(k.Key)
+	return h.Sum(nil)
+}
+
+// scryptParams converts the opslimit and the memlimit of libsodium's crypto_pwhash_scryptsalsa208sha256 into the scrypt parameters.
+func scryptParams(opsLimit, memLimit uint64) (n, r, p int) {
+	const minOpsLimit = 32768
+	if opsLimit < minOpsLimit {
+		opsLimit = minOpsLimit
+	}
+	r = 8
+	var maxN uint64
+	if opsLimit < memLimit/32 {
+		maxN = opsLimit / uint64(r*4)
+	} else {
+		maxN = memLimit / uint64(r*128)
+	}
+	nLog2 := 1
+	for ; nLog2 < 63; nLog2++ {
+		if uint64(1)<<nLog2 > maxN/2 {
+			break
+		}
+	}
+	p = 1
+	if opsLimit >= memLimit/32 {
+		maxRP := (opsLimit / 4) / (uint64(1) << nLog2)
+		if maxRP > 0x3fffffff {
+			maxRP = 0x3fffffff
+		}
+		p = int(maxRP) / r
+	}
+	return 1 << nLog2, r, p
+}
+
+func signatureBlob(alg string, k *MinisignSecretKey, sig []byte) string {
+	return base64.StdEncoding.EncodeToString(append(append([]byte(alg), k.KeyNum[:]...), sig...))
+}
+
+// SignFileWithMinisign writes the minisign signature of the hash file as hashFile+MinisignSignatureSuffix.
+// The timestamp is recorded in the trusted comment.
+func SignFileWithMinisign(hashFile string, k *MinisignSecretKey, timestamp time.Time) error {
+	b, err := os.ReadFile(hashFile)
+	if err != nil {
+		return err
+	}
+	h := blake2b.Sum512(b)
+	sig := ed25519.Sign(k.Key, h[:])
+	trustedComment := fmt.Sprintf("timestamp:%d\tfile:%s\thashed", timestamp.Unix(), filepath.Base(hashFile))
+	globalSig := ed25519.Sign(k.Key, append(append([]byte{}, sig...), trustedComment...))
+	s := untrustedCommentPrefix + "signature from repro-get secret key\n" +
+		signatureBlob(minisignAlgHashed, k, sig) + "\n" +
+		trustedCommentPrefix + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(globalSig) + "\n"
+	sigFile := hashFile + MinisignSignatureSuffix
+	if err = os.WriteFile(sigFile, []byte(s), 0644); err != nil {
+		return fmt.Errorf("failed to create %q: %w", sigFile, err)
+	}
+	return nil
+}
+
+// SignFileWithSignify writes the signify signature of the hash file as hashFile+SignifySignatureSuffix.
+func SignFileWithSignify(hashFile string, k *MinisignSecretKey) error {
+	b, err := os.ReadFile(hashFile)
+	if err != nil {
+		return err
+	}
+	s := untrustedCommentPrefix + "signature from repro-get secret key\n" +
+		signatureBlob(minisignAlgEd25519, k, ed25519.Sign(k.Key, b)) + "\n"
+	sigFile := hashFile + SignifySignatureSuffix
+	if err = os.WriteFile(sigFile, []byte(s), 0644); err != nil {
+		return fmt.Errorf("failed to create %q: %w", sigFile, err)
+	}
+	return nil
+}
+
+// VerifyFileWithMinisign verifies the minisign signature (hashFile+MinisignSignatureSuffix)
+// and the signify signature (hashFile+SignifySignatureSuffix) of the hash file, and returns the signer.
+// Returns an error that wraps ErrUnsigned when neither of them exists.
+func VerifyFileWithMinisign(keys []MinisignPublicKey, hashFile string) (*MinisignPublicKey, error) {
+	var signer *MinisignPublicKey
+	for _, suffix := range []string{MinisignSignatureSuffix, SignifySignatureSuffix} {
+		sigFile := hashFile + suffix
+		sig, err := os.ReadFile(sigFile)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		b, err := os.ReadFile(hashFile)
+		if err != nil {
+			return nil, err
+		}
+		k, err := verifyMinisign(keys, b, sig, suffix == SignifySignatureSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify %q with %q: %w", hashFile, sigFile, err)
+		}
+		if signer == nil {
+			signer = k
+		}
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("%w: neither %q nor %q exists", ErrUnsigned, hashFile+MinisignSignatureSuffix, hashFile+SignifySignatureSuffix)
+	}
+	return signer, nil
+}
+
+// verifyMinisign verifies the signature of b.
+// The signify signature consists of the signature line, while the minisign signature has the trusted comment and its signature too.
+func verifyMinisign(keys []MinisignPublicKey, b, sigFileContent []byte, signify bool) (*MinisignPublicKey, error) {
+	lines := readMinisignLines(sigFileContent)
+	expectedLines := 3
+	if signify {
+		expectedLines = 1
+	}
+	if len(lines) != expectedLines {
+		return nil, fmt.Errorf("expected %d lines, got %d", expectedLines, len(lines))
+	}
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) != signatureBlobSize {
+		return nil, fmt.Errorf("expected %d bytes of the signature, got %d", signatureBlobSize, len(blob))
+	}
+	alg, keyNum, sig := string(blob[:2]), blob[2:2+keyNumSize], blob[2+keyNumSize:]
+	var k *MinisignPublicKey
+	for i := range keys {
+		if bytes.Equal(keys[i].KeyNum[:], keyNum) {
+			k = &keys[i]
+			break
+		}
+	}
+	if k == nil {
+		return nil, fmt.Errorf("no public key was found for the key ID %016X", binary.LittleEndian.Uint64(keyNum))
+	}
+	msg := b
+	switch {
+	case alg == minisignAlgEd25519:
+	case alg == minisignAlgHashed && !signify:
+		h := blake2b.Sum512(b)
+		msg = h[:]
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %q", alg)
+	}
+	if !ed25519.Verify(k.Key, msg, sig) {
+		return nil, errors.New("invalid signature")
+	}
+	if signify {
+		return k, nil
+	}
+	if !strings.HasPrefix(lines[1], trustedCommentPrefix) {
+		return nil, errors.New("no trusted comment was found")
+	}
+	trustedComment := strings.TrimPrefix(lines[1], trustedCommentPrefix)
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[2]))
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(k.Key, append(append([]byte{}, sig...), trustedComment...), globalSig) {
+		return nil, errors.New("invalid signature of the trusted comment")
+	}
+	return k, nil
+}
@@ -0,0 +1,158 @@
+package hashsig
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+	"gotest.tools/v3/assert"
+)
+
+func newTestMinisignKey(t testing.TB) *MinisignSecretKey {
+	_, sk, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	k := &MinisignSecretKey{Key: sk}
+	_, err = rand.Read(k.KeyNum[:])
+	assert.NilError(t, err)
+	return k
+}
+
+// writeMinisignSecretKey writes the secret key in the format of minisign.
+// The key is encrypted with the small scrypt parameters (N=1024, r=8, p=1), when the passphrase is non-empty.
+func writeMinisignSecretKey(t testing.TB, file string, k *MinisignSecretKey, passphrase []byte) {
+	b := []byte(minisignAlgEd25519)
+	if len(passphrase) > 0 {
+		b = append(b, minisignKDFAlgScrypt...)
+	} else {
+		b = append(b, 0, 0)
+	}
+	b = append(b, minisignChkAlgBLAKE2b...)
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	assert.NilError(t, err)
+	b = append(b, salt...)
+	const opsLimit, memLimit = 32768, 16 * 1024 * 1024
+	b = binary.LittleEndian.AppendUint64(b, opsLimit)
+	b = binary.LittleEndian.AppendUint64(b, memLimit)
+	enc := append(append(append([]byte{}, k.KeyNum[:]...), k.Key...), minisignSecretKeyChecksum(k)...)
+	if len(passphrase) > 0 {
+		n, r, p := scryptParams(opsLimit, memLimit)
+		assert.Equal(t, 1024, n)
+		stream, err := scrypt.Key(passphrase, salt, n, r, p, len(enc))
+		assert.NilError(t, err)
+		for i := range enc {
+			enc[i] ^= stream[i]
+		}
+	}
+	b = append(b, enc...)
+	s := untrustedCommentPrefix + "minisign encrypted secret key\n" + base64.StdEncoding.EncodeToString(b) + "\n"
+	assert.NilError(t, os.WriteFile(file, []byte(s), 0600))
+}
+
+// writeSignifySecretKey writes the unencrypted secret key in the format of signify.
+func writeSignifySecretKey(t testing.TB, file string, k *MinisignSecretKey) {
+	b := []byte(minisignAlgEd25519 + signifyKDFAlg)
+	b = append(b, 0, 0, 0, 0)          // kdfrounds
+	b = append(b, make([]byte, 16)...) // salt
+	h := sha512.Sum512(k.Key)
+	b = append(b, h[:8]...)
+	b = append(append(b, k.KeyNum[:]...), k.Key...)
+	s := untrustedCommentPrefix + "signify secret key\n" + base64.StdEncoding.EncodeToString(b) + "\n"
+	assert.NilError(t, os.WriteFile(file, []byte(s), 0600))
+}
+
+func TestScryptParams(t *testing.T) {
+	// The defaults of minisign (crypto_pwhash_scryptsalsa208sha256_*LIMIT_SENSITIVE)
+	n, r, p := scryptParams(33554432, 1073741824)
+	assert.Equal(t, 1<<20, n)
+	assert.Equal(t, 8, r)
+	assert.Equal(t, 1, p)
+}
+
+func TestMinisignSignAndVerifyFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	signer := newTestMinisignKey(t)
+	stranger := newTestMinisignKey(t)
+	passphrase := []byte("passphrase")
+
+	minisignKeyFile := filepath.Join(dir, "minisign.key")
+	writeMinisignSecretKey(t, minisignKeyFile, signer, passphrase)
+	_, err := ReadMinisignSecretKey(minisignKeyFile, nil)
+	assert.ErrorContains(t, err, "no passphrase")
+	_, err = ReadMinisignSecretKey(minisignKeyFile, []byte("wrong"))
+	assert.ErrorContains(t, err, "checksum mismatch")
+	got, err := ReadMinisignSecretKey(minisignKeyFile, passphrase)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, signer, got)
+
+	unencryptedKeyFile := filepath.Join(dir, "unencrypted.key")
+	writeMinisignSecretKey(t, unencryptedKeyFile, signer, nil)
+	got, err = ReadMinisignSecretKey(unencryptedKeyFile, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, signer, got)
+
+	signifyKeyFile := filepath.Join(dir, "signify.sec")
+	writeSignifySecretKey(t, signifyKeyFile, signer)
+	got, err = ReadMinisignSecretKey(signifyKeyFile, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, signer, got)
+
+	pubFile := filepath.Join(dir, "minisign.pub")
+	assert.NilError(t, os.WriteFile(pubFile, []byte(untrustedCommentPrefix+"minisign public key "+signer.Public().ID()+"\n"+signer.Public().String()+"\n"), 0644))
+	keys, err := ReadMinisignPublicKeys(pubFile)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []MinisignPublicKey{*signer.Public()}, keys)
+	inline, err := ParseMinisignPublicKey(signer.Public().String())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, signer.Public(), inline)
+	strangerKeys := []MinisignPublicKey{*stranger.Public()}
+
+	for _, signify := range []bool{false, true} {
+		hashFile := filepath.Join(t.TempDir(), "SHA256SUMS")
+		assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS), 0644))
+		_, err = VerifyFileWithMinisign(keys, hashFile)
+		assert.Assert(t, errors.Is(err, ErrUnsigned))
+
+		if signify {
+			assert.NilError(t, SignFileWithSignify(hashFile, signer))
+		} else {
+			assert.NilError(t, SignFileWithMinisign(hashFile, signer, time.Unix(1667260800, 0)))
+			b, err := os.ReadFile(hashFile + MinisignSignatureSuffix)
+			assert.NilError(t, err)
+			assert.Assert(t, strings.Contains(string(b), "trusted comment: timestamp:1667260800\tfile:SHA256SUMS\thashed\n"))
+		}
+		k, err := VerifyFileWithMinisign(append(strangerKeys, keys...), hashFile)
+		assert.NilError(t, err)
+		assert.Equal(t, signer.Public().ID(), k.ID())
+		assert.NilError(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{MinisignKeys: keys, Require: true}))
+
+		_, err = VerifyFileWithMinisign(strangerKeys, hashFile)
+		assert.ErrorContains(t, err, "no public key was found")
+
+		assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS+testSHA256SUMS), 0644))
+		_, err = VerifyFileWithMinisign(keys, hashFile)
+		assert.ErrorContains(t, err, "invalid signature")
+	}
+
+	// Tampered trusted comment
+	hashFile := filepath.Join(t.TempDir(), "SHA256SUMS")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS), 0644))
+	assert.NilError(t, SignFileWithMinisign(hashFile, signer, time.Unix(1667260800, 0)))
+	sigFile := hashFile + MinisignSignatureSuffix
+	b, err := os.ReadFile(sigFile)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(sigFile, []byte(strings.Replace(string(b), "timestamp:1667260800", "timestamp:1767225600", 1)), 0644))
+	_, err = VerifyFileWithMinisign(keys, hashFile)
+	assert.ErrorContains(t, err, "invalid signature of the trusted comment")
+}
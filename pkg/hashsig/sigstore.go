@@ -0,0 +1,74 @@
+package hashsig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SigstoreBundleSuffix is the suffix of the Sigstore bundle, alongside the hash file.
+// e.g., "SHA256SUMS-amd64.sigstore.json" for "SHA256SUMS-amd64".
+// The bundle contains the signature, the short-lived certificate of the signer, and the Rekor transparency log entry.
+const SigstoreBundleSuffix = ".sigstore.json"
+
+// SigstoreOpts is the expected signer of the Sigstore bundles.
+type SigstoreOpts struct {
+	Identity   string // Regular expression of the certificate identity, such as "^https://github.com/USERNAME/REPO/"
+	OIDCIssuer string // Regular expression of the OIDC issuer, such as "^https://token.actions.githubusercontent.com$"
+}
+
+// SignFileWithSigstore signs the hash file with the keyless flow of cosign, and writes the bundle as hashFile+SigstoreBundleSuffix.
+// The signature is recorded in the Rekor transparency log.
+// The OIDC flow may open a web browser, unless an identity token is available in the environment (e.g., GitHub Actions).
+func SignFileWithSigstore(ctx context.Context, hashFile string) error {
+	cosignExe, err := exec.LookPath("cosign")
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, cosignExe, "sign-blob", "--yes", "--bundle", hashFile+SigstoreBundleSuffix, hashFile)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr // cosign prints the signature on stdout
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %v: %w", cmd.Args, err)
+	}
+	return nil
+}
+
+// VerifyFileWithSigstore verifies the Sigstore bundle of the hash file with cosign,
+// including the inclusion of the signature in the Rekor transparency log.
+// Returns an error that wraps ErrUnsigned when hashFile+SigstoreBundleSuffix does not exist.
+func VerifyFileWithSigstore(ctx context.Context, hashFile string, opts SigstoreOpts) error {
+	bundle := hashFile + SigstoreBundleSuffix
+	if _, err := os.Stat(bundle); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %q does not exist", ErrUnsigned, bundle)
+		}
+		return err
+	}
+	if opts.Identity == "" || opts.OIDCIssuer == "" {
+		return errors.New("the certificate identity and the OIDC issuer must be specified for verifying the Sigstore bundles")
+	}
+	cosignExe, err := exec.LookPath("cosign")
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, cosignExe, "verify-blob",
+		"--bundle", bundle,
+		"--certificate-identity-regexp", opts.Identity,
+		"--certificate-oidc-issuer-regexp", opts.OIDCIssuer,
+		hashFile)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("failed to verify %q with %q (stderr=%q): %w", hashFile, bundle, stderr.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package hashsig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeCosign installs a fake cosign that "signs" a blob by copying it into the bundle,
+// and "verifies" the bundle by comparing it with the blob.
+func fakeCosign(t testing.TB) {
+	binDir := t.TempDir()
+	const script = `#!/bin/sh
+set -eu
+cmd="$1"
+shift
+bundle=""
+while [ "$#" -gt 1 ]; do
+	case "$1" in
+	--bundle) bundle="$2"; shift 2 ;;
+	--certificate-identity-regexp) [ "$2" = "^https://github.com/example/" ]; shift 2 ;;
+	--certificate-oidc-issuer-regexp) shift 2 ;;
+	*) shift ;;
+	esac
+done
+case "$cmd" in
+sign-blob) cp "$1" "$bundle" ;;
+verify-blob) cmp -s "$1" "$bundle" ;;
+*) exit 1 ;;
+esac
+`
+	assert.NilError(t, os.WriteFile(filepath.Join(binDir, "cosign"), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSigstore(t *testing.T) {
+	fakeCosign(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "SHA256SUMS")
+	assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS), 0644))
+
+	opts := SigstoreOpts{
+		Identity:   "^https://github.com/example/",
+		OIDCIssuer: "^https://token.actions.githubusercontent.com$",
+	}
+	err := VerifyFileWithSigstore(ctx, hashFile, opts)
+	assert.Assert(t, errors.Is(err, ErrUnsigned))
+	assert.ErrorContains(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Sigstore: &opts, Require: true}), "no valid signature")
+
+	assert.NilError(t, SignFileWithSigstore(ctx, hashFile))
+	assert.NilError(t, VerifyFileWithSigstore(ctx, hashFile, opts))
+	assert.NilError(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Sigstore: &opts, Require: true}))
+
+	stranger := opts
+	stranger.Identity = "^https://github.com/stranger/"
+	assert.ErrorContains(t, VerifyFileWithSigstore(ctx, hashFile, stranger), "failed to verify")
+	assert.ErrorContains(t, VerifyFileWithSigstore(ctx, hashFile, SigstoreOpts{}), "must be specified")
+
+	assert.NilError(t, os.WriteFile(hashFile, []byte(testSHA256SUMS+testSHA256SUMS), 0644))
+	assert.ErrorContains(t, VerifyFiles(ctx, []string{hashFile}, VerifyOpts{Sigstore: &opts}), "failed to verify")
+}
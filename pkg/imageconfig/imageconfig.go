@@ -0,0 +1,300 @@
+// Package imageconfig implements the declarative image config ("image.yaml"), inspired by apko.
+//
+// The image config describes the distro, the packages, and the metadata of an image.
+// The hash files ("SHA256SUMS-<ARCH>") are resolved from the packages with `repro-get image lock`,
+// and the image is built with `repro-get image build`, or with the Dockerfile generated by `repro-get image dockerfile`.
+package imageconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/reproducible-containers/repro-get/pkg/archutil"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFilename is the default file name of the image config.
+const DefaultFilename = "image.yaml"
+
+// Config is the image config, in YAML.
+type Config struct {
+	Distro        string            `yaml:"Distro"`                  // "debian", "ubuntu", "fedora", "alpine", ...
+	BaseImage     string            `yaml:"BaseImage,omitempty"`     // Needed only for `repro-get image dockerfile`, such as "debian:bullseye-20211220"
+	Packages      []string          `yaml:"Packages"`                // Resolved with the dependencies. Should contain the essential packages for `repro-get image build`.
+	Archs         []string          `yaml:"Archs,omitempty"`         // Such as "amd64", "arm64", and "arm-v7". Defaults to the host architecture.
+	Snapshot      string            `yaml:"Snapshot,omitempty"`      // Recorded in the hash files as "#repro-get:snapshot=...", such as "20211220T000000Z" (Debian only)
+	Indexes       []string          `yaml:"Indexes,omitempty"`       // Repository index files or URLs to resolve the packages from (Alpine only)
+	TargetRelease string            `yaml:"TargetRelease,omitempty"` // Such as "bullseye-backports" (Debian and Ubuntu only)
+	Entrypoint    []string          `yaml:"Entrypoint,omitempty"`
+	Cmd           []string          `yaml:"Cmd,omitempty"`
+	Env           []string          `yaml:"Env,omitempty"` // "KEY=VALUE"; PATH defaults to the standard value
+	WorkingDir    string            `yaml:"WorkingDir,omitempty"`
+	User          string            `yaml:"User,omitempty"`  // Such as "app", "1000", and "1000:1000"
+	Users         []User            `yaml:"Users,omitempty"` // Created in /etc/passwd, /etc/group, and /etc/shadow
+	Annotations   map[string]string `yaml:"Annotations,omitempty"`
+}
+
+// User is a user to be created in the image. The primary group with the same name is created too.
+type User struct {
+	Name  string `yaml:"Name"`
+	UID   int    `yaml:"UID"`
+	GID   int    `yaml:"GID,omitempty"`   // Defaults to UID
+	Home  string `yaml:"Home,omitempty"`  // Defaults to "/home/<NAME>"
+	Shell string `yaml:"Shell,omitempty"` // Defaults to "/bin/sh"
+}
+
+var userNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+
+// Load loads the image config file.
+func Load(f string) (*Config, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %q: %w", f, err)
+	}
+	return cfg, nil
+}
+
+// Parse parses the image config, and fills the default values.
+func Parse(b []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty image config")
+		}
+		return nil, fmt.Errorf("failed to parse the image config: %w", err)
+	}
+	if cfg.Distro == "" {
+		return nil, errors.New("Distro needs to be specified")
+	}
+	if len(cfg.Packages) == 0 {
+		return nil, errors.New("Packages needs to be specified")
+	}
+	if len(cfg.Archs) == 0 {
+		cfg.Archs = []string{archutil.OCIArchDashVariant()}
+	}
+	for _, e := range cfg.Env {
+		if !strings.Contains(e, "=") {
+			return nil, fmt.Errorf("invalid Env %q, expected \"KEY=VALUE\"", e)
+		}
+	}
+	seen := make(map[string]struct{}, len(cfg.Users))
+	for i := range cfg.Users {
+		u := &cfg.Users[i]
+		if !userNameRegexp.MatchString(u.Name) {
+			return nil, fmt.Errorf("invalid user name %q", u.Name)
+		}
+		if _, ok := seen[u.Name]; ok {
+			return nil, fmt.Errorf("duplicate user %q", u.Name)
+		}
+		seen[u.Name] = struct{}{}
+		if u.UID <= 0 || u.GID < 0 {
+			return nil, fmt.Errorf("user %q needs a positive UID", u.Name)
+		}
+		if u.GID == 0 {
+			u.GID = u.UID
+		}
+		if u.Home == "" {
+			u.Home = "/home/" + u.Name
+		}
+		if !filepath.IsAbs(u.Home) {
+			return nil, fmt.Errorf("the home directory of user %q has to be an absolute path, got %q", u.Name, u.Home)
+		}
+		if u.Shell == "" {
+			u.Shell = "/bin/sh"
+		}
+	}
+	return &cfg, nil
+}
+
+// HashFile returns the file name of the hash file for the architecture, such as "SHA256SUMS-amd64".
+func HashFile(arch string) string {
+	return "SHA256SUMS-" + arch
+}
+
+// ImageConfig returns the OCI image config.
+func (cfg *Config) ImageConfig() ocispec.ImageConfig {
+	return ocispec.ImageConfig{
+		User:       cfg.User,
+		Env:        cfg.Env,
+		Entrypoint: cfg.Entrypoint,
+		Cmd:        cfg.Cmd,
+		WorkingDir: cfg.WorkingDir,
+	}
+}
+
+func (u *User) passwdLine() string {
+	return fmt.Sprintf("%s:x:%d:%d::%s:%s", u.Name, u.UID, u.GID, u.Home, u.Shell)
+}
+
+func (u *User) groupLine() string {
+	return fmt.Sprintf("%s:x:%d:", u.Name, u.GID)
+}
+
+func (u *User) shadowLine() string {
+	return u.Name + ":!:::::::"
+}
+
+// CreateUsers creates the users in the root filesystem.
+// The files are created when missing, except /etc/shadow.
+// The home directories are owned by the users only when executed as the root user; see HomeDirs.
+func (cfg *Config) CreateUsers(root string) error {
+	for _, u := range cfg.Users {
+		files := map[string]string{
+			"/etc/passwd": u.passwdLine(),
+			"/etc/group":  u.groupLine(),
+			"/etc/shadow": u.shadowLine(),
+		}
+		for _, f := range []string{"/etc/passwd", "/etc/group", "/etc/shadow"} {
+			p, err := securejoin.SecureJoin(root, f)
+			if err != nil {
+				return err
+			}
+			flags := os.O_WRONLY | os.O_APPEND
+			if f != "/etc/shadow" {
+				flags |= os.O_CREATE
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					return err
+				}
+			}
+			if err := appendLine(p, flags, files[f]); err != nil {
+				if f == "/etc/shadow" && errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return fmt.Errorf("failed to add user %q to %q: %w", u.Name, f, err)
+			}
+		}
+		home, err := securejoin.SecureJoin(root, u.Home)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(home, 0755); err != nil {
+			return err
+		}
+		if os.Geteuid() == 0 {
+			if err := os.Lchown(home, u.UID, u.GID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func appendLine(p string, flags int, line string) error {
+	f, err := os.OpenFile(p, flags, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, line)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// HomeDirs returns the home directories of the users, keyed by the paths relative to the root, such as "home/app".
+func (cfg *Config) HomeDirs() map[string]User {
+	m := make(map[string]User, len(cfg.Users))
+	for _, u := range cfg.Users {
+		m[strings.TrimPrefix(filepath.Clean(u.Home), "/")] = u
+	}
+	return m
+}
+
+// DockerfileInstructions returns the Dockerfile instructions for the users and the metadata,
+// to be appended to the Dockerfile generated by the distro driver.
+// The annotations are converted to the labels.
+func (cfg *Config) DockerfileInstructions() (string, error) {
+	var b strings.Builder
+	if len(cfg.Users) > 0 {
+		// The modification times of the modified files are reset to the original time of /etc/passwd, for reproducibility
+		script := []string{"set -eu", "touch -r /etc/passwd /dev/.repro-get-passwd"}
+		touched := []string{"/etc/passwd", "/etc/group"}
+		for _, u := range cfg.Users {
+			script = append(script,
+				"echo "+shellQuote(u.passwdLine())+" >>/etc/passwd",
+				"echo "+shellQuote(u.groupLine())+" >>/etc/group",
+				"if [ -e /etc/shadow ]; then echo "+shellQuote(u.shadowLine())+" >>/etc/shadow; fi",
+				"mkdir -p "+shellQuote(u.Home),
+				fmt.Sprintf("chown %d:%d %s", u.UID, u.GID, shellQuote(u.Home)),
+			)
+			touched = append(touched, filepath.Dir(u.Home), u.Home)
+		}
+		var quoted []string
+		for _, f := range touched {
+			quoted = append(quoted, shellQuote(f))
+		}
+		script = append(script,
+			"if [ -e /etc/shadow ]; then touch -h -r /dev/.repro-get-passwd /etc/shadow; fi",
+			"touch -h -r /dev/.repro-get-passwd "+strings.Join(quoted, " "),
+		)
+		if err := writeInstruction(&b, "RUN", []string{"/bin/sh", "-c", strings.Join(script, "\n")}); err != nil {
+			return "", err
+		}
+	}
+	if len(cfg.Annotations) > 0 {
+		keys := make([]string, 0, len(cfg.Annotations))
+		for k := range cfg.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var labels []string
+		for _, k := range keys {
+			labels = append(labels, strconv.Quote(k)+"="+strconv.Quote(cfg.Annotations[k]))
+		}
+		fmt.Fprintln(&b, "LABEL "+strings.Join(labels, " "))
+	}
+	for _, e := range cfg.Env {
+		k, v, _ := strings.Cut(e, "=")
+		fmt.Fprintln(&b, "ENV "+k+"="+strconv.Quote(v))
+	}
+	if cfg.WorkingDir != "" {
+		fmt.Fprintln(&b, "WORKDIR "+cfg.WorkingDir)
+	}
+	if cfg.User != "" {
+		fmt.Fprintln(&b, "USER "+cfg.User)
+	}
+	if len(cfg.Entrypoint) > 0 {
+		if err := writeInstruction(&b, "ENTRYPOINT", cfg.Entrypoint); err != nil {
+			return "", err
+		}
+	}
+	if len(cfg.Cmd) > 0 {
+		if err := writeInstruction(&b, "CMD", cfg.Cmd); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// writeInstruction writes the instruction in the exec form, such as `CMD ["/bin/sh"]`.
+func writeInstruction(w io.Writer, instruction string, args []string) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false) // Keep ">" as is
+	if err := enc.Encode(args); err != nil {
+		return err
+	}
+	_, err := fmt.Fprint(w, instruction+" "+b.String()) // b ends with "\n"
+	return err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,89 @@
+package imageconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`Distro: debian
+Packages: [bash, hello]
+Archs: [amd64]
+Entrypoint: [/usr/bin/hello]
+Users:
+  - Name: app
+    UID: 1000
+User: app
+Annotations:
+  org.opencontainers.image.source: https://example.com/foo.git
+`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []User{{Name: "app", UID: 1000, GID: 1000, Home: "/home/app", Shell: "/bin/sh"}}, cfg.Users)
+	imgConfig := cfg.ImageConfig()
+	assert.Equal(t, "app", imgConfig.User)
+	assert.DeepEqual(t, []string{"/usr/bin/hello"}, imgConfig.Entrypoint)
+	assert.DeepEqual(t, map[string]User{"home/app": cfg.Users[0]}, cfg.HomeDirs())
+
+	for _, tc := range []struct {
+		s        string
+		expected string
+	}{
+		{"", "empty"},
+		{"Packages: [bash]\n", "Distro"},
+		{"Distro: debian\n", "Packages"},
+		{"Distro: debian\nPackages: [bash]\nUnknown: foo\n", "not found"},
+		{"Distro: debian\nPackages: [bash]\nEnv: [FOO]\n", "invalid Env"},
+		{"Distro: debian\nPackages: [bash]\nUsers: [{Name: App, UID: 1000}]\n", "invalid user name"},
+		{"Distro: debian\nPackages: [bash]\nUsers: [{Name: app}]\n", "positive UID"},
+		{"Distro: debian\nPackages: [bash]\nUsers: [{Name: app, UID: 1000}, {Name: app, UID: 1001}]\n", "duplicate"},
+		{"Distro: debian\nPackages: [bash]\nUsers: [{Name: app, UID: 1000, Home: home}]\n", "absolute"},
+	} {
+		_, err = Parse([]byte(tc.s))
+		assert.ErrorContains(t, err, tc.expected, tc.s)
+	}
+}
+
+func TestCreateUsers(t *testing.T) {
+	root := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, "etc"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "etc/passwd"), []byte("root:x:0:0:root:/root:/bin/bash\n"), 0644))
+	cfg := &Config{Users: []User{{Name: "app", UID: 1000, GID: 1001, Home: "/srv/app", Shell: "/bin/bash"}}}
+	assert.NilError(t, cfg.CreateUsers(root))
+
+	b, err := os.ReadFile(filepath.Join(root, "etc/passwd"))
+	assert.NilError(t, err)
+	assert.Equal(t, "root:x:0:0:root:/root:/bin/bash\napp:x:1000:1001::/srv/app:/bin/bash\n", string(b))
+	b, err = os.ReadFile(filepath.Join(root, "etc/group"))
+	assert.NilError(t, err)
+	assert.Equal(t, "app:x:1001:\n", string(b))
+	_, err = os.Stat(filepath.Join(root, "etc/shadow"))
+	assert.Assert(t, os.IsNotExist(err))
+	st, err := os.Stat(filepath.Join(root, "srv/app"))
+	assert.NilError(t, err)
+	assert.Assert(t, st.IsDir())
+}
+
+func TestDockerfileInstructions(t *testing.T) {
+	cfg := &Config{
+		Entrypoint:  []string{"/usr/bin/hello"},
+		Cmd:         []string{"--greeting", "hi"},
+		Env:         []string{"FOO=foo bar"},
+		WorkingDir:  "/home/app",
+		User:        "app",
+		Users:       []User{{Name: "app", UID: 1000, GID: 1000, Home: "/home/app", Shell: "/bin/sh"}},
+		Annotations: map[string]string{"org.opencontainers.image.source": "https://example.com/foo.git", "org.opencontainers.image.licenses": "MIT"},
+	}
+	s, err := cfg.DockerfileInstructions()
+	assert.NilError(t, err)
+	assert.Equal(t, `RUN ["/bin/sh","-c","set -eu\ntouch -r /etc/passwd /dev/.repro-get-passwd\necho 'app:x:1000:1000::/home/app:/bin/sh' >>/etc/passwd\necho 'app:x:1000:' >>/etc/group\nif [ -e /etc/shadow ]; then echo 'app:!:::::::' >>/etc/shadow; fi\nmkdir -p '/home/app'\nchown 1000:1000 '/home/app'\nif [ -e /etc/shadow ]; then touch -h -r /dev/.repro-get-passwd /etc/shadow; fi\ntouch -h -r /dev/.repro-get-passwd '/etc/passwd' '/etc/group' '/home' '/home/app'"]
+LABEL "org.opencontainers.image.licenses"="MIT" "org.opencontainers.image.source"="https://example.com/foo.git"
+ENV FOO="foo bar"
+WORKDIR /home/app
+USER app
+ENTRYPOINT ["/usr/bin/hello"]
+CMD ["--greeting","hi"]
+`, s)
+}
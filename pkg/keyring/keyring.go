@@ -0,0 +1,299 @@
+// Package keyring manages the directory of the trusted keys for verifying the repository metadata
+// (InRelease, Release.gpg, and APKINDEX.tar.gz) and the hash files.
+//
+// The OpenPGP keys are stored as "<FINGERPRINT>.asc" (ASCII-armored, public keys only).
+// The RSA keys for Alpine are stored with their original file names, such as "alpine-devel@lists.alpinelinux.org-6165ee59.rsa.pub",
+// as the signatures of APKINDEX.tar.gz refer to the keys by the file names.
+// The minisign (and signify) public keys are stored as "<KEY ID>.minisign.pub".
+package keyring
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"golang.org/x/crypto/openpgp"       //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"golang.org/x/crypto/openpgp/armor" //nolint:staticcheck // Ditto
+)
+
+// KeyType is the type of a key.
+type KeyType string
+
+const (
+	OpenPGP  = KeyType("openpgp")  // For InRelease, Release.gpg, and the signatures of the hash files (SHA256SUMS.asc)
+	RSA      = KeyType("rsa")      // For APKINDEX.tar.gz
+	Minisign = KeyType("minisign") // For the signatures of the hash files (SHA256SUMS.minisig, SHA256SUMS.sig)
+)
+
+// RSASuffix is the suffix of the file names of the RSA keys.
+const RSASuffix = ".rsa.pub"
+
+// MinisignSuffix is the suffix of the file names of the minisign keys.
+const MinisignSuffix = ".minisign.pub"
+
+// Key is a key in the directory.
+type Key struct {
+	Type    KeyType  `json:"Type"`
+	ID      string   `json:"ID"`                // The fingerprint in uppercase hex for OpenPGP, the file name without RSASuffix for RSA, the key ID for minisign
+	UserIDs []string `json:"UserIDs,omitempty"` // OpenPGP only
+	File    string   `json:"File"`
+}
+
+// Dir is the directory of the trusted keys.
+type Dir struct {
+	dir string
+}
+
+// New returns Dir. The directory is created on Add.
+func New(dir string) *Dir {
+	return &Dir{dir: dir}
+}
+
+// Path returns the path of the directory.
+func (d *Dir) Path() string {
+	return d.dir
+}
+
+// Add adds the keys in b, and returns the added keys.
+// b is a binary or ASCII-armored OpenPGP keyring, a PEM-encoded RSA public key, or a minisign (or signify) public key.
+// name is the original file name of b, and is used as the file name of the RSA key.
+// The existing keys with the same IDs are overwritten.
+func (d *Dir) Add(name string, b []byte) ([]Key, error) {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(b)
+	if bytes.HasPrefix(trimmed, []byte("-----BEGIN PUBLIC KEY-----")) || bytes.HasPrefix(trimmed, []byte("-----BEGIN RSA PUBLIC KEY-----")) {
+		k, err := d.addRSA(name, b)
+		if err != nil {
+			return nil, err
+		}
+		return []Key{*k}, nil
+	}
+	if mk, err := hashsig.ParseMinisignPublicKey(string(b)); err == nil {
+		k := &Key{
+			Type: Minisign,
+			ID:   mk.ID(),
+			File: filepath.Join(d.dir, mk.ID()+MinisignSuffix),
+		}
+		if err = os.WriteFile(k.File, b, 0644); err != nil {
+			return nil, err
+		}
+		return []Key{*k}, nil
+	}
+	el, err := readOpenPGP(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q as an OpenPGP keyring, an RSA public key, or a minisign public key: %w", name, err)
+	}
+	if len(el) == 0 {
+		return nil, fmt.Errorf("no key was found in %q", name)
+	}
+	res := make([]Key, 0, len(el))
+	for _, e := range el {
+		k := openPGPKey(e)
+		k.File = filepath.Join(d.dir, k.ID+".asc")
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err = e.Serialize(w); err != nil {
+			return nil, fmt.Errorf("failed to serialize the key %s: %w", k.ID, err)
+		}
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+		if err = os.WriteFile(k.File, buf.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+		res = append(res, k)
+	}
+	return res, nil
+}
+
+func (d *Dir) addRSA(name string, b []byte) (*Key, error) {
+	base := filepath.Base(name)
+	if !strings.HasSuffix(base, RSASuffix) || base == RSASuffix {
+		return nil, fmt.Errorf("the file name of the RSA key has to end with %q, as the signatures refer to the keys by the file names, got %q", RSASuffix, name)
+	}
+	if _, err := parseRSA(b); err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", name, err)
+	}
+	k := &Key{
+		Type: RSA,
+		ID:   strings.TrimSuffix(base, RSASuffix),
+		File: filepath.Join(d.dir, base),
+	}
+	if err := os.WriteFile(k.File, b, 0644); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// List lists the keys, in the order of the file names.
+// An empty list is returned when the directory does not exist.
+func (d *Dir) List() ([]Key, error) {
+	ents, err := os.ReadDir(d.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := make([]string, 0, len(ents))
+	for _, ent := range ents {
+		if ent.Type().IsRegular() {
+			names = append(names, ent.Name())
+		}
+	}
+	sort.Strings(names)
+	var res []Key
+	for _, name := range names {
+		f := filepath.Join(d.dir, name)
+		switch {
+		case strings.HasSuffix(name, RSASuffix):
+			res = append(res, Key{Type: RSA, ID: strings.TrimSuffix(name, RSASuffix), File: f})
+		case strings.HasSuffix(name, MinisignSuffix):
+			res = append(res, Key{Type: Minisign, ID: strings.TrimSuffix(name, MinisignSuffix), File: f})
+		case strings.HasSuffix(name, ".asc"):
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			el, err := readOpenPGP(b)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %q: %w", f, err)
+			}
+			for _, e := range el {
+				k := openPGPKey(e)
+				k.File = f
+				res = append(res, k)
+			}
+		}
+	}
+	return res, nil
+}
+
+// Files returns the files of the keys of the type, without duplicates.
+func (d *Dir) Files(t KeyType) ([]string, error) {
+	keys, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, k := range keys {
+		if k.Type == t && (len(res) == 0 || res[len(res)-1] != k.File) {
+			res = append(res, k.File)
+		}
+	}
+	return res, nil
+}
+
+// Remove removes the key, and returns the removed key.
+// id is the ID, the OpenPGP key ID (the last 16 or 8 hex digits of the fingerprint), or the file name of the key.
+func (d *Dir) Remove(id string) (*Key, error) {
+	keys, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Key
+	for _, k := range keys {
+		if k.matches(id) {
+			matches = append(matches, k)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("key %q was not found in %q", id, d.dir)
+	case 1:
+	default:
+		return nil, fmt.Errorf("key %q is ambiguous (%d keys matched)", id, len(matches))
+	}
+	k := matches[0]
+	if err = os.Remove(k.File); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (k *Key) matches(id string) bool {
+	if strings.EqualFold(k.ID, id) || filepath.Base(k.File) == id {
+		return true
+	}
+	if k.Type == OpenPGP {
+		s := strings.TrimPrefix(strings.ToUpper(strings.ReplaceAll(id, " ", "")), "0X")
+		return (len(s) == 8 || len(s) == 16) && strings.HasSuffix(k.ID, s)
+	}
+	return false
+}
+
+func openPGPKey(e *openpgp.Entity) Key {
+	k := Key{
+		Type: OpenPGP,
+		ID:   strings.ToUpper(hex.EncodeToString(e.PrimaryKey.Fingerprint[:])),
+	}
+	for name := range e.Identities {
+		k.UserIDs = append(k.UserIDs, name)
+	}
+	sort.Strings(k.UserIDs)
+	return k
+}
+
+// readOpenPGP reads a binary or ASCII-armored OpenPGP keyring.
+func readOpenPGP(b []byte) (openpgp.EntityList, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
+
+// parseRSA parses a PEM-encoded RSA public key, in the PKIX or the PKCS #1 format.
+func parseRSA(b []byte) (*rsa.PublicKey, error) {
+	blk, _ := pem.Decode(b)
+	if blk == nil {
+		return nil, errors.New("no PEM block was found")
+	}
+	if blk.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(blk.Bytes)
+	}
+	pub, err := x509.ParsePKIXPublicKey(blk.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA public key, got %T", pub)
+	}
+	return rsaPub, nil
+}
+
+// ReadRSAKeys reads the RSA public keys, keyed by the file names, such as "alpine-devel@lists.alpinelinux.org-6165ee59.rsa.pub".
+// The files without RSASuffix are ignored.
+func ReadRSAKeys(files ...string) (map[string]*rsa.PublicKey, error) {
+	res := make(map[string]*rsa.PublicKey)
+	for _, f := range files {
+		if !strings.HasSuffix(f, RSASuffix) {
+			continue
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := parseRSA(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", f, err)
+		}
+		res[filepath.Base(f)] = pub
+	}
+	return res, nil
+}
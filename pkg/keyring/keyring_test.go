@@ -0,0 +1,125 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/hashsig"
+	"golang.org/x/crypto/openpgp"       //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"golang.org/x/crypto/openpgp/armor" //nolint:staticcheck // Ditto
+	"gotest.tools/v3/assert"
+)
+
+func testOpenPGPKey(t *testing.T, name string, armored bool) (*openpgp.Entity, []byte) {
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	assert.NilError(t, err)
+	assert.NilError(t, e.SerializePrivate(io.Discard, nil)) // Sign the identities
+	var buf bytes.Buffer
+	if !armored {
+		assert.NilError(t, e.Serialize(&buf))
+		return e, buf.Bytes()
+	}
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	assert.NilError(t, err)
+	assert.NilError(t, e.Serialize(w))
+	assert.NilError(t, w.Close())
+	return e, buf.Bytes()
+}
+
+func testRSAKey(t *testing.T) []byte {
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NilError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	assert.NilError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func testMinisignKey(t *testing.T) []byte {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	b := append([]byte("Ed\x01\x23\x45\x67\x89\xab\xcd\xef"), pub...)
+	return []byte("untrusted comment: minisign public key EFCDAB8967452301\n" + base64.StdEncoding.EncodeToString(b) + "\n")
+}
+
+func TestDir(t *testing.T) {
+	d := New(filepath.Join(t.TempDir(), "keys"))
+	keys, err := d.List()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(keys))
+
+	foo, fooKey := testOpenPGPKey(t, "foo", true)
+	added, err := d.Add("foo.asc", fooKey)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(added))
+	assert.Equal(t, OpenPGP, added[0].Type)
+	assert.DeepEqual(t, []string{"foo <foo@example.com>"}, added[0].UserIDs)
+	fooID := added[0].ID
+	assert.Equal(t, 40, len(fooID))
+	assert.Equal(t, filepath.Join(d.Path(), fooID+".asc"), added[0].File)
+
+	_, barKey := testOpenPGPKey(t, "bar", false)
+	_, err = d.Add("bar.gpg", barKey)
+	assert.NilError(t, err)
+
+	rsaKey := testRSAKey(t)
+	_, err = d.Add("test.pub", rsaKey)
+	assert.ErrorContains(t, err, RSASuffix)
+	added, err = d.Add("/etc/apk/keys/test-61234567.rsa.pub", rsaKey)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Key{{Type: RSA, ID: "test-61234567", File: filepath.Join(d.Path(), "test-61234567.rsa.pub")}}, added)
+
+	added, err = d.Add("minisign.pub", testMinisignKey(t))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []Key{{Type: Minisign, ID: "EFCDAB8967452301", File: filepath.Join(d.Path(), "EFCDAB8967452301.minisign.pub")}}, added)
+
+	_, err = d.Add("garbage", []byte("garbage"))
+	assert.ErrorContains(t, err, "failed to read")
+
+	keys, err = d.List()
+	assert.NilError(t, err)
+	assert.Equal(t, 4, len(keys))
+
+	files, err := d.Files(OpenPGP)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(files))
+	b, err := os.ReadFile(filepath.Join(d.Path(), fooID+".asc"))
+	assert.NilError(t, err)
+	el, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	assert.NilError(t, err)
+	assert.Equal(t, foo.PrimaryKey.KeyId, el[0].PrimaryKey.KeyId)
+
+	files, err = d.Files(RSA)
+	assert.NilError(t, err)
+	rsaKeys, err := ReadRSAKeys(files...)
+	assert.NilError(t, err)
+	assert.Assert(t, rsaKeys["test-61234567.rsa.pub"] != nil)
+
+	files, err = d.Files(Minisign)
+	assert.NilError(t, err)
+	minisignKeys, err := hashsig.ReadMinisignPublicKeys(files...)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(minisignKeys))
+
+	_, err = d.Remove("0123")
+	assert.ErrorContains(t, err, "not found")
+	removed, err := d.Remove(fooID[len(fooID)-16:])
+	assert.NilError(t, err)
+	assert.Equal(t, fooID, removed.ID)
+	_, err = d.Remove("test-61234567.rsa.pub")
+	assert.NilError(t, err)
+	_, err = d.Remove("EFCDAB8967452301")
+	assert.NilError(t, err)
+	keys, err = d.List()
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(keys))
+	assert.DeepEqual(t, []string{"bar <bar@example.com>"}, keys[0].UserIDs)
+}
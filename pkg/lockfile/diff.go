@@ -0,0 +1,156 @@
+package lockfile
+
+import (
+	"sort"
+
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+)
+
+// DiffType is the type of a DiffEntry.
+type DiffType string
+
+const (
+	DiffAdded    DiffType = "added"
+	DiffRemoved  DiffType = "removed"
+	DiffUpgraded DiffType = "upgraded" // The version was changed (upgraded or downgraded)
+	// DiffSuspicious is a package whose digest was changed without changing the version.
+	// This should not happen for the legitimate updates of the packages.
+	DiffSuspicious DiffType = "suspicious"
+)
+
+// DiffEntry is an entry of Diff.
+type DiffEntry struct {
+	Type DiffType `json:"Type"`
+	Old  *Package `json:"Old,omitempty"` // nil for DiffAdded
+	New  *Package `json:"New,omitempty"` // nil for DiffRemoved
+}
+
+// Name returns the name of the new package, or the old package if removed.
+func (e *DiffEntry) Name() string {
+	if e.New != nil {
+		return e.New.Name
+	}
+	return e.Old.Name
+}
+
+// ArchSection returns the architecture section of the new package, or the old package if removed.
+func (e *DiffEntry) ArchSection() string {
+	if e.New != nil {
+		return e.New.ArchSection
+	}
+	return e.Old.ArchSection
+}
+
+// Diff compares the packages of the lockfiles.
+//
+// The packages with the same file name are compared by their sums of the common algorithms.
+// When they have no common algorithm, the change is reported as DiffSuspicious too, as it cannot be verified.
+// The rest of the packages are paired by the package name and the architecture, as DiffUpgraded,
+// when the pair is unique in both lockfiles.
+//
+// The packages in the different architecture sections are not compared.
+//
+// The result is sorted by the architecture section and the name.
+func Diff(old, new *Lockfile) []DiffEntry {
+	oldByName := make(map[string]*Package, len(old.Packages)) // key: ArchSection and name
+	for i := range old.Packages {
+		oldByName[old.Packages[i].sectionKey()] = &old.Packages[i]
+	}
+	var (
+		res     []DiffEntry
+		added   []*Package
+		matched = make(map[string]bool)
+	)
+	for i := range new.Packages {
+		newPkg := &new.Packages[i]
+		oldPkg, ok := oldByName[newPkg.sectionKey()]
+		if !ok {
+			added = append(added, newPkg)
+			continue
+		}
+		matched[newPkg.sectionKey()] = true
+		if !sameSums(oldPkg, newPkg) {
+			res = append(res, DiffEntry{Type: DiffSuspicious, Old: oldPkg, New: newPkg})
+		}
+	}
+	var removed []*Package
+	for i := range old.Packages {
+		if oldPkg := &old.Packages[i]; !matched[oldPkg.sectionKey()] {
+			removed = append(removed, oldPkg)
+		}
+	}
+
+	removedByKey := groupByKey(removed)
+	addedByKey := groupByKey(added)
+	for _, newPkg := range added {
+		k := newPkg.key()
+		if k != "" && len(addedByKey[k]) == 1 && len(removedByKey[k]) == 1 {
+			oldPkg := removedByKey[k][0]
+			typ := DiffUpgraded
+			if oldPkg.Version == newPkg.Version {
+				typ = DiffSuspicious
+			}
+			res = append(res, DiffEntry{Type: typ, Old: oldPkg, New: newPkg})
+			continue
+		}
+		res = append(res, DiffEntry{Type: DiffAdded, New: newPkg})
+	}
+	for _, oldPkg := range removed {
+		k := oldPkg.key()
+		if k != "" && len(addedByKey[k]) == 1 && len(removedByKey[k]) == 1 {
+			continue // paired above
+		}
+		res = append(res, DiffEntry{Type: DiffRemoved, Old: oldPkg})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if a, b := res[i].ArchSection(), res[j].ArchSection(); a != b {
+			return a < b
+		}
+		return res[i].Name() < res[j].Name()
+	})
+	return res
+}
+
+// key returns the architecture section, the package name, and the architecture,
+// or an empty string if the package name is unknown.
+func (pkg *Package) key() string {
+	if pkg.Package == "" {
+		return ""
+	}
+	return pkg.ArchSection + "\x00" + pkg.Package + "\x00" + pkg.Architecture
+}
+
+func groupByKey(pkgs []*Package) map[string][]*Package {
+	m := make(map[string][]*Package)
+	for _, pkg := range pkgs {
+		if k := pkg.key(); k != "" {
+			m[k] = append(m[k], pkg)
+		}
+	}
+	return m
+}
+
+// sameSums returns true when the packages have the same sums for all the common algorithms,
+// and have at least one common algorithm.
+func sameSums(a, b *Package) bool {
+	aSums := make(map[string]string)
+	for _, sum := range a.Sums() {
+		if d, err := sha256sums.ParseSum(sum); err == nil {
+			aSums[d.Algorithm().String()] = d.Encoded()
+		}
+	}
+	var common int
+	for _, sum := range b.Sums() {
+		d, err := sha256sums.ParseSum(sum)
+		if err != nil {
+			return false
+		}
+		if v, ok := aSums[d.Algorithm().String()]; ok {
+			if v != d.Encoded() {
+				return false
+			}
+			common++
+		}
+	}
+	return common > 0
+}
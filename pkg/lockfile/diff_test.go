@@ -0,0 +1,54 @@
+package lockfile
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDiff(t *testing.T) {
+	const oldSHA256SUMS = `35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/c/coreutils/coreutils_8.32-4+b1_amd64.deb
+2222222222222222222222222222222222222222222222222222222222222222  pool/main/r/removed/removed_1.0-1_amd64.deb
+3333333333333333333333333333333333333333333333333333333333333333  pool/main/l/libc6/libc6_2.31-13_amd64.deb
+`
+	const newSHA256SUMS = `4444444444444444444444444444444444444444444444444444444444444444  pool/main/h/hello/hello_2.10-3_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+5555555555555555555555555555555555555555555555555555555555555555  pool/main/c/coreutils/coreutils_8.32-4+b1_amd64.deb
+6666666666666666666666666666666666666666666666666666666666666666  pool/main/a/added/added_1.0-1_amd64.deb
+7777777777777777777777777777777777777777777777777777777777777777  pool/main/l/libc6/libc6_2.31-13_arm64.deb
+`
+	oldLF, err := FromSHA256SUMS([]byte(oldSHA256SUMS))
+	assert.NilError(t, err)
+	newLF, err := FromSHA256SUMS([]byte(newSHA256SUMS))
+	assert.NilError(t, err)
+
+	var got []string
+	for _, e := range Diff(oldLF, newLF) {
+		got = append(got, string(e.Type)+" "+e.Name())
+	}
+	assert.DeepEqual(t, []string{
+		"added pool/main/a/added/added_1.0-1_amd64.deb",
+		"suspicious pool/main/c/coreutils/coreutils_8.32-4+b1_amd64.deb",
+		"upgraded pool/main/h/hello/hello_2.10-3_amd64.deb",
+		"removed pool/main/l/libc6/libc6_2.31-13_amd64.deb",
+		"added pool/main/l/libc6/libc6_2.31-13_arm64.deb",
+		"removed pool/main/r/removed/removed_1.0-1_amd64.deb",
+	}, got)
+
+	assert.Equal(t, 0, len(Diff(oldLF, oldLF)))
+}
+
+func TestDiffAlgorithms(t *testing.T) {
+	oldLF := &Lockfile{Packages: []Package{{Name: "foo", SHA256: "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"}}}
+	newLF := &Lockfile{Packages: []Package{{Name: "foo", SHA256: "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		SHA512: "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"}}}
+	assert.Equal(t, 0, len(Diff(oldLF, newLF)))
+
+	// No common algorithm, so the change cannot be verified
+	newLF.Packages[0].SHA256 = ""
+	diff := Diff(oldLF, newLF)
+	assert.Equal(t, 1, len(diff))
+	assert.Equal(t, DiffSuspicious, diff[0].Type)
+}
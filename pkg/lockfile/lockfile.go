@@ -0,0 +1,327 @@
+// Package lockfile implements the structured lockfile format (JSON or YAML), as an alternative to the hash files.
+//
+// A lockfile contains the same entries as the hash file, with the metadata of the packages
+// that would be otherwise derived from the file names.
+// The lockfile can be converted to and from the hash file without losing the entries and the directives.
+package lockfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the current version of the lockfile format.
+const Version = 1
+
+// Format is the format of a lockfile.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	// FormatSHA256SUMS is the plain hash file, not a structured lockfile.
+	FormatSHA256SUMS Format = "sha256sums"
+)
+
+// Formats is the list of the supported formats.
+var Formats = []Format{FormatJSON, FormatYAML, FormatSHA256SUMS}
+
+// Lockfile is a structured lockfile.
+type Lockfile struct {
+	Version     int        `json:"Version" yaml:"Version"`
+	GeneratedAt *time.Time `json:"GeneratedAt,omitempty" yaml:"GeneratedAt,omitempty"` // Unknown for the lockfiles converted from the hash files
+	Distro      string     `json:"Distro,omitempty" yaml:"Distro,omitempty"`           // "debian"
+	Snapshot    string     `json:"Snapshot,omitempty" yaml:"Snapshot,omitempty"`       // "20221101T000000Z", for snapshot.debian.org
+	PPA         string     `json:"PPA,omitempty" yaml:"PPA,omitempty"`                 // "deadsnakes/ppa"
+	Indexes     []Index    `json:"Indexes,omitempty" yaml:"Indexes,omitempty"`         // The repository indexes that were used for generating the lockfile
+	Packages    []Package  `json:"Packages" yaml:"Packages"`                           // Sorted by ArchSection and Name
+}
+
+// Index is a repository index, such as Packages and APKINDEX.
+type Index struct {
+	Name   string `json:"Name" yaml:"Name"` // "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages"
+	SHA256 string `json:"SHA256" yaml:"SHA256"`
+}
+
+// Package is a package file.
+type Package struct {
+	Name         string `json:"Name" yaml:"Name"` // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	SHA256       string `json:"SHA256,omitempty" yaml:"SHA256,omitempty"`
+	SHA512       string `json:"SHA512,omitempty" yaml:"SHA512,omitempty"`
+	BLAKE3       string `json:"BLAKE3,omitempty" yaml:"BLAKE3,omitempty"`
+	CID          string `json:"CID,omitempty" yaml:"CID,omitempty"`                   // IPFS CID
+	Package      string `json:"Package,omitempty" yaml:"Package,omitempty"`           // "hello"
+	Version      string `json:"Version,omitempty" yaml:"Version,omitempty"`           // "2.10-2"
+	Architecture string `json:"Architecture,omitempty" yaml:"Architecture,omitempty"` // "amd64"
+	Origin       string `json:"Origin,omitempty" yaml:"Origin,omitempty"`             // The origin repository, such as "ppa:deadsnakes/ppa", when known
+	ArchSection  string `json:"ArchSection,omitempty" yaml:"ArchSection,omitempty"`   // The architecture section of the hash file (see filespec.DirectiveArch), such as "arm64"; empty for the common section
+	URL          string `json:"URL,omitempty" yaml:"URL,omitempty"`                   // The origin URL (see filespec.DirectiveURL), when recorded
+	SignedBy     string `json:"SignedBy,omitempty" yaml:"SignedBy,omitempty"`         // The fingerprint of the signing key (see filespec.DirectiveSignedBy), when verified
+}
+
+// FromSHA256SUMS converts the content of a hash file into a lockfile.
+// GeneratedAt and Distro are not set.
+func FromSHA256SUMS(b []byte) (*Lockfile, error) {
+	sections, err := filespec.SplitArchSections(b)
+	if err != nil {
+		return nil, err
+	}
+	lf := &Lockfile{Version: Version}
+	urls := make(map[string]string)    // key: file name
+	signers := make(map[string]string) // key: file name
+	for _, line := range strings.Split(string(b), "\n") {
+		k, v, ok := sha256sums.ParseDirective(line)
+		if !ok {
+			continue
+		}
+		switch k {
+		case filespec.DirectiveSnapshot:
+			lf.Snapshot = v
+		case filespec.DirectivePPA:
+			lf.PPA = v
+		case filespec.DirectiveIndex:
+			sum, name, err := sha256sums.ParseLine(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index directive %q: %w", line, err)
+			}
+			lf.Indexes = append(lf.Indexes, Index{Name: name, SHA256: sum})
+		case filespec.DirectiveURL:
+			u, name, err := filespec.ParseURLDirective(v)
+			if err != nil {
+				return nil, err
+			}
+			urls[name] = u
+		case filespec.DirectiveSignedBy:
+			fpr, name, err := filespec.ParseSignedByDirective(v)
+			if err != nil {
+				return nil, err
+			}
+			signers[name] = fpr
+		}
+	}
+	for _, section := range sections {
+		sums, err := sha256sums.ParseAll(bytes.NewReader(section.Content))
+		if err != nil {
+			return nil, err
+		}
+		fileSpecs, err := filespec.NewFromSums(sums)
+		if err != nil {
+			return nil, err
+		}
+		for _, sp := range fileSpecs {
+			pkg := newPackage(sp, lf.PPA)
+			pkg.ArchSection = section.Arch
+			pkg.URL = urls[sp.Name]
+			pkg.SignedBy = signers[sp.Name]
+			lf.Packages = append(lf.Packages, pkg)
+		}
+	}
+	lf.sortPackages()
+	return lf, nil
+}
+
+func (lf *Lockfile) sortPackages() {
+	sort.SliceStable(lf.Packages, func(i, j int) bool {
+		a, b := &lf.Packages[i], &lf.Packages[j]
+		if a.ArchSection != b.ArchSection {
+			return a.ArchSection < b.ArchSection
+		}
+		return a.Name < b.Name
+	})
+}
+
+func newPackage(sp *filespec.FileSpec, ppa string) Package {
+	pkg := Package{
+		Name:   sp.Name,
+		SHA256: sp.SHA256,
+		SHA512: sp.SHA512,
+		BLAKE3: sp.BLAKE3,
+		CID:    sp.CID,
+	}
+	switch {
+	case sp.Dpkg != nil:
+		pkg.Package, pkg.Version, pkg.Architecture = sp.Dpkg.Package, sp.Dpkg.Version, sp.Dpkg.Architecture
+	case sp.RPM != nil:
+		pkg.Package, pkg.Version, pkg.Architecture = sp.RPM.Package, sp.RPM.Version+"-"+sp.RPM.Release, sp.RPM.Architecture
+	case sp.APK != nil:
+		pkg.Package, pkg.Version = sp.APK.Package, sp.APK.Version
+	case sp.NPM != nil:
+		pkg.Package, pkg.Version = sp.NPM.Package, sp.NPM.Version
+	case sp.GoMod != nil:
+		pkg.Package, pkg.Version = sp.GoMod.Module, sp.GoMod.Version
+	case sp.Crate != nil:
+		pkg.Package, pkg.Version = sp.Crate.Package, sp.Crate.Version
+	case sp.Maven != nil:
+		pkg.Package, pkg.Version = sp.Maven.Group+":"+sp.Maven.Artifact, sp.Maven.Version
+	}
+	if ppa != "" {
+		pkg.Origin = "ppa:" + ppa
+	}
+	return pkg
+}
+
+// SHA256SUMS converts the lockfile into the content of a hash file.
+// The metadata that cannot be represented in the hash file (e.g., GeneratedAt) is lost.
+func (lf *Lockfile) SHA256SUMS() ([]byte, error) {
+	var b bytes.Buffer
+	if lf.Snapshot != "" {
+		fmt.Fprintln(&b, sha256sums.FormatDirective(filespec.DirectiveSnapshot, lf.Snapshot))
+	}
+	if lf.PPA != "" {
+		fmt.Fprintln(&b, sha256sums.FormatDirective(filespec.DirectivePPA, lf.PPA))
+	}
+	for _, idx := range lf.Indexes {
+		fmt.Fprintln(&b, sha256sums.FormatDirective(filespec.DirectiveIndex, idx.SHA256+"  "+idx.Name))
+	}
+	packages := make([]Package, len(lf.Packages))
+	copy(packages, lf.Packages)
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].ArchSection < packages[j].ArchSection // The common section comes first
+	})
+	hw := distro.NewHashWriter(&b)
+	var archSection string
+	for _, pkg := range packages {
+		if err := filespec.ValidateName(pkg.Name); err != nil {
+			return nil, err
+		}
+		if pkg.ArchSection != archSection {
+			if err := filespec.ValidateArch(pkg.ArchSection); err != nil {
+				return nil, err
+			}
+			fmt.Fprintln(&b, sha256sums.FormatDirective(filespec.DirectiveArch, pkg.ArchSection))
+			archSection = pkg.ArchSection
+		}
+		sums := pkg.Sums()
+		if len(sums) == 0 {
+			return nil, fmt.Errorf("no sum is known for %q", pkg.Name)
+		}
+		for _, sum := range sums {
+			if _, err := sha256sums.ParseSum(sum); err != nil {
+				return nil, fmt.Errorf("invalid sum of %q: %w", pkg.Name, err)
+			}
+			if err := hw(sum, pkg.Name); err != nil {
+				return nil, err
+			}
+		}
+		if pkg.CID != "" {
+			if pkg.SHA256 == "" {
+				return nil, fmt.Errorf("the CID of %q needs SHA256", pkg.Name)
+			}
+			if err := hw(pkg.SHA256, "/ipfs/"+pkg.CID); err != nil {
+				return nil, err
+			}
+		}
+		if pkg.URL != "" {
+			v := pkg.URL + "  " + pkg.Name
+			if _, _, err := filespec.ParseURLDirective(v); err != nil {
+				return nil, err
+			}
+			fmt.Fprintln(&b, sha256sums.FormatDirective(filespec.DirectiveURL, v))
+		}
+		if pkg.SignedBy != "" {
+			v := pkg.SignedBy + "  " + pkg.Name
+			if _, _, err := filespec.ParseSignedByDirective(v); err != nil {
+				return nil, err
+			}
+			fmt.Fprintln(&b, sha256sums.FormatDirective(filespec.DirectiveSignedBy, v))
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// Sums returns the sums of the package, in the format of the hash file (e.g., "sha512:<SHA512>").
+func (pkg *Package) Sums() []string {
+	var sums []string
+	if pkg.SHA256 != "" {
+		sums = append(sums, pkg.SHA256)
+	}
+	if pkg.SHA512 != "" {
+		sums = append(sums, sha256sums.SHA512Prefix+pkg.SHA512)
+	}
+	if pkg.BLAKE3 != "" {
+		sums = append(sums, sha256sums.BLAKE3Prefix+pkg.BLAKE3)
+	}
+	return sums
+}
+
+// Marshal marshals the lockfile in the format.
+func (lf *Lockfile) Marshal(format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		b, err := json.MarshalIndent(lf, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case FormatYAML:
+		var b bytes.Buffer
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err := enc.Encode(lf); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case FormatSHA256SUMS:
+		return lf.SHA256SUMS()
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected one of %v)", format, Formats)
+	}
+}
+
+// DetectFormat detects the format of the content.
+// JSON is detected by the leading "{", and the content that cannot be parsed as a hash file is assumed to be YAML.
+func DetectFormat(b []byte) Format {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return FormatJSON
+	}
+	if _, err := sha256sums.ParseAll(bytes.NewReader(b)); err == nil {
+		return FormatSHA256SUMS
+	}
+	return FormatYAML
+}
+
+// Read reads a lockfile, or a hash file, with DetectFormat.
+func Read(r io.Reader) (*Lockfile, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var lf Lockfile
+	switch format := DetectFormat(b); format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err = dec.Decode(&lf); err != nil {
+			return nil, fmt.Errorf("failed to parse the lockfile as JSON: %w", err)
+		}
+	case FormatYAML:
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err = dec.Decode(&lf); err != nil {
+			return nil, fmt.Errorf("failed to parse the lockfile as YAML: %w", err)
+		}
+	default:
+		return FromSHA256SUMS(b)
+	}
+	if lf.Version != Version {
+		return nil, fmt.Errorf("unsupported lockfile version %d (expected %d)", lf.Version, Version)
+	}
+	if len(lf.Packages) == 0 {
+		return nil, errors.New("the lockfile has no package")
+	}
+	return &lf, nil
+}
@@ -0,0 +1,120 @@
+package lockfile
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const testSHA256SUMS = `#repro-get:snapshot=20221101T000000Z
+#repro-get:index=9f1fe8e8e8f6b0a3e4a218e4b3fb44e91a5e2d91b0a9e3278b1e14070ef55a87  deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  /ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj
+`
+
+func TestFromSHA256SUMS(t *testing.T) {
+	lf, err := FromSHA256SUMS([]byte(testSHA256SUMS))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, &Lockfile{
+		Version:  Version,
+		Snapshot: "20221101T000000Z",
+		Indexes: []Index{
+			{
+				Name:   "deb.debian.org_debian_dists_bullseye_main_binary-amd64_Packages",
+				SHA256: "9f1fe8e8e8f6b0a3e4a218e4b3fb44e91a5e2d91b0a9e3278b1e14070ef55a87",
+			},
+		},
+		Packages: []Package{
+			{
+				Name:         "pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb",
+				SHA256:       "f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377",
+				CID:          "QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj",
+				Package:      "bash",
+				Version:      "5.1-2+deb11u1",
+				Architecture: "amd64",
+			},
+			{
+				Name:         "pool/main/h/hello/hello_2.10-2_amd64.deb",
+				SHA256:       "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+				SHA512:       "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+				Package:      "hello",
+				Version:      "2.10-2",
+				Architecture: "amd64",
+			},
+		},
+	}, lf)
+}
+
+func TestRoundTrip(t *testing.T) {
+	lf, err := FromSHA256SUMS([]byte(testSHA256SUMS))
+	assert.NilError(t, err)
+	sums, err := lf.SHA256SUMS()
+	assert.NilError(t, err)
+	for _, format := range Formats {
+		b, err := lf.Marshal(format)
+		assert.NilError(t, err)
+		assert.Equal(t, format, DetectFormat(b), string(b))
+		got, err := Read(bytes.NewReader(b))
+		assert.NilError(t, err, string(b))
+		assert.DeepEqual(t, lf, got)
+		gotSums, err := got.SHA256SUMS()
+		assert.NilError(t, err)
+		assert.Equal(t, string(sums), string(gotSums))
+	}
+}
+
+func TestFromSHA256SUMSWithURLs(t *testing.T) {
+	const hashFile = `35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+#repro-get:url=http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+`
+	lf, err := FromSHA256SUMS([]byte(hashFile))
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(lf.Packages))
+	assert.Equal(t, "", lf.Packages[0].URL)
+	assert.Equal(t, "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb", lf.Packages[1].URL)
+
+	sums, err := lf.SHA256SUMS()
+	assert.NilError(t, err)
+	const expected = `f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/bash/bash_5.1-2+deb11u1_amd64.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+#repro-get:url=http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb  pool/main/h/hello/hello_2.10-2_amd64.deb
+`
+	assert.Equal(t, expected, string(sums))
+
+	_, err = FromSHA256SUMS([]byte("#repro-get:url=pool/main/h/hello/hello_2.10-2_amd64.deb\n"))
+	assert.ErrorContains(t, err, "invalid url directive")
+}
+
+func TestFromSHA256SUMSWithSigners(t *testing.T) {
+	const hashFile = `35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  fedora-release/37/1/noarch/fedora-release-37-1.noarch.rpm
+#repro-get:signed-by=115DF9AEF857853EE8445D0A0727707EA15B79CC  fedora-release/37/1/noarch/fedora-release-37-1.noarch.rpm
+`
+	lf, err := FromSHA256SUMS([]byte(hashFile))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(lf.Packages))
+	assert.Equal(t, "115DF9AEF857853EE8445D0A0727707EA15B79CC", lf.Packages[0].SignedBy)
+
+	sums, err := lf.SHA256SUMS()
+	assert.NilError(t, err)
+	assert.Equal(t, hashFile, string(sums))
+
+	_, err = FromSHA256SUMS([]byte("#repro-get:signed-by=0727707EA15B79CC  fedora-release/37/1/noarch/fedora-release-37-1.noarch.rpm\n"))
+	assert.ErrorContains(t, err, "invalid signed-by directive")
+}
+
+func TestRead(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte(`{"Version": 2, "Packages": []}`)))
+	assert.ErrorContains(t, err, "unsupported lockfile version")
+	_, err = Read(bytes.NewReader([]byte(`{"Version": 1, "Packages": [], "Foo": "bar"}`)))
+	assert.ErrorContains(t, err, "unknown field")
+	_, err = Read(bytes.NewReader([]byte("Version: 1\nPackages: []\n")))
+	assert.ErrorContains(t, err, "no package")
+	lf, err := Read(bytes.NewReader([]byte("Version: 1\nPackages:\n  - Name: foo/../bar\n    SHA256: 35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc\n")))
+	assert.NilError(t, err)
+	_, err = lf.SHA256SUMS()
+	assert.ErrorContains(t, err, "must be clean")
+}
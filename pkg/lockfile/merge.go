@@ -0,0 +1,156 @@
+package lockfile
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Merge merges the other lockfile into lf, e.g., for merging the lockfiles for the different architectures.
+//
+// The packages with the same name are merged into one, with the sums of all the algorithms.
+// Returns an error when the lockfiles have conflicting sums for the same name,
+// or conflicting metadata such as the snapshot timestamps.
+// GeneratedAt is cleared.
+func (lf *Lockfile) Merge(o *Lockfile) error {
+	for _, f := range []struct {
+		name     string
+		dst, src *string
+	}{
+		{"distro", &lf.Distro, &o.Distro},
+		{"snapshot", &lf.Snapshot, &o.Snapshot},
+		{"PPA", &lf.PPA, &o.PPA},
+	} {
+		if err := mergeField(f.dst, *f.src); err != nil {
+			return fmt.Errorf("conflicting %s: %w", f.name, err)
+		}
+	}
+	lf.GeneratedAt = nil
+
+	indexes := make(map[string]int) // key: name, value: index of lf.Indexes
+	for i, idx := range lf.Indexes {
+		indexes[idx.Name] = i
+	}
+	for _, idx := range o.Indexes {
+		i, ok := indexes[idx.Name]
+		if !ok {
+			indexes[idx.Name] = len(lf.Indexes)
+			lf.Indexes = append(lf.Indexes, idx)
+			continue
+		}
+		if err := mergeField(&lf.Indexes[i].SHA256, idx.SHA256); err != nil {
+			return fmt.Errorf("conflicting SHA256 of index %q: %w", idx.Name, err)
+		}
+	}
+	sort.Slice(lf.Indexes, func(i, j int) bool {
+		return lf.Indexes[i].Name < lf.Indexes[j].Name
+	})
+
+	packages := make(map[string]int) // key: ArchSection and name, value: index of lf.Packages
+	for i, pkg := range lf.Packages {
+		packages[pkg.sectionKey()] = i
+	}
+	for _, pkg := range o.Packages {
+		i, ok := packages[pkg.sectionKey()]
+		if !ok {
+			packages[pkg.sectionKey()] = len(lf.Packages)
+			lf.Packages = append(lf.Packages, pkg)
+			continue
+		}
+		if err := lf.Packages[i].merge(&pkg); err != nil {
+			return fmt.Errorf("conflicting package %q: %w", pkg.Name, err)
+		}
+	}
+	lf.sortPackages()
+	return nil
+}
+
+// sectionKey returns the architecture section and the name.
+func (pkg *Package) sectionKey() string {
+	return pkg.ArchSection + "\x00" + pkg.Name
+}
+
+// HoistCommonPackages moves the packages that are identical in all the architecture sections into the common section,
+// e.g., after merging the lockfiles of the different architectures with ArchSection.
+func (lf *Lockfile) HoistCommonPackages() {
+	archSections := make(map[string]struct{})
+	for _, pkg := range lf.Packages {
+		if pkg.ArchSection != "" {
+			archSections[pkg.ArchSection] = struct{}{}
+		}
+	}
+	if len(archSections) < 2 {
+		return
+	}
+	byName := make(map[string][]*Package)
+	for i := range lf.Packages {
+		pkg := &lf.Packages[i]
+		byName[pkg.Name] = append(byName[pkg.Name], pkg)
+	}
+	var res []Package
+	hoisted := make(map[string]bool)
+	for _, pkg := range lf.Packages {
+		if hoisted[pkg.Name] {
+			continue
+		}
+		if same := byName[pkg.Name]; len(same) == len(archSections) && allIdentical(same) {
+			hoisted[pkg.Name] = true
+			pkg.ArchSection = ""
+		}
+		res = append(res, pkg)
+	}
+	lf.Packages = res
+	lf.sortPackages()
+}
+
+// allIdentical returns true when the packages are identical except ArchSection, and are not in the common section.
+func allIdentical(pkgs []*Package) bool {
+	for _, pkg := range pkgs {
+		if pkg.ArchSection == "" {
+			return false
+		}
+		a, b := *pkg, *pkgs[0]
+		a.ArchSection, b.ArchSection = "", ""
+		if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+func (pkg *Package) merge(o *Package) error {
+	for _, f := range []struct {
+		name     string
+		dst, src *string
+	}{
+		{"SHA256", &pkg.SHA256, &o.SHA256},
+		{"SHA512", &pkg.SHA512, &o.SHA512},
+		{"BLAKE3", &pkg.BLAKE3, &o.BLAKE3},
+		{"CID", &pkg.CID, &o.CID},
+		{"package", &pkg.Package, &o.Package},
+		{"version", &pkg.Version, &o.Version},
+		{"architecture", &pkg.Architecture, &o.Architecture},
+		{"origin", &pkg.Origin, &o.Origin},
+	} {
+		if err := mergeField(f.dst, *f.src); err != nil {
+			return fmt.Errorf("conflicting %s: %w", f.name, err)
+		}
+	}
+	// The same file may be recorded with the URLs of different mirrors, so the first one is kept
+	if pkg.URL == "" {
+		pkg.URL = o.URL
+	}
+	return nil
+}
+
+// mergeField sets src to dst when dst is empty.
+// Returns an error when both are non-empty and different.
+func mergeField(dst *string, src string) error {
+	switch {
+	case src == "" || *dst == src:
+	case *dst == "":
+		*dst = src
+	default:
+		return fmt.Errorf("%q vs %q", *dst, src)
+	}
+	return nil
+}
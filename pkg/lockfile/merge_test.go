@@ -0,0 +1,73 @@
+package lockfile
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestMerge(t *testing.T) {
+	const amd64 = `#repro-get:snapshot=20221101T000000Z
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+`
+	const arm64 = `#repro-get:snapshot=20221101T000000Z
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_arm64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+`
+	lf, err := FromSHA256SUMS([]byte(amd64))
+	assert.NilError(t, err)
+	o, err := FromSHA256SUMS([]byte(arm64))
+	assert.NilError(t, err)
+	assert.NilError(t, lf.Merge(o))
+	b, err := lf.SHA256SUMS()
+	assert.NilError(t, err)
+	assert.Equal(t, `#repro-get:snapshot=20221101T000000Z
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_arm64.deb
+`, string(b))
+
+	conflicting, err := FromSHA256SUMS([]byte("2222222222222222222222222222222222222222222222222222222222222222  pool/main/h/hello/hello_2.10-2_amd64.deb\n"))
+	assert.NilError(t, err)
+	assert.ErrorContains(t, lf.Merge(conflicting), `conflicting package "pool/main/h/hello/hello_2.10-2_amd64.deb": conflicting SHA256`)
+
+	otherSnapshot, err := FromSHA256SUMS([]byte(strings.Replace(amd64, "20221101T000000Z", "20230101T000000Z", 1)))
+	assert.NilError(t, err)
+	assert.ErrorContains(t, lf.Merge(otherSnapshot), "conflicting snapshot")
+}
+
+func TestMergeArchSections(t *testing.T) {
+	const amd64 = `35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+`
+	const arm64 = `1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_arm64.deb
+f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+`
+	merged := &Lockfile{Version: Version}
+	for arch, s := range map[string]string{"amd64": amd64, "arm64": arm64} {
+		lf, err := FromSHA256SUMS([]byte(s))
+		assert.NilError(t, err)
+		for i := range lf.Packages {
+			lf.Packages[i].ArchSection = arch
+		}
+		assert.NilError(t, merged.Merge(lf))
+	}
+	merged.HoistCommonPackages()
+	b, err := merged.SHA256SUMS()
+	assert.NilError(t, err)
+	const expected = `f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377  pool/main/b/base-files/base-files_11.1+deb11u5_all.deb
+#repro-get:arch=amd64
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+#repro-get:arch=arm64
+1111111111111111111111111111111111111111111111111111111111111111  pool/main/h/hello/hello_2.10-2_arm64.deb
+`
+	assert.Equal(t, expected, string(b))
+
+	lf, err := FromSHA256SUMS(b)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged, lf)
+}
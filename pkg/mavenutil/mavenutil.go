@@ -0,0 +1,53 @@
+package mavenutil
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type Maven struct {
+	Group    string `json:"Group"`    // "org.apache.commons"
+	Artifact string `json:"Artifact"` // "commons-lang3"
+	Version  string `json:"Version"`  // "3.12.0"
+}
+
+// Extensions is the list of the file extensions recognized by ParseFilename.
+var Extensions = []string{".jar", ".pom", ".aar", ".war", ".module"}
+
+// HasExtension returns true if filename has one of Extensions.
+func HasExtension(filename string) bool {
+	for _, ext := range Extensions {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFilename parses a file name like "org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.jar".
+func ParseFilename(filename string) (*Maven, error) {
+	if !HasExtension(filename) {
+		return nil, fmt.Errorf("expected one of %v, got %q", Extensions, filename)
+	}
+	sp := strings.Split(filename, "/")
+	if len(sp) < 4 {
+		return nil, fmt.Errorf("expected <GROUP>/<ARTIFACT>/<VERSION>/<ARTIFACT>-<VERSION>[-<CLASSIFIER>].<EXT>, got %q", filename)
+	}
+	l := len(sp)
+	m := &Maven{
+		Group:    strings.Join(sp[:l-3], "."),
+		Artifact: sp[l-3],
+		Version:  sp[l-2],
+	}
+	if prefix := m.Artifact + "-" + m.Version; !strings.HasPrefix(sp[l-1], prefix) {
+		return nil, fmt.Errorf("expected the basename of %q to have the prefix %q", filename, prefix)
+	}
+	return m, nil
+}
+
+// Filename returns a file name like "org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.jar".
+// basename is like "commons-lang3-3.12.0.jar".
+func (m *Maven) Filename(basename string) string {
+	return path.Join(strings.ReplaceAll(m.Group, ".", "/"), m.Artifact, m.Version, basename)
+}
@@ -0,0 +1,23 @@
+package mavenutil
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseFilename(t *testing.T) {
+	const fname = "org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.jar"
+	got, err := ParseFilename(fname)
+	assert.NilError(t, err)
+	expected := &Maven{
+		Group:    "org.apache.commons",
+		Artifact: "commons-lang3",
+		Version:  "3.12.0",
+	}
+	assert.DeepEqual(t, expected, got)
+	assert.Equal(t, fname, expected.Filename("commons-lang3-3.12.0.jar"))
+
+	_, err = ParseFilename("commons-lang3/3.12.0/commons-lang3-3.12.0.jar")
+	assert.ErrorContains(t, err, "expected <GROUP>")
+}
@@ -0,0 +1,115 @@
+package npmutil
+
+import (
+	"crypto/sha1" //nolint:gosec // SHA1 is still used in old package-lock.json files
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"path"
+	"strings"
+)
+
+type NPM struct {
+	Package string `json:"Package"` // "@babel/core"
+	Version string `json:"Version"` // "7.19.3"
+}
+
+// ParseFilename parses a file name like "@babel/core/-/core-7.19.3.tgz".
+func ParseFilename(filename string) (*NPM, error) {
+	if !strings.HasSuffix(filename, ".tgz") {
+		return nil, fmt.Errorf("expected *.tgz, got %q", filename)
+	}
+	sp := strings.SplitN(filename, "/-/", 2)
+	if len(sp) != 2 || sp[0] == "" || strings.Contains(sp[1], "/") {
+		return nil, fmt.Errorf("expected <PACKAGE>/-/<BASENAME>-<VERSION>.tgz, got %q", filename)
+	}
+	pkg := sp[0]
+	prefix := path.Base(pkg) + "-"
+	if !strings.HasPrefix(sp[1], prefix) {
+		return nil, fmt.Errorf("expected the basename of %q to have the prefix %q", filename, prefix)
+	}
+	return &NPM{
+		Package: pkg,
+		Version: strings.TrimSuffix(strings.TrimPrefix(sp[1], prefix), ".tgz"),
+	}, nil
+}
+
+// Filename returns a file name like "@babel/core/-/core-7.19.3.tgz".
+func Filename(pkg, version string) string {
+	return pkg + "/-/" + path.Base(pkg) + "-" + version + ".tgz"
+}
+
+// Integrity is a Subresource Integrity string like "sha512-<BASE64>".
+// https://w3c.github.io/webappsec-subresource-integrity/
+type Integrity struct {
+	Algorithm string // "sha512"
+	Digest    []byte
+}
+
+// ParseIntegrity parses the "integrity" field of package-lock.json.
+// The field may contain multiple space-separated entries.
+// Entries with unknown algorithms are ignored.
+func ParseIntegrity(s string) ([]Integrity, error) {
+	var res []Integrity
+	for _, f := range strings.Fields(s) {
+		f, _, _ = strings.Cut(f, "?") // the options are ignored
+		algo, b64, ok := strings.Cut(f, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid integrity %q", f)
+		}
+		if NewHash(algo) == nil {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integrity %q: %w", f, err)
+		}
+		res = append(res, Integrity{Algorithm: algo, Digest: digest})
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no supported integrity was found in %q", s)
+	}
+	return res, nil
+}
+
+// SHA256 returns the hex-encoded sha256 digest, if the integrity contains it.
+func SHA256(ii []Integrity) string {
+	for _, i := range ii {
+		if i.Algorithm == "sha256" && len(i.Digest) == sha256.Size {
+			return hex.EncodeToString(i.Digest)
+		}
+	}
+	return ""
+}
+
+// Strongest returns the strongest integrity entry.
+func Strongest(ii []Integrity) *Integrity {
+	order := []string{"sha512", "sha384", "sha256", "sha1"}
+	for _, algo := range order {
+		for _, i := range ii {
+			i := i
+			if i.Algorithm == algo {
+				return &i
+			}
+		}
+	}
+	return nil
+}
+
+// NewHash returns nil for unknown algorithms.
+func NewHash(algo string) hash.Hash {
+	switch algo {
+	case "sha512":
+		return sha512.New()
+	case "sha384":
+		return sha512.New384()
+	case "sha256":
+		return sha256.New()
+	case "sha1":
+		return sha1.New() //nolint:gosec // SHA1 is still used in old package-lock.json files
+	}
+	return nil
+}
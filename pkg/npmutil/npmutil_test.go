@@ -0,0 +1,40 @@
+package npmutil
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseFilename(t *testing.T) {
+	got, err := ParseFilename("lodash/-/lodash-4.17.21.tgz")
+	assert.NilError(t, err)
+	expected := &NPM{
+		Package: "lodash",
+		Version: "4.17.21",
+	}
+	assert.DeepEqual(t, expected, got)
+
+	got, err = ParseFilename("@babel/core/-/core-7.19.3.tgz")
+	assert.NilError(t, err)
+	expected = &NPM{
+		Package: "@babel/core",
+		Version: "7.19.3",
+	}
+	assert.DeepEqual(t, expected, got)
+	assert.Equal(t, "@babel/core/-/core-7.19.3.tgz", Filename(expected.Package, expected.Version))
+
+	_, err = ParseFilename("pool/main/h/hello/hello_2.10-2_amd64.deb")
+	assert.ErrorContains(t, err, "expected *.tgz")
+}
+
+func TestParseIntegrity(t *testing.T) {
+	ii, err := ParseIntegrity("sha1-Qhz8R3K5UdXk6q+zHa7LmQ2S8l0= sha256-NbFQju7pwd+6eYxMBDBO8PJmmQ+TalHxZVce31MyXLw=")
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(ii))
+	assert.Equal(t, "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc", SHA256(ii))
+	assert.Equal(t, "sha256", Strongest(ii).Algorithm)
+
+	_, err = ParseIntegrity("md5-AAAA")
+	assert.ErrorContains(t, err, "no supported integrity")
+}
@@ -0,0 +1,234 @@
+package ocidistutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/nerdctl/pkg/imgutil/dockerconfigresolver"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ArtifactConfigMediaType is the media type of the config of the artifact, used as the artifact type (as in ORAS).
+	ArtifactConfigMediaType = "application/vnd.reproducible-containers.repro-get.config.v1+json"
+
+	// ArtifactLayerMediaType is the media type of the layers of the artifact.
+	// Each of the layers is a raw package file, not a tarball.
+	ArtifactLayerMediaType = "application/octet-stream"
+)
+
+// ArtifactBlob is a blob to be pushed as a layer of the artifact.
+type ArtifactBlob struct {
+	SHA256    string // May be a SHA512 sum prefixed with "sha512:" (see sha256sums.ParseSum)
+	Size      int64
+	Title     string                        // File name, such as "hello_2.10-2_amd64.deb"
+	MediaType string                        // Defaults to ArtifactLayerMediaType
+	Open      func() (io.ReadCloser, error) // Opens the blob
+}
+
+// Artifact is an OCI artifact that consists of package files.
+// The layout is compatible with ORAS (https://oras.land/), so the artifact can be pulled with `oras pull` too.
+type Artifact struct {
+	ref      refdocker.Named
+	resolver remotes.Resolver
+}
+
+// NewArtifact instantiates Artifact.
+// rawRef is an OCI ref such as "ghcr.io/USERNAME/dpkgs:latest".
+func NewArtifact(ctx context.Context, rawRef string, plainHTTP bool) (*Artifact, error) {
+	ref, err := refdocker.ParseDockerRef(rawRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCI ref %q: %w", rawRef, err)
+	}
+	refDomain := refdocker.Domain(ref)
+	var dOpts []dockerconfigresolver.Opt
+	if plainHTTP {
+		dOpts = append(dOpts, dockerconfigresolver.WithPlainHTTP(true))
+	}
+	resolver, err := dockerconfigresolver.New(ctx, refDomain, dOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a resolver for refDomain=%q (ref=%q): %w", refDomain, ref, err)
+	}
+	return &Artifact{
+		ref:      ref,
+		resolver: resolver,
+	}, nil
+}
+
+// Ref returns the normalized ref.
+func (a *Artifact) Ref() string {
+	return a.ref.String()
+}
+
+// Push pushes the blobs as the layers of the artifact, and then pushes the manifest.
+// The blobs that already exist in the registry are skipped.
+func (a *Artifact) Push(ctx context.Context, blobs []ArtifactBlob) (ocispec.Descriptor, error) {
+	return a.pushManifest(ctx, a.ref, ArtifactConfigMediaType, blobs, nil)
+}
+
+// pushManifest pushes the blobs and the manifest to ref, with configMediaType as the media type of the empty config.
+// The manifest refers to the subject, when subject is non-nil.
+func (a *Artifact) pushManifest(ctx context.Context, ref refdocker.Named, configMediaType string, blobs []ArtifactBlob, subject *ocispec.Descriptor) (ocispec.Descriptor, error) {
+	// Register the ref key prefixes to avoid "reference for unknown type" warnings
+	ctx = remotes.WithMediaTypeKeyPrefix(ctx, configMediaType, "config")
+	ctx = remotes.WithMediaTypeKeyPrefix(ctx, ArtifactLayerMediaType, "layer")
+	for _, b := range blobs {
+		if b.MediaType != "" {
+			ctx = remotes.WithMediaTypeKeyPrefix(ctx, b.MediaType, "layer")
+		}
+	}
+	pusher, err := a.resolver.Pusher(ctx, ref.String())
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to get pusher for %v: %w", ref, err)
+	}
+	configB := []byte("{}")
+	config := ocispec.Descriptor{
+		MediaType: configMediaType,
+		Digest:    digest.FromBytes(configB),
+		Size:      int64(len(configB)),
+	}
+	if err = push(ctx, pusher, config, io.NopCloser(bytes.NewReader(configB))); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    make([]ocispec.Descriptor, 0, len(blobs)),
+		Subject:   subject,
+	}
+	for _, b := range blobs {
+		dgst, err := sha256sums.ParseSum(b.SHA256)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		desc := ocispec.Descriptor{
+			MediaType: b.MediaType,
+			Digest:    dgst,
+			Size:      b.Size,
+			Annotations: map[string]string{
+				ocispec.AnnotationTitle: b.Title,
+			},
+		}
+		if desc.MediaType == "" {
+			desc.MediaType = ArtifactLayerMediaType
+		}
+		r, err := b.Open()
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		logrus.Debugf("Pushing %q (%s)", b.Title, desc.Digest)
+		if err = push(ctx, pusher, desc, r); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to push %q: %w", b.Title, err)
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+	manifestB, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifestB),
+		Size:      int64(len(manifestB)),
+	}
+	if subject != nil {
+		// Push the manifest by the digest, not by the tag of a.ref
+		if pusher, err = a.pusherForDigest(ctx, manifestDesc.Digest); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	if err = push(ctx, pusher, manifestDesc, io.NopCloser(bytes.NewReader(manifestB))); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push the manifest: %w", err)
+	}
+	return manifestDesc, nil
+}
+
+// push pushes a blob and closes r.
+func push(ctx context.Context, pusher remotes.Pusher, desc ocispec.Descriptor, r io.ReadCloser) error {
+	defer r.Close()
+	w, err := pusher.Push(ctx, desc)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			logrus.Debugf("Already exists: %s", desc.Digest)
+			return nil
+		}
+		return err
+	}
+	defer w.Close()
+	return content.Copy(ctx, w, r, desc.Size, desc.Digest)
+}
+
+// Resolve resolves the descriptor of the artifact.
+// The descriptor may be an image index too, when the ref is not an artifact but an image.
+func (a *Artifact) Resolve(ctx context.Context) (ocispec.Descriptor, error) {
+	_, desc, err := a.resolver.Resolve(ctx, a.ref.String())
+	return desc, err
+}
+
+// Manifest fetches the manifest of the artifact.
+func (a *Artifact) Manifest(ctx context.Context) (ocispec.Descriptor, *ocispec.Manifest, error) {
+	desc, err := a.Resolve(ctx)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	manifest, err := a.FetchManifest(ctx, desc)
+	if err != nil {
+		return desc, nil, err
+	}
+	if manifest.Config.MediaType != ArtifactConfigMediaType {
+		// Artifacts pushed with `oras push` are accepted too
+		logrus.Debugf("Expected the config media type to be %q, got %q", ArtifactConfigMediaType, manifest.Config.MediaType)
+	}
+	return desc, manifest, nil
+}
+
+// FetchManifest fetches the image manifest of desc, such as a referrer.
+func (a *Artifact) FetchManifest(ctx context.Context, desc ocispec.Descriptor) (*ocispec.Manifest, error) {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, images.MediaTypeDockerSchema2Manifest:
+	default:
+		return nil, fmt.Errorf("expected an image manifest, got %q", desc.MediaType)
+	}
+	r, err := a.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var manifest ocispec.Manifest
+	if err = json.NewDecoder(io.LimitReader(r, desc.Size)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode the manifest %s: %w", desc.Digest, err)
+	}
+	return &manifest, nil
+}
+
+// Fetch fetches a blob of the artifact.
+func (a *Artifact) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	if desc.Digest == "" {
+		return nil, errors.New("empty digest")
+	}
+	fetcher, err := a.resolver.Fetcher(ctx, a.ref.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fetcher for %v: %w", a.ref, err)
+	}
+	r, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %v: %v: %w", desc.Digest, a.ref, err)
+	}
+	return r, nil
+}
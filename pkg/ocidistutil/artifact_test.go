@@ -0,0 +1,168 @@
+package ocidistutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/v3/assert"
+)
+
+// testRegistry is a minimal in-memory implementation of the OCI distribution spec.
+type testRegistry struct {
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[string][]byte // by tag and by digest
+	types     map[string]string // media types of the manifests, by tag and by digest
+	uploads   int
+}
+
+func (reg *testRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	p := r.URL.Path
+	if p == "/v2/" || p == "/v2" {
+		return
+	}
+	serve := func(b []byte, mediaType string) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(b).String())
+		if mediaType != "" {
+			w.Header().Set("Content-Type", mediaType)
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(b)
+		}
+	}
+	switch {
+	case strings.Contains(p, "/blobs/uploads/"):
+		switch r.Method {
+		case http.MethodPost:
+			reg.uploads++
+			w.Header().Set("Location", p+strconv.Itoa(reg.uploads))
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			dgst := digest.Digest(r.URL.Query().Get("digest"))
+			if dgst != digest.FromBytes(b) {
+				http.Error(w, "digest mismatch", http.StatusBadRequest)
+				return
+			}
+			reg.blobs[dgst] = b
+			w.Header().Set("Docker-Content-Digest", dgst.String())
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+		}
+	case strings.Contains(p, "/blobs/"):
+		b, ok := reg.blobs[digest.Digest(p[strings.LastIndex(p, "/")+1:])]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		serve(b, "")
+	case strings.Contains(p, "/manifests/"):
+		k := p[strings.LastIndex(p, "/")+1:]
+		switch r.Method {
+		case http.MethodPut:
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			reg.manifests[k] = b
+			reg.manifests[digest.FromBytes(b).String()] = b
+			reg.types[k] = r.Header.Get("Content-Type")
+			reg.types[digest.FromBytes(b).String()] = r.Header.Get("Content-Type")
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(b).String())
+			w.WriteHeader(http.StatusCreated)
+		default:
+			b, ok := reg.manifests[k]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			serve(b, reg.types[k])
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestRegistry starts testRegistry, and returns its host.
+func newTestRegistry(t testing.TB) (*testRegistry, string) {
+	reg := &testRegistry{
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[string][]byte),
+		types:     make(map[string]string),
+	}
+	ts := httptest.NewServer(reg)
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL)
+	assert.NilError(t, err)
+	t.Setenv("HOME", t.TempDir()) // Ignore ~/.docker/config.json
+	return reg, u.Host
+}
+
+func testBlobs(contents map[string][]byte) []ArtifactBlob {
+	var blobs []ArtifactBlob
+	for title, b := range contents {
+		b := b
+		blobs = append(blobs, ArtifactBlob{
+			SHA256: digest.FromBytes(b).Encoded(),
+			Size:   int64(len(b)),
+			Title:  title,
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(b)), nil
+			},
+		})
+	}
+	return blobs
+}
+
+func TestArtifact(t *testing.T) {
+	_, host := newTestRegistry(t)
+	ctx := context.Background()
+	artifact, err := NewArtifact(ctx, host+"/dpkgs:latest", true)
+	assert.NilError(t, err)
+
+	contents := map[string][]byte{
+		"hello_2.10-2_amd64.deb": []byte("blob-hello"),
+		"bash_5.1-2_amd64.deb":   []byte("blob-bash"),
+	}
+	blobs := testBlobs(contents)
+	pushed, err := artifact.Push(ctx, blobs)
+	assert.NilError(t, err)
+	// Pushing again is a no-op for the blobs
+	_, err = artifact.Push(ctx, blobs)
+	assert.NilError(t, err)
+
+	desc, manifest, err := artifact.Manifest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, pushed.Digest, desc.Digest)
+	assert.Equal(t, ArtifactConfigMediaType, manifest.Config.MediaType)
+	assert.Equal(t, len(contents), len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		assert.Equal(t, ArtifactLayerMediaType, layer.MediaType)
+		expected := contents[layer.Annotations[ocispec.AnnotationTitle]]
+		r, err := artifact.Fetch(ctx, layer)
+		assert.NilError(t, err)
+		got, err := io.ReadAll(r)
+		r.Close()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, expected, got)
+	}
+}
@@ -0,0 +1,154 @@
+package ocidistutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/errdefs"
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// The artifact types of the referrers, used as the media types of the configs (as in ORAS).
+const (
+	// HashFileArtifactType is the artifact type of the hash files (SHA256SUMS), with their signatures.
+	HashFileArtifactType = "application/vnd.reproducible-containers.repro-get.sha256sums.v1"
+	// SPDXArtifactType is the artifact type of the SPDX SBOMs.
+	SPDXArtifactType = "application/spdx+json"
+	// CycloneDXArtifactType is the artifact type of the CycloneDX SBOMs.
+	CycloneDXArtifactType = "application/vnd.cyclonedx+json"
+	// InTotoArtifactType is the artifact type of the in-toto attestations, such as the SLSA provenance.
+	InTotoArtifactType = "application/vnd.in-toto+json"
+)
+
+// ReferrersTag returns the tag of the referrers index of the subject,
+// as in the "referrers tag schema" of the OCI distribution spec v1.1, e.g., "sha256-<HEX>".
+//
+// The referrers are recorded in the index of this tag, so that they can be listed
+// on the registries that do not support the referrers API yet.
+// The tag is compatible with ORAS and cosign.
+func ReferrersTag(subject digest.Digest) string {
+	return subject.Algorithm().String() + "-" + subject.Encoded()
+}
+
+// PushReferrer pushes the blobs as an artifact that refers to the subject, such as the artifact of the packages, or an image.
+// The artifactType is used as the media type of the config.
+// The referrers index of the subject (ReferrersTag) is updated too.
+func (a *Artifact) PushReferrer(ctx context.Context, subject ocispec.Descriptor, artifactType string, blobs []ArtifactBlob, annotations map[string]string) (ocispec.Descriptor, error) {
+	subjectDesc := ocispec.Descriptor{
+		MediaType: subject.MediaType,
+		Digest:    subject.Digest,
+		Size:      subject.Size,
+	}
+	desc, err := a.pushManifest(ctx, a.ref, artifactType, blobs, &subjectDesc)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc.ArtifactType = artifactType
+	desc.Annotations = annotations
+	idx, err := a.referrersIndex(ctx, subject.Digest)
+	if err != nil {
+		return desc, err
+	}
+	manifests := []ocispec.Descriptor{desc}
+	for _, f := range idx.Manifests {
+		if f.Digest != desc.Digest {
+			manifests = append(manifests, f)
+		}
+	}
+	idx.Manifests = manifests
+	idxB, err := json.Marshal(idx)
+	if err != nil {
+		return desc, err
+	}
+	idxDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(idxB),
+		Size:      int64(len(idxB)),
+	}
+	tagged, err := refdocker.WithTag(refdocker.TrimNamed(a.ref), ReferrersTag(subject.Digest))
+	if err != nil {
+		return desc, err
+	}
+	pusher, err := a.resolver.Pusher(ctx, tagged.String())
+	if err != nil {
+		return desc, fmt.Errorf("failed to get pusher for %v: %w", tagged, err)
+	}
+	logrus.Debugf("Updating the referrers index %q (%s)", tagged, idxDesc.Digest)
+	if err = push(ctx, pusher, idxDesc, io.NopCloser(bytes.NewReader(idxB))); err != nil {
+		return desc, fmt.Errorf("failed to push the referrers index %q: %w", tagged, err)
+	}
+	return desc, nil
+}
+
+// Referrers returns the descriptors of the referrers of the subject, in the order of the newest first.
+// The referrers are filtered by artifactType, when it is non-empty.
+// An empty list is returned when the subject has no referrer.
+func (a *Artifact) Referrers(ctx context.Context, subject digest.Digest, artifactType string) ([]ocispec.Descriptor, error) {
+	idx, err := a.referrersIndex(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	var res []ocispec.Descriptor
+	for _, f := range idx.Manifests {
+		if artifactType == "" || f.ArtifactType == artifactType {
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+// referrersIndex fetches the referrers index of the subject.
+// An empty index is returned when the index does not exist.
+func (a *Artifact) referrersIndex(ctx context.Context, subject digest.Digest) (*ocispec.Index, error) {
+	idx := &ocispec.Index{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{},
+	}
+	tagged, err := refdocker.WithTag(refdocker.TrimNamed(a.ref), ReferrersTag(subject))
+	if err != nil {
+		return nil, err
+	}
+	_, desc, err := a.resolver.Resolve(ctx, tagged.String())
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return idx, nil
+		}
+		return nil, fmt.Errorf("failed to resolve the referrers index %q: %w", tagged, err)
+	}
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		return nil, fmt.Errorf("expected the referrers index %q to be an image index, got %q", tagged, desc.MediaType)
+	}
+	r, err := a.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if err = json.NewDecoder(io.LimitReader(r, desc.Size)).Decode(idx); err != nil {
+		return nil, fmt.Errorf("failed to decode the referrers index %q: %w", tagged, err)
+	}
+	return idx, nil
+}
+
+// pusherForDigest returns the pusher for pushing a manifest by the digest.
+func (a *Artifact) pusherForDigest(ctx context.Context, dgst digest.Digest) (remotes.Pusher, error) {
+	ref, err := refdocker.WithDigest(refdocker.TrimNamed(a.ref), dgst)
+	if err != nil {
+		return nil, err
+	}
+	pusher, err := a.resolver.Pusher(ctx, ref.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pusher for %v: %w", ref, err)
+	}
+	return pusher, nil
+}
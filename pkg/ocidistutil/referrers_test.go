@@ -0,0 +1,69 @@
+package ocidistutil
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/v3/assert"
+)
+
+func TestReferrers(t *testing.T) {
+	reg, host := newTestRegistry(t)
+	ctx := context.Background()
+	artifact, err := NewArtifact(ctx, host+"/dpkgs:latest", true)
+	assert.NilError(t, err)
+	subject, err := artifact.Push(ctx, testBlobs(map[string][]byte{"hello_2.10-2_amd64.deb": []byte("blob-hello")}))
+	assert.NilError(t, err)
+
+	referrers, err := artifact.Referrers(ctx, subject.Digest, "")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(referrers))
+
+	hashFile := testBlobs(map[string][]byte{"SHA256SUMS-amd64": []byte("hash-file")})
+	hashFile[0].MediaType = "text/plain"
+	hashFileDesc, err := artifact.PushReferrer(ctx, subject, HashFileArtifactType, hashFile, map[string]string{"foo": "bar"})
+	assert.NilError(t, err)
+	sbomDesc, err := artifact.PushReferrer(ctx, subject, SPDXArtifactType, testBlobs(map[string][]byte{"sbom.spdx.json": []byte("{}")}), nil)
+	assert.NilError(t, err)
+	// Pushing the same referrer again does not duplicate the entry
+	_, err = artifact.PushReferrer(ctx, subject, HashFileArtifactType, hashFile, map[string]string{"foo": "bar"})
+	assert.NilError(t, err)
+
+	// The tag of the artifact is not overwritten
+	desc, _, err := artifact.Manifest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, subject.Digest, desc.Digest)
+	assert.Equal(t, ocispec.MediaTypeImageIndex, reg.types[ReferrersTag(subject.Digest)])
+
+	referrers, err = artifact.Referrers(ctx, subject.Digest, "")
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(referrers))
+	assert.Equal(t, hashFileDesc.Digest, referrers[0].Digest)
+	assert.Equal(t, sbomDesc.Digest, referrers[1].Digest)
+
+	referrers, err = artifact.Referrers(ctx, subject.Digest, HashFileArtifactType)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(referrers))
+	assert.Equal(t, HashFileArtifactType, referrers[0].ArtifactType)
+	assert.DeepEqual(t, map[string]string{"foo": "bar"}, referrers[0].Annotations)
+
+	manifest, err := artifact.FetchManifest(ctx, referrers[0])
+	assert.NilError(t, err)
+	assert.Equal(t, HashFileArtifactType, manifest.Config.MediaType)
+	assert.Equal(t, subject.Digest, manifest.Subject.Digest)
+	assert.Equal(t, 1, len(manifest.Layers))
+	assert.Equal(t, "text/plain", manifest.Layers[0].MediaType)
+	r, err := artifact.Fetch(ctx, manifest.Layers[0])
+	assert.NilError(t, err)
+	b, err := io.ReadAll(r)
+	r.Close()
+	assert.NilError(t, err)
+	assert.Equal(t, "hash-file", string(b))
+
+	referrers, err = artifact.Referrers(ctx, digest.FromString("unknown"), "")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(referrers))
+}
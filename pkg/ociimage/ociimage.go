@@ -0,0 +1,313 @@
+// Package ociimage creates OCI images from root filesystems, without container engines.
+package ociimage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/reproducible-containers/repro-get/pkg/rootfs"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultEnv is the default Config.Env.
+var DefaultEnv = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+
+// Opts is the options for Build.
+type Opts struct {
+	Platform ocispec.Platform
+	Config   ocispec.ImageConfig // Config.Env defaults to DefaultEnv
+	Created  *time.Time          // Defaults to the latest modification time of the files in the layer
+	RefName  string              // The "org.opencontainers.image.ref.name" annotation in index.json, such as "latest"
+	// Annotations of the manifest, such as "org.opencontainers.image.source".
+	// Also set to the manifest descriptor in index.json.
+	Annotations map[string]string
+	LayerOpts
+}
+
+// LayerOpts is the options for WriteLayer.
+type LayerOpts struct {
+	// KeepOwner retains the owners of the files. Otherwise the files are owned by 0:0 (root:root).
+	// Only meaningful when the root filesystem was created by the root user.
+	KeepOwner bool
+	// Owners overrides the owners of the files, keyed by the names relative to the root, such as "home/app".
+	Owners map[string]Owner
+	// ClampTime clamps the modification times of the files newer than it, such as $SOURCE_DATE_EPOCH.
+	ClampTime *time.Time
+}
+
+// Owner is the owner of a file.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// Build creates the image in the OCI image layout directory, with the root filesystem as the single layer.
+// The directory must not exist, or must be empty.
+// The image is reproducible for the same root filesystem and options.
+func Build(dir, root string, opts Opts) (*ocispec.Descriptor, error) {
+	if err := rootfs.EnsureEmpty(dir); err != nil {
+		return nil, err
+	}
+	blobs := filepath.Join(dir, "blobs", digest.Canonical.String())
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		return nil, err
+	}
+
+	layerDesc, diffID, latest, err := writeLayerBlob(blobs, root, opts.LayerOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the layer: %w", err)
+	}
+	created := latest
+	if opts.Created != nil {
+		created = *opts.Created
+	}
+	created = created.UTC().Truncate(time.Second)
+	imgConfig := opts.Config
+	if len(imgConfig.Env) == 0 {
+		imgConfig.Env = DefaultEnv
+	}
+	img := ocispec.Image{
+		Created:      &created,
+		Architecture: opts.Platform.Architecture,
+		OS:           opts.Platform.OS,
+		Variant:      opts.Platform.Variant,
+		Config:       imgConfig,
+		RootFS: ocispec.RootFS{
+			Type:    "layers",
+			DiffIDs: []digest.Digest{diffID},
+		},
+		History: []ocispec.History{
+			{
+				Created:   &created,
+				CreatedBy: "repro-get image build",
+			},
+		},
+	}
+	configDesc, err := writeJSONBlob(blobs, ocispec.MediaTypeImageConfig, img)
+	if err != nil {
+		return nil, err
+	}
+	manifest := ocispec.Manifest{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispec.MediaTypeImageManifest,
+		Config:      *configDesc,
+		Layers:      []ocispec.Descriptor{*layerDesc},
+		Annotations: opts.Annotations,
+	}
+	manifestDesc, err := writeJSONBlob(blobs, ocispec.MediaTypeImageManifest, manifest)
+	if err != nil {
+		return nil, err
+	}
+	platform := opts.Platform
+	manifestDesc.Platform = &platform
+	if len(opts.Annotations) > 0 || opts.RefName != "" {
+		manifestDesc.Annotations = make(map[string]string, len(opts.Annotations)+1)
+		for k, v := range opts.Annotations {
+			manifestDesc.Annotations[k] = v
+		}
+		if opts.RefName != "" {
+			manifestDesc.Annotations[ocispec.AnnotationRefName] = opts.RefName
+		}
+	}
+	idx := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{*manifestDesc},
+	}
+	if err := writeJSONFile(filepath.Join(dir, "index.json"), idx); err != nil {
+		return nil, err
+	}
+	if err := writeJSONFile(filepath.Join(dir, ocispec.ImageLayoutFile), ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion}); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Created the image %s (layer %s, %d bytes)", manifestDesc.Digest, layerDesc.Digest, layerDesc.Size)
+	return manifestDesc, nil
+}
+
+// writeLayerBlob writes the gzip-compressed layer into the blobs directory.
+// Returns the descriptor, the digest of the uncompressed layer, and the latest modification time of the files.
+func writeLayerBlob(blobs, root string, opts LayerOpts) (*ocispec.Descriptor, digest.Digest, time.Time, error) {
+	f, err := os.CreateTemp(blobs, ".layer-*.tmp")
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	compressedDigester := digest.Canonical.Digester()
+	cw := &countWriter{w: io.MultiWriter(f, compressedDigester.Hash())}
+	zw := gzip.NewWriter(cw) // The header has no name and no mtime
+	diffIDDigester := digest.Canonical.Digester()
+	latest, err := WriteLayer(io.MultiWriter(zw, diffIDDigester.Hash()), root, opts)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return nil, "", time.Time{}, err
+	}
+	desc := &ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    compressedDigester.Digest(),
+		Size:      cw.n,
+	}
+	if err := os.Rename(f.Name(), filepath.Join(blobs, desc.Digest.Encoded())); err != nil {
+		return nil, "", time.Time{}, err
+	}
+	return desc, diffIDDigester.Digest(), latest, nil
+}
+
+// WriteLayer writes the root filesystem as an uncompressed tar, in the lexical order of the file names.
+// Returns the latest modification time of the files, after clamping with opts.ClampTime.
+//
+// The access times, the change times, the user names, the group names, and the extended attributes are not recorded.
+// The files are owned by 0:0 unless opts.KeepOwner is true, or the owners are specified in opts.Owners.
+func WriteLayer(w io.Writer, root string, opts LayerOpts) (time.Time, error) {
+	var latest time.Time
+	tw := tar.NewWriter(w)
+	links := make(map[uint64]string) // inode -> name
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var linkname string
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			if linkname, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		if fi.Mode()&fs.ModeSocket != 0 {
+			logrus.Warnf("Ignoring the socket %q", name)
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(fi, linkname)
+		if err != nil {
+			return fmt.Errorf("failed to create the header for %q: %w", name, err)
+		}
+		hdr.Name = name
+		if fi.IsDir() && !strings.HasSuffix(hdr.Name, "/") {
+			hdr.Name += "/"
+		}
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+		hdr.ModTime = hdr.ModTime.UTC().Truncate(time.Second)
+		if opts.ClampTime != nil && hdr.ModTime.After(*opts.ClampTime) {
+			hdr.ModTime = opts.ClampTime.UTC().Truncate(time.Second)
+		}
+		if hdr.ModTime.After(latest) {
+			latest = hdr.ModTime
+		}
+		hdr.Uid, hdr.Gid = 0, 0
+		if st, ok := statOf(fi); ok {
+			if opts.KeepOwner {
+				hdr.Uid, hdr.Gid = st.uid, st.gid
+			}
+			if fi.Mode().IsRegular() && st.nlink > 1 {
+				if target, ok := links[st.ino]; ok {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = target
+					hdr.Size = 0
+				} else {
+					links[st.ino] = name
+				}
+			}
+		}
+		if o, ok := opts.Owners[name]; ok {
+			hdr.Uid, hdr.Gid = o.UID, o.GID
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write the header for %q: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("failed to write %q: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return latest, err
+	}
+	return latest, tw.Close()
+}
+
+func writeJSONBlob(blobs, mediaType string, v interface{}) (*ocispec.Descriptor, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	desc := &ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.Canonical.FromBytes(b),
+		Size:      int64(len(b)),
+	}
+	if err := os.WriteFile(filepath.Join(blobs, desc.Digest.Encoded()), b, 0644); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func writeJSONFile(f string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f, b, 0644)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// ParsePlatform parses the architecture string such as "amd64", "arm64", and "arm-v7", into the Linux platform.
+func ParsePlatform(arch string) (ocispec.Platform, error) {
+	if arch == "" {
+		return ocispec.Platform{}, errors.New("architecture needs to be specified")
+	}
+	p := ocispec.Platform{OS: "linux", Architecture: arch}
+	if a, v, ok := strings.Cut(arch, "-"); ok {
+		p.Architecture, p.Variant = a, v
+	}
+	return p, nil
+}
@@ -0,0 +1,125 @@
+package ociimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"gotest.tools/v3/assert"
+)
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+	assert.NilError(t, os.MkdirAll(filepath.Join(root, "usr/bin"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "usr/bin/foo"), []byte("foo"), 0755))
+	assert.NilError(t, os.Link(filepath.Join(root, "usr/bin/foo"), filepath.Join(root, "usr/bin/foo2")))
+	assert.NilError(t, os.Symlink("usr/bin", filepath.Join(root, "bin")))
+	latest := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	for _, f := range []string{"usr/bin/foo", "usr/bin", "usr"} {
+		assert.NilError(t, os.Chtimes(filepath.Join(root, f), latest, latest))
+	}
+
+	opts := Opts{
+		Platform:    ocispec.Platform{Architecture: "arm", OS: "linux", Variant: "v7"},
+		RefName:     "latest",
+		Annotations: map[string]string{ocispec.AnnotationSource: "https://example.com/foo.git"},
+	}
+	epoch := time.Date(2021, 12, 20, 0, 0, 0, 0, time.UTC)
+	opts.Created = &epoch
+	out := filepath.Join(t.TempDir(), "out")
+	desc, err := Build(out, root, opts)
+	assert.NilError(t, err)
+	assert.Equal(t, "latest", desc.Annotations[ocispec.AnnotationRefName])
+
+	out2 := filepath.Join(t.TempDir(), "out")
+	desc2, err := Build(out2, root, opts)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, desc, desc2)
+	_, err = Build(out2, root, opts)
+	assert.ErrorContains(t, err, "not empty")
+
+	var idx ocispec.Index
+	readJSON(t, filepath.Join(out, "index.json"), &idx)
+	assert.Equal(t, 1, len(idx.Manifests))
+	assert.DeepEqual(t, *desc, idx.Manifests[0])
+	var manifest ocispec.Manifest
+	readJSON(t, blobPath(out, *desc), &manifest)
+	assert.Equal(t, "https://example.com/foo.git", manifest.Annotations[ocispec.AnnotationSource])
+	var img ocispec.Image
+	readJSON(t, blobPath(out, manifest.Config), &img)
+	assert.Equal(t, epoch, *img.Created)
+	assert.Equal(t, "v7", img.Variant)
+	assert.DeepEqual(t, DefaultEnv, img.Config.Env)
+	assert.Equal(t, 1, len(img.RootFS.DiffIDs))
+
+	var layer bytes.Buffer
+	_, err = WriteLayer(&layer, root, LayerOpts{Owners: map[string]Owner{"usr/bin": {UID: 1000, GID: 1000}}})
+	assert.NilError(t, err)
+	tr := tar.NewReader(&layer)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		names = append(names, hdr.Name)
+		if hdr.Name == "usr/bin/" {
+			assert.Equal(t, 1000, hdr.Uid)
+		} else {
+			assert.Equal(t, 0, hdr.Uid)
+		}
+		assert.Equal(t, "", hdr.Uname)
+		switch hdr.Name {
+		case "usr/bin/foo":
+			assert.Equal(t, latest, hdr.ModTime.UTC())
+		case "usr/bin/foo2":
+			assert.Equal(t, byte(tar.TypeLink), hdr.Typeflag)
+			assert.Equal(t, "usr/bin/foo", hdr.Linkname)
+		case "bin":
+			assert.Equal(t, "usr/bin", hdr.Linkname)
+		}
+	}
+	assert.DeepEqual(t, []string{"bin", "usr/", "usr/bin/", "usr/bin/foo", "usr/bin/foo2"}, names)
+
+	layer.Reset()
+	clamped, err := WriteLayer(&layer, root, LayerOpts{ClampTime: &epoch})
+	assert.NilError(t, err)
+	assert.Equal(t, epoch, clamped)
+	tr = tar.NewReader(&layer)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, epoch, hdr.ModTime.UTC(), hdr.Name)
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	p, err := ParsePlatform("arm-v7")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ocispec.Platform{Architecture: "arm", OS: "linux", Variant: "v7"}, p)
+	p, err = ParsePlatform("amd64")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ocispec.Platform{Architecture: "amd64", OS: "linux"}, p)
+	_, err = ParsePlatform("")
+	assert.ErrorContains(t, err, "needs to be specified")
+}
+
+func blobPath(dir string, desc ocispec.Descriptor) string {
+	return filepath.Join(dir, "blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded())
+}
+
+func readJSON(t *testing.T, f string, v interface{}) {
+	b, err := os.ReadFile(f)
+	assert.NilError(t, err)
+	assert.NilError(t, json.Unmarshal(b, v))
+}
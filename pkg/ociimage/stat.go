@@ -0,0 +1,8 @@
+package ociimage
+
+// stat is the owner and the inode of a file.
+type stat struct {
+	uid, gid int
+	nlink    uint64
+	ino      uint64
+}
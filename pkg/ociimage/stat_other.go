@@ -0,0 +1,11 @@
+//go:build !unix
+
+package ociimage
+
+import "io/fs"
+
+// statOf returns false, as the owner and the inode are not available.
+// The files are owned by root, and the hard links are stored as regular files.
+func statOf(fs.FileInfo) (stat, bool) {
+	return stat{}, false
+}
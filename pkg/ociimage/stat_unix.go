@@ -0,0 +1,22 @@
+//go:build unix
+
+package ociimage
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// statOf returns the owner and the inode of the file.
+func statOf(fi fs.FileInfo) (stat, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return stat{}, false
+	}
+	return stat{
+		uid:   int(st.Uid),
+		gid:   int(st.Gid),
+		nlink: uint64(st.Nlink), //nolint:unconvert // Nlink is uint16 on some platforms
+		ino:   uint64(st.Ino),   //nolint:unconvert // Ino is uint32 on some platforms
+	}, true
+}
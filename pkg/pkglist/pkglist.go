@@ -0,0 +1,153 @@
+// Package pkglist lists the packages in the hash files.
+package pkglist
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// Format is the output format of Write.
+type Format string
+
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+	FormatCSV   Format = "csv"
+)
+
+// Formats is the list of the supported formats.
+var Formats = []Format{FormatTable, FormatJSON, FormatCSV}
+
+// Entry is a package in the hash files.
+type Entry struct {
+	Name         string `json:"Name"`                   // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Package      string `json:"Package,omitempty"`      // "hello"
+	Version      string `json:"Version,omitempty"`      // "2.10-2"
+	Architecture string `json:"Architecture,omitempty"` // "amd64", when known from the file name
+	Digest       string `json:"Digest"`                 // "sha256:35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
+	Cached       bool   `json:"Cached"`
+	Size         int64  `json:"Size,omitempty"` // Known only when cached, as the hash file does not contain the sizes
+}
+
+// New returns the entries of the file specs, sorted by the package names.
+// The package names are resolved with d.PackageName, falling back to the names parsed from the file names.
+// The sizes are looked up in c, when c is non-nil.
+func New(d distro.Distro, c *cache.Cache, fileSpecs map[string]*filespec.FileSpec) ([]Entry, error) {
+	res := make([]Entry, 0, len(fileSpecs))
+	for _, sp := range fileSpecs {
+		ent := newEntry(sp)
+		if d != nil {
+			if pkgName, err := d.PackageName(*sp); err == nil {
+				ent.Package = pkgName
+			}
+		}
+		if c != nil {
+			size, err := c.BlobSize(sp.Sum())
+			switch {
+			case err == nil:
+				ent.Cached, ent.Size = true, size
+			case !errors.Is(err, os.ErrNotExist):
+				return nil, fmt.Errorf("failed to get the size of %q: %w", sp.Name, err)
+			}
+		}
+		res = append(res, ent)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Package != res[j].Package {
+			return res[i].Package < res[j].Package
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func newEntry(sp *filespec.FileSpec) Entry {
+	ent := Entry{Name: sp.Name, Digest: sp.Sum()}
+	if !strings.Contains(ent.Digest, ":") {
+		ent.Digest = "sha256:" + ent.Digest
+	}
+	switch {
+	case sp.Dpkg != nil:
+		ent.Package, ent.Version, ent.Architecture = sp.Dpkg.Package, sp.Dpkg.Version, sp.Dpkg.Architecture
+		if v, err := url.PathUnescape(ent.Version); err == nil {
+			ent.Version = v // "1%3a2.0-1" to "1:2.0-1"
+		}
+	case sp.RPM != nil:
+		ent.Package, ent.Version, ent.Architecture = sp.RPM.Package, sp.RPM.Version+"-"+sp.RPM.Release, sp.RPM.Architecture
+	case sp.APK != nil:
+		ent.Package, ent.Version = sp.APK.Package, sp.APK.Version
+	case sp.NPM != nil:
+		ent.Package, ent.Version = sp.NPM.Package, sp.NPM.Version
+	case sp.GoMod != nil:
+		ent.Package, ent.Version = sp.GoMod.Module, sp.GoMod.Version
+	case sp.Crate != nil:
+		ent.Package, ent.Version = sp.Crate.Package, sp.Crate.Version
+	case sp.Maven != nil:
+		ent.Package, ent.Version = sp.Maven.Group+":"+sp.Maven.Artifact, sp.Maven.Version
+	}
+	return ent
+}
+
+var columns = []string{"PACKAGE", "VERSION", "ARCHITECTURE", "SIZE", "DIGEST"}
+
+func (ent Entry) row() []string {
+	size := ""
+	if ent.Cached {
+		size = strconv.FormatInt(ent.Size, 10)
+	}
+	return []string{ent.Package, ent.Version, ent.Architecture, size, ent.Digest}
+}
+
+// Write writes the entries in the format.
+// In FormatTable, the unknown values are printed as "-".
+func Write(w io.Writer, entries []Entry, format Format) error {
+	switch format {
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 4, 8, 4, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+		for _, ent := range entries {
+			row := ent.row()
+			for i := range row {
+				if row[i] == "" {
+					row[i] = "-"
+				}
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case FormatJSON:
+		b, err := json.MarshalIndent(entries, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+		for _, ent := range entries {
+			if err := cw.Write(ent.row()); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown format %q (expected one of %v)", format, Formats)
+	}
+}
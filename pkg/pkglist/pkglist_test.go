@@ -0,0 +1,65 @@
+package pkglist
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/distro/debian"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("foo"))
+	}))
+	defer srv.Close()
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	fooSHA256 := digest.SHA256.FromString("foo").Encoded()
+	u, err := url.Parse(srv.URL + "/foo")
+	assert.NilError(t, err)
+	assert.NilError(t, c.Ensure(context.TODO(), u, fooSHA256))
+
+	fileSpecs, err := filespec.NewFromSums(map[string][]string{
+		"pool/main/t/tzdata/tzdata_1%3a2021a-1_all.deb": {fooSHA256},
+		"pool/main/h/hello/hello_2.10-2_amd64.deb":      {"sha512:" + digest.SHA512.FromString("hello").Encoded()},
+		"pool/main/h/hello/hello_2.10-2.dsc":            {"2222222222222222222222222222222222222222222222222222222222222222"},
+	})
+	assert.NilError(t, err)
+	entries, err := New(debian.New(), c, fileSpecs)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "pool/main/h/hello/hello_2.10-2.dsc", entries[0].Name) // Not a binary package
+	assert.DeepEqual(t, Entry{
+		Name:         "pool/main/t/tzdata/tzdata_1%3a2021a-1_all.deb",
+		Package:      "tzdata",
+		Version:      "1:2021a-1",
+		Architecture: "all",
+		Digest:       "sha256:" + fooSHA256,
+		Cached:       true,
+		Size:         3,
+	}, entries[2])
+
+	var b bytes.Buffer
+	assert.NilError(t, Write(&b, entries, FormatCSV))
+	assert.Equal(t, `PACKAGE,VERSION,ARCHITECTURE,SIZE,DIGEST
+,,,,sha256:2222222222222222222222222222222222222222222222222222222222222222
+hello,2.10-2,amd64,,sha512:`+digest.SHA512.FromString("hello").Encoded()+`
+tzdata,1:2021a-1,all,3,sha256:`+fooSHA256+`
+`, b.String())
+
+	b.Reset()
+	assert.NilError(t, Write(&b, entries[2:], FormatTable))
+	assert.Equal(t, `PACKAGE    VERSION      ARCHITECTURE    SIZE    DIGEST
+tzdata     1:2021a-1    all             3       sha256:`+fooSHA256+`
+`, b.String())
+
+	assert.ErrorContains(t, Write(&b, entries, "xml"), "unknown format")
+}
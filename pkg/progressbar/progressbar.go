@@ -7,6 +7,7 @@
 package progressbar
 
 import (
+	"context"
 	"os"
 	"time"
 
@@ -19,13 +20,13 @@ func New(size int64) (*pb.ProgressBar, error) {
 	bar := pb.New64(size)
 
 	bar.Set(pb.Bytes, true)
-	if isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd()) {
-		bar.SetTemplateString(`{{counters . }} {{bar . | green }} {{percent .}} {{speed . "%s/s"}}`)
+	if IsTerminal() {
+		bar.SetTemplateString(`{{counters . }} {{bar . | green }} {{percent .}} {{speed . "%s/s"}} {{rtime . "ETA %s"}}`)
 		bar.SetRefreshRate(200 * time.Millisecond)
 	} else {
 		bar.Set(pb.Terminal, false)
 		bar.Set(pb.ReturnSymbol, "\n")
-		bar.SetTemplateString(`{{counters . }} ({{percent .}}) {{speed . "%s/s"}}`)
+		bar.SetTemplateString(`{{counters . }} ({{percent .}}) {{speed . "%s/s"}} {{rtime . "ETA %s"}}`)
 		bar.SetRefreshRate(5 * time.Second)
 	}
 	bar.SetWidth(80)
@@ -35,3 +36,25 @@ func New(size int64) (*pb.ProgressBar, error) {
 
 	return bar, nil
 }
+
+// IsTerminal returns true if stdout is a terminal.
+func IsTerminal() bool {
+	return isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+}
+
+// Start creates and starts the progress bar of a file, with the current value.
+// When ctx has a Tracker, the bar is rendered by the Tracker.
+// The caller has to call bar.Finish().
+func Start(ctx context.Context, size, current int64) (*pb.ProgressBar, error) {
+	bar, err := New(size)
+	if err != nil {
+		return nil, err
+	}
+	bar.SetCurrent(current)
+	if t := TrackerFromContext(ctx); t != nil {
+		t.attach(bar)
+		return bar, nil
+	}
+	bar.Start()
+	return bar, nil
+}
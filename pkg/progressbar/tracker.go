@@ -0,0 +1,205 @@
+package progressbar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+// Tracker renders the progress bar of the current file and the aggregate progress bar of a run.
+//
+// When stdout is not a terminal, the aggregate bar is not rendered, and the progress bars of the files
+// are printed as plain lines.
+type Tracker struct {
+	mu         sync.Mutex
+	w          io.Writer
+	terminal   bool
+	quiet      bool
+	total      *pb.ProgressBar // counts the files
+	current    *pb.ProgressBar // nil when no file is being downloaded
+	currentOff int64           // the initial value of current, for resumed downloads
+	downloaded int64
+	drawn      int // the number of the lines drawn on the terminal
+	done       chan struct{}
+	finished   chan struct{}
+}
+
+// NewTracker creates and starts a Tracker for the specified number of files.
+// When quiet is true, nothing is printed.
+// The caller has to call Finish.
+func NewTracker(files int, quiet bool) *Tracker {
+	t := &Tracker{
+		w:        os.Stdout,
+		terminal: IsTerminal() && !quiet,
+		quiet:    quiet,
+		total:    pb.New(files),
+	}
+	if quiet {
+		t.w = io.Discard
+	}
+	t.total.SetTemplateString(`Total: {{counters . }} files {{bar . | cyan }} {{percent .}} {{string . "downloaded"}} {{rtime . "ETA %s"}}`)
+	t.total.Set(pb.Static, true)
+	t.total.Set("downloaded", "(0 B downloaded)")
+	t.total.SetWidth(80)
+	t.total.Start()
+	if t.terminal {
+		t.done = make(chan struct{})
+		t.finished = make(chan struct{})
+		go t.loop()
+	}
+	return t
+}
+
+type trackerKey struct{}
+
+// WithTracker returns a context with the Tracker, for Start.
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// TrackerFromContext returns the Tracker in the context, or nil.
+func TrackerFromContext(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
+
+func (t *Tracker) loop() {
+	defer close(t.finished)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			t.redraw()
+			t.mu.Unlock()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// attach attaches the progress bar of the current file.
+func (t *Tracker) attach(bar *pb.ProgressBar) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.detach()
+	if t.quiet {
+		bar.SetWriter(io.Discard)
+	}
+	if t.terminal {
+		bar.Set(pb.Static, true)
+	}
+	bar.Start()
+	t.current, t.currentOff = bar, bar.Current()
+}
+
+// detach detaches the current progress bar, and leaves its last state above the aggregate bar.
+// Must be called with t.mu held.
+func (t *Tracker) detach() {
+	if t.current == nil {
+		return
+	}
+	t.downloaded += t.current.Current() - t.currentOff
+	t.total.Set("downloaded", "("+formatBytes(t.downloaded)+" downloaded)")
+	if t.terminal {
+		t.clear()
+		fmt.Fprintln(t.w, t.current.String())
+	} else if !t.quiet {
+		// The plain lines of pb are prefixed with "\n", not suffixed
+		fmt.Fprintln(os.Stderr)
+	}
+	t.current = nil
+	t.redraw()
+}
+
+// clear clears the lines drawn on the terminal.
+// Must be called with t.mu held.
+func (t *Tracker) clear() {
+	if t.drawn == 0 {
+		return
+	}
+	fmt.Fprint(t.w, "\r\033[K"+strings.Repeat("\033[1A\033[K", t.drawn-1))
+	t.drawn = 0
+}
+
+// redraw redraws the progress bars on the terminal.
+// Must be called with t.mu held.
+func (t *Tracker) redraw() {
+	if !t.terminal {
+		return
+	}
+	if t.current != nil && t.current.IsFinished() {
+		t.detach() // calls redraw
+		return
+	}
+	t.clear()
+	if t.current != nil {
+		fmt.Fprintln(t.w, t.current.String())
+		t.drawn++
+	}
+	fmt.Fprint(t.w, t.total.String())
+	t.drawn++
+}
+
+// Println prints a line above the progress bars.
+func (t *Tracker) Println(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clear()
+	fmt.Fprintln(t.w, s)
+	t.redraw()
+}
+
+// Write writes the logs to stderr, above the progress bars.
+// Useful for logrus.SetOutput.
+func (t *Tracker) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clear()
+	n, err := os.Stderr.Write(p)
+	t.redraw()
+	return n, err
+}
+
+// Increment increments the number of the files that have been processed.
+func (t *Tracker) Increment() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.detach()
+	t.total.Increment()
+	t.redraw()
+}
+
+// Finish stops the Tracker.
+func (t *Tracker) Finish() {
+	if t.terminal {
+		close(t.done)
+		<-t.finished
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.detach()
+	t.total.Finish()
+	t.clear()
+	fmt.Fprintln(t.w, t.total.String())
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,46 @@
+package remotecache
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// azblobVersion is the version of the Azure Blob Storage REST API.
+// 2019-12-12 or later is needed for putting blobs larger than 256 MiB in a single request.
+const azblobVersion = "2020-04-08"
+
+// newAzblob instantiates a remote cache for "azblob://ACCOUNT/CONTAINER/PREFIX".
+//
+// The SAS token is read from $AZURE_STORAGE_SAS_TOKEN.
+// Otherwise the requests are anonymous.
+// The shared key authorization is not supported.
+func newAzblob(u *url.URL) (*objectStore, error) {
+	account := u.Host
+	container, prefix, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if container == "" {
+		return nil, fmt.Errorf("expected azblob://ACCOUNT/CONTAINER/PREFIX, got %q", u.Redacted())
+	}
+	sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	host := account + ".blob.core.windows.net"
+	o := &objectStore{
+		name:   "azblob://" + account + u.Path,
+		prefix: strings.Trim(prefix, "/"),
+		objectURL: func(key string) *url.URL {
+			objURL := newURL("https", host, "/"+container+"/"+key)
+			objURL.RawQuery = sas
+			return objURL
+		},
+		authorize: func(req *http.Request, _ string) error {
+			req.Header.Set("X-Ms-Version", azblobVersion)
+			return nil
+		},
+		putHeader: http.Header{
+			"X-Ms-Blob-Type": []string{"BlockBlob"},
+		},
+		client: http.DefaultClient,
+	}
+	return o, nil
+}
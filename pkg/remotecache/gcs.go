@@ -0,0 +1,67 @@
+package remotecache
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newGCS instantiates a remote cache for "gs://BUCKET/PREFIX", using the XML API.
+//
+// The OAuth 2.0 access token is read from $GOOGLE_OAUTH_ACCESS_TOKEN,
+// or obtained with `gcloud auth print-access-token` when gcloud is installed.
+// Otherwise the requests are anonymous.
+func newGCS(u *url.URL) (*objectStore, error) {
+	bucket := u.Host
+	o := &objectStore{
+		name:   "gs://" + bucket + u.Path,
+		prefix: strings.Trim(u.Path, "/"),
+		objectURL: func(key string) *url.URL {
+			return newURL("https", "storage.googleapis.com", "/"+bucket+"/"+key)
+		},
+		client: http.DefaultClient,
+	}
+	var (
+		once     sync.Once
+		token    string
+		tokenErr error
+	)
+	o.authorize = func(req *http.Request, _ string) error {
+		once.Do(func() {
+			token, tokenErr = gcsAccessToken()
+		})
+		if tokenErr != nil {
+			return tokenErr
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return nil
+	}
+	return o, nil
+}
+
+func gcsAccessToken() (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	gcloudExe, err := exec.LookPath("gcloud")
+	if err != nil {
+		logrus.Debug("No access token is available for GCS (Hint: set $GOOGLE_OAUTH_ACCESS_TOKEN, or install gcloud)")
+		return "", nil
+	}
+	cmd := exec.Command(gcloudExe, "auth", "print-access-token")
+	cmd.Stderr = os.Stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	b, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to execute %v: %w", cmd.Args, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
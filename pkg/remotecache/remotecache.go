@@ -0,0 +1,215 @@
+// Package remotecache provides the remote cache backends.
+//
+// The blobs are stored as "<PREFIX>/blobs/sha256/<SHA256>" (or "<PREFIX>/blobs/sha512/<SHA512>"), as in the local cache.
+// The URL files and the reverse URL files are not stored in the remote cache.
+//
+// Supported URLs:
+//
+//   - s3://BUCKET/PREFIX : Amazon S3, or an S3-compatible service with $AWS_ENDPOINT_URL
+//
+//   - gs://BUCKET/PREFIX : Google Cloud Storage
+//
+//   - azblob://ACCOUNT/CONTAINER/PREFIX : Azure Blob Storage
+package remotecache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// Schemes is the list of the supported URL schemes.
+var Schemes = []string{
+	"s3",
+	"gs",
+	"azblob",
+}
+
+// IsRemote returns true if s is a URL with a supported scheme.
+func IsRemote(s string) bool {
+	for _, scheme := range Schemes {
+		if strings.HasPrefix(s, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// New instantiates a remote cache.
+func New(rawURL string) (cache.Remote, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse remote cache URL %q: %w", rawURL, err)
+	}
+	if u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid remote cache URL %q", u.Redacted())
+	}
+	switch u.Scheme {
+	case "s3":
+		return newS3(u)
+	case "gs":
+		return newGCS(u)
+	case "azblob":
+		return newAzblob(u)
+	default:
+		return nil, fmt.Errorf("unsupported remote cache URL scheme %q", u.Scheme)
+	}
+}
+
+// objectStore is a remote cache backed by an object storage service with a plain HTTP API.
+type objectStore struct {
+	name      string                            // URL of the remote cache, such as "s3://BUCKET/PREFIX"
+	prefix    string                            // Object name prefix, without the leading and trailing slashes
+	objectURL func(key string) *url.URL         // Returns the HTTP URL of the object
+	authorize func(*http.Request, string) error // Authorizes the request with the SHA256 of the payload, unless nil
+	putHeader http.Header                       // Extra headers for PUT requests
+	client    *http.Client
+}
+
+func (o *objectStore) String() string {
+	return o.name
+}
+
+func (o *objectStore) key(sha256sum string) (string, error) {
+	d, err := sha256sums.ParseSum(sha256sum)
+	if err != nil {
+		return "", err
+	}
+	// "blobs/sha256/<SHA256>", or "blobs/sha512/<SHA512>"
+	return path.Join(o.prefix, path.Dir(cache.BlobsSHA256RelPath), d.Algorithm().String(), d.Encoded()), nil
+}
+
+func (o *objectStore) do(req *http.Request, payloadSHA256 string) (*http.Response, error) {
+	if o.authorize != nil {
+		if err := o.authorize(req, payloadSHA256); err != nil {
+			return nil, err
+		}
+	}
+	u := *req.URL
+	u.RawQuery = "" // may contain credentials, such as Azure SAS tokens
+	logrus.Debugf("%s %s", req.Method, u.Redacted())
+	return o.client.Do(req)
+}
+
+// emptySHA256 is the SHA256 of the empty payload.
+var emptySHA256 = digest.SHA256.FromBytes(nil).Encoded()
+
+// unsignedPayload is used in place of the SHA256 of the payload, when it is unknown.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+func (o *objectStore) Get(ctx context.Context, sha256sum string) (io.ReadCloser, int64, error) {
+	key, err := o.key(sha256sum)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := o.do(req, emptySHA256)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, resp.ContentLength, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("%w: %s/%s", os.ErrNotExist, o.name, key)
+	default:
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("expected HTTP status %d for %s/%s, got %s", http.StatusOK, o.name, key, resp.Status)
+	}
+}
+
+func (o *objectStore) Stat(ctx context.Context, sha256sum string) (int64, error) {
+	key, err := o.key(sha256sum)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, o.objectURL(key).String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := o.do(req, emptySHA256)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusNotFound:
+		return 0, fmt.Errorf("%w: %s/%s", os.ErrNotExist, o.name, key)
+	default:
+		return 0, fmt.Errorf("expected HTTP status %d for %s/%s, got %s", http.StatusOK, o.name, key, resp.Status)
+	}
+}
+
+func (o *objectStore) Put(ctx context.Context, sha256sum string, r io.Reader, size int64) error {
+	key, err := o.key(sha256sum)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, o.objectURL(key).String(), io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	for k, v := range o.putHeader {
+		req.Header[k] = v
+	}
+	payloadSHA256 := sha256sum
+	if strings.Contains(sha256sum, ":") {
+		// The SHA256 of the payload is unknown for the SHA512 sums
+		payloadSHA256 = unsignedPayload
+	}
+	resp, err := o.do(req, payloadSHA256)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	default:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("expected HTTP status %d for %s/%s, got %s: %q", http.StatusOK, o.name, key, resp.Status, string(b))
+	}
+}
+
+// escapePath escapes the path, as in the URI encoding of AWS Signature Version 4.
+// Only the unreserved characters (A-Z, a-z, 0-9, '-', '.', '_', '~') and '/' are preserved.
+func escapePath(p string) string {
+	var sb strings.Builder
+	for _, b := range []byte(p) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '.', b == '_', b == '~', b == '/':
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// newURL returns a URL with the path, so that the escaped path is identical to escapePath(p).
+func newURL(scheme, host, p string) *url.URL {
+	return &url.URL{
+		Scheme:  scheme,
+		Host:    host,
+		Path:    p,
+		RawPath: escapePath(p),
+	}
+}
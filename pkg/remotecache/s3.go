@@ -0,0 +1,163 @@
+package remotecache
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// newS3 instantiates a remote cache for "s3://BUCKET/PREFIX".
+//
+// Environment variables:
+//   - $AWS_REGION, $AWS_DEFAULT_REGION: the region (default: "us-east-1")
+//   - $AWS_ENDPOINT_URL_S3, $AWS_ENDPOINT_URL: the endpoint of an S3-compatible service, such as "http://localhost:9000"
+//   - $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY, $AWS_SESSION_TOKEN: the credentials (default: anonymous)
+func newS3(u *url.URL) (*objectStore, error) {
+	bucket := u.Host
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	var objectURL func(key string) *url.URL
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		ep, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the S3 endpoint %q: %w", endpoint, err)
+		}
+		// Path-style, as virtual-hosted-style is not supported by some S3-compatible services
+		objectURL = func(key string) *url.URL {
+			return newURL(ep.Scheme, ep.Host, path.Join("/", ep.Path, bucket, key))
+		}
+	} else {
+		host := fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, region)
+		objectURL = func(key string) *url.URL {
+			return newURL("https", host, "/"+key)
+		}
+	}
+	o := &objectStore{
+		name:      "s3://" + bucket + u.Path,
+		prefix:    strings.Trim(u.Path, "/"),
+		objectURL: objectURL,
+		client:    http.DefaultClient,
+	}
+	if accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); accessKeyID != "" {
+		signer := &s3Signer{
+			accessKeyID:     accessKeyID,
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			region:          region,
+			now:             time.Now,
+		}
+		o.authorize = signer.sign
+	}
+	return o, nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// s3Signer signs the requests with AWS Signature Version 4.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+type s3Signer struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+	now             func() time.Time
+}
+
+const s3SignAlgorithm = "AWS4-HMAC-SHA256"
+
+// sign signs the request.
+// The host header and all the headers in req.Header are signed.
+func (s *s3Signer) sign(req *http.Request, payloadSHA256 string) error {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadSHA256)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	headerNames := make([]string, 0, len(headers))
+	for k := range headers {
+		headerNames = append(headerNames, k)
+	}
+	sort.Strings(headerNames)
+	var canonicalHeaders strings.Builder
+	for _, k := range headerNames {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadSHA256,
+	}, "\n")
+	scope := strings.Join([]string{date, s.region, "s3", "aws4_request"}, "/")
+	canonicalRequestSHA256 := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3SignAlgorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestSHA256[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SignAlgorithm, s.accessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var res []string
+	for _, k := range keys {
+		vals := q[k]
+		sort.Strings(vals)
+		for _, v := range vals {
+			res = append(res, escapePath(k)+"="+strings.ReplaceAll(escapePath(v), "/", "%2F"))
+		}
+	}
+	return strings.Join(res, "&")
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
@@ -0,0 +1,106 @@
+// Package reposerver serves the cached files as a package repository over HTTP.
+package reposerver
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// MetadataGenerator generates the repository metadata files, and returns map[path]content.
+type MetadataGenerator func(c *cache.Cache, fileSpecs map[string]*filespec.FileSpec) (map[string][]byte, error)
+
+type Opts struct {
+	MetadataGenerators []MetadataGenerator
+}
+
+// Server serves the cached files under the names in the hash file (e.g., "/pool/main/h/hello/hello_2.10-2_amd64.deb"),
+// and under the by-hash paths (e.g., "/pool/main/h/hello/by-hash/SHA256/<SHA256>").
+type Server struct {
+	cache     *cache.Cache
+	blobs     map[string]string // path -> sha256sum
+	metadata  map[string][]byte // path -> content
+	createdAt time.Time
+}
+
+func New(c *cache.Cache, fileSpecs map[string]*filespec.FileSpec, opts Opts) (*Server, error) {
+	s := &Server{
+		cache:     c,
+		blobs:     make(map[string]string, 2*len(fileSpecs)),
+		metadata:  make(map[string][]byte),
+		createdAt: time.Now(),
+	}
+	for _, sp := range fileSpecs {
+		s.blobs[sp.Name] = sp.Sum()
+		if sp.SHA256Path != "" {
+			s.blobs[sp.SHA256Path] = sp.Sum()
+		}
+	}
+	for _, gen := range opts.MetadataGenerators {
+		m, err := gen(c, fileSpecs)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			s.metadata[k] = v
+		}
+	}
+	return s, nil
+}
+
+// MetadataPaths returns the paths of the generated metadata files.
+func (s *Server) MetadataPaths() []string {
+	res := make([]string, 0, len(s.metadata))
+	for k := range s.metadata {
+		res = append(res, k)
+	}
+	return res
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	p := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if b, ok := s.metadata[p]; ok {
+		http.ServeContent(w, r, path.Base(p), s.createdAt, bytes.NewReader(b))
+		return
+	}
+	sha256sum, ok := s.blobs[p]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	blob, err := s.cache.BlobAbsPath(sha256sum)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logrus.Warnf("%q (%s) is not cached", p, sha256sum)
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logrus.Debugf("Serving %q (%s)", p, sha256sum)
+	http.ServeContent(w, r, path.Base(p), st.ModTime(), f)
+}
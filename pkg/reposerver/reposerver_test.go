@@ -0,0 +1,62 @@
+package reposerver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-containers/repro-get/pkg/cache"
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func TestServer(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	assert.NilError(t, err)
+	sha256sum, err := c.ImportWithReader(strings.NewReader("hello"))
+	assert.NilError(t, err)
+	sp, err := filespec.New("pool/main/h/hello/hello_2.10-2_amd64.deb", sha256sum)
+	assert.NilError(t, err)
+	missing, err := filespec.New("pool/main/m/missing/missing_1.0_amd64.deb", "0000000000000000000000000000000000000000000000000000000000000000")
+	assert.NilError(t, err)
+	fileSpecs := map[string]*filespec.FileSpec{sp.Name: sp, missing.Name: missing}
+	gen := func(*cache.Cache, map[string]*filespec.FileSpec) (map[string][]byte, error) {
+		return map[string][]byte{"Packages": []byte("dummy")}, nil
+	}
+	s, err := New(c, fileSpecs, Opts{MetadataGenerators: []MetadataGenerator{gen}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"Packages"}, s.MetadataPaths())
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	get := func(p string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + p)
+		assert.NilError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		assert.NilError(t, err)
+		return resp.StatusCode, string(b)
+	}
+	testCases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/" + sp.Name, http.StatusOK, "hello"},
+		{"/" + sp.SHA256Path, http.StatusOK, "hello"},
+		{"/pool/main/h/hello/../hello/hello_2.10-2_amd64.deb", http.StatusOK, "hello"},
+		{"/Packages", http.StatusOK, "dummy"},
+		{"/" + missing.Name, http.StatusNotFound, ""},
+		{"/pool/main/u/unknown/unknown_1.0_amd64.deb", http.StatusNotFound, ""},
+	}
+	for _, tc := range testCases {
+		status, body := get(tc.path)
+		assert.Equal(t, tc.status, status, tc.path)
+		if tc.body != "" {
+			assert.Equal(t, tc.body, body, tc.path)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package rootfs provides the utilities for creating root filesystems from the package files.
+package rootfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/sirupsen/logrus"
+)
+
+// ExtractEntry extracts the tar entry into the root filesystem.
+// The content of the regular file is written to w too, when w is non-nil.
+// The unsupported types of the entries, such as device files, are ignored with a warning.
+func ExtractEntry(tr *tar.Reader, hdr *tar.Header, root string, w io.Writer) error {
+	dst, err := securejoin.SecureJoin(root, hdr.Name)
+	if err != nil {
+		return err
+	}
+	mode := hdr.FileInfo().Mode()
+	if hdr.Typeflag != tar.TypeDir {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(dst, mode.Perm()); err != nil {
+			return err
+		}
+	case tar.TypeReg:
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+		var fw io.Writer = f
+		if w != nil {
+			fw = io.MultiWriter(f, w)
+		}
+		_, err = io.Copy(fw, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := os.Symlink(hdr.Linkname, dst); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		target, err := securejoin.SecureJoin(root, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(target, dst); err != nil {
+			return err
+		}
+	default:
+		logrus.Warnf("Ignoring %q (unsupported type %q)", hdr.Name, hdr.Typeflag)
+		return nil
+	}
+	if os.Geteuid() == 0 {
+		if err := os.Lchown(dst, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+	if hdr.Typeflag != tar.TypeSymlink {
+		// chmod again, as the permission in the open mode is masked by umask, and chown clears setuid
+		if err := os.Chmod(dst, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return err
+		}
+	}
+	return lchtimes(dst, hdr.ModTime)
+}
+
+// EnsureEmpty creates the directory, or verifies that the existing directory is empty.
+func EnsureEmpty(dir string) error {
+	ents, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(dir, 0755)
+	}
+	if err != nil {
+		return err
+	}
+	if len(ents) > 0 {
+		return fmt.Errorf("directory %q is not empty", dir)
+	}
+	return nil
+}
+
+// Now returns the current time of the filesystem of dir.
+// The time may be slightly behind time.Now, as the filesystems use the coarse clock for the timestamps.
+func Now(dir string) (time.Time, error) {
+	f, err := os.CreateTemp(dir, ".repro-get-now-*")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return st.ModTime(), nil
+}
+
+// Normalize sets the modification times of the files that were modified at or after since, to epoch.
+// since should be obtained with Now.
+//
+// When epoch is nil, the latest modification time of the other files is used instead,
+// so that the result only depends on the files extracted from the packages.
+// The Unix epoch is used when there are no other files.
+func Normalize(root string, since time.Time, epoch *time.Time) error {
+	var modified []string
+	var latest time.Time
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if mt := fi.ModTime(); !mt.Before(since) {
+			modified = append(modified, p)
+		} else if mt.After(latest) {
+			latest = mt
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if epoch != nil {
+		latest = *epoch
+	} else if latest.IsZero() {
+		latest = time.Unix(0, 0)
+	}
+	logrus.Debugf("Setting the modification times of %d files to %s", len(modified), latest.UTC().Format(time.RFC3339))
+	for _, p := range modified {
+		if err := lchtimes(p, latest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
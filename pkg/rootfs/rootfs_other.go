@@ -0,0 +1,21 @@
+//go:build !unix
+
+package rootfs
+
+import (
+	"os"
+	"time"
+)
+
+// lchtimes is similar to os.Chtimes but skips symlinks, as their times cannot be set without following them.
+// The access time is set to the modification time.
+func lchtimes(p string, t time.Time) error {
+	st, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+	if st.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	return os.Chtimes(p, t, t)
+}
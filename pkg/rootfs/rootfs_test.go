@@ -0,0 +1,54 @@
+package rootfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEnsureEmpty(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "rootfs")
+	assert.NilError(t, EnsureEmpty(dir))
+	assert.NilError(t, EnsureEmpty(dir))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "foo"), nil, 0644))
+	assert.ErrorContains(t, EnsureEmpty(dir), "not empty")
+}
+
+func TestNormalize(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	older := old.Add(-time.Hour)
+	for f, mt := range map[string]time.Time{"old": old, "older": older} {
+		p := filepath.Join(root, f)
+		assert.NilError(t, os.WriteFile(p, nil, 0644))
+		assert.NilError(t, os.Chtimes(p, mt, mt))
+	}
+	since := time.Now().Add(-time.Second)
+	assert.NilError(t, os.WriteFile(filepath.Join(root, "new"), nil, 0644))
+	assert.NilError(t, os.Symlink("old", filepath.Join(root, "link")))
+
+	assert.NilError(t, Normalize(root, since, nil))
+	for f, expected := range map[string]time.Time{".": old, "new": old, "link": old, "old": old, "older": older} {
+		st, err := os.Lstat(filepath.Join(root, f))
+		assert.NilError(t, err)
+		assert.Assert(t, st.ModTime().Equal(expected), "%s: %v", f, st.ModTime())
+	}
+
+	epoch := time.Unix(0, 0)
+	assert.NilError(t, Normalize(root, old, &epoch))
+	for f, expected := range map[string]time.Time{".": epoch, "new": epoch, "old": epoch, "older": older} {
+		st, err := os.Lstat(filepath.Join(root, f))
+		assert.NilError(t, err)
+		assert.Assert(t, st.ModTime().Equal(expected), "%s: %v", f, st.ModTime())
+	}
+
+	// No file is older than since
+	empty := t.TempDir()
+	assert.NilError(t, Normalize(empty, time.Time{}, nil))
+	st, err := os.Stat(empty)
+	assert.NilError(t, err)
+	assert.Assert(t, st.ModTime().Equal(epoch), "%v", st.ModTime())
+}
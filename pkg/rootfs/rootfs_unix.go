@@ -0,0 +1,20 @@
+//go:build unix
+
+package rootfs
+
+import (
+	"io/fs"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lchtimes is similar to os.Chtimes but does not follow symlinks.
+// The access time is set to the modification time.
+func lchtimes(p string, t time.Time) error {
+	ts := unix.NsecToTimespec(t.UnixNano())
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, p, []unix.Timespec{ts, ts}, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return &fs.PathError{Op: "lchtimes", Path: p, Err: err}
+	}
+	return nil
+}
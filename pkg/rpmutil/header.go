@@ -0,0 +1,103 @@
+package rpmutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	leadSize       = 96
+	tagBuildTime   = 1006
+	typeInt32      = 4
+	maxHeaderBytes = 64 << 20
+)
+
+var headerMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+
+type headerIndexEntry struct {
+	Tag    uint32
+	Type   uint32
+	Offset uint32
+	Count  uint32
+}
+
+// BuildTime returns the BUILDTIME of the *.rpm file, which is set to $SOURCE_DATE_EPOCH by rpmbuild
+// when use_source_date_epoch_as_buildtime is enabled.
+func BuildTime(r io.Reader) (time.Time, error) {
+	br := bufio.NewReader(r)
+	if _, err := io.CopyN(io.Discard, br, leadSize); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the lead: %w", err)
+	}
+	// The signature header, padded to 8 bytes
+	_, sigSize, err := readHeader(br)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the signature header: %w", err)
+	}
+	if pad := (8 - sigSize%8) % 8; pad > 0 {
+		if _, err := io.CopyN(io.Discard, br, int64(pad)); err != nil {
+			return time.Time{}, err
+		}
+	}
+	hdr, _, err := readHeader(br)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the header: %w", err)
+	}
+	v, err := hdr.int32(tagBuildTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read BUILDTIME: %w", err)
+	}
+	return time.Unix(int64(v), 0).UTC(), nil
+}
+
+type header struct {
+	index []headerIndexEntry
+	store []byte
+}
+
+// readHeader reads the header structure. Returns the header and the number of the bytes read.
+func readHeader(r io.Reader) (*header, int, error) {
+	var intro [16]byte // magic (4 bytes), reserved (4 bytes), the number of the index entries, the size of the store
+	if _, err := io.ReadFull(r, intro[:]); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(intro[:4], headerMagic) {
+		return nil, 0, errors.New("bad magic")
+	}
+	nIndex := binary.BigEndian.Uint32(intro[8:12])
+	storeSize := binary.BigEndian.Uint32(intro[12:16])
+	if uint64(nIndex)*16+uint64(storeSize) > maxHeaderBytes {
+		return nil, 0, errors.New("too large header")
+	}
+	hdr := &header{
+		index: make([]headerIndexEntry, nIndex),
+		store: make([]byte, storeSize),
+	}
+	if err := binary.Read(r, binary.BigEndian, hdr.index); err != nil {
+		return nil, 0, err
+	}
+	if _, err := io.ReadFull(r, hdr.store); err != nil {
+		return nil, 0, err
+	}
+	return hdr, len(intro) + int(nIndex)*16 + int(storeSize), nil
+}
+
+func (hdr *header) int32(tag uint32) (uint32, error) {
+	for _, e := range hdr.index {
+		if e.Tag != tag {
+			continue
+		}
+		if e.Type != typeInt32 || e.Count < 1 {
+			return 0, fmt.Errorf("unexpected type %d (count %d) for tag %d", e.Type, e.Count, tag)
+		}
+		if uint64(e.Offset)+4 > uint64(len(hdr.store)) {
+			return 0, fmt.Errorf("out-of-range offset %d for tag %d", e.Offset, tag)
+		}
+		return binary.BigEndian.Uint32(hdr.store[e.Offset:]), nil
+	}
+	return 0, fmt.Errorf("tag %d not found", tag)
+}
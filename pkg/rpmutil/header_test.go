@@ -0,0 +1,43 @@
+package rpmutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func testHeader(entries []headerIndexEntry, store []byte) []byte {
+	var b bytes.Buffer
+	b.Write(headerMagic)
+	b.Write(make([]byte, 4))
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(entries)))
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(store)))
+	_ = binary.Write(&b, binary.BigEndian, entries)
+	b.Write(store)
+	return b.Bytes()
+}
+
+func TestBuildTime(t *testing.T) {
+	mt := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	var b bytes.Buffer
+	b.Write(make([]byte, leadSize))
+	sig := testHeader([]headerIndexEntry{{Tag: 1000, Type: typeInt32, Offset: 0, Count: 1}}, []byte{0, 0, 0, 42, 0})
+	b.Write(sig)
+	b.Write(make([]byte, (8-len(sig)%8)%8))
+	store := make([]byte, 8)
+	binary.BigEndian.PutUint32(store[4:], uint32(mt.Unix()))
+	b.Write(testHeader([]headerIndexEntry{
+		{Tag: 1000, Type: 6, Offset: 0, Count: 1},
+		{Tag: tagBuildTime, Type: typeInt32, Offset: 4, Count: 1},
+	}, store))
+
+	got, err := BuildTime(bytes.NewReader(b.Bytes()))
+	assert.NilError(t, err)
+	assert.Equal(t, mt, got)
+
+	_, err = BuildTime(bytes.NewReader(make([]byte, leadSize+16)))
+	assert.ErrorContains(t, err, "bad magic")
+}
@@ -0,0 +1,142 @@
+package rpmutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+)
+
+const (
+	sigTagDSAHeader      = 267  // DSA signature of the header
+	sigTagRSAHeader      = 268  // RSA signature of the header
+	sigTagPGP            = 1002 // Legacy RSA signature of the header and the payload
+	sigTagGPG            = 1005 // Legacy DSA signature of the header and the payload
+	tagPayloadDigest     = 5092
+	tagPayloadDigestAlgo = 5093
+	typeString           = 6
+	typeBin              = 7
+	typeStringArray      = 8
+	pgpHashAlgoSHA256    = 8
+	pgpHashAlgoSHA512    = 10
+)
+
+// ErrUnsigned is returned by VerifySignature for the unsigned *.rpm files.
+var ErrUnsigned = errors.New("the package is not signed")
+
+// VerifySignature verifies the OpenPGP signature of the *.rpm file with the keyring, and returns the signer.
+// The signature of the header (RSAHEADER or DSAHEADER) is verified along with the payload digest in the header,
+// or the legacy signature of the header and the payload (SIGPGP or SIGGPG) is verified.
+func VerifySignature(r io.Reader, keyring openpgp.KeyRing) (*openpgp.Entity, error) {
+	br := bufio.NewReader(r)
+	if _, err := io.CopyN(io.Discard, br, leadSize); err != nil {
+		return nil, fmt.Errorf("failed to read the lead: %w", err)
+	}
+	sigHdr, sigSize, err := readHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the signature header: %w", err)
+	}
+	if pad := (8 - sigSize%8) % 8; pad > 0 {
+		if _, err := io.CopyN(io.Discard, br, int64(pad)); err != nil {
+			return nil, err
+		}
+	}
+	var hdrBytes bytes.Buffer
+	hdr, _, err := readHeader(io.TeeReader(br, &hdrBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header: %w", err)
+	}
+	if sig := sigHdr.firstBin(sigTagRSAHeader, sigTagDSAHeader); sig != nil {
+		if digests, err := hdr.stringArray(tagPayloadDigest); err == nil && len(digests) > 0 {
+			signer, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(hdrBytes.Bytes()), bytes.NewReader(sig))
+			if err != nil {
+				return nil, fmt.Errorf("failed to verify the signature of the header: %w", err)
+			}
+			if err = verifyPayloadDigest(br, hdr, digests[0]); err != nil {
+				return nil, err
+			}
+			return signer, nil
+		}
+	}
+	if sig := sigHdr.firstBin(sigTagPGP, sigTagGPG); sig != nil {
+		signer, err := openpgp.CheckDetachedSignature(keyring, io.MultiReader(bytes.NewReader(hdrBytes.Bytes()), br), bytes.NewReader(sig))
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify the signature of the header and the payload: %w", err)
+		}
+		return signer, nil
+	}
+	return nil, ErrUnsigned
+}
+
+func verifyPayloadDigest(payload io.Reader, hdr *header, expected string) error {
+	algo := uint32(pgpHashAlgoSHA256)
+	if v, err := hdr.int32(tagPayloadDigestAlgo); err == nil {
+		algo = v
+	}
+	var h hash.Hash
+	switch algo {
+	case pgpHashAlgoSHA256:
+		h = sha256.New()
+	case pgpHashAlgoSHA512:
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported payload digest algorithm %d", algo)
+	}
+	if _, err := io.Copy(h, payload); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, expected) {
+		return fmt.Errorf("expected the payload digest %s, got %s", expected, got)
+	}
+	return nil
+}
+
+// firstBin returns the non-empty value of the first tag found in the header, or nil.
+func (hdr *header) firstBin(tags ...uint32) []byte {
+	for _, tag := range tags {
+		for _, e := range hdr.index {
+			if e.Tag != tag || e.Type != typeBin || e.Count == 0 {
+				continue
+			}
+			if uint64(e.Offset)+uint64(e.Count) > uint64(len(hdr.store)) {
+				return nil
+			}
+			return hdr.store[e.Offset : e.Offset+e.Count]
+		}
+	}
+	return nil
+}
+
+func (hdr *header) stringArray(tag uint32) ([]string, error) {
+	for _, e := range hdr.index {
+		if e.Tag != tag {
+			continue
+		}
+		if e.Type != typeString && e.Type != typeStringArray {
+			return nil, fmt.Errorf("unexpected type %d for tag %d", e.Type, tag)
+		}
+		if uint64(e.Offset) > uint64(len(hdr.store)) {
+			return nil, fmt.Errorf("out-of-range offset %d for tag %d", e.Offset, tag)
+		}
+		var ss []string
+		rest := hdr.store[e.Offset:]
+		for i := uint32(0); i < e.Count; i++ {
+			n := bytes.IndexByte(rest, 0)
+			if n < 0 {
+				return nil, fmt.Errorf("unterminated string for tag %d", tag)
+			}
+			ss = append(ss, string(rest[:n]))
+			rest = rest[n+1:]
+		}
+		return ss, nil
+	}
+	return nil, fmt.Errorf("tag %d not found", tag)
+}
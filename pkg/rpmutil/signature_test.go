@@ -0,0 +1,70 @@
+package rpmutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/openpgp" //nolint:staticcheck // pault.ag/go/debian/control still depends on x/crypto/openpgp
+	"gotest.tools/v3/assert"
+)
+
+func testSignedRPM(t *testing.T, signer *openpgp.Entity, payload []byte, legacy bool) []byte {
+	digest := sha256.Sum256(payload)
+	store := append([]byte(hex.EncodeToString(digest[:])), 0, 0, 0, 0) // NUL and the padding for the int32
+	binary.BigEndian.PutUint32(store[len(store)-4:], pgpHashAlgoSHA256)
+	hdr := testHeader([]headerIndexEntry{
+		{Tag: tagPayloadDigest, Type: typeStringArray, Offset: 0, Count: 1},
+		{Tag: tagPayloadDigestAlgo, Type: typeInt32, Offset: uint32(len(store) - 4), Count: 1},
+	}, store)
+
+	var sig bytes.Buffer
+	tag := uint32(sigTagRSAHeader)
+	signed := hdr
+	if legacy {
+		tag = sigTagPGP
+		signed = append(append([]byte{}, hdr...), payload...)
+	}
+	if signer != nil {
+		assert.NilError(t, openpgp.DetachSign(&sig, signer, bytes.NewReader(signed), nil))
+	}
+	sigHdr := testHeader([]headerIndexEntry{{Tag: tag, Type: typeBin, Offset: 0, Count: uint32(sig.Len())}}, sig.Bytes())
+
+	var b bytes.Buffer
+	b.Write(make([]byte, leadSize))
+	b.Write(sigHdr)
+	b.Write(make([]byte, (8-len(sigHdr)%8)%8))
+	b.Write(hdr)
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func TestVerifySignature(t *testing.T) {
+	signer, err := openpgp.NewEntity("signer", "", "signer@example.com", nil)
+	assert.NilError(t, err)
+	stranger, err := openpgp.NewEntity("stranger", "", "stranger@example.com", nil)
+	assert.NilError(t, err)
+	keyring := openpgp.EntityList{signer}
+	payload := []byte("payload")
+
+	for _, legacy := range []bool{false, true} {
+		rpm := testSignedRPM(t, signer, payload, legacy)
+		got, err := VerifySignature(bytes.NewReader(rpm), keyring)
+		assert.NilError(t, err)
+		assert.Equal(t, signer.PrimaryKey.Fingerprint, got.PrimaryKey.Fingerprint)
+
+		tampered := append([]byte{}, rpm...)
+		tampered[len(tampered)-1] ^= 0xff
+		_, err = VerifySignature(bytes.NewReader(tampered), keyring)
+		assert.Assert(t, err != nil)
+
+		_, err = VerifySignature(bytes.NewReader(rpm), openpgp.EntityList{stranger})
+		assert.ErrorContains(t, err, "failed to verify")
+	}
+
+	_, err = VerifySignature(bytes.NewReader(testSignedRPM(t, nil, payload, false)), keyring)
+	assert.Assert(t, errors.Is(err, ErrUnsigned))
+}
@@ -0,0 +1,132 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+)
+
+// https://cyclonedx.org/docs/1.5/json/
+const cycloneDXSpecVersion = "1.5"
+
+type cycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp"`
+	Tools     cycloneDXTools      `json:"tools"`
+	Component *cycloneDXComponent `json:"component,omitempty"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type               string              `json:"type"` // "library", "application", "file"
+	BOMRef             string              `json:"bom-ref,omitempty"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version,omitempty"`
+	Hashes             []cycloneDXHash     `json:"hashes,omitempty"`
+	PURL               string              `json:"purl,omitempty"`
+	ExternalReferences []cycloneDXExtRef   `json:"externalReferences,omitempty"`
+	Properties         []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"` // "SHA-256"
+	Content string `json:"content"`
+}
+
+type cycloneDXExtRef struct {
+	Type string `json:"type"` // "distribution"
+	URL  string `json:"url"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cycloneDXHashAlgs maps the algorithms of the sums to the CycloneDX hash algorithms.
+var cycloneDXHashAlgs = map[string]string{
+	"sha256": "SHA-256",
+	"sha512": "SHA-512",
+	"blake3": "BLAKE3",
+}
+
+func writeCycloneDX(w io.Writer, pkgs []Package, opts Opts) error {
+	name := opts.Name
+	if name == "" {
+		name = "repro-get"
+	}
+	version := strings.TrimPrefix(opts.ToolVersion, "v")
+	bom := cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: cycloneDXSpecVersion,
+		// The serial number is derived from the content, so that the same hash file always produces the same BOM
+		SerialNumber: "urn:uuid:" + uuidFromDigest(contentDigest(pkgs)),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: opts.Created.UTC().Format(time.RFC3339),
+			Tools: cycloneDXTools{
+				Components: []cycloneDXComponent{{Type: "application", Name: "repro-get", Version: version}},
+			},
+			Component: &cycloneDXComponent{Type: "file", Name: name},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	refs := make(map[string]bool)
+	for _, pkg := range pkgs {
+		c := cycloneDXComponent{
+			Type:       "library",
+			Name:       pkg.Name,
+			Version:    pkg.Version,
+			PURL:       pkg.PURL,
+			Properties: []cycloneDXProperty{{Name: "repro-get:filename", Value: pkg.FileName}},
+		}
+		// The bom-ref has to be unique in the BOM, while the purl may not be unique (e.g., "*.mod" and "*.zip" of a Go module)
+		c.BOMRef = pkg.PURL
+		if c.BOMRef == "" || refs[c.BOMRef] {
+			c.BOMRef = pkg.FileName
+		}
+		refs[c.BOMRef] = true
+		for _, sum := range pkg.Sums {
+			d, err := sha256sums.ParseSum(sum)
+			if err != nil {
+				return err
+			}
+			alg, ok := cycloneDXHashAlgs[d.Algorithm().String()]
+			if !ok {
+				return fmt.Errorf("unsupported algorithm %q", d.Algorithm())
+			}
+			c.Hashes = append(c.Hashes, cycloneDXHash{Alg: alg, Content: d.Encoded()})
+		}
+		if pkg.DownloadLocation != "" {
+			c.ExternalReferences = []cycloneDXExtRef{{Type: "distribution", URL: pkg.DownloadLocation}}
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(bom)
+}
+
+// uuidFromDigest returns a UUID string in the RFC 4122 layout (version 5), from the first 16 bytes of the digest.
+func uuidFromDigest(d []byte) string {
+	var b [16]byte
+	copy(b[:], d)
+	b[6] = (b[6] & 0x0f) | 0x50
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
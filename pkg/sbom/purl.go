@@ -0,0 +1,85 @@
+package sbom
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// purlDistros is the set of the distros that can be used as the namespaces of the purls, per purl type.
+// The first one is the default.
+var purlDistros = map[string][]string{
+	"deb": {"debian", "ubuntu"},
+	"rpm": {"fedora"},
+	"apk": {"alpine"},
+}
+
+// purlNamespace returns the namespace of the deb, rpm, or apk purl for the distro.
+func purlNamespace(typ, distroName string) string {
+	distros := purlDistros[typ]
+	for _, f := range distros {
+		if f == distroName {
+			return f
+		}
+	}
+	return distros[0]
+}
+
+// purlEscaper escapes the characters that are not escaped by url.PathEscape, but have to be escaped in the purl components.
+var purlEscaper = strings.NewReplacer("@", "%40", ":", "%3A", "+", "%2B")
+
+func purlEscape(s string) string {
+	return purlEscaper.Replace(url.PathEscape(s))
+}
+
+// newPURL returns the package URL such as "pkg:deb/debian/hello@2.10-2?arch=amd64".
+// namespace may contain "/". Returns an empty string when the package type is unknown.
+//
+// https://github.com/package-url/purl-spec/blob/master/PURL-SPECIFICATION.rst
+func newPURL(typ, namespace, name, version, arch string) string {
+	s := "pkg:" + typ + "/"
+	if namespace != "" {
+		for _, f := range strings.Split(namespace, "/") {
+			s += purlEscape(f) + "/"
+		}
+	}
+	s += purlEscape(name)
+	if version != "" {
+		s += "@" + purlEscape(version)
+	}
+	if arch != "" {
+		s += "?arch=" + purlEscape(arch)
+	}
+	return s
+}
+
+// purlOf returns the package URL of the file spec, or an empty string if unknown.
+func purlOf(sp *filespec.FileSpec, pkg *Package, distroName string) string {
+	switch {
+	case sp.Dpkg != nil:
+		return newPURL("deb", purlNamespace("deb", distroName), pkg.Name, pkg.Version, pkg.Architecture)
+	case sp.RPM != nil:
+		return newPURL("rpm", purlNamespace("rpm", distroName), pkg.Name, pkg.Version, pkg.Architecture)
+	case sp.APK != nil:
+		return newPURL("apk", purlNamespace("apk", distroName), pkg.Name, pkg.Version, pkg.Architecture)
+	case sp.NPM != nil:
+		// "@babel/core" is represented as the namespace "@babel" and the name "core"
+		namespace, name, ok := strings.Cut(sp.NPM.Package, "/")
+		if !ok {
+			namespace, name = "", sp.NPM.Package
+		}
+		return newPURL("npm", namespace, name, sp.NPM.Version, "")
+	case sp.GoMod != nil:
+		namespace, name := "", sp.GoMod.Module
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			namespace, name = name[:i], name[i+1:]
+		}
+		return newPURL("golang", namespace, name, sp.GoMod.Version, "")
+	case sp.Crate != nil:
+		return newPURL("cargo", "", sp.Crate.Package, sp.Crate.Version, "")
+	case sp.Maven != nil:
+		return newPURL("maven", sp.Maven.Group, sp.Maven.Artifact, sp.Maven.Version, "")
+	}
+	return ""
+}
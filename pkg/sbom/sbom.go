@@ -0,0 +1,162 @@
+// Package sbom generates the software bills of materials (SBOMs) of the packages in the hash files.
+//
+// The SBOMs are generated only from the hash files and the metadata derived from the file names,
+// without accessing the network, so that the same hash file always produces the same SBOM.
+package sbom
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"github.com/sirupsen/logrus"
+)
+
+// Format is the format of an SBOM.
+type Format string
+
+const (
+	// FormatSPDXJSON is SPDX 2.3 in JSON.
+	FormatSPDXJSON Format = "spdx-json"
+	// FormatCycloneDXJSON is CycloneDX 1.5 in JSON.
+	FormatCycloneDXJSON Format = "cyclonedx-json"
+)
+
+// Formats is the list of the supported formats.
+var Formats = []Format{FormatSPDXJSON, FormatCycloneDXJSON}
+
+// Opts is the options for Write.
+type Opts struct {
+	Name        string    // The name of the SBOM document, such as "SHA256SUMS-amd64"
+	Providers   []string  // The providers for the download locations of the packages
+	Distro      string    // The distro name used as the namespace of the purls, such as "debian" and "ubuntu". Defaults to the distro of the package type.
+	Created     time.Time // The creation time of the SBOM document. Defaults to the current time.
+	ToolVersion string    // The version of repro-get, such as "0.3.0"
+}
+
+// Package is a package in an SBOM.
+type Package struct {
+	Name             string   // "hello", or the base name of the file when the package name is unknown
+	Version          string   // "2.10-2", empty when unknown
+	Architecture     string   // "amd64", empty when unknown
+	FileName         string   // "pool/main/h/hello/hello_2.10-2_amd64.deb"
+	Sums             []string // From the strongest one (see filespec.FileSpec.Sums)
+	DownloadLocation string   // "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb", empty when unknown
+	PURL             string   // "pkg:deb/debian/hello@2.10-2?arch=amd64", empty when unknown
+}
+
+// Packages returns the packages of the file specs, sorted by the file names.
+// The download location is resolved with the first provider (opts.Providers) that yields an HTTP(S) URL.
+func Packages(fileSpecs map[string]*filespec.FileSpec, opts Opts) []Package {
+	var res []Package
+	for _, sp := range fileSpecs {
+		if filespec.ParsePseudoFilename(sp.Name) != nil {
+			continue
+		}
+		pkg := Package{
+			Name:     sp.Basename,
+			FileName: sp.Name,
+			Sums:     sp.Sums(),
+		}
+		switch {
+		case sp.Dpkg != nil:
+			pkg.Name, pkg.Version, pkg.Architecture = sp.Dpkg.Package, sp.Dpkg.Version, sp.Dpkg.Architecture
+			if v, err := url.PathUnescape(pkg.Version); err == nil {
+				pkg.Version = v // "1%3a2.0-1" to "1:2.0-1"
+			}
+		case sp.RPM != nil:
+			pkg.Name, pkg.Version, pkg.Architecture = sp.RPM.Package, sp.RPM.Version+"-"+sp.RPM.Release, sp.RPM.Architecture
+		case sp.APK != nil:
+			pkg.Name, pkg.Version, pkg.Architecture = sp.APK.Package, sp.APK.Version, apkArch(sp.Name)
+		case sp.NPM != nil:
+			pkg.Name, pkg.Version = sp.NPM.Package, sp.NPM.Version
+		case sp.GoMod != nil:
+			pkg.Name, pkg.Version = sp.GoMod.Module, sp.GoMod.Version
+		case sp.Crate != nil:
+			pkg.Name, pkg.Version = sp.Crate.Package, sp.Crate.Version
+		case sp.Maven != nil:
+			pkg.Name, pkg.Version = sp.Maven.Group+":"+sp.Maven.Artifact, sp.Maven.Version
+		}
+		pkg.PURL = purlOf(sp, &pkg, opts.Distro)
+		for _, provider := range opts.Providers {
+			u, err := sp.URL(provider)
+			if err != nil {
+				logrus.WithError(err).Debugf("Skipping the provider %q for %s", provider, sp.Basename)
+				continue
+			}
+			if u.Scheme == "http" || u.Scheme == "https" {
+				pkg.DownloadLocation = u.Redacted()
+				break
+			}
+		}
+		res = append(res, pkg)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].FileName < res[j].FileName
+	})
+	return res
+}
+
+// apkArchs is the set of the apk architectures.
+var apkArchs = map[string]bool{
+	"x86_64":  true,
+	"x86":     true,
+	"aarch64": true,
+	"armv7":   true,
+	"armhf":   true,
+	"ppc64le": true,
+	"s390x":   true,
+	"riscv64": true,
+	"noarch":  true,
+}
+
+// apkArch returns the architecture of an apk file name such as "v3.16/main/x86_64/hello-2.12-r0.apk",
+// or an empty string if the directory is not named after an architecture.
+func apkArch(name string) string {
+	if arch := path.Base(path.Dir(name)); apkArchs[arch] {
+		return arch
+	}
+	return ""
+}
+
+// Write writes the SBOM of the file specs in the format.
+func Write(w io.Writer, fileSpecs map[string]*filespec.FileSpec, format Format, opts Opts) error {
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+	pkgs := Packages(fileSpecs, opts)
+	switch format {
+	case FormatSPDXJSON:
+		return writeSPDX(w, pkgs, opts)
+	case FormatCycloneDXJSON:
+		return writeCycloneDX(w, pkgs, opts)
+	default:
+		return fmt.Errorf("unknown SBOM format %q (expected one of %v)", format, Formats)
+	}
+}
+
+// toolName returns the name of the tool, with the version if known.
+func toolName(opts Opts) string {
+	if opts.ToolVersion == "" {
+		return "repro-get"
+	}
+	return "repro-get-" + strings.TrimPrefix(opts.ToolVersion, "v")
+}
+
+// contentDigest returns the SHA256 of the sums and the file names of the packages,
+// for deriving the identifiers of the SBOM documents from the content.
+func contentDigest(pkgs []Package) []byte {
+	h := sha256.New()
+	for _, pkg := range pkgs {
+		for _, sum := range pkg.Sums {
+			_, _ = io.WriteString(h, sum+"  "+pkg.FileName+"\n")
+		}
+	}
+	return h.Sum(nil)
+}
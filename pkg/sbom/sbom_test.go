@@ -0,0 +1,149 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+	"gotest.tools/v3/assert"
+)
+
+func testFileSpecs(t *testing.T) map[string]*filespec.FileSpec {
+	m, err := filespec.NewFromSums(map[string][]string{
+		"pool/main/h/hello/hello_2.10-2_amd64.deb": {
+			"35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+		},
+		"v3.16/main/x86_64/hello-2.12-r0.apk": {
+			"f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377",
+			"blake3:1111111111111111111111111111111111111111111111111111111111111111",
+		},
+		"dir/foo": {
+			"2222222222222222222222222222222222222222222222222222222222222222",
+		},
+	})
+	assert.NilError(t, err)
+	return m
+}
+
+func TestPackages(t *testing.T) {
+	pkgs := Packages(testFileSpecs(t), Opts{Providers: []string{"oci://ghcr.io/example/repo", "http://deb.debian.org/debian/{{.Name}}"}})
+	assert.DeepEqual(t, []Package{
+		{
+			Name:             "foo",
+			FileName:         "dir/foo",
+			Sums:             []string{"2222222222222222222222222222222222222222222222222222222222222222"},
+			DownloadLocation: "http://deb.debian.org/debian/dir/foo",
+		},
+		{
+			Name:             "hello",
+			Version:          "2.10-2",
+			Architecture:     "amd64",
+			FileName:         "pool/main/h/hello/hello_2.10-2_amd64.deb",
+			Sums:             []string{"35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"},
+			DownloadLocation: "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb",
+			PURL:             "pkg:deb/debian/hello@2.10-2?arch=amd64",
+		},
+		{
+			Name:             "hello",
+			Version:          "2.12-r0",
+			Architecture:     "x86_64",
+			FileName:         "v3.16/main/x86_64/hello-2.12-r0.apk",
+			Sums:             []string{"f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377", "blake3:1111111111111111111111111111111111111111111111111111111111111111"},
+			DownloadLocation: "http://deb.debian.org/debian/v3.16/main/x86_64/hello-2.12-r0.apk",
+			PURL:             "pkg:apk/alpine/hello@2.12-r0?arch=x86_64",
+		},
+	}, pkgs)
+}
+
+func TestWriteSPDX(t *testing.T) {
+	opts := Opts{
+		Name:        "SHA256SUMS-amd64",
+		Created:     time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC),
+		ToolVersion: "v0.3.0",
+	}
+	var b bytes.Buffer
+	assert.NilError(t, Write(&b, testFileSpecs(t), FormatSPDXJSON, opts))
+	var doc spdxDocument
+	assert.NilError(t, json.Unmarshal(b.Bytes(), &doc))
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	assert.Equal(t, "2022-11-01T00:00:00Z", doc.CreationInfo.Created)
+	assert.DeepEqual(t, []string{"Tool: repro-get-0.3.0"}, doc.CreationInfo.Creators)
+	assert.Equal(t, 3, len(doc.Packages))
+	assert.Equal(t, 3, len(doc.Relationships))
+
+	apk := doc.Packages[2]
+	assert.Equal(t, "SPDXRef-Package-v3.16-main-x86-64-hello-2.12-r0.apk", apk.SPDXID)
+	assert.Equal(t, "NOASSERTION", apk.DownloadLocation)
+	assert.DeepEqual(t, []spdxChecksum{
+		{Algorithm: "SHA256", ChecksumValue: "f702ef058e762d7208a9c83f6f6bbf02645533bfd615c54e8cdcce842cd57377"},
+		{Algorithm: "BLAKE3", ChecksumValue: "1111111111111111111111111111111111111111111111111111111111111111"},
+	}, apk.Checksums)
+	assert.DeepEqual(t, []spdxExtRef{
+		{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:apk/alpine/hello@2.12-r0?arch=x86_64"},
+	}, apk.ExternalRefs)
+	assert.Equal(t, apk.SPDXID, doc.Relationships[2].RelatedSPDXElement)
+
+	// The same input produces the same output
+	var b2 bytes.Buffer
+	assert.NilError(t, Write(&b2, testFileSpecs(t), FormatSPDXJSON, opts))
+	assert.Equal(t, b.String(), b2.String())
+
+	assert.ErrorContains(t, Write(&b, testFileSpecs(t), "foo", opts), "unknown SBOM format")
+}
+
+func TestPURL(t *testing.T) {
+	testCases := map[string]string{
+		"pool/main/f/foo/foo_1%3a2.0-1_amd64.deb":                          "pkg:deb/ubuntu/foo@1%3A2.0-1?arch=amd64",
+		"Packages/c/ca-certificates-2022.2.54-5.fc37.noarch.rpm":           "pkg:rpm/fedora/ca-certificates@2022.2.54-5.fc37?arch=noarch",
+		"@babel/core/-/core-7.19.3.tgz":                                    "pkg:npm/%40babel/core@7.19.3",
+		"github.com/!burnt!sushi/toml/@v/v1.2.0.zip":                       "pkg:golang/github.com/BurntSushi/toml@v1.2.0",
+		"serde/serde-1.0.147.crate":                                        "pkg:cargo/serde@1.0.147",
+		"org/apache/commons/commons-lang3/3.12.0/commons-lang3-3.12.0.jar": "pkg:maven/org.apache.commons/commons-lang3@3.12.0",
+		"dir/foo": "",
+	}
+	for name, expected := range testCases {
+		sp, err := filespec.New(name, "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc")
+		assert.NilError(t, err)
+		pkgs := Packages(map[string]*filespec.FileSpec{name: sp}, Opts{Distro: "ubuntu"})
+		assert.Equal(t, expected, pkgs[0].PURL, name)
+	}
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	opts := Opts{
+		Name:        "SHA256SUMS-amd64",
+		Providers:   []string{"http://deb.debian.org/debian/{{.Name}}"},
+		Created:     time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC),
+		ToolVersion: "v0.3.0",
+	}
+	var b bytes.Buffer
+	assert.NilError(t, Write(&b, testFileSpecs(t), FormatCycloneDXJSON, opts))
+	var bom cycloneDXBOM
+	assert.NilError(t, json.Unmarshal(b.Bytes(), &bom))
+	assert.Equal(t, "CycloneDX", bom.BOMFormat)
+	assert.Equal(t, "1.5", bom.SpecVersion)
+	assert.Assert(t, strings.HasPrefix(bom.SerialNumber, "urn:uuid:"), bom.SerialNumber)
+	assert.Equal(t, "2022-11-01T00:00:00Z", bom.Metadata.Timestamp)
+	assert.DeepEqual(t, []cycloneDXComponent{{Type: "application", Name: "repro-get", Version: "0.3.0"}}, bom.Metadata.Tools.Components)
+	assert.Equal(t, 3, len(bom.Components))
+	assert.DeepEqual(t, cycloneDXComponent{
+		Type:    "library",
+		BOMRef:  "pkg:deb/debian/hello@2.10-2?arch=amd64",
+		Name:    "hello",
+		Version: "2.10-2",
+		Hashes:  []cycloneDXHash{{Alg: "SHA-256", Content: "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"}},
+		PURL:    "pkg:deb/debian/hello@2.10-2?arch=amd64",
+		ExternalReferences: []cycloneDXExtRef{
+			{Type: "distribution", URL: "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb"},
+		},
+		Properties: []cycloneDXProperty{{Name: "repro-get:filename", Value: "pool/main/h/hello/hello_2.10-2_amd64.deb"}},
+	}, bom.Components[1])
+	assert.Equal(t, "dir/foo", bom.Components[0].BOMRef)
+
+	var b2 bytes.Buffer
+	assert.NilError(t, Write(&b2, testFileSpecs(t), FormatCycloneDXJSON, opts))
+	assert.Equal(t, b.String(), b2.String())
+}
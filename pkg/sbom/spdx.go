@@ -0,0 +1,140 @@
+package sbom
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+)
+
+// https://spdx.github.io/spdx-spec/v2.3/
+const (
+	spdxVersion       = "SPDX-2.3"
+	spdxDataLicense   = "CC0-1.0"
+	spdxDocumentID    = "SPDXRef-DOCUMENT"
+	spdxNoAssertion   = "NOASSERTION"
+	spdxNamespaceBase = "https://github.com/reproducible-containers/repro-get/spdx/"
+)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string         `json:"SPDXID"`
+	Name             string         `json:"name"`
+	VersionInfo      string         `json:"versionInfo,omitempty"`
+	PackageFileName  string         `json:"packageFileName"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum `json:"checksums"`
+	ExternalRefs     []spdxExtRef   `json:"externalRefs,omitempty"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	Comment          string         `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"` // "SHA256"
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExtRef struct {
+	ReferenceCategory string `json:"referenceCategory"` // "PACKAGE-MANAGER"
+	ReferenceType     string `json:"referenceType"`     // "purl"
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxIDRegexp matches the characters that cannot be used in SPDX identifiers.
+var spdxIDRegexp = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+func writeSPDX(w io.Writer, pkgs []Package, opts Opts) error {
+	doc := spdxDocument{
+		SPDXVersion: spdxVersion,
+		DataLicense: spdxDataLicense,
+		SPDXID:      spdxDocumentID,
+		Name:        opts.Name,
+		CreationInfo: spdxCreationInfo{
+			Created:  opts.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName(opts)},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	if doc.Name == "" {
+		doc.Name = "repro-get"
+	}
+	ids := make(map[string]bool)
+	for _, pkg := range pkgs {
+		baseID := "SPDXRef-Package-" + strings.Trim(spdxIDRegexp.ReplaceAllString(pkg.FileName, "-"), "-")
+		id := baseID
+		for i := 2; ids[id]; i++ {
+			id = baseID + "-" + strconv.Itoa(i)
+		}
+		ids[id] = true
+		p := spdxPackage{
+			SPDXID:           id,
+			Name:             pkg.Name,
+			VersionInfo:      pkg.Version,
+			PackageFileName:  pkg.FileName,
+			DownloadLocation: pkg.DownloadLocation,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+		}
+		if p.DownloadLocation == "" {
+			p.DownloadLocation = spdxNoAssertion
+		}
+		if pkg.PURL != "" {
+			p.ExternalRefs = []spdxExtRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: pkg.PURL}}
+		}
+		if pkg.Architecture != "" {
+			p.Comment = "Architecture: " + pkg.Architecture
+		}
+		for _, sum := range pkg.Sums {
+			d, err := sha256sums.ParseSum(sum)
+			if err != nil {
+				return err
+			}
+			p.Checksums = append(p.Checksums, spdxChecksum{
+				Algorithm:     strings.ToUpper(d.Algorithm().String()),
+				ChecksumValue: d.Encoded(),
+			})
+		}
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      spdxDocumentID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: id,
+		})
+	}
+	// The namespace is derived from the content, so that the same hash file always produces the same document
+	doc.DocumentNamespace = spdxNamespaceBase + spdxIDRegexp.ReplaceAllString(doc.Name, "-") + "-" + hex.EncodeToString(contentDigest(pkgs))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(doc)
+}
@@ -2,18 +2,153 @@ package sha256sums
 
 import (
 	"bufio"
+	_ "crypto/sha512" // for digest.SHA512
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"sort"
 	"strings"
 	"unicode"
+
+	"github.com/opencontainers/go-digest"
+	"lukechampine.com/blake3"
 )
 
+// blake3Size is the size of the BLAKE3 sums, in bytes.
+const blake3Size = 32
+
 var (
 	ErrEmptyLine   = errors.New("empty line")
 	ErrCommentLine = errors.New("comment line")
 )
 
+// DirectivePrefix is the prefix of the directive lines, such as "#repro-get:snapshot=20221101T000000Z".
+// The directive lines are ignored by the `sha256sum` command, as they are comment lines.
+const DirectivePrefix = "#repro-get:"
+
+// FormatDirective returns a directive line, without the trailing newline.
+func FormatDirective(key, value string) string {
+	return DirectivePrefix + key + "=" + value
+}
+
+// ParseDirective parses a directive line.
+// ok is false when the line is not a directive line.
+func ParseDirective(line string) (key, value string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, DirectivePrefix) {
+		return "", "", false
+	}
+	return strings.Cut(strings.TrimPrefix(trimmed, DirectivePrefix), "=")
+}
+
+// ParseDirectives parses the directive lines.
+func ParseDirectives(r io.Reader) (map[string]string, error) {
+	sc := bufio.NewScanner(r)
+	m := make(map[string]string)
+	for sc.Scan() {
+		if k, v, ok := ParseDirective(sc.Text()); ok {
+			m[k] = v
+		}
+	}
+	return m, sc.Err()
+}
+
+// SHA512Prefix is the prefix of the SHA512 sums.
+// The SHA256 sums are not prefixed, for the compatibility with the `sha256sum` command and the existing cache dirs.
+const SHA512Prefix = "sha512:"
+
+// BLAKE3 is the digest algorithm of the BLAKE3 sums.
+// Not supported by go-digest v1.0.0.
+const BLAKE3 digest.Algorithm = "blake3"
+
+// BLAKE3Prefix is the prefix of the BLAKE3 sums.
+// The prefix cannot be omitted in the hash files, as BLAKE3 sums have the same length as SHA256 sums.
+const BLAKE3Prefix = "blake3:"
+
+// ParseSum parses a sum such as "<SHA256>", "sha512:<SHA512>", and "blake3:<BLAKE3>" into the digest.
+func ParseSum(sum string) (digest.Digest, error) {
+	d := digest.NewDigestFromEncoded(digest.SHA256, sum)
+	if alg, encoded, ok := strings.Cut(sum, ":"); ok {
+		d = digest.NewDigestFromEncoded(digest.Algorithm(alg), encoded)
+	}
+	switch d.Algorithm() {
+	case digest.SHA256, digest.SHA512:
+		if err := d.Validate(); err != nil {
+			return "", err
+		}
+	case BLAKE3:
+		// digest.Digest.Validate does not support BLAKE3
+		if encoded := d.Encoded(); len(encoded) != 2*blake3Size || strings.Trim(encoded, "0123456789abcdef") != "" {
+			return "", digest.ErrDigestInvalidFormat
+		}
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", d.Algorithm())
+	}
+	return d, nil
+}
+
+// NewDigester returns a digester of the algorithm, including BLAKE3.
+func NewDigester(alg digest.Algorithm) digest.Digester {
+	if alg == BLAKE3 {
+		return &digester{alg: alg, hash: blake3.New(blake3Size, nil)}
+	}
+	return alg.Digester()
+}
+
+// FromReader computes the digest of the reader with the algorithm, including BLAKE3.
+func FromReader(alg digest.Algorithm, r io.Reader) (digest.Digest, error) {
+	d := NewDigester(alg)
+	if _, err := io.Copy(d.Hash(), r); err != nil {
+		return "", err
+	}
+	return d.Digest(), nil
+}
+
+type digester struct {
+	alg  digest.Algorithm
+	hash hash.Hash
+}
+
+func (d *digester) Hash() hash.Hash {
+	return d.hash
+}
+
+func (d *digester) Digest() digest.Digest {
+	return digest.NewDigest(d.alg, d.hash)
+}
+
+// FormatSum formats the digest as a sum.
+// The SHA256 sums are formatted without the prefix.
+func FormatSum(d digest.Digest) string {
+	if d.Algorithm() == digest.SHA256 {
+		return d.Encoded()
+	}
+	return d.String()
+}
+
+// normalizeSum normalizes the sum in a hash file line.
+// The length of an unprefixed sum determines the algorithm, so that SHA512SUMS files can be parsed too.
+// The "sha256:" and "sha512:" prefixes are accepted too, and "blake3:" is required for BLAKE3.
+func normalizeSum(s string) (string, error) {
+	if !strings.Contains(s, ":") {
+		switch len(s) {
+		case 64:
+		case 128:
+			s = SHA512Prefix + s
+		default:
+			return "", fmt.Errorf("invalid sha256 or sha512 sum %q", s)
+		}
+	}
+	d, err := ParseSum(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid sum %q: %w", s, err)
+	}
+	return FormatSum(d), nil
+}
+
+// ParseLine parses a line of a hash file.
+// The returned sum is formatted with FormatSum.
 func ParseLine(origLine string) (sum, filename string, err error) {
 	if strings.TrimSpace(origLine) == "" {
 		return "", "", ErrEmptyLine
@@ -26,9 +161,9 @@ func ParseLine(origLine string) (sum, filename string, err error) {
 	if len(sp) != 2 {
 		return "", "", fmt.Errorf("invalid line %q", origLine)
 	}
-	sum = sp[0]
-	if len(sum) != 64 {
-		return "", "", fmt.Errorf("invalid sha256 sum %q", sum)
+	sum, err = normalizeSum(sp[0])
+	if err != nil {
+		return "", "", err
 	}
 	filenameWithModePrefix := sp[1]
 	filename = filenameWithModePrefix
@@ -41,21 +176,74 @@ func ParseLine(origLine string) (sum, filename string, err error) {
 }
 
 func Parse(r io.Reader) (mapByFilename map[string]string, err error) {
-	sc := bufio.NewScanner(r)
 	mapByFilename = make(map[string]string)
+	err = parse(r, func(sum, filename string) {
+		mapByFilename[filename] = sum
+	})
+	return
+}
+
+// ParseAll parses a hash file like Parse, but returns all the sums of each file name,
+// for the hash files that contain multiple lines with different algorithms for the same file,
+// e.g., the concatenation of SHA256SUMS and SHA512SUMS.
+// The sums are sorted from the strongest one (see Stronger).
+// For the same algorithm, the last line wins, as in Parse.
+func ParseAll(r io.Reader) (map[string][]string, error) {
+	mapByFilename := make(map[string][]string)
+	err := parse(r, func(sum, filename string) {
+		mapByFilename[filename] = AddSum(mapByFilename[filename], sum)
+	})
+	return mapByFilename, err
+}
+
+// AddSum adds the sum to the sums, replacing the existing sum of the same algorithm.
+// The sums are kept sorted from the strongest one (see Stronger).
+func AddSum(sums []string, sum string) []string {
+	alg := algorithmOf(sum)
+	res := []string{sum}
+	for _, f := range sums {
+		if algorithmOf(f) != alg {
+			res = append(res, f)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return Stronger(res[i], res[j])
+	})
+	return res
+}
+
+// algorithmStrength is the order of the algorithms used for choosing the cache key from multiple sums.
+// SHA256 is preferred over BLAKE3, for the compatibility with the existing cache dirs.
+var algorithmStrength = map[digest.Algorithm]int{
+	BLAKE3:        1,
+	digest.SHA256: 2,
+	digest.SHA512: 3,
+}
+
+// Stronger returns true if the algorithm of the sum a is stronger than that of the sum b.
+func Stronger(a, b string) bool {
+	return algorithmStrength[algorithmOf(a)] > algorithmStrength[algorithmOf(b)]
+}
+
+func algorithmOf(sum string) digest.Algorithm {
+	if alg, _, ok := strings.Cut(sum, ":"); ok {
+		return digest.Algorithm(alg)
+	}
+	return digest.SHA256
+}
+
+func parse(r io.Reader, f func(sum, filename string)) error {
+	sc := bufio.NewScanner(r)
 	for i := 0; sc.Scan(); i++ {
 		line := sc.Text()
-		var sum, filename string
-		sum, filename, err = ParseLine(line)
+		sum, filename, err := ParseLine(line)
 		if err != nil {
 			if errors.Is(err, ErrEmptyLine) || errors.Is(err, ErrCommentLine) {
 				continue
 			}
-			err = fmt.Errorf("line %d: %w", i+1, err)
-			return
+			return fmt.Errorf("line %d: %w", i+1, err)
 		}
-		mapByFilename[filename] = sum
+		f(sum, filename)
 	}
-	err = sc.Err()
-	return
+	return sc.Err()
 }
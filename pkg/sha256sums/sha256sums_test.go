@@ -1,6 +1,8 @@
 package sha256sums
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -80,6 +82,38 @@ func TestParseLine(t *testing.T) {
 			sum:      "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
 			filename: "/ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj",
 		},
+		{
+			// SHA512SUMS
+			line:     "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e  pool/main/h/hello/hello_2.10-2_amd64.deb",
+			sum:      "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+			filename: "pool/main/h/hello/hello_2.10-2_amd64.deb",
+		},
+		{
+			// SHA512, with the prefix
+			line:     "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e  pool/main/h/hello/hello_2.10-2_amd64.deb",
+			sum:      "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+			filename: "pool/main/h/hello/hello_2.10-2_amd64.deb",
+		},
+		{
+			// SHA256, with the prefix
+			line:     "sha256:35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb",
+			sum:      "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+			filename: "pool/main/h/hello/hello_2.10-2_amd64.deb",
+		},
+		{
+			// BLAKE3
+			line:     "blake3:af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262  pool/main/h/hello/hello_2.10-2_amd64.deb",
+			sum:      "blake3:af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+			filename: "pool/main/h/hello/hello_2.10-2_amd64.deb",
+		},
+		{
+			line: "blake3:AF1349B9F5F9A1A6A0404DEA36DCC9499BCB25C9ADC112B7CC9A93CAE41F3262  pool/main/h/hello/hello_2.10-2_amd64.deb",
+			err:  "invalid",
+		},
+		{
+			line: "sha384:35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb",
+			err:  "unsupported digest algorithm",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -93,3 +127,82 @@ func TestParseLine(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDirectives(t *testing.T) {
+	const s = `#repro-get:snapshot=20221101T000000Z
+# foo
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+`
+	got, err := ParseDirectives(strings.NewReader(s))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"snapshot": "20221101T000000Z"}, got)
+	assert.Equal(t, "#repro-get:snapshot=20221101T000000Z", FormatDirective("snapshot", "20221101T000000Z"))
+
+	sums, err := Parse(strings.NewReader(s))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(sums))
+}
+
+func TestParseSum(t *testing.T) {
+	const sha256sum = "35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"
+	d, err := ParseSum(sha256sum)
+	assert.NilError(t, err)
+	assert.Equal(t, "sha256:"+sha256sum, d.String())
+	assert.Equal(t, sha256sum, FormatSum(d))
+
+	const sha512sum = "sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"
+	d, err = ParseSum(sha512sum)
+	assert.NilError(t, err)
+	assert.Equal(t, sha512sum, d.String())
+	assert.Equal(t, sha512sum, FormatSum(d))
+
+	for _, s := range []string{"", "foo", "sha512:" + sha256sum, "sha256:" + sha512sum[7:]} {
+		_, err = ParseSum(s)
+		assert.Assert(t, err != nil, s)
+	}
+}
+
+func TestNewDigesterBLAKE3(t *testing.T) {
+	d, err := FromReader(BLAKE3, strings.NewReader(""))
+	assert.NilError(t, err)
+	assert.Equal(t, "blake3:af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262", d.String())
+	parsed, err := ParseSum(d.String())
+	assert.NilError(t, err)
+	assert.Equal(t, d, parsed)
+	assert.Equal(t, d.String(), FormatSum(parsed))
+
+	// Test vectors from https://github.com/BLAKE3-team/BLAKE3/blob/master/test_vectors/test_vectors.json
+	vectors := map[int]string{
+		1:    "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213",
+		1024: "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7",
+	}
+	for n, expected := range vectors {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i % 251)
+		}
+		d, err := FromReader(BLAKE3, bytes.NewReader(b))
+		assert.NilError(t, err)
+		assert.Equal(t, "blake3:"+expected, d.String(), n)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	const s = `35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/h/hello/hello_2.10-2_amd64.deb
+blake3:af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262  pool/main/h/hello/hello_2.10-2_amd64.deb
+cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e  pool/main/h/hello/hello_2.10-2_amd64.deb
+e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  pool/main/f/foo/foo_1.0-1_amd64.deb
+35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc  pool/main/f/foo/foo_1.0-1_amd64.deb
+`
+	got, err := ParseAll(strings.NewReader(s))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string][]string{
+		"pool/main/h/hello/hello_2.10-2_amd64.deb": {
+			"sha512:cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+			"35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc",
+			"blake3:af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+		},
+		// The last line wins for the same algorithm
+		"pool/main/f/foo/foo_1.0-1_amd64.deb": {"35b1508eeee9c1dfba798c4c04304ef0f266990f936a51f165571edf53325cbc"},
+	}, got)
+}
@@ -0,0 +1,58 @@
+package torrentutil
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// encode writes v in the bencoding format (BEP 3).
+// v must be string, []byte, int, int64, []interface{}, or map[string]interface{}.
+func encode(w io.Writer, v interface{}) error {
+	var err error
+	switch v := v.(type) {
+	case string:
+		_, err = io.WriteString(w, strconv.Itoa(len(v))+":"+v)
+	case []byte:
+		if _, err = io.WriteString(w, strconv.Itoa(len(v))+":"); err == nil {
+			_, err = w.Write(v)
+		}
+	case int:
+		_, err = io.WriteString(w, "i"+strconv.Itoa(v)+"e")
+	case int64:
+		_, err = io.WriteString(w, "i"+strconv.FormatInt(v, 10)+"e")
+	case []interface{}:
+		if _, err = io.WriteString(w, "l"); err != nil {
+			return err
+		}
+		for _, e := range v {
+			if err = encode(w, e); err != nil {
+				return err
+			}
+		}
+		_, err = io.WriteString(w, "e")
+	case map[string]interface{}:
+		if _, err = io.WriteString(w, "d"); err != nil {
+			return err
+		}
+		// The keys must be sorted as raw strings
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err = encode(w, k); err != nil {
+				return err
+			}
+			if err = encode(w, v[k]); err != nil {
+				return err
+			}
+		}
+		_, err = io.WriteString(w, "e")
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return err
+}
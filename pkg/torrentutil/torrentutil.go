@@ -0,0 +1,184 @@
+// Package torrentutil provides utilities for BitTorrent.
+package torrentutil
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec // SHA1 is mandated by BEP 3
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// File is a file in the torrent.
+type File struct {
+	// Path is the slash-separated relative path in the torrent, e.g., "pool/main/h/hello/hello_2.10-2_amd64.deb".
+	Path string
+	// Local is the local path of the file content, e.g., the blob in the cache.
+	Local string
+}
+
+// Opts is the options for Create.
+type Opts struct {
+	// Name is the name of the torrent, used as the directory name of the files.
+	Name string
+	// PieceLength is the piece length in bytes. Automatically chosen when zero.
+	PieceLength int64
+	// Trackers are the tracker URLs. Optional, as the peers can be found with DHT.
+	Trackers []string
+	// WebSeeds are the URLs of the HTTP(S) servers that serve the files under the paths (BEP 19).
+	// e.g., "http://deb.debian.org/debian" for "http://deb.debian.org/debian/pool/main/h/hello/hello_2.10-2_amd64.deb".
+	WebSeeds []string
+	// Comment is an optional comment.
+	Comment string
+}
+
+const (
+	minPieceLength = 256 * 1024
+	maxPieceLength = 16 * 1024 * 1024
+	// targetPieces is the preferred maximum number of the pieces for choosing the piece length automatically
+	targetPieces = 2048
+)
+
+// PieceLength returns the piece length for the total size:
+// a power of two between 256 KiB and 16 MiB.
+func PieceLength(total int64) int64 {
+	l := int64(minPieceLength)
+	for l < maxPieceLength && total/l > targetPieces {
+		l *= 2
+	}
+	return l
+}
+
+// Create writes a multi-file torrent file (BEP 3), and returns the info hash in hex.
+func Create(w io.Writer, files []File, opts Opts) (string, error) {
+	if len(files) == 0 {
+		return "", errors.New("no file was specified")
+	}
+	if opts.Name == "" || strings.ContainsAny(opts.Name, "/\\") {
+		return "", fmt.Errorf("invalid name %q", opts.Name)
+	}
+	var total int64
+	fileEntries := make([]interface{}, len(files))
+	for i, f := range files {
+		p := path.Clean(f.Path)
+		if p != f.Path || path.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+			return "", fmt.Errorf("invalid path %q", f.Path)
+		}
+		st, err := os.Stat(f.Local)
+		if err != nil {
+			return "", err
+		}
+		if !st.Mode().IsRegular() {
+			return "", fmt.Errorf("not a regular file: %q", f.Local)
+		}
+		var pathList []interface{}
+		for _, e := range strings.Split(p, "/") {
+			pathList = append(pathList, e)
+		}
+		fileEntries[i] = map[string]interface{}{
+			"length": st.Size(),
+			"path":   pathList,
+		}
+		total += st.Size()
+	}
+	pieceLength := opts.PieceLength
+	if pieceLength <= 0 {
+		pieceLength = PieceLength(total)
+	}
+	pieces, err := hashPieces(files, pieceLength)
+	if err != nil {
+		return "", err
+	}
+	info := map[string]interface{}{
+		"name":         opts.Name,
+		"piece length": pieceLength,
+		"pieces":       pieces,
+		"files":        fileEntries,
+	}
+	var infoBuf bytes.Buffer
+	if err = encode(&infoBuf, info); err != nil {
+		return "", err
+	}
+	infoHash := sha1.Sum(infoBuf.Bytes()) //nolint:gosec // SHA1 is mandated by BEP 3
+
+	torrent := map[string]interface{}{
+		"info": info,
+	}
+	if len(opts.Trackers) > 0 {
+		torrent["announce"] = opts.Trackers[0]
+		var tier []interface{}
+		for _, f := range opts.Trackers {
+			tier = append(tier, f)
+		}
+		torrent["announce-list"] = []interface{}{tier}
+	}
+	if len(opts.WebSeeds) > 0 {
+		var urlList []interface{}
+		for _, f := range opts.WebSeeds {
+			u, err := url.Parse(f)
+			if err != nil {
+				return "", err
+			}
+			switch u.Scheme {
+			case "http", "https":
+			default:
+				return "", fmt.Errorf("expected http:// or https:// for the web seed, got %q", u.Redacted())
+			}
+			urlList = append(urlList, f)
+		}
+		torrent["url-list"] = urlList
+	}
+	if opts.Comment != "" {
+		torrent["comment"] = opts.Comment
+	}
+	torrent["created by"] = "repro-get"
+	if err = encode(w, torrent); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(infoHash[:]), nil
+}
+
+// hashPieces returns the concatenated SHA1 hashes of the pieces.
+// The pieces span across the file boundaries.
+func hashPieces(files []File, pieceLength int64) ([]byte, error) {
+	readers := make([]io.Reader, 0, len(files))
+	for _, f := range files {
+		r, err := os.Open(f.Local)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		readers = append(readers, r)
+	}
+	mr := io.MultiReader(readers...)
+	var pieces []byte
+	buf := make([]byte, pieceLength)
+	for {
+		n, err := io.ReadFull(mr, buf)
+		if n > 0 {
+			h := sha1.Sum(buf[:n]) //nolint:gosec // SHA1 is mandated by BEP 3
+			pieces = append(pieces, h[:]...)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return pieces, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// MagnetURI returns the magnet URI of the torrent.
+func MagnetURI(infoHash, name string, webSeeds []string) string {
+	q := url.Values{}
+	q.Set("dn", name)
+	for _, f := range webSeeds {
+		q.Add("ws", f)
+	}
+	return "magnet:?xt=urn:btih:" + infoHash + "&" + q.Encode()
+}
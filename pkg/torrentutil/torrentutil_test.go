@@ -0,0 +1,68 @@
+package torrentutil
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec // SHA1 is mandated by BEP 3
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NilError(t, encode(&buf, map[string]interface{}{
+		"spam": []interface{}{"a", int64(42)},
+		"foo":  "bar",
+	}))
+	assert.Equal(t, "d3:foo3:bar4:spaml1:ai42eee", buf.String())
+
+	assert.ErrorContains(t, encode(&buf, 4.2), "unsupported type")
+}
+
+func TestPieceLength(t *testing.T) {
+	assert.Equal(t, int64(256*1024), PieceLength(0))
+	assert.Equal(t, int64(256*1024), PieceLength(512*1024*1024))
+	assert.Equal(t, int64(1024*1024), PieceLength(2*1024*1024*1024))
+	assert.Equal(t, int64(16*1024*1024), PieceLength(1024*1024*1024*1024))
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	a, c := filepath.Join(dir, "a"), filepath.Join(dir, "c")
+	assert.NilError(t, os.WriteFile(a, []byte("hello"), 0644))
+	assert.NilError(t, os.WriteFile(c, []byte("world"), 0644))
+	files := []File{
+		{Path: "a", Local: a},
+		{Path: "b/c", Local: c},
+	}
+	var buf bytes.Buffer
+	infoHash, err := Create(&buf, files, Opts{
+		Name:        "repro-get",
+		PieceLength: 4,
+		WebSeeds:    []string{"http://example.com/debian"},
+	})
+	assert.NilError(t, err)
+
+	var pieces []byte
+	for _, s := range []string{"hell", "owor", "ld"} {
+		h := sha1.Sum([]byte(s)) //nolint:gosec // SHA1 is mandated by BEP 3
+		pieces = append(pieces, h[:]...)
+	}
+	info := "d5:filesld6:lengthi5e4:pathl1:aeed6:lengthi5e4:pathl1:b1:ceee4:name9:repro-get12:piece lengthi4e6:pieces60:" + string(pieces) + "e"
+	expectedInfoHash := sha1.Sum([]byte(info)) //nolint:gosec // SHA1 is mandated by BEP 3
+	assert.Equal(t, hex.EncodeToString(expectedInfoHash[:]), infoHash)
+	assert.Equal(t, "d10:created by9:repro-get4:info"+info+"8:url-listl25:http://example.com/debianee", buf.String())
+
+	_, err = Create(&buf, []File{{Path: "../a", Local: a}}, Opts{Name: "repro-get"})
+	assert.ErrorContains(t, err, "invalid path")
+	_, err = Create(&buf, files, Opts{Name: "repro-get", WebSeeds: []string{"ftp://example.com"}})
+	assert.ErrorContains(t, err, "expected http://")
+}
+
+func TestMagnetURI(t *testing.T) {
+	assert.Equal(t, "magnet:?xt=urn:btih:deadbeef&dn=repro-get&ws=http%3A%2F%2Fexample.com%2Fdebian",
+		MagnetURI("deadbeef", "repro-get", []string{"http://example.com/debian"}))
+}
@@ -0,0 +1,244 @@
+package tuf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+)
+
+// The JSON keys follow the TUF specification, not the convention of repro-get.
+
+// Role names.
+const (
+	RoleRoot      = "root"
+	RoleTimestamp = "timestamp"
+	RoleSnapshot  = "snapshot"
+	RoleTargets   = "targets"
+)
+
+// Envelope is the signed metadata.
+type Envelope struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []Signature     `json:"signatures"`
+}
+
+// Signature is a signature of the metadata.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"` // hex
+}
+
+// Key is a public key.
+type Key struct {
+	KeyType string `json:"keytype"` // "ed25519", "ecdsa", or "rsa"
+	Scheme  string `json:"scheme"`  // "ed25519", "ecdsa-sha2-nistp256", or "rsassa-pss-sha256"
+	KeyVal  KeyVal `json:"keyval"`
+}
+
+// KeyVal is the value of a public key.
+type KeyVal struct {
+	Public string `json:"public"` // hex for ed25519, PEM for ecdsa and rsa
+}
+
+// Role is the keys and the threshold of a role.
+type Role struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+// Common is the common fields of the metadata.
+type Common struct {
+	Type        string    `json:"_type"`
+	SpecVersion string    `json:"spec_version"`
+	Version     int64     `json:"version"`
+	Expires     time.Time `json:"expires"`
+}
+
+// Root is the root metadata ("root.json").
+type Root struct {
+	Common
+	ConsistentSnapshot bool             `json:"consistent_snapshot"`
+	Keys               map[string]*Key  `json:"keys"`
+	Roles              map[string]*Role `json:"roles"`
+}
+
+// MetaFile is the metadata file recorded in the timestamp and the snapshot metadata.
+type MetaFile struct {
+	Version int64             `json:"version"`
+	Length  int64             `json:"length,omitempty"`
+	Hashes  map[string]string `json:"hashes,omitempty"`
+}
+
+// Timestamp is the timestamp metadata ("timestamp.json").
+type Timestamp struct {
+	Common
+	Meta map[string]MetaFile `json:"meta"`
+}
+
+// Snapshot is the snapshot metadata ("snapshot.json"), which has the same fields as the timestamp metadata.
+type Snapshot = Timestamp
+
+// TargetFile is a target file.
+type TargetFile struct {
+	Length int64             `json:"length"`
+	Hashes map[string]string `json:"hashes"`
+	Custom json.RawMessage   `json:"custom,omitempty"`
+}
+
+// Targets is the targets metadata ("targets.json").
+// The delegations are not supported.
+type Targets struct {
+	Common
+	Targets     map[string]TargetFile `json:"targets"`
+	Delegations json.RawMessage       `json:"delegations,omitempty"`
+}
+
+// verify verifies the signature of msg.
+func (k *Key) verify(msg []byte, sig []byte) error {
+	switch k.KeyType {
+	case "ed25519":
+		pub, err := hex.DecodeString(k.KeyVal.Public)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return errors.New("invalid ed25519 public key")
+		}
+		if !ed25519.Verify(pub, msg, sig) {
+			return errors.New("invalid ed25519 signature")
+		}
+		return nil
+	case "ecdsa", "ecdsa-sha2-nistp256":
+		pub, err := parsePEMPublicKey(k.KeyVal.Public)
+		if err != nil {
+			return err
+		}
+		ecPub, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("expected an ECDSA public key, got %T", pub)
+		}
+		digest := sha256.Sum256(msg)
+		if !ecdsa.VerifyASN1(ecPub, digest[:], sig) {
+			return errors.New("invalid ecdsa signature")
+		}
+		return nil
+	case "rsa":
+		pub, err := parsePEMPublicKey(k.KeyVal.Public)
+		if err != nil {
+			return err
+		}
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("expected an RSA public key, got %T", pub)
+		}
+		digest := sha256.Sum256(msg)
+		return rsa.VerifyPSS(rsaPub, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	default:
+		return fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+func parsePEMPublicKey(s string) (crypto.PublicKey, error) {
+	blk, _ := pem.Decode([]byte(s))
+	if blk == nil {
+		return nil, errors.New("no PEM block was found in the public key")
+	}
+	return x509.ParsePKIXPublicKey(blk.Bytes)
+}
+
+// verifySignatures verifies that the metadata is signed by the threshold of the keys of the role,
+// and decodes the signed part into v. The type of the metadata has to be typ.
+func verifySignatures(b []byte, typ string, role *Role, keys map[string]*Key, v interface{}) error {
+	if role == nil || role.Threshold < 1 {
+		return fmt.Errorf("invalid %s role", typ)
+	}
+	var env Envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return err
+	}
+	msg, err := CanonicalJSON(env.Signed)
+	if err != nil {
+		return err
+	}
+	roleKeys := make(map[string]struct{}, len(role.KeyIDs))
+	for _, id := range role.KeyIDs {
+		roleKeys[id] = struct{}{}
+	}
+	verified := make(map[string]struct{})
+	for _, sig := range env.Signatures {
+		if _, ok := roleKeys[sig.KeyID]; !ok {
+			continue
+		}
+		k, ok := keys[sig.KeyID]
+		if !ok {
+			continue
+		}
+		sigBytes, err := hex.DecodeString(sig.Sig)
+		if err != nil {
+			continue
+		}
+		if err = k.verify(msg, sigBytes); err == nil {
+			verified[sig.KeyID] = struct{}{}
+		}
+	}
+	if len(verified) < role.Threshold {
+		return fmt.Errorf("the %s metadata is signed by %d valid keys, expected at least %d", typ, len(verified), role.Threshold)
+	}
+	if err = json.Unmarshal(env.Signed, v); err != nil {
+		return err
+	}
+	var common Common
+	if err = json.Unmarshal(env.Signed, &common); err != nil {
+		return err
+	}
+	if common.Type != typ {
+		return fmt.Errorf("expected the metadata type %q, got %q", typ, common.Type)
+	}
+	return nil
+}
+
+// unmarshalSigned decodes the signed part of the metadata into v, without verifying the signatures.
+func unmarshalSigned(b []byte, v interface{}) error {
+	var env Envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return err
+	}
+	return json.Unmarshal(env.Signed, v)
+}
+
+// verifyHashes verifies the length and the hashes of b. The length is not verified when it is zero.
+// At least one of the hashes has to be supported, when the hashes are specified.
+func verifyHashes(b []byte, length int64, hashes map[string]string) error {
+	if length != 0 && int64(len(b)) != length {
+		return fmt.Errorf("expected the length %d, got %d", length, len(b))
+	}
+	var supported int
+	for alg, expected := range hashes {
+		var h hash.Hash
+		switch alg {
+		case "sha256":
+			h = sha256.New()
+		case "sha512":
+			h = sha512.New()
+		default:
+			continue
+		}
+		h.Write(b)
+		if got := hex.EncodeToString(h.Sum(nil)); got != expected {
+			return fmt.Errorf("expected the %s hash %s, got %s", alg, expected, got)
+		}
+		supported++
+	}
+	if len(hashes) > 0 && supported == 0 {
+		return errors.New("no supported hash algorithm was found")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package tuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/reproducible-containers/repro-get/pkg/filespec"
+)
+
+// DefaultProviderConfigTarget is the default target name of ProviderConfig.
+const DefaultProviderConfigTarget = "providers.json"
+
+// ProviderConfig is the provider config distributed as a target of the TUF repository, in JSON.
+//
+// e.g.,
+//
+//	{
+//	  "Distros": {
+//	    "debian": {
+//	      "Providers": ["https://mirror.example.com/debian/{{.Name}}", "http://snapshot.debian.org/archive/debian/{{.Snapshot}}/{{.Name}}"],
+//	      "Keys": ["keys/mirror.example.com.asc"],
+//	      "Snapshot": "20221101T000000Z"
+//	    }
+//	  }
+//	}
+type ProviderConfig struct {
+	Distros map[string]DistroProviderConfig `json:"Distros"` // Keyed by the distro names, such as "debian"
+}
+
+// DistroProviderConfig is the provider config for a distro.
+type DistroProviderConfig struct {
+	Providers []string `json:"Providers,omitempty"` // Used unless --provider is specified
+	// Keys are the target names of the trusted keys (OpenPGP keyrings, or RSA public keys "*.rsa.pub" for Alpine),
+	// for verifying the repository metadata and the hash files.
+	Keys []string `json:"Keys,omitempty"`
+	// Snapshot is the snapshot timestamp such as "20221101T000000Z", for the hash files without "#repro-get:snapshot=...".
+	Snapshot string `json:"Snapshot,omitempty"`
+}
+
+// ParseProviderConfig parses the provider config.
+func ParseProviderConfig(b []byte) (*ProviderConfig, error) {
+	var cfg ProviderConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse the provider config: %w", err)
+	}
+	for name, d := range cfg.Distros {
+		if d.Snapshot != "" {
+			if err := filespec.ValidateSnapshot(d.Snapshot); err != nil {
+				return nil, fmt.Errorf("invalid snapshot for %q: %w", name, err)
+			}
+		}
+		for _, k := range d.Keys {
+			if path.IsAbs(k) || path.Clean(k) != k || strings.HasPrefix(k, "..") {
+				return nil, fmt.Errorf("invalid key target name %q for %q", k, name)
+			}
+		}
+	}
+	return &cfg, nil
+}
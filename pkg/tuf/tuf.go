@@ -0,0 +1,391 @@
+// Package tuf implements a minimal client of The Update Framework (TUF), https://theupdateframework.io/ ,
+// for distributing the provider config of repro-get (see ProviderConfig) via a TUF repository.
+//
+// The client follows the workflow of the TUF specification (version 1.0): the root metadata is updated
+// from the trusted root metadata, and the timestamp, the snapshot, and the targets metadata are verified
+// against the rollback attacks (the versions must not decrease) and the freeze attacks (the metadata must not be expired).
+// The trusted metadata are persisted in the local directory, so that the rollback attacks can be detected across the runs.
+// The delegations are not supported.
+//
+// The repository is laid out as "<URL>/<ROLE>.json" (or "<URL>/<VERSION>.<ROLE>.json"), and "<URL>/targets/<NAME>".
+package tuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxMetadataSize is the maximum size of a metadata file.
+const MaxMetadataSize = 4 * 1024 * 1024
+
+// MaxRootRotations is the maximum number of the root metadata versions to be fetched in an update.
+const MaxRootRotations = 256
+
+// Client is a TUF client.
+type Client struct {
+	urlOpener *urlopener.URLOpener
+	base      *url.URL
+	dir       string
+	now       func() time.Time
+
+	root    *Root
+	targets *Targets
+}
+
+// New instantiates a client for the repository URL, with the trusted metadata in the local dir.
+// When the local dir has no root metadata yet, initialRoot is trusted as the root metadata.
+// initialRoot may be nil when the local dir already has the root metadata.
+func New(urlOpener *urlopener.URLOpener, rawURL, dir string, initialRoot []byte) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		abs, err := filepath.Abs(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		u = &url.URL{Scheme: "file", Path: abs}
+	}
+	c := &Client{
+		urlOpener: urlOpener,
+		base:      u,
+		dir:       dir,
+		now:       time.Now,
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	b, err := c.readLocal(RoleRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		if len(initialRoot) == 0 {
+			return nil, fmt.Errorf("no trusted root metadata was found in %q", dir)
+		}
+		b = initialRoot
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The trusted root metadata is self-signed
+	var unverified, root Root
+	if err = unmarshalSigned(b, &unverified); err != nil {
+		return nil, fmt.Errorf("failed to parse the trusted root metadata: %w", err)
+	}
+	if err = verifySignatures(b, RoleRoot, unverified.Roles[RoleRoot], unverified.Keys, &root); err != nil {
+		return nil, fmt.Errorf("failed to verify the trusted root metadata: %w", err)
+	}
+	if err = c.writeLocal(RoleRoot, b); err != nil {
+		return nil, err
+	}
+	c.root = &root
+	return c, nil
+}
+
+// Root returns the trusted root metadata.
+func (c *Client) Root() *Root {
+	return c.root
+}
+
+// Update updates the root, the timestamp, the snapshot, and the targets metadata.
+func (c *Client) Update(ctx context.Context) error {
+	if err := c.updateRoot(ctx); err != nil {
+		return err
+	}
+	ts, err := c.updateTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	snap, err := c.updateSnapshot(ctx, ts)
+	if err != nil {
+		return err
+	}
+	return c.updateTargets(ctx, snap)
+}
+
+func (c *Client) updateRoot(ctx context.Context) error {
+	for i := 0; i < MaxRootRotations; i++ {
+		next := c.root.Version + 1
+		b, err := c.fetch(ctx, strconv.FormatInt(next, 10)+"."+RoleRoot+".json", MaxMetadataSize)
+		if err != nil {
+			if isNotFound(err) {
+				break
+			}
+			return err
+		}
+		// The new root has to be signed by the thresholds of both the trusted root and the new root
+		var newRoot Root
+		if err = verifySignatures(b, RoleRoot, c.root.Roles[RoleRoot], c.root.Keys, &newRoot); err != nil {
+			return fmt.Errorf("failed to verify the root metadata version %d with the trusted root: %w", next, err)
+		}
+		if err = verifySignatures(b, RoleRoot, newRoot.Roles[RoleRoot], newRoot.Keys, &newRoot); err != nil {
+			return fmt.Errorf("failed to verify the root metadata version %d with itself: %w", next, err)
+		}
+		if newRoot.Version != next {
+			return fmt.Errorf("expected the root metadata version %d, got %d", next, newRoot.Version)
+		}
+		for _, role := range []string{RoleTimestamp, RoleSnapshot} {
+			if !sameRole(c.root, &newRoot, role) {
+				// Recover from the fast-forward attacks, by discarding the metadata signed with the rotated keys
+				logrus.Debugf("The keys of the %s role were rotated in the root metadata version %d", role, next)
+				if err = c.removeLocal(RoleTimestamp, RoleSnapshot); err != nil {
+					return err
+				}
+			}
+		}
+		if err = c.writeLocal(RoleRoot, b); err != nil {
+			return err
+		}
+		c.root = &newRoot
+		logrus.Debugf("Updated the TUF root metadata to version %d", next)
+	}
+	return c.checkExpires(RoleRoot, c.root.Expires)
+}
+
+func sameRole(a, b *Root, role string) bool {
+	ra, rb := a.Roles[role], b.Roles[role]
+	if ra == nil || rb == nil || ra.Threshold != rb.Threshold || len(ra.KeyIDs) != len(rb.KeyIDs) {
+		return false
+	}
+	for i := range ra.KeyIDs {
+		if ra.KeyIDs[i] != rb.KeyIDs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Client) updateTimestamp(ctx context.Context) (*Timestamp, error) {
+	b, err := c.fetch(ctx, RoleTimestamp+".json", MaxMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	var ts Timestamp
+	if err = verifySignatures(b, RoleTimestamp, c.root.Roles[RoleTimestamp], c.root.Keys, &ts); err != nil {
+		return nil, err
+	}
+	if trusted, err := c.trustedTimestamp(RoleTimestamp); err != nil {
+		return nil, err
+	} else if trusted != nil {
+		if ts.Version < trusted.Version {
+			return nil, fmt.Errorf("rollback attack? the timestamp metadata version %d is older than the trusted version %d", ts.Version, trusted.Version)
+		}
+		if ts.Meta["snapshot.json"].Version < trusted.Meta["snapshot.json"].Version {
+			return nil, fmt.Errorf("rollback attack? the snapshot metadata version %d in the timestamp metadata is older than the trusted version %d",
+				ts.Meta["snapshot.json"].Version, trusted.Meta["snapshot.json"].Version)
+		}
+	}
+	if err = c.checkExpires(RoleTimestamp, ts.Expires); err != nil {
+		return nil, err
+	}
+	if _, ok := ts.Meta["snapshot.json"]; !ok {
+		return nil, errors.New("the timestamp metadata does not contain snapshot.json")
+	}
+	return &ts, c.writeLocal(RoleTimestamp, b)
+}
+
+func (c *Client) updateSnapshot(ctx context.Context, ts *Timestamp) (*Snapshot, error) {
+	meta := ts.Meta["snapshot.json"]
+	b, err := c.fetch(ctx, c.metadataFileName(RoleSnapshot, meta.Version), maxSize(meta.Length))
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyHashes(b, meta.Length, meta.Hashes); err != nil {
+		return nil, fmt.Errorf("failed to verify the snapshot metadata: %w", err)
+	}
+	var snap Snapshot
+	if err = verifySignatures(b, RoleSnapshot, c.root.Roles[RoleSnapshot], c.root.Keys, &snap); err != nil {
+		return nil, err
+	}
+	if snap.Version != meta.Version {
+		return nil, fmt.Errorf("expected the snapshot metadata version %d, got %d", meta.Version, snap.Version)
+	}
+	if trusted, err := c.trustedTimestamp(RoleSnapshot); err != nil {
+		return nil, err
+	} else if trusted != nil {
+		if snap.Version < trusted.Version {
+			return nil, fmt.Errorf("rollback attack? the snapshot metadata version %d is older than the trusted version %d", snap.Version, trusted.Version)
+		}
+		for name, trustedMeta := range trusted.Meta {
+			m, ok := snap.Meta[name]
+			if !ok {
+				return nil, fmt.Errorf("rollback attack? the snapshot metadata does not contain %q", name)
+			}
+			if m.Version < trustedMeta.Version {
+				return nil, fmt.Errorf("rollback attack? the version %d of %q is older than the trusted version %d", m.Version, name, trustedMeta.Version)
+			}
+		}
+	}
+	if err = c.checkExpires(RoleSnapshot, snap.Expires); err != nil {
+		return nil, err
+	}
+	if _, ok := snap.Meta["targets.json"]; !ok {
+		return nil, errors.New("the snapshot metadata does not contain targets.json")
+	}
+	return &snap, c.writeLocal(RoleSnapshot, b)
+}
+
+func (c *Client) updateTargets(ctx context.Context, snap *Snapshot) error {
+	meta := snap.Meta["targets.json"]
+	b, err := c.fetch(ctx, c.metadataFileName(RoleTargets, meta.Version), maxSize(meta.Length))
+	if err != nil {
+		return err
+	}
+	if err = verifyHashes(b, meta.Length, meta.Hashes); err != nil {
+		return fmt.Errorf("failed to verify the targets metadata: %w", err)
+	}
+	var targets Targets
+	if err = verifySignatures(b, RoleTargets, c.root.Roles[RoleTargets], c.root.Keys, &targets); err != nil {
+		return err
+	}
+	if targets.Version != meta.Version {
+		return fmt.Errorf("expected the targets metadata version %d, got %d", meta.Version, targets.Version)
+	}
+	if err = c.checkExpires(RoleTargets, targets.Expires); err != nil {
+		return err
+	}
+	if err = c.writeLocal(RoleTargets, b); err != nil {
+		return err
+	}
+	c.targets = &targets
+	return nil
+}
+
+// Target downloads the target file, and verifies the length and the hashes.
+// Update has to be called in advance.
+func (c *Client) Target(ctx context.Context, name string) ([]byte, error) {
+	if c.targets == nil {
+		return nil, errors.New("the targets metadata is not loaded yet")
+	}
+	tf, ok := c.targets.Targets[name]
+	if !ok {
+		return nil, fmt.Errorf("target %q was not found in the TUF repository", name)
+	}
+	if path.IsAbs(name) || path.Clean(name) != name || strings.HasPrefix(name, "..") {
+		return nil, fmt.Errorf("invalid target name %q", name)
+	}
+	if len(tf.Hashes) == 0 {
+		return nil, fmt.Errorf("target %q has no hash", name)
+	}
+	p := name
+	if c.root.ConsistentSnapshot {
+		// "<DIR>/<HASH>.<BASENAME>"
+		h, ok := tf.Hashes["sha256"]
+		if !ok {
+			h, ok = tf.Hashes["sha512"]
+		}
+		if !ok {
+			return nil, fmt.Errorf("target %q has no supported hash", name)
+		}
+		p = path.Join(path.Dir(name), h+"."+path.Base(name))
+	}
+	b, err := c.fetch(ctx, "targets/"+p, maxSize(tf.Length))
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyHashes(b, tf.Length, tf.Hashes); err != nil {
+		return nil, fmt.Errorf("failed to verify target %q: %w", name, err)
+	}
+	return b, nil
+}
+
+func (c *Client) metadataFileName(role string, version int64) string {
+	if c.root.ConsistentSnapshot {
+		return strconv.FormatInt(version, 10) + "." + role + ".json"
+	}
+	return role + ".json"
+}
+
+func (c *Client) checkExpires(role string, expires time.Time) error {
+	if !c.now().Before(expires) {
+		return fmt.Errorf("freeze attack? the %s metadata expired at %s", role, expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// trustedTimestamp returns the trusted timestamp or snapshot metadata in the local dir, or nil.
+// The metadata that cannot be verified with the current root are ignored.
+func (c *Client) trustedTimestamp(role string) (*Timestamp, error) {
+	b, err := c.readLocal(role)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ts Timestamp
+	if err = verifySignatures(b, role, c.root.Roles[role], c.root.Keys, &ts); err != nil {
+		logrus.WithError(err).Debugf("Ignoring the trusted %s metadata", role)
+		return nil, nil
+	}
+	return &ts, nil
+}
+
+func (c *Client) fetch(ctx context.Context, name string, limit int64) ([]byte, error) {
+	u := *c.base
+	u.Path = path.Join(u.Path, name)
+	logrus.Debugf("Fetching %q", u.Redacted())
+	r, _, err := c.urlOpener.Open(ctx, &u, "")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", u.Redacted(), err)
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%q exceeds the limit of %d bytes", u.Redacted(), limit)
+	}
+	return b, nil
+}
+
+func maxSize(length int64) int64 {
+	if length > 0 {
+		return length
+	}
+	return MaxMetadataSize
+}
+
+func isNotFound(err error) bool {
+	var statusErr *urlopener.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusForbidden
+	}
+	return errors.Is(err, os.ErrNotExist)
+}
+
+func (c *Client) readLocal(role string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(c.dir, role+".json"))
+}
+
+func (c *Client) writeLocal(role string, b []byte) error {
+	f := filepath.Join(c.dir, role+".json")
+	tmp := f + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f)
+}
+
+func (c *Client) removeLocal(roles ...string) error {
+	for _, role := range roles {
+		if err := os.Remove(filepath.Join(c.dir, role+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
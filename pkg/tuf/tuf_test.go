@@ -0,0 +1,291 @@
+package tuf
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/reproducible-containers/repro-get/pkg/urlopener"
+	"gotest.tools/v3/assert"
+)
+
+type testKey struct {
+	id   string
+	key  *Key
+	priv ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T) *testKey {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	k := &Key{KeyType: "ed25519", Scheme: "ed25519", KeyVal: KeyVal{Public: hex.EncodeToString(pub)}}
+	b, err := json.Marshal(k)
+	assert.NilError(t, err)
+	b, err = CanonicalJSON(b)
+	assert.NilError(t, err)
+	digest := sha256.Sum256(b)
+	return &testKey{id: hex.EncodeToString(digest[:]), key: k, priv: priv}
+}
+
+func sign(t *testing.T, signed interface{}, keys ...*testKey) []byte {
+	b, err := json.Marshal(signed)
+	assert.NilError(t, err)
+	msg, err := CanonicalJSON(b)
+	assert.NilError(t, err)
+	env := Envelope{Signed: b}
+	for _, k := range keys {
+		env.Signatures = append(env.Signatures, Signature{KeyID: k.id, Sig: hex.EncodeToString(ed25519.Sign(k.priv, msg))})
+	}
+	b, err = json.Marshal(env)
+	assert.NilError(t, err)
+	return b
+}
+
+func testMetaFile(b []byte, version int64) MetaFile {
+	digest := sha256.Sum256(b)
+	return MetaFile{Version: version, Length: int64(len(b)), Hashes: map[string]string{"sha256": hex.EncodeToString(digest[:])}}
+}
+
+// testRepo is a TUF repository in a local directory.
+type testRepo struct {
+	t       *testing.T
+	dir     string
+	keys    map[string]*testKey // keyed by the role names
+	root    Root
+	expires time.Time
+	version int64
+}
+
+func newTestRepo(t *testing.T, consistentSnapshot bool) *testRepo {
+	r := &testRepo{
+		t:       t,
+		dir:     t.TempDir(),
+		keys:    make(map[string]*testKey),
+		expires: time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second),
+	}
+	r.root.Type = RoleRoot
+	r.root.SpecVersion = "1.0.0"
+	r.root.Version = 1
+	r.root.Expires = r.expires
+	r.root.ConsistentSnapshot = consistentSnapshot
+	r.root.Keys = make(map[string]*Key)
+	r.root.Roles = make(map[string]*Role)
+	for _, role := range []string{RoleRoot, RoleTimestamp, RoleSnapshot, RoleTargets} {
+		k := newTestKey(t)
+		r.keys[role] = k
+		r.root.Keys[k.id] = k.key
+		r.root.Roles[role] = &Role{KeyIDs: []string{k.id}, Threshold: 1}
+	}
+	return r
+}
+
+func (r *testRepo) rootJSON(keys ...*testKey) []byte {
+	if len(keys) == 0 {
+		keys = []*testKey{r.keys[RoleRoot]}
+	}
+	return sign(r.t, r.root, keys...)
+}
+
+func (r *testRepo) writeFile(name string, b []byte) {
+	f := filepath.Join(r.dir, filepath.FromSlash(name))
+	assert.NilError(r.t, os.MkdirAll(filepath.Dir(f), 0755))
+	assert.NilError(r.t, os.WriteFile(f, b, 0644))
+}
+
+func (r *testRepo) metadataFileName(role string, version int64) string {
+	if r.root.ConsistentSnapshot {
+		return strconv.FormatInt(version, 10) + "." + role + ".json"
+	}
+	return role + ".json"
+}
+
+// publish publishes the targets with the new versions of the timestamp, the snapshot, and the targets metadata.
+func (r *testRepo) publish(targetFiles map[string][]byte) {
+	r.version++
+	var targets Targets
+	targets.Type = RoleTargets
+	targets.SpecVersion = "1.0.0"
+	targets.Version = r.version
+	targets.Expires = r.expires
+	targets.Targets = make(map[string]TargetFile)
+	for name, b := range targetFiles {
+		mf := testMetaFile(b, 0)
+		targets.Targets[name] = TargetFile{Length: mf.Length, Hashes: mf.Hashes}
+		p := name
+		if r.root.ConsistentSnapshot {
+			p = filepath.Join(filepath.Dir(name), mf.Hashes["sha256"]+"."+filepath.Base(name))
+		}
+		r.writeFile("targets/"+p, b)
+	}
+	targetsJSON := sign(r.t, targets, r.keys[RoleTargets])
+	r.writeFile(r.metadataFileName(RoleTargets, r.version), targetsJSON)
+
+	var snap Snapshot
+	snap.Type = RoleSnapshot
+	snap.SpecVersion = "1.0.0"
+	snap.Version = r.version
+	snap.Expires = r.expires
+	snap.Meta = map[string]MetaFile{"targets.json": testMetaFile(targetsJSON, r.version)}
+	snapJSON := sign(r.t, snap, r.keys[RoleSnapshot])
+	r.writeFile(r.metadataFileName(RoleSnapshot, r.version), snapJSON)
+
+	var ts Timestamp
+	ts.Type = RoleTimestamp
+	ts.SpecVersion = "1.0.0"
+	ts.Version = r.version
+	ts.Expires = r.expires
+	ts.Meta = map[string]MetaFile{"snapshot.json": testMetaFile(snapJSON, r.version)}
+	r.writeFile("timestamp.json", sign(r.t, ts, r.keys[RoleTimestamp]))
+}
+
+func TestClient(t *testing.T) {
+	for _, consistentSnapshot := range []bool{false, true} {
+		t.Run("consistentSnapshot="+strconv.FormatBool(consistentSnapshot), func(t *testing.T) {
+			ctx := context.Background()
+			r := newTestRepo(t, consistentSnapshot)
+			r.publish(map[string][]byte{"providers.json": []byte("v1"), "keys/foo.asc": []byte("foo")})
+			dir := t.TempDir()
+
+			_, err := New(urlopener.New(), r.dir, dir, nil)
+			assert.ErrorContains(t, err, "no trusted root metadata")
+
+			c, err := New(urlopener.New(), r.dir, dir, r.rootJSON())
+			assert.NilError(t, err)
+			_, err = c.Target(ctx, "providers.json")
+			assert.ErrorContains(t, err, "not loaded yet")
+			assert.NilError(t, c.Update(ctx))
+			b, err := c.Target(ctx, "providers.json")
+			assert.NilError(t, err)
+			assert.Equal(t, "v1", string(b))
+			b, err = c.Target(ctx, "keys/foo.asc")
+			assert.NilError(t, err)
+			assert.Equal(t, "foo", string(b))
+			_, err = c.Target(ctx, "nonexistent")
+			assert.ErrorContains(t, err, "not found")
+
+			old, err := os.ReadFile(filepath.Join(r.dir, "timestamp.json"))
+			assert.NilError(t, err)
+			r.publish(map[string][]byte{"providers.json": []byte("v2")})
+
+			// The local dir already has the trusted root metadata
+			c, err = New(urlopener.New(), r.dir, dir, nil)
+			assert.NilError(t, err)
+			assert.NilError(t, c.Update(ctx))
+			b, err = c.Target(ctx, "providers.json")
+			assert.NilError(t, err)
+			assert.Equal(t, "v2", string(b))
+
+			// Rollback
+			r.writeFile("timestamp.json", old)
+			c, err = New(urlopener.New(), r.dir, dir, nil)
+			assert.NilError(t, err)
+			assert.ErrorContains(t, c.Update(ctx), "rollback attack?")
+		})
+	}
+}
+
+func TestClientFreeze(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t, false)
+	r.publish(map[string][]byte{"providers.json": []byte("v1")})
+	c, err := New(urlopener.New(), r.dir, t.TempDir(), r.rootJSON())
+	assert.NilError(t, err)
+	c.now = func() time.Time {
+		return r.expires.Add(time.Hour)
+	}
+	assert.ErrorContains(t, c.Update(ctx), "freeze attack?")
+}
+
+func TestClientRootRotation(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t, false)
+	initialRoot := r.rootJSON()
+	oldRootKey, oldTimestampKey := r.keys[RoleRoot], r.keys[RoleTimestamp]
+
+	// Rotate the root key and the timestamp key
+	r.root.Version = 2
+	for _, role := range []string{RoleRoot, RoleTimestamp} {
+		k := newTestKey(t)
+		r.keys[role] = k
+		r.root.Keys[k.id] = k.key
+		r.root.Roles[role] = &Role{KeyIDs: []string{k.id}, Threshold: 1}
+	}
+	r.writeFile("2.root.json", r.rootJSON(oldRootKey, r.keys[RoleRoot]))
+	r.publish(map[string][]byte{"providers.json": []byte("v1")})
+
+	dir := t.TempDir()
+	c, err := New(urlopener.New(), r.dir, dir, initialRoot)
+	assert.NilError(t, err)
+	assert.NilError(t, c.Update(ctx))
+	assert.Equal(t, int64(2), c.Root().Version)
+	b, err := c.Target(ctx, "providers.json")
+	assert.NilError(t, err)
+	assert.Equal(t, "v1", string(b))
+
+	// The timestamp signed with the rotated key is no longer trusted
+	r.keys[RoleTimestamp] = oldTimestampKey
+	r.publish(map[string][]byte{"providers.json": []byte("v2")})
+	assert.ErrorContains(t, c.Update(ctx), "signed by 0 valid keys")
+
+	// The new root has to be signed by the current root key
+	r.root.Version = 3
+	r.writeFile("3.root.json", r.rootJSON(newTestKey(t)))
+	c, err = New(urlopener.New(), r.dir, dir, nil)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, c.Update(ctx), "version 3 with the trusted root")
+}
+
+func TestClientThreshold(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t, false)
+	k := newTestKey(t)
+	r.root.Keys[k.id] = k.key
+	r.root.Roles[RoleTargets].KeyIDs = append(r.root.Roles[RoleTargets].KeyIDs, k.id)
+	r.root.Roles[RoleTargets].Threshold = 2
+	r.publish(map[string][]byte{"providers.json": []byte("v1")})
+	c, err := New(urlopener.New(), r.dir, t.TempDir(), r.rootJSON())
+	assert.NilError(t, err)
+	assert.ErrorContains(t, c.Update(ctx), "signed by 1 valid keys, expected at least 2")
+}
+
+func TestClientTamperedTarget(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t, false)
+	r.publish(map[string][]byte{"providers.json": []byte("v1")})
+	r.writeFile("targets/providers.json", []byte("v2"))
+	c, err := New(urlopener.New(), r.dir, t.TempDir(), r.rootJSON())
+	assert.NilError(t, err)
+	assert.NilError(t, c.Update(ctx))
+	_, err = c.Target(ctx, "providers.json")
+	assert.ErrorContains(t, err, "expected the sha256 hash")
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	b, err := CanonicalJSON([]byte(`{"b": [1, true, null], "a": "\"é\n"}`))
+	assert.NilError(t, err)
+	assert.Equal(t, "{\"a\":\"\\\"é\n\",\"b\":[1,true,null]}", string(b))
+
+	_, err = CanonicalJSON([]byte(`{"a": 1.5}`))
+	assert.ErrorContains(t, err, "floating point")
+}
+
+func TestParseProviderConfig(t *testing.T) {
+	cfg, err := ParseProviderConfig([]byte(`{"Distros": {"debian": {"Providers": ["https://mirror.example.com/debian/{{.Name}}"], "Keys": ["keys/foo.asc"], "Snapshot": "20221101T000000Z"}}}`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"https://mirror.example.com/debian/{{.Name}}"}, cfg.Distros["debian"].Providers)
+	assert.DeepEqual(t, []string{"keys/foo.asc"}, cfg.Distros["debian"].Keys)
+	assert.Equal(t, "20221101T000000Z", cfg.Distros["debian"].Snapshot)
+
+	_, err = ParseProviderConfig([]byte(`{"Distros": {"debian": {"Snapshot": "2022"}}}`))
+	assert.ErrorContains(t, err, "invalid snapshot")
+	_, err = ParseProviderConfig([]byte(`{"Distros": {"debian": {"Keys": ["../foo.asc"]}}}`))
+	assert.ErrorContains(t, err, "invalid key target name")
+}
@@ -0,0 +1,116 @@
+package urlopener
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseHostCredential(t *testing.T) {
+	hc, err := ParseHostCredential("Mirror.example.com=foo:bar:baz")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, HostCredential{Host: "mirror.example.com", Credential: Credential{Username: "foo", Password: "bar:baz"}}, *hc)
+
+	hc, err = ParseHostCredential("mirror.example.com=bearer:deadbeef")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, HostCredential{Host: "mirror.example.com", Credential: Credential{Token: "deadbeef"}}, *hc)
+
+	_, err = ParseHostCredential("foo:bar")
+	assert.ErrorContains(t, err, "expected HOST=")
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	assert.NilError(t, os.WriteFile(netrc, []byte(`
+default login anonymous password guest
+machine esm.ubuntu.com/apps/ubuntu/ login bearer password apps
+machine esm.ubuntu.com login bearer
+  password infra
+machine mirror.example.com:8443 login foo password bar
+`), 0600))
+	src, err := NetrcCredentials(netrc)
+	assert.NilError(t, err)
+	testCases := []struct {
+		url      string
+		expected Credential
+	}{
+		{"https://esm.ubuntu.com/apps/ubuntu/pool/foo.deb", Credential{Username: "bearer", Password: "apps"}},
+		{"https://esm.ubuntu.com/infra/ubuntu/pool/foo.deb", Credential{Username: "bearer", Password: "infra"}},
+		{"https://mirror.example.com:8443/foo", Credential{Username: "foo", Password: "bar"}},
+		{"https://mirror.example.com/foo", Credential{Username: "anonymous", Password: "guest"}},
+	}
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		assert.NilError(t, err)
+		cred, err := src(context.TODO(), u)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, tc.expected, *cred)
+	}
+}
+
+func TestHelperCredentials(t *testing.T) {
+	helper := filepath.Join(t.TempDir(), "helper")
+	assert.NilError(t, os.WriteFile(helper, []byte(`#!/bin/sh
+set -eu
+[ "$1" = get ]
+case "$(cat)" in
+https://mirror.example.com) echo '{"Username":"foo","Secret":"bar"}' ;;
+https://registry.example.com) echo '{"Secret":"deadbeef"}' ;;
+*) exit 1 ;;
+esac
+`), 0755))
+	src := HelperCredentials(helper)
+	testCases := []struct {
+		url      string
+		expected *Credential
+	}{
+		{"https://mirror.example.com/foo", &Credential{Username: "foo", Password: "bar"}},
+		{"https://registry.example.com/foo", &Credential{Token: "deadbeef"}},
+		{"https://example.com/foo", nil},
+	}
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		assert.NilError(t, err)
+		cred, err := src(context.TODO(), u)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, tc.expected, cred)
+	}
+}
+
+func TestOpenWithCredential(t *testing.T) {
+	var otherAuth string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("foo"))
+	}))
+	defer other.Close()
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		http.Redirect(w, r, other.URL+"/foo", http.StatusFound)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/foo")
+	assert.NilError(t, err)
+
+	o := New()
+	o.SetCredentialSources(StaticCredentials([]HostCredential{
+		{Host: u.Host, Credential: Credential{Token: "deadbeef"}},
+	}))
+	r, _, err := o.Open(context.TODO(), u, "")
+	assert.NilError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, "foo", string(b))
+	assert.Equal(t, "Bearer deadbeef", auth)
+	// Not sent to the redirected host
+	assert.Equal(t, "", otherAuth)
+}
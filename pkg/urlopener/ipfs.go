@@ -0,0 +1,52 @@
+package urlopener
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultIPFSGateway is the default IPFS gateway for the "ipfs://" URLs (the local Kubo daemon).
+const DefaultIPFSGateway = "http://127.0.0.1:8080"
+
+// SetIPFSGateway sets the HTTP(S) gateway for the "ipfs://<CID>/<PATH>" URLs,
+// which are fetched from "<GATEWAY>/ipfs/<CID>/<PATH>".
+func (o *URLOpener) SetIPFSGateway(gateway string) error {
+	u, err := url.Parse(gateway)
+	if err != nil {
+		return fmt.Errorf("failed to parse the IPFS gateway %q: %w", gateway, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return fmt.Errorf("expected http:// or https:// for the IPFS gateway, got %q", gateway)
+	}
+	if u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid IPFS gateway %q", gateway)
+	}
+	o.mu.Lock()
+	o.ipfsGateway = u
+	o.mu.Unlock()
+	return nil
+}
+
+// ipfsGatewayURL converts "ipfs://<CID>/<PATH>" into "<GATEWAY>/ipfs/<CID>/<PATH>".
+func (o *URLOpener) ipfsGatewayURL(u *url.URL) (*url.URL, error) {
+	if u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid URL %q", u.Redacted())
+	}
+	o.mu.Lock()
+	gw := o.ipfsGateway
+	o.mu.Unlock()
+	if gw == nil {
+		var err error
+		if gw, err = url.Parse(DefaultIPFSGateway); err != nil {
+			return nil, err
+		}
+	}
+	res := *gw
+	res.Path = path.Join(strings.TrimSuffix(gw.Path, "/"), "/ipfs", u.Host, u.Path)
+	res.RawPath = ""
+	return &res, nil
+}
@@ -0,0 +1,48 @@
+package urlopener
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestIPFSGateway(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = io.WriteString(w, "content")
+	}))
+	t.Cleanup(srv.Close)
+
+	o := New()
+	assert.NilError(t, o.SetIPFSGateway(srv.URL+"/prefix/"))
+	for _, s := range []string{
+		"ipfs://QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj",
+		"ipfs://QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj/hello.deb",
+	} {
+		u, err := url.Parse(s)
+		assert.NilError(t, err)
+		r, _, err := o.Open(context.TODO(), u, "")
+		assert.NilError(t, err)
+		b, err := io.ReadAll(r)
+		r.Close()
+		assert.NilError(t, err)
+		assert.Equal(t, "content", string(b))
+	}
+	assert.DeepEqual(t, []string{
+		"/prefix/ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj",
+		"/prefix/ipfs/QmRY19HEWeTJtRC6vAdz7rDfX3PjSMgXmd1KYi9guAACUj/hello.deb",
+	}, paths)
+
+	u, err := url.Parse("ipfs:///foo")
+	assert.NilError(t, err)
+	_, _, err = o.Open(context.TODO(), u, "")
+	assert.ErrorContains(t, err, "invalid URL")
+
+	assert.ErrorContains(t, o.SetIPFSGateway("ftp://127.0.0.1"), "expected http://")
+}
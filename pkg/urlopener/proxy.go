@@ -0,0 +1,84 @@
+package urlopener
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Proxy is a proxy rule for the HTTP(S) URLs and the OCI registries.
+type Proxy struct {
+	// Host is the host name pattern, such as "mirror.example.com", "mirror.example.com:8080", "*.example.com", and "*".
+	// Empty matches all the hosts.
+	Host string
+	// URL is the proxy URL, such as "http://proxy.example.com:3128" and "socks5://proxy.example.com:1080".
+	// Nil for connecting directly.
+	URL *url.URL
+}
+
+// ParseProxy parses a proxy rule string such as "socks5://proxy.example.com:1080",
+// "*.example.com=http://proxy.example.com:3128", and "mirror.example.com=direct".
+func ParseProxy(s string) (*Proxy, error) {
+	var p Proxy
+	rawURL := s
+	if host, v, ok := strings.Cut(s, "="); ok {
+		p.Host, rawURL = strings.ToLower(host), v
+	}
+	if rawURL == "direct" {
+		return &p, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the proxy URL in %q: %w", s, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("expected http://, https://, socks5://, or \"direct\", got %q", rawURL)
+	}
+	p.URL = u
+	return &p, nil
+}
+
+// Matches returns true if the host of u matches p.Host.
+func (p *Proxy) Matches(u *url.URL) bool {
+	return hostMatches(p.Host, u)
+}
+
+// hostMatches returns true if the host of u matches the pattern,
+// such as "mirror.example.com", "mirror.example.com:8080", "*.example.com", and "*".
+// Empty pattern matches all the hosts.
+func hostMatches(pattern string, u *url.URL) bool {
+	switch {
+	case pattern == "" || pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(strings.ToLower(u.Hostname()), pattern[1:])
+	case strings.Contains(pattern, ":"):
+		return strings.ToLower(u.Host) == pattern
+	default:
+		return strings.ToLower(u.Hostname()) == pattern
+	}
+}
+
+// SetProxies sets the proxy rules.
+// The first matching rule is used. $HTTP_PROXY, $HTTPS_PROXY, and $NO_PROXY are used when no rule matches.
+// Must be called before opening URLs.
+func (o *URLOpener) SetProxies(proxies []Proxy) {
+	o.mu.Lock()
+	o.proxies = proxies
+	o.mu.Unlock()
+}
+
+func (o *URLOpener) proxy(req *http.Request) (*url.URL, error) {
+	o.mu.Lock()
+	proxies := o.proxies
+	o.mu.Unlock()
+	for _, p := range proxies {
+		if p.Matches(req.URL) {
+			return p.URL, nil
+		}
+	}
+	return http.ProxyFromEnvironment(req)
+}
@@ -0,0 +1,78 @@
+package urlopener
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseProxy(t *testing.T) {
+	p, err := ParseProxy("socks5://proxy.example.com:1080")
+	assert.NilError(t, err)
+	assert.Equal(t, "", p.Host)
+	assert.Equal(t, "socks5://proxy.example.com:1080", p.URL.String())
+
+	p, err = ParseProxy("*.Example.com=http://proxy.example.com:3128")
+	assert.NilError(t, err)
+	assert.Equal(t, "*.example.com", p.Host)
+	assert.Equal(t, "http://proxy.example.com:3128", p.URL.String())
+
+	p, err = ParseProxy("mirror.example.com=direct")
+	assert.NilError(t, err)
+	assert.Equal(t, "mirror.example.com", p.Host)
+	assert.Assert(t, p.URL == nil)
+
+	_, err = ParseProxy("ftp://proxy.example.com")
+	assert.ErrorContains(t, err, "expected http://")
+}
+
+func TestProxyMatches(t *testing.T) {
+	testCases := []struct {
+		host     string
+		url      string
+		expected bool
+	}{
+		{"", "https://deb.debian.org/debian", true},
+		{"deb.debian.org", "https://deb.debian.org/debian", true},
+		{"deb.debian.org", "https://DEB.debian.org:443/debian", true},
+		{"deb.debian.org", "https://security.debian.org/debian", false},
+		{"*.debian.org", "https://deb.debian.org/debian", true},
+		{"*.debian.org", "https://debian.org/debian", false},
+		{"mirror.example.com:8080", "http://mirror.example.com:8080/", true},
+		{"mirror.example.com:8080", "http://mirror.example.com/", false},
+	}
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		assert.NilError(t, err)
+		p := Proxy{Host: tc.host}
+		assert.Equal(t, tc.expected, p.Matches(u), "host=%q, url=%q", tc.host, tc.url)
+	}
+}
+
+func TestOpenWithProxy(t *testing.T) {
+	var requested []string
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.String())
+		_, _ = w.Write([]byte("proxied"))
+	}))
+	defer proxySrv.Close()
+	proxyURL, err := url.Parse(proxySrv.URL)
+	assert.NilError(t, err)
+
+	o := New()
+	o.SetProxies([]Proxy{{Host: "mirror.example.com", URL: proxyURL}})
+	u, err := url.Parse("http://mirror.example.com/foo")
+	assert.NilError(t, err)
+	r, _, err := o.Open(context.TODO(), u, "")
+	assert.NilError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, "proxied", string(b))
+	assert.DeepEqual(t, []string{"http://mirror.example.com/foo"}, requested)
+}
@@ -0,0 +1,67 @@
+package urlopener
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// openRsync fetches the "rsync://" URL into a temporary file with the rsync command.
+// The temporary file is removed on closing the returned stream.
+// It is up to the caller to validate the sha256sum of the returned stream.
+func (o *URLOpener) openRsync(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+	if u.RawQuery != "" || u.Fragment != "" || u.Host == "" {
+		return nil, 0, fmt.Errorf("invalid URL %q", u.Redacted())
+	}
+	rsyncExe, err := exec.LookPath("rsync")
+	if err != nil {
+		return nil, 0, err
+	}
+	tmpDir, err := os.MkdirTemp("", "repro-get-rsync-")
+	if err != nil {
+		return nil, 0, err
+	}
+	tmpFile := filepath.Join(tmpDir, "blob")
+	// The password can be specified with $RSYNC_PASSWORD
+	cmd := exec.CommandContext(ctx, rsyncExe, "--no-motd", "--copy-links", "--", u.String(), tmpFile)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	if err = cmd.Run(); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, 0, fmt.Errorf("failed to run %v (stderr=%q): %w", cmd.Args, stderr.String(), err)
+	}
+	f, err := os.Open(tmpFile)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, 0, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		os.RemoveAll(tmpDir)
+		return nil, 0, err
+	}
+	return &tmpFileReadCloser{File: f, dir: tmpDir}, st.Size(), nil
+}
+
+// tmpFileReadCloser removes the temporary directory on Close.
+type tmpFileReadCloser struct {
+	*os.File
+	dir string
+}
+
+func (r *tmpFileReadCloser) Close() error {
+	err := r.File.Close()
+	if rmErr := os.RemoveAll(r.dir); rmErr != nil && err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package urlopener
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestOpenRsync(t *testing.T) {
+	// The fake rsync command records the arguments, and copies "$SRC_DIR/<PATH>" to the destination
+	binDir, srcDir := t.TempDir(), t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := `#!/bin/sh
+set -eu
+echo "$@" >` + argsFile + `
+src="$4"
+cp "` + srcDir + `/${src#rsync://*/}" "$5"
+`
+	assert.NilError(t, os.WriteFile(filepath.Join(binDir, "rsync"), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	assert.NilError(t, os.MkdirAll(filepath.Join(srcDir, "debian/pool"), 0755))
+	assert.NilError(t, os.WriteFile(filepath.Join(srcDir, "debian/pool/foo.deb"), []byte("foo"), 0644))
+
+	o := New()
+	u, err := url.Parse("rsync://mirror.example.com/debian/pool/foo.deb")
+	assert.NilError(t, err)
+	r, sz, err := o.Open(context.TODO(), u, "")
+	assert.NilError(t, err)
+	assert.Equal(t, int64(3), sz)
+	b, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, "foo", string(b))
+	tmpFile := r.(*tmpFileReadCloser).Name()
+	assert.NilError(t, r.Close())
+	_, err = os.Stat(tmpFile)
+	assert.Assert(t, os.IsNotExist(err))
+
+	args, err := os.ReadFile(argsFile)
+	assert.NilError(t, err)
+	assert.Equal(t, "--no-motd --copy-links -- rsync://mirror.example.com/debian/pool/foo.deb "+tmpFile+"\n", string(args))
+
+	u, err = url.Parse("rsync://mirror.example.com/debian/pool/bar.deb")
+	assert.NilError(t, err)
+	_, _, err = o.Open(context.TODO(), u, "")
+	assert.ErrorContains(t, err, "failed to run")
+}
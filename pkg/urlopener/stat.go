@@ -0,0 +1,146 @@
+package urlopener
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	refdocker "github.com/containerd/containerd/reference/docker"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
+	"github.com/sirupsen/logrus"
+)
+
+// Stat checks whether the URL is retrievable, without fetching the content.
+// Returns the size of the content (negative when unknown).
+// The sha256sum argument is only used for resolving the OCI URLs.
+func (o *URLOpener) Stat(ctx context.Context, u *url.URL, sha256sum string) (int64, error) {
+	switch u.Scheme {
+	case "http", "https":
+		return o.statHTTP(ctx, u)
+	case "file":
+		if u.User != nil || (u.Host != "" && u.Host != "localhost") || u.RawQuery != "" || u.Fragment != "" {
+			return 0, fmt.Errorf("invalid URL %q", u.Redacted())
+		}
+		st, err := os.Stat(u.Path)
+		if err != nil {
+			return 0, err
+		}
+		if !st.Mode().IsRegular() {
+			return 0, fmt.Errorf("not a regular file: %q", u.Path)
+		}
+		return st.Size(), nil
+	case "ipfs":
+		gwURL, err := o.ipfsGatewayURL(u)
+		if err != nil {
+			return 0, err
+		}
+		return o.statHTTP(ctx, gwURL)
+	case "rsync":
+		return o.statRsync(ctx, u)
+	case "oci", "oci+https", "oci+http":
+		return o.statOCI(ctx, u, sha256sum)
+	default:
+		return 0, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+}
+
+// statHTTP sends a HEAD request, or a ranged GET request for the first byte when HEAD is not allowed.
+func (o *URLOpener) statHTTP(ctx context.Context, u *url.URL) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		logrus.Debugf("HEAD is not allowed for %q (%s), trying a ranged GET", u.Redacted(), resp.Status)
+	default:
+		return 0, &HTTPStatusError{URL: u.Redacted(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err = o.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusPartialContent:
+		_, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return 0, fmt.Errorf("unexpected partial content for %q: %w", u.Redacted(), err)
+		}
+		return size, nil
+	default:
+		return 0, &HTTPStatusError{URL: u.Redacted(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+}
+
+// statRsync runs "rsync --list-only".
+func (o *URLOpener) statRsync(ctx context.Context, u *url.URL) (int64, error) {
+	if u.RawQuery != "" || u.Fragment != "" || u.Host == "" {
+		return 0, fmt.Errorf("invalid URL %q", u.Redacted())
+	}
+	rsyncExe, err := exec.LookPath("rsync")
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.CommandContext(ctx, rsyncExe, "--no-motd", "--copy-links", "--list-only", "--", u.String())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	logrus.Debugf("Running %v", cmd.Args)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %v (stderr=%q): %w", cmd.Args, stderr.String(), err)
+	}
+	// e.g., "-rw-r--r--         30,128 2022/11/01 00:00:00 hello_2.10-2_amd64.deb"
+	fields := strings.Fields(string(out))
+	if len(fields) < 5 || !strings.HasPrefix(fields[0], "-") {
+		return 0, fmt.Errorf("unexpected output of %v: %q", cmd.Args, string(out))
+	}
+	return strconv.ParseInt(strings.ReplaceAll(fields[1], ",", ""), 10, 64)
+}
+
+// statOCI resolves the blob by the digest.
+func (o *URLOpener) statOCI(ctx context.Context, u *url.URL, sha256sum string) (int64, error) {
+	if sha256sum == "" {
+		return 0, errors.New("sha256sum must be provided as an argument of *URLOpener.Stat()")
+	}
+	rawRef := strings.TrimPrefix(u.String(), u.Scheme+"://")
+	ref, err := refdocker.ParseDockerRef(rawRef)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse OCI ref %q: %w", rawRef, err)
+	}
+	dgst, err := sha256sums.ParseSum(sha256sum)
+	if err != nil {
+		return 0, err
+	}
+	resolver, err := o.getOCIResolver(ctx, u.Scheme, ref)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get resolver for %q", u.Redacted())
+	}
+	// Resolving a digest ref tries both the manifests and the blobs endpoints with HEAD requests
+	_, desc, err := resolver.Resolve(ctx, ref.Name()+"@"+dgst.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve %v: %v: %w", dgst, ref, err)
+	}
+	return desc.Size, nil
+}
@@ -0,0 +1,74 @@
+package urlopener
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestStat(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 1000)
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path+" "+r.Header.Get("Range"))
+		switch r.URL.Path {
+		case "/foo":
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "foo", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	ctx := context.TODO()
+	o := New()
+
+	stat := func(s string) (int64, error) {
+		u, err := url.Parse(s)
+		assert.NilError(t, err)
+		return o.Stat(ctx, u, "")
+	}
+	sz, err := stat(srv.URL + "/foo")
+	assert.NilError(t, err)
+	assert.Equal(t, int64(len(content)), sz)
+
+	sz, err = stat(srv.URL + "/no-head")
+	assert.NilError(t, err)
+	assert.Equal(t, int64(len(content)), sz)
+
+	_, err = stat(srv.URL + "/bar")
+	var statusErr *HTTPStatusError
+	assert.Assert(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	assert.DeepEqual(t, []string{
+		"HEAD /foo ",
+		"HEAD /no-head ",
+		"GET /no-head bytes=0-0",
+		"HEAD /bar ",
+	}, methods)
+
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "foo"), content, 0644))
+	sz, err = stat("file://" + dir + "/foo")
+	assert.NilError(t, err)
+	assert.Equal(t, int64(len(content)), sz)
+	_, err = stat("file://" + dir)
+	assert.ErrorContains(t, err, "not a regular file")
+	_, err = stat("file://" + dir + "/bar")
+	assert.Assert(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,101 @@
+package urlopener
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TLS is a TLS configuration rule for the HTTPS URLs and the OCI registries.
+type TLS struct {
+	// Host is the host name pattern, such as "mirror.example.com", "mirror.example.com:8443", "*.example.com", and "*".
+	// Empty matches all the hosts.
+	Host string
+	// CACert is the PEM file of the CA certificates, appended to the system CA pool.
+	CACert string
+	// ClientCert and ClientKey are the PEM files of the client certificate and the key, for mTLS.
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables the verification of the server certificates.
+	InsecureSkipVerify bool
+}
+
+type tlsRule struct {
+	TLS
+	transport http.RoundTripper
+}
+
+// Config returns the *tls.Config.
+func (t *TLS) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify, //nolint:gosec // opt-in
+	}
+	if t.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to load the system CA pool")
+			pool = x509.NewCertPool()
+		}
+		b, err := os.ReadFile(t.CACert)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificate was found in %q", t.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+	if t.ClientCert != "" || t.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate %q and the key %q: %w", t.ClientCert, t.ClientKey, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// SetTLS sets the TLS configuration rules.
+// The first matching rule is used. The system default is used when no rule matches.
+// Must be called before opening URLs.
+func (o *URLOpener) SetTLS(rules []TLS) error {
+	compiled := make([]tlsRule, len(rules))
+	for i, r := range rules {
+		cfg, err := r.Config()
+		if err != nil {
+			return err
+		}
+		if r.InsecureSkipVerify {
+			host := r.Host
+			if host == "" {
+				host = "*"
+			}
+			logrus.Warnf("INSECURE: TLS certificate verification is disabled for %q. "+
+				"The SHA256 of the files are still verified, but the credentials and the metadata are exposed to man-in-the-middle attacks.", host)
+		}
+		tr := o.newTransport()
+		tr.TLSClientConfig = cfg
+		compiled[i] = tlsRule{TLS: r, transport: tr}
+	}
+	o.mu.Lock()
+	o.tlsRules = compiled
+	o.mu.Unlock()
+	return nil
+}
+
+func (o *URLOpener) tlsRule(u *url.URL) *tlsRule {
+	o.mu.Lock()
+	rules := o.tlsRules
+	o.mu.Unlock()
+	for i := range rules {
+		if hostMatches(rules[i].Host, u) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
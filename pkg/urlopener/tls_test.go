@@ -0,0 +1,58 @@
+package urlopener
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestOpenWithTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("foo"))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/foo")
+	assert.NilError(t, err)
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NilError(t, os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+
+	open := func(t *testing.T, rules []TLS) error {
+		o := New()
+		assert.NilError(t, o.SetTLS(rules))
+		r, _, err := o.Open(context.TODO(), u, "")
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		assert.NilError(t, err)
+		assert.Equal(t, "foo", string(b))
+		return nil
+	}
+
+	t.Run("default", func(t *testing.T) {
+		assert.ErrorContains(t, open(t, nil), "certificate")
+	})
+	t.Run("ca-cert", func(t *testing.T) {
+		assert.NilError(t, open(t, []TLS{{Host: u.Hostname(), CACert: caCert}}))
+	})
+	t.Run("ca-cert-other-host", func(t *testing.T) {
+		assert.ErrorContains(t, open(t, []TLS{{Host: "mirror.example.com", CACert: caCert}}), "certificate")
+	})
+	t.Run("insecure-skip-verify", func(t *testing.T) {
+		assert.NilError(t, open(t, []TLS{{Host: "*", InsecureSkipVerify: true}}))
+	})
+	t.Run("invalid-ca-cert", func(t *testing.T) {
+		invalid := filepath.Join(t.TempDir(), "invalid.pem")
+		assert.NilError(t, os.WriteFile(invalid, []byte("invalid"), 0644))
+		assert.ErrorContains(t, New().SetTLS([]TLS{{CACert: invalid}}), "no certificate")
+	})
+}
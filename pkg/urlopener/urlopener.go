@@ -8,26 +8,68 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
 	refdocker "github.com/containerd/containerd/reference/docker"
 	"github.com/containerd/containerd/remotes"
 	"github.com/containerd/containerd/remotes/docker"
+	dockerconfig "github.com/containerd/containerd/remotes/docker/config"
 	"github.com/containerd/nerdctl/pkg/imgutil/dockerconfigresolver"
-	"github.com/opencontainers/go-digest"
+	"github.com/reproducible-containers/repro-get/pkg/sha256sums"
 )
 
 func New() *URLOpener {
 	o := &URLOpener{
 		resolvers: make(map[string]remotes.Resolver),
 	}
+	o.client = &http.Client{
+		Transport: &roundTripper{o: o, fallback: o.newTransport(), auth: true},
+	}
 	return o
 }
 
 type URLOpener struct {
 	mu        sync.Mutex
 	resolvers map[string]remotes.Resolver
+	client    *http.Client
+	proxies   []Proxy
+	tlsRules  []tlsRule
+	// credSources are used only for the HTTP(S) URLs, as the OCI registries use $DOCKER_CONFIG
+	credSources []CredentialSource
+	ipfsGateway *url.URL
+}
+
+func (o *URLOpener) newTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = o.proxy
+	return tr
+}
+
+// roundTripper uses the transport of the matching TLS rule, or the fallback transport.
+// When auth is true, the credential for the host is attached to the request.
+type roundTripper struct {
+	o        *URLOpener
+	fallback http.RoundTripper
+	auth     bool
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.auth && req.Header.Get("Authorization") == "" {
+		cred, err := rt.o.credential(req.Context(), req.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the credential for %q: %w", req.URL.Host, err)
+		}
+		if cred != nil {
+			req = req.Clone(req.Context())
+			cred.apply(req)
+		}
+	}
+	if r := rt.o.tlsRule(req.URL); r != nil {
+		return r.transport.RoundTrip(req)
+	}
+	return rt.fallback.RoundTrip(req)
 }
 
 var Schemes = []string{
@@ -37,67 +79,174 @@ var Schemes = []string{
 	"oci",
 	"oci+http",
 	"oci+https",
+	"ipfs",
+	"rsync",
 }
 
 // Open opens the URL.
 // The sha256sum argument is only used for resolving the OCI URLs.
 // It is up to the caller to validate the sha256sum of the returned stream.
 func (o *URLOpener) Open(ctx context.Context, u *url.URL, sha256sum string) (io.ReadCloser, int64, error) {
+	r, _, sz, err := o.OpenFrom(ctx, u, sha256sum, 0)
+	return r, sz, err
+}
+
+// OpenFrom opens the URL, skipping the first offset bytes when supported, e.g., with an HTTP Range request.
+// Returns the offset where the returned stream starts (zero when skipping is not supported),
+// and the size of the whole content (negative when unknown).
+func (o *URLOpener) OpenFrom(ctx context.Context, u *url.URL, sha256sum string, offset int64) (io.ReadCloser, int64, int64, error) {
 	switch u.Scheme {
 	case "http", "https":
-		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
-		if err != nil {
-			return nil, 0, err
-		}
-		req = req.WithContext(ctx)
-		client := http.DefaultClient
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, 0, err
-		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			return nil, 0, fmt.Errorf("expected HTTP status %d for %q, got %s", http.StatusOK, u.Redacted(), resp.Status)
-		}
-		return resp.Body, resp.ContentLength, nil
+		return o.openHTTP(ctx, u, offset)
 	case "file":
-		if u.User != nil || u.Host != "" || u.RawQuery != "" || u.Fragment != "" {
-			return nil, 0, fmt.Errorf("invalid URL %q", u.Redacted())
+		if u.User != nil || (u.Host != "" && u.Host != "localhost") || u.RawQuery != "" || u.Fragment != "" {
+			return nil, 0, 0, fmt.Errorf("invalid URL %q", u.Redacted())
 		}
 		file := u.Path
 		st, err := os.Stat(file)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, 0, err
 		}
 		f, err := os.Open(file)
-		return f, st.Size(), err
-	case "oci", "oci+https", "oci+http":
-		if sha256sum == "" {
-			return nil, 0, errors.New("sha256sum must be provided as an argument of *URLOpener.Open()")
-		}
-		rawRef := strings.TrimPrefix(u.String(), u.Scheme+"://")
-		ref, err := refdocker.ParseDockerRef(rawRef)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to parse OCI ref %q: %w", rawRef, err)
+			return nil, 0, 0, err
 		}
-		dgst := digest.NewDigestFromHex(digest.SHA256.String(), sha256sum)
-		resolver, err := o.getOCIResolver(ctx, u.Scheme, ref)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to get resolver for %q", u.Redacted())
+		if offset <= 0 || offset > st.Size() {
+			return f, 0, st.Size(), nil
+		}
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, 0, 0, err
 		}
-		// No need to call resolver.Resolve() here, as we do not care about the OCI manifests
-		fetcher, err := resolver.Fetcher(ctx, ref.String())
+		return f, offset, st.Size(), nil
+	case "ipfs":
+		gwURL, err := o.ipfsGatewayURL(u)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to get fetcher for %v: %v: %w", dgst, ref, err)
+			return nil, 0, 0, err
+		}
+		return o.openHTTP(ctx, gwURL, offset)
+	case "rsync":
+		r, sz, err := o.openRsync(ctx, u)
+		return r, 0, sz, err
+	case "oci", "oci+https", "oci+http":
+		r, sz, err := o.openOCI(ctx, u, sha256sum)
+		return r, 0, sz, err
+	default:
+		return nil, 0, 0, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+}
+
+func (o *URLOpener) openHTTP(ctx context.Context, u *url.URL, offset int64) (io.ReadCloser, int64, int64, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	req = req.WithContext(ctx)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, 0, resp.ContentLength, nil
+	case http.StatusPartialContent:
+		start, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && start != offset {
+			err = fmt.Errorf("expected the range to start at %d, got %d", offset, start)
 		}
-		r, sz, err := fetcher.(remotes.FetcherByDigest).FetchByDigest(ctx, dgst)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to get reader for %v: %v: %w", dgst, ref, err)
+			resp.Body.Close()
+			return nil, 0, 0, fmt.Errorf("unexpected partial content for %q: %w", u.Redacted(), err)
+		}
+		return resp.Body, start, size, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The content may have been shrunk
+		resp.Body.Close()
+		if offset > 0 {
+			return o.openHTTP(ctx, u, 0)
 		}
-		return r, sz, nil
 	default:
-		return nil, 0, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+		resp.Body.Close()
+	}
+	return nil, 0, 0, &HTTPStatusError{URL: u.Redacted(), StatusCode: resp.StatusCode, Status: resp.Status}
+}
+
+// HTTPStatusError is returned when the HTTP server responded with an unexpected status.
+type HTTPStatusError struct {
+	URL        string // redacted
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("expected HTTP status %d for %q, got %s", http.StatusOK, e.URL, e.Status)
+}
+
+// parseContentRange parses the Content-Range header such as "bytes 100-199/200",
+// and returns the start offset and the size of the whole content (negative when unknown).
+func parseContentRange(s string) (int64, int64, error) {
+	rng, ok := cutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("unsupported Content-Range %q", s)
+	}
+	startEnd, sizeStr, ok := strings.Cut(rng, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	startStr, _, ok := strings.Cut(startEnd, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q: %w", s, err)
+	}
+	size := int64(-1)
+	if sizeStr != "*" {
+		if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid Content-Range %q: %w", s, err)
+		}
+	}
+	return start, size, nil
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
 	}
+	return s[len(prefix):], true
+}
+
+func (o *URLOpener) openOCI(ctx context.Context, u *url.URL, sha256sum string) (io.ReadCloser, int64, error) {
+	if sha256sum == "" {
+		return nil, 0, errors.New("sha256sum must be provided as an argument of *URLOpener.Open()")
+	}
+	rawRef := strings.TrimPrefix(u.String(), u.Scheme+"://")
+	ref, err := refdocker.ParseDockerRef(rawRef)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to parse OCI ref %q: %w", rawRef, err)
+	}
+	dgst, err := sha256sums.ParseSum(sha256sum)
+	if err != nil {
+		return nil, 0, err
+	}
+	resolver, err := o.getOCIResolver(ctx, u.Scheme, ref)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get resolver for %q", u.Redacted())
+	}
+	// No need to call resolver.Resolve() here, as we do not care about the OCI manifests
+	fetcher, err := resolver.Fetcher(ctx, ref.String())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get fetcher for %v: %v: %w", dgst, ref, err)
+	}
+	r, sz, err := fetcher.(remotes.FetcherByDigest).FetchByDigest(ctx, dgst)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get reader for %v: %v: %w", dgst, ref, err)
+	}
+	return r, sz, nil
 }
 
 func (o *URLOpener) getOCIResolver(ctx context.Context, scheme string, ref refdocker.Named) (remotes.Resolver, error) {
@@ -123,11 +272,21 @@ func (o *URLOpener) getOCIResolver(ctx context.Context, scheme string, ref refdo
 	default:
 		return nil, fmt.Errorf("expected oci://, oci+http://, or oci+https, got %q", scheme)
 	}
-	var err error
-	resolver, err = dockerconfigresolver.New(ctx, refDomain, dOpts...)
+	ho, err := dockerconfigresolver.NewHostOptions(ctx, refDomain, dOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a resolver for refDomain=%q (ref=%q): %w", refDomain, ref, err)
 	}
+	ho.UpdateClient = func(client *http.Client) error {
+		if tr, ok := client.Transport.(*http.Transport); ok {
+			tr.Proxy = o.proxy
+		}
+		client.Transport = &roundTripper{o: o, fallback: client.Transport}
+		return nil
+	}
+	resolver = docker.NewResolver(docker.ResolverOptions{
+		Tracker: dockerconfigresolver.PushTracker,
+		Hosts:   dockerconfig.ConfigureHosts(ctx, *ho),
+	})
 	o.mu.Lock()
 	o.resolvers[k] = resolver
 	o.mu.Unlock()